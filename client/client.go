@@ -0,0 +1,707 @@
+// Package client is a Go SDK for robot controllers. RobotClient speaks the
+// /robot/session WebSocket protocol: it streams audio and frames, delivers
+// transcripts, intentions and commands to callbacks, keeps the connection
+// alive with heartbeats and reconnects to the same session when it drops.
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/codec"
+	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrNotConnected = errors.New("robot client not connected")
+	ErrClosed       = errors.New("robot client closed")
+)
+
+// Options configures a RobotClient. Only ServerURL is required.
+type Options struct {
+	// ServerURL is the server base URL, e.g. https://perceptus.example.com
+	ServerURL string
+	APIKey    string
+
+	// RobotModel and Profile label the session's metrics; Modalities limits
+	// the session to e.g. "audio,text"
+	RobotModel string
+	Profile    string
+	Modalities []string
+
+	// UserID and RobotID identify the speaker and the robot across sessions,
+	// so the server remembers their preferences
+	UserID  string
+	RobotID string
+
+	// Metadata is attached to the session (site, firmware_version,
+	// operator, ...) and propagated to its logs, orchestrator payloads and
+	// webhooks
+	Metadata map[string]string
+
+	// HeartbeatInterval is how often a ping is sent (default 10s); the
+	// connection is considered dead after HeartbeatTimeout without any
+	// message from the server (default 3 intervals)
+	HeartbeatInterval time.Duration
+	HeartbeatTimeout  time.Duration
+
+	// DisableReconnect turns off reconnecting; MaxReconnectAttempts caps the
+	// attempts per outage (0 = unlimited) and MaxBackoff the wait between
+	// them (default 30s)
+	DisableReconnect     bool
+	MaxReconnectAttempts int
+	MaxBackoff           time.Duration
+
+	// MessagePack asks the server for the perceptus.msgpack.v1 subprotocol,
+	// which encodes messages as MessagePack and sends audio and frames as
+	// raw bytes; servers that don't offer it are spoken to in JSON
+	MessagePack bool
+
+	Dialer *websocket.Dialer
+	Logger *zap.Logger
+}
+
+// RobotClient is a connection to one robot session. Callbacks run on the
+// client's read goroutine one at a time and must not block.
+type RobotClient struct {
+	opts   Options
+	logger *zap.Logger
+
+	mu         sync.Mutex
+	writeMu    sync.Mutex
+	conn       *websocket.Conn
+	state      string
+	sessionID  string
+	lastConfig map[string]interface{}
+	closed     bool
+	stopped    chan struct{}
+	done       chan struct{}
+
+	onTranscript func(Transcript)
+	onIntention  func(models.IntentionResult)
+	onCommand    func(Command)
+	onScene      func(models.EnvironmentContext)
+	onAnswer     func(SceneAnswer)
+	onMessage    func(Message)
+	onState      func(state string, err error)
+	onError      func(error)
+}
+
+func NewRobotClient(opts Options) *RobotClient {
+	if opts.HeartbeatInterval <= 0 {
+		opts.HeartbeatInterval = 10 * time.Second
+	}
+	if opts.HeartbeatTimeout <= 0 {
+		opts.HeartbeatTimeout = 3 * opts.HeartbeatInterval
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = 30 * time.Second
+	}
+	if opts.Dialer == nil {
+		opts.Dialer = websocket.DefaultDialer
+	}
+	if opts.Logger == nil {
+		opts.Logger = zap.L()
+	}
+	return &RobotClient{
+		opts:    opts,
+		logger:  opts.Logger.With(zap.String("component", "robot_client")),
+		state:   STATE_CLOSED,
+		stopped: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+// OnTranscript is called for interim and final transcripts.
+func (c *RobotClient) OnTranscript(fn func(Transcript)) { c.onTranscript = fn }
+
+// OnIntention is called for every intention analysis, including ones with
+// no clear intention and ones awaiting confirmation.
+func (c *RobotClient) OnIntention(fn func(models.IntentionResult)) { c.onIntention = fn }
+
+// OnCommand is called for display content and confirmation questions.
+func (c *RobotClient) OnCommand(fn func(Command)) { c.onCommand = fn }
+
+// OnScene is called for every video analysis.
+func (c *RobotClient) OnScene(fn func(models.EnvironmentContext)) { c.onScene = fn }
+
+// OnSceneAnswer is called with the answers to AskAboutScene.
+func (c *RobotClient) OnSceneAnswer(fn func(SceneAnswer)) { c.onAnswer = fn }
+
+// OnMessage is called for every server message, before the typed callbacks.
+func (c *RobotClient) OnMessage(fn func(Message)) { c.onMessage = fn }
+
+// OnStateChange is called when the connection state changes, with the error
+// that caused a reconnect or close.
+func (c *RobotClient) OnStateChange(fn func(state string, err error)) { c.onState = fn }
+
+// OnError is called with a *ProtocolError for rejected messages and a
+// *ServerError for failures reported by the server.
+func (c *RobotClient) OnError(fn func(error)) { c.onError = fn }
+
+// SessionID returns the server's ID of the session, empty before the first
+// connect.
+func (c *RobotClient) SessionID() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.sessionID
+}
+
+// State returns one of the STATE_* constants.
+func (c *RobotClient) State() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state
+}
+
+// Connect opens the session and returns once the server has welcomed it.
+// The connection is then kept alive in the background until Close.
+func (c *RobotClient) Connect(ctx context.Context) error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return ErrClosed
+	}
+	if c.state != STATE_CLOSED {
+		c.mu.Unlock()
+		return errors.New("robot client already connected")
+	}
+	c.mu.Unlock()
+
+	c.setState(STATE_CONNECTING, nil)
+	conn, err := c.dial(ctx)
+	if err != nil {
+		c.setState(STATE_CLOSED, err)
+		return err
+	}
+	c.install(conn)
+	go c.run(conn)
+	go c.heartbeat()
+	return nil
+}
+
+// dial connects and waits for the welcome message, resuming the previous
+// session when there is one.
+func (c *RobotClient) dial(ctx context.Context) (*websocket.Conn, error) {
+	sessionURL, err := c.sessionURL()
+	if err != nil {
+		return nil, err
+	}
+
+	header := http.Header{}
+	if c.opts.APIKey != "" {
+		header.Set("Authorization", "Bearer "+c.opts.APIKey)
+	}
+	dialer := *c.opts.Dialer
+	if c.opts.MessagePack {
+		dialer.Subprotocols = []string{codec.SUBPROTOCOL_MSGPACK}
+	}
+	conn, resp, err := dialer.DialContext(ctx, sessionURL, header)
+	if err != nil {
+		if resp != nil {
+			return nil, fmt.Errorf("failed to connect: %w (%s)", err, resp.Status)
+		}
+		return nil, fmt.Errorf("failed to connect: %w", err)
+	}
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(c.opts.HeartbeatTimeout)
+	}
+	conn.SetReadDeadline(deadline)
+	for {
+		msg, err := readMessage(conn)
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("no welcome from server: %w", err)
+		}
+		if msg.Type == "protocol_error" {
+			protocolErr := &ProtocolError{}
+			json.Unmarshal(msg.Data, protocolErr)
+			conn.Close()
+			return nil, protocolErr
+		}
+		if msg.Type != "text" {
+			continue
+		}
+		var welcome sessionText
+		if err := json.Unmarshal(msg.Data, &welcome); err != nil || welcome.SessionID == "" {
+			continue
+		}
+
+		c.mu.Lock()
+		previous := c.sessionID
+		c.sessionID = welcome.SessionID
+		c.mu.Unlock()
+		if previous != "" && previous != welcome.SessionID {
+			c.logger.Warn("Session could not be resumed, continuing in a new one",
+				zap.String("previous_session_id", previous), zap.String("session_id", welcome.SessionID))
+		}
+		c.logger.Info("Connected to robot session",
+			zap.String("session_id", welcome.SessionID), zap.Bool("resumed", welcome.Resumed))
+		return conn, nil
+	}
+}
+
+func (c *RobotClient) sessionURL() (string, error) {
+	u, err := url.Parse(c.opts.ServerURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid server URL: %w", err)
+	}
+	switch u.Scheme {
+	case "http":
+		u.Scheme = "ws"
+	case "https":
+		u.Scheme = "wss"
+	case "ws", "wss":
+	default:
+		return "", fmt.Errorf("unsupported server URL scheme %q", u.Scheme)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/robot/session"
+
+	query := url.Values{}
+	if c.opts.RobotModel != "" {
+		query.Set("robot_model", c.opts.RobotModel)
+	}
+	if c.opts.Profile != "" {
+		query.Set("profile", c.opts.Profile)
+	}
+	if c.opts.UserID != "" {
+		query.Set("user_id", c.opts.UserID)
+	}
+	if c.opts.RobotID != "" {
+		query.Set("robot_id", c.opts.RobotID)
+	}
+	if len(c.opts.Metadata) > 0 {
+		metadata, err := json.Marshal(c.opts.Metadata)
+		if err != nil {
+			return "", fmt.Errorf("encode metadata: %w", err)
+		}
+		query.Set("metadata", string(metadata))
+	}
+	if len(c.opts.Modalities) > 0 {
+		query.Set("modalities", strings.Join(c.opts.Modalities, ","))
+	}
+	if sessionID := c.SessionID(); sessionID != "" {
+		query.Set("resume_session_id", sessionID)
+	}
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// install makes conn the active connection and replays the last config.
+func (c *RobotClient) install(conn *websocket.Conn) {
+	conn.SetReadDeadline(time.Now().Add(c.opts.HeartbeatTimeout))
+	c.mu.Lock()
+	c.conn = conn
+	config := c.lastConfig
+	c.mu.Unlock()
+	c.setState(STATE_CONNECTED, nil)
+
+	if config != nil {
+		if err := c.send("config", config); err != nil {
+			c.logger.Warn("Failed to restore session config", zap.Error(err))
+		}
+	}
+}
+
+// run reads messages until the connection drops, then reconnects.
+func (c *RobotClient) run(conn *websocket.Conn) {
+	for {
+		err := c.read(conn)
+
+		c.mu.Lock()
+		c.conn = nil
+		closed := c.closed
+		c.mu.Unlock()
+		conn.Close()
+
+		if closed || c.opts.DisableReconnect {
+			c.setState(STATE_CLOSED, err)
+			close(c.done)
+			return
+		}
+
+		c.logger.Warn("Robot session connection lost", zap.Error(err))
+		c.setState(STATE_RECONNECTING, err)
+		conn = c.reconnect()
+		if conn == nil {
+			close(c.done)
+			return
+		}
+		c.install(conn)
+	}
+}
+
+func (c *RobotClient) read(conn *websocket.Conn) error {
+	for {
+		msg, err := readMessage(conn)
+		if err != nil {
+			return err
+		}
+		conn.SetReadDeadline(time.Now().Add(c.opts.HeartbeatTimeout))
+		c.dispatch(msg)
+	}
+}
+
+// reconnect retries with jittered exponential backoff and returns nil once
+// it gives up or the client is closed.
+func (c *RobotClient) reconnect() *websocket.Conn {
+	backoff := 500 * time.Millisecond
+	for attempt := 1; c.opts.MaxReconnectAttempts == 0 || attempt <= c.opts.MaxReconnectAttempts; attempt++ {
+		wait := backoff/2 + time.Duration(rand.Int63n(int64(backoff)))
+		select {
+		case <-c.stopped:
+			c.setState(STATE_CLOSED, ErrClosed)
+			return nil
+		case <-time.After(wait):
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), c.opts.HeartbeatTimeout)
+		conn, err := c.dial(ctx)
+		cancel()
+		if err == nil {
+			return conn
+		}
+		c.logger.Warn("Reconnect failed", zap.Int("attempt", attempt), zap.Error(err))
+		backoff = min(2*backoff, c.opts.MaxBackoff)
+	}
+
+	err := fmt.Errorf("gave up reconnecting after %d attempts", c.opts.MaxReconnectAttempts)
+	c.mu.Lock()
+	c.closed = true
+	c.mu.Unlock()
+	c.setState(STATE_CLOSED, err)
+	return nil
+}
+
+func (c *RobotClient) heartbeat() {
+	ticker := time.NewTicker(c.opts.HeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.stopped:
+			return
+		case <-c.done:
+			return
+		case <-ticker.C:
+			// Failures surface as read errors and trigger a reconnect
+			c.send("ping", nil)
+		}
+	}
+}
+
+func (c *RobotClient) dispatch(msg Message) {
+	if c.onMessage != nil {
+		c.onMessage(msg)
+	}
+
+	switch msg.Type {
+	case "transcript_interim", "transcript_final":
+		if c.onTranscript == nil {
+			return
+		}
+		var payload transcriptPayload
+		if err := json.Unmarshal(msg.Data, &payload); err == nil {
+			c.onTranscript(Transcript{Text: payload.Transcript, Final: msg.Type == "transcript_final", Source: payload.Source})
+		}
+	case "intention_analysis":
+		if c.onIntention == nil {
+			return
+		}
+		var intention models.IntentionResult
+		if err := json.Unmarshal(msg.Data, &intention); err == nil {
+			c.onIntention(intention)
+		}
+	case "video_analysis":
+		if c.onScene == nil {
+			return
+		}
+		var scene models.EnvironmentContext
+		if err := json.Unmarshal(msg.Data, &scene); err == nil {
+			c.onScene(scene)
+		}
+	case "scene_answer":
+		if c.onAnswer == nil {
+			return
+		}
+		var answer SceneAnswer
+		if err := json.Unmarshal(msg.Data, &answer); err == nil {
+			c.onAnswer(answer)
+		}
+	case COMMAND_DISPLAY:
+		if c.onCommand == nil {
+			return
+		}
+		var content models.DisplayContent
+		if err := json.Unmarshal(msg.Data, &content); err == nil {
+			c.onCommand(Command{Type: COMMAND_DISPLAY, Display: &content})
+		}
+	case COMMAND_CONFIRM:
+		if c.onCommand == nil {
+			return
+		}
+		var confirmation Confirmation
+		if err := json.Unmarshal(msg.Data, &confirmation); err == nil {
+			c.onCommand(Command{Type: COMMAND_CONFIRM, Confirmation: &confirmation})
+		}
+	case COMMAND_CAPTURE:
+		if c.onCommand == nil {
+			return
+		}
+		var capture CaptureRequest
+		if err := json.Unmarshal(msg.Data, &capture); err == nil {
+			c.onCommand(Command{Type: COMMAND_CAPTURE, Capture: &capture})
+		}
+	case COMMAND_CAPTURE_FREQUENCY:
+		if c.onCommand == nil {
+			return
+		}
+		var frequency CaptureFrequency
+		if err := json.Unmarshal(msg.Data, &frequency); err == nil {
+			c.onCommand(Command{Type: COMMAND_CAPTURE_FREQUENCY, CaptureFrequency: &frequency})
+		}
+	case COMMAND_CAMERA_CONTROL:
+		if c.onCommand == nil {
+			return
+		}
+		var control CameraControl
+		if err := json.Unmarshal(msg.Data, &control); err == nil && control.Action != "state" {
+			c.onCommand(Command{Type: COMMAND_CAMERA_CONTROL, CameraControl: &control})
+		}
+	case COMMAND_PROMPT_USER:
+		if c.onCommand == nil {
+			return
+		}
+		var prompt UserPrompt
+		if err := json.Unmarshal(msg.Data, &prompt); err == nil {
+			c.onCommand(Command{Type: COMMAND_PROMPT_USER, Prompt: &prompt})
+		}
+	case COMMAND_POWER_MODE:
+		if c.onCommand == nil {
+			return
+		}
+		var mode PowerMode
+		if err := json.Unmarshal(msg.Data, &mode); err == nil {
+			c.onCommand(Command{Type: COMMAND_POWER_MODE, PowerMode: &mode})
+		}
+	case "protocol_error":
+		protocolErr := &ProtocolError{}
+		json.Unmarshal(msg.Data, protocolErr)
+		c.logger.Warn("Server rejected message", zap.Error(protocolErr))
+		if c.onError != nil {
+			c.onError(protocolErr)
+		}
+	case "error":
+		serverErr := &ServerError{}
+		json.Unmarshal(msg.Data, serverErr)
+		c.logger.Warn("Server reported an error", zap.Error(serverErr))
+		if c.onError != nil {
+			c.onError(serverErr)
+		}
+	case "text":
+		var text sessionText
+		if err := json.Unmarshal(msg.Data, &text); err == nil && text.ProtocolVersion == "" && c.isClosing() {
+			// Stop confirmation
+			c.mu.Lock()
+			if c.conn != nil {
+				c.conn.Close()
+			}
+			c.mu.Unlock()
+		}
+	}
+}
+
+func (c *RobotClient) setState(state string, err error) {
+	c.mu.Lock()
+	changed := c.state != state
+	c.state = state
+	c.mu.Unlock()
+	if changed && c.onState != nil {
+		c.onState(state, err)
+	}
+}
+
+func (c *RobotClient) isClosing() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closed
+}
+
+func (c *RobotClient) send(msgType string, data interface{}) error {
+	return c.sendMessage(map[string]interface{}{"type": msgType, "data": data})
+}
+
+// sendMessage stamps and writes a message in the connection's encoding.
+func (c *RobotClient) sendMessage(msg map[string]interface{}) error {
+	c.mu.Lock()
+	conn := c.conn
+	c.mu.Unlock()
+	if conn == nil {
+		return ErrNotConnected
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	conn.SetWriteDeadline(time.Now().Add(c.opts.HeartbeatTimeout))
+	msg["timestamp"] = time.Now()
+	if conn.Subprotocol() != codec.SUBPROTOCOL_MSGPACK {
+		return conn.WriteJSON(msg)
+	}
+	encoded, err := codec.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return conn.WriteMessage(websocket.BinaryMessage, encoded)
+}
+
+// readMessage reads a message in the connection's encoding. Data of
+// MessagePack messages is converted to JSON for the callbacks.
+func readMessage(conn *websocket.Conn) (Message, error) {
+	var msg Message
+	if conn.Subprotocol() != codec.SUBPROTOCOL_MSGPACK {
+		err := conn.ReadJSON(&msg)
+		return msg, err
+	}
+	_, raw, err := conn.ReadMessage()
+	if err != nil {
+		return msg, err
+	}
+	if err := codec.Unmarshal(raw, &msg); err != nil {
+		return msg, fmt.Errorf("invalid MessagePack message: %w", err)
+	}
+	return msg, nil
+}
+
+// SendAudio streams an audio chunk in the encoding the server expects
+// (webm/opus by default, or its AUDIO_ENCODING). Chunks sent while
+// reconnecting fail with ErrNotConnected.
+func (c *RobotClient) SendAudio(chunk []byte) error {
+	// []byte is base64 in JSON and raw bytes in MessagePack
+	return c.send("audio_data", chunk)
+}
+
+// SendFrame sends a JPEG frame for scene analysis.
+func (c *RobotClient) SendFrame(jpeg []byte) error {
+	return c.send("video_data", jpeg)
+}
+
+// SendCapture answers a COMMAND_CAPTURE with its frame. The request ID lets
+// the server match the analysis to the request.
+func (c *RobotClient) SendCapture(requestID string, jpeg []byte) error {
+	return c.sendMessage(map[string]interface{}{"type": "video_data", "data": jpeg, "request_id": requestID})
+}
+
+// SendDepth sends a 16-bit grayscale PNG depth map registered to the RGB
+// camera; send it right before the frame it belongs to. scale is meters per
+// unit (0 for millimeters).
+func (c *RobotClient) SendDepth(depthPNG []byte, scale float64) error {
+	depth := map[string]interface{}{
+		"data":     depthPNG,
+		"encoding": "png",
+	}
+	if scale > 0 {
+		depth["scale"] = scale
+	}
+	return c.send("depth_data", depth)
+}
+
+// SendText sends a typed command, analyzed like a final transcript.
+func (c *RobotClient) SendText(text string) error {
+	return c.send("text_input", map[string]string{"text": text})
+}
+
+// AskAboutScene asks a question about what the camera sees now ("is the
+// door open?") and returns its ID. The answer arrives through OnSceneAnswer;
+// frames is how many of the latest frames to look at (0 for one).
+func (c *RobotClient) AskAboutScene(question string, frames int) (string, error) {
+	questionID := uuid.New().String()
+	request := map[string]interface{}{"question_id": questionID, "question": question}
+	if frames > 0 {
+		request["frames"] = frames
+	}
+	return questionID, c.send("ask_about_scene", request)
+}
+
+// SendConfig updates the session configuration, e.g.
+// {"video_frequency": "10s"}. The latest config is sent again after a
+// reconnect.
+func (c *RobotClient) SendConfig(config map[string]interface{}) error {
+	c.mu.Lock()
+	merged := make(map[string]interface{}, len(c.lastConfig)+len(config))
+	for key, value := range c.lastConfig {
+		merged[key] = value
+	}
+	for key, value := range config {
+		merged[key] = value
+	}
+	c.lastConfig = merged
+	c.mu.Unlock()
+	return c.send("config", config)
+}
+
+// SendRobotState reports the robot's location, position and battery.
+func (c *RobotClient) SendRobotState(state RobotState) error {
+	return c.send("robot_state", state)
+}
+
+// AckDisplay acknowledges display content received through OnCommand.
+func (c *RobotClient) AckDisplay(ack models.DisplayAck) error {
+	return c.send("display_ack", ack)
+}
+
+// SendCameraState reports the camera's settings, answering a camera_control
+// command with its RequestID or, with an empty one, after the robot changed
+// them itself. applyErr tells the server why a set request failed.
+func (c *RobotClient) SendCameraState(requestID string, settings CameraSettings, applyErr error) error {
+	state := map[string]interface{}{"action": "state", "settings": settings}
+	if requestID != "" {
+		state["request_id"] = requestID
+	}
+	if applyErr != nil {
+		state["error"] = applyErr.Error()
+	}
+	return c.send("camera_control", state)
+}
+
+// Close stops the session and waits until the server confirms or ctx ends.
+func (c *RobotClient) Close(ctx context.Context) error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+	c.closed = true
+	connected := c.conn != nil
+	c.mu.Unlock()
+	close(c.stopped)
+
+	if !connected {
+		return nil
+	}
+	if err := c.send("stop", nil); err != nil {
+		return fmt.Errorf("failed to send stop: %w", err)
+	}
+	select {
+	case <-c.done:
+		return nil
+	case <-ctx.Done():
+		c.mu.Lock()
+		if c.conn != nil {
+			c.conn.Close()
+		}
+		c.mu.Unlock()
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,216 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
+)
+
+const (
+	COMMAND_DISPLAY = "display"
+	COMMAND_CONFIRM = "intention_confirmation"
+	COMMAND_CAPTURE = "capture_request"
+	// COMMAND_CAPTURE_FREQUENCY asks the robot to capture at a new pace
+	COMMAND_CAPTURE_FREQUENCY = "capture_frequency_update"
+	// COMMAND_CAMERA_CONTROL asks for the camera's settings or changes them
+	COMMAND_CAMERA_CONTROL = "camera_control"
+	// COMMAND_PROMPT_USER asks the robot to check in with an idle user
+	COMMAND_PROMPT_USER = "prompt_user"
+	// COMMAND_POWER_MODE announces low-power mode and the return from it
+	COMMAND_POWER_MODE = "power_mode"
+)
+
+const (
+	STATE_CONNECTING   = "connecting"
+	STATE_CONNECTED    = "connected"
+	STATE_RECONNECTING = "reconnecting"
+	STATE_CLOSED       = "closed"
+)
+
+// Message is a raw server message.
+type Message struct {
+	Type      string          `json:"type"`
+	Version   string          `json:"version,omitempty"`
+	Data      json.RawMessage `json:"data"`
+	Timestamp time.Time       `json:"timestamp"`
+}
+
+// Transcript is a transcript_interim or transcript_final message. Source is
+// "text" for echoed text_input commands.
+type Transcript struct {
+	Text   string
+	Final  bool
+	Source string
+}
+
+// Command is an instruction the server sends for the robot to carry out:
+// showing display content (answer with AckDisplay), speaking a
+// confirmation question before an intention is acted on, capturing a
+// frame (answer with SendCapture), changing the capture pace, reading or
+// changing camera settings (answer with SendCameraState), prompting an idle
+// user, or switching power mode.
+type Command struct {
+	Type             string
+	Display          *models.DisplayContent
+	Confirmation     *Confirmation
+	Capture          *CaptureRequest
+	CaptureFrequency *CaptureFrequency
+	CameraControl    *CameraControl
+	Prompt           *UserPrompt
+	PowerMode        *PowerMode
+}
+
+// CaptureRequest asks the robot for a camera frame. Reason is "scheduled"
+// for server-driven capture at the video frequency, "periodic" for the
+// fixed SCHEDULED_CAPTURE_INTERVAL, "on_demand" or "scene_question".
+type CaptureRequest struct {
+	RequestID string `json:"request_id"`
+	Reason    string `json:"reason"`
+}
+
+// CaptureFrequency is the pace the server analyzes frames at after adapting
+// it to the scene or the frame budget; frames pushed faster are skipped.
+// Reason is motion, activity, calm, budget or reset.
+type CaptureFrequency struct {
+	Frequency     string  `json:"frequency"`
+	BaseFrequency string  `json:"base_frequency"`
+	Reason        string  `json:"reason"`
+	MotionScore   float64 `json:"motion_score,omitempty"`
+	BudgetUsed    float64 `json:"budget_used,omitempty"`
+}
+
+// Interval parses Frequency.
+func (f CaptureFrequency) Interval() (time.Duration, error) {
+	return time.ParseDuration(f.Frequency)
+}
+
+// UserPrompt checks in with a user who has been quiet for IdleFor. Say Text
+// with text-to-speech when Speak is set; answers are ordinary audio or text.
+type UserPrompt struct {
+	PromptID string `json:"prompt_id"`
+	Text     string `json:"text"`
+	Speak    bool   `json:"speak"`
+	Reason   string `json:"reason"`
+	IdleFor  string `json:"idle_for"`
+}
+
+// PowerMode is "low_power" after a spell of inactivity and "normal" again
+// once the user speaks. In low power the server analyzes frames at
+// VideoFrequency and, with SpeechGated, transcribes only speech, so the
+// robot can slow its camera and microphone down too.
+type PowerMode struct {
+	Mode           string `json:"mode"`
+	Reason         string `json:"reason"`
+	IdleFor        string `json:"idle_for,omitempty"`
+	VideoFrequency string `json:"video_frequency,omitempty"`
+	SpeechGated    bool   `json:"speech_gated"`
+}
+
+// CameraControl asks for the robot camera's settings (Action "get") or to
+// apply Settings (Action "set").
+type CameraControl struct {
+	RequestID string          `json:"request_id"`
+	Action    string          `json:"action"`
+	Settings  *CameraSettings `json:"settings,omitempty"`
+}
+
+// CameraSettings are camera parameters. In a set request, zero values leave
+// a parameter unchanged; a Zoom of 1 and a full-frame ROI reset those.
+type CameraSettings struct {
+	Width        int     `json:"width,omitempty"`
+	Height       int     `json:"height,omitempty"`
+	ExposureMS   float64 `json:"exposure_ms,omitempty"`
+	AutoExposure bool    `json:"auto_exposure,omitempty"`
+	Zoom         float64 `json:"zoom,omitempty"`
+	ROI          *Region `json:"roi,omitempty"`
+	Device       string  `json:"device,omitempty"`
+}
+
+// Region is a part of the frame in normalized coordinates (0-1, origin at
+// the top-left corner).
+type Region struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+// Confirmation asks the robot to speak Question and listen for a yes/no
+// answer; the answer arrives as ordinary audio or text.
+type Confirmation struct {
+	IntentionID   string                 `json:"intention_id"`
+	Question      string                 `json:"question"`
+	IntentionType string                 `json:"intention_type"`
+	Description   string                 `json:"description"`
+	Confidence    float64                `json:"confidence"`
+	Slots         map[string]interface{} `json:"slots,omitempty"`
+	ExpiresAt     time.Time              `json:"expires_at"`
+}
+
+// SceneAnswer answers an AskAboutScene question. Verdict is "yes", "no" or
+// "unknown" for yes/no questions; FrameTime is when the latest frame looked
+// at arrived.
+type SceneAnswer struct {
+	QuestionID string `json:"question_id"`
+	Question   string `json:"question"`
+	models.SceneAnswer
+	Frames    int       `json:"frames"`
+	FrameTime time.Time `json:"frame_time"`
+}
+
+// RobotState is reported to the server and served to the intention model.
+// Position holds map coordinates such as {"x": 1.2, "y": 3.4, "theta": 0}.
+type RobotState struct {
+	Location  string             `json:"location,omitempty"`
+	Position  map[string]float64 `json:"position,omitempty"`
+	Battery   *float64           `json:"battery,omitempty"`
+	Locations []string           `json:"locations,omitempty"`
+	Timezone  string             `json:"timezone,omitempty"`
+}
+
+// ProtocolError is a message the server rejected.
+type ProtocolError struct {
+	Code        string `json:"code"`
+	Message     string `json:"message"`
+	MessageType string `json:"message_type,omitempty"`
+	Field       string `json:"field,omitempty"`
+	Version     string `json:"supported_version"`
+}
+
+func (e *ProtocolError) Error() string {
+	if e.MessageType != "" {
+		return fmt.Sprintf("protocol error %s on %s: %s", e.Code, e.MessageType, e.Message)
+	}
+	return fmt.Sprintf("protocol error %s: %s", e.Code, e.Message)
+}
+
+// sessionText covers both shapes of the "text" message: the welcome sent on
+// connect and the stop confirmation.
+type sessionText struct {
+	SessionID       string `json:"session_id"`
+	Resumed         bool   `json:"resumed"`
+	Message         string `json:"message"`
+	ProtocolVersion string `json:"protocol_version"`
+}
+
+type transcriptPayload struct {
+	Transcript string `json:"transcript"`
+	Source     string `json:"source,omitempty"`
+}
+
+// ServerError is a failure the server reported while processing a valid
+// message. Category is "client" when the robot's input was unusable and
+// "server" when the server or one of its providers is unhealthy.
+type ServerError struct {
+	Code        string `json:"code"`
+	Category    string `json:"category"`
+	Retryable   bool   `json:"retryable"`
+	MessageType string `json:"message_type,omitempty"`
+	Message     string `json:"message"`
+}
+
+func (e *ServerError) Error() string {
+	return fmt.Sprintf("%s error %s: %s", e.Category, e.Code, e.Message)
+}
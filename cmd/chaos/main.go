@@ -0,0 +1,306 @@
+// Command chaos runs fault-injection scenarios against a server started with
+// TESTMODE=true and ADMIN_API_KEY set. For each scenario it installs the
+// faults through /admin/testmode, drives a robot session (text commands,
+// frames, audio) and checks that the session degrades gracefully: pings
+// keep being answered, the session never stalls and a stop is confirmed.
+// It prints a JSON report and exits non-zero when a scenario fails.
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/lpernett/godotenv"
+	"go.uber.org/zap"
+)
+
+// Scenario is a named set of faults in the TESTMODE_FAULTS format.
+type Scenario struct {
+	Name   string `json:"name"`
+	Faults string `json:"faults"`
+}
+
+var defaultScenarios = []Scenario{
+	{Name: "baseline", Faults: ""},
+	{Name: "llm_errors", Faults: "llm:error=1"},
+	{Name: "llm_slow", Faults: "llm:latency=20s"},
+	{Name: "llm_malformed", Faults: "llm:malformed=1"},
+	{Name: "stt_flaky", Faults: "stt:error=0.5"},
+	{Name: "memory_down", Faults: "memory:error=1"},
+	{Name: "memory_slow", Faults: "memory:latency=10s"},
+	{Name: "orchestrator_down", Faults: "orchestrator:error=1"},
+	{Name: "orchestrator_malformed", Faults: "orchestrator:malformed=1"},
+	{Name: "everything_flaky", Faults: "llm:latency=1s,error=0.3;stt:error=0.3;memory:error=0.5;orchestrator:malformed=0.5"},
+}
+
+// Result is the outcome of one scenario.
+type Result struct {
+	Scenario    string         `json:"scenario"`
+	Passed      bool           `json:"passed"`
+	Failures    []string       `json:"failures,omitempty"`
+	MaxPongMs   int64          `json:"max_pong_ms"`
+	Messages    map[string]int `json:"messages"`
+	DurationSec float64        `json:"duration_sec"`
+}
+
+type options struct {
+	server      *url.URL
+	apiKey      string
+	adminKey    string
+	duration    time.Duration
+	pingEvery   time.Duration
+	pongTimeout time.Duration
+	stopTimeout time.Duration
+}
+
+func main() {
+	server := flag.String("server", "http://localhost:8080", "server base URL")
+	duration := flag.Duration("duration", 30*time.Second, "how long to drive each scenario")
+	pingEvery := flag.Duration("ping-every", time.Second, "ping interval")
+	pongTimeout := flag.Duration("pong-timeout", 2*time.Second, "longest acceptable ping round trip")
+	stopTimeout := flag.Duration("stop-timeout", 5*time.Second, "longest acceptable wait for the stop confirmation")
+	scenariosFile := flag.String("scenarios", "", "JSON file with [{\"name\",\"faults\"}] (defaults to the built-in set)")
+	only := flag.String("only", "", "comma-separated scenario names to run")
+	flag.Parse()
+
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		panic("Failed to initialize logger: " + err.Error())
+	}
+	zap.ReplaceGlobals(logger)
+
+	if err := godotenv.Load(); err != nil {
+		zap.L().Warn("Error loading .env file")
+	}
+
+	base, err := url.Parse(*server)
+	if err != nil {
+		zap.L().Fatal("Invalid server URL", zap.Error(err))
+	}
+	opts := options{
+		server:      base,
+		apiKey:      os.Getenv("CHAOS_API_KEY"),
+		adminKey:    os.Getenv("ADMIN_API_KEY"),
+		duration:    *duration,
+		pingEvery:   *pingEvery,
+		pongTimeout: *pongTimeout,
+		stopTimeout: *stopTimeout,
+	}
+
+	scenarios := defaultScenarios
+	if *scenariosFile != "" {
+		data, err := os.ReadFile(*scenariosFile)
+		if err != nil {
+			zap.L().Fatal("Failed to read scenarios", zap.Error(err))
+		}
+		if err := json.Unmarshal(data, &scenarios); err != nil {
+			zap.L().Fatal("Failed to parse scenarios", zap.Error(err))
+		}
+	}
+	if *only != "" {
+		selected := map[string]bool{}
+		for _, name := range strings.Split(*only, ",") {
+			selected[strings.TrimSpace(name)] = true
+		}
+		var filtered []Scenario
+		for _, scenario := range scenarios {
+			if selected[scenario.Name] {
+				filtered = append(filtered, scenario)
+			}
+		}
+		scenarios = filtered
+	}
+
+	var results []Result
+	failed := false
+	for _, scenario := range scenarios {
+		zap.L().Info("Running scenario", zap.String("scenario", scenario.Name), zap.String("faults", scenario.Faults))
+		result := runScenario(opts, scenario)
+		if !result.Passed {
+			failed = true
+		}
+		results = append(results, result)
+	}
+	if err := setFaults(opts, ""); err != nil {
+		zap.L().Warn("Failed to clear faults", zap.Error(err))
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(results); err != nil {
+		zap.L().Fatal("Failed to write report", zap.Error(err))
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func setFaults(opts options, faults string) error {
+	body, _ := json.Marshal(map[string]string{"faults": faults})
+	req, err := http.NewRequest(http.MethodPut, opts.server.JoinPath("/admin/testmode").String(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+opts.adminKey)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("/admin/testmode returned %s", resp.Status)
+	}
+	return nil
+}
+
+type inbound struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+func runScenario(opts options, scenario Scenario) Result {
+	started := time.Now()
+	result := Result{Scenario: scenario.Name, Messages: map[string]int{}}
+	fail := func(format string, args ...interface{}) {
+		result.Failures = append(result.Failures, fmt.Sprintf(format, args...))
+	}
+	defer func() {
+		result.Passed = len(result.Failures) == 0
+		result.DurationSec = time.Since(started).Seconds()
+	}()
+
+	if err := setFaults(opts, scenario.Faults); err != nil {
+		fail("install faults: %v", err)
+		return result
+	}
+
+	wsURL := *opts.server
+	wsURL.Scheme = strings.Replace(wsURL.Scheme, "http", "ws", 1)
+	wsURL.Path = "/robot/session"
+	query := url.Values{}
+	if opts.apiKey != "" {
+		query.Set("api_key", opts.apiKey)
+	}
+	wsURL.RawQuery = query.Encode()
+
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL.String(), nil)
+	if err != nil {
+		fail("connect: %v", err)
+		return result
+	}
+	defer conn.Close()
+
+	messages := make(chan inbound, 256)
+	go func() {
+		defer close(messages)
+		for {
+			var msg inbound
+			if err := conn.ReadJSON(&msg); err != nil {
+				return
+			}
+			messages <- msg
+		}
+	}()
+
+	send := func(msgType string, data interface{}) {
+		msg := map[string]interface{}{"type": msgType, "data": data, "timestamp": time.Now()}
+		if err := conn.WriteJSON(msg); err != nil {
+			fail("send %s: %v", msgType, err)
+		}
+	}
+
+	frame := testFrame()
+	audio := base64.StdEncoding.EncodeToString(make([]byte, 3200)) // 100ms of 16kHz linear16 silence
+	commands := []string{"go to the kitchen", "what do you see", "bring me the red cup", "stop"}
+
+	ping := time.NewTicker(opts.pingEvery)
+	defer ping.Stop()
+	activity := time.NewTicker(opts.duration / 10)
+	defer activity.Stop()
+	deadline := time.After(opts.duration)
+
+	var pingSentAt time.Time
+	step := 0
+	for running := true; running; {
+		select {
+		case msg, ok := <-messages:
+			if !ok {
+				fail("connection closed by server")
+				return result
+			}
+			result.Messages[msg.Type]++
+			if msg.Type == "pong" && !pingSentAt.IsZero() {
+				rtt := time.Since(pingSentAt)
+				result.MaxPongMs = max(result.MaxPongMs, rtt.Milliseconds())
+				pingSentAt = time.Time{}
+			}
+		case <-ping.C:
+			if !pingSentAt.IsZero() && time.Since(pingSentAt) > opts.pongTimeout {
+				fail("ping unanswered after %s", time.Since(pingSentAt).Round(time.Millisecond))
+			}
+			if pingSentAt.IsZero() {
+				pingSentAt = time.Now()
+				send("ping", nil)
+			}
+		case <-activity.C:
+			send("text_input", map[string]string{"text": commands[step%len(commands)]})
+			send("video_data", frame)
+			send("audio_data", audio)
+			step++
+		case <-deadline:
+			running = false
+		}
+	}
+
+	if result.Messages["text"] == 0 {
+		fail("no welcome message")
+	}
+
+	// The stop confirmation must arrive even while providers misbehave
+	send("stop", nil)
+	stopDeadline := time.After(opts.stopTimeout)
+	for {
+		select {
+		case msg, ok := <-messages:
+			if !ok {
+				fail("connection closed without stop confirmation")
+				return result
+			}
+			result.Messages[msg.Type]++
+			if msg.Type == "text" && strings.Contains(string(msg.Data), "stopped") {
+				return result
+			}
+		case <-stopDeadline:
+			fail("no stop confirmation within %s", opts.stopTimeout)
+			return result
+		}
+	}
+}
+
+// testFrame returns a small JPEG with enough texture to pass the frame
+// quality gate.
+func testFrame() string {
+	img := image.NewRGBA(image.Rect(0, 0, 320, 240))
+	for y := 0; y < 240; y++ {
+		for x := 0; x < 320; x++ {
+			shade := uint8(60 + ((x/16+y/16)%2)*120)
+			img.Set(x, y, color.RGBA{shade, shade, shade, 255})
+		}
+	}
+	var buf bytes.Buffer
+	jpeg.Encode(&buf, img, &jpeg.Options{Quality: 80})
+	return "data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(buf.Bytes())
+}
@@ -0,0 +1,422 @@
+// Command cli (perceptus-cli) drives a robot session from the terminal. It
+// connects to /robot/session, streams the local microphone and camera
+// through ffmpeg, sends every line typed on stdin as a text_input command and
+// pretty-prints transcripts, intentions and scene analyses. Ctrl-C stops the
+// session and waits for the server to confirm.
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/handlers"
+	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+	"github.com/gorilla/websocket"
+	"github.com/lpernett/godotenv"
+)
+
+const (
+	colorReset  = "\033[0m"
+	colorDim    = "\033[2m"
+	colorRed    = "\033[31m"
+	colorGreen  = "\033[32m"
+	colorYellow = "\033[33m"
+	colorBlue   = "\033[34m"
+	colorCyan   = "\033[36m"
+)
+
+type options struct {
+	server       string
+	apiKey       string
+	mic          bool
+	micDevice    string
+	audioFormat  utils.AudioFormat
+	camera       bool
+	cameraDevice string
+	cameraSize   string
+	listCameras  bool
+	frameEvery   time.Duration
+	raw          bool
+	color        bool
+}
+
+type inbound struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+func main() {
+	godotenv.Load()
+
+	opts := options{}
+	flag.StringVar(&opts.server, "server", "http://localhost:8080", "server base URL")
+	flag.StringVar(&opts.apiKey, "api-key", os.Getenv("PERCEPTUS_API_KEY"), "tenant API key (defaults to PERCEPTUS_API_KEY)")
+	flag.BoolVar(&opts.mic, "mic", false, "stream the local microphone")
+	flag.StringVar(&opts.micDevice, "mic-device", "", "microphone device (pulse source, avfoundation index or dshow name)")
+	encoding := flag.String("audio-encoding", "", "send audio in this encoding (linear16, opus or aac), matching the server's AUDIO_ENCODING; default webm/opus")
+	sampleRate := flag.Int("sample-rate", 16000, "sample rate of raw audio, matching the server's AUDIO_SAMPLE_RATE")
+	flag.BoolVar(&opts.camera, "camera", false, "send frames from the local camera")
+	flag.StringVar(&opts.cameraDevice, "camera-device", "", "camera device: an ID or name from -list-cameras (default: the first camera)")
+	flag.StringVar(&opts.cameraSize, "camera-size", "", "camera resolution, e.g. 1920x1080 (default: the camera's)")
+	flag.BoolVar(&opts.listCameras, "list-cameras", false, "list the local cameras and exit")
+	flag.DurationVar(&opts.frameEvery, "frame-every", 5*time.Second, "interval between camera frames")
+	flag.BoolVar(&opts.raw, "raw", false, "print every message as raw JSON")
+	noColor := flag.Bool("no-color", false, "disable colored output")
+	flag.Parse()
+
+	opts.audioFormat = utils.AudioFormat{Encoding: *encoding, SampleRate: *sampleRate}
+	opts.color = !*noColor && os.Getenv("NO_COLOR") == ""
+
+	if opts.listCameras {
+		if err := listCameras(); err != nil {
+			fmt.Fprintln(os.Stderr, "perceptus-cli:", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if err := run(opts); err != nil {
+		fmt.Fprintln(os.Stderr, "perceptus-cli:", err)
+		os.Exit(1)
+	}
+}
+
+func run(opts options) error {
+	wsURL, err := url.Parse(opts.server)
+	if err != nil {
+		return fmt.Errorf("invalid server URL: %w", err)
+	}
+	wsURL.Scheme = strings.Replace(wsURL.Scheme, "http", "ws", 1)
+	wsURL.Path = "/robot/session"
+	query := url.Values{}
+	if opts.apiKey != "" {
+		query.Set("api_key", opts.apiKey)
+	}
+	wsURL.RawQuery = query.Encode()
+
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL.String(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", wsURL.Redacted(), err)
+	}
+	defer conn.Close()
+
+	p := &printer{color: opts.color, raw: opts.raw}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var writeMu sync.Mutex
+	send := func(msgType string, data interface{}) error {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		return conn.WriteJSON(map[string]interface{}{"type": msgType, "data": data, "timestamp": time.Now()})
+	}
+
+	var camera utils.Camera
+	if opts.camera {
+		if camera, err = openCamera(ctx, opts); err != nil {
+			return err
+		}
+		defer camera.Close()
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for {
+			var msg inbound
+			if err := conn.ReadJSON(&msg); err != nil {
+				if ctx.Err() == nil {
+					p.status(colorRed, "connection closed: %v", err)
+				}
+				return
+			}
+			if p.print(msg) {
+				return
+			}
+			if msg.Type == "camera_control" && camera != nil {
+				go controlCamera(ctx, camera, msg.Data, send, p)
+			}
+		}
+	}()
+
+	if opts.mic {
+		mic, err := utils.OpenMicrophone(ctx, opts.micDevice, opts.audioFormat)
+		if err != nil {
+			return err
+		}
+		defer mic.Close()
+		go streamAudio(ctx, mic, opts.audioFormat, send, p)
+		p.status(colorDim, "streaming microphone")
+	}
+	if camera != nil {
+		go streamFrames(ctx, camera, opts.frameEvery, send, p)
+		p.status(colorDim, "sending a camera frame every %s", opts.frameEvery)
+	}
+
+	// Typed lines become text_input commands
+	go func() {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			text := strings.TrimSpace(scanner.Text())
+			if text == "" {
+				continue
+			}
+			if err := send("text_input", map[string]string{"text": text}); err != nil {
+				p.status(colorRed, "failed to send text: %v", err)
+			}
+		}
+	}()
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	select {
+	case <-stopped:
+		return nil
+	case <-interrupt:
+	}
+
+	cancel()
+	p.status(colorDim, "stopping session")
+	if err := send("stop", nil); err != nil {
+		return fmt.Errorf("failed to send stop: %w", err)
+	}
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		p.status(colorYellow, "no stop confirmation within 5s")
+	}
+	return nil
+}
+
+// streamAudio sends about 100ms of audio per message.
+func streamAudio(ctx context.Context, mic io.Reader, format utils.AudioFormat, send func(string, interface{}) error, p *printer) {
+	chunkSize := 4096
+	if format.Encoding == utils.AudioEncodingLinear16 {
+		chunkSize = format.SampleRate / 10 * 2
+	}
+	buf := make([]byte, chunkSize)
+	for ctx.Err() == nil {
+		n, err := io.ReadFull(mic, buf)
+		if n > 0 {
+			if sendErr := send("audio_data", base64.StdEncoding.EncodeToString(buf[:n])); sendErr != nil {
+				p.status(colorRed, "failed to send audio: %v", sendErr)
+				return
+			}
+		}
+		if err != nil {
+			if ctx.Err() == nil {
+				p.status(colorRed, "microphone stopped: %v", err)
+			}
+			return
+		}
+	}
+}
+
+func streamFrames(ctx context.Context, camera utils.CameraCapture, every time.Duration, send func(string, interface{}) error, p *printer) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		frame, err := camera.CaptureFrame(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			p.status(colorRed, "camera capture failed: %v", err)
+		} else if err := send("video_data", "data:image/jpeg;base64,"+base64.StdEncoding.EncodeToString(frame)); err != nil {
+			p.status(colorRed, "failed to send frame: %v", err)
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// listCameras prints the local cameras -camera-device accepts.
+func listCameras() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	devices, err := utils.ListCameras(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%d camera(s), %s backend\n", len(devices), utils.CameraBackend())
+	for _, device := range devices {
+		fmt.Printf("  %-14s %s\n", device.ID, device.Name)
+	}
+	return nil
+}
+
+// openCamera opens the local camera at the requested resolution.
+func openCamera(ctx context.Context, opts options) (utils.Camera, error) {
+	var size utils.CameraSettings
+	if opts.cameraSize != "" {
+		width, height, err := utils.ParseResolution(opts.cameraSize)
+		if err != nil {
+			return nil, err
+		}
+		size = utils.CameraSettings{Width: width, Height: height}
+	}
+	camera, err := utils.OpenDeviceCamera(ctx, opts.cameraDevice)
+	if err != nil {
+		return nil, err
+	}
+	if size.Width == 0 {
+		return camera, nil
+	}
+	if _, err := camera.ApplyCameraSettings(ctx, size); err != nil {
+		camera.Close()
+		return nil, err
+	}
+	return camera, nil
+}
+
+// controlCamera answers the server's camera_control requests with the
+// camera's state after applying them.
+func controlCamera(ctx context.Context, camera utils.Camera, data json.RawMessage, send func(string, interface{}) error, p *printer) {
+	var request handlers.CameraControlPayload
+	if err := json.Unmarshal(data, &request); err != nil || request.Action == handlers.CAMERA_ACTION_STATE {
+		return
+	}
+	state := handlers.CameraControlPayload{RequestID: request.RequestID, Action: handlers.CAMERA_ACTION_STATE}
+	settings := camera.CameraSettings()
+	if request.Action == handlers.CAMERA_ACTION_SET && request.Settings != nil {
+		applied, err := camera.ApplyCameraSettings(ctx, *request.Settings)
+		settings = applied
+		if err != nil {
+			state.Error = err.Error()
+			p.status(colorRed, "camera settings not applied: %v", err)
+		} else {
+			p.status(colorDim, "camera settings applied")
+		}
+	}
+	state.Settings = &settings
+	if err := send("camera_control", state); err != nil {
+		p.status(colorRed, "failed to send camera state: %v", err)
+	}
+}
+
+type printer struct {
+	mu    sync.Mutex
+	color bool
+	raw   bool
+}
+
+func (p *printer) line(color, label, text string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stamp := time.Now().Format("15:04:05")
+	if !p.color {
+		fmt.Printf("%s %-10s %s\n", stamp, label, text)
+		return
+	}
+	fmt.Printf("%s%s%s %s%-10s%s %s\n", colorDim, stamp, colorReset, color, label, colorReset, text)
+}
+
+func (p *printer) status(color, format string, args ...interface{}) {
+	p.line(color, "cli", fmt.Sprintf(format, args...))
+}
+
+// print renders a server message and reports whether it confirms the stop.
+func (p *printer) print(msg inbound) bool {
+	if p.raw {
+		p.line(colorDim, msg.Type, string(msg.Data))
+		return msg.Type == "text" && strings.Contains(string(msg.Data), "stopped")
+	}
+
+	switch msg.Type {
+	case "pong", "video_frame":
+		// Echoes are noise in a terminal
+	case "text":
+		var started handlers.SessionStartedPayload
+		json.Unmarshal(msg.Data, &started)
+		if started.ProtocolVersion != "" {
+			p.line(colorGreen, "session", fmt.Sprintf("%s (protocol %s, modalities %s)",
+				started.SessionID, started.ProtocolVersion, strings.Join(started.Capabilities.Modalities, ", ")))
+			return false
+		}
+		var stopped handlers.SessionStoppedPayload
+		json.Unmarshal(msg.Data, &stopped)
+		p.line(colorGreen, "session", stopped.Message)
+		return strings.Contains(stopped.Message, "stopped")
+	case "transcript_interim", "transcript_final":
+		var transcript handlers.TranscriptPayload
+		json.Unmarshal(msg.Data, &transcript)
+		if msg.Type == "transcript_interim" {
+			p.line(colorDim, "heard", transcript.Transcript+" …")
+		} else {
+			p.line(colorCyan, "heard", transcript.Transcript)
+		}
+	case "intention_analysis", "intention_deduplicated":
+		var intention models.IntentionResult
+		json.Unmarshal(msg.Data, &intention)
+		if !intention.HasClearIntention {
+			p.line(colorDim, "intention", "none")
+			break
+		}
+		text := fmt.Sprintf("%s (%.0f%%) %s", intention.IntentionType, intention.Confidence*100, intention.Description)
+		if len(intention.Slots) > 0 {
+			slots, _ := json.Marshal(intention.Slots)
+			text += " " + string(slots)
+		}
+		if msg.Type == "intention_deduplicated" {
+			text += " [duplicate]"
+		}
+		if intention.AwaitingConfirmation {
+			text += " [awaiting confirmation]"
+		}
+		p.line(colorBlue, "intention", text)
+	case "intention_confirmation":
+		var confirmation handlers.IntentionConfirmationPayload
+		json.Unmarshal(msg.Data, &confirmation)
+		p.line(colorYellow, "confirm?", confirmation.Question)
+	case "video_analysis":
+		var envContext models.EnvironmentContext
+		json.Unmarshal(msg.Data, &envContext)
+		text := envContext.Overview
+		if len(envContext.KeyElements) > 0 {
+			text += " [" + strings.Join(envContext.KeyElements, ", ") + "]"
+		}
+		p.line(colorGreen, "scene", text)
+	case "world_state":
+		var worldState handlers.WorldStatePayload
+		json.Unmarshal(msg.Data, &worldState)
+		p.line(colorGreen, "world", worldState.Summary)
+	case "stt_status":
+		var status handlers.STTStatusPayload
+		json.Unmarshal(msg.Data, &status)
+		p.line(colorYellow, "stt", status.Status)
+	case "protocol_error":
+		var protocolErr handlers.ProtocolError
+		json.Unmarshal(msg.Data, &protocolErr)
+		p.line(colorRed, "error", fmt.Sprintf("%s: %s", protocolErr.Code, protocolErr.Message))
+	case "error":
+		var serverErr handlers.ErrorPayload
+		json.Unmarshal(msg.Data, &serverErr)
+		text := fmt.Sprintf("%s (%s): %s", serverErr.Code, serverErr.Category, serverErr.Message)
+		if serverErr.Retryable {
+			text += " [retryable]"
+		}
+		p.line(colorRed, "error", text)
+	case "rate_limited", "frame_quality_low", "rtsp_error", "context_stale", "rule_triggered", "environment_change":
+		p.line(colorYellow, msg.Type, string(msg.Data))
+	default:
+		p.line(colorDim, msg.Type, string(msg.Data))
+	}
+	return false
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/codec"
+	"github.com/Perceptus-Labs/perceptus-go-sdk/handlers"
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+	"go.uber.org/zap"
+)
+
+// BenchmarkResult is one hot-path benchmark, as go test -bench reports it.
+type BenchmarkResult struct {
+	Name        string  `json:"name"`
+	Iterations  int     `json:"iterations"`
+	NsPerOp     int64   `json:"ns_per_op"`
+	MBPerSec    float64 `json:"mb_per_sec,omitempty"`
+	BytesPerOp  int64   `json:"bytes_per_op"`
+	AllocsPerOp int64   `json:"allocs_per_op"`
+}
+
+// runBenchmarks measures what the server does for every message: decoding
+// inbound audio and frames, marshalling outbound messages and fanning them
+// out to event feed subscribers.
+func runBenchmarks() []BenchmarkResult {
+	frame := testFrame()
+	frameB64 := frame[strings.Index(frame, ",")+1:]
+	audio := base64.StdEncoding.EncodeToString(testTone(16000)[:3200]) // 100ms of 16kHz linear16
+	audioMessage, _ := json.Marshal(map[string]interface{}{"type": "audio_data", "data": audio, "timestamp": time.Now()})
+	// MessagePack clients send the chunk as raw bytes
+	audioMsgpack, _ := codec.Marshal(map[string]interface{}{"type": "audio_data", "data": testTone(16000)[:3200], "timestamp": time.Now()})
+	transcriptMessage := handlers.WebSocketMessage{
+		Type:      "transcript_final",
+		Version:   handlers.PROTOCOL_VERSION,
+		Data:      handlers.TranscriptPayload{Transcript: "bring me the red cup from the kitchen"},
+		Timestamp: time.Now(),
+	}
+	frameMessage := handlers.WebSocketMessage{
+		Type:      "video_frame",
+		Version:   handlers.PROTOCOL_VERSION,
+		Data:      handlers.VideoFramePayload{ImageB64: frame},
+		Timestamp: time.Now(),
+	}
+	transcriptFilter := utils.NewTranscriptFilter(map[string]string{
+		utils.FILTER_PROFANITY: utils.FILTER_ACTION_MASK,
+		utils.FILTER_EMAIL:     utils.FILTER_ACTION_REDACT,
+		utils.FILTER_PHONE:     utils.FILTER_ACTION_REDACT,
+	}, nil)
+
+	benchmarks := []struct {
+		name  string
+		bytes int
+		fn    func(b *testing.B)
+	}{
+		{"base64_decode_audio_chunk", len(audio), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				base64.StdEncoding.DecodeString(audio)
+			}
+		}},
+		{"base64_decode_frame", len(frameB64), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				base64.StdEncoding.DecodeString(frameB64)
+			}
+		}},
+		{"json_unmarshal_audio_message", len(audioMessage), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				var msg handlers.WebSocketMessage
+				json.Unmarshal(audioMessage, &msg)
+			}
+		}},
+		{"msgpack_unmarshal_audio_message", len(audioMsgpack), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				var msg handlers.WebSocketMessage
+				codec.Unmarshal(audioMsgpack, &msg)
+				codec.NormalizePayload(msg.Data)
+			}
+		}},
+		{"json_marshal_transcript", 0, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				json.Marshal(transcriptMessage)
+			}
+		}},
+		{"msgpack_marshal_transcript", 0, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				codec.Marshal(transcriptMessage)
+			}
+		}},
+		{"json_marshal_video_frame", len(frame), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				json.Marshal(frameMessage)
+			}
+		}},
+		{"msgpack_marshal_video_frame", len(frame), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				codec.Marshal(frameMessage)
+			}
+		}},
+		{"event_feed_fanout_1", 0, fanout(1)},
+		{"event_feed_fanout_16", 0, fanout(16)},
+		{"event_feed_fanout_128", 0, fanout(128)},
+		{"frame_signature", len(frame), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				utils.SignFrame(frame)
+			}
+		}},
+		{"transcript_filter", 0, func(b *testing.B) {
+			text := "call me at 555 123 4567 or mail jane.doe@example.com, this damn thing is shit"
+			for i := 0; i < b.N; i++ {
+				transcriptFilter.Apply(text)
+			}
+		}},
+	}
+
+	var results []BenchmarkResult
+	for _, bm := range benchmarks {
+		result := testing.Benchmark(func(b *testing.B) {
+			b.ReportAllocs()
+			if bm.bytes > 0 {
+				b.SetBytes(int64(bm.bytes))
+			}
+			bm.fn(b)
+		})
+		benchmark := BenchmarkResult{
+			Name:        bm.name,
+			Iterations:  result.N,
+			NsPerOp:     result.NsPerOp(),
+			BytesPerOp:  result.AllocedBytesPerOp(),
+			AllocsPerOp: result.AllocsPerOp(),
+		}
+		if result.Bytes > 0 && result.T > 0 {
+			benchmark.MBPerSec = math.Round(float64(result.Bytes*int64(result.N))/1e4/result.T.Seconds()) / 100
+		}
+		results = append(results, benchmark)
+		zap.L().Info("Benchmark", zap.String("name", bm.name), zap.String("result", result.String()+result.MemString()))
+	}
+	return results
+}
+
+// fanout publishes session events to subscribers that drain their feed, as
+// dashboard and caption viewers do.
+func fanout(subscribers int) func(b *testing.B) {
+	return func(b *testing.B) {
+		feed := handlers.NewEventFeed()
+		defer feed.Close()
+		for i := 0; i < subscribers; i++ {
+			events, _ := feed.Subscribe()
+			go func() {
+				for range events {
+				}
+			}()
+		}
+		msg := handlers.WebSocketMessage{
+			Type:      "transcript_final",
+			Data:      handlers.TranscriptPayload{Transcript: "bring me the red cup"},
+			Timestamp: time.Now(),
+		}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			feed.Publish(msg)
+		}
+	}
+}
@@ -0,0 +1,625 @@
+// Command loadtest soaks a server with simulated robots. Each client opens a
+// robot session and streams canned audio and frames at the configured rates,
+// sends text commands and probes latency with pings and echo probes. While it
+// runs, the server's goroutines and memory are sampled from /metrics. It
+// prints a JSON report of per-message latencies, drops and the server's
+// resource usage from start to end.
+//
+// With -bench it runs Go benchmarks of the server's hot paths instead (base64
+// decoding, JSON marshalling, event fanout), which needs no server.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/lpernett/godotenv"
+	"go.uber.org/zap"
+)
+
+type options struct {
+	server        *url.URL
+	apiKey        string
+	clients       int
+	duration      time.Duration
+	ramp          time.Duration
+	frameRate     float64
+	audioChunk    time.Duration
+	textEvery     time.Duration
+	pingEvery     time.Duration
+	metricsEvery  time.Duration
+	replyTimeout  time.Duration
+	audio         []byte
+	frame         string
+	commands      []string
+	sampleRate    int
+	bytesPerChunk int
+}
+
+func main() {
+	server := flag.String("server", "http://localhost:8080", "server base URL")
+	clients := flag.Int("clients", 10, "number of simulated robots")
+	duration := flag.Duration("duration", time.Minute, "how long each client streams")
+	ramp := flag.Duration("ramp", 10*time.Second, "time over which clients connect")
+	frameRate := flag.Float64("frame-rate", 1, "frames per second per client (0 disables video)")
+	audioChunk := flag.Duration("audio-chunk", 100*time.Millisecond, "length of each audio chunk, sent in real time (0 disables audio)")
+	audioFile := flag.String("audio", "", "raw 16-bit mono PCM to loop (defaults to a synthetic tone)")
+	sampleRate := flag.Int("sample-rate", 16000, "sample rate of the audio")
+	frameFile := flag.String("frame", "", "JPEG to stream (defaults to a synthetic test pattern)")
+	textEvery := flag.Duration("text-every", 10*time.Second, "text command interval per client (0 disables text)")
+	pingEvery := flag.Duration("ping-every", time.Second, "ping and echo probe interval per client")
+	metricsEvery := flag.Duration("metrics-every", 5*time.Second, "server /metrics sampling interval (0 disables sampling)")
+	replyTimeout := flag.Duration("reply-timeout", 10*time.Second, "how long a reply may take before the message counts as dropped")
+	bench := flag.Bool("bench", false, "run the hot-path benchmarks instead of a load test")
+	flag.Parse()
+
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		panic("Failed to initialize logger: " + err.Error())
+	}
+	zap.ReplaceGlobals(logger)
+
+	if *bench {
+		writeReport(runBenchmarks())
+		return
+	}
+
+	if err := godotenv.Load(); err != nil {
+		zap.L().Warn("Error loading .env file")
+	}
+
+	base, err := url.Parse(*server)
+	if err != nil {
+		zap.L().Fatal("Invalid server URL", zap.Error(err))
+	}
+	opts := options{
+		server:       base,
+		apiKey:       os.Getenv("LOADTEST_API_KEY"),
+		clients:      max(*clients, 1),
+		duration:     *duration,
+		ramp:         *ramp,
+		frameRate:    *frameRate,
+		audioChunk:   *audioChunk,
+		textEvery:    *textEvery,
+		pingEvery:    *pingEvery,
+		metricsEvery: *metricsEvery,
+		replyTimeout: *replyTimeout,
+		sampleRate:   *sampleRate,
+		commands:     []string{"go to the kitchen", "what do you see", "bring me the red cup", "stop moving"},
+	}
+	opts.bytesPerChunk = int(opts.audioChunk.Seconds()*float64(opts.sampleRate)) * 2
+
+	if *audioFile != "" {
+		if opts.audio, err = os.ReadFile(*audioFile); err != nil {
+			zap.L().Fatal("Failed to read audio", zap.Error(err))
+		}
+	} else {
+		opts.audio = testTone(opts.sampleRate)
+	}
+	if *frameFile != "" {
+		data, err := os.ReadFile(*frameFile)
+		if err != nil {
+			zap.L().Fatal("Failed to read frame", zap.Error(err))
+		}
+		opts.frame = "data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(data)
+	} else {
+		opts.frame = testFrame()
+	}
+
+	writeReport(runLoad(opts))
+}
+
+func writeReport(report interface{}) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		zap.L().Fatal("Failed to write report", zap.Error(err))
+	}
+}
+
+// Report is the outcome of a load test.
+type Report struct {
+	Clients         int                      `json:"clients"`
+	Connected       int                      `json:"connected"`
+	ConnectFailures int                      `json:"connect_failures"`
+	Disconnects     int                      `json:"disconnects"`
+	DurationSec     float64                  `json:"duration_sec"`
+	Sent            map[string]int           `json:"sent"`
+	Received        map[string]int           `json:"received"`
+	Latencies       map[string]LatencyReport `json:"latencies_ms"`
+	Drops           map[string]int           `json:"drops"`
+	Server          *ServerReport            `json:"server,omitempty"`
+}
+
+// LatencyReport summarizes the round trips of one kind of message.
+type LatencyReport struct {
+	Count int     `json:"count"`
+	P50   float64 `json:"p50"`
+	P95   float64 `json:"p95"`
+	P99   float64 `json:"p99"`
+	Max   float64 `json:"max"`
+}
+
+// ServerReport is the server's resource usage sampled from /metrics.
+type ServerReport struct {
+	Samples    int      `json:"samples"`
+	Goroutines Resource `json:"goroutines"`
+	HeapBytes  Resource `json:"heap_bytes"`
+	RSSBytes   Resource `json:"rss_bytes"`
+}
+
+// Resource is a gauge at the start and end of the run and its peak.
+type Resource struct {
+	Start float64 `json:"start"`
+	Peak  float64 `json:"peak"`
+	End   float64 `json:"end"`
+}
+
+func (r *Resource) observe(value float64, first bool) {
+	if first {
+		r.Start = value
+	}
+	r.Peak = max(r.Peak, value)
+	r.End = value
+}
+
+// stats collects the counters of all clients.
+type stats struct {
+	mu              sync.Mutex
+	connected       int
+	connectFailures int
+	disconnects     int
+	sent            map[string]int
+	received        map[string]int
+	latencies       map[string][]time.Duration
+	drops           map[string]int
+}
+
+func newStats() *stats {
+	return &stats{
+		sent:      map[string]int{},
+		received:  map[string]int{},
+		latencies: map[string][]time.Duration{},
+		drops:     map[string]int{},
+	}
+}
+
+func (s *stats) add(counter *int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	*counter++
+}
+
+func (s *stats) count(counters map[string]int, key string, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	counters[key] += n
+}
+
+func (s *stats) latency(kind string, d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latencies[kind] = append(s.latencies[kind], d)
+}
+
+func runLoad(opts options) Report {
+	started := time.Now()
+	st := newStats()
+	done := make(chan struct{})
+
+	var server *ServerReport
+	var sampler sync.WaitGroup
+	if opts.metricsEvery > 0 {
+		server = &ServerReport{}
+		sampler.Add(1)
+		go func() {
+			defer sampler.Done()
+			sampleServer(opts, server, done)
+		}()
+	}
+
+	zap.L().Info("Starting load test",
+		zap.Int("clients", opts.clients), zap.Duration("duration", opts.duration), zap.Duration("ramp", opts.ramp))
+	var wg sync.WaitGroup
+	for i := 0; i < opts.clients; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			time.Sleep(opts.ramp * time.Duration(i) / time.Duration(opts.clients))
+			runClient(opts, i, st)
+		}(i)
+	}
+	wg.Wait()
+	close(done)
+	sampler.Wait()
+
+	report := Report{
+		Clients:         opts.clients,
+		Connected:       st.connected,
+		ConnectFailures: st.connectFailures,
+		Disconnects:     st.disconnects,
+		DurationSec:     time.Since(started).Seconds(),
+		Sent:            st.sent,
+		Received:        st.received,
+		Latencies:       map[string]LatencyReport{},
+		Drops:           st.drops,
+		Server:          server,
+	}
+	for kind, latencies := range st.latencies {
+		report.Latencies[kind] = summarize(latencies)
+	}
+	return report
+}
+
+func summarize(latencies []time.Duration) LatencyReport {
+	slices.Sort(latencies)
+	percentile := func(p float64) float64 {
+		index := int(math.Ceil(p*float64(len(latencies)))) - 1
+		return millis(latencies[max(index, 0)])
+	}
+	return LatencyReport{
+		Count: len(latencies),
+		P50:   percentile(0.50),
+		P95:   percentile(0.95),
+		P99:   percentile(0.99),
+		Max:   millis(latencies[len(latencies)-1]),
+	}
+}
+
+func millis(d time.Duration) float64 {
+	return math.Round(float64(d.Microseconds())/10) / 100
+}
+
+type inbound struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+// pending matches replies to the messages that asked for them. Replies of
+// one kind arrive in order, so each kind is a queue of send times; echo
+// probes carry their own ID.
+type pending struct {
+	mu     sync.Mutex
+	queues map[string][]time.Time
+	probes map[string]time.Time
+}
+
+func (p *pending) push(kind string, at time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.queues[kind] = append(p.queues[kind], at)
+}
+
+func (p *pending) pop(kind string) (time.Time, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	queue := p.queues[kind]
+	if len(queue) == 0 {
+		return time.Time{}, false
+	}
+	p.queues[kind] = queue[1:]
+	return queue[0], true
+}
+
+func (p *pending) empty() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, queue := range p.queues {
+		if len(queue) > 0 {
+			return false
+		}
+	}
+	return len(p.probes) == 0
+}
+
+// expire drops and returns the number of requests older than timeout.
+func (p *pending) expire(now time.Time, timeout time.Duration) map[string]int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	expired := map[string]int{}
+	for kind, queue := range p.queues {
+		n := 0
+		for n < len(queue) && now.Sub(queue[n]) > timeout {
+			n++
+		}
+		if n > 0 {
+			expired[kind] += n
+			p.queues[kind] = queue[n:]
+		}
+	}
+	for id, at := range p.probes {
+		if now.Sub(at) > timeout {
+			expired["echo_probe"]++
+			delete(p.probes, id)
+		}
+	}
+	return expired
+}
+
+// Messages whose reply is timed, and the reply that answers them
+var replies = map[string]string{
+	"pong":              "ping",
+	"video_frame":       "video_data",
+	"transcript_final":  "text_input",
+	"echo_probe_result": "echo_probe",
+}
+
+func runClient(opts options, id int, st *stats) {
+	wsURL := *opts.server
+	wsURL.Scheme = strings.Replace(wsURL.Scheme, "http", "ws", 1)
+	wsURL.Path = "/robot/session"
+	query := url.Values{}
+	if opts.apiKey != "" {
+		query.Set("api_key", opts.apiKey)
+	}
+	query.Set("robot_id", fmt.Sprintf("loadtest-%d", id))
+	wsURL.RawQuery = query.Encode()
+
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL.String(), nil)
+	if err != nil {
+		zap.L().Warn("Failed to connect", zap.Int("client", id), zap.Error(err))
+		st.add(&st.connectFailures)
+		return
+	}
+	defer conn.Close()
+	st.add(&st.connected)
+
+	waiting := &pending{queues: map[string][]time.Time{}, probes: map[string]time.Time{}}
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			var msg inbound
+			if err := conn.ReadJSON(&msg); err != nil {
+				return
+			}
+			now := time.Now()
+			st.count(st.received, msg.Type, 1)
+			receive(msg, now, waiting, st)
+		}
+	}()
+
+	send := func(msgType string, data interface{}) bool {
+		msg := map[string]interface{}{"type": msgType, "data": data, "timestamp": time.Now()}
+		if err := conn.WriteJSON(msg); err != nil {
+			return false
+		}
+		st.count(st.sent, msgType, 1)
+		return true
+	}
+
+	never := make(<-chan time.Time)
+	ticker := func(every time.Duration) (<-chan time.Time, func()) {
+		if every <= 0 {
+			return never, func() {}
+		}
+		t := time.NewTicker(every)
+		return t.C, t.Stop
+	}
+	audioTick, stopAudio := ticker(opts.audioChunk)
+	defer stopAudio()
+	var frameEvery time.Duration
+	if opts.frameRate > 0 {
+		frameEvery = time.Duration(float64(time.Second) / opts.frameRate)
+	}
+	frameTick, stopFrames := ticker(frameEvery)
+	defer stopFrames()
+	textTick, stopText := ticker(opts.textEvery)
+	defer stopText()
+	pingTick, stopPing := ticker(opts.pingEvery)
+	defer stopPing()
+	deadline := time.After(opts.duration)
+
+	audioOffset, step := 0, 0
+	for running := true; running; {
+		sentAt := time.Now()
+		ok := true
+		select {
+		case <-closed:
+			st.add(&st.disconnects)
+			return
+		case <-audioTick:
+			ok = send("audio_data", base64.StdEncoding.EncodeToString(nextChunk(opts.audio, &audioOffset, opts.bytesPerChunk)))
+		case <-frameTick:
+			waiting.push("video_data", sentAt)
+			ok = send("video_data", opts.frame)
+		case <-textTick:
+			waiting.push("text_input", sentAt)
+			ok = send("text_input", map[string]string{"text": opts.commands[step%len(opts.commands)]})
+			step++
+		case <-pingTick:
+			waiting.push("ping", sentAt)
+			probeID := fmt.Sprintf("%d-%d", id, sentAt.UnixNano())
+			waiting.mu.Lock()
+			waiting.probes[probeID] = sentAt
+			waiting.mu.Unlock()
+			ok = send("ping", nil) && send("echo_probe", map[string]interface{}{
+				"probe_id":       probeID,
+				"client_sent_at": sentAt.UnixMilli(),
+			})
+			for kind, n := range waiting.expire(time.Now(), opts.replyTimeout) {
+				st.count(st.drops, "unanswered:"+kind, n)
+			}
+		case <-deadline:
+			running = false
+		}
+		if !ok {
+			st.add(&st.disconnects)
+			return
+		}
+	}
+
+	// Whatever is still waiting after the grace period was dropped
+	send("stop", nil)
+	grace := time.After(opts.replyTimeout)
+	poll := time.NewTicker(50 * time.Millisecond)
+	defer poll.Stop()
+	for !waiting.empty() {
+		select {
+		case <-closed:
+		case <-grace:
+		case <-poll.C:
+			continue
+		}
+		break
+	}
+	for kind, n := range waiting.expire(time.Now(), 0) {
+		st.count(st.drops, "unanswered:"+kind, n)
+	}
+}
+
+// receive times a reply or counts a drop the server reported.
+func receive(msg inbound, now time.Time, waiting *pending, st *stats) {
+	switch msg.Type {
+	case "echo_probe_result":
+		var result struct {
+			ProbeID string `json:"probe_id"`
+		}
+		json.Unmarshal(msg.Data, &result)
+		waiting.mu.Lock()
+		sentAt, ok := waiting.probes[result.ProbeID]
+		delete(waiting.probes, result.ProbeID)
+		waiting.mu.Unlock()
+		if ok {
+			st.latency("echo_probe", now.Sub(sentAt))
+		}
+	case "rate_limited":
+		var limited struct {
+			MessageType string `json:"message_type"`
+		}
+		json.Unmarshal(msg.Data, &limited)
+		st.count(st.drops, "rate_limited:"+limited.MessageType, 1)
+		// The limited message gets no other reply
+		waiting.pop(limited.MessageType)
+	case "error":
+		var payload struct {
+			Code string `json:"code"`
+		}
+		json.Unmarshal(msg.Data, &payload)
+		st.count(st.drops, "error:"+payload.Code, 1)
+	default:
+		request, timed := replies[msg.Type]
+		if !timed {
+			return
+		}
+		if msg.Type == "transcript_final" && !strings.Contains(string(msg.Data), `"source":"text"`) {
+			// Transcripts of the streamed audio answer no text command
+			return
+		}
+		if sentAt, ok := waiting.pop(request); ok {
+			st.latency(request, now.Sub(sentAt))
+		}
+	}
+}
+
+// nextChunk returns the next n bytes of audio, looping around its end.
+func nextChunk(audio []byte, offset *int, n int) []byte {
+	chunk := make([]byte, n)
+	for i := range chunk {
+		chunk[i] = audio[(*offset+i)%len(audio)]
+	}
+	*offset = (*offset + n) % len(audio)
+	return chunk
+}
+
+// sampleServer records the server's goroutines and memory from /metrics
+// until done is closed, with a last sample at the end.
+func sampleServer(opts options, report *ServerReport, done <-chan struct{}) {
+	ticker := time.NewTicker(opts.metricsEvery)
+	defer ticker.Stop()
+	for {
+		metrics, err := scrapeMetrics(opts.server)
+		if err != nil {
+			zap.L().Warn("Failed to sample server metrics", zap.Error(err))
+		} else {
+			first := report.Samples == 0
+			report.Goroutines.observe(metrics["go_goroutines"], first)
+			report.HeapBytes.observe(metrics["go_memstats_heap_alloc_bytes"], first)
+			report.RSSBytes.observe(metrics["process_resident_memory_bytes"], first)
+			report.Samples++
+		}
+		select {
+		case <-done:
+			if metrics, err := scrapeMetrics(opts.server); err == nil && report.Samples > 0 {
+				report.Goroutines.observe(metrics["go_goroutines"], false)
+				report.HeapBytes.observe(metrics["go_memstats_heap_alloc_bytes"], false)
+				report.RSSBytes.observe(metrics["process_resident_memory_bytes"], false)
+				report.Samples++
+			}
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// scrapeMetrics reads the unlabeled gauges of the Prometheus text format.
+func scrapeMetrics(server *url.URL) (map[string]float64, error) {
+	resp, err := http.Get(server.JoinPath("/metrics").String())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("/metrics returned %s", resp.Status)
+	}
+
+	metrics := map[string]float64{}
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, value, found := strings.Cut(line, " ")
+		if !found || strings.Contains(name, "{") {
+			continue
+		}
+		if parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+			metrics[name] = parsed
+		}
+	}
+	return metrics, scanner.Err()
+}
+
+// testFrame returns a small JPEG with enough texture to pass the frame
+// quality gate.
+func testFrame() string {
+	img := image.NewRGBA(image.Rect(0, 0, 320, 240))
+	for y := 0; y < 240; y++ {
+		for x := 0; x < 320; x++ {
+			shade := uint8(60 + ((x/16+y/16)%2)*120)
+			img.Set(x, y, color.RGBA{shade, shade, shade, 255})
+		}
+	}
+	var buf bytes.Buffer
+	jpeg.Encode(&buf, img, &jpeg.Options{Quality: 80})
+	return "data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+// testTone returns a second of a 440Hz tone as 16-bit little-endian PCM.
+func testTone(sampleRate int) []byte {
+	pcm := make([]byte, sampleRate*2)
+	for i := 0; i < sampleRate; i++ {
+		sample := int16(8000 * math.Sin(2*math.Pi*440*float64(i)/float64(sampleRate)))
+		binary.LittleEndian.PutUint16(pcm[i*2:], uint16(sample))
+	}
+	return pcm
+}
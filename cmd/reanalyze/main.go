@@ -0,0 +1,65 @@
+// Command reanalyze submits archived transcripts and frames to the OpenAI
+// Batch API, stores the new results in Redis and prints a diff report against
+// the original online analyses. It is meant to be run from a nightly cron.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"os"
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+	"github.com/lpernett/godotenv"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+func main() {
+	since := flag.Duration("since", 24*time.Hour, "re-analyze records archived within this window")
+	limit := flag.Int("limit", 0, "maximum number of records to submit (0 for all)")
+	tenant := flag.String("tenant", "", "only re-analyze this tenant's records (all tenants when empty)")
+	poll := flag.Duration("poll", time.Minute, "batch status polling interval")
+	timeout := flag.Duration("timeout", 25*time.Hour, "give up waiting for the batch after this long")
+	flag.Parse()
+
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		panic("Failed to initialize logger: " + err.Error())
+	}
+	zap.ReplaceGlobals(logger)
+
+	if err := godotenv.Load(); err != nil {
+		zap.L().Warn("Error loading .env file")
+	}
+	cfg, _, err := utils.LoadConfig(utils.ConfigFilePath())
+	if cfg == nil {
+		zap.L().Fatal("Failed to load config file", zap.Error(err))
+	}
+	if err != nil {
+		zap.L().Fatal("Invalid configuration", zap.Error(err))
+	}
+	utils.SetConfig(cfg)
+
+	redisClient := redis.NewClient(&redis.Options{
+		Addr:        cfg.RedisHost,
+		Password:    cfg.RedisPassword,
+		DB:          0,
+		DialTimeout: 20 * time.Second,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	report, err := utils.RunBatchReanalysis(ctx, utils.NewOpenAIClient(), redisClient, *tenant, time.Now().Add(-*since), *limit, *poll)
+	if err != nil {
+		zap.L().Fatal("Batch reanalysis failed", zap.Error(err))
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		zap.L().Fatal("Failed to write report", zap.Error(err))
+	}
+}
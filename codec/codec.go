@@ -0,0 +1,114 @@
+// Package codec encodes robot session messages as MessagePack for clients
+// that negotiate the perceptus.msgpack.v1 WebSocket subprotocol. Messages
+// keep the shape of their JSON encoding: struct fields are named by their
+// json tags, json.RawMessage values are embedded as the values they hold,
+// and Normalize turns decoded values into the types encoding/json produces,
+// so handlers need not care which encoding a client chose.
+package codec
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// SUBPROTOCOL_MSGPACK is the WebSocket subprotocol of MessagePack encoded
+// sessions. Clients that don't ask for it, such as browsers, get JSON.
+const SUBPROTOCOL_MSGPACK = "perceptus.msgpack.v1"
+
+func init() {
+	// json.RawMessage holds JSON text; encode the value it holds rather
+	// than the text as binary, and decode any value back to JSON
+	msgpack.Register(json.RawMessage(nil),
+		func(e *msgpack.Encoder, v reflect.Value) error {
+			raw := v.Interface().(json.RawMessage)
+			if len(raw) == 0 {
+				return e.EncodeNil()
+			}
+			var value interface{}
+			if err := json.Unmarshal(raw, &value); err != nil {
+				return fmt.Errorf("invalid json.RawMessage: %w", err)
+			}
+			return e.Encode(value)
+		},
+		func(d *msgpack.Decoder, v reflect.Value) error {
+			value, err := d.DecodeInterface()
+			if err != nil {
+				return err
+			}
+			raw, err := json.Marshal(Normalize(value))
+			if err != nil {
+				return err
+			}
+			v.Set(reflect.ValueOf(json.RawMessage(raw)))
+			return nil
+		})
+}
+
+// Marshal encodes v as MessagePack, naming struct fields by their json tags.
+func Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := msgpack.NewEncoder(&buf)
+	encoder.SetCustomStructTag("json")
+	encoder.UseCompactInts(true)
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal decodes MessagePack into v, matching struct fields by their json
+// tags. Values decoded into interface{} fields should be passed through
+// Normalize.
+func Unmarshal(raw []byte, v interface{}) error {
+	decoder := msgpack.NewDecoder(bytes.NewReader(raw))
+	decoder.SetCustomStructTag("json")
+	return decoder.Decode(v)
+}
+
+// Normalize converts a decoded MessagePack value to what encoding/json would
+// have decoded from the equivalent JSON: numbers become float64, binary
+// becomes a base64 string, timestamps RFC 3339 strings and map keys
+// strings.
+func Normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case int8, int16, int32, int64, uint8, uint16, uint32, uint64, float32:
+		return reflect.ValueOf(v).Convert(reflect.TypeOf(float64(0))).Interface()
+	case []byte:
+		return base64.StdEncoding.EncodeToString(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case []interface{}:
+		for i, item := range v {
+			v[i] = Normalize(item)
+		}
+		return v
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = Normalize(item)
+		}
+		return v
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			m[fmt.Sprint(key)] = Normalize(item)
+		}
+		return m
+	}
+	return value
+}
+
+// NormalizePayload normalizes a message's data like Normalize, except that a
+// binary payload, such as an audio chunk or a frame a robot sends as raw
+// bytes, stays []byte instead of taking a round trip through base64.
+func NormalizePayload(value interface{}) interface{} {
+	if _, binary := value.([]byte); binary {
+		return value
+	}
+	return Normalize(value)
+}
@@ -0,0 +1,170 @@
+// handlers/acoustic_events.go
+
+package handlers
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+	"go.uber.org/zap"
+)
+
+// AcousticEventDetector is an audio stage that classifies windows of the
+// robot's audio for non-speech sounds (doorbells, alarms, breaking glass,
+// crying, dog barks) and reports them as acoustic_event messages. Windows
+// are classified off the audio path, one at a time; windows arriving while
+// the classifier is busy are skipped.
+type AcousticEventDetector struct {
+	session    *RoboSession
+	classifier utils.SoundClassifier
+	events     []string
+	notify     []string
+	minScore   float64
+	window     time.Duration
+	cooldown   time.Duration
+
+	mu         sync.Mutex
+	buffer     []byte
+	sampleRate int
+	lastSeen   map[string]time.Time
+	busy       atomic.Bool
+}
+
+// InitAcousticEventDetector returns the session's detector, or nil when
+// ACOUSTIC_EVENTS_ENABLED is off or the classifier is not configured.
+func InitAcousticEventDetector(session *RoboSession) *AcousticEventDetector {
+	if !session.Config.AcousticEventsEnabled {
+		return nil
+	}
+	classifier, err := utils.NewSoundClassifierFromConfig(session.Config)
+	if err != nil {
+		session.Logger.Warn("Acoustic event detection disabled", zap.Error(err))
+		return nil
+	}
+	events, err := utils.ParseSoundEvents(session.Config.AcousticEvents)
+	if err != nil {
+		session.Logger.Warn("Acoustic event detection disabled", zap.Error(err))
+		return nil
+	}
+	notify, err := utils.ParseSoundEvents(session.Config.AcousticEventNotify)
+	if err != nil {
+		session.Logger.Warn("Acoustic event detection disabled", zap.Error(err))
+		return nil
+	}
+
+	session.Logger.Info("Acoustic event detection enabled", zap.Strings("events", events), zap.Strings("notify", notify))
+	return &AcousticEventDetector{
+		session:    session,
+		classifier: classifier,
+		events:     events,
+		notify:     notify,
+		minScore:   session.Config.AcousticEventMinScore,
+		window:     session.Config.AcousticEventWindow,
+		cooldown:   session.Config.AcousticEventCooldown,
+		lastSeen:   make(map[string]time.Time),
+	}
+}
+
+func (d *AcousticEventDetector) Name() string  { return "acoustic_events" }
+func (d *AcousticEventDetector) Phase() string { return AUDIO_PHASE_PREPROCESS }
+
+// Process collects audio into windows and hands each full window to the
+// classifier. The chunk passes through unchanged.
+func (d *AcousticEventDetector) Process(ctx context.Context, chunk *AudioChunk) error {
+	if chunk.Format.Encoding != utils.AudioEncodingLinear16 || chunk.Format.SampleRate == 0 {
+		return nil
+	}
+	windowBytes := int(d.window.Seconds()*float64(chunk.Format.SampleRate)) * 2
+
+	d.mu.Lock()
+	if chunk.Format.SampleRate != d.sampleRate {
+		d.buffer, d.sampleRate = nil, chunk.Format.SampleRate
+	}
+	d.buffer = append(d.buffer, chunk.Audio...)
+	if len(d.buffer) < windowBytes {
+		d.mu.Unlock()
+		return nil
+	}
+	window := d.buffer[:windowBytes]
+	d.buffer = append([]byte(nil), d.buffer[windowBytes:]...)
+	sampleRate := d.sampleRate
+	d.mu.Unlock()
+
+	if !d.busy.CompareAndSwap(false, true) {
+		d.session.Logger.Debug("Sound classifier busy, skipping window")
+		return nil
+	}
+	d.session.Supervisor.Task("acoustic_events", func() {
+		defer d.busy.Store(false)
+		d.classify(window, sampleRate)
+	})
+	return nil
+}
+
+func (d *AcousticEventDetector) classify(window []byte, sampleRate int) {
+	rs := d.session
+	ctx, cancel := context.WithTimeout(rs.sessionCtx, 10*time.Second)
+	defer cancel()
+
+	scores, err := d.classifier.Classify(ctx, window, sampleRate)
+	if cancelled(ctx) {
+		return
+	}
+	if err != nil {
+		rs.Logger.Warn("Failed to classify sound", zap.Error(err))
+		rs.MetricLabels.ProviderError("sound_classifier")
+		return
+	}
+	rs.recordUsage(models.USAGE_SOUND_WINDOWS, 1)
+
+	now := rs.Clock.Now()
+	for _, event := range utils.SoundEventsFromScores(scores, d.events, d.minScore) {
+		// A barking dog is one event, not one per window
+		d.mu.Lock()
+		last, seen := d.lastSeen[event.Event]
+		if seen && now.Sub(last) < d.cooldown {
+			d.mu.Unlock()
+			continue
+		}
+		d.lastSeen[event.Event] = now
+		d.mu.Unlock()
+		d.report(event, now)
+	}
+}
+
+func (d *AcousticEventDetector) report(event utils.SoundEvent, at time.Time) {
+	rs := d.session
+	notify := slices.Contains(d.notify, event.Event)
+	payload := AcousticEventPayload{
+		EventID:   rs.IDs.NewID(),
+		Event:     event.Event,
+		Label:     event.Label,
+		Score:     event.Score,
+		Notified:  notify,
+		Timestamp: at.Unix(),
+	}
+	rs.Logger.Info("Acoustic event detected",
+		zap.String("event", event.Event), zap.String("label", event.Label), zap.Float64("score", event.Score))
+	rs.sendWebSocketMessage("acoustic_event", payload)
+
+	if notify {
+		rs.journalAction("heard %s, sent to the orchestrator", event.Event)
+		rs.notifyOrchestrator(payload.EventID, OrchestratorAcousticEventPayload{
+			TriggerID:   payload.EventID,
+			SessionID:   rs.ID,
+			TenantID:    rs.Tenant.ID,
+			TriggerType: "acoustic_event",
+			Event:       event.Event,
+			Label:       event.Label,
+			Score:       event.Score,
+			Timestamp:   at.Unix(),
+			Worker:      utils.Worker(),
+			Metadata:    rs.metadataCopy(),
+		})
+	}
+}
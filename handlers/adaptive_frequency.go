@@ -0,0 +1,351 @@
+// handlers/adaptive_frequency.go
+
+package handlers
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+	"go.uber.org/zap"
+)
+
+// Reasons of a capture_frequency_update message.
+const (
+	FREQUENCY_REASON_MOTION    = "motion"
+	FREQUENCY_REASON_ACTIVITY  = "activity"
+	FREQUENCY_REASON_CALM      = "calm"
+	FREQUENCY_REASON_BUDGET    = "budget"
+	FREQUENCY_REASON_RESET     = "reset"
+	FREQUENCY_REASON_LOW_POWER = "low_power"
+	FREQUENCY_REASON_RESUMED   = "resumed"
+)
+
+// Calm analyses in a row before the frequency is lowered
+const calmAnalysesToSlowDown = 2
+
+// AdaptiveFrequency adjusts how often frames are analyzed to what happens in
+// the scene: motion between frames or activities in an analysis halve the
+// interval down to VIDEO_FREQUENCY_MIN, calm scenes stretch it by half up to
+// VIDEO_FREQUENCY_MAX. With VIDEO_FRAME_BUDGET_PER_HOUR set, a session that
+// used half its hourly budget is held to the pace the budget sustains, and
+// one that used all of it to VIDEO_FREQUENCY_MAX. Frames arriving faster
+// than the interval are skipped; changes are sent as
+// capture_frequency_update so the robot can lower its camera duty cycle too.
+// In low-power mode frames are held to at most one per low-power interval,
+// whether adaptation is on or not.
+type AdaptiveFrequency struct {
+	session *RoboSession
+
+	mu      sync.Mutex
+	enabled bool
+	// base is the client's video_frequency the interval adapted from
+	base     time.Duration
+	interval time.Duration
+	previous utils.FrameSignature
+	calm     int
+	// analyzed holds the admission times of the last hour
+	analyzed     []time.Time
+	lastAdmitted time.Time
+	// A frame was requested on demand and is admitted regardless
+	onDemand bool
+	// lowPower is the slowest interval while in low-power mode, 0 outside;
+	// resumed is closed when the mode ends
+	lowPower time.Duration
+	resumed  chan struct{}
+
+	min             time.Duration
+	max             time.Duration
+	motionThreshold float64
+	budget          int
+}
+
+func NewAdaptiveFrequency(session *RoboSession) *AdaptiveFrequency {
+	return &AdaptiveFrequency{
+		session:         session,
+		enabled:         session.Config.AdaptiveVideoFrequency,
+		min:             session.Config.VideoFrequencyMin,
+		max:             session.Config.VideoFrequencyMax,
+		motionThreshold: session.Config.VideoMotionThreshold,
+		budget:          session.Config.VideoFrameBudgetPerHour,
+		resumed:         make(chan struct{}),
+	}
+}
+
+func (a *AdaptiveFrequency) Enabled() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.enabled
+}
+
+// Interval returns the effective video frequency: the adapted one, or the
+// client's while adaptation is off, slowed to the low-power interval.
+func (a *AdaptiveFrequency) Interval() time.Duration {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.effective()
+}
+
+// effective is called with a.mu held.
+func (a *AdaptiveFrequency) effective() time.Duration {
+	a.rebase()
+	interval := a.interval
+	if !a.enabled {
+		interval = a.base
+	}
+	return max(interval, a.lowPower)
+}
+
+// SetLowPower enters low-power mode, holding the frequency to at most one
+// frame per interval, or leaves it with 0.
+func (a *AdaptiveFrequency) SetLowPower(interval time.Duration) {
+	a.mu.Lock()
+	if a.lowPower == interval {
+		a.mu.Unlock()
+		return
+	}
+	a.lowPower = interval
+	reason := FREQUENCY_REASON_LOW_POWER
+	if interval == 0 {
+		reason = FREQUENCY_REASON_RESUMED
+		close(a.resumed)
+		a.resumed = make(chan struct{})
+	}
+	effective := a.effective()
+	a.mu.Unlock()
+
+	a.session.Logger.Info("Video frequency changed for power mode", zap.Duration("frequency", effective), zap.String("reason", reason))
+	a.notify(effective, reason, 0)
+}
+
+// Resumed returns a channel closed when low-power mode ends, so capture
+// loops waiting out a low-power interval pick the normal pace up at once.
+func (a *AdaptiveFrequency) Resumed() <-chan struct{} {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.resumed
+}
+
+// rebase restarts from the client's frequency when it changed. Called with
+// a.mu held.
+func (a *AdaptiveFrequency) rebase() {
+	base := a.session.VideoFrequency()
+	if base <= 0 {
+		base = 30 * time.Second
+	}
+	if base != a.base {
+		a.base = base
+		a.interval = a.clamp(base)
+		a.calm = 0
+	}
+}
+
+// clamp is called with a.mu held.
+func (a *AdaptiveFrequency) clamp(interval time.Duration) time.Duration {
+	return min(max(interval, a.min), a.max)
+}
+
+// SetEnabled switches adaptation, returning to the client's frequency when
+// it is turned off.
+func (a *AdaptiveFrequency) SetEnabled(enabled bool) {
+	a.mu.Lock()
+	if a.enabled == enabled {
+		a.mu.Unlock()
+		return
+	}
+	a.enabled = enabled
+	a.rebase()
+	a.interval, a.calm = a.clamp(a.base), 0
+	effective := a.effective()
+	a.mu.Unlock()
+
+	a.session.Logger.Info("Adaptive video frequency switched", zap.Bool("enabled", enabled))
+	if !enabled {
+		a.notify(effective, FREQUENCY_REASON_RESET, 0)
+	}
+}
+
+// ExpectFrame admits the next frame whatever the interval, for on-demand
+// captures.
+func (a *AdaptiveFrequency) ExpectFrame() {
+	a.mu.Lock()
+	a.onDemand = true
+	a.mu.Unlock()
+}
+
+// Admit scores a frame's motion against the previous one and reports whether
+// it is due for analysis.
+func (a *AdaptiveFrequency) Admit(imageData string) bool {
+	if !a.Enabled() {
+		return a.admitLowPower()
+	}
+	signature, err := utils.SignFrame(imageData)
+	if err != nil {
+		// Left to the frame quality check to report
+		a.session.Logger.Debug("Unable to score frame motion", zap.Error(err))
+	}
+
+	a.mu.Lock()
+	a.rebase()
+	motion := utils.MotionScore(a.previous, signature)
+	if signature != nil {
+		a.previous = signature
+	}
+	moving := motion >= a.motionThreshold
+	if moving {
+		a.calm = 0
+	}
+
+	now := a.session.Clock.Now()
+	a.pruneAnalyzed(now)
+	exhausted := a.budget > 0 && len(a.analyzed) >= a.budget
+	elapsed := now.Sub(a.lastAdmitted)
+	// Frames are a little early when the robot's clock drifts from ours.
+	// Motion doesn't wake a session in low-power mode; speech does
+	due := elapsed >= max(a.interval, a.lowPower)*9/10 || (moving && a.lowPower == 0 && elapsed >= a.min)
+	admit := a.onDemand || (due && !exhausted)
+	if admit {
+		a.onDemand = false
+		a.lastAdmitted = now
+		a.analyzed = append(a.analyzed, now)
+	}
+	a.mu.Unlock()
+
+	if moving {
+		a.adapt(FREQUENCY_REASON_MOTION, motion, 0.5)
+	}
+	if !admit {
+		a.session.Logger.Debug("Skipped frame between adaptive captures",
+			zap.Float64("motion", motion), zap.Bool("budget_exhausted", exhausted))
+	}
+	return admit
+}
+
+// admitLowPower paces frames while adaptation is off: all are due, except
+// in low-power mode.
+func (a *AdaptiveFrequency) admitLowPower() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	now := a.session.Clock.Now()
+	admit := a.onDemand || a.lowPower == 0 || now.Sub(a.lastAdmitted) >= a.lowPower*9/10
+	if admit {
+		a.onDemand = false
+		a.lastAdmitted = now
+	} else {
+		a.session.Logger.Debug("Skipped frame in low-power mode")
+	}
+	return admit
+}
+
+// Observe adapts to an analysis: activities speed captures up, calm scenes
+// slow them down.
+func (a *AdaptiveFrequency) Observe(envContext models.EnvironmentContext) {
+	if !a.Enabled() {
+		return
+	}
+	if len(envContext.Activities) > 0 {
+		a.mu.Lock()
+		a.calm = 0
+		a.mu.Unlock()
+		a.adapt(FREQUENCY_REASON_ACTIVITY, 0, 0.5)
+		return
+	}
+
+	a.mu.Lock()
+	a.calm++
+	calm := a.calm >= calmAnalysesToSlowDown
+	if calm {
+		a.calm = 0
+	}
+	a.mu.Unlock()
+	if calm {
+		a.adapt(FREQUENCY_REASON_CALM, 0, 1.5)
+	} else {
+		// Budget pressure still applies while the scene settles
+		a.adapt(FREQUENCY_REASON_BUDGET, 0, 1)
+	}
+}
+
+// adapt scales the interval by factor within the bounds and the budget,
+// notifying the client when it changed.
+func (a *AdaptiveFrequency) adapt(reason string, motion, factor float64) {
+	a.mu.Lock()
+	a.rebase()
+	target := a.clamp(time.Duration(float64(a.interval) * factor))
+	if floor := a.budgetFloor(a.session.Clock.Now()); floor > target {
+		target, reason = floor, FREQUENCY_REASON_BUDGET
+	}
+	// Ignore changes below a second; they only add chatter
+	changed := (target - a.interval).Abs() >= time.Second
+	if changed {
+		a.interval = target
+	}
+	// Low-power mode holds the pace; the adapted one is announced on resume
+	lowPower := a.lowPower > 0
+	a.mu.Unlock()
+
+	if changed && !lowPower {
+		a.session.Logger.Info("Adapted video frequency",
+			zap.Duration("frequency", target), zap.String("reason", reason), zap.Float64("motion", motion))
+		a.notify(target, reason, motion)
+	}
+}
+
+// budgetFloor returns the shortest interval the frame budget allows. Called
+// with a.mu held.
+func (a *AdaptiveFrequency) budgetFloor(now time.Time) time.Duration {
+	if a.budget <= 0 {
+		return 0
+	}
+	a.pruneAnalyzed(now)
+	used := len(a.analyzed)
+	switch {
+	case used >= a.budget:
+		return a.max
+	case used*2 >= a.budget:
+		return a.clamp(time.Hour / time.Duration(a.budget))
+	}
+	return 0
+}
+
+// pruneAnalyzed is called with a.mu held.
+func (a *AdaptiveFrequency) pruneAnalyzed(now time.Time) {
+	cutoff := now.Add(-time.Hour)
+	drop := 0
+	for drop < len(a.analyzed) && a.analyzed[drop].Before(cutoff) {
+		drop++
+	}
+	a.analyzed = a.analyzed[drop:]
+}
+
+func (a *AdaptiveFrequency) notify(interval time.Duration, reason string, motion float64) {
+	a.mu.Lock()
+	payload := CaptureFrequencyUpdatePayload{
+		Frequency:     interval.String(),
+		BaseFrequency: a.base.String(),
+		Reason:        reason,
+		MotionScore:   motion,
+	}
+	if a.budget > 0 {
+		payload.BudgetUsed = float64(len(a.analyzed)) / float64(a.budget)
+	}
+	a.mu.Unlock()
+	a.session.sendWebSocketMessage("capture_frequency_update", payload)
+}
+
+// applyAdaptiveFrequencyConfig switches adaptation from a config payload
+// ({"adaptive_video_frequency":true}).
+func (rs *RoboSession) applyAdaptiveFrequencyConfig(configData map[string]interface{}) (string, error) {
+	value, exists := configData["adaptive_video_frequency"]
+	if !exists {
+		return "", nil
+	}
+	enabled, ok := value.(bool)
+	if !ok {
+		return "data.adaptive_video_frequency", fmt.Errorf("must be a boolean")
+	}
+	rs.AdaptiveFrequency.SetEnabled(enabled)
+	return "", nil
+}
@@ -0,0 +1,206 @@
+// handlers/admin_handler.go
+
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
+	"github.com/Perceptus-Labs/perceptus-go-sdk/testmode"
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// RequireAdmin is the middleware of the admin API.
+func RequireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requireAdmin(w, r) {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// RequireTenant is the middleware of the tenant API: it authenticates the
+// request's API key and passes the tenant on in the request context.
+func RequireTenant(tenants *utils.TenantStore) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tenant, err := tenants.Resolve(r)
+			if err != nil {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(utils.WithTenant(r.Context(), tenant)))
+		})
+	}
+}
+
+// requireAdmin checks the ADMIN_API_KEY bearer token, writing the HTTP error
+// itself. Admin endpoints are disabled when ADMIN_API_KEY is unset.
+func requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	adminKey := utils.CurrentConfig().AdminAPIKey
+	if adminKey == "" {
+		http.Error(w, "admin API disabled", http.StatusNotFound)
+		return false
+	}
+
+	provided := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(provided), []byte(adminKey)) != 1 {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// HandleReloadCredentials re-reads provider credentials without a restart:
+// POST /admin/reload
+func HandleReloadCredentials(w http.ResponseWriter, r *http.Request, tenants *utils.TenantStore) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	if err := tenants.Reload(); err != nil {
+		zap.L().Error("Credential reload failed", zap.Error(err))
+		http.Error(w, "reload failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "reloaded"})
+}
+
+// HandleRotateEncryptionKeys rewraps every stored artifact whose tenant has
+// moved to a new encryption key, after which the old key can be retired:
+// POST /admin/encryption/rotate
+func HandleRotateEncryptionKeys(w http.ResponseWriter, r *http.Request, redisClient *redis.Client) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	rotated, err := rotateArtifactKeys(r.Context(), redisClient)
+	if err != nil {
+		zap.L().Error("Encryption key rotation failed", zap.Int("rotated", rotated), zap.Error(err))
+		http.Error(w, "rotation failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	zap.L().Info("Rewrapped artifacts under current encryption keys", zap.Int("artifacts", rotated))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"rotated": rotated})
+}
+
+// rotateArtifactKeys rewraps the artifacts in Redis, then the stored frames
+// and the recording files.
+func rotateArtifactKeys(ctx context.Context, redisClient *redis.Client) (int, error) {
+	rotated, err := utils.RotateArtifactKeys(ctx, redisClient)
+	if err != nil {
+		return rotated, err
+	}
+	if config := frameStore(); config != nil {
+		frames, err := utils.RotateFrameKeys(ctx, config.Store)
+		rotated += frames
+		if err != nil {
+			return rotated, err
+		}
+	}
+	recordings, err := utils.RotateRecordingKeys(ctx, redisClient, recordingDir())
+	return rotated + recordings, err
+}
+
+// HandleTestMode reads or replaces the injected provider faults of a server
+// started with TESTMODE=true: GET /admin/testmode, or PUT /admin/testmode
+// with {"faults":"llm:latency=2s,error=0.2;stt:error=0.1"} ("" clears them).
+func HandleTestMode(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	if !testmode.Enabled() {
+		http.Error(w, "test mode disabled", http.StatusNotFound)
+		return
+	}
+
+	if r.Method == http.MethodPut {
+		var req struct {
+			Faults string `json:"faults"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid body", http.StatusBadRequest)
+			return
+		}
+		faults, err := testmode.ParseFaults(req.Faults)
+		if err == nil {
+			err = testmode.SetFaults(faults)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"faults": testmode.FormatFaults(testmode.Faults())})
+}
+
+// HandleAdminOrchestratorRoutes manages a tenant's intention routing table
+// stored in Redis, which takes precedence over orchestrator_routes in the
+// tenant configuration: GET, PUT (a JSON array of routes) or DELETE (back to
+// the configured routes) /admin/tenants/{id}/orchestrator-routes.
+func HandleAdminOrchestratorRoutes(w http.ResponseWriter, r *http.Request, redisClient *redis.Client, tenants *utils.TenantStore) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	tenantID := r.PathValue("id")
+	tenant := tenants.Current(tenantID)
+	if tenant == nil {
+		http.Error(w, "tenant not found", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		var routes []models.OrchestratorRoute
+		if err := json.NewDecoder(r.Body).Decode(&routes); err != nil {
+			http.Error(w, "invalid body", http.StatusBadRequest)
+			return
+		}
+		if err := utils.ValidateOrchestratorRoutes(routes); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := utils.SaveOrchestratorRoutes(r.Context(), redisClient, tenantID, routes); err != nil {
+			zap.L().Error("Failed to save orchestrator routes", zap.String("tenant_id", tenantID), zap.Error(err))
+			http.Error(w, "failed to save routes", http.StatusInternalServerError)
+			return
+		}
+		zap.L().Info("Orchestrator routes updated", zap.String("tenant_id", tenantID), zap.Int("routes", len(routes)))
+	case http.MethodDelete:
+		if err := utils.DeleteOrchestratorRoutes(r.Context(), redisClient, tenantID); err != nil {
+			zap.L().Error("Failed to delete orchestrator routes", zap.String("tenant_id", tenantID), zap.Error(err))
+			http.Error(w, "failed to delete routes", http.StatusInternalServerError)
+			return
+		}
+		zap.L().Info("Orchestrator routes reset to configuration", zap.String("tenant_id", tenantID))
+	}
+
+	routes, stored, err := utils.LoadStoredOrchestratorRoutes(r.Context(), redisClient, tenantID)
+	if err != nil {
+		zap.L().Error("Failed to load orchestrator routes", zap.String("tenant_id", tenantID), zap.Error(err))
+		http.Error(w, "failed to load routes", http.StatusInternalServerError)
+		return
+	}
+	source := "redis"
+	if !stored {
+		routes, source = tenant.OrchestratorRoutes, "config"
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"tenant_id": tenantID,
+		"source":    source,
+		"routes":    routes,
+	})
+}
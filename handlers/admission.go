@@ -0,0 +1,73 @@
+// handlers/admission.go
+
+package handlers
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+	"go.uber.org/zap"
+)
+
+var (
+	admissionOnce       sync.Once
+	admissionController *utils.AdmissionController
+)
+
+// sessionAdmission returns the instance-wide admission controller, created on
+// first use so limits are read after the configuration is installed.
+func sessionAdmission() *utils.AdmissionController {
+	admissionOnce.Do(func() {
+		admissionController = utils.NewAdmissionController(utils.AdmissionLimitsFromConfig(utils.CurrentConfig()))
+	})
+	return admissionController
+}
+
+// admitSession reserves a session slot before the WebSocket upgrade. Clients
+// may wait for a slot with ?wait=30s (capped by ADMISSION_MAX_WAIT, default
+// ADMISSION_WAIT); otherwise a full server answers 503 with Retry-After.
+func admitSession(w http.ResponseWriter, r *http.Request, cfg *utils.Config, tenant *models.Tenant) (func(), bool) {
+	wait := cfg.AdmissionWait
+	if value := r.URL.Query().Get("wait"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			http.Error(w, "invalid wait duration", http.StatusBadRequest)
+			return nil, false
+		}
+		wait = min(parsed, cfg.AdmissionMaxWait)
+	}
+
+	ip := clientIP(r)
+	release, err := sessionAdmission().Acquire(r.Context(), tenant.ID, tenant.RateLimits.MaxSessions, ip, wait)
+	if err != nil {
+		zap.L().Warn("Rejected robot session, concurrency limit reached",
+			zap.String("tenant_id", tenant.ID), zap.String("client_ip", ip), zap.Error(err))
+		retryAfter := cfg.AdmissionRetryAfter
+		w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())))
+		http.Error(w, "too many concurrent sessions", http.StatusServiceUnavailable)
+		return nil, false
+	}
+	return release, true
+}
+
+// clientIP returns the caller's address, taken from X-Forwarded-For only when
+// TRUST_PROXY_HEADERS is set.
+func clientIP(r *http.Request) string {
+	if utils.CurrentConfig().TrustProxyHeaders {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			first, _, _ := strings.Cut(forwarded, ",")
+			return strings.TrimSpace(first)
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -3,109 +3,770 @@
 package handlers
 
 import (
+	"fmt"
 	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
 
 	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
 	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
 	"go.uber.org/zap"
 )
 
+const (
+	STT_STATUS_CONNECTED    = "connected"
+	STT_STATUS_RECONNECTING = "reconnecting"
+	STT_STATUS_FAILED       = "failed"
+)
+
 type AudioHandler struct {
-	session        *RoboSession
-	deepgramClient *utils.DeepgramClient
-	isActive       bool
+	session *RoboSession
+	mu      sync.Mutex
+	stt     utils.SpeechToText
+	// input is the format the robot sends, format the one streamed
+	input        utils.AudioFormat
+	format       utils.AudioFormat
+	preprocessor *utils.AudioPreprocessor
+	isActive     bool
+
+	// vad holds back audio without speech; finalize is set when it closed
+	// the gate, so the stream is flushed after the trailing audio is sent
+	vad      *utils.VoiceActivityDetector
+	finalize bool
+	// lowPower gates audio with a detector of its own, lowPowerVAD, when
+	// VAD_ENABLED is off
+	lowPower    bool
+	lowPowerVAD bool
+
+	// decoder turns Opus or AAC from the robot into PCM for speech-to-text
+	decoder utils.AudioDecoder
+
+	// stages are the registered audio stages
+	stages *AudioPipeline
+
+	// Reconnection state; audio received while the stream is down is kept in
+	// buffered, oldest chunks dropped past bufferLimit bytes
+	reconnecting bool
+	sttFailed    bool
+	// switching is set from an audio format change until the new stream is
+	// installed; audio is buffered meanwhile
+	switching         bool
+	buffered          [][]byte
+	bufferedBytes     int
+	droppedBytes      int
+	bufferLimit       int
+	maxAttempts       int
+	maxBackoff        time.Duration
+	keepAliveInterval time.Duration
 }
 
 func InitAudioHandler(session *RoboSession) (*AudioHandler, error) {
 	session.Logger.Info("Initializing Audio Handler...")
 
-	// Initialize Deepgram client with default settings
-	deepgramClient := utils.InitDeepgramClient(
-		"en",  // Default language
-		"0.3", // Default confidence threshold
-		session.TranscriptionCh,
-	)
-
-	// Connect to Deepgram
-	deepgramClient.Connect()
-
 	audioHandler := &AudioHandler{
-		session:        session,
-		deepgramClient: deepgramClient,
-		isActive:       true,
+		session:           session,
+		isActive:          true,
+		bufferLimit:       session.Config.STTReconnectBufferBytes,
+		maxAttempts:       session.Config.STTReconnectMaxAttempts,
+		maxBackoff:        session.Config.STTReconnectMaxBackoff,
+		keepAliveInterval: session.Config.STTKeepaliveInterval,
+	}
+	var builtin []AudioStage
+	if detector := InitAcousticEventDetector(session); detector != nil {
+		builtin = append(builtin, detector)
 	}
+	audioHandler.stages = newAudioPipeline(session, builtin...)
+	chain, err := audioHandler.newChain(utils.AudioFormatFromConfig(session.Config))
+	if err != nil {
+		return nil, err
+	}
+	audioHandler.useChain(chain)
+	stt, err := audioHandler.connectSTT()
+	if err != nil {
+		return nil, err
+	}
+	audioHandler.stt = stt
+	session.Supervisor.Go("stt_monitor", func() { audioHandler.monitor(stt) })
 
-	session.Logger.Info("Audio Handler initialized and connected to Deepgram")
+	session.Logger.Info("Audio Handler initialized and connected to speech-to-text", zap.String("provider", stt.Provider()))
 
 	// Start the handler goroutine to listen for SESSION_END
-	go audioHandler.handleTranscript()
+	session.Supervisor.Go("transcripts", audioHandler.handleTranscript)
 
 	return audioHandler, nil
 }
 
+// audioChain turns audio in the robot's format into what is streamed:
+// compressed audio is decoded to PCM, which can be preprocessed and gated
+// by voice activity detection.
+type audioChain struct {
+	input        utils.AudioFormat
+	format       utils.AudioFormat
+	decoder      utils.AudioDecoder
+	preprocessor *utils.AudioPreprocessor
+	vad          *utils.VoiceActivityDetector
+}
+
+func (h *AudioHandler) newChain(input utils.AudioFormat) (audioChain, error) {
+	chain := audioChain{input: input, format: input}
+	compressed := utils.IsCompressedEncoding(input.Encoding)
+	if compressed {
+		chain.format = utils.AudioFormat{Encoding: utils.AudioEncodingLinear16, SampleRate: input.SampleRate}
+	}
+	if h.session.Config.AudioPreprocessing {
+		// Denoising needs raw samples; containerized audio is passed through
+		if chain.format.Encoding == utils.AudioEncodingLinear16 {
+			preprocessor, err := utils.NewAudioPreprocessor(h.session.Config, chain.format.SampleRate)
+			if err != nil {
+				return audioChain{}, err
+			}
+			chain.preprocessor = preprocessor
+		} else {
+			h.session.Logger.Warn("AUDIO_PREPROCESSING requires AUDIO_ENCODING=linear16, sending audio unprocessed")
+		}
+	}
+	if settings := utils.VADSettingsFromConfig(h.session.Config); settings.Enabled {
+		if chain.format.Encoding == utils.AudioEncodingLinear16 {
+			vad, err := utils.NewVoiceActivityDetector(chain.format.SampleRate, settings, h.speechActivity)
+			if err != nil {
+				return audioChain{}, err
+			}
+			chain.vad = vad
+		} else {
+			h.session.Logger.Warn("VAD_ENABLED requires AUDIO_ENCODING=linear16, streaming all audio")
+		}
+	}
+	// Started last, so a failed stage leaves no ffmpeg process behind
+	if compressed {
+		decoder, err := utils.NewFFmpegAudioDecoder(input.Encoding, chain.format.SampleRate, h.forwardDecoded)
+		if err != nil {
+			return audioChain{}, err
+		}
+		chain.decoder = decoder
+	}
+	return chain, nil
+}
+
+// useChain is called before audio flows or with h.mu held.
+func (h *AudioHandler) useChain(chain audioChain) {
+	h.input = chain.input
+	h.format = chain.format
+	h.decoder = chain.decoder
+	h.preprocessor = chain.preprocessor
+	h.vad = chain.vad
+	h.finalize = false
+	h.lowPowerVAD = false
+	h.gateForLowPower()
+}
+
+// SetLowPower gates linear16 audio with voice activity detection while the
+// session is in low-power mode, so silence is not streamed to speech-to-text
+// even with VAD_ENABLED off. It reports whether audio is gated.
+func (h *AudioHandler) SetLowPower(on bool) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lowPower = on
+	if !on && h.lowPowerVAD {
+		h.vad, h.lowPowerVAD, h.finalize = nil, false, false
+	}
+	h.gateForLowPower()
+	if on && h.vad == nil && h.format.Encoding != utils.AudioEncodingLinear16 {
+		h.session.Logger.Info("Low-power mode cannot gate audio without linear16, streaming all audio", zap.String("encoding", h.format.Encoding))
+	}
+	return h.vad != nil
+}
+
+// gateForLowPower is called with h.mu held.
+func (h *AudioHandler) gateForLowPower() {
+	if !h.lowPower || h.vad != nil || h.format.Encoding != utils.AudioEncodingLinear16 {
+		return
+	}
+	vad, err := utils.NewVoiceActivityDetector(h.format.SampleRate, utils.VADSettingsFromConfig(h.session.Config), h.speechActivity)
+	if err != nil {
+		h.session.Logger.Warn("Low-power mode cannot gate audio", zap.Error(err))
+		return
+	}
+	h.vad, h.lowPowerVAD = vad, true
+}
+
+// Format returns the format of the audio the robot sends.
+func (h *AudioHandler) Format() utils.AudioFormat {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.input
+}
+
+// SetFormat switches the format of the audio the robot sends and reports
+// whether it changed. Audio already received is decoded and flushed to the
+// current stream, which is then closed: it cannot take the new format.
+// Audio buffered while the stream was down is in the old format, so it is
+// replayed to the old stream if it is still up, or else to a stream opened
+// for it in the old format. New audio is buffered until Reconnect installs
+// a new stream, and the caller must call it next. Called from the session's
+// read loop, so no audio arrives during the switch itself.
+func (h *AudioHandler) SetFormat(input utils.AudioFormat) (bool, error) {
+	if input == h.Format() {
+		return false, nil
+	}
+	chain, err := h.newChain(input)
+	if err != nil {
+		return false, err
+	}
+
+	h.mu.Lock()
+	decoder := h.decoder
+	h.mu.Unlock()
+	// Outside h.mu: the decoder's sink takes it while ffmpeg drains
+	if decoder != nil {
+		decoder.Close()
+	}
+
+	h.mu.Lock()
+	previous := h.stt
+	oldFormat := h.format
+	buffered := h.buffered
+	h.buffered = nil
+	h.bufferedBytes = 0
+	h.useChain(chain)
+	h.stt = nil
+	h.switching = true
+	h.mu.Unlock()
+
+	if previous != nil && previous.IsConnected() {
+		buffered = replayAudio(previous, buffered)
+	}
+	if previous != nil {
+		if err := previous.Finalize(); err != nil {
+			h.session.Logger.Debug("Failed to finalize speech-to-text before format change", zap.Error(err))
+		}
+		previous.Close()
+	}
+	if len(buffered) > 0 {
+		h.session.Supervisor.Go("stt_replay", func() { h.replayInFormat(oldFormat, buffered) })
+	}
+	h.session.Logger.Info("Audio format changed",
+		zap.String("encoding", input.Encoding),
+		zap.Int("sample_rate", input.SampleRate))
+	return true, nil
+}
+
+// connectSTT opens a stream to the tenant's speech-to-text backend. Deepgram
+// tries the models of the STT chain until one accepts the connection; the
+// other backends use their default model. A stream that failed to connect is
+// still returned so the monitor reconnects it; errors mean the backend is
+// misconfigured.
+func (h *AudioHandler) connectSTT() (utils.SpeechToText, error) {
+	h.mu.Lock()
+	format := h.format
+	h.mu.Unlock()
+	return h.connectSTTFormat(format)
+}
+
+// connectSTTFormat opens a stream taking audio in format.
+func (h *AudioHandler) connectSTTFormat(format utils.AudioFormat) (utils.SpeechToText, error) {
+	tenant := h.session.credentials()
+	provider := utils.STTProviderOf(tenant)
+	options := h.session.streamSTTOptions()
+	models := []string{""}
+	if provider == utils.STT_PROVIDER_DEEPGRAM {
+		models = h.session.modelChain(utils.MODEL_TASK_STT)
+	}
+
+	var stt utils.SpeechToText
+	for _, model := range models {
+		next, err := utils.NewSpeechToText(tenant, utils.STTStreamConfig{
+			Model:               model,
+			Language:            options.Language,
+			ConfidenceThreshold: 0.3, // Default confidence threshold
+			Format:              format,
+			Options:             options,
+			TranscriptionCh:     h.session.TranscriptionCh,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create %s stream: %w", provider, err)
+		}
+		stt = next
+		if stt.Connect() {
+			h.session.Logger.Info("Connected to speech-to-text", zap.String("provider", provider), zap.String("model", model))
+			return stt, nil
+		}
+		h.session.Logger.Warn("Speech-to-text connection failed, trying next STT model", zap.String("provider", provider), zap.String("model", model))
+	}
+	return stt, nil
+}
+
+// Reconnect replaces the speech-to-text stream, e.g. after the STT model,
+// language, audio format or the tenant's backend changed, and reports
+// whether the new stream connected. Audio keeps going to the old stream
+// until the new one is installed; closing the old one then flushes its
+// pending transcripts. A new stream that failed to connect is retried by
+// the monitor with the audio buffered.
+func (h *AudioHandler) Reconnect() bool {
+	stt, err := h.connectSTT()
+	if err != nil {
+		h.session.Logger.Error("Failed to reconnect speech-to-text", zap.Error(err))
+		h.mu.Lock()
+		// After a format change there is no old stream to fall back to
+		if h.switching {
+			h.switching = false
+			h.sttFailed = true
+			h.buffered = nil
+			h.bufferedBytes = 0
+		}
+		h.mu.Unlock()
+		h.session.sendError(ERROR_CODE_STT_UNAVAILABLE, "", "Speech-to-text unavailable, use text_input")
+		return false
+	}
+
+	h.mu.Lock()
+	if !h.isActive {
+		h.mu.Unlock()
+		stt.Close()
+		return false
+	}
+	previous := h.stt
+	h.switching = false
+	h.install(stt)
+	h.mu.Unlock()
+
+	if previous != nil {
+		previous.Close()
+	}
+	h.session.Supervisor.Go("stt_monitor", func() { h.monitor(stt) })
+	return stt.IsConnected()
+}
+
+// KeepAlive pings the current speech-to-text stream.
+func (h *AudioHandler) KeepAlive() error {
+	h.mu.Lock()
+	stt := h.stt
+	h.mu.Unlock()
+
+	if stt == nil {
+		return fmt.Errorf("speech-to-text stream not connected")
+	}
+	return stt.KeepAlive()
+}
+
+// Connected reports whether audio is streamed to speech-to-text.
+func (h *AudioHandler) Connected() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.stt != nil && h.stt.IsConnected()
+}
+
+// Provider names the session's current speech-to-text backend.
+func (h *AudioHandler) Provider() string {
+	return utils.STTProviderOf(h.session.credentials())
+}
+
+// monitor pings the stream every keepAliveInterval so a silently dropped
+// connection is noticed between utterances, and starts reconnecting when the
+// stream is lost. It returns once the stream is replaced or closed.
+func (h *AudioHandler) monitor(stt utils.SpeechToText) {
+	if stt == nil {
+		return
+	}
+
+	ticker := h.session.Clock.NewTicker(h.keepAliveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stt.Disconnected():
+			h.recover(stt)
+			return
+		case <-ticker.C():
+			if !h.isCurrent(stt) {
+				return
+			}
+			if err := stt.KeepAlive(); err != nil {
+				h.session.Logger.Warn("Speech-to-text keep-alive failed", zap.Error(err))
+			}
+		}
+	}
+}
+
+func (h *AudioHandler) isCurrent(stt utils.SpeechToText) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.isActive && h.stt == stt
+}
+
+// recover reconnects after lost dropped, backing off exponentially up to
+// maxBackoff. Audio is buffered meanwhile and replayed on the new stream.
+// After maxAttempts failures (0 retries forever) the client is told that
+// speech-to-text is unavailable until the next Reconnect.
+func (h *AudioHandler) recover(lost utils.SpeechToText) {
+	h.mu.Lock()
+	if !h.isActive || h.stt != lost || h.reconnecting {
+		h.mu.Unlock()
+		return
+	}
+	h.reconnecting = true
+	h.sttFailed = false
+	h.mu.Unlock()
+
+	defer func() {
+		h.mu.Lock()
+		h.reconnecting = false
+		h.mu.Unlock()
+	}()
+
+	h.session.Logger.Warn("Speech-to-text stream lost, reconnecting", zap.String("provider", lost.Provider()))
+	h.session.MetricLabels.ProviderError(lost.Provider())
+	h.session.sendError(ERROR_CODE_STT_RECONNECTING, "", "Speech-to-text stream lost, reconnecting; audio is buffered")
+
+	backoff := 500 * time.Millisecond
+	for attempt := 1; h.maxAttempts <= 0 || attempt <= h.maxAttempts; attempt++ {
+		h.sendSTTStatus(STT_STATUS_RECONNECTING, attempt)
+		<-h.session.Clock.After(backoff)
+		backoff = min(backoff*2, h.maxBackoff)
+
+		h.mu.Lock()
+		current := h.stt
+		active := h.isActive
+		h.mu.Unlock()
+		// Closed, or another Reconnect already installed a working stream
+		if !active || (current != lost && current != nil && current.IsConnected()) {
+			return
+		}
+
+		stt, err := h.connectSTT()
+		if err != nil || stt == nil || !stt.IsConnected() {
+			if stt != nil {
+				stt.Close()
+			}
+			h.session.Logger.Warn("Speech-to-text reconnect failed", zap.Int("attempt", attempt), zap.Error(err))
+			continue
+		}
+
+		h.mu.Lock()
+		if !h.isActive {
+			h.mu.Unlock()
+			stt.Close()
+			return
+		}
+		previous := h.stt
+		h.install(stt)
+		h.mu.Unlock()
+
+		if previous != nil {
+			previous.Close()
+		}
+		h.session.Logger.Info("Speech-to-text stream reconnected", zap.Int("attempt", attempt))
+		h.sendSTTStatus(STT_STATUS_CONNECTED, attempt)
+		h.session.Supervisor.Go("stt_monitor", func() { h.monitor(stt) })
+		return
+	}
+
+	h.mu.Lock()
+	h.sttFailed = true
+	h.buffered = nil
+	h.bufferedBytes = 0
+	h.mu.Unlock()
+
+	h.session.Logger.Error("Giving up reconnecting to speech-to-text", zap.Int("attempts", h.maxAttempts))
+	h.sendSTTStatus(STT_STATUS_FAILED, h.maxAttempts)
+	h.session.sendError(ERROR_CODE_STT_UNAVAILABLE, "", "Speech-to-text unavailable, use text_input")
+}
+
+// install makes stt the current stream and replays the audio buffered
+// while disconnected. Called with h.mu held, so live audio waits
+// for the replay and stays in order.
+func (h *AudioHandler) install(stt utils.SpeechToText) {
+	h.stt = stt
+	if stt == nil || !stt.IsConnected() {
+		return
+	}
+	h.sttFailed = false
+	h.reconnecting = false
+
+	if h.droppedBytes > 0 {
+		h.session.Logger.Warn("Audio dropped while speech-to-text was disconnected", zap.Int("bytes", h.droppedBytes))
+	}
+	for _, chunk := range h.buffered {
+		if err := stt.Send(chunk); err != nil {
+			h.session.Logger.Error("Failed to replay buffered audio", zap.Error(err))
+			break
+		}
+	}
+	h.buffered = nil
+	h.bufferedBytes = 0
+	h.droppedBytes = 0
+}
+
+// replayAudio sends chunks to stt and returns those it did not take.
+func replayAudio(stt utils.SpeechToText, chunks [][]byte) [][]byte {
+	for i, chunk := range chunks {
+		if err := stt.Send(chunk); err != nil {
+			return chunks[i:]
+		}
+	}
+	return nil
+}
+
+// replayInFormat transcribes audio buffered before a format change on a
+// stream of its own, as the session's stream no longer takes its format.
+func (h *AudioHandler) replayInFormat(format utils.AudioFormat, chunks [][]byte) {
+	bytes := 0
+	for _, chunk := range chunks {
+		bytes += len(chunk)
+	}
+	stt, err := h.connectSTTFormat(format)
+	if err != nil || !stt.IsConnected() {
+		if stt != nil {
+			stt.Close()
+		}
+		h.session.Logger.Warn("Failed to replay audio buffered before the format change", zap.Int("bytes", bytes), zap.Error(err))
+		return
+	}
+	defer stt.Close()
+	if left := replayAudio(stt, chunks); len(left) > 0 {
+		h.session.Logger.Warn("Failed to replay audio buffered before the format change", zap.Int("chunks", len(left)))
+	}
+	if err := stt.Finalize(); err != nil {
+		h.session.Logger.Debug("Failed to finalize replayed audio", zap.Error(err))
+	}
+	h.session.Logger.Info("Replayed audio buffered before the format change", zap.Int("bytes", bytes))
+}
+
+// bufferAudio keeps a chunk for replay, dropping the oldest audio past the
+// buffer limit. Called with h.mu held.
+func (h *AudioHandler) bufferAudio(chunk []byte) {
+	if len(chunk) == 0 {
+		return
+	}
+	h.buffered = append(h.buffered, chunk)
+	h.bufferedBytes += len(chunk)
+	for h.bufferedBytes > h.bufferLimit && len(h.buffered) > 0 {
+		h.bufferedBytes -= len(h.buffered[0])
+		h.droppedBytes += len(h.buffered[0])
+		h.buffered = h.buffered[1:]
+	}
+}
+
+func (h *AudioHandler) sendSTTStatus(status string, attempt int) {
+	h.mu.Lock()
+	payload := STTStatusPayload{
+		Status:        status,
+		Attempt:       attempt,
+		BufferedBytes: h.bufferedBytes,
+		DroppedBytes:  h.droppedBytes,
+	}
+	h.mu.Unlock()
+	h.session.sendWebSocketMessage("stt_status", payload)
+}
+
 func (h *AudioHandler) handleTranscript() {
-	for h.session.IsActive {
-		transcript := <-h.session.TranscriptionCh
+	// Checks the hard flush deadline between speech-to-text segments
+	ticker := h.session.Clock.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	var bufferStarted time.Time
+	for h.session.IsActive() {
+		var transcript string
+		select {
+		case t, ok := <-h.session.TranscriptionCh:
+			if !ok {
+				return
+			}
+			transcript = t
+		case <-ticker.C():
+			settings := h.session.transcriptSettings()
+			if h.session.transcriptBuffer() != "" && settings.FlushAfter > 0 && h.session.Clock.Since(bufferStarted) >= settings.FlushAfter {
+				h.flushTranscript("flush_after")
+			}
+			continue
+		}
+
 		if transcript == models.SESSION_END {
 			h.session.Logger.Info("Session orchestrator received SESSION_END")
 			return
 		}
 
-		h.session.Logger.Debug("Received transcript", zap.String("transcript", transcript))
+		if transcript == utils.END_OF_SPEECH {
+			h.flushTranscript("end_of_speech")
+			continue
+		}
 
-		if transcript == "<END_OF_SPEECH>" {
-			// Process the accumulated transcript for intention
-			if h.session.CurrentTranscript != "" {
-				h.session.Logger.Info("End of speech detected, processing transcript", zap.String("transcript", h.session.CurrentTranscript))
-				h.session.sendWebSocketMessage("transcript_final", map[string]string{
-					"transcript": transcript,
-				})
-				// Update context for new processing
-				h.session.UpdateContext()
+		transcript = h.stages.ProcessTranscript(AUDIO_PHASE_STT, transcript)
+		transcript = h.session.filterTranscript(transcript)
+		h.session.Logger.Debug("Received transcript", zap.String("transcript", h.session.redact(transcript)))
 
-				// Send the final transcript to the client
-				// h.session.sendWebSocketMessage("transcript_final", map[string]interface{}{
-				// 	"transcript": h.session.CurrentTranscript,
-				// 	"timestamp":  time.Now(),
-				// })
+		// Accumulate transcript (filter out empty/whitespace)
+		if strings.TrimSpace(transcript) == "" {
+			continue
+		}
+		h.session.Inactivity.Touch()
+		if h.session.transcriptBuffer() == "" {
+			bufferStarted = h.session.Clock.Now()
+		}
+		buffered := strings.TrimSpace(h.session.appendTranscript(transcript + " "))
 
-				// Process the complete transcript for intention analysis
-				h.session.IntentionHandler.ProcessTranscript(h.session.CurrentTranscript)
+		settings := h.session.transcriptSettings()
+		if utf8.RuneCountInString(buffered) >= settings.MaxLength {
+			h.flushTranscript("max_length")
+			continue
+		}
 
-				// Reset transcript buffer
-				h.session.CurrentTranscript = ""
-			}
-		} else {
-			// Accumulate transcript (filter out empty/whitespace)
-			if strings.TrimSpace(transcript) != "" {
-				h.session.CurrentTranscript += transcript + " "
-
-				// Send interim transcript to client
-				h.session.sendWebSocketMessage("transcript_interim", map[string]string{
-					"transcript": strings.TrimSpace(h.session.CurrentTranscript),
-				})
-			}
+		// Send interim transcript to client
+		if settings.EchoInterim {
+			h.session.sendWebSocketMessage("transcript_interim", TranscriptPayload{
+				Transcript: buffered,
+			})
 		}
+		h.session.Captions.Publish(buffered, false, "speech")
 	}
 }
 
-// ProcessAudioData sends audio data directly to Deepgram (called from WebSocket handler)
+// flushTranscript hands the accumulated transcript to intention analysis and
+// resets the buffer. reason is end_of_speech, max_length or flush_after.
+func (h *AudioHandler) flushTranscript(reason string) {
+	// Again over the whole utterance, for numbers split across segments
+	transcript := strings.TrimSpace(h.session.filterTranscript(h.session.takeTranscript()))
+	transcript = strings.TrimSpace(h.stages.ProcessTranscript(AUDIO_PHASE_TRANSCRIPT, transcript))
+	if transcript == "" {
+		return
+	}
+
+	h.session.Logger.Info("Processing transcript",
+		zap.String("reason", reason), zap.String("transcript", h.session.redact(transcript)))
+	h.session.sendWebSocketMessage("transcript_final", TranscriptPayload{Transcript: transcript})
+	h.session.Captions.Publish(transcript, true, "speech")
+
+	// Queue the complete transcript for intention analysis; the next
+	// utterance cancels it if it is still running
+	h.session.startUtterance()
+	h.session.IntentionHandler.ProcessTranscript(transcript)
+}
+
+// ProcessAudioData sends audio data to speech-to-text (called from WebSocket handler),
+// denoising and normalizing it first when preprocessing is enabled. While the
+// stream is reconnecting the audio is buffered instead. Compressed audio is
+// handed to the decoder, which forwards the PCM as it is decoded.
 func (h *AudioHandler) ProcessAudioData(audioData []byte) error {
-	// Send audio data to Deepgram immediately
-	err := h.deepgramClient.Send(audioData)
-	if err != nil {
-		h.session.Logger.Error("Failed to send audio data to Deepgram", zap.Error(err))
+	if h.decoder != nil {
+		if err := h.decoder.Write(audioData); err != nil {
+			return err
+		}
+		h.session.recordUsage(models.USAGE_AUDIO_BYTES, int64(len(audioData)))
+		return nil
+	}
+	if err := h.forward(audioData); err != nil {
 		return err
 	}
+	h.session.recordUsage(models.USAGE_AUDIO_BYTES, int64(len(audioData)))
+	return nil
+}
 
+// forwardDecoded is the decoder's sink.
+func (h *AudioHandler) forwardDecoded(pcm []byte) {
+	h.mu.Lock()
+	active := h.isActive
+	h.mu.Unlock()
+	if !active {
+		return
+	}
+	if err := h.forward(pcm); err != nil {
+		h.session.Logger.Error("Failed to process decoded audio", zap.Error(err))
+		h.session.sendError(ERROR_CODE_STT_UNAVAILABLE, "audio_data", err.Error())
+	}
+}
+
+// speechActivity is the detector's callback, called from forward with h.mu
+// held.
+func (h *AudioHandler) speechActivity(speaking bool) {
+	if !speaking {
+		h.finalize = true
+	}
+	h.session.Logger.Debug("Speech activity changed", zap.Bool("speaking", speaking))
+	h.session.sendWebSocketMessage("speech_activity", SpeechActivityPayload{
+		Speaking:  speaking,
+		Timestamp: h.session.Clock.Now().Unix(),
+	})
+}
+
+// forward preprocesses audio in the stream's format, drops it while the
+// voice activity detector finds no speech, and sends or buffers the rest.
+func (h *AudioHandler) forward(audioData []byte) error {
+	h.mu.Lock()
+	processed := audioData
+	if h.preprocessor != nil {
+		processed = h.preprocessor.Process(audioData)
+	}
+	processed = h.stages.ProcessAudio(AUDIO_PHASE_PREPROCESS, h.format, processed)
+	finalize := false
+	if h.vad != nil {
+		processed = h.vad.Process(processed)
+		finalize, h.finalize = h.finalize, false
+	}
+	processed = h.stages.ProcessAudio(AUDIO_PHASE_VAD, h.format, processed)
+	if len(processed) == 0 && !finalize {
+		h.mu.Unlock()
+		return nil
+	}
+	if h.sttFailed {
+		h.mu.Unlock()
+		return fmt.Errorf("speech-to-text unavailable")
+	}
+	if h.reconnecting || h.switching {
+		h.bufferAudio(processed)
+		h.mu.Unlock()
+		return nil
+	}
+	stt := h.stt
+	h.mu.Unlock()
+
+	if stt == nil {
+		return fmt.Errorf("speech-to-text stream not connected")
+	}
+	if !stt.IsConnected() {
+		// Lost but not yet picked up by the monitor
+		h.mu.Lock()
+		h.bufferAudio(processed)
+		h.mu.Unlock()
+		return nil
+	}
+	if len(processed) > 0 {
+		if err := stt.Send(processed); err != nil {
+			h.session.Logger.Error("Failed to send audio data to speech-to-text", zap.String("provider", stt.Provider()), zap.Error(err))
+			h.session.MetricLabels.ProviderError(stt.Provider())
+
+			// Keep the chunk for replay; the monitor sees the stream is down
+			h.mu.Lock()
+			h.bufferAudio(processed)
+			h.mu.Unlock()
+			return err
+		}
+		h.session.recordUsage(models.USAGE_AUDIO_STREAMED_BYTES, int64(len(processed)))
+	}
+	// No audio follows until the next utterance, so the backend's own
+	// endpointing would never fire
+	if finalize {
+		if err := stt.Finalize(); err != nil {
+			h.session.Logger.Warn("Failed to finalize speech-to-text utterance", zap.String("provider", stt.Provider()), zap.Error(err))
+		}
+	}
 	return nil
 }
 
 func (h *AudioHandler) Close() {
 	h.session.Logger.Info("Closing Audio Handler")
+
+	h.mu.Lock()
 	h.isActive = false
+	h.mu.Unlock()
+
+	// Outside h.mu: the decoder's sink takes it while ffmpeg drains
+	if h.decoder != nil {
+		h.decoder.Close()
+	}
 
-	if h.deepgramClient != nil {
-		h.deepgramClient.Close()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.stt != nil {
+		h.stt.Close()
 	}
 }
@@ -0,0 +1,174 @@
+// handlers/audio_pipeline.go
+
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+	"go.uber.org/zap"
+)
+
+// Phases of the audio pipeline, in the order they run. Stages registered
+// for a phase run after its built-in work:
+//   - preprocess: every chunk, after decoding, denoising and normalization
+//   - vad: the chunks voice activity detection lets through (all of them
+//     without VAD_ENABLED)
+//   - stt: each transcript segment from speech-to-text, before filtering
+//   - transcript: the complete utterance, before transcript_final and
+//     intention analysis
+const (
+	AUDIO_PHASE_PREPROCESS = "preprocess"
+	AUDIO_PHASE_VAD        = "vad"
+	AUDIO_PHASE_STT        = "stt"
+	AUDIO_PHASE_TRANSCRIPT = "transcript"
+)
+
+var audioPhases = []string{AUDIO_PHASE_PREPROCESS, AUDIO_PHASE_VAD, AUDIO_PHASE_STT, AUDIO_PHASE_TRANSCRIPT}
+
+// AudioStage is a step of the audio pipeline. Integrators embedding the SDK
+// register their own with RegisterAudioStage, e.g. for keyword spotting or
+// acoustic event detection (glass breaking, alarms). Audio stages run on
+// the session's audio path and must keep up with real time. Errors and
+// panics are logged and the audio carries on.
+type AudioStage interface {
+	// Name identifies the stage in logs and audio_event messages
+	Name() string
+	// Phase is one of the AUDIO_PHASE_* constants
+	Phase() string
+	Process(ctx context.Context, chunk *AudioChunk) error
+}
+
+// AudioChunk is what moves through the pipeline: audio in the preprocess
+// and vad phases, text in the stt and transcript phases. A stage may
+// replace Audio or Transcript; emptying them drops the chunk.
+type AudioChunk struct {
+	SessionID string
+	TenantID  string
+	RobotID   string
+	// Metadata the session was started with (robot_id, site, ...)
+	Metadata map[string]string
+	// Format of Audio, linear16 unless the robot streams a container
+	// format that is not decoded
+	Format     utils.AudioFormat
+	Audio      []byte
+	Transcript string
+
+	session *RoboSession
+	stage   string
+}
+
+// Emit sends the robot an audio_event message, such as
+// Emit("glass_breaking", map[string]interface{}{"confidence": 0.93}).
+func (c *AudioChunk) Emit(event string, data interface{}) {
+	c.session.sendWebSocketMessage("audio_event", AudioEventPayload{
+		Event:     event,
+		Stage:     c.stage,
+		Data:      data,
+		Timestamp: c.session.Clock.Now().Unix(),
+	})
+}
+
+var (
+	audioStagesMu sync.RWMutex
+	audioStages   []AudioStage
+)
+
+// RegisterAudioStage adds a stage to the audio pipeline of every session.
+// Stages of a phase run in registration order; register them before the
+// server starts accepting sessions.
+func RegisterAudioStage(stage AudioStage) error {
+	if !slices.Contains(audioPhases, stage.Phase()) {
+		return fmt.Errorf("audio stage %s: unknown phase %q", stage.Name(), stage.Phase())
+	}
+	audioStagesMu.Lock()
+	defer audioStagesMu.Unlock()
+	audioStages = append(audioStages, stage)
+	zap.L().Info("Registered audio stage", zap.String("stage", stage.Name()), zap.String("phase", stage.Phase()))
+	return nil
+}
+
+// AudioStageFunc adapts a function to an AudioStage.
+func AudioStageFunc(name, phase string, process func(ctx context.Context, chunk *AudioChunk) error) AudioStage {
+	return audioStageFunc{name: name, phase: phase, process: process}
+}
+
+type audioStageFunc struct {
+	name, phase string
+	process     func(ctx context.Context, chunk *AudioChunk) error
+}
+
+func (s audioStageFunc) Name() string  { return s.name }
+func (s audioStageFunc) Phase() string { return s.phase }
+func (s audioStageFunc) Process(ctx context.Context, chunk *AudioChunk) error {
+	return s.process(ctx, chunk)
+}
+
+// AudioPipeline runs a session's audio stages: the built-in stages that
+// work like plugins, such as acoustic event detection, then the registered
+// ones. Decoding, preprocessing and VAD stay in the AudioHandler.
+type AudioPipeline struct {
+	session *RoboSession
+	stages  map[string][]AudioStage
+}
+
+func newAudioPipeline(session *RoboSession, builtin ...AudioStage) *AudioPipeline {
+	audioStagesMu.RLock()
+	defer audioStagesMu.RUnlock()
+	pipeline := &AudioPipeline{session: session, stages: make(map[string][]AudioStage)}
+	for _, stage := range append(builtin, audioStages...) {
+		pipeline.stages[stage.Phase()] = append(pipeline.stages[stage.Phase()], stage)
+	}
+	return pipeline
+}
+
+// ProcessAudio passes audio through the stages of an audio phase and
+// returns what is left of it.
+func (p *AudioPipeline) ProcessAudio(phase string, format utils.AudioFormat, audio []byte) []byte {
+	if len(p.stages[phase]) == 0 || len(audio) == 0 {
+		return audio
+	}
+	chunk := p.chunk()
+	chunk.Format, chunk.Audio = format, audio
+	p.run(phase, chunk)
+	return chunk.Audio
+}
+
+// ProcessTranscript passes text through the stages of a text phase and
+// returns what is left of it.
+func (p *AudioPipeline) ProcessTranscript(phase, transcript string) string {
+	if len(p.stages[phase]) == 0 || transcript == "" {
+		return transcript
+	}
+	chunk := p.chunk()
+	chunk.Transcript = transcript
+	p.run(phase, chunk)
+	return chunk.Transcript
+}
+
+func (p *AudioPipeline) chunk() *AudioChunk {
+	rs := p.session
+	return &AudioChunk{
+		SessionID: rs.ID,
+		TenantID:  rs.Tenant.ID,
+		RobotID:   rs.RobotID,
+		Metadata:  maps.Clone(rs.Metadata),
+		session:   rs,
+	}
+}
+
+func (p *AudioPipeline) run(phase string, chunk *AudioChunk) {
+	for _, stage := range p.stages[phase] {
+		if len(chunk.Audio) == 0 && chunk.Transcript == "" {
+			return
+		}
+		chunk.stage = stage.Name()
+		if err := runPluginStage(func() error { return stage.Process(p.session.sessionCtx, chunk) }); err != nil {
+			p.session.Logger.Warn("Audio stage failed", zap.String("stage", stage.Name()), zap.String("phase", phase), zap.Error(err))
+		}
+	}
+}
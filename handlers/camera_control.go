@@ -0,0 +1,314 @@
+// handlers/camera_control.go
+
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+	"go.uber.org/zap"
+)
+
+// Actions of a camera_control message. get and set are requests; state
+// answers them, and robots also send it when their camera changes.
+const (
+	CAMERA_ACTION_GET   = "get"
+	CAMERA_ACTION_SET   = "set"
+	CAMERA_ACTION_STATE = "state"
+)
+
+// Cameras a camera_control message refers to: the server's own capture of
+// the session's RTSP source or server camera, or the robot's camera.
+const (
+	CAMERA_SOURCE_SERVER = "server"
+	CAMERA_SOURCE_ROBOT  = "robot"
+)
+
+// cameraControlTimeout bounds applying settings to the server's capture,
+// e.g. running v4l2-ctl.
+const cameraControlTimeout = 10 * time.Second
+
+// CameraController reads and changes the session's camera parameters. While
+// the server ingests an RTSP source or camera it adjusts that capture itself;
+// otherwise requests are forwarded to the robot, which answers with its
+// camera state.
+type CameraController struct {
+	session *RoboSession
+
+	mu         sync.Mutex
+	pending    map[string]chan CameraControlPayload
+	robotState *utils.CameraSettings
+}
+
+func InitCameraController(session *RoboSession) *CameraController {
+	return &CameraController{
+		session: session,
+		pending: make(map[string]chan CameraControlPayload),
+	}
+}
+
+// serverCamera returns the server-side capture, or nil when frames come
+// from the robot.
+func (c *CameraController) serverCamera() utils.AdjustableCamera {
+	ingester := c.session.currentIngester()
+	if ingester == nil {
+		return nil
+	}
+	camera, _ := ingester.capture.(utils.AdjustableCamera)
+	return camera
+}
+
+// controlServer runs a get or set request against the server-side capture
+// and returns the resulting state, which also carries the error.
+func (c *CameraController) controlServer(ctx context.Context, camera utils.AdjustableCamera, requestID, action string, settings *utils.CameraSettings) (CameraControlPayload, error) {
+	state := CameraControlPayload{RequestID: requestID, Action: CAMERA_ACTION_STATE, Source: CAMERA_SOURCE_SERVER}
+	current := camera.CameraSettings()
+	var err error
+	if action == CAMERA_ACTION_SET {
+		ctx, cancel := context.WithTimeout(ctx, cameraControlTimeout)
+		defer cancel()
+		var applied utils.CameraSettings
+		applied, err = camera.ApplyCameraSettings(ctx, *settings)
+		current = applied
+		if err != nil {
+			c.session.Logger.Warn("Failed to apply camera settings", zap.Any("settings", settings), zap.Error(err))
+			state.Error = err.Error()
+		} else {
+			c.session.Logger.Info("Applied camera settings", zap.Any("settings", applied))
+		}
+	}
+	state.Settings = &current
+	return state, err
+}
+
+// Send forwards a get or set request to the robot and returns its ID; the
+// robot's state can be awaited with WaitForState.
+func (c *CameraController) Send(action string, settings *utils.CameraSettings) string {
+	requestID := c.session.IDs.NewID()
+
+	c.mu.Lock()
+	c.pending[requestID] = make(chan CameraControlPayload, 1)
+	c.mu.Unlock()
+
+	c.session.Logger.Info("Sending camera control to robot",
+		zap.String("request_id", requestID), zap.String("action", action), zap.Any("settings", settings))
+	c.session.sendWebSocketMessage("camera_control", CameraControlPayload{
+		RequestID: requestID,
+		Action:    action,
+		Source:    CAMERA_SOURCE_ROBOT,
+		Settings:  settings,
+	})
+	return requestID
+}
+
+// WaitForState blocks until the robot answers a request or timeout.
+func (c *CameraController) WaitForState(ctx context.Context, requestID string, timeout time.Duration) (CameraControlPayload, error) {
+	c.mu.Lock()
+	ch, ok := c.pending[requestID]
+	c.mu.Unlock()
+	if !ok {
+		return CameraControlPayload{}, fmt.Errorf("unknown camera control request %s", requestID)
+	}
+
+	select {
+	case state := <-ch:
+		return state, nil
+	case <-time.After(timeout):
+		return CameraControlPayload{}, fmt.Errorf("timed out waiting for the camera state")
+	case <-ctx.Done():
+		return CameraControlPayload{}, ctx.Err()
+	}
+}
+
+// RobotState returns the camera settings the robot last reported, or nil.
+func (c *CameraController) RobotState() *utils.CameraSettings {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.robotState
+}
+
+// handleMessage handles an inbound camera_control message: the robot's
+// camera state, or a request for the server-side capture.
+func (c *CameraController) handleMessage(data interface{}) {
+	message, protocolErr := decodeCameraControl(data)
+	if protocolErr != nil {
+		c.session.sendProtocolError(protocolErr)
+		return
+	}
+
+	if message.Action == CAMERA_ACTION_STATE {
+		c.handleState(message)
+		return
+	}
+
+	camera := c.serverCamera()
+	if camera == nil {
+		c.session.sendWebSocketMessage("camera_control", CameraControlPayload{
+			RequestID: message.RequestID,
+			Action:    CAMERA_ACTION_STATE,
+			Source:    CAMERA_SOURCE_SERVER,
+			Error:     "no server-side camera; set rtsp_url or camera_device to control one",
+		})
+		return
+	}
+	state, _ := c.controlServer(c.session.sessionCtx, camera, message.RequestID, message.Action, message.Settings)
+	c.session.sendWebSocketMessage("camera_control", state)
+}
+
+func (c *CameraController) handleState(state CameraControlPayload) {
+	state.Source = CAMERA_SOURCE_ROBOT
+
+	c.mu.Lock()
+	if state.Settings != nil {
+		c.robotState = state.Settings
+	}
+	ch, ok := c.pending[state.RequestID]
+	delete(c.pending, state.RequestID)
+	c.mu.Unlock()
+
+	c.session.Logger.Info("Robot reported camera state",
+		zap.String("request_id", state.RequestID),
+		zap.Any("settings", state.Settings),
+		zap.String("error", state.Error))
+	if ok {
+		ch <- state
+	}
+}
+
+// decodeCameraControl decodes and validates a camera_control payload whose
+// field types validateInbound already checked.
+func decodeCameraControl(data interface{}) (CameraControlPayload, *ProtocolError) {
+	var message CameraControlPayload
+	raw, _ := json.Marshal(data)
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&message); err != nil {
+		return message, newProtocolError(PROTOCOL_ERROR_INVALID_PAYLOAD, "camera_control", "data", err.Error())
+	}
+	if message.Action == CAMERA_ACTION_SET && message.Settings == nil {
+		return message, newProtocolError(PROTOCOL_ERROR_INVALID_PAYLOAD, "camera_control", "data.settings", "settings are required to set the camera")
+	}
+	if message.Settings != nil && message.Action != CAMERA_ACTION_STATE {
+		settings, err := message.Settings.Validate()
+		if err != nil {
+			return message, newProtocolError(PROTOCOL_ERROR_INVALID_PAYLOAD, "camera_control", "data.settings", err.Error())
+		}
+		message.Settings = &settings
+	}
+	return message, nil
+}
+
+// HandleSessionCamera reads or changes a live session's camera parameters:
+// GET /robot/sessions/{id}/camera returns the known state and
+// POST /robot/sessions/{id}/camera[?wait=5s] applies settings. With wait,
+// robot cameras are asked for their state and the answer is awaited.
+func HandleSessionCamera(w http.ResponseWriter, r *http.Request, tenants *utils.TenantStore) {
+	rs, ok := resolveTenantSession(w, r, tenants)
+	if !ok {
+		return
+	}
+	if !rs.hasModality(MODALITY_VIDEO) {
+		http.Error(w, "video modality is not enabled for this session", http.StatusConflict)
+		return
+	}
+
+	var timeout time.Duration
+	if wait := r.URL.Query().Get("wait"); wait != "" {
+		var err error
+		if timeout, err = time.ParseDuration(wait); err != nil {
+			http.Error(w, "invalid wait duration", http.StatusBadRequest)
+			return
+		}
+	}
+
+	action := CAMERA_ACTION_GET
+	var settings *utils.CameraSettings
+	if r.Method == http.MethodPost {
+		action = CAMERA_ACTION_SET
+		decoder := json.NewDecoder(r.Body)
+		decoder.DisallowUnknownFields()
+		var update utils.CameraSettings
+		if err := decoder.Decode(&update); err != nil {
+			http.Error(w, "invalid camera settings", http.StatusBadRequest)
+			return
+		}
+		update, err := update.Validate()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		settings = &update
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if camera := rs.Camera.serverCamera(); camera != nil {
+		state, err := rs.Camera.controlServer(r.Context(), camera, "", action, settings)
+		if err != nil {
+			status := http.StatusBadGateway
+			if errors.Is(err, utils.ErrCameraSettingUnsupported) {
+				status = http.StatusUnprocessableEntity
+			}
+			w.WriteHeader(status)
+		}
+		json.NewEncoder(w).Encode(state)
+		return
+	}
+
+	if action == CAMERA_ACTION_GET && timeout == 0 {
+		json.NewEncoder(w).Encode(CameraControlPayload{
+			Action:   CAMERA_ACTION_STATE,
+			Source:   CAMERA_SOURCE_ROBOT,
+			Settings: rs.Camera.RobotState(),
+		})
+		return
+	}
+
+	requestID := rs.Camera.Send(action, settings)
+	if timeout == 0 {
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]interface{}{"request_id": requestID})
+		return
+	}
+	state, err := rs.Camera.WaitForState(r.Context(), requestID, timeout)
+	if err != nil {
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]interface{}{"request_id": requestID, "error": err.Error()})
+		return
+	}
+	json.NewEncoder(w).Encode(state)
+}
+
+// HandleCameras lists the cameras attached to the machine running the
+// server, for the camera_device config: GET /robot/cameras. Disabled unless
+// SERVER_CAMERAS_ENABLED is set, as every tenant would share the cameras.
+func HandleCameras(w http.ResponseWriter, r *http.Request) {
+	if !utils.CurrentConfig().ServerCamerasEnabled {
+		http.Error(w, "server cameras are not enabled", http.StatusNotFound)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 15*time.Second)
+	defer cancel()
+	devices, err := utils.ListCameras(ctx)
+	if err != nil {
+		zap.L().Warn("Failed to list cameras", zap.Error(err))
+		http.Error(w, "failed to list cameras: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if devices == nil {
+		devices = []utils.CameraDevice{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"platform": runtime.GOOS,
+		"backend":  utils.CameraBackend(),
+		"cameras":  devices,
+	})
+}
@@ -0,0 +1,323 @@
+// handlers/captions.go
+
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+// captionLanguage matches BCP-47 codes such as "es" or "pt-BR".
+var captionLanguage = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+
+type captionViewer struct {
+	ch       chan CaptionPayload
+	language string
+}
+
+// CaptionFeed streams a session's transcripts to caption viewers such as
+// wall displays and accessibility clients. Viewers hold a token issued by
+// the session owner. Final captions are translated once per language
+// watched, in order, on a single worker; interim captions only reach
+// viewers of the original language. Slow viewers miss captions rather than
+// delaying the session.
+type CaptionFeed struct {
+	session *RoboSession
+
+	mu      sync.Mutex
+	viewers map[*captionViewer]struct{}
+	tokens  map[string]time.Time
+	closed  bool
+
+	translations    chan CaptionPayload
+	startTranslator sync.Once
+	maxViewers      int
+}
+
+func NewCaptionFeed(session *RoboSession) *CaptionFeed {
+	return &CaptionFeed{
+		session:      session,
+		viewers:      make(map[*captionViewer]struct{}),
+		tokens:       make(map[string]time.Time),
+		translations: make(chan CaptionPayload, 32),
+		maxViewers:   session.Config.CaptionMaxViewers,
+	}
+}
+
+// IssueToken returns a new viewer token valid for ttl.
+func (f *CaptionFeed) IssueToken(ttl time.Duration) (string, time.Time, error) {
+	raw := make([]byte, 24)
+	if _, err := rand.Read(raw); err != nil {
+		return "", time.Time{}, err
+	}
+	token := hex.EncodeToString(raw)
+	expiresAt := f.session.Clock.Now().Add(ttl)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := f.session.Clock.Now()
+	for issued, expiry := range f.tokens {
+		if now.After(expiry) {
+			delete(f.tokens, issued)
+		}
+	}
+	f.tokens[token] = expiresAt
+	return token, expiresAt, nil
+}
+
+// RevokeTokens invalidates every viewer token and disconnects the viewers.
+func (f *CaptionFeed) RevokeTokens() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	revoked := len(f.tokens)
+	f.tokens = make(map[string]time.Time)
+	for viewer := range f.viewers {
+		close(viewer.ch)
+	}
+	f.viewers = make(map[*captionViewer]struct{})
+	return revoked
+}
+
+func (f *CaptionFeed) validToken(token string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	expiresAt, ok := f.tokens[token]
+	return ok && token != "" && f.session.Clock.Now().Before(expiresAt)
+}
+
+// subscribe adds a viewer of captions in language ("" for the original).
+// ok is false when the session ended or has CAPTION_MAX_VIEWERS viewers.
+func (f *CaptionFeed) subscribe(language string) (<-chan CaptionPayload, func(), bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed || len(f.viewers) >= f.maxViewers {
+		return nil, nil, false
+	}
+	if language != "" {
+		f.startTranslator.Do(func() { go f.translate() })
+	}
+
+	viewer := &captionViewer{ch: make(chan CaptionPayload, 64), language: language}
+	f.viewers[viewer] = struct{}{}
+	return viewer.ch, func() {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if _, ok := f.viewers[viewer]; ok {
+			delete(f.viewers, viewer)
+			close(viewer.ch)
+		}
+	}, true
+}
+
+// Publish sends a transcript to the viewers, queueing final ones for
+// translation when viewers watch other languages.
+func (f *CaptionFeed) Publish(text string, final bool, source string) {
+	if f == nil || text == "" {
+		return
+	}
+	caption := CaptionPayload{Text: text, Final: final, Source: source, Timestamp: f.session.Clock.Now()}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	translate := false
+	for viewer := range f.viewers {
+		if viewer.language != "" {
+			translate = true
+			continue
+		}
+		f.deliver(viewer, caption)
+	}
+	if translate && final && !f.closed {
+		select {
+		case f.translations <- caption:
+		default:
+			f.session.Logger.Warn("Caption translation queue full, dropping caption")
+		}
+	}
+}
+
+// deliver is called with f.mu held.
+func (f *CaptionFeed) deliver(viewer *captionViewer, caption CaptionPayload) {
+	select {
+	case viewer.ch <- caption:
+	default:
+	}
+}
+
+// translate serves the translation queue until the session ends.
+func (f *CaptionFeed) translate() {
+	rs := f.session
+	for caption := range f.translations {
+		f.mu.Lock()
+		languages := make(map[string]bool)
+		for viewer := range f.viewers {
+			if viewer.language != "" {
+				languages[viewer.language] = true
+			}
+		}
+		f.mu.Unlock()
+
+		for language := range languages {
+			ctx, cancel := context.WithTimeout(rs.sessionCtx, 10*time.Second)
+			text, err := rs.openAIClient().TranslateCaption(ctx, caption.Text, language)
+			cancel()
+			if err != nil {
+				if !cancelled(rs.sessionCtx) {
+					rs.Logger.Warn("Failed to translate caption", zap.String("language", language), zap.Error(err))
+					rs.MetricLabels.ProviderError("openai")
+				}
+				continue
+			}
+			translated := caption
+			translated.Text = text
+			translated.Language = language
+
+			f.mu.Lock()
+			for viewer := range f.viewers {
+				if viewer.language == language {
+					f.deliver(viewer, translated)
+				}
+			}
+			f.mu.Unlock()
+		}
+	}
+}
+
+// Close ends every viewer stream and the translation worker.
+func (f *CaptionFeed) Close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return
+	}
+	f.closed = true
+	for viewer := range f.viewers {
+		close(viewer.ch)
+	}
+	f.viewers = make(map[*captionViewer]struct{})
+	close(f.translations)
+}
+
+// HandleCaptionToken issues a caption viewer token for a live session:
+// POST /robot/sessions/{id}/captions/tokens[?ttl=2h]
+// DELETE revokes every token and disconnects the viewers.
+func HandleCaptionToken(w http.ResponseWriter, r *http.Request, tenants *utils.TenantStore) {
+	rs, ok := resolveTenantSession(w, r, tenants)
+	if !ok {
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		revoked := rs.Captions.RevokeTokens()
+		rs.Logger.Info("Caption tokens revoked", zap.Int("tokens", revoked))
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	ttl := utils.CurrentConfig().CaptionTokenTTL
+	if value := r.URL.Query().Get("ttl"); value != "" {
+		requested, err := time.ParseDuration(value)
+		if err != nil || requested <= 0 || requested > ttl {
+			http.Error(w, "ttl must be a positive duration of at most "+ttl.String(), http.StatusBadRequest)
+			return
+		}
+		ttl = requested
+	}
+	token, expiresAt, err := rs.Captions.IssueToken(ttl)
+	if err != nil {
+		rs.Logger.Error("Failed to issue caption token", zap.Error(err))
+		http.Error(w, "failed to issue token", http.StatusInternalServerError)
+		return
+	}
+	rs.Logger.Info("Caption token issued", zap.Time("expires_at", expiresAt))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(CaptionTokenPayload{
+		Token:     token,
+		URL:       "/robot/sessions/" + rs.ID + "/captions?token=" + token,
+		ExpiresAt: expiresAt,
+	})
+}
+
+// HandleCaptions streams a live session's captions over a read-only
+// WebSocket: GET /robot/sessions/{id}/captions?token=...[&lang=es]
+// Authentication is the caption token alone, so displays need no tenant key.
+func HandleCaptions(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	rs, ok := GetSession(r.PathValue("id"))
+	if !ok || !rs.Captions.validToken(query.Get("token")) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	language := query.Get("lang")
+	if language != "" && !captionLanguage.MatchString(language) {
+		http.Error(w, "lang must be a BCP-47 language code", http.StatusBadRequest)
+		return
+	}
+
+	captions, unsubscribe, ok := rs.Captions.subscribe(language)
+	if !ok {
+		http.Error(w, "too many caption viewers", http.StatusServiceUnavailable)
+		return
+	}
+	defer unsubscribe()
+
+	conn, err := captionUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		rs.Logger.Warn("Failed to upgrade caption viewer", zap.Error(err))
+		return
+	}
+	defer conn.Close()
+	rs.Logger.Info("Caption viewer connected", zap.String("remote_addr", r.RemoteAddr), zap.String("language", language))
+
+	// Read-only: inbound messages are discarded, reading notices the close
+	gone := make(chan struct{})
+	rs.Supervisor.Task("caption_reader", func() {
+		defer close(gone)
+		conn.SetReadLimit(512)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	})
+
+	ping := time.NewTicker(30 * time.Second)
+	defer ping.Stop()
+	for {
+		select {
+		case <-gone:
+			return
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(5*time.Second)); err != nil {
+				return
+			}
+		case caption, ok := <-captions:
+			if !ok {
+				conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseNormalClosure, "session ended"), time.Now().Add(time.Second))
+				return
+			}
+			conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			if err := conn.WriteJSON(WebSocketMessage{
+				Type:      "caption",
+				Version:   PROTOCOL_VERSION,
+				Data:      caption,
+				Timestamp: caption.Timestamp,
+			}); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,171 @@
+// handlers/capture_handler.go
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+	"go.uber.org/zap"
+)
+
+// Reasons of a capture_request message.
+const (
+	CAPTURE_REASON_SCHEDULED = "scheduled"
+	CAPTURE_REASON_ON_DEMAND = "on_demand"
+	// A scene question found no recent frame to answer from
+	CAPTURE_REASON_SCENE_QUESTION = "scene_question"
+	// The scheduled_capture job's fixed-interval frame
+	CAPTURE_REASON_PERIODIC = "periodic"
+)
+
+// maxCaptureWait caps the wait of POST /robot/sessions/{id}/capture.
+const maxCaptureWait = 2 * time.Minute
+
+// applyCaptureConfig switches server-driven capture from a config payload
+// ({"capture_requests":true}).
+func (rs *RoboSession) applyCaptureConfig(configData map[string]interface{}) (string, error) {
+	value, exists := configData["capture_requests"]
+	if !exists {
+		return "", nil
+	}
+	enabled, ok := value.(bool)
+	if !ok {
+		return "data.capture_requests", fmt.Errorf("must be a boolean")
+	}
+	if enabled && !rs.hasModality(MODALITY_VIDEO) {
+		return "data.capture_requests", fmt.Errorf("video modality is not enabled for this session")
+	}
+	rs.setCaptureRequests(enabled)
+	return "", nil
+}
+
+func (rs *RoboSession) captureRequestsEnabled() bool {
+	rs.stateMu.Lock()
+	defer rs.stateMu.Unlock()
+	return rs.stopCaptureRequests != nil
+}
+
+// setCaptureRequests starts or stops the capture_request ticker.
+func (rs *RoboSession) setCaptureRequests(enabled bool) {
+	rs.stateMu.Lock()
+	defer rs.stateMu.Unlock()
+	if enabled == (rs.stopCaptureRequests != nil) {
+		return
+	}
+	if !enabled {
+		rs.stopCaptureRequests()
+		rs.stopCaptureRequests = nil
+		rs.Logger.Info("Stopped capture requests")
+		return
+	}
+	ctx, cancel := context.WithCancel(rs.sessionCtx)
+	rs.stopCaptureRequests = cancel
+	rs.Logger.Info("Started capture requests", zap.Duration("frequency", rs.VideoFrequency()))
+	rs.Supervisor.Go("capture_requests", func() { rs.runCaptureRequests(ctx) })
+}
+
+// runCaptureRequests asks the robot for a frame at the effective video
+// frequency. While an RTSP source is set the server pulls frames itself and
+// skips requests.
+func (rs *RoboSession) runCaptureRequests(ctx context.Context) {
+	for {
+		// Re-read the frequency every cycle so config updates and
+		// adaptation apply immediately
+		frequency := rs.AdaptiveFrequency.Interval()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-rs.Clock.After(frequency):
+		case <-rs.AdaptiveFrequency.Resumed():
+		}
+		if rs.currentIngester() == nil {
+			rs.requestCapture(CAPTURE_REASON_SCHEDULED)
+		}
+	}
+}
+
+// requestCapture sends a capture_request and returns its ID. The robot
+// answers with a video_data frame.
+func (rs *RoboSession) requestCapture(reason string) string {
+	requestID := rs.IDs.NewID()
+	if reason == CAPTURE_REASON_ON_DEMAND || reason == CAPTURE_REASON_PERIODIC {
+		rs.AdaptiveFrequency.ExpectFrame()
+	}
+	rs.Logger.Debug("Requesting frame capture", zap.String("request_id", requestID), zap.String("reason", reason))
+	rs.sendWebSocketMessage("capture_request", CaptureRequestPayload{RequestID: requestID, Reason: reason})
+	return requestID
+}
+
+// HandleSessionCapture asks a live session's robot for a frame, optionally
+// waiting for its analysis, at most maxCaptureWait:
+// POST /robot/sessions/{id}/capture[?wait=30s]
+func HandleSessionCapture(w http.ResponseWriter, r *http.Request, tenants *utils.TenantStore) {
+	rs, ok := resolveTenantSession(w, r, tenants)
+	if !ok {
+		return
+	}
+	if !rs.hasModality(MODALITY_VIDEO) {
+		http.Error(w, "video modality is not enabled for this session", http.StatusConflict)
+		return
+	}
+
+	var timeout time.Duration
+	if wait := r.URL.Query().Get("wait"); wait != "" {
+		var err error
+		if timeout, err = time.ParseDuration(wait); err != nil || timeout < 0 {
+			http.Error(w, "invalid wait duration", http.StatusBadRequest)
+			return
+		}
+		timeout = min(timeout, maxCaptureWait)
+	}
+
+	// Subscribe before asking so a fast analysis is not missed
+	events, unsubscribe := rs.Events.Subscribe()
+	defer unsubscribe()
+
+	requestID := rs.requestCapture(CAPTURE_REASON_ON_DEMAND)
+	response := map[string]interface{}{"request_id": requestID}
+	if timeout > 0 {
+		analysis, err := waitForAnalysis(r.Context(), events, requestID, timeout)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusAccepted)
+			response["error"] = err.Error()
+			json.NewEncoder(w).Encode(response)
+			return
+		}
+		response["analysis"] = analysis
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// waitForAnalysis returns the video_analysis of the frame answering the
+// capture request, ignoring the analyses of other frames.
+func waitForAnalysis(ctx context.Context, events <-chan WebSocketMessage, requestID string, timeout time.Duration) (interface{}, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		select {
+		case msg, ok := <-events:
+			if !ok {
+				return nil, fmt.Errorf("session ended before the frame was analyzed")
+			}
+			if analysis, ok := msg.Data.(models.EnvironmentContext); ok && msg.Type == "video_analysis" && analysis.RequestID == requestID {
+				return analysis, nil
+			}
+		case <-timer.C:
+			return nil, fmt.Errorf("timed out waiting for the frame analysis")
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,54 @@
+// handlers/compression.go
+
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+)
+
+// incompressibleTypes carry already-compressed media (base64 JPEG frames);
+// deflating them costs CPU for almost no gain.
+var incompressibleTypes = map[string]bool{
+	"video_frame": true,
+}
+
+// CompressionSettings tune permessage-deflate. gorilla/websocket always
+// negotiates no context takeover, so every message is compressed on its own.
+type CompressionSettings struct {
+	Enabled  bool
+	Level    int
+	MinBytes int
+}
+
+// wsCompression reads WS_COMPRESSION, WS_COMPRESSION_LEVEL and
+// WS_COMPRESSION_MIN_BYTES.
+func wsCompression(cfg *utils.Config) CompressionSettings {
+	return CompressionSettings{
+		Enabled:  cfg.WSCompression,
+		Level:    cfg.WSCompressionLevel,
+		MinBytes: cfg.WSCompressionMinBytes,
+	}
+}
+
+// negotiateCompression decides whether to offer permessage-deflate to this
+// client. Clients opt out with ?compression=false, e.g. on a LAN where CPU
+// matters more than bandwidth.
+func negotiateCompression(r *http.Request, cfg *utils.Config) bool {
+	if !cfg.WSCompression {
+		return false
+	}
+	if value := r.URL.Query().Get("compression"); value != "" {
+		enabled, err := strconv.ParseBool(value)
+		return err != nil || enabled
+	}
+	return true
+}
+
+// compressMessage reports whether an outbound message of msgType and size
+// should be deflated.
+func (s CompressionSettings) compressMessage(msgType string, size int) bool {
+	return s.Enabled && !incompressibleTypes[msgType] && size >= s.MinBytes
+}
@@ -0,0 +1,192 @@
+// handlers/confirmation.go
+
+package handlers
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+	"go.uber.org/zap"
+)
+
+const (
+	CONFIRMATION_OUTCOME_CONFIRMED = "confirmed"
+	CONFIRMATION_OUTCOME_REJECTED  = "rejected"
+	CONFIRMATION_OUTCOME_CORRECTED = "corrected"
+	CONFIRMATION_OUTCOME_EXPIRED   = "expired"
+)
+
+// Leading words that answer a confirmation question. Multi-word phrases are
+// matched before single words.
+var (
+	confirmationYes = []string{"that's right", "that is right", "go ahead", "do it", "yes", "yeah", "yep", "yup", "sure", "correct", "affirmative", "ok", "okay"}
+	confirmationNo  = []string{"never mind", "not that", "no", "nope", "nah", "wrong", "cancel", "negative"}
+)
+
+// IntentionConfirmer holds at most one intention awaiting a spoken yes/no.
+// Intentions with a confidence in [MinConfidence, 0.7] are held back when
+// INTENTION_CONFIRMATION is enabled; the next utterance confirms, rejects
+// or replaces them.
+type IntentionConfirmer struct {
+	Enabled       bool
+	MinConfidence float64
+	Timeout       time.Duration
+
+	mu      sync.Mutex
+	pending *pendingConfirmation
+}
+
+type pendingConfirmation struct {
+	result     models.IntentionResult
+	transcript string
+}
+
+// NewIntentionConfirmerFromConfig reads INTENTION_CONFIRMATION,
+// INTENTION_CONFIRMATION_MIN_CONFIDENCE and INTENTION_CONFIRMATION_TIMEOUT.
+func NewIntentionConfirmerFromConfig(cfg *utils.Config) *IntentionConfirmer {
+	return &IntentionConfirmer{
+		Enabled:       cfg.IntentionConfirmation,
+		MinConfidence: cfg.IntentionConfirmationMinConfidence,
+		Timeout:       cfg.IntentionConfirmationTimeout,
+	}
+}
+
+// needsConfirmation reports whether result is plausible but not confident
+// enough to act on directly.
+func (c *IntentionConfirmer) needsConfirmation(result models.IntentionResult) bool {
+	return c.Enabled &&
+		result.HasClearIntention &&
+		result.Source == models.INTENTION_SOURCE_MODEL &&
+		result.Confidence >= c.MinConfidence &&
+		result.Confidence <= 0.7
+}
+
+// take returns and clears the pending confirmation, or nil if id is set and
+// no longer pending.
+func (c *IntentionConfirmer) take(id string) *pendingConfirmation {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	pending := c.pending
+	if pending == nil || (id != "" && pending.result.ID != id) {
+		return nil
+	}
+	c.pending = nil
+	return pending
+}
+
+// askConfirmation holds result back and asks the user to confirm it. An
+// earlier unanswered question is replaced.
+func (h *IntentionHandler) askConfirmation(transcript string, result models.IntentionResult) {
+	c := h.confirmer
+	c.mu.Lock()
+	c.pending = &pendingConfirmation{result: result, transcript: transcript}
+	c.mu.Unlock()
+
+	h.session.Logger.Info("Asking user to confirm intention",
+		zap.String("intention_id", result.ID),
+		zap.String("type", result.IntentionType),
+		zap.Float64("confidence", result.Confidence))
+	h.session.sendWebSocketMessage("intention_confirmation", IntentionConfirmationPayload{
+		IntentionID:   result.ID,
+		Question:      confirmationQuestion(result),
+		IntentionType: result.IntentionType,
+		Description:   result.Description,
+		Confidence:    result.Confidence,
+		Slots:         result.Slots,
+		ExpiresAt:     h.session.Clock.Now().Add(c.Timeout),
+	})
+
+	h.session.Supervisor.Task("confirmation_expiry", func() {
+		select {
+		case <-h.session.sessionCtx.Done():
+			return
+		case <-h.session.Clock.After(c.Timeout):
+		}
+		if c.take(result.ID) != nil {
+			h.session.Logger.Info("Intention confirmation expired", zap.String("intention_id", result.ID))
+			h.sendConfirmationResult(result.ID, CONFIRMATION_OUTCOME_EXPIRED, "")
+		}
+	})
+}
+
+// answerConfirmation interprets transcript as the answer to a pending
+// question. It returns the text still to analyze: empty when the utterance
+// was a plain yes or no, the remainder for "no, <correction>", or the whole
+// utterance when it was not an answer at all.
+func (h *IntentionHandler) answerConfirmation(transcript string) string {
+	pending := h.confirmer.take("")
+	if pending == nil {
+		return transcript
+	}
+	result := pending.result
+
+	answer, remainder := classifyConfirmation(transcript)
+	switch {
+	case answer == CONFIRMATION_OUTCOME_CONFIRMED:
+		h.session.Logger.Info("Intention confirmed", zap.String("intention_id", result.ID))
+		h.sendConfirmationResult(result.ID, CONFIRMATION_OUTCOME_CONFIRMED, transcript)
+		h.notifyOrchestrator(pending.transcript, result, true)
+		return ""
+	case answer == CONFIRMATION_OUTCOME_REJECTED && remainder == "":
+		h.session.Logger.Info("Intention rejected", zap.String("intention_id", result.ID))
+		h.sendConfirmationResult(result.ID, CONFIRMATION_OUTCOME_REJECTED, transcript)
+		return ""
+	case answer == CONFIRMATION_OUTCOME_REJECTED:
+		transcript = remainder
+	}
+
+	// Anything else replaces the held intention
+	h.session.Logger.Info("Intention corrected", zap.String("intention_id", result.ID))
+	h.sendConfirmationResult(result.ID, CONFIRMATION_OUTCOME_CORRECTED, transcript)
+	return transcript
+}
+
+func (h *IntentionHandler) sendConfirmationResult(intentionID, outcome, transcript string) {
+	h.session.sendWebSocketMessage("intention_confirmation_result", IntentionConfirmationResultPayload{
+		IntentionID: intentionID,
+		Outcome:     outcome,
+		Transcript:  transcript,
+	})
+}
+
+func confirmationQuestion(result models.IntentionResult) string {
+	description := strings.TrimRight(strings.TrimSpace(result.Description), ".!?")
+	if description == "" {
+		return fmt.Sprintf("Did you mean %s?", strings.ReplaceAll(result.IntentionType, "_", " "))
+	}
+	return fmt.Sprintf("Did you mean: %s?", description)
+}
+
+// classifyConfirmation returns CONFIRMATION_OUTCOME_CONFIRMED or
+// CONFIRMATION_OUTCOME_REJECTED when the utterance starts with an answer,
+// with the words after it, or "" when it is not an answer.
+func classifyConfirmation(transcript string) (string, string) {
+	words := strings.FieldsFunc(strings.ToLower(transcript), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\''
+	})
+	normalized := strings.Join(words, " ")
+
+	match := func(phrases []string) (string, bool) {
+		for _, phrase := range phrases {
+			if normalized == phrase {
+				return "", true
+			}
+			if strings.HasPrefix(normalized, phrase+" ") {
+				return strings.TrimPrefix(normalized, phrase+" "), true
+			}
+		}
+		return "", false
+	}
+	if _, ok := match(confirmationYes); ok {
+		return CONFIRMATION_OUTCOME_CONFIRMED, ""
+	}
+	if remainder, ok := match(confirmationNo); ok {
+		return CONFIRMATION_OUTCOME_REJECTED, remainder
+	}
+	return "", ""
+}
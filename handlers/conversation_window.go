@@ -0,0 +1,159 @@
+// handlers/conversation_window.go
+
+package handlers
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+	"go.uber.org/zap"
+)
+
+// ConversationWindow keeps what was said in a session for the intention
+// prompt within a token budget: the latest utterances verbatim and a rolling
+// summary of the ones that left the window, so hours-long sessions keep
+// their earlier context without growing the prompt. A budget of 0 disables
+// it.
+type ConversationWindow struct {
+	session *RoboSession
+
+	mu      sync.Mutex
+	summary string
+	turns   []string
+	tokens  int
+	// evicted turns wait here to be folded into the summary
+	evicted     []string
+	summarizing bool
+
+	maxTokens      int
+	summaryWords   int
+	maxEvictTokens int
+}
+
+func NewConversationWindow(session *RoboSession) *ConversationWindow {
+	maxTokens := session.Config.TranscriptWindowTokens
+	return &ConversationWindow{
+		session:      session,
+		maxTokens:    maxTokens,
+		summaryWords: session.Config.TranscriptSummaryWords,
+		// Bounds the backlog while the summarization model is unavailable
+		maxEvictTokens: 4 * maxTokens,
+	}
+}
+
+// Context returns the conversation for the prompt, oldest first: the summary
+// of earlier conversation, then the utterances in the window.
+func (w *ConversationWindow) Context() []string {
+	if w == nil || w.maxTokens <= 0 {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	conversation := make([]string, 0, len(w.turns)+1)
+	if w.summary != "" {
+		conversation = append(conversation, "Earlier: "+w.summary)
+	}
+	return append(conversation, w.turns...)
+}
+
+// Add appends an utterance, moving the oldest ones out of the window once it
+// exceeds its budget and summarizing them in the background.
+func (w *ConversationWindow) Add(utterance string) {
+	if w == nil || w.maxTokens <= 0 || utterance == "" {
+		return
+	}
+	w.mu.Lock()
+	w.turns = append(w.turns, utterance)
+	w.tokens += utils.EstimateTokens(utterance)
+	// Keep the latest utterance even when it alone exceeds the budget
+	for w.tokens > w.maxTokens && len(w.turns) > 1 {
+		w.tokens -= utils.EstimateTokens(w.turns[0])
+		w.evicted = append(w.evicted, w.turns[0])
+		w.turns = w.turns[1:]
+	}
+	start := len(w.evicted) > 0 && !w.summarizing
+	if start {
+		w.summarizing = true
+	}
+	w.mu.Unlock()
+
+	if start {
+		w.session.Supervisor.Go("transcript_summary", w.summarize)
+	}
+}
+
+// summarize folds the evicted utterances into the summary until none are
+// left. On failure they are kept for the next attempt, dropping the oldest
+// past maxEvictTokens.
+func (w *ConversationWindow) summarize() {
+	rs := w.session
+	for {
+		w.mu.Lock()
+		if len(w.evicted) == 0 || cancelled(rs.sessionCtx) {
+			w.summarizing = false
+			w.mu.Unlock()
+			return
+		}
+		previous, batch := w.summary, w.evicted
+		w.evicted = nil
+		w.mu.Unlock()
+
+		ctx, cancel := context.WithTimeout(rs.sessionCtx, 30*time.Second)
+		summary, err := rs.openAIClient().SummarizeConversation(ctx, previous, batch, w.summaryWords)
+		cancel()
+
+		w.mu.Lock()
+		if err != nil {
+			w.evicted = append(batch, w.evicted...)
+			dropped := 0
+			for w.evictedTokens() > w.maxEvictTokens && len(w.evicted) > 0 {
+				w.evicted = w.evicted[1:]
+				dropped++
+			}
+			w.summarizing = false
+			w.mu.Unlock()
+			rs.Logger.Warn("Failed to summarize earlier conversation", zap.Int("dropped_utterances", dropped), zap.Error(err))
+			rs.MetricLabels.ProviderError("openai")
+			return
+		}
+		w.summary = summary
+		w.mu.Unlock()
+		rs.Logger.Debug("Summarized earlier conversation", zap.Int("utterances", len(batch)))
+	}
+}
+
+// evictedTokens is called with w.mu held.
+func (w *ConversationWindow) evictedTokens() int {
+	tokens := 0
+	for _, utterance := range w.evicted {
+		tokens += utils.EstimateTokens(utterance)
+	}
+	return tokens
+}
+
+// state returns the window for session snapshots.
+func (w *ConversationWindow) state() (string, []string) {
+	if w == nil {
+		return "", nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	// Utterances not yet summarized are kept verbatim
+	return w.summary, append(append([]string(nil), w.evicted...), w.turns...)
+}
+
+// restore replaces the window with a snapshot's, re-applying the budget.
+func (w *ConversationWindow) restore(summary string, turns []string) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	w.summary, w.turns, w.tokens, w.evicted = summary, nil, 0, nil
+	w.mu.Unlock()
+	for _, turn := range turns {
+		w.Add(turn)
+	}
+}
@@ -0,0 +1,271 @@
+// handlers/dashboard.go
+
+package handlers
+
+import (
+	_ "embed"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// The dashboard page is static; it asks for the admin key and calls the
+// /admin API with it, so serving the page itself needs no authorization.
+//
+//go:embed dashboard/index.html
+var dashboardHTML []byte
+
+// HandleDashboard serves the admin dashboard: GET /admin
+func HandleDashboard(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Content-Security-Policy", "default-src 'self'; style-src 'self' 'unsafe-inline'; script-src 'self' 'unsafe-inline'")
+	w.Write(dashboardHTML)
+}
+
+// SessionSummary describes a live session on the dashboard.
+type SessionSummary struct {
+	ID                string            `json:"id"`
+	TenantID          string            `json:"tenant_id"`
+	StartTime         time.Time         `json:"start_time"`
+	LastActivity      time.Time         `json:"last_activity"`
+	Modalities        []string          `json:"modalities"`
+	Incognito         bool              `json:"incognito"`
+	LogLevel          string            `json:"log_level,omitempty"`
+	CurrentTranscript string            `json:"current_transcript,omitempty"`
+	Metadata          map[string]string `json:"metadata,omitempty"`
+	Usage             map[string]int64  `json:"usage"`
+}
+
+func (rs *RoboSession) summary() SessionSummary {
+	rs.stateMu.Lock()
+	usage := make(map[string]int64, len(rs.usage))
+	for counter, value := range rs.usage {
+		usage[counter] = value
+	}
+	rs.stateMu.Unlock()
+
+	modalities := make([]string, 0, len(rs.Modalities))
+	for modality, enabled := range rs.Modalities {
+		if enabled {
+			modalities = append(modalities, modality)
+		}
+	}
+	sort.Strings(modalities)
+
+	rs.stateMu.Lock()
+	lastActivity, currentTranscript := rs.lastActivity, rs.currentTranscript
+	rs.stateMu.Unlock()
+
+	summary := SessionSummary{
+		ID:           rs.ID,
+		TenantID:     rs.Tenant.ID,
+		StartTime:    rs.StartTime,
+		LastActivity: lastActivity,
+		Modalities:   modalities,
+		Incognito:    rs.Incognito,
+		Metadata:     rs.metadataCopy(),
+		Usage:        usage,
+	}
+	if level, overridden := rs.logLevel.Level(); overridden {
+		summary.LogLevel = level.String()
+	}
+	if !rs.Incognito {
+		summary.CurrentTranscript = currentTranscript
+	}
+	return summary
+}
+
+// HandleAdminSessions lists the live sessions of this instance:
+// GET /admin/sessions
+func HandleAdminSessions(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	sessions := ListSessions()
+	summaries := make([]SessionSummary, 0, len(sessions))
+	for _, rs := range sessions {
+		summaries = append(summaries, rs.summary())
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].StartTime.After(summaries[j].StartTime) })
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"instance_id": utils.InstanceID(),
+		"sessions":    summaries,
+	})
+}
+
+// HandleAdminUsage returns the usage counters of every tenant:
+// GET /admin/usage
+func HandleAdminUsage(w http.ResponseWriter, r *http.Request, tenants *utils.TenantStore) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	usage := make(map[string]map[string]string)
+	for _, tenant := range tenants.All() {
+		counters, err := tenants.Usage(r.Context(), tenant.ID)
+		if err != nil {
+			zap.L().Error("Failed to read tenant usage", zap.String("tenant_id", tenant.ID), zap.Error(err))
+			http.Error(w, "failed to read usage", http.StatusInternalServerError)
+			return
+		}
+		usage[tenant.ID] = counters
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"tenants": usage})
+}
+
+// resolveAdminSession authorizes an admin and looks up the session named by
+// the {id} path value, writing the HTTP error itself when that fails.
+func resolveAdminSession(w http.ResponseWriter, r *http.Request) (*RoboSession, bool) {
+	if !requireAdmin(w, r) {
+		return nil, false
+	}
+	rs, ok := GetSession(r.PathValue("id"))
+	if !ok {
+		http.Error(w, "session not found", http.StatusNotFound)
+		return nil, false
+	}
+	return rs, true
+}
+
+// HandleAdminSessionEvents streams any live session's event feed to the
+// dashboard: GET /admin/sessions/{id}/events[?types=a,b]
+func HandleAdminSessionEvents(w http.ResponseWriter, r *http.Request) {
+	rs, ok := resolveAdminSession(w, r)
+	if !ok {
+		return
+	}
+	streamSessionEvents(w, r, rs)
+}
+
+// HandleAdminTerminateSession ends a live session as if the robot had sent
+// stop, telling the robot why first: DELETE /admin/sessions/{id}
+func HandleAdminTerminateSession(w http.ResponseWriter, r *http.Request) {
+	rs, ok := resolveAdminSession(w, r)
+	if !ok {
+		return
+	}
+
+	rs.Logger.Info("Terminating session on admin request", zap.String("remote_addr", r.RemoteAddr))
+	rs.setEnding(SESSION_END_REASON_TERMINATED, true)
+	rs.SendToAllChannels(models.SESSION_END)
+	rs.sendWebSocketMessage("text", SessionStoppedPayload{
+		SessionID: rs.ID,
+		Message:   "Session stopped by an administrator",
+	})
+	rs.Stop()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "terminated", "session_id": rs.ID})
+}
+
+// AdminCommand is a command pushed to a live session from the dashboard.
+// "display" shows content on the robot's screen; "text_input" injects an
+// utterance as if the user had said it.
+type AdminCommand struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+// HandleAdminSessionCommand pushes a command to a live session:
+// POST /admin/sessions/{id}/commands
+func HandleAdminSessionCommand(w http.ResponseWriter, r *http.Request) {
+	rs, ok := resolveAdminSession(w, r)
+	if !ok {
+		return
+	}
+
+	var command AdminCommand
+	if err := json.NewDecoder(r.Body).Decode(&command); err != nil {
+		http.Error(w, "invalid command", http.StatusBadRequest)
+		return
+	}
+
+	response := map[string]interface{}{"type": command.Type}
+	switch command.Type {
+	case "display":
+		var content models.DisplayContent
+		if err := json.Unmarshal(command.Data, &content); err != nil || content.Layout == "" {
+			http.Error(w, "display content with a layout is required", http.StatusBadRequest)
+			return
+		}
+		response["display_id"] = rs.DisplayHandler.Show(content)
+	case "text_input":
+		var input struct {
+			Text string `json:"text"`
+		}
+		if err := json.Unmarshal(command.Data, &input); err != nil || input.Text == "" {
+			http.Error(w, "text is required", http.StatusBadRequest)
+			return
+		}
+		rs.handleTextInput(map[string]interface{}{"text": input.Text})
+	default:
+		http.Error(w, "unsupported command type", http.StatusBadRequest)
+		return
+	}
+
+	rs.Logger.Info("Pushed admin command", zap.String("type", command.Type))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// HandleAdminLogLevel reads or changes the instance-wide log level:
+// GET/PUT /admin/log-level with {"level":"debug"}
+func HandleAdminLogLevel(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	before := utils.LogLevel.Level()
+	utils.LogLevel.ServeHTTP(w, r)
+	if after := utils.LogLevel.Level(); after != before {
+		zap.L().Info("Changed log level", zap.Stringer("from", before), zap.Stringer("to", after), zap.String("remote_addr", r.RemoteAddr))
+	}
+}
+
+// HandleAdminSessionLogLevel overrides one live session's log level, e.g. to
+// debug a single robot: PUT /admin/sessions/{id}/log-level with
+// {"level":"debug"}; DELETE restores the instance-wide level.
+func HandleAdminSessionLogLevel(w http.ResponseWriter, r *http.Request) {
+	rs, ok := resolveAdminSession(w, r)
+	if !ok {
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		rs.logLevel.Set(nil)
+	} else {
+		var req struct {
+			Level string `json:"level"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid request", http.StatusBadRequest)
+			return
+		}
+		level, err := zapcore.ParseLevel(req.Level)
+		if err != nil {
+			http.Error(w, "level must be one of debug, info, warn, error", http.StatusBadRequest)
+			return
+		}
+		rs.logLevel.Set(&level)
+	}
+
+	level, overridden := rs.logLevel.Level()
+	rs.Logger.Info("Changed session log level", zap.Stringer("level", level), zap.Bool("overridden", overridden))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"session_id": rs.ID,
+		"level":      level.String(),
+		"overridden": overridden,
+	})
+}
@@ -0,0 +1,398 @@
+// handlers/data_deletion.go
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+	"github.com/pinecone-io/go-pinecone/v4/pinecone"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// maxPineconeDeleteIDs is the most IDs Pinecone deletes in one request.
+const maxPineconeDeleteIDs = 1000
+
+// errFrameStoreDisabled is reported for stored frames that can't be deleted
+// since FRAME_STORE was turned off.
+var errFrameStoreDisabled = errors.New("frames were stored but FRAME_STORE is not configured")
+
+// errUnreadableSession is reported for a session whose description is
+// sealed under a key that is not available.
+var errUnreadableSession = errors.New("session description cannot be decrypted")
+
+// HandleSessionDataDeletion deletes everything stored about an ended
+// session, for data-subject erasure requests:
+// DELETE /robot/sessions/{id}/data
+func HandleSessionDataDeletion(w http.ResponseWriter, r *http.Request, redisClient *redis.Client, tenants *utils.TenantStore) {
+	tenant, err := tenants.Resolve(r)
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	sessionID := r.PathValue("id")
+	if rs, live := GetSession(sessionID); live && rs.Tenant.ID == tenant.ID {
+		http.Error(w, "session is live, end it before deleting its data", http.StatusConflict)
+		return
+	}
+	meta, err := utils.LoadSessionMeta(r.Context(), redisClient, sessionID)
+	if err != nil || meta.TenantID != tenant.ID {
+		http.Error(w, "session not found", http.StatusNotFound)
+		return
+	}
+
+	eraser := newDataEraser(redisClient, tenant, models.DELETION_SCOPE_SESSION, sessionID)
+	eraser.run(r.Context(), []models.SessionMeta{*meta}, nil)
+	writeDeletionReport(w, eraser.report)
+}
+
+// HandleRobotDataDeletion deletes everything stored about a robot's ended
+// sessions, the preferences learned for it and its registration:
+// DELETE /robot/robots/{id}/data
+func HandleRobotDataDeletion(w http.ResponseWriter, r *http.Request, redisClient *redis.Client, tenants *utils.TenantStore) {
+	tenant, err := tenants.Resolve(r)
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	robotID := r.PathValue("id")
+	if !utils.ValidRobotID(robotID) {
+		http.Error(w, "invalid robot ID", http.StatusBadRequest)
+		return
+	}
+	sessions, unreadable, err := utils.ListTenantSessions(r.Context(), redisClient, tenant.ID, func(meta models.SessionMeta) bool {
+		return meta.Metadata[METADATA_ROBOT_ID] == robotID
+	})
+	if err != nil {
+		zap.L().Error("Failed to list robot sessions", zap.String("tenant_id", tenant.ID), zap.String("robot_id", robotID), zap.Error(err))
+		http.Error(w, "failed to list sessions", http.StatusInternalServerError)
+		return
+	}
+
+	subject := utils.PreferenceSubject("", robotID)
+	eraser := newDataEraser(redisClient, tenant, models.DELETION_SCOPE_ROBOT, robotID)
+	eraser.failUnreadable(unreadable)
+	eraser.run(r.Context(), sessions, func(preference models.Preference) bool {
+		return preference.Subject == subject
+	})
+	writeDeletionReport(w, eraser.report)
+}
+
+// HandleTenantDataDeletion deletes everything stored about the caller's
+// tenant's ended sessions, every preference and site observation not made by
+// a live one and the robots without a live session. The tenant ID must be
+// repeated as confirm:
+// DELETE /tenant/data?confirm=<tenant id>
+func HandleTenantDataDeletion(w http.ResponseWriter, r *http.Request, redisClient *redis.Client, tenants *utils.TenantStore) {
+	tenant, err := tenants.Resolve(r)
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if r.URL.Query().Get("confirm") != tenant.ID {
+		http.Error(w, "confirm must be set to the tenant ID", http.StatusBadRequest)
+		return
+	}
+
+	sessions, unreadable, err := utils.ListTenantSessions(r.Context(), redisClient, tenant.ID, func(models.SessionMeta) bool { return true })
+	if err != nil {
+		zap.L().Error("Failed to list tenant sessions", zap.String("tenant_id", tenant.ID), zap.Error(err))
+		http.Error(w, "failed to list sessions", http.StatusInternalServerError)
+		return
+	}
+
+	eraser := newDataEraser(redisClient, tenant, models.DELETION_SCOPE_TENANT, tenant.ID)
+	eraser.failUnreadable(unreadable)
+	eraser.run(r.Context(), sessions, func(models.Preference) bool { return true })
+	writeDeletionReport(w, eraser.report)
+}
+
+// writeDeletionReport answers 200 when everything was deleted, 500 with
+// the report when a step failed and the request should be retried.
+func writeDeletionReport(w http.ResponseWriter, report *models.DeletionReport) {
+	status := http.StatusOK
+	if !report.Complete {
+		status = http.StatusInternalServerError
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(report)
+}
+
+// dataEraser deletes the data of sessions from Redis, the frame store, the
+// recordings directory and the memory store, recording what it removed.
+type dataEraser struct {
+	rdb    *redis.Client
+	tenant *models.Tenant
+	report *models.DeletionReport
+	// live sessions of the tenant on this worker and their robots, left
+	// alone
+	live       map[string]bool
+	liveRobots map[string]bool
+	// deleteRecords deletes memory records by ID from a namespace, nil for
+	// tenants without a memory store
+	deleteRecords func(ctx context.Context, namespace string, ids []string) error
+}
+
+func newDataEraser(rdb *redis.Client, tenant *models.Tenant, scope, targetID string) *dataEraser {
+	live := make(map[string]bool)
+	liveRobots := make(map[string]bool)
+	for _, rs := range ListSessions() {
+		if rs.Tenant.ID == tenant.ID {
+			live[rs.ID] = true
+			if rs.RobotID != "" {
+				liveRobots[rs.RobotID] = true
+			}
+		}
+	}
+	e := &dataEraser{
+		rdb:    rdb,
+		tenant: tenant,
+		report: &models.DeletionReport{
+			Scope:    scope,
+			TargetID: targetID,
+			TenantID: tenant.ID,
+			Sessions: []string{},
+		},
+		live:       live,
+		liveRobots: liveRobots,
+	}
+	if tenant.PineconeAPIKey != "" && tenant.PineconeHost != "" {
+		e.deleteRecords = func(ctx context.Context, namespace string, ids []string) error {
+			idx, err := utils.SharedPineconeIndex(tenant.PineconeAPIKey, tenant.PineconeHost, namespace)
+			if err != nil {
+				return err
+			}
+			return utils.DeleteFromPinecone(ctx, idx, ids)
+		}
+	}
+	return e
+}
+
+// failUnreadable reports the tenant's sessions whose description could not
+// be decrypted: they may belong to the target and cannot be erased.
+func (e *dataEraser) failUnreadable(sessionIDs []string) {
+	for _, id := range sessionIDs {
+		e.fail("session_meta", fmt.Errorf("session %s: %w", id, errUnreadableSession))
+	}
+}
+
+// run deletes the sessions' data, then the preferences and site
+// observations learned in them. Preferences matching extra are deleted too,
+// and for the whole tenant every site observation, unless made in a live
+// session. Robot and tenant scope then delete their robots' registration,
+// state and usage, unless a robot is in a live session. Deletion goes on when the client
+// disconnects; a session's description is kept until all its data is gone,
+// so a failed request can be retried.
+func (e *dataEraser) run(ctx context.Context, sessions []models.SessionMeta, extra func(models.Preference) bool) {
+	ctx = context.WithoutCancel(ctx)
+	erased := make(map[string]bool)
+	for _, meta := range sessions {
+		if e.live[meta.ID] {
+			e.report.SkippedLive = append(e.report.SkippedLive, meta.ID)
+			continue
+		}
+		erased[meta.ID] = true
+		e.report.Sessions = append(e.report.Sessions, meta.ID)
+		e.eraseSession(ctx, meta.ID)
+	}
+
+	if _, err := utils.PurgeArchivedSessions(ctx, e.rdb, e.tenant.ID, erased); err != nil {
+		e.fail("archive", err)
+	}
+
+	removed, err := utils.ErasePreferences(ctx, e.rdb, e.tenant.ID, func(preference models.Preference) bool {
+		return erased[preference.SessionID] || (extra != nil && extra(preference) && !e.live[preference.SessionID])
+	})
+	e.report.Preferences += len(removed)
+	if err != nil {
+		e.fail("preferences", err)
+	}
+	e.deleteVectors(ctx, utils.PreferenceNamespace(e.tenant), removed)
+
+	tenantWide := e.report.Scope == models.DELETION_SCOPE_TENANT
+	removed, err = utils.EraseSiteObservations(ctx, e.rdb, e.tenant.ID, func(observation models.SiteObservation) bool {
+		return erased[observation.SessionID] || (tenantWide && !e.live[observation.SessionID])
+	})
+	e.report.SiteObservations += len(removed)
+	if err != nil {
+		e.fail("site_memory", err)
+	}
+	e.deleteVectors(ctx, e.tenant.PineconeNamespace, removed)
+
+	switch e.report.Scope {
+	case models.DELETION_SCOPE_ROBOT:
+		e.eraseRobots(ctx, []string{e.report.TargetID})
+	case models.DELETION_SCOPE_TENANT:
+		ids, err := utils.TenantRobotIDs(ctx, e.rdb, e.tenant.ID)
+		if err != nil {
+			e.fail("robots", err)
+		}
+		e.eraseRobots(ctx, ids)
+	}
+
+	e.report.Complete = len(e.report.Errors) == 0
+	e.report.DeletedAt = time.Now()
+	zap.L().Info("Deleted data",
+		zap.String("scope", e.report.Scope),
+		zap.String("target_id", e.report.TargetID),
+		zap.String("tenant_id", e.tenant.ID),
+		zap.Int("sessions", len(e.report.Sessions)),
+		zap.Int("skipped_live", len(e.report.SkippedLive)),
+		zap.Bool("complete", e.report.Complete))
+}
+
+// eraseSession deletes the frames, recordings and memory records of a
+// session, then what Redis keeps of it.
+func (e *dataEraser) eraseSession(ctx context.Context, sessionID string) {
+	failed := len(e.report.Errors)
+	records, err := utils.LoadSessionAnalyses(ctx, e.rdb, sessionID)
+	if err != nil {
+		e.fail("archive", err)
+		return
+	}
+
+	vectorIDs := []string{sessionID + "-world-state"}
+	for _, record := range records {
+		switch record.Kind {
+		case models.ANALYSIS_KIND_INTENTION:
+			vectorIDs = append(vectorIDs, record.ID+intentionRecordSuffix)
+		case models.ANALYSIS_KIND_VISION:
+			vectorIDs = append(vectorIDs, record.ID+"-env")
+			e.deleteFrame(ctx, record)
+		}
+	}
+
+	deleted, err := utils.DeleteSessionRecordings(ctx, e.rdb, recordingDir(), e.tenant.ID, sessionID)
+	e.report.Recordings += deleted
+	if err != nil {
+		e.fail("recordings", err)
+	}
+
+	if e.deleteVectors(ctx, e.tenant.PineconeNamespace, vectorIDs) {
+		// Catches records whose archive entry already expired. Serverless
+		// indexes can't delete by filter, which leaves them to the IDs.
+		e.withIndex(e.tenant.PineconeNamespace, func(idx *pinecone.IndexConnection) error {
+			if err := utils.DeleteFromPineconeByFilter(ctx, idx, map[string]interface{}{"session_id": map[string]interface{}{"$eq": sessionID}}); err != nil {
+				zap.L().Info("Memory store did not delete by filter, deleted by ID", zap.String("session_id", sessionID), zap.Error(err))
+			}
+			return nil
+		})
+	}
+
+	if err := utils.EraseSessionArchive(ctx, e.rdb, e.tenant.ID, sessionID, records, e.report); err != nil {
+		e.fail("redis", err)
+	}
+	if len(e.report.Errors) > failed {
+		return
+	}
+	if err := utils.DeleteSessionMeta(ctx, e.rdb, sessionID); err != nil {
+		e.fail("redis", err)
+		return
+	}
+	e.report.RedisKeys++
+}
+
+// eraseRobots deletes the robots' registration, state and usage, leaving
+// those in a live session.
+func (e *dataEraser) eraseRobots(ctx context.Context, robotIDs []string) {
+	for _, id := range robotIDs {
+		if e.liveRobots[id] {
+			continue
+		}
+		existed, err := utils.DeleteRobot(ctx, e.rdb, e.tenant.ID, id)
+		if err != nil {
+			e.fail("robots", err)
+			continue
+		}
+		if existed {
+			e.report.Robots++
+		}
+	}
+}
+
+// deleteFrame deletes the stored frame an environment context was
+// described from, if any.
+func (e *dataEraser) deleteFrame(ctx context.Context, record models.AnalysisRecord) {
+	var envContext models.EnvironmentContext
+	if json.Unmarshal(record.Result, &envContext) != nil || envContext.ImageURI == "" {
+		return
+	}
+	config := frameStore()
+	if config == nil {
+		e.fail("frames", errFrameStoreDisabled)
+		return
+	}
+	if err := config.Store.Delete(ctx, e.tenant.ID, record.ID); err != nil {
+		e.fail("frames", err)
+		return
+	}
+	e.report.Frames++
+}
+
+// deleteVectors deletes memory records by ID from a namespace of the
+// tenant's index and reports whether it could.
+func (e *dataEraser) deleteVectors(ctx context.Context, namespace string, ids []string) bool {
+	if len(ids) == 0 {
+		return true
+	}
+	if e.deleteRecords == nil {
+		return false
+	}
+	for batch := range slices.Chunk(ids, maxPineconeDeleteIDs) {
+		if err := e.deleteRecords(ctx, namespace, batch); err != nil {
+			e.fail("memory", err)
+			return false
+		}
+		e.report.MemoryVectors += len(batch)
+	}
+	return true
+}
+
+// withIndex calls fn with a connection to a namespace of the tenant's
+// index. Tenants without Pinecone have nothing to delete.
+func (e *dataEraser) withIndex(namespace string, fn func(idx *pinecone.IndexConnection) error) bool {
+	if e.tenant.PineconeAPIKey == "" || e.tenant.PineconeHost == "" {
+		return false
+	}
+	idx, err := utils.SharedPineconeIndex(e.tenant.PineconeAPIKey, e.tenant.PineconeHost, namespace)
+	if err == nil {
+		err = fn(idx)
+	}
+	if err != nil {
+		e.fail("memory", err)
+		return false
+	}
+	return true
+}
+
+func (e *dataEraser) fail(step string, err error) {
+	zap.L().Error("Data deletion step failed",
+		zap.String("scope", e.report.Scope),
+		zap.String("target_id", e.report.TargetID),
+		zap.String("step", step),
+		zap.Error(err))
+	e.report.Errors = append(e.report.Errors, step+": "+err.Error())
+}
+
+// recordingDir is where recordings are kept, also when recording has since
+// been turned off.
+func recordingDir() string {
+	if config := recordingConfig(); config != nil {
+		return config.Dir
+	}
+	if dir := utils.CurrentConfig().RecordingDir; dir != "" {
+		return dir
+	}
+	return "recordings"
+}
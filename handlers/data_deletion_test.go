@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestRobotDataDeletionErasesEverything(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer rdb.Close()
+
+	now := time.Now()
+	meta := models.SessionMeta{ID: "s1", TenantID: "acme", StartTime: now.Add(-time.Minute), EndTime: now, Metadata: map[string]string{METADATA_ROBOT_ID: "r1"}}
+	if err := utils.SaveSessionMeta(ctx, rdb, meta); err != nil {
+		t.Fatalf("SaveSessionMeta() error = %v", err)
+	}
+	for _, record := range []models.AnalysisRecord{
+		{ID: "i1", SessionID: "s1", TenantID: "acme", Kind: models.ANALYSIS_KIND_INTENTION, Transcript: "bring me the mug", Result: json.RawMessage(`{}`), Timestamp: now},
+		{ID: "v1", SessionID: "s1", TenantID: "acme", Kind: models.ANALYSIS_KIND_VISION, Result: json.RawMessage(`{"overview":"a kitchen"}`), Timestamp: now},
+	} {
+		if err := utils.ArchiveAnalysis(ctx, rdb, record); err != nil {
+			t.Fatalf("ArchiveAnalysis() error = %v", err)
+		}
+	}
+	if err := utils.SaveWorldState(ctx, rdb, "acme", "s1", "a mug on the counter"); err != nil {
+		t.Fatalf("SaveWorldState() error = %v", err)
+	}
+	if err := utils.SaveSessionSummary(ctx, rdb, models.SessionSummary{SessionID: "s1", TenantID: "acme", RobotID: "r1", EndTime: now}); err != nil {
+		t.Fatalf("SaveSessionSummary() error = %v", err)
+	}
+	if _, _, err := utils.RegisterRobot(ctx, rdb, "acme", models.RobotRegistration{ID: "r1", Site: "kitchen"}, now); err != nil {
+		t.Fatalf("RegisterRobot() error = %v", err)
+	}
+	if err := utils.IncrementRobotUsage(ctx, rdb, "acme", "r1", "sessions", 1); err != nil {
+		t.Fatalf("IncrementRobotUsage() error = %v", err)
+	}
+
+	eraser := newDataEraser(rdb, &models.Tenant{ID: "acme"}, models.DELETION_SCOPE_ROBOT, "r1")
+	var vectors []string
+	eraser.deleteRecords = func(ctx context.Context, namespace string, ids []string) error {
+		vectors = append(vectors, ids...)
+		return nil
+	}
+	sessions, unreadable, err := utils.ListTenantSessions(ctx, rdb, "acme", func(meta models.SessionMeta) bool {
+		return meta.Metadata[METADATA_ROBOT_ID] == "r1"
+	})
+	if err != nil {
+		t.Fatalf("ListTenantSessions() error = %v", err)
+	}
+	eraser.failUnreadable(unreadable)
+	eraser.run(ctx, sessions, nil)
+
+	report := eraser.report
+	if !report.Complete || !slices.Equal(report.Sessions, []string{"s1"}) {
+		t.Fatalf("report = %+v, want s1 erased completely", report)
+	}
+	if report.Transcripts != 1 || report.EnvironmentContexts != 1 || report.Summaries != 1 || report.Robots != 1 {
+		t.Errorf("report = %+v, want one transcript, context, summary and robot", report)
+	}
+	for _, id := range []string{"s1-world-state", "i1" + intentionRecordSuffix, "v1-env"} {
+		if !slices.Contains(vectors, id) {
+			t.Errorf("deleted memory records = %v, want %s among them", vectors, id)
+		}
+	}
+	if keys := server.Keys(); len(keys) != 0 {
+		t.Errorf("Redis keys left = %v, want none", keys)
+	}
+}
@@ -0,0 +1,139 @@
+// handlers/debug_intentions.go
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+	"go.uber.org/zap"
+)
+
+// DebugIntentionRequest is the body of POST /debug/intentions. With an
+// intention_type the intention is built from the fields as given; with only
+// a transcript it is resolved like an utterance, by the command grammar or
+// the intention model.
+type DebugIntentionRequest struct {
+	SessionID          string                 `json:"session_id"`
+	Transcript         string                 `json:"transcript,omitempty"`
+	IntentionType      string                 `json:"intention_type,omitempty"`
+	Description        string                 `json:"description,omitempty"`
+	Confidence         *float64               `json:"confidence,omitempty"`
+	Slots              map[string]interface{} `json:"slots,omitempty"`
+	EnvironmentContext string                 `json:"environment_context,omitempty"`
+	Confirmed          bool                   `json:"confirmed,omitempty"`
+}
+
+// simulateIntention builds the intention a debug request describes. The
+// result has no clear intention when the transcript didn't resolve to one.
+func (h *IntentionHandler) simulateIntention(ctx context.Context, req DebugIntentionRequest) (models.IntentionResult, error) {
+	result := models.IntentionResult{
+		ID:                 h.session.IDs.NewID(),
+		HasClearIntention:  true,
+		IntentionType:      req.IntentionType,
+		Description:        req.Description,
+		Confidence:         1,
+		EnvironmentContext: req.EnvironmentContext,
+		Slots:              req.Slots,
+		Source:             models.INTENTION_SOURCE_SIMULATED,
+		Timestamp:          h.session.Clock.Now(),
+	}
+	if req.Confidence != nil {
+		result.Confidence = *req.Confidence
+	}
+	if req.IntentionType != "" {
+		return result, nil
+	}
+
+	if rule, slots, ok := h.grammar.Match(req.Transcript); ok {
+		result.IntentionType, result.Description, result.Slots = rule.IntentionType, rule.Description, slots
+		return result, nil
+	}
+
+	environmentContext := h.session.EnvironmentCache.Recent(5)
+	if req.EnvironmentContext != "" {
+		environmentContext = []string{req.EnvironmentContext}
+	}
+	intention, err := h.openaiClient.AnalyzeTranscriptForIntention(ctx, req.Transcript, environmentContext, nil, nil)
+	if err != nil {
+		return result, err
+	}
+	result.HasClearIntention = intention.HasClearIntention
+	result.IntentionType = intention.IntentionType
+	result.Description = intention.Description
+	result.Slots = intention.Slots
+	result.EnvironmentContext = strings.Join(environmentContext, "\n")
+	if req.Confidence == nil {
+		result.Confidence = intention.Confidence
+	}
+	return result, nil
+}
+
+// HandleDebugIntention fabricates an intention for a live session and sends
+// it through the orchestrator path like a spoken one, so orchestrator
+// integrations can be tested without a robot: POST /debug/intentions.
+// Simulated intentions skip deduplication and confirmation, and are not
+// stored in the session's intention history. Disabled unless
+// DEBUG_ENDPOINTS_ENABLED is set.
+func HandleDebugIntention(w http.ResponseWriter, r *http.Request, tenants *utils.TenantStore) {
+	if !utils.CurrentConfig().DebugEndpointsEnabled {
+		http.Error(w, "debug endpoints disabled", http.StatusNotFound)
+		return
+	}
+	tenant, err := tenants.Resolve(r)
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req DebugIntentionRequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		http.Error(w, "invalid intention", http.StatusBadRequest)
+		return
+	}
+	if req.IntentionType == "" && strings.TrimSpace(req.Transcript) == "" {
+		http.Error(w, "intention_type or transcript is required", http.StatusBadRequest)
+		return
+	}
+	if req.Confidence != nil && (*req.Confidence < 0 || *req.Confidence > 1) {
+		http.Error(w, "confidence must be between 0 and 1", http.StatusBadRequest)
+		return
+	}
+
+	rs, ok := GetSession(req.SessionID)
+	if !ok || rs.Tenant.ID != tenant.ID {
+		http.Error(w, "session not found", http.StatusNotFound)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+	h := rs.IntentionHandler
+	result, err := h.simulateIntention(ctx, req)
+	if err != nil {
+		rs.Logger.Warn("Failed to analyze simulated transcript", zap.Error(err))
+		http.Error(w, "intention analysis failed: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if !result.HasClearIntention {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(map[string]interface{}{"intention": result, "error": "no clear intention in the transcript"})
+		return
+	}
+
+	rs.Logger.Info("Simulating intention",
+		zap.String("intention_id", result.ID),
+		zap.String("type", result.IntentionType),
+		zap.Bool("confirmed", req.Confirmed))
+	h.notifyOrchestrator(req.Transcript, result, req.Confirmed)
+	json.NewEncoder(w).Encode(map[string]interface{}{"intention": result})
+}
@@ -0,0 +1,77 @@
+// handlers/depth_handler.go
+
+package handlers
+
+import (
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+	"go.uber.org/zap"
+)
+
+// depthSample is the latest depth map of a session. Robots send depth_data
+// just before the video_data frame it is registered to; the frame analysis
+// picks it up if it is no older than DEPTH_MAX_SKEW.
+type depthSample struct {
+	frame      *utils.DepthFrame
+	stats      models.DepthStats
+	receivedAt time.Time
+}
+
+// handleDepthData decodes a depth map and keeps it for the next frame.
+func (rs *RoboSession) handleDepthData(data interface{}) {
+	payload, _ := data.(map[string]interface{})
+	encoded, _ := payload["data"].(string)
+	encoding, _ := payload["encoding"].(string)
+	width, _ := payload["width"].(float64)
+	height, _ := payload["height"].(float64)
+	scale, _ := payload["scale"].(float64)
+
+	frame, err := utils.DecodeDepth(encoded, encoding, int(width), int(height), scale)
+	if err != nil {
+		rs.Logger.Warn("Failed to decode depth map", zap.String("encoding", encoding), zap.Error(err))
+		rs.sendError(ERROR_CODE_DEPTH_DECODE, "depth_data", err.Error())
+		return
+	}
+	stats := frame.Stats(utils.DepthSettingsFromConfig(rs.Config))
+	rs.recordUsage(models.USAGE_DEPTH_FRAMES, 1)
+
+	rs.stateMu.Lock()
+	rs.depth = &depthSample{frame: frame, stats: stats, receivedAt: rs.Clock.Now()}
+	rs.stateMu.Unlock()
+
+	rs.Logger.Debug("Depth map received",
+		zap.Int("width", frame.Width), zap.Int("height", frame.Height),
+		zap.Float64("nearest_obstacle_m", stats.NearestObstacle), zap.Float64("free_space", stats.FreeSpace))
+}
+
+// depthForFrame returns the depth map paired with a frame received now, nil
+// when there is none recent enough.
+func (rs *RoboSession) depthForFrame() *depthSample {
+	rs.stateMu.Lock()
+	defer rs.stateMu.Unlock()
+	if rs.depth == nil || rs.Clock.Since(rs.depth.receivedAt) > rs.Config.DepthMaxSkew {
+		return nil
+	}
+	return rs.depth
+}
+
+// depthImage renders the depth map for the vision model when DEPTH_VISION is
+// enabled, cropped like the frame so both show the same view.
+func (rs *RoboSession) depthImage(sample *depthSample) string {
+	if sample == nil || !rs.Config.DepthVision {
+		return ""
+	}
+	rendered, err := sample.frame.Render(utils.DepthSettingsFromConfig(rs.Config))
+	if err != nil {
+		rs.Logger.Warn("Failed to render depth map", zap.Error(err))
+		return ""
+	}
+	cropped, _, err := visionPreprocessor().Process(rendered, rs.visionROI())
+	if err != nil {
+		rs.Logger.Warn("Failed to preprocess depth rendering", zap.Error(err))
+		return rendered
+	}
+	return cropped
+}
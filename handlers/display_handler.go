@@ -0,0 +1,148 @@
+// handlers/display_handler.go
+
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// DisplayHandler pushes UI content to the robot screen and tracks acks.
+type DisplayHandler struct {
+	session *RoboSession
+
+	mu      sync.Mutex
+	pending map[string]chan models.DisplayAck
+}
+
+func InitDisplayHandler(session *RoboSession) *DisplayHandler {
+	return &DisplayHandler{
+		session: session,
+		pending: make(map[string]chan models.DisplayAck),
+	}
+}
+
+// Show sends content to the robot and returns the display ID.
+func (h *DisplayHandler) Show(content models.DisplayContent) string {
+	if content.ID == "" {
+		content.ID = uuid.New().String()
+	}
+
+	h.session.Logger.Info("Sending display content",
+		zap.String("display_id", content.ID), zap.String("layout", content.Layout))
+	h.session.sendWebSocketMessage("display", content)
+	return content.ID
+}
+
+// ShowAndWait sends content to the robot and blocks until the robot
+// acknowledges it, the timeout passes or the session ends. Only awaited
+// displays are tracked, and only while they are awaited.
+func (h *DisplayHandler) ShowAndWait(content models.DisplayContent, timeout time.Duration) (string, models.DisplayAck, error) {
+	if content.ID == "" {
+		content.ID = uuid.New().String()
+	}
+	ch := make(chan models.DisplayAck, 1)
+	h.mu.Lock()
+	h.pending[content.ID] = ch
+	h.mu.Unlock()
+	defer func() {
+		h.mu.Lock()
+		if h.pending[content.ID] == ch {
+			delete(h.pending, content.ID)
+		}
+		h.mu.Unlock()
+	}()
+
+	h.Show(content)
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case ack := <-ch:
+		return content.ID, ack, nil
+	case <-timer.C:
+		return content.ID, models.DisplayAck{}, fmt.Errorf("timed out waiting for display ack")
+	case <-h.session.sessionCtx.Done():
+		return content.ID, models.DisplayAck{}, fmt.Errorf("session ended before the display was acknowledged")
+	}
+}
+
+func (h *DisplayHandler) handleAck(data interface{}) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		h.session.Logger.Warn("Invalid display_ack payload", zap.Error(err))
+		return
+	}
+	var ack models.DisplayAck
+	if err := json.Unmarshal(raw, &ack); err != nil || ack.DisplayID == "" {
+		h.session.Logger.Warn("Invalid display_ack payload", zap.Any("data", data))
+		return
+	}
+
+	h.mu.Lock()
+	ch, ok := h.pending[ack.DisplayID]
+	delete(h.pending, ack.DisplayID)
+	h.mu.Unlock()
+	if !ok {
+		h.session.Logger.Debug("display_ack for unknown display", zap.String("display_id", ack.DisplayID))
+		return
+	}
+
+	h.session.Logger.Info("Display acknowledged",
+		zap.String("display_id", ack.DisplayID),
+		zap.String("status", ack.Status),
+		zap.String("selected_option", ack.SelectedOption))
+	ch <- ack
+}
+
+// HandleSessionDisplay lets the orchestrator or server push display content to
+// a live session: POST /robot/sessions/{id}/display[?wait=5s]
+func HandleSessionDisplay(w http.ResponseWriter, r *http.Request, tenants *utils.TenantStore) {
+	rs, ok := resolveTenantSession(w, r, tenants)
+	if !ok {
+		return
+	}
+
+	var content models.DisplayContent
+	if err := json.NewDecoder(r.Body).Decode(&content); err != nil {
+		http.Error(w, "invalid display content", http.StatusBadRequest)
+		return
+	}
+	if content.Layout == "" {
+		http.Error(w, "layout is required", http.StatusBadRequest)
+		return
+	}
+
+	wait := r.URL.Query().Get("wait")
+	if wait == "" {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"display_id": rs.DisplayHandler.Show(content)})
+		return
+	}
+	timeout, err := time.ParseDuration(wait)
+	if err != nil {
+		http.Error(w, "invalid wait duration", http.StatusBadRequest)
+		return
+	}
+
+	displayID, ack, err := rs.DisplayHandler.ShowAndWait(content, timeout)
+	response := map[string]interface{}{"display_id": displayID}
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		response["error"] = err.Error()
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+	response["ack"] = ack
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,77 @@
+// handlers/echo_probe.go
+
+package handlers
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// EchoProbeResult breaks down where time is spent between the client and the
+// server pipeline. All timestamps are Unix milliseconds; NetworkMs compares the
+// client clock with the server clock and is only meaningful when they are in
+// sync, the client can always compute the round trip from ClientSentAt.
+// QueueMs runs from reading the frame to dispatching it (decoding and
+// validation), HandlerMs from dispatch to the outbound queue, OutboundMs is
+// the wait in the outbound queue and ServerMs the whole time on the server.
+type EchoProbeResult struct {
+	ProbeID            string  `json:"probe_id"`
+	ClientSentAt       int64   `json:"client_sent_at,omitempty"`
+	ServerReceivedAt   int64   `json:"server_received_at"`
+	ServerDispatchedAt int64   `json:"server_dispatched_at"`
+	ServerSentAt       int64   `json:"server_sent_at"`
+	NetworkMs          float64 `json:"network_ms,omitempty"`
+	QueueMs            float64 `json:"queue_ms"`
+	HandlerMs          float64 `json:"handler_ms"`
+	OutboundMs         float64 `json:"outbound_ms"`
+	ServerMs           float64 `json:"server_ms"`
+
+	// Current depth of the pipeline channels
+	TranscriptionQueue int `json:"transcription_queue"`
+	VideoQueue         int `json:"video_queue"`
+
+	receivedAt time.Time
+	queuedAt   time.Time
+}
+
+// stampSent completes the result when the outbound writer is about to send
+// it.
+func (r *EchoProbeResult) stampSent(at time.Time) {
+	r.ServerSentAt = at.UnixMilli()
+	r.OutboundMs = millis(at.Sub(r.queuedAt))
+	r.ServerMs = millis(at.Sub(r.receivedAt))
+}
+
+// handleEchoProbe answers an echo_probe. receivedAt is taken as soon as the
+// frame was read off the socket, before decoding and validation; the egress
+// time is stamped by the outbound writer.
+func (rs *RoboSession) handleEchoProbe(data interface{}, receivedAt, dispatchedAt time.Time) {
+	payload, _ := data.(map[string]interface{})
+	probeID, _ := payload["probe_id"].(string)
+	clientSentAt, _ := payload["client_sent_at"].(float64)
+
+	result := &EchoProbeResult{
+		ProbeID:            probeID,
+		ClientSentAt:       int64(clientSentAt),
+		ServerReceivedAt:   receivedAt.UnixMilli(),
+		ServerDispatchedAt: dispatchedAt.UnixMilli(),
+		QueueMs:            millis(dispatchedAt.Sub(receivedAt)),
+		TranscriptionQueue: len(rs.TranscriptionCh),
+		VideoQueue:         len(rs.VideoAnalysisCh),
+		receivedAt:         receivedAt,
+	}
+	if clientSentAt > 0 {
+		result.NetworkMs = float64(receivedAt.UnixMilli()) - clientSentAt
+	}
+
+	result.queuedAt = rs.Clock.Now()
+	result.HandlerMs = millis(result.queuedAt.Sub(dispatchedAt))
+
+	rs.Logger.Debug("Answering echo probe", zap.String("probe_id", probeID), zap.Float64("handler_ms", result.HandlerMs))
+	rs.sendWebSocketMessage("echo_probe_result", result)
+}
+
+func millis(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
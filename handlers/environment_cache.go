@@ -0,0 +1,119 @@
+// handlers/environment_cache.go
+
+package handlers
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
+)
+
+// EnvironmentCache is a ring buffer of the session's latest environment
+// contexts. Intention analysis falls back to it when Pinecone is unavailable
+// or slow.
+type EnvironmentCache struct {
+	mu      sync.RWMutex
+	entries []models.EnvironmentContext
+	next    int
+	full    bool
+}
+
+func NewEnvironmentCache(size int) *EnvironmentCache {
+	if size < 1 {
+		size = 1
+	}
+	return &EnvironmentCache{entries: make([]models.EnvironmentContext, size)}
+}
+
+func (c *EnvironmentCache) Add(envContext models.EnvironmentContext) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[c.next] = envContext
+	c.next = (c.next + 1) % len(c.entries)
+	if c.next == 0 {
+		c.full = true
+	}
+}
+
+// Latest returns the most recent context, if any.
+func (c *EnvironmentCache) Latest() (models.EnvironmentContext, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.next == 0 && !c.full {
+		return models.EnvironmentContext{}, false
+	}
+	return c.entries[(c.next-1+len(c.entries))%len(c.entries)], true
+}
+
+// Recent returns up to limit contexts formatted for the intention prompt,
+// most recent first.
+func (c *EnvironmentCache) Recent(limit int) []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	count := c.next
+	if c.full {
+		count = len(c.entries)
+	}
+	if limit > count {
+		limit = count
+	}
+
+	texts := make([]string, 0, limit)
+	for i := 1; i <= limit; i++ {
+		envContext := c.entries[(c.next-i+len(c.entries))%len(c.entries)]
+		texts = append(texts, formatEnvironmentContext(envContext))
+	}
+	return texts
+}
+
+// KeyElements returns up to limit distinct key elements of the cached
+// contexts, most recently seen first.
+func (c *EnvironmentCache) KeyElements(limit int) []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	count := c.next
+	if c.full {
+		count = len(c.entries)
+	}
+
+	var elements []string
+	seen := make(map[string]bool)
+	for i := 1; i <= count && len(elements) < limit; i++ {
+		for _, element := range c.entries[(c.next-i+len(c.entries))%len(c.entries)].KeyElements {
+			key := strings.ToLower(strings.TrimSpace(element))
+			if key == "" || seen[key] {
+				continue
+			}
+			seen[key] = true
+			elements = append(elements, strings.TrimSpace(element))
+			if len(elements) == limit {
+				break
+			}
+		}
+	}
+	return elements
+}
+
+func formatEnvironmentContext(envContext models.EnvironmentContext) string {
+	parts := []string{fmt.Sprintf("[%s] %s", envContext.Timestamp.Format("15:04:05"), envContext.Overview)}
+	if len(envContext.KeyElements) > 0 {
+		parts = append(parts, "Key elements: "+strings.Join(envContext.KeyElements, ", "))
+	}
+	if envContext.Layout != "" {
+		parts = append(parts, "Layout: "+envContext.Layout)
+	}
+	if len(envContext.Activities) > 0 {
+		parts = append(parts, "Activities: "+strings.Join(envContext.Activities, ", "))
+	}
+	if depth := envContext.Depth; depth != nil && depth.MedianDistance > 0 {
+		parts = append(parts, fmt.Sprintf("Depth: nearest obstacle ahead %.2f m, %.0f%% of the way ahead free",
+			depth.NearestObstacle, depth.FreeSpace*100))
+	}
+	return strings.Join(parts, " ")
+}
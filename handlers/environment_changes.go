@@ -0,0 +1,230 @@
+// handlers/environment_changes.go
+
+package handlers
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+	"go.uber.org/zap"
+)
+
+const defaultChangeWatchCooldown = time.Minute
+
+// sceneItem is a key element or activity present in the scene.
+type sceneItem struct {
+	field string
+	value string
+	// missing counts the analyses in a row that didn't list it
+	missing int
+}
+
+// ChangeDetector diffs successive environment contexts and reports key
+// elements and activities that appeared or disappeared as
+// environment_change messages. An item only counts as gone once it is
+// missing from ENVIRONMENT_CHANGE_ABSENT_FOR analyses in a row, so one
+// description that leaves it out doesn't raise a pair of changes. When the
+// robot reports a new location the scene there becomes the new baseline.
+// All changes are sent while the session has environment_changes on;
+// otherwise only those matching one of the tenant's change watches with the
+// alert action, and watches with the orchestrator action notify it.
+type ChangeDetector struct {
+	session *RoboSession
+
+	mu        sync.Mutex
+	enabled   bool
+	absentFor int
+	// present is keyed by field and normalized value
+	present   map[string]*sceneItem
+	baseline  bool
+	location  string
+	contextID string
+	lastFired map[string]time.Time
+}
+
+func NewChangeDetector(session *RoboSession) *ChangeDetector {
+	return &ChangeDetector{
+		session:   session,
+		enabled:   session.Config.EnvironmentChanges,
+		absentFor: max(session.Config.EnvironmentChangeAbsentFor, 1),
+		present:   make(map[string]*sceneItem),
+		lastFired: make(map[string]time.Time),
+	}
+}
+
+func (d *ChangeDetector) Enabled() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.enabled
+}
+
+// SetEnabled switches environment_change messages for all changes. The
+// scene tracked while off may be stale, so it is seen afresh.
+func (d *ChangeDetector) SetEnabled(enabled bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if enabled && !d.enabled {
+		d.present = make(map[string]*sceneItem)
+		d.baseline = false
+	}
+	d.enabled = enabled
+}
+
+// Observe is called for each new environment context.
+func (d *ChangeDetector) Observe(envContext models.EnvironmentContext) {
+	watches := d.session.credentials().ChangeWatches
+
+	d.mu.Lock()
+	if !d.enabled && len(watches) == 0 {
+		d.mu.Unlock()
+		return
+	}
+	previousID := d.contextID
+	changes := d.diff(envContext)
+	enabled := d.enabled
+	d.mu.Unlock()
+	if len(changes) == 0 {
+		return
+	}
+
+	change := models.EnvironmentChange{
+		ChangeID:          d.session.IDs.NewID(),
+		ContextID:         envContext.ID,
+		PreviousContextID: previousID,
+		Changes:           changes,
+		Timestamp:         envContext.Timestamp,
+	}
+	alert, notify := d.match(watches, &change)
+	d.session.Logger.Info("Environment changed",
+		zap.String("change_id", change.ChangeID),
+		zap.Any("changes", changes),
+		zap.Strings("watches", change.Watches))
+
+	if enabled || alert {
+		d.session.sendWebSocketMessage("environment_change", change)
+	}
+	if notify {
+		d.session.journalAction("environment changed (%s), sent to the orchestrator", describeSceneChanges(changes))
+		d.session.notifyOrchestrator(change.ChangeID, OrchestratorEnvironmentChangePayload{
+			TriggerID:          change.ChangeID,
+			SessionID:          d.session.ID,
+			TenantID:           d.session.Tenant.ID,
+			TriggerType:        "environment_change",
+			Watches:            change.Watches,
+			Changes:            changes,
+			EnvironmentContext: envContext,
+			Timestamp:          envContext.Timestamp.Unix(),
+			Worker:             utils.Worker(),
+			Metadata:           d.session.metadataCopy(),
+		})
+	}
+}
+
+// diff updates the scene with a context and returns what changed. Called
+// with d.mu held.
+func (d *ChangeDetector) diff(envContext models.EnvironmentContext) []models.SceneChange {
+	if d.baseline && envContext.Location != d.location {
+		d.session.Logger.Debug("Robot moved, resetting the scene baseline",
+			zap.String("from", d.location), zap.String("to", envContext.Location))
+		d.present = make(map[string]*sceneItem)
+		d.baseline = false
+	}
+	d.location = envContext.Location
+	d.contextID = envContext.ID
+
+	var changes []models.SceneChange
+	seen := make(map[string]bool)
+	for _, field := range []struct {
+		name   string
+		values []string
+	}{
+		{"key_elements", envContext.KeyElements},
+		{"activities", envContext.Activities},
+	} {
+		for _, value := range field.values {
+			key := utils.SceneItemKey(value)
+			if key == "" {
+				continue
+			}
+			key = field.name + ":" + key
+			seen[key] = true
+			if item, ok := d.present[key]; ok {
+				item.missing = 0
+				item.value = value
+				continue
+			}
+			d.present[key] = &sceneItem{field: field.name, value: value}
+			if d.baseline {
+				changes = append(changes, models.SceneChange{Field: field.name, Change: models.CHANGE_APPEARED, Value: value})
+			}
+		}
+	}
+
+	for key, item := range d.present {
+		if seen[key] {
+			continue
+		}
+		item.missing++
+		if item.missing >= d.absentFor {
+			delete(d.present, key)
+			changes = append(changes, models.SceneChange{Field: item.field, Change: models.CHANGE_DISAPPEARED, Value: item.value})
+		}
+	}
+	d.baseline = true
+
+	// Map order is random; keep messages stable
+	slices.SortFunc(changes, func(a, b models.SceneChange) int {
+		return cmp.Or(cmp.Compare(a.Change, b.Change), cmp.Compare(a.Field, b.Field), cmp.Compare(a.Value, b.Value))
+	})
+	return changes
+}
+
+// match records the watches the changes match, outside their cooldown, and
+// reports whether any of them alerts or notifies the orchestrator.
+func (d *ChangeDetector) match(watches []models.ChangeWatch, change *models.EnvironmentChange) (alert, notify bool) {
+	now := d.session.Clock.Now()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, watch := range watches {
+		if !slices.ContainsFunc(change.Changes, func(c models.SceneChange) bool { return utils.ChangeWatchMatches(watch, c) }) {
+			continue
+		}
+		if last, ok := d.lastFired[watch.ID]; ok && now.Sub(last) < parseRuleDuration(watch.Cooldown, defaultChangeWatchCooldown) {
+			continue
+		}
+		d.lastFired[watch.ID] = now
+		change.Watches = append(change.Watches, watch.ID)
+		alert = alert || slices.Contains(watch.Actions, models.RULE_ACTION_ALERT)
+		notify = notify || slices.Contains(watch.Actions, models.RULE_ACTION_ORCHESTRATOR)
+	}
+	return alert, notify
+}
+
+func describeSceneChanges(changes []models.SceneChange) string {
+	parts := make([]string, len(changes))
+	for i, change := range changes {
+		parts[i] = change.Value + " " + change.Change
+	}
+	return strings.Join(parts, ", ")
+}
+
+// applyEnvironmentChangesConfig switches change messages from a config
+// payload ({"environment_changes":true}).
+func (rs *RoboSession) applyEnvironmentChangesConfig(configData map[string]interface{}) (string, error) {
+	value, exists := configData["environment_changes"]
+	if !exists {
+		return "", nil
+	}
+	enabled, ok := value.(bool)
+	if !ok {
+		return "data.environment_changes", fmt.Errorf("must be a boolean")
+	}
+	rs.Changes.SetEnabled(enabled)
+	return "", nil
+}
@@ -0,0 +1,94 @@
+// handlers/errors.go
+
+package handlers
+
+import (
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+	"go.uber.org/zap"
+)
+
+// Error codes of the "error" message. Client errors mean the robot sent
+// something the server cannot use; server errors mean a provider or the
+// server itself is unhealthy.
+const (
+	ERROR_CODE_AUDIO_DECODE        = "E_AUDIO_DECODE"
+	ERROR_CODE_VIDEO_DECODE        = "E_VIDEO_DECODE"
+	ERROR_CODE_DEPTH_DECODE        = "E_DEPTH_DECODE"
+	ERROR_CODE_STT_RECONNECTING    = "E_STT_RECONNECTING"
+	ERROR_CODE_STT_UNAVAILABLE     = "E_STT_UNAVAILABLE"
+	ERROR_CODE_FRAME_DROPPED       = "E_FRAME_DROPPED"
+	ERROR_CODE_VISION_FAILED       = "E_VISION_FAILED"
+	ERROR_CODE_INTENTION_FAILED    = "E_INTENTION_FAILED"
+	ERROR_CODE_ORCHESTRATOR_CONFIG = "E_ORCHESTRATOR_CONFIG"
+	ERROR_CODE_ORCHESTRATOR_FAILED = "E_ORCHESTRATOR_FAILED"
+	ERROR_CODE_RTSP_FAILED         = "E_RTSP_FAILED"
+	ERROR_CODE_CAMERA_FAILED       = "E_CAMERA_FAILED"
+	ERROR_CODE_SESSION_FAILED      = "E_SESSION_FAILED"
+	ERROR_CODE_NO_FRAME            = "E_NO_FRAME"
+)
+
+const (
+	ERROR_CATEGORY_CLIENT = "client"
+	ERROR_CATEGORY_SERVER = "server"
+)
+
+type errorSpec struct {
+	category  string
+	retryable bool
+}
+
+// errorCodes fixes the category of each code and whether sending the same
+// input again later may succeed.
+var errorCodes = map[string]errorSpec{
+	ERROR_CODE_AUDIO_DECODE:        {ERROR_CATEGORY_CLIENT, false},
+	ERROR_CODE_VIDEO_DECODE:        {ERROR_CATEGORY_CLIENT, false},
+	ERROR_CODE_DEPTH_DECODE:        {ERROR_CATEGORY_CLIENT, false},
+	ERROR_CODE_STT_RECONNECTING:    {ERROR_CATEGORY_SERVER, true},
+	ERROR_CODE_STT_UNAVAILABLE:     {ERROR_CATEGORY_SERVER, true},
+	ERROR_CODE_FRAME_DROPPED:       {ERROR_CATEGORY_SERVER, true},
+	ERROR_CODE_VISION_FAILED:       {ERROR_CATEGORY_SERVER, true},
+	ERROR_CODE_INTENTION_FAILED:    {ERROR_CATEGORY_SERVER, true},
+	ERROR_CODE_ORCHESTRATOR_CONFIG: {ERROR_CATEGORY_SERVER, false},
+	ERROR_CODE_ORCHESTRATOR_FAILED: {ERROR_CATEGORY_SERVER, true},
+	ERROR_CODE_RTSP_FAILED:         {ERROR_CATEGORY_CLIENT, true},
+	ERROR_CODE_CAMERA_FAILED:       {ERROR_CATEGORY_CLIENT, true},
+	ERROR_CODE_SESSION_FAILED:      {ERROR_CATEGORY_SERVER, true},
+	ERROR_CODE_NO_FRAME:            {ERROR_CATEGORY_CLIENT, true},
+}
+
+// errorRepeatInterval limits how often the same code is reported, so a
+// failing provider does not answer every audio chunk with an error.
+const errorRepeatInterval = time.Second
+
+// sendError reports a recoverable failure to the client. messageType names
+// the inbound message that failed, if any.
+func (rs *RoboSession) sendError(code, messageType, message string) {
+	rs.errorMu.Lock()
+	if rs.lastErrorAt == nil {
+		rs.lastErrorAt = make(map[string]time.Time)
+	}
+	now := rs.Clock.Now()
+	if last, ok := rs.lastErrorAt[code]; ok && now.Sub(last) < errorRepeatInterval {
+		rs.errorMu.Unlock()
+		return
+	}
+	rs.lastErrorAt[code] = now
+	rs.errorMu.Unlock()
+
+	spec, ok := errorCodes[code]
+	if !ok {
+		rs.Logger.Warn("Unregistered error code", zap.String("code", code))
+		spec = errorSpec{category: ERROR_CATEGORY_SERVER, retryable: true}
+	}
+	payload := ErrorPayload{
+		Code:        code,
+		Category:    spec.category,
+		Retryable:   spec.retryable,
+		MessageType: messageType,
+		Message:     message,
+	}
+	rs.sendWebSocketMessage("error", payload)
+	rs.sendWebhook(utils.WEBHOOK_SESSION_ERROR, payload)
+}
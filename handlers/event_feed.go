@@ -0,0 +1,171 @@
+// handlers/event_feed.go
+
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+	"go.uber.org/zap"
+)
+
+// feedEventTypes are the outbound messages mirrored to read-only dashboard
+// subscribers. Media echoes such as video_frame are left out.
+var feedEventTypes = map[string]bool{
+	"transcript_interim":            true,
+	"transcript_final":              true,
+	"stt_status":                    true,
+	"speech_activity":               true,
+	"prompt_user":                   true,
+	"power_mode":                    true,
+	"audio_event":                   true,
+	"acoustic_event":                true,
+	"intention_analysis":            true,
+	"intention_deduplicated":        true,
+	"intention_escalated":           true,
+	"intention_confirmation":        true,
+	"intention_confirmation_result": true,
+	"video_analysis":                true,
+	"video_annotations":             true,
+	"world_state":                   true,
+	"preference_learned":            true,
+	"context_stale":                 true,
+	"rule_triggered":                true,
+	"environment_change":            true,
+	"recording_saved":               true,
+	"scene_answer":                  true,
+	"error":                         true,
+}
+
+// EventFeed fans session events out to dashboard subscribers. Slow
+// subscribers miss events rather than delaying the robot connection.
+type EventFeed struct {
+	mu          sync.Mutex
+	subscribers map[chan WebSocketMessage]struct{}
+	closed      bool
+}
+
+func NewEventFeed() *EventFeed {
+	return &EventFeed{subscribers: make(map[chan WebSocketMessage]struct{})}
+}
+
+// Subscribe returns a channel of events, closed when the session ends, and a
+// function to unsubscribe.
+func (f *EventFeed) Subscribe() (<-chan WebSocketMessage, func()) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ch := make(chan WebSocketMessage, 64)
+	if f.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	f.subscribers[ch] = struct{}{}
+
+	return ch, func() {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if _, ok := f.subscribers[ch]; ok {
+			delete(f.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+func (f *EventFeed) Publish(msg WebSocketMessage) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for ch := range f.subscribers {
+		select {
+		case ch <- msg:
+		default:
+		}
+	}
+}
+
+// Close ends every subscription.
+func (f *EventFeed) Close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.closed = true
+	for ch := range f.subscribers {
+		close(ch)
+	}
+	f.subscribers = make(map[chan WebSocketMessage]struct{})
+}
+
+// HandleSessionEvents streams a live session's transcripts, intentions and
+// video analyses as Server-Sent Events. ?types=a,b limits the event types.
+func HandleSessionEvents(w http.ResponseWriter, r *http.Request, tenants *utils.TenantStore) {
+	rs, ok := resolveTenantSession(w, r, tenants)
+	if !ok {
+		return
+	}
+	streamSessionEvents(w, r, rs)
+}
+
+// streamSessionEvents writes the session's event feed to an authorized
+// caller until the client goes away or the session ends.
+func streamSessionEvents(w http.ResponseWriter, r *http.Request, rs *RoboSession) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	var only map[string]bool
+	if types := r.URL.Query().Get("types"); types != "" {
+		only = make(map[string]bool)
+		for _, t := range strings.Split(types, ",") {
+			only[strings.TrimSpace(t)] = true
+		}
+	}
+
+	events, unsubscribe := rs.Events.Subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	rs.Logger.Info("Dashboard subscribed to session events", zap.String("remote_addr", r.RemoteAddr))
+
+	keepalive := time.NewTicker(15 * time.Second)
+	defer keepalive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepalive.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+			flusher.Flush()
+		case msg, ok := <-events:
+			if !ok {
+				fmt.Fprint(w, "event: session_ended\ndata: {}\n\n")
+				flusher.Flush()
+				return
+			}
+			if only != nil && !only[msg.Type] {
+				continue
+			}
+			data, err := json.Marshal(msg)
+			if err != nil {
+				rs.Logger.Warn("Failed to encode session event", zap.String("type", msg.Type), zap.Error(err))
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", msg.Type, data)
+			flusher.Flush()
+		}
+	}
+}
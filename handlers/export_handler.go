@@ -0,0 +1,101 @@
+// handlers/export_handler.go
+
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+const maxBundleSize = 512 << 20
+
+// HandleSessionExport streams a signed bundle of a (live or ended) session:
+// GET /robot/sessions/{id}/export[?media=true]
+func HandleSessionExport(w http.ResponseWriter, r *http.Request, redisClient *redis.Client, tenants *utils.TenantStore) {
+	tenant, err := tenants.Resolve(r)
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	sessionID := r.PathValue("id")
+	meta, err := utils.LoadSessionMeta(r.Context(), redisClient, sessionID)
+	if err != nil || meta.TenantID != tenant.ID {
+		http.Error(w, "session not found", http.StatusNotFound)
+		return
+	}
+
+	records, err := utils.LoadSessionAnalyses(r.Context(), redisClient, sessionID)
+	if err != nil {
+		zap.L().Error("Failed to load session records for export", zap.String("session_id", sessionID), zap.Error(err))
+		http.Error(w, "failed to load session", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="session-%s.zip"`, sessionID))
+	if err := utils.WriteSessionBundle(w, *meta, records, r.URL.Query().Get("media") == "true"); err != nil {
+		zap.L().Error("Failed to write session bundle", zap.String("session_id", sessionID), zap.Error(err))
+	}
+}
+
+// HandleSessionImport verifies a bundle produced by HandleSessionExport on
+// another deployment and stores it under the caller's tenant, as a new
+// session so it cannot overwrite or extend an existing one:
+// POST /robot/sessions/import
+func HandleSessionImport(w http.ResponseWriter, r *http.Request, redisClient *redis.Client, tenants *utils.TenantStore) {
+	tenant, err := tenants.Resolve(r)
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBundleSize))
+	if err != nil {
+		http.Error(w, "bundle too large or unreadable", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	bundle, err := utils.ReadSessionBundle(data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sourceID := bundle.Meta.ID
+	bundle.Meta.ID = sessionIDs.NewID()
+	bundle.Meta.TenantID = tenant.ID
+	if err := utils.SaveSessionMeta(r.Context(), redisClient, bundle.Meta); err != nil {
+		zap.L().Error("Failed to import session meta", zap.Error(err))
+		http.Error(w, "failed to import session", http.StatusInternalServerError)
+		return
+	}
+	for _, record := range bundle.Records {
+		record.SessionID = bundle.Meta.ID
+		record.TenantID = tenant.ID
+		if err := utils.ArchiveAnalysis(r.Context(), redisClient, record); err != nil {
+			zap.L().Error("Failed to import session record", zap.String("record_id", record.ID), zap.Error(err))
+			http.Error(w, "failed to import session", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	zap.L().Info("Imported session bundle",
+		zap.String("session_id", bundle.Meta.ID),
+		zap.String("source_session_id", sourceID),
+		zap.String("tenant_id", tenant.ID),
+		zap.Int("records", len(bundle.Records)))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"session_id":        bundle.Meta.ID,
+		"source_session_id": sourceID,
+		"records":           len(bundle.Records),
+	})
+}
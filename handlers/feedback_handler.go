@@ -0,0 +1,126 @@
+// handlers/feedback_handler.go
+
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+var feedbackLabels = map[string]bool{
+	models.FEEDBACK_LABEL_CORRECT:   true,
+	models.FEEDBACK_LABEL_INCORRECT: true,
+	models.FEEDBACK_LABEL_EXECUTED:  true,
+}
+
+// IntentionFeedbackRequest is the body of a feedback call. IntentionType may
+// carry the corrected type when an intention is labeled incorrect.
+type IntentionFeedbackRequest struct {
+	Label         string `json:"label"`
+	IntentionType string `json:"intention_type,omitempty"`
+	Comment       string `json:"comment,omitempty"`
+	Source        string `json:"source,omitempty"`
+}
+
+// HandleIntentionFeedback labels a detected intention as correct, incorrect or
+// executed. The label is stored next to the archived transcript:
+// POST /robot/sessions/{id}/intentions/{intention_id}/feedback
+func HandleIntentionFeedback(w http.ResponseWriter, r *http.Request, redisClient *redis.Client, tenants *utils.TenantStore) {
+	tenant, err := tenants.Resolve(r)
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	sessionID := r.PathValue("id")
+	meta, err := utils.LoadSessionMeta(r.Context(), redisClient, sessionID)
+	if err != nil || meta.TenantID != tenant.ID {
+		http.Error(w, "session not found", http.StatusNotFound)
+		return
+	}
+
+	var req IntentionFeedbackRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid feedback", http.StatusBadRequest)
+		return
+	}
+	if !feedbackLabels[req.Label] {
+		http.Error(w, "label must be one of correct, incorrect, executed", http.StatusBadRequest)
+		return
+	}
+
+	intentionID := r.PathValue("intention_id")
+	record, err := utils.FindSessionAnalysis(r.Context(), redisClient, sessionID, intentionID)
+	if err != nil || record.Kind != models.ANALYSIS_KIND_INTENTION {
+		http.Error(w, "intention not found", http.StatusNotFound)
+		return
+	}
+
+	feedback := models.IntentionFeedback{
+		IntentionID:   intentionID,
+		SessionID:     sessionID,
+		TenantID:      tenant.ID,
+		Label:         req.Label,
+		IntentionType: req.IntentionType,
+		Comment:       req.Comment,
+		Source:        req.Source,
+		Timestamp:     time.Now(),
+	}
+	if err := utils.SaveIntentionFeedback(r.Context(), redisClient, feedback); err != nil {
+		zap.L().Error("Failed to save intention feedback", zap.String("intention_id", intentionID), zap.Error(err))
+		http.Error(w, "failed to save feedback", http.StatusInternalServerError)
+		return
+	}
+
+	zap.L().Info("Recorded intention feedback",
+		zap.String("session_id", sessionID),
+		zap.String("intention_id", intentionID),
+		zap.String("label", req.Label))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(feedback)
+}
+
+// HandleFeedbackExport streams the caller's labeled intentions as JSON Lines
+// for training: GET /intentions/feedback/export[?since=168h]
+func HandleFeedbackExport(w http.ResponseWriter, r *http.Request, redisClient *redis.Client, tenants *utils.TenantStore) {
+	tenant, err := tenants.Resolve(r)
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	window := 7 * 24 * time.Hour
+	if since := r.URL.Query().Get("since"); since != "" {
+		window, err = time.ParseDuration(since)
+		if err != nil {
+			http.Error(w, "invalid since duration", http.StatusBadRequest)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", `attachment; filename="intention-feedback.jsonl"`)
+	encoder := json.NewEncoder(w)
+	count := 0
+	err = utils.ExportLabeledIntentions(r.Context(), redisClient, tenant.ID, time.Now().Add(-window), func(example models.LabeledIntention) error {
+		count++
+		return encoder.Encode(example)
+	})
+	if err != nil {
+		zap.L().Error("Failed to export intention feedback", zap.String("tenant_id", tenant.ID), zap.Error(err))
+		if count == 0 {
+			http.Error(w, "failed to export feedback", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	zap.L().Info("Exported labeled intentions", zap.String("tenant_id", tenant.ID), zap.Int("examples", count))
+}
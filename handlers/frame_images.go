@@ -0,0 +1,96 @@
+// handlers/frame_images.go
+
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+	"go.uber.org/zap"
+)
+
+var (
+	frameStoreOnce   sync.Once
+	sharedFrameStore *utils.FrameStoreConfig
+)
+
+// frameStore returns the instance-wide frame store, nil when FRAME_STORE is
+// unset or misconfigured.
+func frameStore() *utils.FrameStoreConfig {
+	frameStoreOnce.Do(func() {
+		config, err := utils.FrameStoreFromConfig(utils.CurrentConfig())
+		if err != nil {
+			zap.L().Error("Frame storage disabled", zap.Error(err))
+			return
+		}
+		if config != nil {
+			zap.L().Info("Frame storage enabled", zap.String("image", config.Image))
+		}
+		sharedFrameStore = config
+	})
+	return sharedFrameStore
+}
+
+// frameImageURI returns where the frame of a context will be stored, empty
+// when frames are not stored for the session.
+func (rs *RoboSession) frameImageURI(contextID string) string {
+	config := frameStore()
+	if config == nil || rs.Incognito {
+		return ""
+	}
+	return config.Store.URI(rs.Tenant.ID, contextID)
+}
+
+// storeFrameImage persists the frame an environment context was described
+// from, the original or the image sent to the vision model.
+func (h *VideoHandler) storeFrameImage(contextID, original, analyzed string) {
+	config := frameStore()
+	image := analyzed
+	if config.Image == utils.FRAME_IMAGE_ORIGINAL {
+		image = original
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	if err := utils.StoreFrame(ctx, config.Store, h.session.Tenant.ID, contextID, image); err != nil {
+		h.session.Logger.Warn("Failed to store frame", zap.String("context_id", contextID), zap.Error(err))
+		h.session.MetricLabels.ProviderError("frame_store")
+	}
+}
+
+// HandleContextImage serves the frame an environment context was described
+// from: GET /robot/contexts/{id}/image
+func HandleContextImage(w http.ResponseWriter, r *http.Request, tenants *utils.TenantStore) {
+	tenant, err := tenants.Resolve(r)
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	config := frameStore()
+	if config == nil {
+		http.Error(w, "frame storage is not enabled", http.StatusNotFound)
+		return
+	}
+	contextID := r.PathValue("id")
+
+	image, contentType, err := utils.LoadFrame(r.Context(), config.Store, tenant.ID, contextID)
+	if errors.Is(err, utils.ErrFrameNotFound) {
+		http.Error(w, "frame not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		zap.L().Error("Failed to load frame", zap.String("tenant_id", tenant.ID), zap.String("context_id", contextID), zap.Error(err))
+		http.Error(w, "failed to load frame", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(image)))
+	w.Header().Set("Cache-Control", "private, max-age=3600")
+	w.Write(image)
+}
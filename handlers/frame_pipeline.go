@@ -0,0 +1,216 @@
+// handlers/frame_pipeline.go
+
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+	"go.uber.org/zap"
+)
+
+// Phases of the frame pipeline, in the order they run. The built-in work of
+// a phase runs before the stages registered for it:
+//   - preprocess: frame admission, quality check, crop and downscale
+//   - detect: nothing built in; custom detectors (barcodes, gauges) add
+//     findings to the frame
+//   - describe: scene description by the vision model
+//   - store: environment cache, memory store, archive and site memory
+//   - notify: video_analysis and video_annotations messages, rules and
+//     adaptive frequency
+const (
+	FRAME_PHASE_PREPROCESS = "preprocess"
+	FRAME_PHASE_DETECT     = "detect"
+	FRAME_PHASE_DESCRIBE   = "describe"
+	FRAME_PHASE_STORE      = "store"
+	FRAME_PHASE_NOTIFY     = "notify"
+)
+
+var framePhases = []string{FRAME_PHASE_PREPROCESS, FRAME_PHASE_DETECT, FRAME_PHASE_DESCRIBE, FRAME_PHASE_STORE, FRAME_PHASE_NOTIFY}
+
+// ErrSkipFrame, returned by a stage, drops the frame: later stages do not
+// run and nothing is reported to the client.
+var ErrSkipFrame = errors.New("skip frame")
+
+// FrameStage is a step of the frame pipeline. Integrators embedding the SDK
+// register their own stages with RegisterFrameStage to extend the vision
+// pipeline without changing VideoHandler. Process runs once per analyzed
+// frame, concurrently across frames and sessions. Errors other than
+// ErrSkipFrame are logged and the frame carries on.
+type FrameStage interface {
+	// Name identifies the stage in logs
+	Name() string
+	// Phase is one of the FRAME_PHASE_* constants
+	Phase() string
+	Process(ctx context.Context, frame *Frame) error
+}
+
+// Frame is a frame moving through the pipeline.
+type Frame struct {
+	SessionID string
+	TenantID  string
+	RobotID   string
+	// Metadata the session was started with (robot_id, site, ...)
+	Metadata map[string]string
+	// Image is the frame as received, a data URL or base64 JPEG
+	Image string
+	// Analyzed is the image sent to the vision model, cropped to the
+	// region of interest and downscaled by preprocess
+	Analyzed string
+	Crop     utils.CropRect
+	// Findings are facts detect stages extract from the frame, such as a
+	// barcode or a gauge reading; they are added to the context's
+	// additional info before it is stored
+	Findings map[string]string
+	// Context is the scene description, set by describe; later stages may
+	// amend it
+	Context *models.EnvironmentContext
+	// RequestID is the capture_request the frame answers, if any
+	RequestID string
+
+	depth   *depthSample
+	started time.Time
+}
+
+// AddFinding records a fact about the frame under key.
+func (f *Frame) AddFinding(key, value string) {
+	if f.Findings == nil {
+		f.Findings = make(map[string]string)
+	}
+	f.Findings[key] = value
+}
+
+var (
+	frameStagesMu sync.RWMutex
+	frameStages   []FrameStage
+)
+
+// RegisterFrameStage adds a stage to the frame pipeline of every session.
+// Stages of a phase run in registration order; register them before the
+// server starts accepting sessions.
+func RegisterFrameStage(stage FrameStage) error {
+	if !slices.Contains(framePhases, stage.Phase()) {
+		return fmt.Errorf("frame stage %s: unknown phase %q", stage.Name(), stage.Phase())
+	}
+	frameStagesMu.Lock()
+	defer frameStagesMu.Unlock()
+	frameStages = append(frameStages, stage)
+	zap.L().Info("Registered frame stage", zap.String("stage", stage.Name()), zap.String("phase", stage.Phase()))
+	return nil
+}
+
+// FrameStageFunc adapts a function to a FrameStage.
+func FrameStageFunc(name, phase string, process func(ctx context.Context, frame *Frame) error) FrameStage {
+	return frameStageFunc{name: name, phase: phase, process: process}
+}
+
+type frameStageFunc struct {
+	name, phase string
+	process     func(ctx context.Context, frame *Frame) error
+}
+
+func (s frameStageFunc) Name() string  { return s.name }
+func (s frameStageFunc) Phase() string { return s.phase }
+func (s frameStageFunc) Process(ctx context.Context, frame *Frame) error {
+	return s.process(ctx, frame)
+}
+
+// FramePipeline runs the stages of a frame, phase by phase.
+type FramePipeline struct {
+	session *RoboSession
+	stages  []pipelineStage
+}
+
+// pipelineStage marks the registered stages, whose failures do not stop
+// the frame.
+type pipelineStage struct {
+	FrameStage
+	custom bool
+}
+
+// newFramePipeline builds a session's pipeline from the built-in stages and
+// the registered ones.
+func newFramePipeline(session *RoboSession, builtin []FrameStage) *FramePipeline {
+	frameStagesMu.RLock()
+	registered := slices.Clone(frameStages)
+	frameStagesMu.RUnlock()
+
+	pipeline := &FramePipeline{session: session}
+	for _, phase := range framePhases {
+		for _, stage := range builtin {
+			if stage.Phase() == phase {
+				pipeline.stages = append(pipeline.stages, pipelineStage{FrameStage: stage})
+			}
+		}
+		for _, stage := range registered {
+			if stage.Phase() == phase {
+				pipeline.stages = append(pipeline.stages, pipelineStage{FrameStage: stage, custom: true})
+			}
+		}
+	}
+	return pipeline
+}
+
+// Run passes a frame through every stage until one skips it.
+func (p *FramePipeline) Run(ctx context.Context, imageData, requestID string) {
+	rs := p.session
+	frame := &Frame{
+		SessionID: rs.ID,
+		TenantID:  rs.Tenant.ID,
+		RobotID:   rs.RobotID,
+		Metadata:  maps.Clone(rs.Metadata),
+		Image:     imageData,
+		Analyzed:  imageData,
+		Crop:      utils.FullFrame,
+		RequestID: requestID,
+	}
+	for _, stage := range p.stages {
+		if cancelled(ctx) {
+			rs.Logger.Debug("Frame pipeline cancelled", zap.String("stage", stage.Name()))
+			return
+		}
+		if !stage.custom {
+			if err := stage.Process(ctx, frame); err != nil {
+				return
+			}
+			continue
+		}
+		err := runPluginStage(func() error { return stage.Process(ctx, frame) })
+		if errors.Is(err, ErrSkipFrame) {
+			rs.Logger.Debug("Frame skipped by stage", zap.String("stage", stage.Name()))
+			return
+		}
+		if err != nil {
+			rs.Logger.Warn("Frame stage failed", zap.String("stage", stage.Name()), zap.String("phase", stage.Phase()), zap.Error(err))
+		}
+	}
+}
+
+// runPluginStage runs a registered stage, turning a panic into an error so
+// a faulty plugin does not count against the session's restart limit.
+func runPluginStage(process func() error) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("panic: %v", recovered)
+		}
+	}()
+	return process()
+}
+
+// mergeFindings adds the detect stages' findings to the scene description.
+func (f *Frame) mergeFindings() {
+	if f.Context == nil || len(f.Findings) == 0 {
+		return
+	}
+	if f.Context.AdditionalInfo == nil {
+		f.Context.AdditionalInfo = make(map[string]string, len(f.Findings))
+	}
+	maps.Copy(f.Context.AdditionalInfo, f.Findings)
+}
@@ -0,0 +1,151 @@
+// handlers/frame_upload.go
+
+package handlers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+	"go.uber.org/zap"
+)
+
+// Image types accepted by the frame upload, by sniffed content type
+var uploadImageTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+}
+
+// frameUploadLimits bounds a frame upload: FRAME_UPLOAD_MAX_BYTES per frame
+// and FRAME_UPLOAD_MAX_FRAMES per request.
+func frameUploadLimits(cfg *utils.Config) (maxBytes int64, maxFrames int) {
+	return int64(cfg.FrameUploadMaxBytes), cfg.FrameUploadMaxFrames
+}
+
+// uploadError is a rejected upload with its HTTP status.
+type uploadError struct {
+	status  int
+	message string
+}
+
+func (e *uploadError) Error() string {
+	return e.message
+}
+
+// readUploadedFrames reads every file part of a multipart upload as a frame
+// and returns them as data URLs. The content type is sniffed from the bytes
+// rather than trusted from the part header, and each frame must decode.
+func readUploadedFrames(r *http.Request, maxBytes int64, maxFrames int) ([]string, error) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/form-data" {
+		return nil, &uploadError{http.StatusUnsupportedMediaType, "expected multipart/form-data"}
+	}
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, &uploadError{http.StatusBadRequest, err.Error()}
+	}
+
+	var frames []string
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				return nil, &uploadError{http.StatusRequestEntityTooLarge, "upload too large"}
+			}
+			return nil, &uploadError{http.StatusBadRequest, fmt.Sprintf("invalid multipart body: %v", err)}
+		}
+		if part.FileName() == "" {
+			part.Close()
+			continue
+		}
+		if len(frames) == maxFrames {
+			return nil, &uploadError{http.StatusRequestEntityTooLarge, fmt.Sprintf("at most %d frames per upload", maxFrames)}
+		}
+
+		data, err := io.ReadAll(io.LimitReader(part, maxBytes+1))
+		part.Close()
+		if err != nil {
+			return nil, &uploadError{http.StatusBadRequest, fmt.Sprintf("failed to read %s: %v", part.FileName(), err)}
+		}
+		if int64(len(data)) > maxBytes {
+			return nil, &uploadError{http.StatusRequestEntityTooLarge, fmt.Sprintf("%s exceeds %d bytes", part.FileName(), maxBytes)}
+		}
+		contentType := http.DetectContentType(data)
+		if !uploadImageTypes[contentType] {
+			return nil, &uploadError{http.StatusUnsupportedMediaType, fmt.Sprintf("%s is %s, expected JPEG or PNG", part.FileName(), contentType)}
+		}
+		if _, _, err := image.DecodeConfig(bytes.NewReader(data)); err != nil {
+			return nil, &uploadError{http.StatusBadRequest, fmt.Sprintf("%s is not a valid image: %v", part.FileName(), err)}
+		}
+		frames = append(frames, "data:"+contentType+";base64,"+base64.StdEncoding.EncodeToString(data))
+	}
+	if len(frames) == 0 {
+		return nil, &uploadError{http.StatusBadRequest, "no frames in upload"}
+	}
+	return frames, nil
+}
+
+// HandleFrameUpload queues JPEG or PNG frames uploaded as multipart files
+// for a live session's vision analysis, as if sent as video_data, for
+// robots whose camera pipeline can only POST: POST /robot/sessions/{id}/frames
+// The upload is validated as a whole; frames are then queued in order and
+// those the busy analysis queue could not take are reported as dropped.
+func HandleFrameUpload(w http.ResponseWriter, r *http.Request, tenants *utils.TenantStore) {
+	rs, ok := resolveTenantSession(w, r, tenants)
+	if !ok {
+		return
+	}
+	if !rs.hasModality(MODALITY_VIDEO) {
+		http.Error(w, "video modality is not enabled for this session", http.StatusConflict)
+		return
+	}
+	if !rs.rateLimiter.Allow() {
+		rs.recordUsage(models.USAGE_RATE_LIMITED, 1)
+		http.Error(w, "rate limited", http.StatusTooManyRequests)
+		return
+	}
+
+	maxBytes, maxFrames := frameUploadLimits(utils.CurrentConfig())
+	// Room for the multipart framing around the frames
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytes*int64(maxFrames)+1<<20)
+
+	frames, err := readUploadedFrames(r, maxBytes, maxFrames)
+	if err != nil {
+		status := http.StatusBadRequest
+		var rejected *uploadError
+		if errors.As(err, &rejected) {
+			status = rejected.status
+		}
+		rs.Logger.Warn("Rejected frame upload", zap.Int("status", status), zap.Error(err))
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	queued := 0
+	for _, frame := range frames {
+		if rs.submitFrame(frame, "") {
+			queued++
+		}
+	}
+	rs.Logger.Debug("Frames uploaded", zap.Int("frames", len(frames)), zap.Int("queued", queued))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]int{
+		"received": len(frames),
+		"queued":   queued,
+		"dropped":  len(frames) - queued,
+	})
+}
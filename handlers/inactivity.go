@@ -0,0 +1,248 @@
+// handlers/inactivity.go
+
+package handlers
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+	"go.uber.org/zap"
+)
+
+// Modes of a power_mode message.
+const (
+	POWER_MODE_NORMAL = "normal"
+	POWER_MODE_LOW    = "low_power"
+)
+
+// Reasons of a power_mode message.
+const (
+	POWER_REASON_INACTIVITY = "inactivity"
+	POWER_REASON_ACTIVITY   = "activity"
+	POWER_REASON_CONFIG     = "config"
+)
+
+// inactivityCheckInterval is how often idle time is compared with the
+// thresholds.
+const inactivityCheckInterval = time.Second
+
+// InactivitySettings control what happens when a session goes quiet.
+type InactivitySettings struct {
+	// PromptAfter sends Prompt as prompt_user after this long without
+	// speech or an intention (0 disables)
+	PromptAfter time.Duration
+	Prompt      string
+	// Speak asks the robot to say the prompt with its text-to-speech
+	Speak bool
+	// LowPowerAfter switches the session to low-power mode after this long
+	// without speech or an intention (0 disables)
+	LowPowerAfter time.Duration
+	// VideoFrequency is the slowest the video frequency gets in low-power
+	// mode
+	VideoFrequency time.Duration
+}
+
+func defaultInactivitySettings(cfg *utils.Config) InactivitySettings {
+	return InactivitySettings{
+		PromptAfter: cfg.InactivityPromptAfter,
+		// Set but empty leaves only low-power mode
+		Prompt:         strings.TrimSpace(cfg.InactivityPrompt),
+		Speak:          cfg.InactivityPromptSpeak,
+		LowPowerAfter:  cfg.InactivityLowPowerAfter,
+		VideoFrequency: cfg.InactivityVideoFrequency,
+	}
+}
+
+// InactivityMonitor watches for dead air: once nobody has spoken and no
+// intention was detected for a while, it prompts the user with prompt_user,
+// and later downshifts the session to low-power mode, where frames are
+// analyzed at most every INACTIVITY_VIDEO_FREQUENCY and linear16 audio is
+// gated by voice activity detection so silence is not transcribed. The
+// next speech or intention returns the session to normal.
+type InactivityMonitor struct {
+	session *RoboSession
+
+	mu         sync.Mutex
+	settings   InactivitySettings
+	lastActive time.Time
+	// prompted is set once the user was prompted in the current idle spell
+	prompted bool
+	lowPower bool
+}
+
+func NewInactivityMonitor(session *RoboSession) *InactivityMonitor {
+	return &InactivityMonitor{
+		session:    session,
+		settings:   defaultInactivitySettings(session.Config),
+		lastActive: session.Clock.Now(),
+	}
+}
+
+func (m *InactivityMonitor) Settings() InactivitySettings {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.settings
+}
+
+// LowPower reports whether the session is in low-power mode.
+func (m *InactivityMonitor) LowPower() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.lowPower
+}
+
+// Touch records speech or an intention, leaving low-power mode.
+func (m *InactivityMonitor) Touch() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lastActive = m.session.Clock.Now()
+	m.prompted = false
+	if m.lowPower {
+		m.resume(POWER_REASON_ACTIVITY)
+	}
+}
+
+// run checks idle time until the session stops.
+func (m *InactivityMonitor) run() {
+	ticker := m.session.Clock.NewTicker(inactivityCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.session.sessionCtx.Done():
+			return
+		case <-ticker.C():
+			m.check()
+		}
+	}
+}
+
+func (m *InactivityMonitor) check() {
+	m.mu.Lock()
+	settings := m.settings
+	idle := m.session.Clock.Since(m.lastActive)
+	prompt := settings.PromptAfter > 0 && settings.Prompt != "" && !m.prompted && idle >= settings.PromptAfter
+	if prompt {
+		m.prompted = true
+	}
+	if settings.LowPowerAfter > 0 && !m.lowPower && idle >= settings.LowPowerAfter {
+		m.downshift(settings, idle)
+	}
+	m.mu.Unlock()
+
+	if prompt {
+		m.prompt(settings, idle)
+	}
+}
+
+func (m *InactivityMonitor) prompt(settings InactivitySettings, idle time.Duration) {
+	payload := PromptUserPayload{
+		PromptID: m.session.IDs.NewID(),
+		Text:     settings.Prompt,
+		Speak:    settings.Speak,
+		Reason:   POWER_REASON_INACTIVITY,
+		IdleFor:  idle.Round(time.Second).String(),
+	}
+	m.session.Logger.Info("Prompting idle user", zap.String("prompt_id", payload.PromptID), zap.Duration("idle", idle))
+	m.session.sendWebSocketMessage("prompt_user", payload)
+}
+
+// downshift slows vision and gates speech-to-text. Called with m.mu held,
+// so switches happen in order.
+func (m *InactivityMonitor) downshift(settings InactivitySettings, idle time.Duration) {
+	m.lowPower = true
+	payload := PowerModePayload{
+		Mode:    POWER_MODE_LOW,
+		Reason:  POWER_REASON_INACTIVITY,
+		IdleFor: idle.Round(time.Second).String(),
+	}
+	if m.session.hasModality(MODALITY_VIDEO) {
+		m.session.AdaptiveFrequency.SetLowPower(settings.VideoFrequency)
+		payload.VideoFrequency = m.session.AdaptiveFrequency.Interval().String()
+	}
+	if m.session.AudioHandler != nil {
+		payload.SpeechGated = m.session.AudioHandler.SetLowPower(true)
+	}
+	m.session.Logger.Info("Session idle, entering low-power mode",
+		zap.Duration("idle", idle),
+		zap.String("video_frequency", payload.VideoFrequency),
+		zap.Bool("speech_gated", payload.SpeechGated))
+	m.session.sendWebSocketMessage("power_mode", payload)
+}
+
+// resume undoes downshift. Called with m.mu held.
+func (m *InactivityMonitor) resume(reason string) {
+	m.lowPower = false
+	payload := PowerModePayload{Mode: POWER_MODE_NORMAL, Reason: reason}
+	if m.session.hasModality(MODALITY_VIDEO) {
+		m.session.AdaptiveFrequency.SetLowPower(0)
+		payload.VideoFrequency = m.session.AdaptiveFrequency.Interval().String()
+	}
+	if m.session.AudioHandler != nil {
+		payload.SpeechGated = m.session.AudioHandler.SetLowPower(false)
+	}
+	m.session.Logger.Info("Leaving low-power mode", zap.String("reason", reason))
+	m.session.sendWebSocketMessage("power_mode", payload)
+}
+
+// applyInactivityConfig updates the settings from a config payload and
+// returns the offending field on invalid values. Turning low-power mode off
+// leaves it immediately.
+func (rs *RoboSession) applyInactivityConfig(configData map[string]interface{}) (string, error) {
+	m := rs.Inactivity
+	settings := m.Settings()
+
+	for _, field := range []struct {
+		key    string
+		target *time.Duration
+	}{
+		{"inactivity_prompt_after", &settings.PromptAfter},
+		{"inactivity_low_power_after", &settings.LowPowerAfter},
+	} {
+		value, exists := configData[field.key]
+		if !exists {
+			continue
+		}
+		str, _ := value.(string)
+		duration, err := time.ParseDuration(str)
+		if err != nil || duration < 0 {
+			return "data." + field.key, fmt.Errorf("must be a non-negative duration, e.g. 5m")
+		}
+		*field.target = duration
+	}
+	if value, exists := configData["inactivity_prompt"]; exists {
+		prompt, ok := value.(string)
+		if !ok {
+			return "data.inactivity_prompt", fmt.Errorf("must be a string")
+		}
+		settings.Prompt = strings.TrimSpace(prompt)
+	}
+	if value, exists := configData["inactivity_prompt_speak"]; exists {
+		speak, ok := value.(bool)
+		if !ok {
+			return "data.inactivity_prompt_speak", fmt.Errorf("must be a boolean")
+		}
+		settings.Speak = speak
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.settings = settings
+	if m.lowPower && settings.LowPowerAfter == 0 {
+		m.resume(POWER_REASON_CONFIG)
+	}
+	return "", nil
+}
+
+// inactivityConfig returns the settings in config payload form.
+func (s InactivitySettings) inactivityConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"inactivity_prompt_after":    s.PromptAfter.String(),
+		"inactivity_prompt":          s.Prompt,
+		"inactivity_prompt_speak":    s.Speak,
+		"inactivity_low_power_after": s.LowPowerAfter.String(),
+	}
+}
@@ -0,0 +1,56 @@
+// handlers/incognito.go
+
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
+)
+
+const (
+	INCOGNITO_SOURCE_CLIENT = "client"
+	INCOGNITO_SOURCE_TENANT = "tenant_policy"
+)
+
+// Stores an ordinary session writes to; incognito sessions skip all of them
+var persistentStores = []string{"session_meta", "snapshots", "analysis_archive", "vector_memory", "world_state", "site_memory", "frame_images", "robot_state", "recordings"}
+
+// parseIncognito decides whether a session runs in incognito mode: a tenant
+// policy forces it, otherwise clients opt in with ?incognito=true.
+func parseIncognito(r *http.Request, tenant *models.Tenant) (bool, string, error) {
+	if tenant.Incognito {
+		return true, INCOGNITO_SOURCE_TENANT, nil
+	}
+	value := r.URL.Query().Get("incognito")
+	if value == "" {
+		return false, "", nil
+	}
+	incognito, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, "", fmt.Errorf("invalid incognito flag %q", value)
+	}
+	if !incognito {
+		return false, "", nil
+	}
+	return true, INCOGNITO_SOURCE_CLIENT, nil
+}
+
+// privacy reports the session's persistence state for the welcome message.
+func (rs *RoboSession) privacy() Privacy {
+	if !rs.Incognito {
+		return Privacy{Persisted: persistentStores}
+	}
+	// Usage counters hold no content and stay on for billing
+	return Privacy{Incognito: true, Source: rs.incognitoSource, Persisted: []string{"usage_counters"}}
+}
+
+// redact hides user content from logs of incognito sessions.
+func (rs *RoboSession) redact(text string) string {
+	if rs.Incognito {
+		return fmt.Sprintf("[redacted %d chars]", len(text))
+	}
+	return text
+}
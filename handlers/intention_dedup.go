@@ -0,0 +1,93 @@
+// handlers/intention_dedup.go
+
+package handlers
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+	"go.uber.org/zap"
+)
+
+type notifiedIntention struct {
+	intentionType string
+	description   string
+	embedding     []float64
+	notifiedAt    time.Time
+}
+
+// IntentionDeduper suppresses orchestrator notifications for intentions that
+// repeat a recently notified one (same type, similar description).
+type IntentionDeduper struct {
+	clock      utils.Clock
+	mu         sync.Mutex
+	window     time.Duration
+	similarity float64
+	recent     []notifiedIntention
+}
+
+func NewIntentionDeduper(cfg *utils.Config, clock utils.Clock) *IntentionDeduper {
+	return &IntentionDeduper{
+		clock:      clock,
+		window:     cfg.IntentionDedupWindow,
+		similarity: cfg.IntentionDedupSimilarity,
+	}
+}
+
+// IsDuplicate reports whether result repeats an intention notified within the
+// window. When it does not, the intention is remembered for later checks.
+func (d *IntentionDeduper) IsDuplicate(ctx context.Context, openaiClient *utils.OpenAIClient, result models.IntentionResult, logger *zap.Logger) bool {
+	if d.window <= 0 {
+		return false
+	}
+
+	embedding, err := openaiClient.CreateEmbedding(ctx, result.Description)
+	if err != nil {
+		// Fall back to exact description matching below
+		logger.Warn("Failed to embed intention for deduplication", zap.Error(err))
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.clock.Now()
+	kept := d.recent[:0]
+	for _, prev := range d.recent {
+		if now.Sub(prev.notifiedAt) <= d.window {
+			kept = append(kept, prev)
+		}
+	}
+	d.recent = kept
+
+	for _, prev := range d.recent {
+		if prev.intentionType != result.IntentionType {
+			continue
+		}
+		if embedding != nil && prev.embedding != nil {
+			if score := utils.CosineSimilarity(embedding, prev.embedding); score >= d.similarity {
+				logger.Info("Suppressing duplicate intention",
+					zap.String("type", result.IntentionType),
+					zap.Float64("similarity", score),
+					zap.Duration("since_previous", now.Sub(prev.notifiedAt)))
+				return true
+			}
+		} else if strings.EqualFold(strings.TrimSpace(prev.description), strings.TrimSpace(result.Description)) {
+			logger.Info("Suppressing duplicate intention",
+				zap.String("type", result.IntentionType),
+				zap.Duration("since_previous", now.Sub(prev.notifiedAt)))
+			return true
+		}
+	}
+
+	d.recent = append(d.recent, notifiedIntention{
+		intentionType: result.IntentionType,
+		description:   result.Description,
+		embedding:     embedding,
+		notifiedAt:    now,
+	})
+	return false
+}
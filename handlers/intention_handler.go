@@ -3,26 +3,32 @@
 package handlers
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"net/http"
-	"os"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
 	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
-	"github.com/pinecone-io/go-pinecone/v4/pinecone"
 	"go.uber.org/zap"
 )
 
 type IntentionHandler struct {
 	session      *RoboSession
 	openaiClient *utils.OpenAIClient
-	pineconeIdx  *pinecone.IndexConnection
+	pineconeIdx  *utils.PineconeIndex
+	deduper      *IntentionDeduper
+	grammar      *utils.CommandGrammar
+	tools        *utils.ToolRegistry
+	confirmer    *IntentionConfirmer
+	sentiment    utils.SentimentAnalyzer
+	escalator    *IntentionEscalator
+	queue        intentionQueue
 	isActive     bool
 }
 
@@ -30,11 +36,11 @@ func InitIntentionHandler(session *RoboSession) *IntentionHandler {
 	session.Logger.Info("Initializing Intention Handler...")
 
 	// Initialize OpenAI client
-	openaiClient := utils.NewOpenAIClient()
+	openaiClient := session.openAIClient()
 
 	// Initialize Pinecone connection
-	pineconeIdx, err := utils.GetPineconeIndex(&session.ID)
-	if err != nil {
+	pineconeIdx, err := session.pineconeIndex()
+	if err != nil && !errors.Is(err, errMemoryDisabled) {
 		session.Logger.Warn("Failed to initialize Pinecone connection", zap.Error(err))
 	}
 
@@ -42,75 +48,266 @@ func InitIntentionHandler(session *RoboSession) *IntentionHandler {
 		session:      session,
 		openaiClient: openaiClient,
 		pineconeIdx:  pineconeIdx,
+		deduper:      NewIntentionDeduper(session.Config, session.Clock),
+		grammar:      utils.SharedCommandGrammar(),
+		confirmer:    NewIntentionConfirmerFromConfig(session.Config),
+		sentiment:    newSentimentAnalyzer(session, openaiClient),
+		escalator:    NewIntentionEscalator(session.Config, session.Tenant),
+		queue:        intentionQueue{coalesce: session.Config.IntentionCoalesce},
 		isActive:     true,
 	}
+	if session.Config.IntentionToolsEnabled {
+		intentionHandler.tools = newIntentionTools(session)
+	}
 
 	session.Logger.Info("Intention Handler initialized")
 
 	return intentionHandler
 }
 
-func (h *IntentionHandler) analyzeIntention(transcript string) {
+// analyzeIntention returns false when the analysis was cancelled before its
+// result was published.
+func (h *IntentionHandler) analyzeIntention(ctx context.Context, transcript string, conversation []string) bool {
 	// Create a new context with timeout for this specific operation
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
-	h.session.Logger.Debug("Analyzing intention from transcript", zap.String("transcript", transcript))
+	h.session.Logger.Debug("Analyzing intention from transcript", zap.String("transcript", h.session.redact(transcript)))
 
-	// Get relevant environment context from Pinecone
+	// Get relevant environment context from Pinecone, falling back to the
+	// session's recent contexts when Pinecone is unavailable or slow
 	var environmentContext []string
 	if h.pineconeIdx != nil {
 		context, err := h.getRelevantEnvironmentContext(ctx, transcript)
 		if err != nil {
-			h.session.Logger.Error("Failed to get environment context", zap.Error(err))
+			h.session.Logger.Warn("Failed to get environment context from Pinecone, using local cache", zap.Error(err))
+			h.session.MetricLabels.ProviderError("pinecone")
+			environmentContext = h.session.EnvironmentCache.Recent(5)
 		} else {
 			environmentContext = context
 		}
+	} else {
+		environmentContext = h.session.EnvironmentCache.Recent(5)
 	}
 
-	// Analyze intention with OpenAI
-	intention, err := h.openaiClient.AnalyzeTranscriptForIntention(ctx, transcript, environmentContext)
+	// Learn from the utterance while recalling what is already known
+	h.session.Supervisor.Task("preference_learning", func() { h.session.Preferences.Learn(transcript) })
+	preferences := h.session.Preferences.Recall(ctx, transcript)
+
+	// What this and other robots at the site saw elsewhere
+	environmentContext = append(environmentContext, h.session.SiteMemory.Recall(ctx, transcript)...)
+	// and, until it describes a scene, what it saw at the end of its last session
+	environmentContext = append(environmentContext, h.session.robotRecall()...)
+
+	// How it was said is classified meanwhile
+	sentiment := h.startSentiment(ctx, transcript)
+
+	// Analyze intention with OpenAI, letting the model look up robot state,
+	// map locations and time when tools are enabled
+	var intention *models.IntentionResult
+	var err error
+	started := h.session.Clock.Now()
+	if h.tools != nil {
+		maxRounds := h.session.Config.IntentionToolMaxRounds
+		intention, err = h.openaiClient.AnalyzeTranscriptWithTools(ctx, transcript, environmentContext, preferences, conversation, h.tools, maxRounds)
+	} else {
+		intention, err = h.openaiClient.AnalyzeTranscriptForIntention(ctx, transcript, environmentContext, preferences, conversation)
+	}
+	if cancelled(ctx) {
+		h.session.Logger.Info("Intention analysis cancelled", zap.Error(ctx.Err()))
+		return false
+	}
 	if err != nil {
 		h.session.Logger.Error("Failed to analyze intention", zap.Error(err))
-		return
+		h.session.MetricLabels.ProviderError("openai")
+		h.session.sendError(ERROR_CODE_INTENTION_FAILED, "", "Intention analysis failed")
+		return true
 	}
+	h.session.MetricLabels.ObserveAnalysis("intention", h.session.Clock.Since(started).Seconds())
+
+	h.session.recordUsage(models.USAGE_INTENTIONS, 1)
 
 	// Parse the intention result
 	hasIntention, intentionType, description, confidence := intention.HasClearIntention, intention.IntentionType, intention.Description, intention.Confidence
 
 	// Create intention result
 	result := models.IntentionResult{
+		ID:                 h.session.IDs.NewID(),
 		HasClearIntention:  hasIntention,
 		IntentionType:      intentionType,
 		Description:        description,
 		Confidence:         confidence,
 		EnvironmentContext: strings.Join(environmentContext, "\n"),
-		Timestamp:          time.Now(),
+		Preferences:        preferences,
+		Conversation:       conversation,
+		Slots:              intention.Slots,
+		ToolCalls:          intention.ToolCalls,
+		Source:             models.INTENTION_SOURCE_MODEL,
+		Sentiment:          sentiment(),
+		Timestamp:          h.session.Clock.Now(),
 	}
+	h.publishIntention(ctx, transcript, environmentContext, result)
+	return true
+}
 
+// publishIntention records an intention, forwards confident ones to the
+// orchestrator and reports it to the client. Intentions escalated by their
+// sentiment are forwarded whatever their confidence. Grammar matches and
+// escalations skip deduplication so repeated safety commands and calls for
+// help always go through.
+func (h *IntentionHandler) publishIntention(ctx context.Context, transcript string, environmentContext []string, result models.IntentionResult) {
+	hasIntention, intentionType, description, confidence := result.HasClearIntention, result.IntentionType, result.Description, result.Confidence
+	escalation := h.escalator.escalate(result.Sentiment, h.session.Clock.Now())
+	if escalation != nil {
+		result.Escalation = &models.Escalation{PolicyID: escalation.ID, PolicyName: escalation.Name}
+		h.session.Logger.Warn("Intention escalated",
+			zap.String("policy_id", escalation.ID),
+			zap.String("emotion", result.Sentiment.Emotion),
+			zap.Float64("urgency", result.Sentiment.Urgency),
+			zap.Float64("confidence", confidence))
+	}
 	if hasIntention {
+		h.session.Inactivity.Touch()
 		h.session.Logger.Info("Intention detected",
 			zap.String("type", intentionType),
-			zap.String("description", description),
+			zap.String("source", result.Source),
+			zap.String("description", h.session.redact(description)),
 			zap.Float64("confidence", confidence))
 	} else {
 		h.session.Logger.Debug("No clear intention detected",
-			zap.String("description", description),
+			zap.String("description", h.session.redact(description)),
 			zap.Float64("confidence", confidence))
 	}
 
+	h.session.setLastIntention(result)
+	h.session.journalRequest(transcript, result)
+	if !h.session.Incognito {
+		h.session.Supervisor.Task("intention_archive", func() { h.archiveAnalysis(transcript, environmentContext, result) })
+		h.storeIntention(transcript, result)
+	}
+
+	if escalation != nil && slices.Contains(escalation.Actions, models.RULE_ACTION_ALERT) {
+		h.session.sendWebSocketMessage("intention_escalated", IntentionEscalatedPayload{
+			IntentionID:   result.ID,
+			PolicyID:      escalation.ID,
+			PolicyName:    escalation.Name,
+			IntentionType: intentionType,
+			Description:   description,
+			Confidence:    confidence,
+			Sentiment:     *result.Sentiment,
+			Timestamp:     result.Timestamp.Unix(),
+		})
+	}
+
 	if hasIntention && confidence > 0.7 {
-		h.notifyOrchestrator(result)
+		if result.Source != models.INTENTION_SOURCE_GRAMMAR && result.Escalation == nil && h.deduper.IsDuplicate(ctx, h.openaiClient, result, h.session.Logger) {
+			h.session.sendWebSocketMessage("intention_deduplicated", result)
+		} else {
+			h.notifyOrchestrator(transcript, result, false)
+		}
+	} else if escalation != nil && slices.Contains(escalation.Actions, models.RULE_ACTION_ORCHESTRATOR) {
+		h.notifyOrchestrator(transcript, result, false)
+	} else if h.confirmer.needsConfirmation(result) {
+		result.AwaitingConfirmation = true
+		h.session.sendWebSocketMessage("intention_analysis", result)
+		h.askConfirmation(transcript, result)
+		return
 	}
 
 	h.session.sendWebSocketMessage("intention_analysis", result)
 }
 
+// archiveAnalysis keeps the analysis input and output for offline re-analysis.
+func (h *IntentionHandler) archiveAnalysis(transcript string, environmentContext []string, result models.IntentionResult) {
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		h.session.Logger.Error("Failed to marshal intention for archive", zap.Error(err))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	worker := utils.Worker()
+	record := models.AnalysisRecord{
+		ID:                 result.ID,
+		SessionID:          h.session.ID,
+		TenantID:           h.session.Tenant.ID,
+		Kind:               models.ANALYSIS_KIND_INTENTION,
+		Transcript:         transcript,
+		EnvironmentContext: environmentContext,
+		Preferences:        result.Preferences,
+		Conversation:       result.Conversation,
+		Result:             resultJSON,
+		Worker:             &worker,
+		Timestamp:          result.Timestamp,
+	}
+	if err := utils.ArchiveAnalysis(ctx, h.session.RedisClient, record); err != nil {
+		h.session.Logger.Warn("Failed to archive intention analysis", zap.Error(err))
+	}
+}
+
+// storeIntention queues the intention for the memory store so the history
+// endpoint can search past intentions by meaning.
+func (h *IntentionHandler) storeIntention(transcript string, result models.IntentionResult) {
+	if h.pineconeIdx == nil {
+		return
+	}
+
+	lines := []string{"Transcript: " + transcript}
+	if result.IntentionType != "" {
+		lines = append(lines, "Intention: "+result.IntentionType)
+	}
+	if result.Description != "" {
+		lines = append(lines, "Description: "+result.Description)
+	}
+	if len(result.Slots) > 0 {
+		if slots, err := json.Marshal(result.Slots); err == nil {
+			lines = append(lines, "Slots: "+string(slots))
+		}
+	}
+
+	vectorID := result.ID + intentionRecordSuffix
+	metadata := map[string]interface{}{
+		"session_id":     h.session.ID,
+		"intention_id":   result.ID,
+		"intention_type": result.IntentionType,
+		"confidence":     result.Confidence,
+		"timestamp":      result.Timestamp.Unix(),
+		"type":           INTENTION_RECORD_TYPE,
+		"server_version": utils.Version,
+		"instance_id":    utils.InstanceID(),
+	}
+	h.session.addMetadataFields(metadata)
+
+	logger := h.session.Logger
+	labels := h.session.MetricLabels
+	pineconeWriter().Enqueue(utils.PineconeWrite{
+		Index:    h.pineconeIdx,
+		ID:       vectorID,
+		Text:     strings.Join(lines, "\n"),
+		Metadata: metadata,
+		OnFailure: func(err error) {
+			logger.Error("Failed to upsert intention to Pinecone", zap.Error(err), zap.String("vector_id", vectorID))
+			labels.ProviderError("pinecone")
+		},
+	})
+}
+
 func (h *IntentionHandler) getRelevantEnvironmentContext(ctx context.Context, transcript string) ([]string, error) {
 	if h.pineconeIdx == nil {
 		return []string{}, nil
 	}
-	queryResponse, err := utils.FetchResponseFromPinecone(ctx, h.pineconeIdx, transcript)
+	idx, err := h.pineconeIdx.Conn()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, h.session.Config.PineconeQueryTimeout)
+	defer cancel()
+	query := utils.PineconeQueryFromConfig(h.session.Config, h.session.ID, h.session.Clock.Now())
+	query.Text = transcript
+	queryResponse, err := utils.FetchResponseFromPinecone(ctx, idx, query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch response from Pinecone: %w", err)
 	}
@@ -118,59 +315,156 @@ func (h *IntentionHandler) getRelevantEnvironmentContext(ctx context.Context, tr
 	return queryResponse, nil
 }
 
-func (h *IntentionHandler) notifyOrchestrator(result models.IntentionResult) {
+// notifyOrchestrator forwards an intention; confirmed marks intentions the
+// user confirmed by voice.
+func (h *IntentionHandler) notifyOrchestrator(transcript string, result models.IntentionResult, confirmed bool) {
 	h.session.Logger.Info("Notifying orchestrator of detected intention",
 		zap.String("type", result.IntentionType),
 		zap.Float64("confidence", result.Confidence))
 
 	// Prepare payload for orchestrator
-	payload := map[string]interface{}{
-		"session_id":          h.session.ID,
-		"intention_type":      result.IntentionType,
-		"description":         result.Description,
-		"confidence":          result.Confidence,
-		"transcript":          h.session.CurrentTranscript,
-		"environment_context": result.EnvironmentContext,
-		"timestamp":           result.Timestamp.Unix(),
+	payload := OrchestratorIntentionPayload{
+		IntentionID:        result.ID,
+		SessionID:          h.session.ID,
+		TenantID:           h.session.Tenant.ID,
+		IntentionType:      result.IntentionType,
+		Description:        result.Description,
+		Confidence:         result.Confidence,
+		Slots:              result.Slots,
+		Source:             result.Source,
+		Transcript:         transcript,
+		EnvironmentContext: result.EnvironmentContext,
+		Timestamp:          result.Timestamp.Unix(),
+		Worker:             utils.Worker(),
+		Incognito:          h.session.Incognito,
+		Confirmed:          confirmed,
+		Sentiment:          result.Sentiment,
+		Escalation:         result.Escalation,
+		Metadata:           h.session.metadataCopy(),
 	}
 
-	// Make API call to orchestrator
-	h.session.Logger.Info("Orchestrator notification payload", zap.Any("payload", payload))
+	h.session.journalAction("%s intention sent to the orchestrator: %s", result.IntentionType, result.Description)
+	h.session.routeToOrchestrators(result.IntentionType, result.ID, payload)
+}
 
-	jsonData, err := json.Marshal(payload)
-	if err != nil {
-		h.session.Logger.Error("Failed to marshal payload", zap.Error(err))
+// routeToOrchestrators posts an intention to every endpoint routed for its
+// type in parallel, or to the tenant's orchestrator when no route matches.
+func (rs *RoboSession) routeToOrchestrators(intentionType, idempotencyKey string, payload interface{}) {
+	endpoints := utils.RouteEndpoints(rs.orchestratorRoutes(), intentionType)
+	if len(endpoints) == 0 {
+		rs.postToOrchestrator(idempotencyKey, payload)
 		return
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+
+	rs.logOrchestratorPayload(payload)
+	tenant := rs.credentials()
+	var wg sync.WaitGroup
+	for _, endpoint := range endpoints {
+		wg.Add(1)
+		rs.Supervisor.Task("orchestrator_route", func() {
+			defer wg.Done()
+			rs.callOrchestrator(utils.EndpointTenant(tenant, endpoint), idempotencyKey, payload)
+		})
+	}
+	wg.Wait()
+}
+
+// orchestratorRoutes returns the tenant's routing table; one stored through
+// the admin API takes precedence over the tenant configuration.
+func (rs *RoboSession) orchestratorRoutes() []models.OrchestratorRoute {
+	tenant := rs.credentials()
+	if rs.RedisClient == nil {
+		return tenant.OrchestratorRoutes
+	}
+	ctx, cancel := context.WithTimeout(rs.sessionCtx, 2*time.Second)
 	defer cancel()
-	orchestratorEndpoint := os.Getenv("ORCHESTRATOR_URL")
-	apiKey := os.Getenv("ORCHESTRATOR_API_KEY")
-	client := &http.Client{Timeout: 10 * time.Minute}
-	req, err := http.NewRequestWithContext(ctx, "POST", orchestratorEndpoint+"/orchestrate",
-		bytes.NewBuffer(jsonData))
+	routes, stored, err := utils.LoadStoredOrchestratorRoutes(ctx, rs.RedisClient, tenant.ID)
 	if err != nil {
-		h.session.Logger.Error("Failed to create orchestrator request", zap.Error(err))
-		return
+		rs.Logger.Warn("Failed to load stored orchestrator routes, using configured routes", zap.Error(err))
+		return tenant.OrchestratorRoutes
 	}
+	if stored {
+		return routes
+	}
+	return tenant.OrchestratorRoutes
+}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+apiKey)
+// postToOrchestrator sends a payload to the tenant's orchestrator. It is used
+// for server-side triggers such as rules, and for intentions without a route.
+func (rs *RoboSession) postToOrchestrator(idempotencyKey string, payload interface{}) {
+	rs.logOrchestratorPayload(payload)
+	rs.callOrchestrator(rs.credentials(), idempotencyKey, payload)
+}
+
+// notifyOrchestrator posts payload in the background.
+func (rs *RoboSession) notifyOrchestrator(idempotencyKey string, payload interface{}) {
+	rs.Supervisor.Task("orchestrator_notification", func() { rs.postToOrchestrator(idempotencyKey, payload) })
+}
 
-	resp, err := client.Do(req)
+func (rs *RoboSession) logOrchestratorPayload(payload interface{}) {
+	if rs.Incognito {
+		rs.Logger.Info("Orchestrator notification payload", zap.String("payload", "[redacted]"))
+	} else {
+		rs.Logger.Info("Orchestrator notification payload", zap.Any("payload", payload))
+	}
+}
+
+// callOrchestrator posts payload to the orchestrator configured on tenant.
+// Network errors, 429 and 5xx responses are retried with exponential backoff
+// up to ORCHESTRATOR_MAX_ATTEMPTS, every attempt carrying idempotencyKey so
+// the orchestrator can drop the deliveries it already acted on.
+func (rs *RoboSession) callOrchestrator(tenant *models.Tenant, idempotencyKey string, payload interface{}) {
+	client, err := utils.SharedOrchestratorClient(tenant)
 	if err != nil {
-		h.session.Logger.Error("Failed to call orchestrator", zap.Error(err))
+		rs.Logger.Error("Invalid orchestrator configuration", zap.String("url", tenant.OrchestratorURL), zap.Error(err))
+		rs.MetricLabels.ProviderError("orchestrator")
+		rs.sendError(ERROR_CODE_ORCHESTRATOR_CONFIG, "", "Orchestrator is misconfigured")
 		return
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	// Bound to the session rather than the utterance so a new utterance
+	// doesn't abort an intention that was already dispatched
+	ctx, cancel := context.WithTimeout(rs.sessionCtx, 10*time.Minute)
+	defer cancel()
+	maxAttempts := max(rs.Config.OrchestratorMaxAttempts, 1)
+	backoff := rs.Config.OrchestratorRetryBackoff
+
+	var status int
+	var body []byte
+	for attempt := 1; ; attempt++ {
+		status, body, err = client.Post(ctx, "/orchestrate", idempotencyKey, payload)
+		if cancelled(ctx) {
+			rs.Logger.Info("Orchestrator call cancelled, session stopped")
+			return
+		}
+		if attempt >= maxAttempts || !utils.OrchestratorRetryable(status, err) {
+			break
+		}
+
+		wait := backoff << (attempt - 1)
+		rs.Logger.Warn("Retrying orchestrator call",
+			zap.String("url", tenant.OrchestratorURL), zap.String("idempotency_key", idempotencyKey),
+			zap.Int("attempt", attempt), zap.Int("status", status), zap.Duration("backoff", wait), zap.Error(err))
+		select {
+		case <-ctx.Done():
+			rs.Logger.Info("Orchestrator call cancelled, session stopped")
+			return
+		case <-rs.Clock.After(wait):
+		}
+	}
 	if err != nil {
-		h.session.Logger.Error("Failed to read response body", zap.Error(err))
+		rs.Logger.Error("Failed to call orchestrator", zap.String("url", tenant.OrchestratorURL), zap.Error(err))
+		rs.MetricLabels.ProviderError("orchestrator")
+		rs.sendError(ERROR_CODE_ORCHESTRATOR_FAILED, "", "Orchestrator unreachable")
 		return
 	}
+	if status >= http.StatusBadRequest {
+		rs.MetricLabels.ProviderError("orchestrator")
+		rs.sendError(ERROR_CODE_ORCHESTRATOR_FAILED, "", fmt.Sprintf("Orchestrator returned %d", status))
+	}
 
-	h.session.Logger.Info("Orchestrator response", zap.String("body", string(body)))
+	rs.recordUsage(models.USAGE_ORCHESTRATIONS, 1)
+	rs.Logger.Info("Orchestrator response", zap.String("url", tenant.OrchestratorURL), zap.String("body", string(body)))
 }
 
 func (h *IntentionHandler) Close() {
@@ -178,12 +472,49 @@ func (h *IntentionHandler) Close() {
 	h.isActive = false
 }
 
-// ProcessTranscript should be called when a complete transcript is ready
-func (h *IntentionHandler) ProcessTranscript(transcript string) {
-	if transcript == "" {
-		return
+// processTranscript analyzes one transcript from the queue, preceded by the
+// carried transcript of a cancelled analysis, and returns false when ctx was
+// cancelled before the result was published.
+func (h *IntentionHandler) processTranscript(ctx context.Context, carried, transcript string) bool {
+	h.session.Logger.Info("Processing transcript for intention analysis", zap.String("transcript", h.session.redact(transcript)))
+	// A pending "did you mean" question consumes the answer
+	if transcript = h.answerConfirmation(transcript); transcript == "" {
+		return true
+	}
+	// The conversation before this utterance, which joins it; a carried
+	// transcript joined it when it was first analyzed
+	conversation := h.session.Conversation.Context()
+	h.session.Conversation.Add(transcript)
+	if carried != "" {
+		transcript = carried + " " + transcript
+	}
+	if h.matchCommand(ctx, transcript) {
+		return true
+	}
+	return h.analyzeIntention(ctx, transcript, conversation)
+}
+
+// matchCommand runs the command grammar fast path. Exact matches such as
+// "stop" are published right away without the model round trip.
+func (h *IntentionHandler) matchCommand(ctx context.Context, transcript string) bool {
+	rule, slots, ok := h.grammar.Match(transcript)
+	if !ok {
+		return false
 	}
 
-	h.session.Logger.Info("Processing transcript for intention analysis", zap.String("transcript", transcript))
-	h.analyzeIntention(transcript)
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	result := models.IntentionResult{
+		ID:                h.session.IDs.NewID(),
+		HasClearIntention: true,
+		IntentionType:     rule.IntentionType,
+		Description:       rule.Description,
+		Confidence:        1,
+		Slots:             slots,
+		Source:            models.INTENTION_SOURCE_GRAMMAR,
+		Timestamp:         h.session.Clock.Now(),
+	}
+	h.publishIntention(ctx, transcript, nil, result)
+	return true
 }
@@ -0,0 +1,168 @@
+// handlers/intention_history.go
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// INTENTION_RECORD_TYPE is the memory store type of intention records. It is
+// not in the default PINECONE_FILTER_TYPES, so intentions never show up as
+// scene context.
+const INTENTION_RECORD_TYPE = "intention"
+
+const (
+	intentionRecordSuffix   = "-intention"
+	defaultIntentionHistory = 10
+	maxIntentionHistory     = 100
+)
+
+// IntentionHistoryEntry is one past intention. Score is the semantic
+// similarity to the query; Result and Transcript come from the archive and
+// are missing once it expired, leaving only the stored Text.
+type IntentionHistoryEntry struct {
+	IntentionID string                  `json:"intention_id"`
+	Score       float64                 `json:"score,omitempty"`
+	Timestamp   time.Time               `json:"timestamp"`
+	Transcript  string                  `json:"transcript,omitempty"`
+	Result      *models.IntentionResult `json:"result,omitempty"`
+	Text        string                  `json:"text,omitempty"`
+}
+
+// HandleSessionIntentions lists a (live or ended) session's intentions,
+// ranked by similarity to q or newest first without it:
+// GET /robot/sessions/{id}/intentions[?q=...&limit=10&since=24h]
+func HandleSessionIntentions(w http.ResponseWriter, r *http.Request, redisClient *redis.Client, tenants *utils.TenantStore) {
+	tenant, err := tenants.Resolve(r)
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	sessionID := r.PathValue("id")
+	meta, err := utils.LoadSessionMeta(r.Context(), redisClient, sessionID)
+	if err != nil || meta.TenantID != tenant.ID {
+		http.Error(w, "session not found", http.StatusNotFound)
+		return
+	}
+
+	query := r.URL.Query()
+	limit := defaultIntentionHistory
+	if value := query.Get("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxIntentionHistory {
+			http.Error(w, "limit must be between 1 and 100", http.StatusBadRequest)
+			return
+		}
+	}
+	var since time.Time
+	if value := query.Get("since"); value != "" {
+		window, err := time.ParseDuration(value)
+		if err != nil {
+			http.Error(w, "invalid since duration", http.StatusBadRequest)
+			return
+		}
+		since = time.Now().Add(-window)
+	}
+
+	records, err := utils.LoadSessionAnalyses(r.Context(), redisClient, sessionID)
+	if err != nil {
+		zap.L().Error("Failed to load session intentions", zap.String("session_id", sessionID), zap.Error(err))
+		http.Error(w, "failed to load session", http.StatusInternalServerError)
+		return
+	}
+	archived := make(map[string]IntentionHistoryEntry)
+	for _, record := range records {
+		if record.Kind != models.ANALYSIS_KIND_INTENTION {
+			continue
+		}
+		entry := IntentionHistoryEntry{IntentionID: record.ID, Timestamp: record.Timestamp, Transcript: record.Transcript}
+		var result models.IntentionResult
+		if err := json.Unmarshal(record.Result, &result); err == nil {
+			entry.Result = &result
+		}
+		archived[record.ID] = entry
+	}
+
+	text := strings.TrimSpace(query.Get("q"))
+	var entries []IntentionHistoryEntry
+	if text == "" {
+		entries = recentIntentions(archived, since, limit)
+	} else {
+		if tenant.PineconeAPIKey == "" || tenant.PineconeHost == "" {
+			http.Error(w, "semantic search is not configured", http.StatusServiceUnavailable)
+			return
+		}
+		entries, err = searchIntentions(r.Context(), tenant, sessionID, text, since, limit, archived)
+		if err != nil {
+			zap.L().Error("Failed to search session intentions", zap.String("session_id", sessionID), zap.Error(err))
+			http.Error(w, "failed to search intentions", http.StatusBadGateway)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"session_id": sessionID,
+		"query":      text,
+		"intentions": entries,
+	})
+}
+
+func recentIntentions(archived map[string]IntentionHistoryEntry, since time.Time, limit int) []IntentionHistoryEntry {
+	entries := make([]IntentionHistoryEntry, 0, len(archived))
+	for _, entry := range archived {
+		if entry.Timestamp.Before(since) {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Timestamp.After(entries[j].Timestamp) })
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return entries
+}
+
+func searchIntentions(ctx context.Context, tenant *models.Tenant, sessionID, text string, since time.Time, limit int, archived map[string]IntentionHistoryEntry) ([]IntentionHistoryEntry, error) {
+	idx, err := utils.SharedPineconeIndex(tenant.PineconeAPIKey, tenant.PineconeHost, tenant.PineconeNamespace)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, utils.CurrentConfig().PineconeQueryTimeout)
+	defer cancel()
+	matches, err := utils.SearchPinecone(ctx, idx, utils.PineconeQuery{
+		Text:      text,
+		TopK:      limit,
+		SessionID: sessionID,
+		Types:     []string{INTENTION_RECORD_TYPE},
+		Since:     since,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]IntentionHistoryEntry, 0, len(matches))
+	for _, match := range matches {
+		intentionID := strings.TrimSuffix(match.ID, intentionRecordSuffix)
+		entry, ok := archived[intentionID]
+		if !ok {
+			entry = IntentionHistoryEntry{IntentionID: intentionID, Timestamp: match.Timestamp, Text: match.Text}
+		}
+		entry.Score = match.Score
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
@@ -0,0 +1,103 @@
+// handlers/intention_queue.go
+
+package handlers
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// intentionQueue runs a session's transcript analyses one at a time in
+// arrival order, so LLM latency never reorders intention_analysis messages
+// or orchestrator calls. A new transcript cancels the analysis still running
+// (barge-in); queued transcripts are not affected and each gets a fresh
+// context when it is dequeued. With coalescing, transcripts that queued up
+// while an analysis ran are merged into one analysis, together with a
+// transcript whose analysis was cancelled by the newer utterance. Command
+// grammar matches are never merged so "stop" keeps its fast path.
+type intentionQueue struct {
+	mu      sync.Mutex
+	pending []queuedTranscript
+	carry   string
+	running bool
+	// cancel abandons the running analysis
+	cancel   context.CancelFunc
+	coalesce bool
+}
+
+type queuedTranscript struct {
+	transcript string
+	command    bool
+}
+
+// ProcessTranscript queues a complete transcript for intention analysis
+// without blocking and cancels the analysis of the previous one if it is
+// still running.
+func (h *IntentionHandler) ProcessTranscript(transcript string) {
+	if transcript == "" {
+		return
+	}
+
+	_, _, command := h.grammar.Match(transcript)
+
+	q := &h.queue
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.pending = append(q.pending, queuedTranscript{transcript: transcript, command: command})
+	if q.cancel != nil {
+		q.cancel()
+		q.cancel = nil
+	}
+	if q.running {
+		h.session.Logger.Debug("Transcript queued behind running analysis", zap.Int("queued", len(q.pending)))
+		return
+	}
+	q.running = true
+	h.session.Supervisor.Go("intention", h.drainTranscripts)
+}
+
+// drainTranscripts processes queued transcripts until the queue is empty.
+func (h *IntentionHandler) drainTranscripts() {
+	q := &h.queue
+	for {
+		q.mu.Lock()
+		if len(q.pending) == 0 {
+			q.running = false
+			q.mu.Unlock()
+			return
+		}
+		next := q.pending[0]
+		q.pending = q.pending[1:]
+		carried := ""
+		if q.coalesce && !next.command {
+			parts := []string{next.transcript}
+			for len(q.pending) > 0 && !q.pending[0].command {
+				parts = append(parts, q.pending[0].transcript)
+				q.pending = q.pending[1:]
+			}
+			if carried = q.carry; len(parts) > 1 || carried != "" {
+				h.session.Logger.Info("Coalescing transcripts into one analysis",
+					zap.Int("transcripts", len(parts)), zap.Bool("carried", carried != ""))
+			}
+			next.transcript = strings.Join(parts, " ")
+		}
+		// A command supersedes the cancelled transcript rather than joining it
+		q.carry = ""
+		ctx, cancel := context.WithCancel(h.session.sessionCtx)
+		q.cancel = cancel
+		q.mu.Unlock()
+
+		published := h.processTranscript(ctx, carried, next.transcript)
+		cancel()
+		if published || !q.coalesce || h.session.sessionCtx.Err() != nil {
+			continue
+		}
+		// A newer utterance cancelled the analysis: analyze both together
+		q.mu.Lock()
+		q.carry = strings.TrimSpace(carried + " " + next.transcript)
+		q.mu.Unlock()
+	}
+}
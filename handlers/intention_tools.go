@@ -0,0 +1,77 @@
+// handlers/intention_tools.go
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+)
+
+// newIntentionTools registers the tools the intention model may call, served
+// from the session's robot state and clock.
+func newIntentionTools(session *RoboSession) *utils.ToolRegistry {
+	tools := utils.NewToolRegistry()
+
+	tools.Register(utils.IntentionTool{
+		Name:        "get_robot_state",
+		Description: "Current robot state (location, position, battery, task) and earlier reported states, most recent first",
+		Handler: func(ctx context.Context, arguments json.RawMessage) (interface{}, error) {
+			return session.RobotState.Snapshot(), nil
+		},
+	})
+
+	tools.Register(utils.IntentionTool{
+		Name:        "get_map_locations",
+		Description: "Named locations on the robot's map, such as rooms and the charging dock",
+		Handler: func(ctx context.Context, arguments json.RawMessage) (interface{}, error) {
+			return map[string]interface{}{
+				"locations": session.RobotState.Locations(),
+			}, nil
+		},
+	})
+
+	tools.Register(utils.IntentionTool{
+		Name:        "get_time",
+		Description: "Current local date and time at the robot",
+		Handler: func(ctx context.Context, arguments json.RawMessage) (interface{}, error) {
+			now := time.Now().In(session.RobotState.Timezone())
+			return map[string]interface{}{
+				"now":      now.Format(time.RFC3339),
+				"weekday":  now.Weekday().String(),
+				"timezone": now.Location().String(),
+			}, nil
+		},
+	})
+
+	if session.SiteMemory != nil {
+		tools.Register(utils.IntentionTool{
+			Name:        "get_site_memory",
+			Description: "What the robots at this site last observed at a location (such as \"kitchen\" or \"loading dock\"), or at every location when none is given",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"location": map[string]interface{}{
+						"type":        "string",
+						"description": "Location tag, as in the robot state",
+					},
+				},
+			},
+			Handler: func(ctx context.Context, arguments json.RawMessage) (interface{}, error) {
+				var args struct {
+					Location string `json:"location"`
+				}
+				if len(arguments) > 0 {
+					if err := json.Unmarshal(arguments, &args); err != nil {
+						return nil, err
+					}
+				}
+				return session.SiteMemory.Lookup(ctx, args.Location)
+			},
+		})
+	}
+
+	return tools
+}
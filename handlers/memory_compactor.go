@@ -0,0 +1,133 @@
+// handlers/memory_compactor.go
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+	"go.uber.org/zap"
+)
+
+// MemoryCompactor periodically folds a session's environment contexts into a
+// rolling world state document and prunes the superseded scene vectors.
+type MemoryCompactor struct {
+	session      *RoboSession
+	openaiClient *utils.OpenAIClient
+	pineconeIdx  *utils.PineconeIndex
+
+	interval   time.Duration
+	keepRecent int
+
+	// mu serializes compactions and guards the state they carry over
+	mu            sync.Mutex
+	worldState    string
+	lastCompacted time.Time
+}
+
+func StartMemoryCompactor(session *RoboSession, openaiClient *utils.OpenAIClient, pineconeIdx *utils.PineconeIndex) *MemoryCompactor {
+	compactor := &MemoryCompactor{
+		session:      session,
+		openaiClient: openaiClient,
+		pineconeIdx:  pineconeIdx,
+		interval:     session.Config.MemoryCompactionInterval,
+		keepRecent:   session.Config.MemoryCompactionKeepRecent,
+	}
+	if compactor.interval <= 0 {
+		return nil
+	}
+
+	session.schedule("memory_compaction", compactor.interval, func(ctx context.Context) error {
+		if err := compactor.Compact(ctx); err != nil {
+			return fmt.Errorf("memory compaction failed: %w", err)
+		}
+		return nil
+	})
+	return compactor
+}
+
+// Compact summarizes every context archived since the last run. All but the
+// keepRecent newest contexts are then deleted from Pinecone, their content
+// living on in the world state record. It gives up when ctx or the
+// session ends.
+func (c *MemoryCompactor) Compact(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+	stop := context.AfterFunc(c.session.sessionCtx, cancel)
+	defer stop()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	records, err := utils.LoadSessionAnalyses(ctx, c.session.RedisClient, c.session.ID)
+	if err != nil {
+		return err
+	}
+
+	var contexts []models.EnvironmentContext
+	for _, record := range records {
+		if record.Kind != models.ANALYSIS_KIND_VISION || !record.Timestamp.After(c.lastCompacted) {
+			continue
+		}
+		var envContext models.EnvironmentContext
+		if err := json.Unmarshal(record.Result, &envContext); err != nil {
+			continue
+		}
+		contexts = append(contexts, envContext)
+	}
+	if len(contexts) == 0 {
+		return nil
+	}
+
+	summary, err := c.openaiClient.SummarizeEnvironment(ctx, c.worldState, contexts)
+	if err != nil {
+		return fmt.Errorf("failed to summarize environment: %w", err)
+	}
+	c.worldState = summary
+	c.lastCompacted = contexts[len(contexts)-1].Timestamp
+
+	if err := utils.SaveWorldState(ctx, c.session.RedisClient, c.session.Tenant.ID, c.session.ID, summary); err != nil {
+		c.session.Logger.Warn("Failed to store world state", zap.Error(err))
+	}
+
+	if c.pineconeIdx != nil {
+		metadata := map[string]interface{}{
+			"session_id":     c.session.ID,
+			"timestamp":      time.Now().Unix(),
+			"type":           "world_state",
+			"server_version": utils.Version,
+			"instance_id":    utils.InstanceID(),
+		}
+		idx, err := c.pineconeIdx.Conn()
+		if err != nil {
+			return err
+		}
+		if err := utils.UpsertToPinecone(ctx, idx, c.session.ID+"-world-state", summary, metadata); err != nil {
+			return err
+		}
+
+		var stale []string
+		if len(contexts) > c.keepRecent {
+			for _, envContext := range contexts[:len(contexts)-c.keepRecent] {
+				stale = append(stale, envContext.ID+"-env")
+			}
+		}
+		if err := utils.DeleteFromPinecone(ctx, idx, stale); err != nil {
+			return err
+		}
+		c.session.Logger.Info("Compacted environment memory",
+			zap.Int("contexts", len(contexts)), zap.Int("pruned", len(stale)))
+	}
+
+	c.session.sendWebSocketMessage("world_state", WorldStatePayload{
+		Summary:   summary,
+		Contexts:  len(contexts),
+		Timestamp: time.Now(),
+	})
+	return nil
+}
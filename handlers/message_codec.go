@@ -0,0 +1,73 @@
+// handlers/message_codec.go
+
+package handlers
+
+import (
+	"encoding/json"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/codec"
+	"github.com/gorilla/websocket"
+)
+
+// MessageCodec encodes a session's WebSocket messages: JSON text frames by
+// default, or MessagePack binary frames for clients that negotiated the
+// perceptus.msgpack.v1 subprotocol.
+type MessageCodec interface {
+	Name() string
+	Marshal(msg WebSocketMessage) ([]byte, error)
+	Unmarshal(raw []byte, msg *WebSocketMessage) error
+	// FrameType is the WebSocket message type messages are written as
+	FrameType() int
+}
+
+// codecFor returns the codec of a negotiated subprotocol.
+func codecFor(subprotocol string) MessageCodec {
+	if subprotocol == codec.SUBPROTOCOL_MSGPACK {
+		return msgpackCodec{}
+	}
+	return jsonCodec{}
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string { return "json" }
+
+func (jsonCodec) Marshal(msg WebSocketMessage) ([]byte, error) {
+	return json.Marshal(msg)
+}
+
+func (jsonCodec) Unmarshal(raw []byte, msg *WebSocketMessage) error {
+	return json.Unmarshal(raw, msg)
+}
+
+func (jsonCodec) FrameType() int { return websocket.TextMessage }
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) Name() string { return "msgpack" }
+
+func (msgpackCodec) Marshal(msg WebSocketMessage) ([]byte, error) {
+	return codec.Marshal(msg)
+}
+
+// Unmarshal decodes a message so its data looks decoded from JSON, which
+// is what validation and the handlers expect. Binary audio and frames are
+// kept as []byte.
+func (msgpackCodec) Unmarshal(raw []byte, msg *WebSocketMessage) error {
+	if err := codec.Unmarshal(raw, msg); err != nil {
+		return err
+	}
+	msg.Data = codec.NormalizePayload(msg.Data)
+	return nil
+}
+
+func (msgpackCodec) FrameType() int { return websocket.BinaryMessage }
+
+// writeMessage encodes msg with c and writes it to conn.
+func writeMessage(conn *websocket.Conn, c MessageCodec, msg WebSocketMessage) error {
+	data, err := c.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return conn.WriteMessage(c.FrameType(), data)
+}
@@ -0,0 +1,83 @@
+// handlers/modalities.go
+
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
+)
+
+// Input modalities a client can declare with ?modalities=audio,video at
+// connect. Text is always available.
+const (
+	MODALITY_AUDIO = "audio"
+	MODALITY_VIDEO = "video"
+	MODALITY_TEXT  = "text"
+)
+
+var knownModalities = []string{MODALITY_AUDIO, MODALITY_VIDEO, MODALITY_TEXT}
+
+// messageModalities maps inbound message types to the modality they need.
+var messageModalities = map[string]string{
+	"audio_data":      MODALITY_AUDIO,
+	"video_data":      MODALITY_VIDEO,
+	"depth_data":      MODALITY_VIDEO,
+	"ask_about_scene": MODALITY_VIDEO,
+	"camera_control":  MODALITY_VIDEO,
+}
+
+func defaultModalities() map[string]bool {
+	return map[string]bool{MODALITY_AUDIO: true, MODALITY_VIDEO: true, MODALITY_TEXT: true}
+}
+
+// parseModalities reads the modalities declared on the upgrade request.
+// Without the parameter the session profile's modalities are enabled, or all
+// of them.
+func parseModalities(r *http.Request, profile *models.SessionProfile) (map[string]bool, error) {
+	if !r.URL.Query().Has("modalities") {
+		return profileModalities(profile), nil
+	}
+
+	modalities := map[string]bool{MODALITY_TEXT: true}
+	for _, name := range strings.Split(r.URL.Query().Get("modalities"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if !containsString(knownModalities, name) {
+			return nil, fmt.Errorf("unknown modality %q", name)
+		}
+		modalities[name] = true
+	}
+	return modalities, nil
+}
+
+func (rs *RoboSession) hasModality(modality string) bool {
+	return rs.Modalities[modality]
+}
+
+// capabilities describes the active modality set and profile in the session
+// handshake.
+func (rs *RoboSession) capabilities() Capabilities {
+	var active []string
+	for _, modality := range knownModalities {
+		if rs.hasModality(modality) {
+			active = append(active, modality)
+		}
+	}
+	return Capabilities{Modalities: active, Profile: rs.Profile, Memory: !rs.memoryDisabled, Warmup: rs.Warmup}
+}
+
+// checkModality rejects inbound messages for a modality the session did not
+// declare.
+func (rs *RoboSession) checkModality(msg WebSocketMessage) *ProtocolError {
+	modality, ok := messageModalities[msg.Type]
+	if !ok || rs.hasModality(modality) {
+		return nil
+	}
+	return newProtocolError(PROTOCOL_ERROR_MODALITY_DISABLED, msg.Type, "type",
+		fmt.Sprintf("%s modality is not enabled for this session", modality))
+}
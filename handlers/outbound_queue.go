@@ -0,0 +1,232 @@
+// handlers/outbound_queue.go
+
+package handlers
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+const (
+	OUTBOUND_PRIORITY_NORMAL = iota
+	OUTBOUND_PRIORITY_CONTROL
+	OUTBOUND_PRIORITY_MEDIA
+)
+
+// outboundPriorities classifies outbound messages; unlisted types are normal.
+// Control messages jump the queue, media keeps only the newest message.
+var outboundPriorities = map[string]int{
+	"text":                     OUTBOUND_PRIORITY_CONTROL,
+	"pong":                     OUTBOUND_PRIORITY_CONTROL,
+	"echo_probe_result":        OUTBOUND_PRIORITY_CONTROL,
+	"protocol_error":           OUTBOUND_PRIORITY_CONTROL,
+	"rate_limited":             OUTBOUND_PRIORITY_CONTROL,
+	"config_updated":           OUTBOUND_PRIORITY_CONTROL,
+	"config_applied":           OUTBOUND_PRIORITY_CONTROL,
+	"capture_frequency_update": OUTBOUND_PRIORITY_CONTROL,
+	"stt_status":               OUTBOUND_PRIORITY_CONTROL,
+	"speech_activity":          OUTBOUND_PRIORITY_CONTROL,
+	"power_mode":               OUTBOUND_PRIORITY_CONTROL,
+	"audio_event":              OUTBOUND_PRIORITY_CONTROL,
+	"acoustic_event":           OUTBOUND_PRIORITY_CONTROL,
+	"intention_escalated":      OUTBOUND_PRIORITY_CONTROL,
+	"error":                    OUTBOUND_PRIORITY_CONTROL,
+	"video_frame":              OUTBOUND_PRIORITY_MEDIA,
+}
+
+// sentStamper is implemented by payloads that record when they are written to
+// the socket, e.g. echo_probe_result.
+type sentStamper interface {
+	stampSent(at time.Time)
+}
+
+// OutboundQueue serializes writes to a session's WebSocket. gorilla
+// connections allow one concurrent writer, so every outbound message goes
+// through a single writer goroutine. Control messages are written first;
+// normal messages drop the oldest once the queue is full; a video frame
+// replaces any frame still waiting, since a stale preview is worthless.
+type OutboundQueue struct {
+	conn         *websocket.Conn
+	codec        MessageCodec
+	clock        utils.Clock
+	logger       *zap.Logger
+	onDrop       func(msgType string)
+	limit        int
+	writeTimeout time.Duration
+	compression  CompressionSettings
+
+	mu      sync.Mutex
+	control []WebSocketMessage
+	normal  []WebSocketMessage
+	frame   *WebSocketMessage
+	started bool
+	closed  bool
+	wake    chan struct{}
+	done    chan struct{}
+}
+
+// NewOutboundQueue creates a queue for conn sized by OUTBOUND_QUEUE_SIZE with
+// writes bounded by OUTBOUND_WRITE_TIMEOUT, encoding messages with codec.
+// Messages are held until Start; onDrop, if set, is called with the type of
+// every discarded message.
+func NewOutboundQueue(conn *websocket.Conn, codec MessageCodec, cfg *utils.Config, clock utils.Clock, logger *zap.Logger, onDrop func(msgType string)) *OutboundQueue {
+	return &OutboundQueue{
+		conn:         conn,
+		codec:        codec,
+		clock:        clock,
+		logger:       logger,
+		onDrop:       onDrop,
+		limit:        max(cfg.OutboundQueueSize, 1),
+		writeTimeout: cfg.OutboundWriteTimeout,
+		compression:  wsCompression(cfg),
+		wake:         make(chan struct{}, 1),
+		done:         make(chan struct{}),
+	}
+}
+
+// Start runs the writer goroutine through spawn, e.g. a session's
+// Supervisor.Go, which restarts it after a panic.
+func (q *OutboundQueue) Start(spawn func(name string, worker func())) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.started || q.closed {
+		return
+	}
+	q.started = true
+	spawn("outbound_writer", q.run)
+}
+
+// Enqueue queues msg by its type's priority. It never blocks; messages are
+// discarded once the queue is closed.
+func (q *OutboundQueue) Enqueue(msg WebSocketMessage) {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return
+	}
+
+	var dropped string
+	switch outboundPriorities[msg.Type] {
+	case OUTBOUND_PRIORITY_CONTROL:
+		q.control = append(q.control, msg)
+		if len(q.control) > q.limit {
+			dropped = q.control[0].Type
+			q.control = q.control[1:]
+		}
+	case OUTBOUND_PRIORITY_MEDIA:
+		if q.frame != nil {
+			dropped = q.frame.Type
+		}
+		q.frame = &msg
+	default:
+		q.normal = append(q.normal, msg)
+		if len(q.normal) > q.limit {
+			dropped = q.normal[0].Type
+			q.normal = q.normal[1:]
+		}
+	}
+	q.mu.Unlock()
+
+	if dropped != "" {
+		if q.onDrop != nil {
+			q.onDrop(dropped)
+		}
+		q.logger.Debug("Dropped outbound message", zap.String("type", dropped))
+	}
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Close stops accepting messages and waits up to timeout for the queued ones
+// to be written.
+func (q *OutboundQueue) Close(timeout time.Duration) {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return
+	}
+	q.closed = true
+	started := q.started
+	q.mu.Unlock()
+
+	if !started {
+		return
+	}
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	select {
+	case <-q.done:
+	case <-time.After(timeout):
+		q.logger.Warn("Timed out flushing outbound messages")
+	}
+}
+
+// next pops the highest-priority message. ok is false when the queue is
+// empty; closed reports whether it was closed.
+func (q *OutboundQueue) next() (msg WebSocketMessage, ok bool, closed bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	switch {
+	case len(q.control) > 0:
+		msg, q.control = q.control[0], q.control[1:]
+		return msg, true, q.closed
+	case len(q.normal) > 0:
+		msg, q.normal = q.normal[0], q.normal[1:]
+		return msg, true, q.closed
+	case q.frame != nil:
+		msg, q.frame = *q.frame, nil
+		return msg, true, q.closed
+	}
+	return msg, false, q.closed
+}
+
+// run writes queued messages. done is closed only when it returns normally,
+// so it can be restarted after a panic.
+func (q *OutboundQueue) run() {
+	for {
+		msg, ok, closed := q.next()
+		if !ok {
+			if closed {
+				close(q.done)
+				return
+			}
+			<-q.wake
+			continue
+		}
+
+		// As close to the write as the payload can be changed
+		if stamper, ok := msg.Data.(sentStamper); ok {
+			stamper.stampSent(q.clock.Now())
+		}
+		data, err := q.codec.Marshal(msg)
+		if err != nil {
+			q.logger.Error("failed to encode ws message", zap.String("type", msg.Type), zap.Error(err))
+			continue
+		}
+
+		// Only applies when the client negotiated permessage-deflate
+		q.conn.EnableWriteCompression(q.compression.compressMessage(msg.Type, len(data)))
+		if q.writeTimeout > 0 {
+			q.conn.SetWriteDeadline(time.Now().Add(q.writeTimeout))
+		}
+		if err := q.conn.WriteMessage(q.codec.FrameType(), data); err != nil {
+			// A failed write leaves the connection unusable; the reader
+			// notices and stops the session
+			q.logger.Error("failed to send ws message", zap.String("type", msg.Type), zap.Error(err))
+			q.mu.Lock()
+			q.closed = true
+			q.control, q.normal, q.frame = nil, nil, nil
+			q.mu.Unlock()
+			close(q.done)
+			return
+		}
+	}
+}
@@ -0,0 +1,379 @@
+// handlers/payloads.go
+
+package handlers
+
+import (
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+)
+
+// Payloads of outbound WebSocket messages and orchestrator calls. They are the
+// source of the JSON Schemas served on /schemas, so send these rather than
+// ad-hoc maps.
+
+// SessionStartedPayload is the "text" welcome message sent after the upgrade.
+type SessionStartedPayload struct {
+	SessionID       string       `json:"session_id"`
+	Resumed         bool         `json:"resumed"`
+	Message         string       `json:"message"`
+	ProtocolVersion string       `json:"protocol_version"`
+	Capabilities    Capabilities `json:"capabilities"`
+	Privacy         Privacy      `json:"privacy"`
+	// Metadata echoes the metadata the session was started with
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// Robot is the registered robot, with its calibration and the state
+	// its previous session left
+	Robot     *models.Robot `json:"robot,omitempty"`
+	Timestamp time.Time     `json:"timestamp"`
+}
+
+// Privacy confirms whether the session is incognito and which stores it
+// writes to.
+type Privacy struct {
+	Incognito bool     `json:"incognito"`
+	Source    string   `json:"source,omitempty"` // "client" or "tenant_policy"
+	Persisted []string `json:"persisted"`
+}
+
+// SessionStoppedPayload is the "text" message confirming a client stop.
+type SessionStoppedPayload struct {
+	SessionID string `json:"session_id"`
+	Message   string `json:"message"`
+}
+
+type Capabilities struct {
+	Modalities []string `json:"modalities"`
+	// Profile is the session profile applied at connect; Memory is false
+	// when it turned Pinecone memory off
+	Profile string        `json:"profile,omitempty"`
+	Memory  bool          `json:"memory"`
+	Warmup  *WarmupStatus `json:"warmup,omitempty"`
+}
+
+// WarmupStatus reports the priming pass run before the welcome message, per
+// provider ("ok", "failed" or "timeout").
+type WarmupStatus struct {
+	Providers  map[string]string `json:"providers"`
+	DurationMs int64             `json:"duration_ms"`
+}
+
+type TranscriptPayload struct {
+	Transcript string `json:"transcript"`
+	Source     string `json:"source,omitempty"` // "text" for text_input
+}
+
+// CaptionPayload is a line of the live caption stream. Interim captions
+// grow until the final one replaces them; Language is set on translations.
+type CaptionPayload struct {
+	Text      string    `json:"text"`
+	Final     bool      `json:"final"`
+	Language  string    `json:"language,omitempty"`
+	Source    string    `json:"source,omitempty"` // "text" for text_input
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// CaptionTokenPayload grants a caption viewer access until ExpiresAt.
+type CaptionTokenPayload struct {
+	Token     string    `json:"token"`
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type ConfigUpdatedPayload struct {
+	VideoFrequency string            `json:"video_frequency"`
+	RTSPURL        string            `json:"rtsp_url"`
+	CameraDevice   string            `json:"camera_device,omitempty"`
+	Models         utils.ModelChains `json:"models,omitempty"`
+
+	TranscriptMaxLength    int    `json:"transcript_max_length"`
+	TranscriptFlushAfter   string `json:"transcript_flush_after"`
+	EchoInterimTranscripts bool   `json:"echo_interim_transcripts"`
+
+	VisionROI              *utils.CropRect `json:"vision_roi"`
+	CaptureRequests        bool            `json:"capture_requests"`
+	AdaptiveVideoFrequency bool            `json:"adaptive_video_frequency"`
+	EnvironmentChanges     bool            `json:"environment_changes"`
+
+	InactivityPromptAfter   string `json:"inactivity_prompt_after"`
+	InactivityLowPowerAfter string `json:"inactivity_low_power_after"`
+}
+
+// ConfigAppliedPayload acknowledges a config message. Applied lists the
+// settings that took effect and Reconnected those that needed a new
+// speech-to-text stream; with any, it is sent once the stream is replaced.
+// STTConnected tells whether speech-to-text is connected afterwards.
+type ConfigAppliedPayload struct {
+	Applied      []string `json:"applied"`
+	Reconnected  []string `json:"reconnected"`
+	STTConnected bool     `json:"stt_connected"`
+}
+
+// CaptureRequestPayload asks the robot to send a video_data frame now.
+type CaptureRequestPayload struct {
+	RequestID string `json:"request_id"`
+	Reason    string `json:"reason"`
+}
+
+// SceneAnswerPayload answers an ask_about_scene question. Frames is the
+// number of frames looked at and FrameTime when the latest one arrived.
+type SceneAnswerPayload struct {
+	QuestionID string `json:"question_id"`
+	Question   string `json:"question"`
+	models.SceneAnswer
+	Frames    int       `json:"frames"`
+	FrameTime time.Time `json:"frame_time"`
+}
+
+// CaptureFrequencyUpdatePayload announces the video frequency the server
+// now analyzes frames at, so the robot can capture at the same pace.
+// BudgetUsed is the share of the hourly frame budget used, when one is set.
+type CaptureFrequencyUpdatePayload struct {
+	Frequency     string  `json:"frequency"`
+	BaseFrequency string  `json:"base_frequency"`
+	Reason        string  `json:"reason"`
+	MotionScore   float64 `json:"motion_score,omitempty"`
+	BudgetUsed    float64 `json:"budget_used,omitempty"`
+}
+
+// PromptUserPayload asks the robot to check in with a user who went quiet,
+// saying Text with its text-to-speech when Speak is set. Answers arrive as
+// ordinary audio or text.
+type PromptUserPayload struct {
+	PromptID string `json:"prompt_id"`
+	Text     string `json:"text"`
+	Speak    bool   `json:"speak"`
+	Reason   string `json:"reason"`
+	IdleFor  string `json:"idle_for"`
+}
+
+// PowerModePayload announces that the session entered or left low-power
+// mode. VideoFrequency is the pace frames are analyzed at now, and
+// SpeechGated tells whether only speech is streamed to speech-to-text.
+type PowerModePayload struct {
+	Mode           string `json:"mode"`
+	Reason         string `json:"reason"`
+	IdleFor        string `json:"idle_for,omitempty"`
+	VideoFrequency string `json:"video_frequency,omitempty"`
+	SpeechGated    bool   `json:"speech_gated"`
+}
+
+type RateLimitedPayload struct {
+	MessageType string `json:"message_type"`
+}
+
+type VideoFramePayload struct {
+	ImageB64 string `json:"image_b64"`
+}
+
+// CameraControlPayload is a camera_control message. Requests (get, set)
+// carry the settings to change; the state answering them carries the
+// camera's settings and the error when they could not be applied.
+type CameraControlPayload struct {
+	RequestID string                `json:"request_id,omitempty"`
+	Action    string                `json:"action"`
+	Source    string                `json:"source,omitempty"`
+	Settings  *utils.CameraSettings `json:"settings,omitempty"`
+	Error     string                `json:"error,omitempty"`
+}
+
+type RTSPErrorPayload struct {
+	URL   string `json:"url"`
+	Error string `json:"error"`
+}
+
+type WorldStatePayload struct {
+	Summary   string    `json:"summary"`
+	Contexts  int       `json:"contexts"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// VideoAnnotationsPayload carries labeled boxes for the analyzed frame.
+// Coordinates are normalized to the frame size; FrameWidth and FrameHeight
+// give the pixel size of the analyzed frame when it could be read.
+type VideoAnnotationsPayload struct {
+	ContextID   string          `json:"context_id"`
+	FrameWidth  int             `json:"frame_width,omitempty"`
+	FrameHeight int             `json:"frame_height,omitempty"`
+	Annotations []models.Region `json:"annotations"`
+	Timestamp   time.Time       `json:"timestamp"`
+}
+
+// FrameQualityLowPayload asks the client to recapture a frame that was too
+// blurry or badly exposed to analyze.
+type FrameQualityLowPayload struct {
+	utils.FrameQuality
+	Message string `json:"message"`
+}
+
+// ContextStalePayload warns that no environment context arrived for a while.
+// LastContextAt is omitted when the session never produced one.
+type ContextStalePayload struct {
+	LastContextAt int64  `json:"last_context_at,omitempty"`
+	StaleFor      string `json:"stale_for"`
+}
+
+// STTStatusPayload reports the speech-to-text stream state: "reconnecting"
+// before each attempt, "connected" once audio flows again and "failed" when
+// retries are exhausted. Audio sent while reconnecting is buffered and
+// replayed; DroppedBytes counts audio that overflowed the buffer.
+type STTStatusPayload struct {
+	Status        string `json:"status"`
+	Attempt       int    `json:"attempt,omitempty"`
+	BufferedBytes int    `json:"buffered_bytes,omitempty"`
+	DroppedBytes  int    `json:"dropped_bytes,omitempty"`
+}
+
+// SpeechActivityPayload reports voice activity detection opening (speaking)
+// and closing the gate on the audio sent to speech-to-text.
+type SpeechActivityPayload struct {
+	Speaking  bool  `json:"speaking"`
+	Timestamp int64 `json:"timestamp"`
+}
+
+// AudioEventPayload is an event emitted by a registered audio stage, such
+// as a spotted keyword or a detected alarm.
+type AudioEventPayload struct {
+	Event     string      `json:"event"`
+	Stage     string      `json:"stage"`
+	Data      interface{} `json:"data,omitempty"`
+	Timestamp int64       `json:"timestamp"`
+}
+
+// AcousticEventPayload reports a non-speech sound heard by the robot.
+// Label is the classifier's class (e.g. "Smoke detector, smoke alarm");
+// Notified tells whether the orchestrator was notified.
+type AcousticEventPayload struct {
+	EventID   string  `json:"event_id"`
+	Event     string  `json:"event"`
+	Label     string  `json:"label"`
+	Score     float64 `json:"score"`
+	Notified  bool    `json:"notified"`
+	Timestamp int64   `json:"timestamp"`
+}
+
+// IntentionEscalatedPayload alerts the client that an intention was
+// escalated by an escalation policy because of how it was said.
+type IntentionEscalatedPayload struct {
+	IntentionID   string           `json:"intention_id"`
+	PolicyID      string           `json:"policy_id"`
+	PolicyName    string           `json:"policy_name"`
+	IntentionType string           `json:"intention_type"`
+	Description   string           `json:"description"`
+	Confidence    float64          `json:"confidence"`
+	Sentiment     models.Sentiment `json:"sentiment"`
+	Timestamp     int64            `json:"timestamp"`
+}
+
+// PreferenceLearnedPayload reports a fact added to the user's (or robot's)
+// long-term memory; Replaces lists the preferences it superseded.
+type PreferenceLearnedPayload struct {
+	ID       string   `json:"id"`
+	Subject  string   `json:"subject"`
+	Fact     string   `json:"fact"`
+	Category string   `json:"category,omitempty"`
+	Replaces []string `json:"replaces,omitempty"`
+}
+
+// IntentionConfirmationPayload asks the robot to speak Question and wait for
+// a yes/no answer before the intention is acted on.
+type IntentionConfirmationPayload struct {
+	IntentionID   string                 `json:"intention_id"`
+	Question      string                 `json:"question"`
+	IntentionType string                 `json:"intention_type"`
+	Description   string                 `json:"description"`
+	Confidence    float64                `json:"confidence"`
+	Slots         map[string]interface{} `json:"slots,omitempty"`
+	ExpiresAt     time.Time              `json:"expires_at"`
+}
+
+// IntentionConfirmationResultPayload reports how a confirmation question was
+// answered: "confirmed", "rejected", "corrected" or "expired".
+type IntentionConfirmationResultPayload struct {
+	IntentionID string `json:"intention_id"`
+	Outcome     string `json:"outcome"`
+	Transcript  string `json:"transcript,omitempty"`
+}
+
+// OrchestratorIntentionPayload is posted to /orchestrate for a detected intention.
+type OrchestratorIntentionPayload struct {
+	IntentionID        string                 `json:"intention_id"`
+	SessionID          string                 `json:"session_id"`
+	TenantID           string                 `json:"tenant_id"`
+	IntentionType      string                 `json:"intention_type"`
+	Description        string                 `json:"description"`
+	Confidence         float64                `json:"confidence"`
+	Slots              map[string]interface{} `json:"slots"`
+	Source             string                 `json:"source"` // "model", "grammar" or "simulated"
+	Transcript         string                 `json:"transcript"`
+	EnvironmentContext string                 `json:"environment_context"`
+	Timestamp          int64                  `json:"timestamp"`
+	Worker             models.WorkerInfo      `json:"worker"`
+	Incognito          bool                   `json:"incognito,omitempty"`
+	// True when the user confirmed the intention by voice first
+	Confirmed bool `json:"confirmed,omitempty"`
+	// How the transcript was said and, when that escalated the intention
+	// regardless of its confidence, the policy that did
+	Sentiment  *models.Sentiment  `json:"sentiment,omitempty"`
+	Escalation *models.Escalation `json:"escalation,omitempty"`
+	// Metadata the session was started with (robot_id, site, ...)
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// OrchestratorRulePayload is posted to /orchestrate when a trigger rule fires.
+type OrchestratorRulePayload struct {
+	TriggerID          string                    `json:"trigger_id"`
+	SessionID          string                    `json:"session_id"`
+	TenantID           string                    `json:"tenant_id"`
+	TriggerType        string                    `json:"trigger_type"`
+	RuleID             string                    `json:"rule_id"`
+	RuleName           string                    `json:"rule_name"`
+	MatchingSince      int64                     `json:"matching_since"`
+	EnvironmentContext models.EnvironmentContext `json:"environment_context"`
+	Timestamp          int64                     `json:"timestamp"`
+	Worker             models.WorkerInfo         `json:"worker"`
+	Metadata           map[string]string         `json:"metadata,omitempty"`
+}
+
+// OrchestratorAcousticEventPayload is posted to /orchestrate when a
+// safety-relevant sound is heard.
+type OrchestratorAcousticEventPayload struct {
+	TriggerID   string            `json:"trigger_id"`
+	SessionID   string            `json:"session_id"`
+	TenantID    string            `json:"tenant_id"`
+	TriggerType string            `json:"trigger_type"`
+	Event       string            `json:"event"`
+	Label       string            `json:"label"`
+	Score       float64           `json:"score"`
+	Timestamp   int64             `json:"timestamp"`
+	Worker      models.WorkerInfo `json:"worker"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+}
+
+// OrchestratorEnvironmentChangePayload is posted to /orchestrate when an
+// environment change matches a change watch with the orchestrator action.
+type OrchestratorEnvironmentChangePayload struct {
+	TriggerID          string                    `json:"trigger_id"`
+	SessionID          string                    `json:"session_id"`
+	TenantID           string                    `json:"tenant_id"`
+	TriggerType        string                    `json:"trigger_type"`
+	Watches            []string                  `json:"watches"`
+	Changes            []models.SceneChange      `json:"changes"`
+	EnvironmentContext models.EnvironmentContext `json:"environment_context"`
+	Timestamp          int64                     `json:"timestamp"`
+	Worker             models.WorkerInfo         `json:"worker"`
+	Metadata           map[string]string         `json:"metadata,omitempty"`
+}
+
+// ErrorPayload reports a recoverable failure. Category is "client" when the
+// robot sent something unusable and "server" when a provider or the server
+// is unhealthy; Retryable tells whether sending the same input again later
+// may succeed. MessageType names the inbound message that failed, if any.
+type ErrorPayload struct {
+	Code        string `json:"code"`
+	Category    string `json:"category"`
+	Retryable   bool   `json:"retryable"`
+	MessageType string `json:"message_type,omitempty"`
+	Message     string `json:"message"`
+}
@@ -0,0 +1,29 @@
+// handlers/pinecone_writer.go
+
+package handlers
+
+import (
+	"sync"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+)
+
+var (
+	pineconeWriterOnce   sync.Once
+	sharedPineconeWriter *utils.PineconeWriter
+)
+
+// pineconeWriter returns the instance-wide batched Pinecone writer, created
+// on first use so batching settings are read after the configuration is
+// installed.
+func pineconeWriter() *utils.PineconeWriter {
+	pineconeWriterOnce.Do(func() {
+		sharedPineconeWriter = utils.NewPineconeWriterFromConfig(utils.CurrentConfig())
+	})
+	return sharedPineconeWriter
+}
+
+// FlushPineconeWrites flushes queued vector writes on shutdown.
+func FlushPineconeWrites() {
+	pineconeWriter().Close()
+}
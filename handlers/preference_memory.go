@@ -0,0 +1,304 @@
+// handlers/preference_memory.go
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// Known preferences listed in the extraction prompt
+const maxKnownPreferences = 50
+
+// PreferenceMemory learns durable facts about the session's user (or robot)
+// from transcripts and recalls the relevant ones for intention analysis in
+// this and later sessions. Preferences are stored in Redis with their
+// provenance and indexed in a separate Pinecone namespace for recall; a nil
+// PreferenceMemory does nothing.
+type PreferenceMemory struct {
+	session *RoboSession
+	subject string
+	// pineconeIdx is nil without Pinecone; recall then uses the newest
+	// preferences
+	pineconeIdx   *utils.PineconeIndex
+	topK          int
+	minConfidence float64
+	limit         int
+}
+
+// InitPreferenceMemory returns the session's preference memory, or nil when
+// PREFERENCE_MEMORY_ENABLED is off or the client identified neither a user
+// nor a robot.
+func InitPreferenceMemory(session *RoboSession) *PreferenceMemory {
+	subject := utils.PreferenceSubject(session.UserID, session.RobotID)
+	if !session.Config.PreferenceMemoryEnabled || subject == "" || session.RedisClient == nil {
+		return nil
+	}
+
+	memory := &PreferenceMemory{
+		session:       session,
+		subject:       subject,
+		topK:          session.Config.PreferenceTopK,
+		minConfidence: session.Config.PreferenceMinConfidence,
+		limit:         session.Config.PreferenceMaxPerSubject,
+	}
+	index, err := utils.NewPineconeIndex(func() (string, string, string) {
+		tenant := session.credentials()
+		return tenant.PineconeAPIKey, tenant.PineconeHost, utils.PreferenceNamespace(tenant)
+	})
+	if err != nil {
+		session.Logger.Warn("Preference memory without Pinecone, recalling newest preferences", zap.Error(err))
+	} else {
+		memory.pineconeIdx = index
+	}
+	session.Logger.Info("Preference memory enabled", zap.String("subject", subject))
+	return memory
+}
+
+// Learn extracts durable facts from a transcript and stores them. Incognito
+// sessions recall preferences but never add any.
+func (m *PreferenceMemory) Learn(transcript string) {
+	if m == nil || m.session.Incognito {
+		return
+	}
+	rs := m.session
+	ctx, cancel := context.WithTimeout(rs.sessionCtx, 30*time.Second)
+	defer cancel()
+
+	tenantID := rs.Tenant.ID
+	known, err := utils.LoadPreferences(ctx, rs.RedisClient, tenantID, m.subject)
+	if err != nil {
+		rs.Logger.Warn("Failed to load preferences", zap.Error(err))
+		return
+	}
+	extracted, err := rs.openAIClient().ExtractPreferences(ctx, transcript, known[:min(len(known), maxKnownPreferences)])
+	if cancelled(ctx) {
+		return
+	}
+	if err != nil {
+		rs.Logger.Warn("Failed to extract preferences", zap.Error(err))
+		rs.MetricLabels.ProviderError("openai")
+		return
+	}
+
+	knownIDs := make(map[string]bool, len(known))
+	for _, preference := range known {
+		knownIDs[preference.ID] = true
+	}
+	var learned []models.Preference
+	var replaced []string
+	for _, candidate := range extracted {
+		fact := strings.TrimSpace(candidate.Fact)
+		if fact == "" || candidate.Confidence < m.minConfidence {
+			continue
+		}
+		learned = append(learned, models.Preference{
+			ID:         utils.PreferenceID(m.subject, fact),
+			Subject:    m.subject,
+			Fact:       fact,
+			Category:   candidate.Category,
+			Confidence: candidate.Confidence,
+			SessionID:  rs.ID,
+			Utterance:  transcript,
+			LearnedAt:  rs.Clock.Now(),
+		})
+		for _, id := range candidate.Replaces {
+			if knownIDs[id] {
+				replaced = append(replaced, id)
+			}
+		}
+	}
+	if len(learned) == 0 {
+		return
+	}
+
+	removed, err := utils.SavePreferences(ctx, rs.RedisClient, tenantID, m.subject, learned, replaced, m.limit)
+	if err != nil {
+		rs.Logger.Error("Failed to save preferences", zap.Error(err))
+		return
+	}
+	m.index(learned, removed)
+
+	for _, preference := range learned {
+		rs.Logger.Info("Preference learned",
+			zap.String("preference_id", preference.ID),
+			zap.String("category", preference.Category),
+			zap.String("text", preference.Fact))
+		rs.sendWebSocketMessage("preference_learned", PreferenceLearnedPayload{
+			ID:       preference.ID,
+			Subject:  preference.Subject,
+			Fact:     preference.Fact,
+			Category: preference.Category,
+			Replaces: replaced,
+		})
+	}
+}
+
+// Recall returns the preferences most relevant to a transcript, or the
+// newest ones when the memory store cannot be searched.
+func (m *PreferenceMemory) Recall(ctx context.Context, transcript string) []string {
+	if m == nil {
+		return nil
+	}
+	rs := m.session
+	if m.pineconeIdx != nil {
+		facts, err := m.search(ctx, transcript)
+		if err == nil {
+			return facts
+		}
+		rs.Logger.Warn("Failed to search preferences, using newest", zap.Error(err))
+		rs.MetricLabels.ProviderError("pinecone")
+	}
+
+	preferences, err := utils.LoadPreferences(ctx, rs.RedisClient, rs.Tenant.ID, m.subject)
+	if err != nil {
+		rs.Logger.Warn("Failed to load preferences", zap.Error(err))
+		return nil
+	}
+	facts := make([]string, 0, m.topK)
+	for _, preference := range preferences[:min(len(preferences), m.topK)] {
+		facts = append(facts, preference.Fact)
+	}
+	return facts
+}
+
+func (m *PreferenceMemory) search(ctx context.Context, transcript string) ([]string, error) {
+	idx, err := m.pineconeIdx.Conn()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, m.session.Config.PineconeQueryTimeout)
+	defer cancel()
+	return utils.FetchResponseFromPinecone(ctx, idx, utils.PineconeQuery{
+		Text:    transcript,
+		TopK:    m.topK,
+		Subject: m.subject,
+		Types:   []string{utils.PREFERENCE_RECORD_TYPE},
+	})
+}
+
+// index queues learned preferences for the memory store and deletes the
+// ones dropped from Redis.
+func (m *PreferenceMemory) index(learned []models.Preference, removed []string) {
+	if m.pineconeIdx == nil {
+		return
+	}
+	rs := m.session
+	for _, preference := range learned {
+		vectorID := preference.ID
+		pineconeWriter().Enqueue(utils.PineconeWrite{
+			Index: m.pineconeIdx,
+			ID:    vectorID,
+			Text:  preference.Fact,
+			Metadata: map[string]interface{}{
+				"type":          utils.PREFERENCE_RECORD_TYPE,
+				"subject":       preference.Subject,
+				"fact_category": preference.Category,
+				"session_id":    preference.SessionID,
+				"timestamp":     preference.LearnedAt.Unix(),
+				"preference_id": preference.ID,
+			},
+			OnFailure: func(err error) {
+				rs.Logger.Error("Failed to upsert preference to Pinecone", zap.Error(err), zap.String("vector_id", vectorID))
+				rs.MetricLabels.ProviderError("pinecone")
+			},
+		})
+	}
+	if len(removed) == 0 {
+		return
+	}
+	idx, err := m.pineconeIdx.Conn()
+	if err == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		err = utils.DeleteFromPinecone(ctx, idx, removed)
+	}
+	if err != nil {
+		rs.Logger.Warn("Failed to delete superseded preferences from Pinecone", zap.Strings("ids", removed), zap.Error(err))
+	}
+}
+
+// preferenceSubject reads the user_id or robot_id query parameter of the
+// preference endpoints.
+func preferenceSubject(r *http.Request) string {
+	query := r.URL.Query()
+	return utils.PreferenceSubject(query.Get("user_id"), query.Get("robot_id"))
+}
+
+// HandlePreferences lists the preferences remembered for a user or robot,
+// newest first: GET /robot/preferences?user_id=... (or robot_id=...)
+func HandlePreferences(w http.ResponseWriter, r *http.Request, redisClient *redis.Client, tenants *utils.TenantStore) {
+	tenant, err := tenants.Resolve(r)
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	subject := preferenceSubject(r)
+	if subject == "" {
+		http.Error(w, "user_id or robot_id required", http.StatusBadRequest)
+		return
+	}
+
+	preferences, err := utils.LoadPreferences(r.Context(), redisClient, tenant.ID, subject)
+	if err != nil {
+		zap.L().Error("Failed to load preferences", zap.String("tenant_id", tenant.ID), zap.Error(err))
+		http.Error(w, "failed to load preferences", http.StatusInternalServerError)
+		return
+	}
+	if preferences == nil {
+		preferences = []models.Preference{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"subject":     subject,
+		"preferences": preferences,
+	})
+}
+
+// HandleDeletePreference forgets one preference:
+// DELETE /robot/preferences/{id}?user_id=... (or robot_id=...)
+func HandleDeletePreference(w http.ResponseWriter, r *http.Request, redisClient *redis.Client, tenants *utils.TenantStore) {
+	tenant, err := tenants.Resolve(r)
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	subject := preferenceSubject(r)
+	if subject == "" {
+		http.Error(w, "user_id or robot_id required", http.StatusBadRequest)
+		return
+	}
+
+	id := r.PathValue("id")
+	deleted, err := utils.DeletePreference(r.Context(), redisClient, tenant.ID, subject, id)
+	if err != nil {
+		zap.L().Error("Failed to delete preference", zap.String("tenant_id", tenant.ID), zap.Error(err))
+		http.Error(w, "failed to delete preference", http.StatusInternalServerError)
+		return
+	}
+	if !deleted {
+		http.Error(w, "preference not found", http.StatusNotFound)
+		return
+	}
+
+	if tenant.PineconeAPIKey != "" && tenant.PineconeHost != "" {
+		idx, err := utils.SharedPineconeIndex(tenant.PineconeAPIKey, tenant.PineconeHost, utils.PreferenceNamespace(tenant))
+		if err == nil {
+			err = utils.DeleteFromPinecone(r.Context(), idx, []string{id})
+		}
+		if err != nil {
+			// Recall only searches; the fact is gone from the store of record
+			zap.L().Warn("Failed to delete preference from Pinecone", zap.String("preference_id", id), zap.Error(err))
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
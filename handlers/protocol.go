@@ -0,0 +1,298 @@
+// handlers/protocol.go
+
+package handlers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+)
+
+// PROTOCOL_VERSION is the WebSocket protocol version spoken by this server.
+// Clients may send "version" on any message; only the major version must match.
+const PROTOCOL_VERSION = "1.0"
+
+func init() {
+	utils.SetComponentVersion("protocol", PROTOCOL_VERSION)
+}
+
+const (
+	PROTOCOL_ERROR_INVALID_JSON        = "E_INVALID_JSON"
+	PROTOCOL_ERROR_UNKNOWN_TYPE        = "E_UNKNOWN_TYPE"
+	PROTOCOL_ERROR_INVALID_PAYLOAD     = "E_INVALID_PAYLOAD"
+	PROTOCOL_ERROR_UNSUPPORTED_VERSION = "E_UNSUPPORTED_VERSION"
+	PROTOCOL_ERROR_MODALITY_DISABLED   = "E_MODALITY_DISABLED"
+	PROTOCOL_ERROR_MESSAGE_TOO_LARGE   = "E_MESSAGE_TOO_LARGE"
+)
+
+// ProtocolError is sent back to the client as a protocol_error message.
+type ProtocolError struct {
+	Code        string `json:"code"`
+	Message     string `json:"message"`
+	MessageType string `json:"message_type,omitempty"`
+	Field       string `json:"field,omitempty"`
+	Version     string `json:"supported_version"`
+}
+
+// FieldSchema describes one field of an object payload using JSON Schema types
+// (string, number, integer, boolean, array, object).
+type FieldSchema struct {
+	Type        string   `json:"type"`
+	Required    bool     `json:"-"`
+	Enum        []string `json:"enum,omitempty"`
+	Description string   `json:"description,omitempty"`
+}
+
+// MessageSchema describes the data payload of an inbound message type.
+// DataType is "object", "string" or "" for messages without data.
+type MessageSchema struct {
+	Type        string
+	Version     string
+	Description string
+	DataType    string
+	Fields      map[string]FieldSchema
+}
+
+var inboundSchemas = map[string]MessageSchema{
+	"config": {
+		Type:        "config",
+		Version:     PROTOCOL_VERSION,
+		Description: "Update session configuration",
+		DataType:    "object",
+		Fields: map[string]FieldSchema{
+			"video_frequency": {Type: "string", Description: "Go duration, e.g. 30s"},
+			"rtsp_url":        {Type: "string", Description: "RTSP or HTTP MJPEG stream to ingest, empty to stop"},
+			"camera_device":   {Type: "string", Description: "Camera attached to the server to ingest, by ID or name from GET /robot/cameras, empty to stop; requires SERVER_CAMERAS_ENABLED"},
+			"models":          {Type: "object", Description: "Model or fallback chain per task: intention, vision, summarization, embedding, stt"},
+
+			"transcript_max_length":    {Type: "integer", Description: "Flush the transcript buffer at this many characters"},
+			"transcript_flush_after":   {Type: "string", Description: "Flush this long after the first segment without UtteranceEnd, 0 disables"},
+			"echo_interim_transcripts": {Type: "boolean", Description: "Send transcript_interim while accumulating"},
+
+			"stt_endpointing_ms":   {Type: "integer", Description: "Silence in ms before Deepgram finalizes a segment, 0 disables"},
+			"stt_utterance_end_ms": {Type: "integer", Description: "Word gap in ms before UtteranceEnd (0 or at least 1000), 0 ends speech on endpointing"},
+			"stt_interim_results":  {Type: "boolean", Description: "Request interim results from Deepgram, required by UtteranceEnd"},
+			"stt_filler_words":     {Type: "boolean", Description: "Transcribe filler words such as uh and um"},
+			"stt_smart_format":     {Type: "boolean", Description: "Format numbers, dates and punctuation"},
+			"stt_keywords":         {Type: "array", Description: "Boosted words for nova-2 and older, e.g. [\"Perceptus:2\"]"},
+			"stt_keyterms":         {Type: "array", Description: "Key terms prompted to nova-3 models"},
+			"stt_scene_boost":      {Type: "boolean", Description: "Boost key elements seen by video analysis in speech-to-text"},
+			"stt_language":         {Type: "string", Description: "Spoken language code, e.g. en or de, or multi; reconnects speech-to-text"},
+
+			"audio_encoding":    {Type: "string", Description: "Encoding of audio_data: linear16, opus, aac, or empty for containers; reconnects speech-to-text"},
+			"audio_sample_rate": {Type: "integer", Description: "Sample rate of audio_data in Hz; reconnects speech-to-text"},
+
+			"capture_requests":         {Type: "boolean", Description: "Send capture_request messages every video_frequency instead of waiting for pushed frames"},
+			"adaptive_video_frequency": {Type: "boolean", Description: "Adapt the video frequency to scene motion, activities and the frame budget, announced with capture_frequency_update"},
+			"environment_changes":      {Type: "boolean", Description: "Send environment_change when key elements or activities appear or disappear, not only for change watches"},
+
+			"inactivity_prompt_after":    {Type: "string", Description: "Send prompt_user after this long without speech or an intention, 0 disables"},
+			"inactivity_prompt":          {Type: "string", Description: "Text of the inactivity prompt, empty disables it"},
+			"inactivity_prompt_speak":    {Type: "boolean", Description: "Ask the robot to say the inactivity prompt with its text-to-speech"},
+			"inactivity_low_power_after": {Type: "string", Description: "Enter low-power mode after this long without speech or an intention, announced with power_mode, 0 disables"},
+
+			"vision_roi": {Type: "object", Description: "Normalized region {x, y, width, height} cropped from frames before analysis, null for the default"},
+		},
+	},
+	"audio_data": {
+		Type:        "audio_data",
+		Version:     PROTOCOL_VERSION,
+		Description: "Base64-encoded audio chunk, or raw bytes over MessagePack",
+		DataType:    "string",
+	},
+	"video_data": {
+		Type:        "video_data",
+		Version:     PROTOCOL_VERSION,
+		Description: "Base64-encoded JPEG frame or data URL, or raw image bytes over MessagePack",
+		DataType:    "string",
+	},
+	"depth_data": {
+		Type:        "depth_data",
+		Version:     PROTOCOL_VERSION,
+		Description: "Depth map registered to the RGB camera, paired with the next video_data frame",
+		DataType:    "object",
+		Fields: map[string]FieldSchema{
+			"data":     {Type: "string", Required: true, Description: "Base64-encoded depth map"},
+			"encoding": {Type: "string", Required: true, Enum: []string{utils.DEPTH_ENCODING_PNG, utils.DEPTH_ENCODING_ZSTD}, Description: "16-bit grayscale PNG or zstd-compressed little-endian uint16"},
+			"width":    {Type: "integer", Description: "Map width, required for zstd"},
+			"height":   {Type: "integer", Description: "Map height, required for zstd"},
+			"scale":    {Type: "number", Description: "Meters per unit, default 0.001 (millimeters)"},
+		},
+	},
+	"text_input": {
+		Type:        "text_input",
+		Version:     PROTOCOL_VERSION,
+		Description: "Typed command, fed to intention analysis as a final transcript",
+		DataType:    "object",
+		Fields: map[string]FieldSchema{
+			"text": {Type: "string", Required: true},
+		},
+	},
+	"robot_state": {
+		Type:        "robot_state",
+		Version:     PROTOCOL_VERSION,
+		Description: "Robot state report, served to the intention model's tools",
+		DataType:    "object",
+		Fields: map[string]FieldSchema{
+			"location":  {Type: "string", Description: "Name of the current map location"},
+			"position":  {Type: "object", Description: "Map coordinates, e.g. {x, y, theta}"},
+			"battery":   {Type: "number", Description: "Battery level between 0 and 1"},
+			"locations": {Type: "array", Description: "Named map locations"},
+			"timezone":  {Type: "string", Description: "IANA timezone of the robot"},
+		},
+	},
+	"display_ack": {
+		Type:        "display_ack",
+		Version:     PROTOCOL_VERSION,
+		Description: "Acknowledge display content",
+		DataType:    "object",
+		Fields: map[string]FieldSchema{
+			"display_id":      {Type: "string", Required: true},
+			"status":          {Type: "string", Required: true, Enum: []string{"shown", "dismissed", "selected", "error"}},
+			"selected_option": {Type: "string"},
+			"error":           {Type: "string"},
+		},
+	},
+	"echo_probe": {
+		Type:        "echo_probe",
+		Version:     PROTOCOL_VERSION,
+		Description: "Latency probe, answered with echo_probe_result",
+		DataType:    "object",
+		Fields: map[string]FieldSchema{
+			"probe_id":       {Type: "string", Required: true},
+			"client_sent_at": {Type: "number", Description: "Client clock, Unix milliseconds"},
+		},
+	},
+	"ask_about_scene": {
+		Type:        "ask_about_scene",
+		Version:     PROTOCOL_VERSION,
+		Description: "Question about what the camera sees now, answered with scene_answer",
+		DataType:    "object",
+		Fields: map[string]FieldSchema{
+			"question":    {Type: "string", Required: true, Description: "e.g. \"is the door open?\""},
+			"question_id": {Type: "string", Description: "Echoed in the answer; generated when missing"},
+			"frames":      {Type: "integer", Description: "Latest frames to look at, 1 to SCENE_QA_MAX_FRAMES (default 1)"},
+		},
+	},
+	"camera_control": {
+		Type:        "camera_control",
+		Version:     PROTOCOL_VERSION,
+		Description: "Get or set the server-side RTSP camera, answered with camera_control state, or report the robot camera's state",
+		DataType:    "object",
+		Fields: map[string]FieldSchema{
+			"action":     {Type: "string", Required: true, Enum: []string{CAMERA_ACTION_GET, CAMERA_ACTION_SET, CAMERA_ACTION_STATE}},
+			"request_id": {Type: "string", Description: "Echoed in the answer; the request_id of the camera_control a state answers"},
+			"source":     {Type: "string", Description: "Ignored; echoed by robots answering a request"},
+			"settings":   {Type: "object", Description: "{width, height, exposure_ms, auto_exposure, zoom, roi: {x, y, width, height}, device}, required for set"},
+			"error":      {Type: "string", Description: "Why the robot could not apply a set request"},
+		},
+	},
+	"ping": {
+		Type:        "ping",
+		Version:     PROTOCOL_VERSION,
+		Description: "Heartbeat, answered with pong",
+	},
+	"stop": {
+		Type:        "stop",
+		Version:     PROTOCOL_VERSION,
+		Description: "End the session",
+	},
+}
+
+func majorVersion(version string) string {
+	major, _, _ := strings.Cut(version, ".")
+	return major
+}
+
+// validateInbound checks an inbound message against its schema.
+func validateInbound(msg WebSocketMessage) *ProtocolError {
+	if msg.Version != "" && majorVersion(msg.Version) != majorVersion(PROTOCOL_VERSION) {
+		return newProtocolError(PROTOCOL_ERROR_UNSUPPORTED_VERSION, msg.Type, "",
+			fmt.Sprintf("protocol version %s is not supported", msg.Version))
+	}
+
+	schema, ok := inboundSchemas[msg.Type]
+	if !ok {
+		return newProtocolError(PROTOCOL_ERROR_UNKNOWN_TYPE, msg.Type, "type",
+			fmt.Sprintf("unknown message type %q", msg.Type))
+	}
+
+	switch schema.DataType {
+	case "string":
+		// MessagePack clients send binary payloads as raw bytes
+		switch msg.Data.(type) {
+		case string, []byte:
+		default:
+			return newProtocolError(PROTOCOL_ERROR_INVALID_PAYLOAD, msg.Type, "data", "data must be a string")
+		}
+	case "object":
+		data, ok := msg.Data.(map[string]interface{})
+		if !ok {
+			return newProtocolError(PROTOCOL_ERROR_INVALID_PAYLOAD, msg.Type, "data", "data must be an object")
+		}
+		for name, field := range schema.Fields {
+			value, exists := data[name]
+			if !exists || value == nil {
+				if field.Required {
+					return newProtocolError(PROTOCOL_ERROR_INVALID_PAYLOAD, msg.Type, "data."+name, "field is required")
+				}
+				continue
+			}
+			if !matchesType(field.Type, value) {
+				return newProtocolError(PROTOCOL_ERROR_INVALID_PAYLOAD, msg.Type, "data."+name,
+					fmt.Sprintf("field must be of type %s", field.Type))
+			}
+			if len(field.Enum) > 0 {
+				if str, _ := value.(string); !containsString(field.Enum, str) {
+					return newProtocolError(PROTOCOL_ERROR_INVALID_PAYLOAD, msg.Type, "data."+name,
+						fmt.Sprintf("field must be one of %s", strings.Join(field.Enum, ", ")))
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func matchesType(schemaType string, value interface{}) bool {
+	switch schemaType {
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "integer":
+		f, ok := value.(float64)
+		return ok && f == float64(int64(f))
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	}
+	return true
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func newProtocolError(code, messageType, field, message string) *ProtocolError {
+	return &ProtocolError{
+		Code:        code,
+		Message:     message,
+		MessageType: messageType,
+		Field:       field,
+		Version:     PROTOCOL_VERSION,
+	}
+}
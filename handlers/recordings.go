@@ -0,0 +1,237 @@
+// handlers/recordings.go
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+var (
+	recordingOnce         sync.Once
+	sharedRecordingConfig *utils.RecordingConfig
+)
+
+// recordingConfig returns the instance-wide recording configuration, nil
+// when RECORDING_ENABLED is off or recording is not possible.
+func recordingConfig() *utils.RecordingConfig {
+	recordingOnce.Do(func() {
+		config, err := utils.RecordingConfigFromConfig(utils.CurrentConfig())
+		if err != nil {
+			zap.L().Error("Video recording disabled", zap.Error(err))
+			return
+		}
+		if config != nil {
+			zap.L().Info("Video recording enabled", zap.String("dir", config.Dir), zap.Duration("retention", config.Retention))
+		}
+		sharedRecordingConfig = config
+	})
+	return sharedRecordingConfig
+}
+
+// recordingFinishTimeout bounds how long a session's teardown waits for its
+// last recording, e.g. when the recorder crashed and was not restarted.
+const recordingFinishTimeout = 30 * time.Second
+
+type recordedFrame struct {
+	imageData string
+	at        time.Time
+}
+
+// SessionRecorder records the frames of a session into MP4 recordings for
+// incident review, starting a new recording every RECORDING_MAX_DURATION.
+// Frames are encoded off the frame path; when the encoder falls behind,
+// frames are dropped from the recording, never from analysis.
+type SessionRecorder struct {
+	session *RoboSession
+	config  *utils.RecordingConfig
+	frames  chan recordedFrame
+	done    chan struct{}
+
+	// Owned by run
+	current   *utils.VideoRecorder
+	currentID string
+	tmpPath   string
+	retryAt   time.Time
+}
+
+// InitSessionRecorder returns the session's recorder, nil when recording is
+// off, the session sends no video or it is incognito.
+func InitSessionRecorder(session *RoboSession) *SessionRecorder {
+	config := recordingConfig()
+	if config == nil || session.Incognito || !session.hasModality(MODALITY_VIDEO) {
+		return nil
+	}
+	r := &SessionRecorder{
+		session: session,
+		config:  config,
+		frames:  make(chan recordedFrame, 32),
+		done:    make(chan struct{}),
+	}
+	session.Supervisor.Go("recorder", r.run)
+	return r
+}
+
+// Add queues a frame for the recording.
+func (r *SessionRecorder) Add(imageData string, at time.Time) {
+	if r == nil {
+		return
+	}
+	select {
+	case r.frames <- recordedFrame{imageData: imageData, at: at}:
+	default:
+		r.session.Logger.Debug("Recorder busy, frame not recorded")
+	}
+}
+
+// Close finishes the current recording. No frame may be added after it.
+func (r *SessionRecorder) Close() {
+	if r == nil {
+		return
+	}
+	close(r.frames)
+	select {
+	case <-r.done:
+	case <-time.After(recordingFinishTimeout):
+		r.session.Logger.Warn("Timed out finishing the recording")
+	}
+}
+
+func (r *SessionRecorder) run() {
+	for frame := range r.frames {
+		if frame.at.Before(r.retryAt) {
+			continue
+		}
+		if r.current != nil && r.current.Duration() >= r.config.MaxDuration {
+			r.finish()
+		}
+		if r.current == nil {
+			r.currentID = r.session.IDs.NewID()
+			r.tmpPath = filepath.Join(r.config.Dir, ".recording", r.currentID+".mp4")
+			r.current = utils.NewVideoRecorder(r.tmpPath, r.config.FPS)
+		}
+
+		if err := r.current.WriteFrame(frame.imageData, frame.at); err != nil {
+			if r.current.Err() == nil {
+				r.session.Logger.Debug("Frame not recorded", zap.Error(err))
+				continue
+			}
+			// Keep what was recorded and try again in a minute
+			r.session.Logger.Warn("Video recording failed", zap.Error(err))
+			r.session.MetricLabels.ProviderError("recording")
+			r.finish()
+			r.retryAt = frame.at.Add(time.Minute)
+		}
+	}
+	r.finish()
+	// Only once drained: after a panic the supervisor restarts run
+	close(r.done)
+}
+
+// finish closes the current recording and saves it.
+func (r *SessionRecorder) finish() {
+	if r.current == nil {
+		return
+	}
+	rs := r.session
+	recording, err := r.current.Close()
+	r.current = nil
+	if err != nil {
+		rs.Logger.Warn("Failed to finish recording", zap.String("recording_id", r.currentID), zap.Error(err))
+		rs.MetricLabels.ProviderError("recording")
+		return
+	}
+	if recording == nil {
+		return
+	}
+
+	recording.ID, recording.SessionID, recording.TenantID = r.currentID, rs.ID, rs.Tenant.ID
+	if r.config.Retention > 0 {
+		recording.ExpiresAt = recording.EndedAt.Add(r.config.Retention)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := utils.SaveRecording(ctx, rs.RedisClient, r.config.Dir, recording, r.tmpPath); err != nil {
+		rs.Logger.Warn("Failed to save recording", zap.String("recording_id", recording.ID), zap.Error(err))
+		rs.MetricLabels.ProviderError("recording")
+		return
+	}
+	rs.Logger.Info("Recording saved",
+		zap.String("recording_id", recording.ID),
+		zap.Int("frames", recording.Frames),
+		zap.Duration("duration", recording.EndedAt.Sub(recording.StartedAt)))
+	rs.sendWebSocketMessage("recording_saved", recording)
+}
+
+// HandleSessionRecordings lists a session's recordings:
+// GET /robot/sessions/{id}/recordings
+func HandleSessionRecordings(w http.ResponseWriter, r *http.Request, redisClient *redis.Client, tenants *utils.TenantStore) {
+	tenant, err := tenants.Resolve(r)
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	sessionID := r.PathValue("id")
+
+	recordings, err := utils.ListSessionRecordings(r.Context(), redisClient, tenant.ID, sessionID)
+	if err != nil {
+		zap.L().Error("Failed to list recordings", zap.String("tenant_id", tenant.ID), zap.String("session_id", sessionID), zap.Error(err))
+		http.Error(w, "failed to list recordings", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"recordings": recordings})
+}
+
+// HandleRecording downloads a recording as MP4, with range requests for
+// seeking: GET /robot/recordings/{id}
+func HandleRecording(w http.ResponseWriter, r *http.Request, redisClient *redis.Client, tenants *utils.TenantStore) {
+	tenant, err := tenants.Resolve(r)
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	config := recordingConfig()
+	if config == nil {
+		http.Error(w, "video recording is not enabled", http.StatusNotFound)
+		return
+	}
+	recordingID := r.PathValue("id")
+
+	recording, err := utils.LoadRecording(r.Context(), redisClient, tenant.ID, recordingID)
+	if errors.Is(err, utils.ErrRecordingNotFound) {
+		http.Error(w, "recording not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		zap.L().Error("Failed to load recording", zap.String("tenant_id", tenant.ID), zap.String("recording_id", recordingID), zap.Error(err))
+		http.Error(w, "failed to load recording", http.StatusInternalServerError)
+		return
+	}
+	video, err := utils.OpenRecording(r.Context(), config.Dir, tenant.ID, recordingID)
+	if errors.Is(err, utils.ErrRecordingNotFound) {
+		http.Error(w, "recording not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		zap.L().Error("Failed to read recording", zap.String("tenant_id", tenant.ID), zap.String("recording_id", recordingID), zap.Error(err))
+		http.Error(w, "failed to read recording", http.StatusInternalServerError)
+		return
+	}
+	defer video.Close()
+
+	w.Header().Set("Content-Type", "video/mp4")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fmt.Sprintf("%s-%d.mp4", recording.SessionID, recording.StartedAt.Unix())))
+	w.Header().Set("Cache-Control", "private, max-age=3600")
+	http.ServeContent(w, r, "", recording.EndedAt, video)
+}
@@ -0,0 +1,132 @@
+// handlers/robot_state.go
+
+package handlers
+
+import (
+	"sync"
+	"time"
+)
+
+const robotStateHistorySize = 10
+
+type robotStateEntry struct {
+	State     map[string]interface{} `json:"state"`
+	UpdatedAt time.Time              `json:"updated_at"`
+}
+
+// RobotState keeps the latest robot_state reported by the client, a short
+// history of earlier reports and the named locations on the robot's map.
+type RobotState struct {
+	mu        sync.RWMutex
+	current   *robotStateEntry
+	history   []robotStateEntry
+	locations []interface{}
+	timezone  *time.Location
+}
+
+func NewRobotState() *RobotState {
+	return &RobotState{}
+}
+
+// Update records a robot_state payload. "locations" and "timezone" are kept
+// separately; every other field is treated as robot state.
+func (s *RobotState) Update(data map[string]interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state := make(map[string]interface{}, len(data))
+	for key, value := range data {
+		switch key {
+		case "locations":
+			if locations, ok := value.([]interface{}); ok {
+				s.locations = locations
+			}
+		case "timezone":
+			if name, ok := value.(string); ok {
+				if location, err := time.LoadLocation(name); err == nil {
+					s.timezone = location
+				}
+			}
+		default:
+			state[key] = value
+		}
+	}
+	if len(state) == 0 {
+		return
+	}
+
+	if s.current != nil {
+		s.history = append([]robotStateEntry{*s.current}, s.history...)
+		if len(s.history) > robotStateHistorySize {
+			s.history = s.history[:robotStateHistorySize]
+		}
+	}
+	s.current = &robotStateEntry{State: state, UpdatedAt: time.Now()}
+}
+
+// Snapshot returns the current state and earlier reports, most recent first.
+func (s *RobotState) Snapshot() map[string]interface{} {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.current == nil {
+		return map[string]interface{}{"known": false}
+	}
+	return map[string]interface{}{
+		"known":    true,
+		"current":  s.current,
+		"previous": append([]robotStateEntry(nil), s.history...),
+	}
+}
+
+func (s *RobotState) Locations() []interface{} {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]interface{}(nil), s.locations...)
+}
+
+// Location returns the location tag of the current state ("kitchen"), ""
+// when the robot has not reported one.
+func (s *RobotState) Location() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.current == nil {
+		return ""
+	}
+	location, _ := s.current.State["location"].(string)
+	return location
+}
+
+// Timezone returns the robot's reported timezone, or the server's.
+func (s *RobotState) Timezone() *time.Location {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.timezone == nil {
+		return time.Local
+	}
+	return s.timezone
+}
+
+// Persisted returns the current state as a robot_state payload, including
+// the locations and timezone, for restoring in a later session with Update.
+// It is nil when nothing was reported.
+func (s *RobotState) Persisted() map[string]interface{} {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	data := make(map[string]interface{})
+	if s.current != nil {
+		for key, value := range s.current.State {
+			data[key] = value
+		}
+	}
+	if len(s.locations) > 0 {
+		data["locations"] = append([]interface{}(nil), s.locations...)
+	}
+	if s.timezone != nil {
+		data["timezone"] = s.timezone.String()
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	return data
+}
@@ -0,0 +1,247 @@
+// handlers/robots.go
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// sessionRobot looks up the robot a session names in its robot_id metadata
+// before the upgrade, so unknown robots can be refused with a plain HTTP
+// error when ROBOT_REGISTRATION_REQUIRED is on. Otherwise unknown robots
+// are registered when the session attaches (nil robot, nil error). A
+// registered site and model fill in what the client did not send.
+func sessionRobot(ctx context.Context, cfg *utils.Config, redisClient *redis.Client, tenant *models.Tenant, metadata map[string]string) (*models.Robot, int, error) {
+	robotID := metadata[METADATA_ROBOT_ID]
+	if robotID == "" || redisClient == nil {
+		return nil, 0, nil
+	}
+	if !utils.ValidRobotID(robotID) {
+		return nil, http.StatusBadRequest, errors.New("invalid robot_id")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	robot, err := utils.LoadRobot(ctx, redisClient, tenant.ID, robotID)
+	if errors.Is(err, utils.ErrRobotNotFound) {
+		if cfg.RobotRegistrationRequired {
+			return nil, http.StatusForbidden, errors.New("robot not registered")
+		}
+		return nil, 0, nil
+	}
+	if err != nil {
+		// The session still runs, only without the robot's history
+		zap.L().Warn("Failed to load robot", zap.String("tenant_id", tenant.ID), zap.String("robot_id", robotID), zap.Error(err))
+		return nil, 0, nil
+	}
+	if metadata[METADATA_SITE] == "" && robot.Site != "" {
+		metadata[METADATA_SITE] = robot.Site
+	}
+	return robot, 0, nil
+}
+
+// attachRobot records the session on its robot, registering robots seen
+// for the first time, and restores the state the robot last reported.
+// Called before the session's goroutines start.
+func (rs *RoboSession) attachRobot(robot *models.Robot) {
+	if rs.RobotID == "" || rs.RedisClient == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(rs.sessionCtx, 2*time.Second)
+	defer cancel()
+
+	now := rs.Clock.Now()
+	attached, err := utils.UpdateRobot(ctx, rs.RedisClient, rs.Tenant.ID, rs.RobotID, true, func(stored *models.Robot) {
+		if stored.RegisteredAt.IsZero() {
+			stored.RegisteredAt, stored.UpdatedAt = now, now
+			stored.Site = rs.Metadata[METADATA_SITE]
+		}
+		stored.LastSessionID, stored.LastSeenAt = rs.ID, now
+	})
+	if err != nil {
+		rs.Logger.Warn("Failed to attach robot", zap.Error(err))
+		return
+	}
+	if robot == nil {
+		rs.Logger.Info("Registered robot on first session")
+	} else {
+		// The previous session's state, not the one just written
+		attached = robot
+	}
+	rs.Robot = attached
+	if len(attached.LastState) > 0 {
+		rs.RobotState.Update(attached.LastState)
+	}
+}
+
+// saveRobot carries the robot's state over to its next session: the last
+// robot_state, where it was and the last scene it described. Incognito
+// sessions only count usage.
+func (rs *RoboSession) saveRobot(at time.Time) {
+	if rs.Robot == nil || rs.Incognito {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	state := rs.RobotState.Persisted()
+	location := rs.RobotState.Location()
+	latest, seen := rs.EnvironmentCache.Latest()
+	_, err := utils.UpdateRobot(ctx, rs.RedisClient, rs.Tenant.ID, rs.RobotID, false, func(robot *models.Robot) {
+		// A newer session of the same robot owns its state
+		if robot.LastSessionID != rs.ID {
+			return
+		}
+		robot.LastSeenAt = at
+		if state != nil {
+			robot.LastState = state
+		}
+		if location != "" {
+			robot.LastLocation = location
+		}
+		if seen {
+			robot.LastEnvironment = &latest
+		}
+	})
+	if err != nil && !errors.Is(err, utils.ErrRobotNotFound) {
+		rs.Logger.Warn("Failed to save robot state", zap.Error(err))
+	}
+}
+
+// recordRobotUsage adds to the robot's cumulative usage. Failures are
+// logged only.
+func (rs *RoboSession) recordRobotUsage(counter string, delta int64) {
+	if rs.Robot == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := utils.IncrementRobotUsage(ctx, rs.RedisClient, rs.Tenant.ID, rs.RobotID, counter, delta); err != nil {
+		rs.Logger.Warn("Failed to increment robot usage", zap.String("counter", counter), zap.Error(err))
+	}
+}
+
+// robotRecall returns what the robot saw at the end of its last session,
+// for intention analysis until it describes a scene in this one.
+func (rs *RoboSession) robotRecall() []string {
+	if rs.Robot == nil || rs.Robot.LastEnvironment == nil {
+		return nil
+	}
+	if _, seen := rs.EnvironmentCache.Latest(); seen {
+		return nil
+	}
+	last := *rs.Robot.LastEnvironment
+	line := "[robot's last session"
+	if !last.Timestamp.IsZero() {
+		line += ", observed " + rs.Clock.Since(last.Timestamp).Round(time.Minute).String() + " ago"
+	}
+	if last.Location != "" {
+		line += " in " + last.Location
+	}
+	return []string{line + "] " + formatEnvironmentContext(last)}
+}
+
+// HandleRobots lists the caller's robots (GET /robot/robots) or registers
+// one (POST /robot/robots).
+func HandleRobots(w http.ResponseWriter, r *http.Request, redisClient *redis.Client, tenants *utils.TenantStore) {
+	tenant, err := tenants.Resolve(r)
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		var registration models.RobotRegistration
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&registration); err != nil {
+			http.Error(w, "invalid robot registration", http.StatusBadRequest)
+			return
+		}
+		registerRobot(w, r, redisClient, tenant, registration)
+		return
+	}
+
+	robots, err := utils.ListRobots(r.Context(), redisClient, tenant.ID)
+	if err != nil {
+		zap.L().Error("Failed to list robots", zap.String("tenant_id", tenant.ID), zap.Error(err))
+		http.Error(w, "failed to list robots", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"robots": robots})
+}
+
+// HandleRobot reads (GET), updates the registration of (PUT) or removes
+// (DELETE) one robot: /robot/robots/{id}
+func HandleRobot(w http.ResponseWriter, r *http.Request, redisClient *redis.Client, tenants *utils.TenantStore) {
+	tenant, err := tenants.Resolve(r)
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	robotID := r.PathValue("id")
+
+	switch r.Method {
+	case http.MethodPut:
+		var registration models.RobotRegistration
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&registration); err != nil {
+			http.Error(w, "invalid robot registration", http.StatusBadRequest)
+			return
+		}
+		registration.ID = robotID
+		registerRobot(w, r, redisClient, tenant, registration)
+
+	case http.MethodDelete:
+		deleted, err := utils.DeleteRobot(r.Context(), redisClient, tenant.ID, robotID)
+		if err != nil {
+			zap.L().Error("Failed to delete robot", zap.String("tenant_id", tenant.ID), zap.String("robot_id", robotID), zap.Error(err))
+			http.Error(w, "failed to delete robot", http.StatusInternalServerError)
+			return
+		}
+		if !deleted {
+			http.Error(w, "robot not found", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		robot, err := utils.LoadRobot(r.Context(), redisClient, tenant.ID, robotID)
+		if errors.Is(err, utils.ErrRobotNotFound) {
+			http.Error(w, "robot not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			zap.L().Error("Failed to load robot", zap.String("tenant_id", tenant.ID), zap.String("robot_id", robotID), zap.Error(err))
+			http.Error(w, "failed to load robot", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(robot)
+	}
+}
+
+func registerRobot(w http.ResponseWriter, r *http.Request, redisClient *redis.Client, tenant *models.Tenant, registration models.RobotRegistration) {
+	if !utils.ValidRobotID(registration.ID) {
+		http.Error(w, "invalid robot id", http.StatusBadRequest)
+		return
+	}
+	robot, created, err := utils.RegisterRobot(r.Context(), redisClient, tenant.ID, registration, time.Now())
+	if err != nil {
+		zap.L().Error("Failed to register robot", zap.String("tenant_id", tenant.ID), zap.String("robot_id", registration.ID), zap.Error(err))
+		http.Error(w, "failed to register robot", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if created {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(robot)
+}
@@ -0,0 +1,125 @@
+// handlers/rtsp_ingester.go
+
+package handlers
+
+import (
+	"context"
+	"encoding/base64"
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+	"go.uber.org/zap"
+)
+
+// RTSPIngester pulls frames from an RTSP or MJPEG camera, or a camera
+// attached to the server, at the session's effective video frequency and
+// feeds them into the video analysis pipeline.
+type RTSPIngester struct {
+	session *RoboSession
+	// url is set for streams, device for local cameras
+	url     string
+	device  string
+	capture utils.CameraCapture
+	cancel  context.CancelFunc
+}
+
+func StartRTSPIngester(session *RoboSession, url string) (*RTSPIngester, error) {
+	ctx, cancel := context.WithTimeout(session.sessionCtx, 15*time.Second)
+	defer cancel()
+	capture, err := utils.OpenStreamCamera(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	return startIngester(session, capture, url, ""), nil
+}
+
+// StartDeviceIngester captures from a camera attached to the machine
+// running the server, given by ID or name as listed by GET /robot/cameras.
+func StartDeviceIngester(session *RoboSession, device string) (*RTSPIngester, error) {
+	ctx, cancel := context.WithTimeout(session.sessionCtx, 15*time.Second)
+	defer cancel()
+	capture, err := utils.OpenDeviceCamera(ctx, device)
+	if err != nil {
+		return nil, err
+	}
+	if id := capture.CameraSettings().Device; id != "" {
+		device = id
+	}
+	return startIngester(session, capture, "", device), nil
+}
+
+func startIngester(session *RoboSession, capture utils.CameraCapture, url, device string) *RTSPIngester {
+	ctx, cancel := context.WithCancel(context.Background())
+	ingester := &RTSPIngester{
+		session: session,
+		url:     url,
+		device:  device,
+		capture: capture,
+		cancel:  cancel,
+	}
+
+	session.Logger.Info("Starting camera ingest", ingester.sourceField())
+	session.Supervisor.Go("camera_ingest", func() { ingester.run(ctx) })
+
+	return ingester
+}
+
+func (i *RTSPIngester) sourceField() zap.Field {
+	if i.url != "" {
+		return zap.String("url", i.url)
+	}
+	return zap.String("device", i.device)
+}
+
+func (i *RTSPIngester) run(ctx context.Context) {
+	code, label := ERROR_CODE_RTSP_FAILED, "RTSP capture failed"
+	if i.url == "" {
+		code, label = ERROR_CODE_CAMERA_FAILED, "Camera capture failed"
+	}
+	for {
+		// Re-read the frequency every cycle so config updates and
+		// adaptation apply immediately
+		frequency := i.session.AdaptiveFrequency.Interval()
+		resumed := i.session.AdaptiveFrequency.Resumed()
+
+		captureCtx, cancel := context.WithTimeout(ctx, 15*time.Second)
+		frame, err := i.capture.CaptureFrame(captureCtx)
+		cancel()
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			i.session.Logger.Warn(label, i.sourceField(), zap.Error(err))
+			i.session.sendError(code, "", label+": "+err.Error())
+		} else if ctx.Err() == nil {
+			i.session.submitFrame("data:image/jpeg;base64,"+base64.StdEncoding.EncodeToString(frame), "")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-i.session.Clock.After(frequency):
+		case <-resumed:
+		}
+	}
+}
+
+// CaptureNow grabs a frame outside the ingest pace and submits it for
+// analysis whatever the adapted frequency.
+func (i *RTSPIngester) CaptureNow(ctx context.Context) error {
+	captureCtx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+	frame, err := i.capture.CaptureFrame(captureCtx)
+	if err != nil {
+		return err
+	}
+	i.session.AdaptiveFrequency.ExpectFrame()
+	i.session.submitFrame("data:image/jpeg;base64,"+base64.StdEncoding.EncodeToString(frame), "")
+	return nil
+}
+
+func (i *RTSPIngester) Stop() {
+	i.session.Logger.Info("Stopping camera ingest", i.sourceField())
+	i.cancel()
+	i.capture.Close()
+}
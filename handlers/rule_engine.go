@@ -0,0 +1,123 @@
+// handlers/rule_engine.go
+
+package handlers
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+	"go.uber.org/zap"
+)
+
+const defaultRuleCooldown = 5 * time.Minute
+
+// RuleEngine evaluates the tenant's trigger rules against every environment
+// context of the session and fires their actions.
+type RuleEngine struct {
+	session *RoboSession
+	rules   []models.TriggerRule
+
+	mu            sync.Mutex
+	matchingSince map[string]time.Time
+	lastFired     map[string]time.Time
+}
+
+func InitRuleEngine(session *RoboSession) *RuleEngine {
+	return &RuleEngine{
+		session:       session,
+		rules:         session.Tenant.TriggerRules,
+		matchingSince: make(map[string]time.Time),
+		lastFired:     make(map[string]time.Time),
+	}
+}
+
+// Evaluate is called for each new environment context.
+func (e *RuleEngine) Evaluate(envContext models.EnvironmentContext) {
+	if len(e.rules) == 0 {
+		return
+	}
+
+	var fired []models.RuleTrigger
+	e.mu.Lock()
+	for _, rule := range e.rules {
+		if !utils.RuleMatches(rule, envContext) {
+			delete(e.matchingSince, rule.ID)
+			continue
+		}
+
+		since, ok := e.matchingSince[rule.ID]
+		if !ok {
+			since = envContext.Timestamp
+			e.matchingSince[rule.ID] = since
+		}
+		if hold := parseRuleDuration(rule.For, 0); envContext.Timestamp.Sub(since) < hold {
+			continue
+		}
+		if last, ok := e.lastFired[rule.ID]; ok && e.session.Clock.Since(last) < parseRuleDuration(rule.Cooldown, defaultRuleCooldown) {
+			continue
+		}
+
+		e.lastFired[rule.ID] = e.session.Clock.Now()
+		fired = append(fired, models.RuleTrigger{
+			RuleID:             rule.ID,
+			RuleName:           rule.Name,
+			MatchingSince:      since.Unix(),
+			EnvironmentContext: envContext,
+		})
+	}
+	e.mu.Unlock()
+
+	for _, trigger := range fired {
+		e.fire(trigger)
+	}
+}
+
+func (e *RuleEngine) fire(trigger models.RuleTrigger) {
+	rule := e.ruleByID(trigger.RuleID)
+	trigger.TriggerID = e.session.IDs.NewID()
+	e.session.Logger.Info("Trigger rule fired",
+		zap.String("trigger_id", trigger.TriggerID), zap.String("rule_id", trigger.RuleID), zap.String("rule_name", trigger.RuleName))
+
+	if slices.Contains(rule.Actions, models.RULE_ACTION_ALERT) {
+		e.session.sendWebSocketMessage("rule_triggered", trigger)
+	}
+	if slices.Contains(rule.Actions, models.RULE_ACTION_ORCHESTRATOR) {
+		e.session.journalAction("rule %q fired, sent to the orchestrator", trigger.RuleName)
+		e.session.notifyOrchestrator(trigger.TriggerID, OrchestratorRulePayload{
+			TriggerID:          trigger.TriggerID,
+			SessionID:          e.session.ID,
+			TenantID:           e.session.Tenant.ID,
+			TriggerType:        "rule",
+			RuleID:             trigger.RuleID,
+			RuleName:           trigger.RuleName,
+			MatchingSince:      trigger.MatchingSince,
+			EnvironmentContext: trigger.EnvironmentContext,
+			Timestamp:          e.session.Clock.Now().Unix(),
+			Worker:             utils.Worker(),
+			Metadata:           e.session.metadataCopy(),
+		})
+	}
+}
+
+func (e *RuleEngine) ruleByID(id string) models.TriggerRule {
+	for _, rule := range e.rules {
+		if rule.ID == id {
+			return rule
+		}
+	}
+	return models.TriggerRule{}
+}
+
+func parseRuleDuration(value string, fallback time.Duration) time.Duration {
+	if value == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return fallback
+	}
+	return d
+}
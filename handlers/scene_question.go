@@ -0,0 +1,220 @@
+// handlers/scene_question.go
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+	"go.uber.org/zap"
+)
+
+const sceneQuestionMaxLength = 500
+
+// errNoRecentFrame is returned when no frame arrived recently enough to
+// answer from, not even after asking the robot for one.
+var errNoRecentFrame = errors.New("no recent frame to answer from")
+
+type recentFrame struct {
+	image      string
+	receivedAt time.Time
+}
+
+// FrameHistory keeps the latest frames of a session in memory so questions
+// about the scene can be answered from what the robot sees now.
+type FrameHistory struct {
+	mu     sync.Mutex
+	frames []recentFrame
+	size   int
+	// updated is closed and replaced whenever a frame arrives
+	updated chan struct{}
+}
+
+func NewFrameHistory(size int) *FrameHistory {
+	return &FrameHistory{size: max(size, 1), updated: make(chan struct{})}
+}
+
+func (h *FrameHistory) Add(image string, receivedAt time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.frames = append(h.frames, recentFrame{image: image, receivedAt: receivedAt})
+	if len(h.frames) > h.size {
+		h.frames = h.frames[len(h.frames)-h.size:]
+	}
+	close(h.updated)
+	h.updated = make(chan struct{})
+}
+
+// Latest returns up to n frames received after since, oldest first.
+func (h *FrameHistory) Latest(n int, since time.Time) []recentFrame {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var frames []recentFrame
+	for i := len(h.frames) - 1; i >= 0 && len(frames) < n; i-- {
+		if !h.frames[i].receivedAt.After(since) {
+			break
+		}
+		frames = append([]recentFrame{h.frames[i]}, frames...)
+	}
+	return frames
+}
+
+// Updated returns a channel closed when the next frame arrives.
+func (h *FrameHistory) Updated() <-chan struct{} {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.updated
+}
+
+// SceneQuestion is an ask_about_scene message or request body. Frames is how
+// many of the latest frames to look at, 1 by default.
+type SceneQuestion struct {
+	QuestionID string `json:"question_id,omitempty"`
+	Question   string `json:"question"`
+	Frames     int    `json:"frames,omitempty"`
+}
+
+// answerSceneQuestion answers a question from the latest frames. Frames
+// older than SCENE_QA_MAX_FRAME_AGE are not used; without a newer one the
+// robot is asked for a frame and given SCENE_QA_CAPTURE_WAIT to send it.
+func (rs *RoboSession) answerSceneQuestion(ctx context.Context, question SceneQuestion) (*SceneAnswerPayload, error) {
+	started := rs.Clock.Now()
+	maxFrames := rs.Config.SceneQAMaxFrames
+	count := min(max(question.Frames, 1), maxFrames)
+	since := started.Add(-rs.Config.SceneQAMaxFrameAge)
+
+	frames := rs.RecentFrames.Latest(count, since)
+	if len(frames) == 0 {
+		updated := rs.RecentFrames.Updated()
+		rs.requestCapture(CAPTURE_REASON_SCENE_QUESTION)
+		select {
+		case <-updated:
+		case <-rs.Clock.After(rs.Config.SceneQACaptureWait):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if frames = rs.RecentFrames.Latest(count, since); len(frames) == 0 {
+			return nil, errNoRecentFrame
+		}
+	}
+
+	images := make([]string, 0, len(frames))
+	for _, frame := range frames {
+		image, _, err := visionPreprocessor().Process(frame.image, rs.visionROI())
+		if err != nil {
+			rs.Logger.Warn("Failed to preprocess frame, asking about the original", zap.Error(err))
+			image = frame.image
+		}
+		images = append(images, image)
+	}
+
+	answer, err := rs.openAIClient().AnswerSceneQuestion(ctx, question.Question, images)
+	if err != nil {
+		rs.MetricLabels.ProviderError("openai")
+		return nil, err
+	}
+	rs.MetricLabels.ObserveAnalysis("scene_question", rs.Clock.Since(started).Seconds())
+	rs.recordUsage(models.USAGE_SCENE_QUESTIONS, 1)
+
+	return &SceneAnswerPayload{
+		QuestionID:  question.QuestionID,
+		Question:    question.Question,
+		SceneAnswer: *answer,
+		Frames:      len(frames),
+		FrameTime:   frames[len(frames)-1].receivedAt,
+	}, nil
+}
+
+// handleAskAboutScene answers an ask_about_scene message with a
+// scene_answer, off the listener since the vision call takes seconds.
+func (rs *RoboSession) handleAskAboutScene(data interface{}) {
+	var question SceneQuestion
+	if raw, err := json.Marshal(data); err == nil {
+		json.Unmarshal(raw, &question)
+	}
+	question.Question = strings.TrimSpace(question.Question)
+	if question.Question == "" || len(question.Question) > sceneQuestionMaxLength {
+		rs.sendProtocolError(newProtocolError(PROTOCOL_ERROR_INVALID_PAYLOAD, "ask_about_scene", "data.question",
+			"question must be between 1 and 500 characters"))
+		return
+	}
+	if question.QuestionID == "" {
+		question.QuestionID = rs.IDs.NewID()
+	}
+
+	rs.Supervisor.Task("scene_question", func() {
+		ctx, cancel := context.WithTimeout(rs.sessionCtx, 30*time.Second)
+		defer cancel()
+		rs.Logger.Info("Answering scene question",
+			zap.String("question_id", question.QuestionID), zap.String("question", rs.redact(question.Question)))
+
+		answer, err := rs.answerSceneQuestion(ctx, question)
+		switch {
+		case rs.sessionCtx.Err() != nil:
+			// The session stopped
+			return
+		case errors.Is(err, errNoRecentFrame):
+			rs.sendError(ERROR_CODE_NO_FRAME, "ask_about_scene", "No recent frame to answer from")
+		case err != nil:
+			rs.Logger.Error("Failed to answer scene question", zap.String("question_id", question.QuestionID), zap.Error(err))
+			rs.sendError(ERROR_CODE_VISION_FAILED, "ask_about_scene", "Scene question could not be answered")
+		default:
+			rs.sendWebSocketMessage("scene_answer", answer)
+		}
+	})
+}
+
+// HandleSceneQuestion answers a question about a live session's latest
+// frames: POST /robot/sessions/{id}/scene-questions {"question":"is the door open?"}
+func HandleSceneQuestion(w http.ResponseWriter, r *http.Request, tenants *utils.TenantStore) {
+	rs, ok := resolveTenantSession(w, r, tenants)
+	if !ok {
+		return
+	}
+	if !rs.hasModality(MODALITY_VIDEO) {
+		http.Error(w, "video modality is not enabled for this session", http.StatusConflict)
+		return
+	}
+	if !rs.rateLimiter.Allow() {
+		rs.recordUsage(models.USAGE_RATE_LIMITED, 1)
+		http.Error(w, "rate limited", http.StatusTooManyRequests)
+		return
+	}
+
+	var question SceneQuestion
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&question); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	question.Question = strings.TrimSpace(question.Question)
+	if question.Question == "" || len(question.Question) > sceneQuestionMaxLength {
+		http.Error(w, "question must be between 1 and 500 characters", http.StatusBadRequest)
+		return
+	}
+	if question.QuestionID == "" {
+		question.QuestionID = rs.IDs.NewID()
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+	answer, err := rs.answerSceneQuestion(ctx, question)
+	switch {
+	case errors.Is(err, errNoRecentFrame):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		rs.Logger.Error("Failed to answer scene question", zap.String("question_id", question.QuestionID), zap.Error(err))
+		http.Error(w, "scene question could not be answered", http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(answer)
+}
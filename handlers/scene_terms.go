@@ -0,0 +1,118 @@
+// handlers/scene_terms.go
+
+package handlers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+	"go.uber.org/zap"
+)
+
+// SceneBoostSettings control boosting of the key elements seen by video
+// analysis in speech-to-text, so names of objects in view ("spatula",
+// "defibrillator") are transcribed correctly. Deepgram fixes keyterms when
+// the stream opens, so new terms reconnect the stream, at most once per
+// Interval.
+type SceneBoostSettings struct {
+	Enabled  bool
+	MaxTerms int
+	Interval time.Duration
+}
+
+func defaultSceneBoostSettings(cfg *utils.Config) SceneBoostSettings {
+	return SceneBoostSettings{
+		Enabled:  cfg.STTSceneBoost,
+		MaxTerms: cfg.STTSceneBoostMaxTerms,
+		Interval: cfg.STTSceneBoostInterval,
+	}
+}
+
+func (rs *RoboSession) sceneBoostSettings() SceneBoostSettings {
+	rs.stateMu.Lock()
+	defer rs.stateMu.Unlock()
+	return rs.sceneBoost
+}
+
+// applySceneBoostConfig toggles boosting from a config payload
+// ({"stt_scene_boost":true}) and reports whether the stream must be
+// reconnected to add or drop scene terms.
+func (rs *RoboSession) applySceneBoostConfig(configData map[string]interface{}) (string, bool, error) {
+	value, exists := configData["stt_scene_boost"]
+	if !exists {
+		return "", false, nil
+	}
+	enabled, ok := value.(bool)
+	if !ok {
+		return "data.stt_scene_boost", false, fmt.Errorf("must be a boolean")
+	}
+
+	rs.stateMu.Lock()
+	defer rs.stateMu.Unlock()
+	if rs.sceneBoost.Enabled == enabled {
+		return "", false, nil
+	}
+	rs.sceneBoost.Enabled = enabled
+	if !enabled {
+		hadTerms := len(rs.sceneTerms) > 0
+		rs.sceneTerms = nil
+		return "", hadTerms, nil
+	}
+	return "", false, nil
+}
+
+// updateSceneTerms picks up the key elements of the latest analyses and
+// reconnects the Deepgram stream when they changed.
+func (rs *RoboSession) updateSceneTerms() {
+	settings := rs.sceneBoostSettings()
+	if !settings.Enabled || settings.MaxTerms < 1 || rs.AudioHandler == nil {
+		return
+	}
+	terms := rs.EnvironmentCache.KeyElements(settings.MaxTerms)
+
+	rs.stateMu.Lock()
+	now := rs.Clock.Now()
+	if sameTerms(terms, rs.sceneTerms) || (!rs.sceneTermsAt.IsZero() && now.Sub(rs.sceneTermsAt) < settings.Interval) {
+		rs.stateMu.Unlock()
+		return
+	}
+	rs.sceneTerms = terms
+	rs.sceneTermsAt = now
+	rs.stateMu.Unlock()
+
+	rs.Logger.Info("Boosting scene terms in speech-to-text", zap.Strings("terms", terms))
+	rs.Supervisor.Task("stt_reconnect", func() { rs.AudioHandler.Reconnect() })
+}
+
+// streamSTTOptions returns the options for a new Deepgram stream: the
+// session's settings plus the current scene terms.
+func (rs *RoboSession) streamSTTOptions() utils.STTOptions {
+	rs.stateMu.Lock()
+	defer rs.stateMu.Unlock()
+
+	options := rs.stt
+	if len(rs.sceneTerms) > 0 {
+		options.Keyterms = append(append([]string{}, options.Keyterms...), rs.sceneTerms...)
+		options.Keywords = append(append([]string{}, options.Keywords...), rs.sceneTerms...)
+	}
+	return options
+}
+
+// sameTerms compares term sets, ignoring order and case.
+func sameTerms(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	set := make(map[string]bool, len(a))
+	for _, term := range a {
+		set[strings.ToLower(term)] = true
+	}
+	for _, term := range b {
+		if !set[strings.ToLower(term)] {
+			return false
+		}
+	}
+	return true
+}
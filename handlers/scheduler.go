@@ -0,0 +1,135 @@
+// handlers/scheduler.go
+
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// scheduler runs periodic jobs once per interval across replicas. It is nil
+// until StartScheduler is called.
+var scheduler *utils.Scheduler
+
+// StartScheduler creates the shared scheduler and registers the global jobs.
+func StartScheduler(redisClient *redis.Client) {
+	scheduler = utils.NewScheduler(redisClient)
+
+	scheduler.Schedule("retention_purge", utils.CurrentConfig().RetentionPurgeInterval, func(ctx context.Context) error {
+		purged, err := utils.PurgeExpiredRecords(ctx, redisClient)
+		if err != nil {
+			return err
+		}
+		zap.L().Info("Purged expired records", zap.Int("records", purged))
+
+		if config := recordingConfig(); config != nil {
+			purged, err := utils.PurgeExpiredRecordings(ctx, redisClient, config.Dir, config.Retention)
+			if err != nil {
+				return err
+			}
+			if purged > 0 {
+				zap.L().Info("Purged expired recordings", zap.Int("recordings", purged))
+			}
+		}
+		return nil
+	})
+
+	// Disabled unless ENCRYPTION_ROTATION_INTERVAL is set
+	scheduler.Schedule("encryption_key_rotation", utils.CurrentConfig().EncryptionRotationInterval, func(ctx context.Context) error {
+		rotated, err := rotateArtifactKeys(ctx, redisClient)
+		if err != nil {
+			return err
+		}
+		if rotated > 0 {
+			zap.L().Info("Rewrapped artifacts under current encryption keys", zap.Int("artifacts", rotated))
+		}
+		return nil
+	})
+}
+
+// StopScheduler cancels all scheduled jobs on this replica.
+func StopScheduler() {
+	if scheduler != nil {
+		scheduler.Stop()
+	}
+}
+
+// schedule runs a periodic job for this session on the shared scheduler. Jobs
+// are cancelled when the session stops, and a run in progress when the
+// session context ends.
+func (rs *RoboSession) schedule(job string, interval time.Duration, fn utils.Job) {
+	if scheduler == nil {
+		rs.Logger.Warn("Scheduler not started, skipping job", zap.String("job", job))
+		return
+	}
+	scheduler.Schedule(rs.schedulerPrefix()+job, interval, func(ctx context.Context) error {
+		if rs.sessionCtx.Err() != nil {
+			return nil
+		}
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		stop := context.AfterFunc(rs.sessionCtx, cancel)
+		defer stop()
+		return fn(ctx)
+	})
+}
+
+func (rs *RoboSession) cancelScheduledJobs() {
+	if scheduler != nil {
+		scheduler.CancelPrefix(rs.schedulerPrefix())
+	}
+}
+
+func (rs *RoboSession) schedulerPrefix() string {
+	return "session:" + rs.ID + ":"
+}
+
+// scheduledCapture takes a frame at the fixed SCHEDULED_CAPTURE_INTERVAL,
+// whatever the adapted video frequency or power mode, so the scene is
+// analyzed at a known pace. The server's camera source is captured
+// directly; otherwise the robot is sent a capture_request.
+func (rs *RoboSession) scheduledCapture(ctx context.Context) error {
+	if ingester := rs.currentIngester(); ingester != nil {
+		if err := ingester.CaptureNow(ctx); err != nil {
+			return fmt.Errorf("scheduled capture failed: %w", err)
+		}
+		return nil
+	}
+	rs.requestCapture(CAPTURE_REASON_PERIODIC)
+	return nil
+}
+
+// checkStaleContext warns the client once when no environment context has
+// arrived for STALE_CONTEXT_AFTER, e.g. because the camera stopped sending.
+func (rs *RoboSession) checkStaleContext(context.Context) error {
+	staleAfter := rs.Config.StaleContextAfter
+
+	last := rs.StartTime
+	if latest, ok := rs.EnvironmentCache.Latest(); ok {
+		last = latest.Timestamp
+	}
+	if rs.Clock.Since(last) < staleAfter {
+		return nil
+	}
+
+	rs.stateMu.Lock()
+	warned := rs.staleWarnedAt.Equal(last)
+	rs.staleWarnedAt = last
+	rs.stateMu.Unlock()
+	if warned {
+		return nil
+	}
+
+	rs.Logger.Warn("Environment context is stale", zap.Time("last_context_at", last))
+	payload := ContextStalePayload{StaleFor: rs.Clock.Since(last).Round(time.Second).String()}
+	if last != rs.StartTime {
+		payload.LastContextAt = last.Unix()
+	}
+	rs.sendWebSocketMessage("context_stale", payload)
+	return nil
+}
@@ -0,0 +1,167 @@
+// handlers/schema_handler.go
+
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+)
+
+const (
+	SCHEMA_KIND_ENVELOPE     = "envelope"
+	SCHEMA_KIND_INBOUND      = "inbound"
+	SCHEMA_KIND_OUTBOUND     = "outbound"
+	SCHEMA_KIND_ORCHESTRATOR = "orchestrator"
+	SCHEMA_KIND_WEBHOOK      = "webhook"
+)
+
+// outboundPayloads maps outbound WebSocket message types to example values of
+// their data payloads. Types sent with several shapes list each of them.
+var outboundPayloads = map[string][]interface{}{
+	"text":                          {SessionStartedPayload{}, SessionStoppedPayload{}},
+	"pong":                          {nil},
+	"config_updated":                {ConfigUpdatedPayload{}},
+	"config_applied":                {ConfigAppliedPayload{}},
+	"capture_frequency_update":      {CaptureFrequencyUpdatePayload{}},
+	"transcript_interim":            {TranscriptPayload{}},
+	"transcript_final":              {TranscriptPayload{}},
+	"caption":                       {CaptionPayload{}},
+	"stt_status":                    {STTStatusPayload{}},
+	"speech_activity":               {SpeechActivityPayload{}},
+	"prompt_user":                   {PromptUserPayload{}},
+	"power_mode":                    {PowerModePayload{}},
+	"audio_event":                   {AudioEventPayload{}},
+	"acoustic_event":                {AcousticEventPayload{}},
+	"intention_analysis":            {models.IntentionResult{}},
+	"intention_deduplicated":        {models.IntentionResult{}},
+	"intention_escalated":           {IntentionEscalatedPayload{}},
+	"intention_confirmation":        {IntentionConfirmationPayload{}},
+	"intention_confirmation_result": {IntentionConfirmationResultPayload{}},
+	"video_frame":                   {VideoFramePayload{}},
+	"video_analysis":                {models.EnvironmentContext{}},
+	"video_annotations":             {VideoAnnotationsPayload{}},
+	"frame_quality_low":             {FrameQualityLowPayload{}},
+	"world_state":                   {WorldStatePayload{}},
+	"preference_learned":            {PreferenceLearnedPayload{}},
+	"context_stale":                 {ContextStalePayload{}},
+	"rule_triggered":                {models.RuleTrigger{}},
+	"environment_change":            {models.EnvironmentChange{}},
+	"recording_saved":               {models.Recording{}},
+	"display":                       {models.DisplayContent{}},
+	"capture_request":               {CaptureRequestPayload{}},
+	"scene_answer":                  {SceneAnswerPayload{}},
+	"echo_probe_result":             {EchoProbeResult{}},
+	"camera_control":                {CameraControlPayload{}},
+	"rtsp_error":                    {RTSPErrorPayload{}},
+	"rate_limited":                  {RateLimitedPayload{}},
+	"protocol_error":                {ProtocolError{}},
+	"error":                         {ErrorPayload{}},
+}
+
+var orchestratorPayloads = map[string]interface{}{
+	"intention":          OrchestratorIntentionPayload{},
+	"rule":               OrchestratorRulePayload{},
+	"environment_change": OrchestratorEnvironmentChangePayload{},
+	"acoustic_event":     OrchestratorAcousticEventPayload{},
+}
+
+// webhookPayloads maps webhook event names to their payloads.
+var webhookPayloads = map[string]interface{}{
+	utils.WEBHOOK_SESSION_STARTED: SessionStartedWebhook{},
+	utils.WEBHOOK_SESSION_ENDED:   SessionEndedWebhook{},
+	utils.WEBHOOK_SESSION_ERROR:   ErrorPayload{},
+}
+
+// schemaRegistry returns every published schema keyed by kind and name.
+func schemaRegistry() map[string]map[string]map[string]interface{} {
+	registry := map[string]map[string]map[string]interface{}{
+		SCHEMA_KIND_ENVELOPE:     {"message": utils.JSONSchemaFor(WebSocketMessage{})},
+		SCHEMA_KIND_INBOUND:      {},
+		SCHEMA_KIND_OUTBOUND:     {},
+		SCHEMA_KIND_ORCHESTRATOR: {},
+		SCHEMA_KIND_WEBHOOK:      {},
+	}
+
+	for name, schema := range inboundSchemas {
+		registry[SCHEMA_KIND_INBOUND][name] = inboundJSONSchema(schema)
+	}
+	for name, payloads := range outboundPayloads {
+		if len(payloads) == 1 {
+			registry[SCHEMA_KIND_OUTBOUND][name] = utils.JSONSchemaFor(payloads[0])
+			continue
+		}
+		var variants []interface{}
+		for _, payload := range payloads {
+			variants = append(variants, utils.JSONSchemaFor(payload))
+		}
+		registry[SCHEMA_KIND_OUTBOUND][name] = map[string]interface{}{"oneOf": variants}
+	}
+	for name, payload := range orchestratorPayloads {
+		registry[SCHEMA_KIND_ORCHESTRATOR][name] = utils.JSONSchemaFor(payload)
+	}
+	for name, payload := range webhookPayloads {
+		registry[SCHEMA_KIND_WEBHOOK][name] = utils.JSONSchemaFor(payload)
+	}
+
+	for kind, schemas := range registry {
+		for name, schema := range schemas {
+			schema["$schema"] = utils.JSONSchemaDialect
+			schema["$id"] = "/schemas/" + kind + "/" + name
+			schema["title"] = name
+			schema["version"] = PROTOCOL_VERSION
+		}
+	}
+	return registry
+}
+
+// inboundJSONSchema converts a validation schema to JSON Schema.
+func inboundJSONSchema(schema MessageSchema) map[string]interface{} {
+	jsonSchema := map[string]interface{}{"description": schema.Description}
+	switch schema.DataType {
+	case "string":
+		jsonSchema["type"] = "string"
+	case "object":
+		properties := make(map[string]interface{}, len(schema.Fields))
+		required := []string{}
+		for name, field := range schema.Fields {
+			properties[name] = field
+			if field.Required {
+				required = append(required, name)
+			}
+		}
+		sort.Strings(required)
+		jsonSchema["type"] = "object"
+		jsonSchema["properties"] = properties
+		jsonSchema["required"] = required
+	default:
+		jsonSchema["type"] = "null"
+	}
+	return jsonSchema
+}
+
+// HandleSchemas serves the schema registry: GET /schemas lists every schema,
+// GET /schemas/{kind}/{name} returns one.
+func HandleSchemas(w http.ResponseWriter, r *http.Request) {
+	registry := schemaRegistry()
+	w.Header().Set("Content-Type", "application/schema+json")
+
+	kind, name := r.PathValue("kind"), r.PathValue("name")
+	if kind == "" {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"version": PROTOCOL_VERSION,
+			"schemas": registry,
+		})
+		return
+	}
+
+	schema, ok := registry[kind][name]
+	if !ok {
+		http.Error(w, "schema not found", http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(schema)
+}
@@ -0,0 +1,100 @@
+// handlers/sentiment.go
+
+package handlers
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+	"go.uber.org/zap"
+)
+
+const defaultEscalationCooldown = time.Minute
+
+// newSentimentAnalyzer returns the session's sentiment analyzer, nil when
+// SENTIMENT_ANALYSIS_ENABLED is off or the backend is misconfigured.
+func newSentimentAnalyzer(session *RoboSession, openaiClient *utils.OpenAIClient) utils.SentimentAnalyzer {
+	if !session.Config.SentimentAnalysisEnabled {
+		return nil
+	}
+	analyzer, err := utils.NewSentimentAnalyzerFromConfig(session.Config, openaiClient)
+	if err != nil {
+		session.Logger.Warn("Sentiment analysis disabled", zap.Error(err))
+		return nil
+	}
+	return analyzer
+}
+
+// startSentiment classifies the transcript alongside intention analysis.
+// The returned function waits for the result, nil when sentiment analysis
+// is off or failed, so a slow classifier never fails the intention.
+func (h *IntentionHandler) startSentiment(ctx context.Context, transcript string) func() *models.Sentiment {
+	if h.sentiment == nil {
+		return func() *models.Sentiment { return nil }
+	}
+	ctx, cancel := context.WithTimeout(ctx, h.session.Config.SentimentTimeout)
+	done := make(chan *models.Sentiment, 1)
+	h.session.Supervisor.Task("sentiment", func() {
+		// Delivered even after a panic so the intention does not wait forever
+		var sentiment *models.Sentiment
+		defer func() {
+			cancel()
+			done <- sentiment
+		}()
+		result, err := h.sentiment.AnalyzeSentiment(ctx, transcript)
+		if err != nil {
+			if !cancelled(ctx) {
+				h.session.Logger.Warn("Failed to analyze sentiment", zap.Error(err))
+				h.session.MetricLabels.ProviderError("sentiment")
+			}
+			return
+		}
+		h.session.recordUsage(models.USAGE_SENTIMENTS, 1)
+		sentiment = result
+	})
+	return func() *models.Sentiment { return <-done }
+}
+
+// IntentionEscalator applies the tenant's escalation policies to the
+// sentiment of each intention, at most once per policy cooldown.
+type IntentionEscalator struct {
+	policies []models.EscalationPolicy
+
+	mu            sync.Mutex
+	lastEscalated map[string]time.Time
+}
+
+func NewIntentionEscalator(cfg *utils.Config, tenant *models.Tenant) *IntentionEscalator {
+	policies := tenant.EscalationPolicies
+	if len(policies) == 0 {
+		policies = utils.DefaultEscalationPolicies(cfg)
+	}
+	return &IntentionEscalator{
+		policies:      policies,
+		lastEscalated: make(map[string]time.Time),
+	}
+}
+
+// escalate returns the first policy the sentiment matches that is not
+// cooling down, nil when none does.
+func (e *IntentionEscalator) escalate(sentiment *models.Sentiment, now time.Time) *models.EscalationPolicy {
+	if sentiment == nil {
+		return nil
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for i, policy := range e.policies {
+		if !utils.EscalationMatches(policy, sentiment) {
+			continue
+		}
+		if last, ok := e.lastEscalated[policy.ID]; ok && now.Sub(last) < parseRuleDuration(policy.Cooldown, defaultEscalationCooldown) {
+			continue
+		}
+		e.lastEscalated[policy.ID] = now
+		return &e.policies[i]
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+// handlers/session_metadata.go
+
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"regexp"
+
+	"go.uber.org/zap"
+)
+
+// Well-known session metadata keys, also accepted as plain query parameters
+// of the upgrade request.
+const (
+	METADATA_ROBOT_ID         = "robot_id"
+	METADATA_SITE             = "site"
+	METADATA_FIRMWARE_VERSION = "firmware_version"
+	METADATA_OPERATOR         = "operator"
+)
+
+var wellKnownMetadataKeys = []string{METADATA_ROBOT_ID, METADATA_SITE, METADATA_FIRMWARE_VERSION, METADATA_OPERATOR}
+
+const (
+	SESSION_METADATA_MAX_KEYS         = 20
+	SESSION_METADATA_MAX_VALUE_LENGTH = 256
+)
+
+var metadataKeyPattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,63}$`)
+
+// parseSessionMetadata reads the metadata a client attaches to its session:
+// a JSON object of strings in the metadata query parameter
+// (?metadata={"site":"plant-3"}) and the well-known keys as plain parameters
+// (?site=plant-3&operator=jdoe), which win over the object.
+func parseSessionMetadata(r *http.Request) (map[string]string, error) {
+	query := r.URL.Query()
+	metadata := make(map[string]string)
+	if raw := query.Get("metadata"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &metadata); err != nil {
+			return nil, fmt.Errorf("metadata must be a JSON object of strings")
+		}
+	}
+	for _, key := range wellKnownMetadataKeys {
+		if query.Has(key) {
+			metadata[key] = query.Get(key)
+		}
+	}
+
+	if len(metadata) > SESSION_METADATA_MAX_KEYS {
+		return nil, fmt.Errorf("metadata has more than %d keys", SESSION_METADATA_MAX_KEYS)
+	}
+	for key, value := range metadata {
+		if !metadataKeyPattern.MatchString(key) {
+			return nil, fmt.Errorf("invalid metadata key %q: use lowercase letters, digits and underscores", key)
+		}
+		if len(value) > SESSION_METADATA_MAX_VALUE_LENGTH {
+			return nil, fmt.Errorf("metadata %q is longer than %d bytes", key, SESSION_METADATA_MAX_VALUE_LENGTH)
+		}
+		if value == "" {
+			delete(metadata, key)
+		}
+	}
+	return metadata, nil
+}
+
+// setMetadata attaches the client's metadata to the session's logs. Called
+// before the session's goroutines start.
+func (rs *RoboSession) setMetadata(metadata map[string]string) {
+	rs.Metadata = metadata
+	rs.RobotID = metadata[METADATA_ROBOT_ID]
+	if len(metadata) == 0 {
+		return
+	}
+	rs.Logger = rs.Logger.With(zap.Any("metadata", metadata))
+	rs.Outbound.logger = rs.Logger
+}
+
+// metadataCopy returns the session metadata for a payload, nil without any.
+func (rs *RoboSession) metadataCopy() map[string]string {
+	if len(rs.Metadata) == 0 {
+		return nil
+	}
+	return maps.Clone(rs.Metadata)
+}
+
+// addMetadataFields adds the session metadata to a Pinecone record as
+// meta_<key> fields, so memory can be filtered by site or robot.
+func (rs *RoboSession) addMetadataFields(record map[string]interface{}) {
+	for key, value := range rs.Metadata {
+		record["meta_"+key] = value
+	}
+}
@@ -0,0 +1,77 @@
+// handlers/session_models.go
+
+package handlers
+
+import (
+	"fmt"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+)
+
+// modelChain returns the session's model chain for a task, falling back to
+// the environment configuration.
+func (rs *RoboSession) modelChain(task string) []string {
+	rs.stateMu.Lock()
+	chain := rs.models[task]
+	rs.stateMu.Unlock()
+
+	if len(chain) > 0 {
+		return chain
+	}
+	return utils.DefaultModelChain(task)
+}
+
+func (rs *RoboSession) modelOverrides() utils.ModelChains {
+	rs.stateMu.Lock()
+	defer rs.stateMu.Unlock()
+
+	overrides := make(utils.ModelChains, len(rs.models))
+	for task, chain := range rs.models {
+		overrides[task] = append([]string(nil), chain...)
+	}
+	return overrides
+}
+
+func (rs *RoboSession) setModelOverrides(overrides utils.ModelChains) {
+	rs.stateMu.Lock()
+	defer rs.stateMu.Unlock()
+
+	for task, chain := range overrides {
+		rs.models[task] = chain
+	}
+}
+
+// parseModelOverrides reads the "models" config object, mapping tasks to a
+// model name, a comma-separated fallback chain or an array of model names.
+func parseModelOverrides(value interface{}) (utils.ModelChains, error) {
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("models must be an object")
+	}
+
+	overrides := make(utils.ModelChains, len(object))
+	for task, raw := range object {
+		switch v := raw.(type) {
+		case string:
+			chain, err := utils.ParseModelChain(v)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", task, err)
+			}
+			overrides[task] = chain
+		case []interface{}:
+			for _, model := range v {
+				name, ok := model.(string)
+				if !ok {
+					return nil, fmt.Errorf("%s: model names must be strings", task)
+				}
+				overrides[task] = append(overrides[task], name)
+			}
+		default:
+			return nil, fmt.Errorf("%s: expected a model name or list", task)
+		}
+	}
+	if err := utils.ValidateModelChains(overrides); err != nil {
+		return nil, err
+	}
+	return overrides, nil
+}
@@ -0,0 +1,86 @@
+// handlers/session_profiles.go
+
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+	"go.uber.org/zap"
+)
+
+// errMemoryDisabled is returned for the Pinecone index of sessions whose
+// profile turns memory off.
+var errMemoryDisabled = errors.New("memory is disabled by the session profile")
+
+// parseProfile looks up the profile named with ?profile= on the upgrade
+// request. Names that are not profiles only label metrics, as they did
+// before profiles existed.
+func parseProfile(r *http.Request) *models.SessionProfile {
+	name := r.URL.Query().Get("profile")
+	if name == "" {
+		return nil
+	}
+	profile, ok := utils.SessionProfile(name)
+	if !ok {
+		zap.L().Warn("Unknown session profile, using defaults", zap.String("profile", name))
+		return nil
+	}
+	return profile
+}
+
+// profileModalities returns the modalities a profile enables, all of them
+// when it names none. Text is always available.
+func profileModalities(profile *models.SessionProfile) map[string]bool {
+	if profile == nil || len(profile.Modalities) == 0 {
+		return defaultModalities()
+	}
+	modalities := map[string]bool{MODALITY_TEXT: true}
+	for _, modality := range profile.Modalities {
+		modalities[modality] = true
+	}
+	return modalities
+}
+
+// applyProfile presets the session from its profile before the handlers
+// start, so the speech-to-text stream connects with the profile's settings
+// and disabled providers are never set up. Invalid settings are logged and
+// skipped.
+func (rs *RoboSession) applyProfile(profile *models.SessionProfile) {
+	if profile == nil {
+		return
+	}
+	rs.Profile = profile.Name
+	rs.memoryDisabled = profile.Memory != nil && !*profile.Memory
+
+	applied, _ := rs.applySessionConfig(profile.Config, func(protocolErr *ProtocolError) {
+		rs.Logger.Warn("Invalid session profile setting",
+			zap.String("profile", profile.Name),
+			zap.String("field", protocolErr.Field),
+			zap.String("error", protocolErr.Message))
+	})
+	rs.Logger.Info("Applied session profile",
+		zap.String("profile", profile.Name),
+		zap.Bool("memory", !rs.memoryDisabled),
+		zap.Strings("settings", applied))
+}
+
+// profileSets reports whether a profile presets a config key.
+func profileSets(profile *models.SessionProfile, key string) bool {
+	if profile == nil {
+		return false
+	}
+	_, ok := profile.Config[key]
+	return ok
+}
+
+// HandleSessionProfiles lists the profiles a session can be started with.
+func HandleSessionProfiles(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"profiles": utils.SessionProfiles(),
+	})
+}
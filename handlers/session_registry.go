@@ -0,0 +1,81 @@
+// handlers/session_registry.go
+
+package handlers
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+)
+
+// sessionRegistry tracks live sessions so REST endpoints can reach them.
+var sessionRegistry = struct {
+	sync.RWMutex
+	sessions map[string]*RoboSession
+}{sessions: make(map[string]*RoboSession)}
+
+func registerSession(rs *RoboSession) {
+	sessionRegistry.Lock()
+	defer sessionRegistry.Unlock()
+	sessionRegistry.sessions[rs.ID] = rs
+}
+
+func unregisterSession(id string) {
+	sessionRegistry.Lock()
+	defer sessionRegistry.Unlock()
+	delete(sessionRegistry.sessions, id)
+}
+
+// GetSession returns the live session with the given ID, if any.
+func GetSession(id string) (*RoboSession, bool) {
+	sessionRegistry.RLock()
+	defer sessionRegistry.RUnlock()
+	rs, ok := sessionRegistry.sessions[id]
+	return rs, ok
+}
+
+// ListSessions returns a snapshot of all live sessions.
+func ListSessions() []*RoboSession {
+	sessionRegistry.RLock()
+	defer sessionRegistry.RUnlock()
+	sessions := make([]*RoboSession, 0, len(sessionRegistry.sessions))
+	for _, rs := range sessionRegistry.sessions {
+		sessions = append(sessions, rs)
+	}
+	return sessions
+}
+
+// StopSessions ends every live session, on shutdown, and waits for their
+// summaries. Their snapshots are kept, so robots can resume on another
+// replica.
+func StopSessions() {
+	var wg sync.WaitGroup
+	for _, rs := range ListSessions() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rs.Stop()
+		}()
+	}
+	wg.Wait()
+	pendingSummaries.Wait()
+}
+
+// resolveTenantSession authenticates the caller and looks up the session named
+// by the {id} path value, writing the HTTP error itself when that fails.
+// Sessions of other tenants are reported as not found.
+func resolveTenantSession(w http.ResponseWriter, r *http.Request, tenants *utils.TenantStore) (*RoboSession, bool) {
+	tenant, err := tenants.Resolve(r)
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	rs, ok := GetSession(r.PathValue("id"))
+	if !ok || rs.Tenant.ID != tenant.ID {
+		http.Error(w, "session not found", http.StatusNotFound)
+		return nil, false
+	}
+	return rs, true
+}
@@ -0,0 +1,203 @@
+// handlers/session_snapshot.go
+
+package handlers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// recordUsage counts usage against both the session and its tenant.
+func (rs *RoboSession) recordUsage(counter string, delta int64) {
+	rs.stateMu.Lock()
+	rs.usage[counter] += delta
+	rs.stateMu.Unlock()
+
+	rs.Tenants.IncrementUsage(rs.Tenant.ID, counter, delta)
+	rs.recordRobotUsage(counter, delta)
+}
+
+func (rs *RoboSession) setLastIntention(result models.IntentionResult) {
+	rs.stateMu.Lock()
+	defer rs.stateMu.Unlock()
+	rs.lastIntention = &result
+}
+
+// configSnapshot returns the client-visible session configuration.
+func (rs *RoboSession) configSnapshot() map[string]interface{} {
+	config := rs.transcriptSettings().transcriptConfig()
+	config["video_frequency"] = rs.VideoFrequency().String()
+	config["rtsp_url"] = rs.ingestURL()
+	config["camera_device"] = rs.ingestDevice()
+	config["models"] = rs.modelOverrides()
+	for key, value := range sttConfig(rs.sttSettings()) {
+		config[key] = value
+	}
+	config["stt_scene_boost"] = rs.sceneBoostSettings().Enabled
+	config["capture_requests"] = rs.captureRequestsEnabled()
+	config["adaptive_video_frequency"] = rs.AdaptiveFrequency.Enabled()
+	config["environment_changes"] = rs.Changes.Enabled()
+	for key, value := range rs.Inactivity.Settings().inactivityConfig() {
+		config[key] = value
+	}
+	for key, value := range rs.visionConfig() {
+		config[key] = value
+	}
+	if rs.AudioHandler != nil {
+		format := rs.AudioHandler.Format()
+		config["audio_encoding"] = format.Encoding
+		if format.SampleRate > 0 {
+			config["audio_sample_rate"] = format.SampleRate
+		}
+	}
+	return config
+}
+
+func (rs *RoboSession) snapshot() models.SessionSnapshot {
+	rs.stateMu.Lock()
+	usage := make(map[string]int64, len(rs.usage))
+	for counter, value := range rs.usage {
+		usage[counter] = value
+	}
+	lastIntention := rs.lastIntention
+	currentTranscript := rs.currentTranscript
+	rs.stateMu.Unlock()
+	conversationSummary, conversation := rs.Conversation.state()
+
+	return models.SessionSnapshot{
+		SessionID:           rs.ID,
+		TenantID:            rs.Tenant.ID,
+		StartTime:           rs.StartTime,
+		Config:              rs.configSnapshot(),
+		CurrentTranscript:   currentTranscript,
+		ConversationSummary: conversationSummary,
+		Conversation:        conversation,
+		LastIntention:       lastIntention,
+		Usage:               usage,
+		UpdatedAt:           rs.Clock.Now(),
+	}
+}
+
+func (rs *RoboSession) saveSnapshot() {
+	if rs.Incognito {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	ttl := rs.Config.SessionSnapshotTTL
+	if err := utils.SaveSessionSnapshot(ctx, rs.RedisClient, rs.snapshot(), ttl); err != nil {
+		rs.Logger.Warn("Failed to save session snapshot", zap.Error(err))
+	}
+}
+
+func (rs *RoboSession) discardSnapshot() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := utils.DeleteSessionSnapshot(ctx, rs.RedisClient, rs.ID); err != nil {
+		rs.Logger.Warn("Failed to delete session snapshot", zap.Error(err))
+	}
+}
+
+// runSnapshots persists the session state until the session stops.
+func (rs *RoboSession) runSnapshots() {
+	interval := rs.Config.SessionSnapshotInterval
+	if interval <= 0 {
+		return
+	}
+
+	ticker := rs.Clock.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-rs.sessionCtx.Done():
+			return
+		case <-ticker.C():
+			rs.saveSnapshot()
+			rs.saveRobot(rs.Clock.Now())
+		}
+	}
+}
+
+// claimResumableSnapshot returns the snapshot of a previous session the
+// client asked to resume, if it belongs to the same tenant and is not still
+// live. The snapshot is claimed, so concurrent resumes of the same session
+// cannot both restore it; the resumed session saves its own.
+func claimResumableSnapshot(ctx context.Context, redisClient *redis.Client, tenant *models.Tenant, sessionID string) *models.SessionSnapshot {
+	if _, live := GetSession(sessionID); live {
+		return nil
+	}
+	snapshot, err := utils.ClaimSessionSnapshot(ctx, redisClient, tenant.ID, sessionID)
+	if err != nil {
+		if !errors.Is(err, utils.ErrSnapshotNotClaimed) {
+			zap.L().Warn("Failed to claim session snapshot", zap.String("session_id", sessionID), zap.Error(err))
+		}
+		return nil
+	}
+	return snapshot
+}
+
+// restoreSnapshot re-applies the persisted state of a resumed session.
+func (rs *RoboSession) restoreSnapshot(snapshot *models.SessionSnapshot) {
+	rs.Logger.Info("Restoring session from snapshot", zap.Time("snapshot_at", snapshot.UpdatedAt))
+
+	rs.StartTime = snapshot.StartTime
+	rs.Conversation.restore(snapshot.ConversationSummary, snapshot.Conversation)
+
+	rs.stateMu.Lock()
+	rs.currentTranscript = snapshot.CurrentTranscript
+	rs.lastIntention = snapshot.LastIntention
+	// Added to what this connection already counted, e.g. the session
+	for counter, value := range snapshot.Usage {
+		rs.usage[counter] += value
+	}
+	rs.stateMu.Unlock()
+
+	if freq, ok := snapshot.Config["video_frequency"].(string); ok {
+		if duration, err := time.ParseDuration(freq); err == nil {
+			rs.setVideoFrequency(duration)
+		}
+	}
+	rs.applyTranscriptConfig(snapshot.Config)
+	rs.applyVisionConfig(snapshot.Config)
+	rs.applyAdaptiveFrequencyConfig(snapshot.Config)
+	rs.applyEnvironmentChangesConfig(snapshot.Config)
+	rs.applyInactivityConfig(snapshot.Config)
+	_, reconnect, _ := rs.applySTTConfig(snapshot.Config)
+	rs.applySceneBoostConfig(snapshot.Config)
+	if _, changed, _ := rs.applyAudioFormatConfig(snapshot.Config); changed {
+		reconnect = true
+	}
+	if value, ok := snapshot.Config["models"]; ok {
+		if overrides, err := parseModelOverrides(value); err == nil && len(overrides) > 0 {
+			rs.setModelOverrides(overrides)
+			if _, ok := overrides[utils.MODEL_TASK_STT]; ok {
+				reconnect = true
+			}
+		}
+	}
+	if reconnect && rs.AudioHandler != nil {
+		rs.Supervisor.Task("stt_reconnect", func() { rs.AudioHandler.Reconnect() })
+	}
+	if rtspURL, ok := snapshot.Config["rtsp_url"].(string); ok && rtspURL != "" && rs.hasModality(MODALITY_VIDEO) {
+		rs.setRTSPSource(rtspURL)
+	}
+	if device, ok := snapshot.Config["camera_device"].(string); ok && device != "" && rs.hasModality(MODALITY_VIDEO) && rs.Config.ServerCamerasEnabled {
+		rs.setCameraDevice(device)
+	}
+	captureRequests, ok := snapshot.Config["capture_requests"].(bool)
+	if !ok {
+		captureRequests = rs.Config.CaptureRequests
+	}
+	if captureRequests && rs.hasModality(MODALITY_VIDEO) {
+		rs.setCaptureRequests(true)
+	}
+}
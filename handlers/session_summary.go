@@ -0,0 +1,174 @@
+// handlers/session_summary.go
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// Entries kept per list of the session journal, oldest dropped first
+const maxJournalEntries = 100
+
+// sessionJournal records what happened in a session for its summary.
+type sessionJournal struct {
+	mu       sync.Mutex
+	requests []string
+	actions  []string
+}
+
+func (j *sessionJournal) add(list *[]string, entry string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	*list = append(*list, entry)
+	if len(*list) > maxJournalEntries {
+		*list = (*list)[len(*list)-maxJournalEntries:]
+	}
+}
+
+// journalRequest records an utterance and the intention found in it.
+func (rs *RoboSession) journalRequest(transcript string, result models.IntentionResult) {
+	entry := fmt.Sprintf("%q: no clear intention", transcript)
+	if result.HasClearIntention {
+		entry = fmt.Sprintf("%q: %s (%.2f) %s", transcript, result.IntentionType, result.Confidence, result.Description)
+	}
+	rs.journal.add(&rs.journal.requests, entry)
+}
+
+// journalAction records an action triggered for the robot.
+func (rs *RoboSession) journalAction(format string, args ...interface{}) {
+	rs.journal.add(&rs.journal.actions, fmt.Sprintf(format, args...))
+}
+
+// sessionActivity returns what the summary is written from.
+func (rs *RoboSession) sessionActivity(endTime time.Time) utils.SessionActivity {
+	rs.journal.mu.Lock()
+	defer rs.journal.mu.Unlock()
+	return utils.SessionActivity{
+		Duration:    endTime.Sub(rs.StartTime),
+		Requests:    append([]string(nil), rs.journal.requests...),
+		Actions:     append([]string(nil), rs.journal.actions...),
+		Environment: rs.EnvironmentCache.Recent(5),
+	}
+}
+
+// pendingSummaries counts the summaries of ended sessions still being
+// written, which shutdown waits for.
+var pendingSummaries sync.WaitGroup
+
+// summarizeSession writes the end-of-session summary and stores it. It runs
+// after the session was torn down, so the summary no longer reaches the
+// client. It returns nil when summaries are off (SESSION_SUMMARY_ENABLED),
+// the session is incognito, nothing happened or the model failed within
+// SESSION_SUMMARY_TIMEOUT.
+func (rs *RoboSession) summarizeSession(endTime time.Time) *models.SessionSummary {
+	if !rs.Config.SessionSummaryEnabled || rs.Incognito {
+		return nil
+	}
+	activity := rs.sessionActivity(endTime)
+	if activity.Empty() {
+		return nil
+	}
+
+	// The session context is cancelled by now
+	ctx, cancel := context.WithTimeout(context.Background(), rs.Config.SessionSummaryTimeout)
+	defer cancel()
+	summary, err := rs.openAIClient().SummarizeSession(ctx, activity)
+	if err != nil {
+		rs.Logger.Warn("Failed to summarize session", zap.Error(err))
+		rs.MetricLabels.ProviderError("openai")
+		return nil
+	}
+	summary.SessionID = rs.ID
+	summary.TenantID = rs.Tenant.ID
+	summary.RobotID = rs.RobotID
+	summary.UserID = rs.UserID
+	summary.StartTime = rs.StartTime
+	summary.EndTime = endTime
+	summary.GeneratedAt = rs.Clock.Now()
+
+	if rs.RedisClient != nil {
+		if err := utils.SaveSessionSummary(ctx, rs.RedisClient, *summary); err != nil {
+			rs.Logger.Warn("Failed to save session summary", zap.Error(err))
+		}
+	}
+	rs.Logger.Info("Session summarized",
+		zap.Int("requests", len(summary.UserRequests)),
+		zap.Int("follow_ups", len(summary.FollowUps)))
+	return summary
+}
+
+// HandleSessionSummary returns an ended session's summary:
+// GET /robot/sessions/{id}/summary
+func HandleSessionSummary(w http.ResponseWriter, r *http.Request, redisClient *redis.Client, tenants *utils.TenantStore) {
+	tenant, err := tenants.Resolve(r)
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	summary, err := utils.LoadSessionSummary(r.Context(), redisClient, r.PathValue("id"))
+	if err != nil {
+		zap.L().Error("Failed to load session summary", zap.String("tenant_id", tenant.ID), zap.Error(err))
+		http.Error(w, "failed to load session summary", http.StatusInternalServerError)
+		return
+	}
+	if summary == nil || summary.TenantID != tenant.ID {
+		http.Error(w, "summary not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summary)
+}
+
+// HandleRobotDigest returns the daily digest of a robot: the summaries of
+// its sessions that ended on a UTC day, oldest first, with their follow-ups
+// collected. GET /robot/summaries?robot_id=...[&date=2006-01-02]
+// Without robot_id it covers the sessions opened without one.
+func HandleRobotDigest(w http.ResponseWriter, r *http.Request, redisClient *redis.Client, tenants *utils.TenantStore) {
+	tenant, err := tenants.Resolve(r)
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	query := r.URL.Query()
+	day := time.Now().UTC().Truncate(24 * time.Hour)
+	if value := query.Get("date"); value != "" {
+		if day, err = time.Parse(time.DateOnly, value); err != nil {
+			http.Error(w, "date must be YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+	}
+	robotID := query.Get("robot_id")
+
+	summaries, err := utils.LoadRobotSessionSummaries(r.Context(), redisClient, tenant.ID, robotID, day, day.AddDate(0, 0, 1))
+	if err != nil {
+		zap.L().Error("Failed to load session summaries", zap.String("tenant_id", tenant.ID), zap.Error(err))
+		http.Error(w, "failed to load session summaries", http.StatusInternalServerError)
+		return
+	}
+	followUps := []string{}
+	for _, summary := range summaries {
+		followUps = append(followUps, summary.FollowUps...)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"robot_id":   robotID,
+		"date":       day.Format(time.DateOnly),
+		"sessions":   len(summaries),
+		"follow_ups": followUps,
+		"summaries":  summaries,
+	})
+}
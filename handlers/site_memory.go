@@ -0,0 +1,218 @@
+// handlers/site_memory.go
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// SiteMemory is the world model shared by the robots at one site (the site
+// session metadata). Every analyzed frame taken at a known location (the
+// location of robot_state) replaces that location's entry, so a robot can
+// answer questions about areas only another robot has seen. Entries are
+// stored in Redis by normalized location tag and indexed in the tenant's
+// Pinecone namespace for recall; a nil SiteMemory does nothing.
+type SiteMemory struct {
+	session *RoboSession
+	site    string
+	// pineconeIdx is nil without Pinecone; recall then uses the most
+	// recently observed locations
+	pineconeIdx *utils.PineconeIndex
+	topK        int
+	ttl         time.Duration
+}
+
+// InitSiteMemory returns the session's site memory, or nil when
+// SITE_MEMORY_ENABLED is off or the client did not name its site.
+func InitSiteMemory(session *RoboSession) *SiteMemory {
+	site := session.Metadata[METADATA_SITE]
+	if !session.Config.SiteMemoryEnabled || site == "" || session.RedisClient == nil {
+		return nil
+	}
+
+	memory := &SiteMemory{
+		session: session,
+		site:    site,
+		topK:    session.Config.SiteMemoryTopK,
+		ttl:     session.Config.SiteMemoryTTL,
+	}
+	index, err := session.pineconeIndex()
+	if err != nil {
+		session.Logger.Warn("Site memory without Pinecone, recalling latest observations", zap.Error(err))
+	} else {
+		memory.pineconeIdx = index
+	}
+	session.Logger.Info("Site memory enabled", zap.String("site", site))
+	return memory
+}
+
+// Observe merges an environment context into the site memory. Contexts
+// without a location and those of incognito sessions are not shared.
+func (m *SiteMemory) Observe(envContext models.EnvironmentContext) {
+	if m == nil || m.session.Incognito || utils.SiteLocationTag(envContext.Location) == "" {
+		return
+	}
+	rs := m.session
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	observation, err := utils.MergeSiteObservation(ctx, rs.RedisClient, rs.Tenant.ID, models.SiteObservation{
+		Site:        m.site,
+		Location:    envContext.Location,
+		Overview:    envContext.Overview,
+		KeyElements: envContext.KeyElements,
+		Layout:      envContext.Layout,
+		Activities:  envContext.Activities,
+		RobotID:     rs.RobotID,
+		SessionID:   rs.ID,
+		ContextID:   envContext.ID,
+		ObservedAt:  envContext.Timestamp,
+	}, m.ttl)
+	if err != nil {
+		rs.Logger.Warn("Failed to update site memory", zap.Error(err))
+		return
+	}
+	if observation.ContextID == envContext.ID {
+		m.index(observation)
+	}
+}
+
+// index queues an observation for the memory store under the location's
+// stable ID, replacing the previous observation.
+func (m *SiteMemory) index(observation models.SiteObservation) {
+	if m.pineconeIdx == nil {
+		return
+	}
+	rs := m.session
+	vectorID := utils.SiteObservationID(observation.Site, observation.Location)
+	text := utils.FormatSiteObservation(observation)
+	pineconeWriter().Enqueue(utils.PineconeWrite{
+		Index: m.pineconeIdx,
+		ID:    vectorID,
+		Text:  text,
+		Metadata: map[string]interface{}{
+			"text":       text,
+			"type":       utils.SITE_MEMORY_RECORD_TYPE,
+			"site":       observation.Site,
+			"location":   observation.Location,
+			"robot_id":   observation.RobotID,
+			"session_id": observation.SessionID,
+			"context_id": observation.ContextID,
+			"timestamp":  observation.ObservedAt.Unix(),
+		},
+		OnFailure: func(err error) {
+			rs.Logger.Error("Failed to upsert site observation to Pinecone", zap.Error(err), zap.String("vector_id", vectorID))
+			rs.MetricLabels.ProviderError("pinecone")
+		},
+	})
+}
+
+// Recall returns the site observations most relevant to a transcript, or
+// the most recent ones when the memory store cannot be searched, each with
+// its age.
+func (m *SiteMemory) Recall(ctx context.Context, transcript string) []string {
+	if m == nil {
+		return nil
+	}
+	rs := m.session
+	now := rs.Clock.Now()
+	if m.pineconeIdx != nil {
+		matches, err := m.search(ctx, transcript)
+		if err == nil {
+			recalled := make([]string, 0, len(matches))
+			for _, match := range matches {
+				recalled = append(recalled, siteMemoryLine(match.Text, match.Timestamp, now))
+			}
+			return recalled
+		}
+		rs.Logger.Warn("Failed to search site memory, using latest observations", zap.Error(err))
+		rs.MetricLabels.ProviderError("pinecone")
+	}
+
+	observations, err := utils.LoadSiteMemory(ctx, rs.RedisClient, rs.Tenant.ID, m.site)
+	if err != nil {
+		rs.Logger.Warn("Failed to load site memory", zap.Error(err))
+		return nil
+	}
+	recalled := make([]string, 0, m.topK)
+	for _, observation := range observations[:min(len(observations), m.topK)] {
+		recalled = append(recalled, siteMemoryLine(utils.FormatSiteObservation(observation), observation.ObservedAt, now))
+	}
+	return recalled
+}
+
+func (m *SiteMemory) search(ctx context.Context, transcript string) ([]utils.PineconeMatch, error) {
+	idx, err := m.pineconeIdx.Conn()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, m.session.Config.PineconeQueryTimeout)
+	defer cancel()
+	return utils.SearchPinecone(ctx, idx, utils.PineconeQuery{
+		Text:  transcript,
+		TopK:  m.topK,
+		Site:  m.site,
+		Types: []string{utils.SITE_MEMORY_RECORD_TYPE},
+	})
+}
+
+// Lookup answers the get_site_memory tool: one location's observation, or
+// every location of the site.
+func (m *SiteMemory) Lookup(ctx context.Context, location string) (interface{}, error) {
+	rs := m.session
+	if location != "" {
+		observation, err := utils.LoadSiteObservation(ctx, rs.RedisClient, rs.Tenant.ID, m.site, location)
+		if err != nil {
+			return nil, err
+		}
+		if observation == nil {
+			return map[string]interface{}{"site": m.site, "location": utils.SiteLocationTag(location), "known": false}, nil
+		}
+		return map[string]interface{}{"site": m.site, "known": true, "observation": observation}, nil
+	}
+	observations, err := utils.LoadSiteMemory(ctx, rs.RedisClient, rs.Tenant.ID, m.site)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"site": m.site, "observations": observations}, nil
+}
+
+func siteMemoryLine(text string, observedAt, now time.Time) string {
+	if observedAt.IsZero() {
+		return "[site memory] " + text
+	}
+	return fmt.Sprintf("[site memory, observed %s ago] %s", now.Sub(observedAt).Round(time.Minute), text)
+}
+
+// HandleSiteMemory lists what the robots at a site have observed, most
+// recently observed first: GET /robot/sites/{site}/memory
+func HandleSiteMemory(w http.ResponseWriter, r *http.Request, redisClient *redis.Client, tenants *utils.TenantStore) {
+	tenant, err := tenants.Resolve(r)
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	site := r.PathValue("site")
+
+	observations, err := utils.LoadSiteMemory(r.Context(), redisClient, tenant.ID, site)
+	if err != nil {
+		zap.L().Error("Failed to load site memory", zap.String("tenant_id", tenant.ID), zap.String("site", site), zap.Error(err))
+		http.Error(w, "failed to load site memory", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"site":         site,
+		"observations": observations,
+	})
+}
@@ -0,0 +1,190 @@
+// handlers/stt_settings.go
+
+package handlers
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+)
+
+func (rs *RoboSession) sttSettings() utils.STTOptions {
+	rs.stateMu.Lock()
+	defer rs.stateMu.Unlock()
+	return rs.stt
+}
+
+// applySTTConfig updates the Deepgram options from a config payload. It
+// returns the offending field on invalid values and whether the options
+// changed, in which case the stream must be reconnected to apply them.
+func (rs *RoboSession) applySTTConfig(configData map[string]interface{}) (string, bool, error) {
+	current := rs.sttSettings()
+	settings := current
+
+	for _, field := range []struct {
+		key    string
+		target *int
+	}{
+		{"stt_endpointing_ms", &settings.EndpointingMs},
+		{"stt_utterance_end_ms", &settings.UtteranceEndMs},
+	} {
+		value, exists := configData[field.key]
+		if !exists {
+			continue
+		}
+		ms, ok := value.(float64)
+		if !ok || ms < 0 || ms != float64(int(ms)) {
+			return "data." + field.key, false, fmt.Errorf("must be a non-negative integer of milliseconds")
+		}
+		*field.target = int(ms)
+	}
+	for _, field := range []struct {
+		key    string
+		target *bool
+	}{
+		{"stt_interim_results", &settings.InterimResults},
+		{"stt_filler_words", &settings.FillerWords},
+		{"stt_smart_format", &settings.SmartFormat},
+	} {
+		value, exists := configData[field.key]
+		if !exists {
+			continue
+		}
+		enabled, ok := value.(bool)
+		if !ok {
+			return "data." + field.key, false, fmt.Errorf("must be a boolean")
+		}
+		*field.target = enabled
+	}
+	for _, field := range []struct {
+		key    string
+		target *[]string
+	}{
+		{"stt_keywords", &settings.Keywords},
+		{"stt_keyterms", &settings.Keyterms},
+	} {
+		value, exists := configData[field.key]
+		if !exists {
+			continue
+		}
+		terms, err := parseTerms(value)
+		if err != nil {
+			return "data." + field.key, false, err
+		}
+		*field.target = terms
+	}
+
+	if value, exists := configData["stt_language"]; exists {
+		language, ok := value.(string)
+		if !ok || language == "" {
+			return "data.stt_language", false, fmt.Errorf("must be a non-empty language code")
+		}
+		settings.Language = language
+	}
+
+	if err := settings.Validate(); err != nil {
+		return "data.stt_utterance_end_ms", false, err
+	}
+	if reflect.DeepEqual(settings, current) {
+		return "", false, nil
+	}
+
+	rs.stateMu.Lock()
+	rs.stt = settings
+	rs.stateMu.Unlock()
+	return "", true, nil
+}
+
+func parseTerms(value interface{}) ([]string, error) {
+	list, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("must be an array of strings")
+	}
+	var terms []string
+	for _, item := range list {
+		term, ok := item.(string)
+		if !ok || term == "" {
+			return nil, fmt.Errorf("must be an array of non-empty strings")
+		}
+		terms = append(terms, term)
+	}
+	return terms, nil
+}
+
+// sttConfig returns the options in config payload form.
+func sttConfig(o utils.STTOptions) map[string]interface{} {
+	keywords, keyterms := o.Keywords, o.Keyterms
+	if keywords == nil {
+		keywords = []string{}
+	}
+	if keyterms == nil {
+		keyterms = []string{}
+	}
+	return map[string]interface{}{
+		"stt_endpointing_ms":   o.EndpointingMs,
+		"stt_utterance_end_ms": o.UtteranceEndMs,
+		"stt_interim_results":  o.InterimResults,
+		"stt_filler_words":     o.FillerWords,
+		"stt_smart_format":     o.SmartFormat,
+		"stt_keywords":         keywords,
+		"stt_keyterms":         keyterms,
+		"stt_language":         o.Language,
+	}
+}
+
+// changedSTTSettings lists the config keys whose values differ between two
+// sets of options.
+func changedSTTSettings(before, after utils.STTOptions) []string {
+	previous, current := sttConfig(before), sttConfig(after)
+	var changed []string
+	for key, value := range current {
+		if !reflect.DeepEqual(previous[key], value) {
+			changed = append(changed, key)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// applyAudioFormatConfig switches the format of the audio the robot sends.
+// It returns the offending field on invalid values and whether the format
+// changed; the caller then reconnects the stream, which is torn down here.
+func (rs *RoboSession) applyAudioFormatConfig(configData map[string]interface{}) (string, bool, error) {
+	if rs.AudioHandler == nil {
+		return "", false, nil
+	}
+	format := rs.AudioHandler.Format()
+	encoding, encodingExists := configData["audio_encoding"]
+	sampleRate, sampleRateExists := configData["audio_sample_rate"]
+	if !encodingExists && !sampleRateExists {
+		return "", false, nil
+	}
+	if encodingExists {
+		value, ok := encoding.(string)
+		if !ok {
+			return "data.audio_encoding", false, fmt.Errorf("must be a string")
+		}
+		format.Encoding = value
+	}
+	if sampleRateExists {
+		hz, ok := sampleRate.(float64)
+		if !ok || hz != float64(int(hz)) || !utils.ValidAudioSampleRate(int(hz)) {
+			return "data.audio_sample_rate", false, fmt.Errorf("must be one of 8000, 16000, 22050, 24000, 32000, 44100 or 48000 Hz")
+		}
+		format.SampleRate = int(hz)
+	}
+	// Raw audio needs a sample rate; containers let the backend detect it
+	if format.Encoding == "" {
+		format.SampleRate = 0
+	} else if format.SampleRate == 0 {
+		format.SampleRate = rs.Config.AudioSampleRate
+	}
+
+	changed, err := rs.AudioHandler.SetFormat(format)
+	if err != nil {
+		return "data.audio_encoding", false, err
+	}
+	return "", changed, nil
+}
@@ -0,0 +1,158 @@
+// handlers/supervisor.go
+
+package handlers
+
+import (
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Supervisor runs a session's goroutines so a panic in one of them fails the
+// session, not the process. Workers (the transcript loop, the video loop,
+// the outbound writer) are restarted after a backoff doubling from
+// SUPERVISOR_BACKOFF up to SUPERVISOR_MAX_BACKOFF; tasks (one frame's
+// analysis, one archive write) are not, since they would panic on the same
+// input again. Critical workers (the WebSocket read loop) are left in an
+// undefined state by a panic, so it terminates the session at once.
+// Otherwise, once SUPERVISOR_MAX_RESTARTS panics happened within
+// SUPERVISOR_RESTART_WINDOW the session is terminated with E_SESSION_FAILED.
+type Supervisor struct {
+	session *RoboSession
+
+	mu     sync.Mutex
+	panics []time.Time
+	failed bool
+
+	maxRestarts int
+	window      time.Duration
+	backoff     time.Duration
+	maxBackoff  time.Duration
+
+	// fail terminates the session; tests replace it
+	fail func(message string, fields ...zap.Field)
+}
+
+func NewSupervisor(session *RoboSession) *Supervisor {
+	s := &Supervisor{
+		session:     session,
+		maxRestarts: session.Config.SupervisorMaxRestarts,
+		window:      session.Config.SupervisorRestartWindow,
+		backoff:     session.Config.SupervisorBackoff,
+		maxBackoff:  session.Config.SupervisorMaxBackoff,
+	}
+	s.fail = s.terminate
+	return s
+}
+
+// ended reports whether the session was torn down, which the workers'
+// panics no longer count against.
+func (s *Supervisor) ended() bool {
+	return s.session.sessionCtx.Err() != nil
+}
+
+// Go runs worker in a goroutine, restarting it after a panic until it
+// returns normally, the session ends or the restart limit is reached.
+func (s *Supervisor) Go(name string, worker func()) {
+	go func() {
+		for attempt := 0; ; attempt++ {
+			if !s.run(name, worker) {
+				return
+			}
+			if s.ended() || !s.recordPanic() {
+				return
+			}
+
+			backoff := min(s.backoff<<min(attempt, 16), s.maxBackoff)
+			s.session.Logger.Warn("Restarting session worker",
+				zap.String("worker", name), zap.Int("restart", attempt+1), zap.Duration("backoff", backoff))
+			select {
+			case <-s.session.sessionCtx.Done():
+				return
+			case <-s.session.Clock.After(backoff):
+			}
+		}
+	}()
+}
+
+// Task runs task in a goroutine, recovering from a panic without rerunning
+// it.
+func (s *Supervisor) Task(name string, task func()) {
+	go func() {
+		if s.run(name, task) && !s.ended() {
+			s.recordPanic()
+		}
+	}()
+}
+
+// Critical runs worker in a goroutine and terminates the session if it
+// panics, for workers that cannot resume where the panic left them.
+func (s *Supervisor) Critical(name string, worker func()) {
+	go func() {
+		if !s.run(name, worker) || s.ended() {
+			return
+		}
+		s.mu.Lock()
+		failed := s.failed
+		s.failed = true
+		s.mu.Unlock()
+		if !failed {
+			s.fail("Terminating session, critical worker crashed", zap.String("worker", name))
+		}
+	}()
+}
+
+// run calls fn and reports whether it panicked.
+func (s *Supervisor) run(name string, fn func()) (panicked bool) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			panicked = true
+			s.session.Logger.Error("Recovered from session worker panic",
+				zap.String("worker", name),
+				zap.Any("panic", recovered),
+				zap.ByteString("stack", debug.Stack()))
+			s.session.MetricLabels.WorkerPanic(name)
+		}
+	}()
+	fn()
+	return false
+}
+
+// recordPanic counts a panic against the restart limit, terminating the
+// session once it is exceeded. It reports whether the session carries on.
+func (s *Supervisor) recordPanic() bool {
+	s.mu.Lock()
+	if s.failed {
+		s.mu.Unlock()
+		return false
+	}
+	now := s.session.Clock.Now()
+	recent := s.panics[:0]
+	for _, at := range s.panics {
+		if now.Sub(at) < s.window {
+			recent = append(recent, at)
+		}
+	}
+	s.panics = append(recent, now)
+	s.failed = len(s.panics) > s.maxRestarts
+	failed := s.failed
+	s.mu.Unlock()
+
+	if failed {
+		s.fail("Terminating session, workers keep crashing",
+			zap.Int("panics", s.maxRestarts+1), zap.Duration("window", s.window))
+	}
+	return !failed
+}
+
+// terminate ends a session whose workers crashed. The snapshot is kept so
+// the client can resume once the cause is fixed.
+func (s *Supervisor) terminate(message string, fields ...zap.Field) {
+	rs := s.session
+	rs.Logger.Error(message, fields...)
+	rs.setEnding(SESSION_END_REASON_FAILED, false)
+	rs.sendError(ERROR_CODE_SESSION_FAILED, "", "Session failed after an internal error")
+	rs.Stop()
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+	"go.uber.org/zap"
+)
+
+// newTestSupervisor supervises a bare session; failures receives the
+// message of each termination.
+func newTestSupervisor(t *testing.T, maxRestarts int) (*Supervisor, <-chan string, context.CancelFunc) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	session := &RoboSession{
+		Clock:         utils.SystemClock{},
+		Logger:        zap.NewNop(),
+		MetricLabels:  utils.NewMetricLabels("test", "", "", ""),
+		sessionCtx:    ctx,
+		cancelSession: cancel,
+	}
+	failures := make(chan string, 4)
+	s := &Supervisor{
+		session:     session,
+		maxRestarts: maxRestarts,
+		window:      time.Minute,
+		backoff:     time.Millisecond,
+		maxBackoff:  time.Millisecond,
+		fail: func(message string, fields ...zap.Field) {
+			failures <- message
+		},
+	}
+	return s, failures, cancel
+}
+
+func TestSupervisorRestartsWorker(t *testing.T) {
+	s, failures, _ := newTestSupervisor(t, 5)
+	var runs atomic.Int32
+	done := make(chan struct{})
+	s.Go("worker", func() {
+		if runs.Add(1) < 3 {
+			panic("boom")
+		}
+		close(done)
+	})
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("worker not restarted: got = %d runs, want 3", runs.Load())
+	}
+	select {
+	case message := <-failures:
+		t.Errorf("session terminated: %s", message)
+	default:
+	}
+}
+
+func TestSupervisorTerminatesAfterRestartLimit(t *testing.T) {
+	s, failures, _ := newTestSupervisor(t, 2)
+	var runs atomic.Int32
+	s.Go("worker", func() {
+		runs.Add(1)
+		panic("boom")
+	})
+
+	select {
+	case <-failures:
+	case <-time.After(time.Second):
+		t.Fatal("session not terminated")
+	}
+	time.Sleep(20 * time.Millisecond)
+	if got := runs.Load(); got != 3 {
+		t.Errorf("got = %d runs, want 3", got)
+	}
+}
+
+func TestSupervisorTerminatesOnCriticalPanic(t *testing.T) {
+	s, failures, _ := newTestSupervisor(t, 5)
+	var runs atomic.Int32
+	s.Critical("listener", func() {
+		runs.Add(1)
+		panic("boom")
+	})
+
+	select {
+	case <-failures:
+	case <-time.After(time.Second):
+		t.Fatal("session not terminated")
+	}
+	time.Sleep(20 * time.Millisecond)
+	if got := runs.Load(); got != 1 {
+		t.Errorf("got = %d runs, want 1", got)
+	}
+}
+
+func TestSupervisorIgnoresPanicsAfterSessionEnd(t *testing.T) {
+	s, failures, cancel := newTestSupervisor(t, 0)
+	cancel()
+	var runs atomic.Int32
+	s.Go("worker", func() {
+		runs.Add(1)
+		panic("boom")
+	})
+	s.Task("task", func() { panic("boom") })
+	s.Critical("listener", func() { panic("boom") })
+
+	time.Sleep(20 * time.Millisecond)
+	if got := runs.Load(); got != 1 {
+		t.Errorf("got = %d runs, want 1", got)
+	}
+	select {
+	case message := <-failures:
+		t.Errorf("ended session terminated: %s", message)
+	default:
+	}
+}
+
+func TestSupervisorDoesNotRerunTasks(t *testing.T) {
+	s, failures, _ := newTestSupervisor(t, 5)
+	var runs atomic.Int32
+	done := make(chan struct{})
+	s.Task("task", func() {
+		defer close(done)
+		runs.Add(1)
+		panic("boom")
+	})
+
+	<-done
+	time.Sleep(20 * time.Millisecond)
+	if got := runs.Load(); got != 1 {
+		t.Errorf("got = %d runs, want 1", got)
+	}
+	select {
+	case message := <-failures:
+		t.Errorf("session terminated after one panic: %s", message)
+	default:
+	}
+}
@@ -0,0 +1,33 @@
+// handlers/tenant_handler.go
+
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+	"go.uber.org/zap"
+)
+
+// HandleTenantUsage returns the usage counters of the caller's tenant.
+func HandleTenantUsage(w http.ResponseWriter, r *http.Request, tenants *utils.TenantStore) {
+	tenant, err := tenants.Resolve(r)
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	usage, err := tenants.Usage(r.Context(), tenant.ID)
+	if err != nil {
+		zap.L().Error("Failed to read tenant usage", zap.String("tenant_id", tenant.ID), zap.Error(err))
+		http.Error(w, "failed to read usage", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"tenant_id": tenant.ID,
+		"usage":     usage,
+	})
+}
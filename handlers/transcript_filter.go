@@ -0,0 +1,24 @@
+// handlers/transcript_filter.go
+
+package handlers
+
+import (
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+	"go.uber.org/zap"
+)
+
+// filterTranscript applies the tenant's transcript filters to text as it
+// enters the session, so profanity and personal data never reach intention
+// analysis, memory, the archive, the orchestrator or the logs.
+func (rs *RoboSession) filterTranscript(text string) string {
+	filtered, counts := rs.transcriptFilter.Apply(text)
+	if len(counts) > 0 {
+		rs.Logger.Debug("Filtered transcript", zap.Any("matches", counts))
+	}
+	return filtered
+}
+
+func newSessionTranscriptFilter(rs *RoboSession) *utils.TranscriptFilter {
+	tenant := rs.credentials()
+	return utils.NewTranscriptFilter(tenant.TranscriptFilters, tenant.ProfanityWords)
+}
@@ -0,0 +1,257 @@
+// handlers/transcript_search.go
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// Modes of GET /search.
+const (
+	SEARCH_MODE_KEYWORD  = "keyword"
+	SEARCH_MODE_SEMANTIC = "semantic"
+)
+
+const (
+	defaultSearchResults = 20
+	maxSearchResults     = 100
+)
+
+// TranscriptMatch is an utterance found by a transcript search. Score is
+// the number of keyword occurrences, or the semantic similarity.
+type TranscriptMatch struct {
+	SessionID string `json:"session_id"`
+	// IntentionID identifies the utterance's intention analysis
+	IntentionID string    `json:"intention_id"`
+	RobotID     string    `json:"robot_id,omitempty"`
+	Text        string    `json:"text"`
+	Timestamp   time.Time `json:"timestamp"`
+	Score       float64   `json:"score"`
+	// SessionURL lists the session's intentions
+	SessionURL string `json:"session_url"`
+}
+
+// transcriptSearch is a parsed GET /search request.
+type transcriptSearch struct {
+	text     string
+	robotID  string
+	from, to time.Time
+	limit    int
+}
+
+// HandleTranscriptSearch finds utterances in the caller's archived
+// transcripts, by keyword (every word must occur, newest first) or by
+// meaning through the memory store (best first):
+// GET /search?q=...[&mode=keyword|semantic&robot_id=...&from=...&to=...&limit=20]
+func HandleTranscriptSearch(w http.ResponseWriter, r *http.Request, redisClient *redis.Client, tenants *utils.TenantStore) {
+	tenant, err := tenants.Resolve(r)
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	query := r.URL.Query()
+	search := transcriptSearch{
+		text:    strings.TrimSpace(query.Get("q")),
+		robotID: query.Get("robot_id"),
+		limit:   defaultSearchResults,
+	}
+	if search.text == "" {
+		http.Error(w, "q is required", http.StatusBadRequest)
+		return
+	}
+	if search.robotID != "" && !utils.ValidRobotID(search.robotID) {
+		http.Error(w, "invalid robot ID", http.StatusBadRequest)
+		return
+	}
+	for _, bound := range []struct {
+		name   string
+		target *time.Time
+	}{
+		{"from", &search.from},
+		{"to", &search.to},
+	} {
+		value := query.Get(bound.name)
+		if value == "" {
+			continue
+		}
+		if *bound.target, err = time.Parse(time.RFC3339, value); err != nil {
+			http.Error(w, bound.name+" must be an RFC 3339 timestamp", http.StatusBadRequest)
+			return
+		}
+	}
+	if !search.from.IsZero() && !search.to.IsZero() && search.to.Before(search.from) {
+		http.Error(w, "to is before from", http.StatusBadRequest)
+		return
+	}
+	if value := query.Get("limit"); value != "" {
+		search.limit, err = strconv.Atoi(value)
+		if err != nil || search.limit < 1 || search.limit > maxSearchResults {
+			http.Error(w, "limit must be between 1 and 100", http.StatusBadRequest)
+			return
+		}
+	}
+
+	mode := query.Get("mode")
+	var matches []TranscriptMatch
+	switch mode {
+	case "", SEARCH_MODE_KEYWORD:
+		mode = SEARCH_MODE_KEYWORD
+		matches, err = searchTranscriptKeywords(r.Context(), redisClient, tenant, search)
+		if err != nil {
+			zap.L().Error("Failed to search transcripts", zap.String("tenant_id", tenant.ID), zap.Error(err))
+			http.Error(w, "failed to search transcripts", http.StatusInternalServerError)
+			return
+		}
+	case SEARCH_MODE_SEMANTIC:
+		if tenant.PineconeAPIKey == "" || tenant.PineconeHost == "" {
+			http.Error(w, "semantic search is not configured", http.StatusServiceUnavailable)
+			return
+		}
+		matches, err = searchTranscriptMeaning(r.Context(), redisClient, tenant, search)
+		if err != nil {
+			zap.L().Error("Failed to search transcripts", zap.String("tenant_id", tenant.ID), zap.Error(err))
+			http.Error(w, "failed to search transcripts", http.StatusBadGateway)
+			return
+		}
+	default:
+		http.Error(w, "mode must be keyword or semantic", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"query":   search.text,
+		"mode":    mode,
+		"results": matches,
+	})
+}
+
+// searchTranscriptKeywords looks the terms up in the tenant's transcript
+// index, which follows the archive's retention and encryption. Utterances
+// whose session description expired are left out.
+func searchTranscriptKeywords(ctx context.Context, rdb *redis.Client, tenant *models.Tenant, search transcriptSearch) ([]TranscriptMatch, error) {
+	terms := utils.SearchTerms(search.text)
+	sessions := make(map[string]*models.SessionMeta)
+	matches := []TranscriptMatch{}
+	err := utils.SearchTranscripts(ctx, rdb, tenant.ID, terms, search.from, search.to, func(record models.AnalysisRecord) bool {
+		// The index matches up to maxIndexedPrefix runes of a term
+		score := keywordScore(terms, record.Transcript)
+		if score == 0 {
+			return true
+		}
+		meta, known := sessions[record.SessionID]
+		if !known {
+			var err error
+			meta, err = utils.LoadSessionMeta(ctx, rdb, record.SessionID)
+			if err != nil || meta.TenantID != tenant.ID {
+				meta = nil
+			}
+			sessions[record.SessionID] = meta
+		}
+		if meta == nil || (search.robotID != "" && meta.Metadata[METADATA_ROBOT_ID] != search.robotID) {
+			return true
+		}
+		matches = append(matches, transcriptMatch(*meta, record.ID, record.Transcript, record.Timestamp, float64(score)))
+		return len(matches) < search.limit
+	})
+	if err != nil {
+		return nil, err
+	}
+	return matches, nil
+}
+
+// searchTranscriptMeaning searches the intention records of the memory
+// store. Records whose session is not the tenant's, or whose session
+// description expired, are left out.
+func searchTranscriptMeaning(ctx context.Context, rdb *redis.Client, tenant *models.Tenant, search transcriptSearch) ([]TranscriptMatch, error) {
+	idx, err := utils.SharedPineconeIndex(tenant.PineconeAPIKey, tenant.PineconeHost, tenant.PineconeNamespace)
+	if err != nil {
+		return nil, err
+	}
+
+	searchCtx, cancel := context.WithTimeout(ctx, utils.CurrentConfig().PineconeQueryTimeout)
+	defer cancel()
+	hits, err := utils.SearchPinecone(searchCtx, idx, utils.PineconeQuery{
+		Text:    search.text,
+		TopK:    search.limit,
+		Types:   []string{INTENTION_RECORD_TYPE},
+		RobotID: search.robotID,
+		Since:   search.from,
+		Until:   search.to,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make(map[string]*models.SessionMeta)
+	matches := []TranscriptMatch{}
+	for _, hit := range hits {
+		meta, known := sessions[hit.SessionID]
+		if !known {
+			meta, err = utils.LoadSessionMeta(ctx, rdb, hit.SessionID)
+			if err != nil || meta.TenantID != tenant.ID {
+				meta = nil
+			}
+			sessions[hit.SessionID] = meta
+		}
+		if meta == nil {
+			continue
+		}
+		intentionID := strings.TrimSuffix(hit.ID, intentionRecordSuffix)
+		matches = append(matches, transcriptMatch(*meta, intentionID, recordTranscript(hit.Text), hit.Timestamp, hit.Score))
+	}
+	return matches, nil
+}
+
+func transcriptMatch(meta models.SessionMeta, intentionID, text string, timestamp time.Time, score float64) TranscriptMatch {
+	return TranscriptMatch{
+		SessionID:   meta.ID,
+		IntentionID: intentionID,
+		RobotID:     meta.Metadata[METADATA_ROBOT_ID],
+		Text:        text,
+		Timestamp:   timestamp,
+		Score:       score,
+		SessionURL:  "/robot/sessions/" + meta.ID + "/intentions",
+	}
+}
+
+// keywordScore counts the words of a transcript that start with a term, 0
+// unless every term occurs, so "charg" finds "charger" and "charging".
+func keywordScore(terms []string, transcript string) int {
+	words := utils.SearchTerms(transcript)
+	score := 0
+	for _, term := range terms {
+		found := 0
+		for _, word := range words {
+			if strings.HasPrefix(word, term) {
+				found++
+			}
+		}
+		if found == 0 {
+			return 0
+		}
+		score += found
+	}
+	return score
+}
+
+// recordTranscript extracts what was said from the text of an intention
+// record.
+func recordTranscript(text string) string {
+	line, _, _ := strings.Cut(text, "\n")
+	if transcript, ok := strings.CutPrefix(line, "Transcript: "); ok {
+		return transcript
+	}
+	return text
+}
@@ -0,0 +1,79 @@
+// handlers/transcript_settings.go
+
+package handlers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+)
+
+// TranscriptSettings control how final Deepgram segments are accumulated into
+// an utterance before intention analysis.
+type TranscriptSettings struct {
+	// MaxLength flushes the buffer once it holds this many characters
+	MaxLength int
+	// FlushAfter flushes the buffer this long after its first segment even
+	// without an UtteranceEnd (0 disables)
+	FlushAfter time.Duration
+	// EchoInterim sends transcript_interim messages while accumulating
+	EchoInterim bool
+}
+
+func defaultTranscriptSettings(cfg *utils.Config) TranscriptSettings {
+	return TranscriptSettings{
+		MaxLength:   cfg.TranscriptMaxLength,
+		FlushAfter:  cfg.TranscriptFlushAfter,
+		EchoInterim: cfg.TranscriptEchoInterim,
+	}
+}
+
+func (rs *RoboSession) transcriptSettings() TranscriptSettings {
+	rs.stateMu.Lock()
+	defer rs.stateMu.Unlock()
+	return rs.transcript
+}
+
+// applyTranscriptConfig updates the settings from a config payload and
+// returns the offending field on invalid values.
+func (rs *RoboSession) applyTranscriptConfig(configData map[string]interface{}) (string, error) {
+	settings := rs.transcriptSettings()
+
+	if value, exists := configData["transcript_max_length"]; exists {
+		length, ok := value.(float64)
+		if !ok || length < 1 || length != float64(int(length)) {
+			return "data.transcript_max_length", fmt.Errorf("must be a positive integer")
+		}
+		settings.MaxLength = int(length)
+	}
+	if value, exists := configData["transcript_flush_after"]; exists {
+		str, _ := value.(string)
+		duration, err := time.ParseDuration(str)
+		if err != nil || duration < 0 {
+			return "data.transcript_flush_after", fmt.Errorf("must be a non-negative duration, e.g. 20s")
+		}
+		settings.FlushAfter = duration
+	}
+	if value, exists := configData["echo_interim_transcripts"]; exists {
+		echo, ok := value.(bool)
+		if !ok {
+			return "data.echo_interim_transcripts", fmt.Errorf("must be a boolean")
+		}
+		settings.EchoInterim = echo
+	}
+
+	rs.stateMu.Lock()
+	rs.transcript = settings
+	rs.stateMu.Unlock()
+	return "", nil
+}
+
+// transcriptConfig returns the settings in config payload form.
+func (s TranscriptSettings) transcriptConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"transcript_max_length":    s.MaxLength,
+		"transcript_flush_after":   s.FlushAfter.String(),
+		"echo_interim_transcripts": s.EchoInterim,
+	}
+}
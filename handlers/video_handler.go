@@ -4,19 +4,23 @@ package handlers
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
 	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
-	"github.com/pinecone-io/go-pinecone/v4/pinecone"
 	"go.uber.org/zap"
 )
 
 type VideoHandler struct {
 	session      *RoboSession
 	openaiClient *utils.OpenAIClient
-	pineconeIdx  *pinecone.IndexConnection
+	pineconeIdx  *utils.PineconeIndex
+	compactor    *MemoryCompactor
+	pipeline     *FramePipeline
 	isActive     bool
 }
 
@@ -24,11 +28,11 @@ func InitVideoHandler(session *RoboSession) *VideoHandler {
 	session.Logger.Info("Initializing Video Handler...")
 
 	// Initialize OpenAI client
-	openaiClient := utils.NewOpenAIClient()
+	openaiClient := session.openAIClient()
 
 	// Initialize Pinecone connection
-	pineconeIdx, err := utils.GetPineconeIndex(&session.ID)
-	if err != nil {
+	pineconeIdx, err := session.pineconeIndex()
+	if err != nil && !errors.Is(err, errMemoryDisabled) {
 		session.Logger.Warn("Failed to initialize Pinecone connection", zap.Error(err))
 		// Continue without Pinecone - we'll still do video analysis
 	}
@@ -39,78 +43,243 @@ func InitVideoHandler(session *RoboSession) *VideoHandler {
 		pineconeIdx:  pineconeIdx,
 		isActive:     true,
 	}
+	videoHandler.pipeline = newFramePipeline(session, videoHandler.builtinStages())
+
+	// World state lives in Redis and Pinecone, so incognito sessions skip it
+	if !session.Incognito {
+		videoHandler.compactor = StartMemoryCompactor(session, openaiClient, pineconeIdx)
+	}
 
 	session.Logger.Info("Video Handler initialized")
 
 	// Start the continuous video processing goroutine
-	go videoHandler.run()
+	session.Supervisor.Go("video", videoHandler.run)
 
 	return videoHandler
 }
 
 func (h *VideoHandler) run() {
-	h.session.Logger.Info("Video handler goroutine started", zap.Duration("frequency", h.session.VideoFrequency))
+	h.session.Logger.Info("Video handler goroutine started", zap.Duration("frequency", h.session.VideoFrequency()))
 
 	for h.isActive {
-		b64 := <-h.session.VideoAnalysisCh
-		if b64 == models.SESSION_END {
+		frame := <-h.session.VideoAnalysisCh
+		if frame.image == models.SESSION_END {
 			h.session.Logger.Info("Video handler received SESSION_END")
 			return
 		}
-		go h.captureAndAnalyze(b64)
+		h.session.Supervisor.Task("frame_analysis", func() { h.captureAndAnalyze(frame) })
 	}
 	h.session.Logger.Info("Video handler goroutine stopped")
 }
 
-func (h *VideoHandler) captureAndAnalyze(imageData string) {
+func (h *VideoHandler) captureAndAnalyze(queued queuedFrame) {
 	// Create a new context with timeout for this specific operation
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(h.session.sessionCtx, 30*time.Second)
 	defer cancel()
 
 	h.session.Logger.Debug("Capturing and analyzing image")
+	h.pipeline.Run(ctx, queued.image, queued.requestID)
+}
+
+// builtinStages are the handler's own frame stages, around which the
+// registered ones run.
+func (h *VideoHandler) builtinStages() []FrameStage {
+	return []FrameStage{
+		FrameStageFunc("preprocess", FRAME_PHASE_PREPROCESS, h.preprocess),
+		FrameStageFunc("describe", FRAME_PHASE_DESCRIBE, h.describe),
+		FrameStageFunc("store", FRAME_PHASE_STORE, h.store),
+		FrameStageFunc("notify", FRAME_PHASE_NOTIFY, h.notify),
+	}
+}
 
-	// Analyze image with OpenAI GPT-4V
-	environmentSummary, err := h.openaiClient.AnalyzeImageContext(ctx, imageData)
+func (h *VideoHandler) preprocess(ctx context.Context, frame *Frame) error {
+	if !h.session.AdaptiveFrequency.Admit(frame.Image) {
+		return ErrSkipFrame
+	}
+	if !h.checkFrameQuality(frame.Image) {
+		return ErrSkipFrame
+	}
+
+	// Crop and downscale before the vision call; the original frame is kept
+	// for the archive and annotation overlay
+	analyzedImage, crop, err := visionPreprocessor().Process(frame.Image, h.session.visionROI())
+	if err != nil {
+		h.session.Logger.Warn("Failed to preprocess frame, analyzing original", zap.Error(err))
+		analyzedImage, crop = frame.Image, utils.FullFrame
+	}
+	frame.Analyzed, frame.Crop = analyzedImage, crop
+	return nil
+}
+
+func (h *VideoHandler) describe(ctx context.Context, frame *Frame) error {
+	// Analyze image with OpenAI GPT-4V, along with the depth map sent for
+	// this frame when there is one
+	frame.depth = h.session.depthForFrame()
+	frame.started = h.session.Clock.Now()
+	environmentSummary, err := h.openaiClient.AnalyzeImageWithDepth(ctx, frame.Analyzed, h.session.depthImage(frame.depth))
+	if cancelled(ctx) {
+		h.session.Logger.Debug("Image analysis cancelled, session stopped")
+		return ErrSkipFrame
+	}
 	if err != nil {
 		h.session.Logger.Error("Failed to analyze image", zap.Error(err))
-		return
+		h.session.MetricLabels.ProviderError("openai")
+		h.session.sendError(ERROR_CODE_VISION_FAILED, "video_data", "Scene analysis failed")
+		return ErrSkipFrame
 	}
+	h.session.MetricLabels.ObserveAnalysis("vision", h.session.Clock.Since(frame.started).Seconds())
 
-	h.session.Logger.Debug("Generated environment description", zap.String("description", environmentSummary.Overview))
+	h.session.Logger.Debug("Generated environment description", zap.String("description", h.session.redact(environmentSummary.Overview)))
+	h.session.recordUsage(models.USAGE_FRAMES_ANALYZED, 1)
 
 	// Create environment context
-	envContext := models.EnvironmentContext{
-		ID:             fmt.Sprintf("%s-%d", h.session.ID, time.Now().Unix()),
+	now := h.session.Clock.Now()
+	frame.Context = &models.EnvironmentContext{
+		ID:             fmt.Sprintf("%s-%d", h.session.ID, now.Unix()),
 		SessionID:      h.session.ID,
-		Timestamp:      time.Now(),
+		Timestamp:      now,
 		Overview:       environmentSummary.Overview,
 		KeyElements:    environmentSummary.KeyElements,
 		Layout:         environmentSummary.Layout,
 		Activities:     environmentSummary.Activities,
 		AdditionalInfo: environmentSummary.AdditionalInfo,
+		Regions:        utils.UncropRegions(environmentSummary.Regions, frame.Crop),
+		Location:       h.session.RobotState.Location(),
+		RequestID:      frame.RequestID,
 	}
-	// Store in Pinecone if available (async)
-	if h.pineconeIdx != nil {
-		go h.storeEnvironmentContext(envContext)
+	frame.Context.ImageURI = h.session.frameImageURI(frame.Context.ID)
+	if frame.depth != nil {
+		stats := frame.depth.stats
+		frame.Context.Depth = &stats
 	}
+	return nil
+}
+
+func (h *VideoHandler) store(ctx context.Context, frame *Frame) error {
+	frame.mergeFindings()
+	envContext := *frame.Context
+	h.session.EnvironmentCache.Add(envContext)
+	h.session.updateSceneTerms()
+
+	// Store in Pinecone if available and archive (async); incognito sessions
+	// keep contexts in the in-memory cache only
+	if !h.session.Incognito {
+		if h.pineconeIdx != nil {
+			h.storeEnvironmentContext(envContext)
+		}
+		imageData := frame.Image
+		h.session.Supervisor.Task("vision_archive", func() { h.archiveAnalysis(imageData, envContext) })
+		if envContext.ImageURI != "" {
+			analyzed := frame.Analyzed
+			h.session.Supervisor.Task("frame_store", func() { h.storeFrameImage(envContext.ID, imageData, analyzed) })
+		}
+		h.session.Supervisor.Task("site_memory", func() { h.session.SiteMemory.Observe(envContext) })
+	}
+	return nil
+}
+
+func (h *VideoHandler) notify(ctx context.Context, frame *Frame) error {
+	envContext := *frame.Context
 
 	// Send analysis result via websocket
 	h.session.sendWebSocketMessage("video_analysis", envContext)
+	if len(envContext.Regions) > 0 {
+		h.sendAnnotations(frame.Image, envContext)
+	}
+
+	h.session.RuleEngine.Evaluate(envContext)
+	h.session.Changes.Observe(envContext)
+	h.session.AdaptiveFrequency.Observe(envContext)
+	return nil
 }
 
-func (h *VideoHandler) storeEnvironmentContext(envContext models.EnvironmentContext) {
-	if h.pineconeIdx == nil {
+// sendAnnotations sends the key element boxes of an analysis so robot UIs can
+// overlay them on the camera preview.
+func (h *VideoHandler) sendAnnotations(imageData string, envContext models.EnvironmentContext) {
+	payload := VideoAnnotationsPayload{
+		ContextID:   envContext.ID,
+		Annotations: envContext.Regions,
+		Timestamp:   envContext.Timestamp,
+	}
+	if width, height, ok := utils.FrameSize(imageData); ok {
+		payload.FrameWidth, payload.FrameHeight = width, height
+	}
+	h.session.sendWebSocketMessage("video_annotations", payload)
+}
+
+// checkFrameQuality scores the frame for blur and exposure and, when it is too
+// poor for a reliable scene description, asks the client to recapture instead
+// of spending an analysis on it. Frames that cannot be scored are analyzed.
+func (h *VideoHandler) checkFrameQuality(imageData string) bool {
+	if !h.session.Config.FrameQualityCheck {
+		return true
+	}
+	quality, err := utils.ScoreFrame(imageData, utils.FrameQualityThresholdsFromConfig(h.session.Config))
+	if err != nil {
+		var corrupt base64.CorruptInputError
+		if errors.As(err, &corrupt) {
+			h.session.Logger.Warn("Rejected frame with invalid base64", zap.Error(err))
+			h.session.sendError(ERROR_CODE_VIDEO_DECODE, "video_data", err.Error())
+			return false
+		}
+		h.session.Logger.Debug("Unable to score frame quality", zap.Error(err))
+		return true
+	}
+	if len(quality.Issues) == 0 {
+		return true
+	}
+
+	h.session.Logger.Info("Skipping low quality frame",
+		zap.Strings("issues", quality.Issues),
+		zap.Float64("sharpness", quality.Sharpness),
+		zap.Float64("brightness", quality.Brightness))
+	h.session.recordUsage(models.USAGE_FRAMES_REJECTED, 1)
+	h.session.sendWebSocketMessage("frame_quality_low", FrameQualityLowPayload{
+		FrameQuality: *quality,
+		Message:      "Frame quality too low for analysis, please recapture",
+	})
+	return false
+}
+
+// archiveAnalysis keeps the scene description (and, when ARCHIVE_FRAMES is
+// enabled, the frame itself) for export and offline re-analysis.
+func (h *VideoHandler) archiveAnalysis(imageData string, envContext models.EnvironmentContext) {
+	resultJSON, err := json.Marshal(envContext)
+	if err != nil {
+		h.session.Logger.Error("Failed to marshal environment context for archive", zap.Error(err))
 		return
 	}
 
-	// Create a new context with timeout for this specific operation
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	h.session.Logger.Debug("Storing environment context in Pinecone")
+	worker := utils.Worker()
+	record := models.AnalysisRecord{
+		ID:        envContext.ID,
+		SessionID: h.session.ID,
+		TenantID:  h.session.Tenant.ID,
+		Kind:      models.ANALYSIS_KIND_VISION,
+		Result:    resultJSON,
+		Worker:    &worker,
+		Timestamp: envContext.Timestamp,
+	}
+	if utils.ArchiveFramesEnabled() {
+		record.ImageData = imageData
+	}
+	if err := utils.ArchiveAnalysis(ctx, h.session.RedisClient, record); err != nil {
+		h.session.Logger.Warn("Failed to archive vision analysis", zap.Error(err))
+	}
+}
+
+// storeEnvironmentContext queues the context on the shared batched writer;
+// failures are reported once retries are exhausted.
+func (h *VideoHandler) storeEnvironmentContext(envContext models.EnvironmentContext) {
+	if h.pineconeIdx == nil {
+		return
+	}
 
 	// Convert the environment context to a string for storage
-	allTexts := fmt.Sprintf("%s", envContext)
+	allTexts := formatEnvironmentContext(envContext)
 
 	// Create vector ID
 	vectorID := fmt.Sprintf("%s-env", envContext.ID)
@@ -126,15 +295,28 @@ func (h *VideoHandler) storeEnvironmentContext(envContext models.EnvironmentCont
 		"session_id":      envContext.SessionID,
 		"timestamp":       envContext.Timestamp.Unix(),
 		"type":            "environment_context",
+		"server_version":  utils.Version,
+		"instance_id":     utils.InstanceID(),
 	}
-
-	// Use the utility function to upsert to Pinecone (now with integrated embeddings)
-	err := utils.UpsertToPinecone(ctx, h.pineconeIdx, vectorID, allTexts, metadata)
-	if err != nil {
-		h.session.Logger.Error("Failed to upsert to Pinecone", zap.Error(err), zap.String("vector_id", vectorID))
+	if envContext.ImageURI != "" {
+		metadata["image_uri"] = envContext.ImageURI
 	}
+	h.session.addMetadataFields(metadata)
+
+	logger := h.session.Logger
+	labels := h.session.MetricLabels
+	pineconeWriter().Enqueue(utils.PineconeWrite{
+		Index:    h.pineconeIdx,
+		ID:       vectorID,
+		Text:     allTexts,
+		Metadata: metadata,
+		OnFailure: func(err error) {
+			logger.Error("Failed to upsert to Pinecone", zap.Error(err), zap.String("vector_id", vectorID))
+			labels.ProviderError("pinecone")
+		},
+	})
 
-	h.session.Logger.Debug("Environment context stored in Pinecone")
+	h.session.Logger.Debug("Environment context queued for Pinecone", zap.String("vector_id", vectorID))
 }
 
 func (h *VideoHandler) Close() {
@@ -0,0 +1,86 @@
+// handlers/vision_settings.go
+
+package handlers
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+	"go.uber.org/zap"
+)
+
+var (
+	imagePreprocessorOnce sync.Once
+	imagePreprocessor     utils.ImagePreprocessor
+)
+
+// visionPreprocessor returns the instance-wide frame preprocessing settings,
+// read on first use. Invalid settings are logged and replaced by defaults.
+func visionPreprocessor() utils.ImagePreprocessor {
+	imagePreprocessorOnce.Do(func() {
+		var err error
+		imagePreprocessor, err = utils.ImagePreprocessorFromConfig(utils.CurrentConfig())
+		if err != nil {
+			zap.L().Error("Invalid vision preprocessing settings, using defaults", zap.Error(err))
+			imagePreprocessor = utils.ImagePreprocessor{MaxDimension: 1024, JPEGQuality: 85, Crop: utils.FullFrame}
+		}
+	})
+	return imagePreprocessor
+}
+
+func (rs *RoboSession) visionROI() *utils.CropRect {
+	rs.stateMu.Lock()
+	defer rs.stateMu.Unlock()
+	return rs.roi
+}
+
+// applyVisionConfig sets the session's region of interest from a config
+// payload: {"vision_roi":{"x":0.25,"y":0,"width":0.5,"height":1}} crops
+// frames before analysis, null restores the instance-wide crop.
+func (rs *RoboSession) applyVisionConfig(configData map[string]interface{}) (string, error) {
+	value, exists := configData["vision_roi"]
+	if !exists {
+		return "", nil
+	}
+
+	var roi *utils.CropRect
+	if value != nil {
+		fields, ok := value.(map[string]interface{})
+		if !ok {
+			return "data.vision_roi", fmt.Errorf("must be an object with x, y, width and height")
+		}
+		var numbers [4]float64
+		for i, name := range []string{"x", "y", "width", "height"} {
+			number, ok := fields[name].(float64)
+			if !ok {
+				return "data.vision_roi." + name, fmt.Errorf("must be a number between 0 and 1")
+			}
+			numbers[i] = number
+		}
+		crop, err := utils.CropRect{X: numbers[0], Y: numbers[1], Width: numbers[2], Height: numbers[3]}.Validate()
+		if err != nil {
+			return "data.vision_roi", err
+		}
+		roi = &crop
+	}
+
+	rs.stateMu.Lock()
+	rs.roi = roi
+	rs.stateMu.Unlock()
+	return "", nil
+}
+
+// visionConfig returns the region of interest in config payload form.
+func (rs *RoboSession) visionConfig() map[string]interface{} {
+	roi := rs.visionROI()
+	if roi == nil {
+		return map[string]interface{}{"vision_roi": nil}
+	}
+	return map[string]interface{}{"vision_roi": map[string]interface{}{
+		"x":      roi.X,
+		"y":      roi.Y,
+		"width":  roi.Width,
+		"height": roi.Height,
+	}}
+}
@@ -0,0 +1,99 @@
+// handlers/warmup.go
+
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+	"go.uber.org/zap"
+)
+
+const (
+	WARMUP_STATUS_OK      = "ok"
+	WARMUP_STATUS_FAILED  = "failed"
+	WARMUP_STATUS_TIMEOUT = "timeout"
+)
+
+// warmupEnabled reports whether a new session should run the priming pass.
+// SESSION_WARMUP sets the default; ?warmup=true|false overrides it.
+func warmupEnabled(r *http.Request, cfg *utils.Config) bool {
+	enabled := cfg.SessionWarmup
+	if value := r.URL.Query().Get("warmup"); value != "" {
+		if parsed, err := strconv.ParseBool(value); err == nil {
+			enabled = parsed
+		}
+	}
+	return enabled
+}
+
+// warmup primes the providers of the session's active handlers in parallel so
+// the first utterance and frame don't pay cold-start penalties. Each provider
+// is reported as ok, failed or timeout; the pass never takes longer than
+// SESSION_WARMUP_TIMEOUT.
+func (rs *RoboSession) warmup() *WarmupStatus {
+	ctx, cancel := context.WithTimeout(rs.sessionCtx, rs.Config.SessionWarmupTimeout)
+	defer cancel()
+
+	steps := make(map[string]func(context.Context) error)
+	if h := rs.IntentionHandler; h != nil {
+		steps["intention"] = func(ctx context.Context) error {
+			return h.openaiClient.Warmup(ctx, utils.MODEL_TASK_INTENTION)
+		}
+		if h.pineconeIdx != nil {
+			steps["pinecone"] = func(ctx context.Context) error {
+				idx, err := h.pineconeIdx.Conn()
+				if err != nil {
+					return err
+				}
+				return utils.WarmupPinecone(ctx, idx)
+			}
+		}
+	}
+	if h := rs.VideoHandler; h != nil {
+		steps["vision"] = func(ctx context.Context) error {
+			return h.openaiClient.Warmup(ctx, utils.MODEL_TASK_VISION)
+		}
+	}
+	if h := rs.AudioHandler; h != nil {
+		steps[h.Provider()] = func(ctx context.Context) error {
+			return h.KeepAlive()
+		}
+	}
+
+	started := time.Now()
+	status := &WarmupStatus{Providers: make(map[string]string, len(steps))}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, step := range steps {
+		wg.Add(1)
+		rs.Supervisor.Task("warmup", func() {
+			defer wg.Done()
+			err := step(ctx)
+
+			result := WARMUP_STATUS_OK
+			if err != nil {
+				result = WARMUP_STATUS_FAILED
+				if ctx.Err() != nil {
+					result = WARMUP_STATUS_TIMEOUT
+				}
+				rs.Logger.Warn("Warm-up failed", zap.String("provider", name), zap.Error(err))
+			}
+			mu.Lock()
+			status.Providers[name] = result
+			mu.Unlock()
+		})
+	}
+	wg.Wait()
+
+	status.DurationMs = time.Since(started).Milliseconds()
+	rs.Logger.Info("Session warm-up finished",
+		zap.Any("providers", status.Providers),
+		zap.Int64("duration_ms", status.DurationMs))
+	return status
+}
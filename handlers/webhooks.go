@@ -0,0 +1,109 @@
+// handlers/webhooks.go
+
+package handlers
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+)
+
+const (
+	SESSION_END_REASON_STOPPED      = "stopped"
+	SESSION_END_REASON_DISCONNECTED = "disconnected"
+	SESSION_END_REASON_TERMINATED   = "terminated"
+	SESSION_END_REASON_FAILED       = "failed"
+)
+
+// SessionStartedWebhook is the data of a session_started event.
+type SessionStartedWebhook struct {
+	StartTime  time.Time         `json:"start_time"`
+	Resumed    bool              `json:"resumed"`
+	Modalities []string          `json:"modalities"`
+	RobotModel string            `json:"robot_model,omitempty"`
+	Profile    string            `json:"profile,omitempty"`
+	Incognito  bool              `json:"incognito"`
+	Worker     models.WorkerInfo `json:"worker"`
+}
+
+// SessionEndedWebhook is the data of a session_ended event. Reason is
+// "stopped" when the robot sent stop, "terminated" when an administrator
+// ended the session, "failed" when its workers kept crashing and
+// "disconnected" when the connection dropped (the session may then be
+// resumed, as may a failed one). Summary is the end-of-session summary, when
+// one was written.
+type SessionEndedWebhook struct {
+	StartTime       time.Time              `json:"start_time"`
+	EndTime         time.Time              `json:"end_time"`
+	DurationSeconds float64                `json:"duration_seconds"`
+	Reason          string                 `json:"reason"`
+	Usage           map[string]int64       `json:"usage"`
+	Summary         *models.SessionSummary `json:"summary,omitempty"`
+}
+
+var (
+	webhookDispatcherOnce   sync.Once
+	sharedWebhookDispatcher *utils.WebhookDispatcher
+)
+
+// webhookDispatcher returns the instance-wide dispatcher, created on first
+// use so its settings are read after the configuration is installed.
+func webhookDispatcher() *utils.WebhookDispatcher {
+	webhookDispatcherOnce.Do(func() {
+		sharedWebhookDispatcher = utils.NewWebhookDispatcherFromConfig(utils.CurrentConfig())
+	})
+	return sharedWebhookDispatcher
+}
+
+// FlushWebhooks waits for pending webhook deliveries on shutdown.
+func FlushWebhooks() {
+	webhookDispatcher().Close(utils.CurrentConfig().WebhookFlushTimeout)
+}
+
+// sendWebhook emits a lifecycle event to the tenant's webhook URLs.
+func (rs *RoboSession) sendWebhook(event string, data interface{}) {
+	tenant := rs.credentials()
+	if len(tenant.WebhookURLs) == 0 {
+		return
+	}
+	webhookDispatcher().Send(tenant, utils.WebhookEvent{
+		ID:        rs.IDs.NewID(),
+		Event:     event,
+		SessionID: rs.ID,
+		Timestamp: rs.Clock.Now(),
+		Metadata:  rs.metadataCopy(),
+		Data:      data,
+	})
+}
+
+func (rs *RoboSession) sendSessionStartedWebhook(resumed bool, robotModel, profile string) {
+	rs.sendWebhook(utils.WEBHOOK_SESSION_STARTED, SessionStartedWebhook{
+		StartTime:  rs.StartTime,
+		Resumed:    resumed,
+		Modalities: rs.capabilities().Modalities,
+		RobotModel: robotModel,
+		Profile:    profile,
+		Incognito:  rs.Incognito,
+		Worker:     utils.Worker(),
+	})
+}
+
+func (rs *RoboSession) sendSessionEndedWebhook(endTime time.Time, summary *models.SessionSummary) {
+	reason, clientStopped := rs.ending()
+	if reason == "" {
+		reason = SESSION_END_REASON_DISCONNECTED
+		if clientStopped {
+			reason = SESSION_END_REASON_STOPPED
+		}
+	}
+	rs.sendWebhook(utils.WEBHOOK_SESSION_ENDED, SessionEndedWebhook{
+		StartTime:       rs.StartTime,
+		EndTime:         endTime,
+		DurationSeconds: endTime.Sub(rs.StartTime).Seconds(),
+		Reason:          reason,
+		Usage:           rs.summary().Usage,
+		Summary:         summary,
+	})
+}
@@ -5,110 +5,484 @@ package handlers
 import (
 	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/Perceptus-Labs/perceptus-go-sdk/codec"
 	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
-	"github.com/google/uuid"
+	"github.com/Perceptus-Labs/perceptus-go-sdk/router"
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
 	"github.com/gorilla/websocket"
 	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
 )
 
 type RoboSession struct {
-	ID                   string
-	CurrentContext       context.Context
-	CancelCurrentContext context.CancelFunc
-	Connection           *websocket.Conn
-	RedisClient          *redis.Client
-	Logger               *zap.Logger
+	ID          string
+	Connection  *websocket.Conn
+	RedisClient *redis.Client
+	Logger      *zap.Logger
+
+	// Config holds the server settings the session started with; a reload
+	// applies to sessions started after it
+	Config *utils.Config
+
+	// sessionCtx lives as long as the session and the context of every
+	// intention analysis derives from it: Stop cancels all in-flight
+	// provider calls
+	sessionCtx    context.Context
+	cancelSession context.CancelFunc
+
+	// Tenant owning this session and the store used for usage accounting
+	Tenant      *models.Tenant
+	Tenants     *utils.TenantStore
+	rateLimiter *utils.RateLimiter
 
 	// Channels for communication between handlers
 	TranscriptionCh chan string
-	VideoAnalysisCh chan string
+	VideoAnalysisCh chan queuedFrame
+	// framesMu guards sending to VideoAnalysisCh against its close
+	framesMu     sync.RWMutex
+	framesClosed bool
+
+	// Requests and actions of the session, for its end-of-session summary
+	journal sessionJournal
+
+	// Time and ID sources, injectable for deterministic tests
+	Clock utils.Clock
+	IDs   utils.IDGenerator
+
+	// Session state; active is cleared by Stop, which tears down only once
+	active    atomic.Bool
+	stopOnce  sync.Once
+	StartTime time.Time
+
+	// Effective frequency adapted to scene dynamics and frame budget
+	AdaptiveFrequency *AdaptiveFrequency
+	// Prompts and low-power mode after dead air
+	Inactivity *InactivityMonitor
+	// Appearing and disappearing key elements and activities
+	Changes *ChangeDetector
+	// Latest frames, answered from by ask_about_scene
+	RecentFrames *FrameHistory
+
+	// Earlier utterances given to intention analysis
+	Conversation   *ConversationWindow
+	LastActionTime time.Time
+
+	// Live captions for token-authenticated viewers
+	Captions *CaptionFeed
 
-	// Session state
-	IsActive     bool
-	StartTime    time.Time
-	LastActivity time.Time
+	// Runs the session's goroutines, recovering from panics
+	Supervisor *Supervisor
 
-	// Configuration
-	VideoFrequency time.Duration // How often to take pictures
+	// Provider clients shared by the session's handlers, created on first
+	// use over the server-wide connection pools
+	providersOnce sync.Once
+	openAI        *utils.OpenAIClient
+	pinecone      *utils.PineconeIndex
+	pineconeErr   error
 
-	// Current transcript buffer
-	CurrentTranscript string
-	LastActionTime    time.Time
+	// State persisted in snapshots for crash recovery
+	stateMu sync.Mutex
+	// How often to take pictures, as configured by the client
+	videoFrequency time.Duration
+	lastActivity   time.Time
+	// Transcript buffered until the utterance is flushed
+	currentTranscript string
+	// Server-side capture from a stream or camera, if any
+	ingester      *RTSPIngester
+	usage         map[string]int64
+	lastIntention *models.IntentionResult
+	models        utils.ModelChains
+	transcript    TranscriptSettings
+	stt           utils.STTOptions
+	sceneBoost    SceneBoostSettings
+	sceneTerms    []string
+	sceneTermsAt  time.Time
+	roi           *utils.CropRect
+	depth         *depthSample
+	// stopCaptureRequests is set while server-driven capture runs
+	stopCaptureRequests context.CancelFunc
+	clientStopped       bool
+	endReason           string
+	staleWarnedAt       time.Time
+
+	// Last time each error code was reported, to throttle repeats
+	errorMu     sync.Mutex
+	lastErrorAt map[string]time.Time
 
 	VideoHandler     *VideoHandler
 	AudioHandler     *AudioHandler
 	IntentionHandler *IntentionHandler
+	DisplayHandler   *DisplayHandler
+	Camera           *CameraController
+	RuleEngine       *RuleEngine
+	Recorder         *SessionRecorder
+
+	// Modalities declared by the client; handlers for missing ones are not started
+	Modalities map[string]bool
+	// Profile names the session profile applied at connect; memoryDisabled
+	// is set when it turns Pinecone memory off
+	Profile        string
+	memoryDisabled bool
+
+	// Latest robot_state reported by the client
+	RobotState *RobotState
+
+	// UserID and RobotID identify who the session talks to and which robot
+	// across sessions; preferences are remembered for the user, else the robot
+	UserID  string
+	RobotID string
+	// Robot is the registered robot as of the session start, with the
+	// state its previous session left; nil without a robot_id
+	Robot *models.Robot
+	// Metadata the client attached at session start (robot_id, site,
+	// firmware_version, operator, ...); read-only once the session runs
+	Metadata    map[string]string
+	Preferences *PreferenceMemory
+	// SiteMemory is shared with the other robots at the session's site
+	SiteMemory *SiteMemory
+
+	// Latest environment contexts, used when Pinecone is unavailable
+	EnvironmentCache *EnvironmentCache
+
+	// Prometheus label values (tenant, robot model, profile)
+	MetricLabels utils.MetricLabels
+
+	// Read-only event stream for dashboards
+	Events *EventFeed
+
+	// Single writer of the WebSocket connection, and the encoding the
+	// client negotiated
+	Outbound *OutboundQueue
+	Codec    MessageCodec
+
+	// Result of the start-up priming pass, nil when warm-up is disabled
+	Warmup *WarmupStatus
+
+	// Per-session log level set by administrators, following LOG_LEVEL
+	// until overridden
+	logLevel *utils.LogLevelOverride
+
+	// Incognito sessions keep everything in memory and redact content in logs
+	Incognito       bool
+	incognitoSource string
+
+	// The tenant's transcript filters, nil when it has none
+	transcriptFilter *utils.TranscriptFilter
+
+	// Frees the session's admission slot, called once on Stop
+	releaseAdmission func()
 }
 
+// upgrader offers the MessagePack subprotocol; clients that don't ask for it
+// get JSON.
 var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool {
-		return true // Allow connections from any origin
-	},
+	Subprotocols:      []string{codec.SUBPROTOCOL_MSGPACK},
+	CheckOrigin:       checkOrigin,
 	EnableCompression: true,
 	ReadBufferSize:    1024,
 	WriteBufferSize:   1024,
 }
 
-func NewRoboSession(id string, conn *websocket.Conn, redisClient *redis.Client) *RoboSession {
-	ctx, cancel := context.WithCancel(context.Background())
+// captionUpgrader negotiates no subprotocol: captions are always JSON.
+var captionUpgrader = websocket.Upgrader{
+	CheckOrigin:     checkOrigin,
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
 
-	// Create a logger with session ID context
-	logger := zap.L().With(zap.String("session_id", id))
+// allowedOrigins lists the browser origins that may open WebSockets:
+// WEBSOCKET_ALLOWED_ORIGINS, or CORS_ALLOWED_ORIGINS when it is unset.
+func allowedOrigins() []string {
+	return router.ParseOrigins(utils.CurrentConfig().WebSocketAllowedOrigins)
+}
 
+// checkOrigin accepts clients that send no Origin (robots and other native
+// clients), pages served by this server and the allowed origins. Browsers
+// on other origins are refused with 403, so a page cannot open a session
+// with credentials it happens to hold.
+func checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	if router.OriginAllowed(allowedOrigins(), origin) {
+		return true
+	}
+	zap.L().Warn("Refused WebSocket from disallowed origin", zap.String("origin", origin), zap.String("path", r.URL.Path))
+	return false
+}
+
+// Time and ID sources of new sessions; tests replace them for deterministic runs
+var (
+	sessionClock utils.Clock       = utils.SystemClock{}
+	sessionIDs   utils.IDGenerator = utils.UUIDGenerator{}
+)
+
+func NewRoboSession(id string, cfg *utils.Config, conn *websocket.Conn, redisClient *redis.Client, tenant *models.Tenant, tenants *utils.TenantStore) *RoboSession {
+	sessionCtx, cancelSession := context.WithCancel(context.Background())
+
+	// Create a logger with session ID context and its own level
+	logLevel := &utils.LogLevelOverride{}
+	logger := utils.WithLevelOverride(zap.L().With(zap.String("session_id", id), zap.String("tenant_id", tenant.ID)), logLevel)
+
+	clock := sessionClock
 	session := &RoboSession{
-		ID:                   id,
-		CurrentContext:       ctx,
-		CancelCurrentContext: cancel,
-		Connection:           conn,
-		RedisClient:          redisClient,
-		Logger:               logger,
+		ID:            id,
+		Clock:         clock,
+		IDs:           sessionIDs,
+		sessionCtx:    sessionCtx,
+		cancelSession: cancelSession,
+		Config:        cfg,
+		Connection:    conn,
+		Codec:         codecFor(conn.Subprotocol()),
+		RedisClient:   redisClient,
+		Logger:        logger,
+		logLevel:      logLevel,
+
+		Tenant:      tenant,
+		Tenants:     tenants,
+		rateLimiter: utils.NewRateLimiter(tenant.RateLimits.MessagesPerSecond, tenant.RateLimits.Burst),
 
 		TranscriptionCh: make(chan string, 100),
-		VideoAnalysisCh: make(chan string, 100),
+		VideoAnalysisCh: make(chan queuedFrame, 100),
 
-		IsActive:     true,
-		StartTime:    time.Now(),
-		LastActivity: time.Now(),
+		StartTime: clock.Now(),
 
-		VideoFrequency: 30 * time.Second, // Default: take picture every 30 seconds
+		videoFrequency: 30 * time.Second, // Default: take picture every 30 seconds
+		lastActivity:   clock.Now(),
 
-		CurrentTranscript: "",
-		LastActionTime:    time.Now(),
+		LastActionTime: clock.Now(),
+
+		Modalities:       defaultModalities(),
+		RobotState:       NewRobotState(),
+		EnvironmentCache: NewEnvironmentCache(cfg.EnvironmentCacheSize),
+		MetricLabels:     utils.NewMetricLabels(tenant.ID, "", "", ""),
+		Events:           NewEventFeed(),
+
+		usage:      make(map[string]int64),
+		models:     make(utils.ModelChains),
+		transcript: defaultTranscriptSettings(cfg),
+		stt:        utils.DefaultSTTOptions(cfg),
+		sceneBoost: defaultSceneBoostSettings(cfg),
 	}
+	session.active.Store(true)
+	session.Supervisor = NewSupervisor(session)
+	session.Outbound = NewOutboundQueue(conn, session.Codec, cfg, clock, logger, func(msgType string) {
+		session.MetricLabels.OutboundDropped(msgType)
+	})
+	session.Conversation = NewConversationWindow(session)
+	session.Captions = NewCaptionFeed(session)
+	session.AdaptiveFrequency = NewAdaptiveFrequency(session)
+	session.Inactivity = NewInactivityMonitor(session)
+	session.Changes = NewChangeDetector(session)
+	session.RecentFrames = NewFrameHistory(cfg.SceneQAMaxFrames)
+	session.transcriptFilter = newSessionTranscriptFilter(session)
 
 	return session
 }
 
-func (rs *RoboSession) UpdateContext() {
-	rs.CancelCurrentContext()
-	rs.CurrentContext, rs.CancelCurrentContext = context.WithCancel(context.Background())
-	rs.LastActivity = time.Now()
+// credentials returns the latest provider configuration of the session's
+// tenant, so rotated keys apply to subsequent calls.
+func (rs *RoboSession) credentials() *models.Tenant {
+	if tenant := rs.Tenants.Current(rs.Tenant.ID); tenant != nil {
+		return tenant
+	}
+	return rs.Tenant
 }
 
+// initProviders creates the session's provider clients once. They read the
+// tenant's credentials per call, so rotated keys apply without recreating
+// them.
+func (rs *RoboSession) initProviders() {
+	rs.providersOnce.Do(func() {
+		rs.openAI = utils.NewOpenAIClientWithKeySource(func() string {
+			return rs.credentials().OpenAIAPIKey
+		})
+		rs.openAI.ModelSource = rs.modelChain
+		rs.openAI.Clock = rs.Clock
+
+		if rs.memoryDisabled {
+			rs.pineconeErr = errMemoryDisabled
+			return
+		}
+		rs.pinecone, rs.pineconeErr = utils.NewPineconeIndex(func() (string, string, string) {
+			tenant := rs.credentials()
+			return tenant.PineconeAPIKey, tenant.PineconeHost, tenant.PineconeNamespace
+		})
+	})
+}
+
+// openAIClient returns the session's OpenAI client.
+func (rs *RoboSession) openAIClient() *utils.OpenAIClient {
+	rs.initProviders()
+	return rs.openAI
+}
+
+// pineconeIndex returns the session's connection to the tenant's index,
+// failing with errMemoryDisabled when the session profile turns memory off.
+func (rs *RoboSession) pineconeIndex() (*utils.PineconeIndex, error) {
+	rs.initProviders()
+	return rs.pinecone, rs.pineconeErr
+}
+
+// startUtterance records a new utterance as activity; queuing it for
+// analysis cancels the previous one (barge-in).
+func (rs *RoboSession) startUtterance() {
+	rs.Inactivity.Touch()
+	rs.stateMu.Lock()
+	defer rs.stateMu.Unlock()
+	rs.lastActivity = rs.Clock.Now()
+}
+
+// cancelled reports whether ctx ended because the session stopped or a newer
+// utterance superseded it, as opposed to timing out.
+func cancelled(ctx context.Context) bool {
+	return errors.Is(ctx.Err(), context.Canceled)
+}
+
+// IsActive reports whether the session has not been stopped.
+func (rs *RoboSession) IsActive() bool {
+	return rs.active.Load()
+}
+
+// Stop tears the session down. It is called from the read loop, the
+// supervisor, the dashboard and failed setups; only the first call does the
+// teardown and concurrent calls wait for it.
 func (rs *RoboSession) Stop() {
+	rs.stopOnce.Do(rs.stop)
+}
+
+func (rs *RoboSession) stop() {
 	rs.Logger.Info("Stopping session")
-	if rs.IsActive {
-		rs.IsActive = false
+	if rs.releaseAdmission != nil {
+		rs.releaseAdmission()
+	}
+	rs.active.Store(false)
+	unregisterSession(rs.ID)
+	rs.cancelScheduledJobs()
+	rs.MetricLabels.SessionEnded()
+	endTime := rs.Clock.Now()
+	rs.saveMeta(endTime)
+	rs.saveRobot(endTime)
+
+	// Keep the snapshot when the connection dropped so the client can
+	// resume; a deliberate stop ends the session for good
+	if _, clientStopped := rs.ending(); clientStopped {
+		rs.discardSnapshot()
+	} else {
+		rs.saveSnapshot()
+	}
+
+	// Send SESSION_END to all channels to stop all goroutines
+	rs.SendToAllChannels(models.SESSION_END)
 
-		// Send SESSION_END to all channels to stop all goroutines
-		rs.SendToAllChannels(models.SESSION_END)
+	rs.stopIngest()
+	rs.Events.Close()
+	rs.Captions.Close()
 
-		// Cancel current context
-		rs.CancelCurrentContext()
+	// Cancel in-flight provider calls, including the current utterance's
+	rs.cancelSession()
 
-		// Close all channels
-		close(rs.TranscriptionCh)
-		close(rs.VideoAnalysisCh)
+	// Close all channels
+	close(rs.TranscriptionCh)
+	rs.framesMu.Lock()
+	rs.framesClosed = true
+	close(rs.VideoAnalysisCh)
+	rs.framesMu.Unlock()
 
-		if rs.Connection != nil {
-			rs.Connection.Close()
-		}
+	// Write what is still queued, e.g. the stop confirmation
+	rs.Outbound.Close(rs.Config.OutboundFlushTimeout)
+	if rs.Connection != nil {
+		rs.Connection.Close()
+	}
+
+	// Frames are closed, so the last recording can be finished
+	rs.Recorder.Close()
+
+	// The summary waits on the model, so it does not hold up the teardown;
+	// the webhook carries it
+	pendingSummaries.Add(1)
+	rs.Supervisor.Task("session_summary", func() {
+		defer pendingSummaries.Done()
+		rs.sendSessionEndedWebhook(endTime, rs.summarizeSession(endTime))
+	})
+}
+
+// setEnding records why the session is ending. clientStopped is set when the
+// client or an administrator stopped it, which discards its snapshot.
+func (rs *RoboSession) setEnding(reason string, clientStopped bool) {
+	rs.stateMu.Lock()
+	defer rs.stateMu.Unlock()
+	if reason != "" {
+		rs.endReason = reason
+	}
+	rs.clientStopped = rs.clientStopped || clientStopped
+}
+
+func (rs *RoboSession) ending() (reason string, clientStopped bool) {
+	rs.stateMu.Lock()
+	defer rs.stateMu.Unlock()
+	return rs.endReason, rs.clientStopped
+}
+
+// transcriptBuffer returns the transcript buffered for the current utterance.
+func (rs *RoboSession) transcriptBuffer() string {
+	rs.stateMu.Lock()
+	defer rs.stateMu.Unlock()
+	return rs.currentTranscript
+}
+
+// appendTranscript adds a segment to the buffered transcript and returns it.
+func (rs *RoboSession) appendTranscript(segment string) string {
+	rs.stateMu.Lock()
+	defer rs.stateMu.Unlock()
+	rs.currentTranscript += segment
+	return rs.currentTranscript
+}
+
+// takeTranscript empties the transcript buffer and returns what it held.
+func (rs *RoboSession) takeTranscript() string {
+	rs.stateMu.Lock()
+	defer rs.stateMu.Unlock()
+	transcript := rs.currentTranscript
+	rs.currentTranscript = ""
+	return transcript
+}
+
+// saveMeta persists the session description so it can be exported later.
+// A zero endTime marks the session as still running.
+func (rs *RoboSession) saveMeta(endTime time.Time) {
+	if rs.Incognito {
+		return
+	}
+	worker := utils.Worker()
+	meta := models.SessionMeta{
+		ID:        rs.ID,
+		TenantID:  rs.Tenant.ID,
+		Worker:    &worker,
+		StartTime: rs.StartTime,
+		EndTime:   endTime,
+		Config:    rs.configSnapshot(),
+		Metadata:  rs.metadataCopy(),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := utils.SaveSessionMeta(ctx, rs.RedisClient, meta); err != nil {
+		rs.Logger.Warn("Failed to save session meta", zap.Error(err))
 	}
 }
 
@@ -119,7 +493,7 @@ func (rs *RoboSession) SendToAllChannels(message string) {
 	default:
 	}
 	select {
-	case rs.VideoAnalysisCh <- message:
+	case rs.VideoAnalysisCh <- queuedFrame{image: message}:
 	default:
 	}
 }
@@ -131,88 +505,240 @@ func (rs *RoboSession) Close() {
 type SessionConfig struct {
 	VideoFrequency time.Duration `json:"video_frequency"`
 	AudioFrequency time.Duration `json:"audio_frequency"`
+	RTSPURL        string        `json:"rtsp_url"`
 }
 
 type WebSocketMessage struct {
-	Type      string      `json:"type"`
-	Data      interface{} `json:"data"`
-	Timestamp time.Time   `json:"timestamp"`
+	Type      string             `json:"type"`
+	Version   string             `json:"version,omitempty"`
+	Data      interface{}        `json:"data"`
+	Worker    *models.WorkerInfo `json:"worker,omitempty"`
+	Timestamp time.Time          `json:"timestamp"`
+	// RequestID is echoed on the video_data frame answering a
+	// capture_request
+	RequestID string `json:"request_id,omitempty"`
 }
 
 func (rs *RoboSession) setupHandlers() {
+	rs.DisplayHandler = InitDisplayHandler(rs)
+	rs.Camera = InitCameraController(rs)
+	rs.RuleEngine = InitRuleEngine(rs)
+	rs.Preferences = InitPreferenceMemory(rs)
+	rs.SiteMemory = InitSiteMemory(rs)
+
 	intentionHandler := InitIntentionHandler(rs)
 	rs.IntentionHandler = intentionHandler
 
-	audioHandler, err := InitAudioHandler(rs)
-	if err != nil {
-		rs.Logger.Error("Failed to initialize audio handler", zap.Error(err))
-		rs.Stop()
-		return
+	if rs.hasModality(MODALITY_AUDIO) {
+		audioHandler, err := InitAudioHandler(rs)
+		if err != nil {
+			rs.Logger.Error("Failed to initialize audio handler", zap.Error(err))
+			rs.Stop()
+			return
+		}
+		rs.AudioHandler = audioHandler
 	}
-	rs.AudioHandler = audioHandler
 
-	videoHandler := InitVideoHandler(rs)
-	rs.VideoHandler = videoHandler
+	if rs.hasModality(MODALITY_VIDEO) {
+		videoHandler := InitVideoHandler(rs)
+		rs.VideoHandler = videoHandler
+		rs.Recorder = InitSessionRecorder(rs)
+	}
 }
 
-func HandleRobotSession(w http.ResponseWriter, r *http.Request, redisClient *redis.Client) {
+func HandleRobotSession(w http.ResponseWriter, r *http.Request, redisClient *redis.Client, tenants *utils.TenantStore) {
 	zap.L().Info("WebSocket upgrade request received",
 		zap.String("remote_addr", r.RemoteAddr),
 		zap.String("user_agent", r.UserAgent()))
 
-	// Upgrade HTTP connection to WebSocket
-	conn, err := upgrader.Upgrade(w, r, nil)
+	// Resolve the tenant before upgrading so bad keys get a plain HTTP 401
+	tenant, err := tenants.Resolve(r)
+	if err != nil {
+		zap.L().Warn("Rejected robot session", zap.String("remote_addr", r.RemoteAddr), zap.Error(err))
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	// The session keeps the settings it starts with
+	cfg := utils.CurrentConfig()
+
+	profile := parseProfile(r)
+	modalities, err := parseModalities(r, profile)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	incognito, incognitoSource, err := parseIncognito(r, tenant)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	metadata, err := parseSessionMetadata(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	robot, status, err := sessionRobot(r.Context(), cfg, redisClient, tenant, metadata)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	robotModel := r.URL.Query().Get("robot_model")
+	if robotModel == "" && robot != nil {
+		robotModel = robot.Model
+	}
+	releaseAdmission, ok := admitSession(w, r, cfg, tenant)
+	if !ok {
+		return
+	}
+
+	// Upgrade HTTP connection to WebSocket, offering compression unless the
+	// server or client turned it off
+	sessionUpgrader := upgrader
+	sessionUpgrader.EnableCompression = negotiateCompression(r, cfg)
+	conn, err := sessionUpgrader.Upgrade(w, r, nil)
 	if err != nil {
 		zap.L().Error("Failed to upgrade to websocket", zap.Error(err))
+		releaseAdmission()
 		return
 	}
 
 	zap.L().Info("WebSocket connection upgraded successfully")
+	if sessionUpgrader.EnableCompression {
+		if err := conn.SetCompressionLevel(cfg.WSCompressionLevel); err != nil {
+			zap.L().Warn("Failed to set compression level", zap.Error(err))
+		}
+	}
 
-	// Create new robot session
-	sessionID := uuid.New().String()
-	session := NewRoboSession(sessionID, conn, redisClient)
-	session.Logger.Info("New robot session started")
+	// Create new robot session, resuming a previous one if the client asks
+	// for it and a snapshot survived. Incognito sessions have no snapshots.
+	sessionID := sessionIDs.NewID()
+	var resumed *models.SessionSnapshot
+	if resumeID := r.URL.Query().Get("resume_session_id"); resumeID != "" && !incognito {
+		resumed = claimResumableSnapshot(r.Context(), redisClient, tenant, resumeID)
+		if resumed != nil {
+			sessionID = resumeID
+		}
+	}
+	session := NewRoboSession(sessionID, cfg, conn, redisClient, tenant, tenants)
+	session.Modalities = modalities
+	session.Incognito, session.incognitoSource = incognito, incognitoSource
+	session.UserID = r.URL.Query().Get("user_id")
+	session.setMetadata(metadata)
+	session.releaseAdmission = releaseAdmission
+	session.MetricLabels = utils.NewMetricLabels(tenant.ID, robotModel, r.URL.Query().Get("profile"), metadata[METADATA_SITE])
+	session.MetricLabels.SessionStarted()
+	session.Logger.Info("New robot session started",
+		zap.Bool("resumed", resumed != nil),
+		zap.Bool("incognito", incognito),
+		zap.String("encoding", session.Codec.Name()),
+		zap.Any("capabilities", session.capabilities()))
+	session.attachRobot(robot)
+	session.recordUsage(models.USAGE_SESSIONS, 1)
+	session.applyProfile(profile)
 
 	// Setup handlers
 	session.setupHandlers()
+	if session.IsActive() {
+		if resumed != nil {
+			session.restoreSnapshot(resumed)
+		}
+		registerSession(session)
+		session.saveMeta(time.Time{})
+		session.Supervisor.Go("snapshots", session.runSnapshots)
+		session.Supervisor.Go("inactivity", session.Inactivity.run)
+		if session.hasModality(MODALITY_VIDEO) {
+			if cfg.CaptureRequests && resumed == nil && !profileSets(profile, "capture_requests") {
+				session.setCaptureRequests(true)
+			}
+			session.schedule("stale_context", cfg.StaleContextCheckInterval, session.checkStaleContext)
+			session.schedule("scheduled_capture", cfg.ScheduledCaptureInterval, session.scheduledCapture)
+		}
+		if warmupEnabled(r, cfg) {
+			session.Warmup = session.warmup()
+		}
+		session.sendSessionStartedWebhook(resumed != nil, robotModel, r.URL.Query().Get("profile"))
+	}
 
 	// Send welcome message immediately after upgrade (before starting message listener)
+	worker := utils.Worker()
 	welcomeMsg := WebSocketMessage{
-		Type: "text",
-		Data: map[string]interface{}{
-			"session_id": session.ID,
-			"message":    "Robot session started successfully",
-			"timestamp":  time.Now(),
+		Type:    "text",
+		Version: PROTOCOL_VERSION,
+		Worker:  &worker,
+		Data: SessionStartedPayload{
+			SessionID:       session.ID,
+			Resumed:         resumed != nil,
+			Message:         "Robot session started successfully",
+			ProtocolVersion: PROTOCOL_VERSION,
+			Capabilities:    session.capabilities(),
+			Privacy:         session.privacy(),
+			Metadata:        session.metadataCopy(),
+			Robot:           session.Robot,
+			Timestamp:       session.Clock.Now(),
 		},
-		Timestamp: time.Now(),
+		Timestamp: session.Clock.Now(),
 	}
 
-	if err := conn.WriteJSON(welcomeMsg); err != nil {
+	// Written before the outbound writer starts so it is always the first
+	// message, ahead of anything the handlers queued during setup
+	if err := writeMessage(conn, session.Codec, welcomeMsg); err != nil {
 		session.Logger.Error("Failed to send welcome message", zap.Error(err))
 	} else {
 		session.Logger.Info("Welcome message sent successfully")
 	}
+	session.Outbound.Start(session.Supervisor.Go)
 
-	// Handle incoming websocket messages
-	go session.listenWebsocketMessages(conn)
+	// Handle incoming websocket messages, disconnecting clients that go
+	// silent for WS_READ_TIMEOUT
+	session.keepAlive(conn, cfg.WSReadTimeout)
+	session.Supervisor.Critical("listener", func() { session.listenWebsocketMessages(conn) })
 }
 
 func (rs *RoboSession) listenWebsocketMessages(conn *websocket.Conn) {
 	rs.Logger.Info("Starting WebSocket message listener")
 
+	limits := wsLimits(rs.Config)
+
 	// Handle incoming websocket messages
 	for {
-		// First try to read as JSON message
-		var msg WebSocketMessage
-		err := conn.ReadJSON(&msg)
+		raw, err := readMessage(conn, limits.MaxMessageBytes)
+		if errors.Is(err, errMessageTooLarge) {
+			rs.rejectOversized(conn, limits.MaxMessageBytes)
+			break
+		}
 		if err != nil {
-			rs.Logger.Error("Failed to read JSON message", zap.Error(err))
+			rs.Logger.Error("Failed to read WebSocket message", zap.Error(err))
 			break
 		}
+		if limits.ReadTimeout > 0 {
+			conn.SetReadDeadline(rs.Clock.Now().Add(limits.ReadTimeout))
+		}
+		receivedAt := rs.Clock.Now()
+
+		var msg WebSocketMessage
+		if err := rs.Codec.Unmarshal(raw, &msg); err != nil {
+			rs.sendProtocolError(newProtocolError(PROTOCOL_ERROR_INVALID_JSON, "", "", err.Error()))
+			continue
+		}
 
 		rs.Logger.Debug("Received WebSocket message", zap.String("type", msg.Type))
 
+		if protocolErr := validateInbound(msg); protocolErr != nil {
+			rs.sendProtocolError(protocolErr)
+			continue
+		}
+		if protocolErr := rs.checkModality(msg); protocolErr != nil {
+			rs.sendProtocolError(protocolErr)
+			continue
+		}
+		rs.MetricLabels.MessageReceived(msg.Type)
+
+		if !rs.rateLimiter.Allow() {
+			rs.recordUsage(models.USAGE_RATE_LIMITED, 1)
+			rs.sendWebSocketMessage("rate_limited", RateLimitedPayload{MessageType: msg.Type})
+			continue
+		}
+
 		// Handle different message types
 		switch msg.Type {
 		case "config":
@@ -221,40 +747,43 @@ func (rs *RoboSession) listenWebsocketMessages(conn *websocket.Conn) {
 			rs.handleAudioData(rs.AudioHandler, msg.Data)
 		case "video_data":
 			rs.handleVideoData(msg)
+		case "depth_data":
+			rs.handleDepthData(msg.Data)
+		case "text_input":
+			rs.handleTextInput(msg.Data)
+		case "robot_state":
+			if data, ok := msg.Data.(map[string]interface{}); ok {
+				rs.RobotState.Update(data)
+			}
+		case "display_ack":
+			rs.DisplayHandler.handleAck(msg.Data)
+		case "echo_probe":
+			rs.handleEchoProbe(msg.Data, receivedAt, rs.Clock.Now())
+		case "ask_about_scene":
+			rs.handleAskAboutScene(msg.Data)
+		case "camera_control":
+			rs.Camera.handleMessage(msg.Data)
 		case "ping":
 			// Send pong response
-			pongMsg := WebSocketMessage{
-				Type:      "pong",
-				Timestamp: time.Now(),
-			}
-			if err := conn.WriteJSON(pongMsg); err != nil {
-				rs.Logger.Error("Failed to send pong", zap.Error(err))
-			}
+			rs.sendWebSocketMessage("pong", nil)
 		case "stop":
 			rs.Logger.Info("Received stop command from client")
+			rs.setEnding("", true)
 
 			// Send SESSION_END to all channels to stop all goroutines
 			rs.SendToAllChannels(models.SESSION_END)
 
+			// Queue the confirmation; Stop flushes it before closing the
+			// connection
+			rs.sendWebSocketMessage("text", SessionStoppedPayload{
+				SessionID: rs.ID,
+				Message:   "Session stopped successfully",
+			})
+
 			// Stop the session
 			rs.Stop()
 
-			// Send confirmation back to client
-			stopMsg := WebSocketMessage{
-				Type: "text",
-				Data: map[string]interface{}{
-					"session_id": rs.ID,
-					"message":    "Session stopped successfully",
-				},
-				Timestamp: time.Now(),
-			}
-			if err := conn.WriteJSON(stopMsg); err != nil {
-				rs.Logger.Error("Failed to send stop confirmation", zap.Error(err))
-			}
-
 			return
-		default:
-			rs.Logger.Warn("Unknown message type", zap.String("type", msg.Type))
 		}
 	}
 
@@ -271,19 +800,198 @@ func (rs *RoboSession) handleConfigMessage(data interface{}) {
 		return
 	}
 
+	applied, reconnect := rs.applySessionConfig(configData, rs.sendProtocolError)
+
+	settings := rs.transcriptSettings()
+	inactivity := rs.Inactivity.Settings()
+	rs.saveMeta(time.Time{})
+	rs.sendWebSocketMessage("config_updated", ConfigUpdatedPayload{
+		VideoFrequency: rs.VideoFrequency().String(),
+		RTSPURL:        rs.ingestURL(),
+		CameraDevice:   rs.ingestDevice(),
+		Models:         rs.modelOverrides(),
+
+		TranscriptMaxLength:    settings.MaxLength,
+		TranscriptFlushAfter:   settings.FlushAfter.String(),
+		EchoInterimTranscripts: settings.EchoInterim,
+		VisionROI:              rs.visionROI(),
+		CaptureRequests:        rs.captureRequestsEnabled(),
+		AdaptiveVideoFrequency: rs.AdaptiveFrequency.Enabled(),
+		EnvironmentChanges:     rs.Changes.Enabled(),
+
+		InactivityPromptAfter:   inactivity.PromptAfter.String(),
+		InactivityLowPowerAfter: inactivity.LowPowerAfter.String(),
+	})
+
+	sort.Strings(applied)
+	ack := ConfigAppliedPayload{Applied: applied, Reconnected: reconnect}
+	if ack.Applied == nil {
+		ack.Applied = []string{}
+	}
+	if len(reconnect) == 0 || rs.AudioHandler == nil {
+		ack.Reconnected = []string{}
+		ack.STTConnected = rs.AudioHandler != nil && rs.AudioHandler.Connected()
+		rs.sendWebSocketMessage("config_applied", ack)
+		return
+	}
+	// Acknowledged once the new stream is up
+	rs.Supervisor.Task("stt_reconnect", func() {
+		ack.STTConnected = rs.AudioHandler.Reconnect()
+		rs.Logger.Info("Speech-to-text reconnected for config change",
+			zap.Strings("settings", reconnect),
+			zap.Bool("connected", ack.STTConnected))
+		rs.sendWebSocketMessage("config_applied", ack)
+	})
+}
+
+// applySessionConfig applies the settings of a config message, passing
+// invalid ones to reject. It returns the keys that took effect and those of
+// them that need a new speech-to-text stream.
+func (rs *RoboSession) applySessionConfig(configData map[string]interface{}, reject func(*ProtocolError)) (applied, reconnect []string) {
+	accept := func(keys ...string) {
+		for _, key := range keys {
+			if _, exists := configData[key]; exists {
+				applied = append(applied, key)
+			}
+		}
+	}
+
 	// Parse video frequency
 	if videoFreq, exists := configData["video_frequency"]; exists {
 		if freqStr, ok := videoFreq.(string); ok {
 			if duration, err := time.ParseDuration(freqStr); err == nil {
-				rs.VideoFrequency = duration
+				rs.setVideoFrequency(duration)
 				rs.Logger.Info("Updated video frequency", zap.Duration("frequency", duration))
+				accept("video_frequency")
 			}
 		}
 	}
 
-	rs.sendWebSocketMessage("config_updated", map[string]interface{}{
-		"video_frequency": rs.VideoFrequency.String(),
-	})
+	// Transcript accumulation limits
+	if field, err := rs.applyTranscriptConfig(configData); err != nil {
+		reject(newProtocolError(PROTOCOL_ERROR_INVALID_PAYLOAD, "config", field, err.Error()))
+	} else {
+		accept("transcript_max_length", "transcript_flush_after", "echo_interim_transcripts")
+	}
+
+	// Speech-to-text language, endpointing and recognition options; changes
+	// reconnect the stream
+	previousSTT := rs.sttSettings()
+	if field, changed, err := rs.applySTTConfig(configData); err != nil {
+		reject(newProtocolError(PROTOCOL_ERROR_INVALID_PAYLOAD, "config", field, err.Error()))
+	} else {
+		for key := range sttConfig(previousSTT) {
+			accept(key)
+		}
+		if changed {
+			rs.Logger.Info("Updated speech-to-text options", zap.Any("stt", sttConfig(rs.sttSettings())))
+			reconnect = append(reconnect, changedSTTSettings(previousSTT, rs.sttSettings())...)
+		}
+	}
+	if field, changed, err := rs.applySceneBoostConfig(configData); err != nil {
+		reject(newProtocolError(PROTOCOL_ERROR_INVALID_PAYLOAD, "config", field, err.Error()))
+	} else {
+		accept("stt_scene_boost")
+		if changed {
+			reconnect = append(reconnect, "stt_scene_boost")
+		}
+	}
+
+	// Audio format sent by the robot; the current stream is flushed and
+	// closed, audio buffered until the new one connects
+	if field, changed, err := rs.applyAudioFormatConfig(configData); err != nil {
+		reject(newProtocolError(PROTOCOL_ERROR_INVALID_PAYLOAD, "config", field, err.Error()))
+	} else {
+		accept("audio_encoding", "audio_sample_rate")
+		if changed {
+			for _, key := range []string{"audio_encoding", "audio_sample_rate"} {
+				if _, exists := configData[key]; exists {
+					reconnect = append(reconnect, key)
+				}
+			}
+		}
+	}
+
+	// Region of interest cropped from frames before vision analysis
+	if field, err := rs.applyVisionConfig(configData); err != nil {
+		reject(newProtocolError(PROTOCOL_ERROR_INVALID_PAYLOAD, "config", field, err.Error()))
+	} else {
+		accept("vision_roi")
+	}
+
+	// Per-session model chains; a new STT model reconnects the stream
+	if value, exists := configData["models"]; exists {
+		overrides, err := parseModelOverrides(value)
+		if err != nil {
+			reject(newProtocolError(PROTOCOL_ERROR_INVALID_PAYLOAD, "config", "data.models", err.Error()))
+		} else {
+			rs.setModelOverrides(overrides)
+			rs.Logger.Info("Updated model configuration", zap.Any("models", overrides))
+			accept("models")
+			if _, ok := overrides[utils.MODEL_TASK_STT]; ok {
+				reconnect = append(reconnect, "models."+utils.MODEL_TASK_STT)
+			}
+		}
+	}
+
+	// Video frequency adapted to scene dynamics and frame budget
+	if field, err := rs.applyAdaptiveFrequencyConfig(configData); err != nil {
+		reject(newProtocolError(PROTOCOL_ERROR_INVALID_PAYLOAD, "config", field, err.Error()))
+	} else {
+		accept("adaptive_video_frequency")
+	}
+
+	// environment_change messages for every change in the scene
+	if field, err := rs.applyEnvironmentChangesConfig(configData); err != nil {
+		reject(newProtocolError(PROTOCOL_ERROR_INVALID_PAYLOAD, "config", field, err.Error()))
+	} else {
+		accept("environment_changes")
+	}
+
+	// Prompts and low-power mode after dead air
+	if field, err := rs.applyInactivityConfig(configData); err != nil {
+		reject(newProtocolError(PROTOCOL_ERROR_INVALID_PAYLOAD, "config", field, err.Error()))
+	} else {
+		accept("inactivity_prompt_after", "inactivity_prompt", "inactivity_prompt_speak", "inactivity_low_power_after")
+	}
+
+	// Server-driven capture at the video frequency
+	if field, err := rs.applyCaptureConfig(configData); err != nil {
+		reject(newProtocolError(PROTOCOL_ERROR_INVALID_PAYLOAD, "config", field, err.Error()))
+	} else {
+		accept("capture_requests")
+	}
+
+	// Start, replace or stop (empty string) server-side RTSP ingest
+	if rtspURL, exists := configData["rtsp_url"]; exists {
+		if urlStr, ok := rtspURL.(string); ok {
+			if rs.hasModality(MODALITY_VIDEO) {
+				rs.setRTSPSource(urlStr)
+				accept("rtsp_url")
+			} else if urlStr != "" {
+				reject(newProtocolError(PROTOCOL_ERROR_MODALITY_DISABLED, "config", "data.rtsp_url",
+					"video modality is not enabled for this session"))
+			}
+		}
+	}
+
+	// Start, replace or stop (empty string) capture from a server camera
+	if device, exists := configData["camera_device"]; exists {
+		if deviceStr, ok := device.(string); ok {
+			if deviceStr != "" && !rs.Config.ServerCamerasEnabled {
+				reject(newProtocolError(PROTOCOL_ERROR_INVALID_PAYLOAD, "config", "data.camera_device",
+					"server cameras are not enabled"))
+			} else if rs.hasModality(MODALITY_VIDEO) {
+				rs.setCameraDevice(deviceStr)
+				accept("camera_device")
+			} else if deviceStr != "" {
+				reject(newProtocolError(PROTOCOL_ERROR_MODALITY_DISABLED, "config", "data.camera_device",
+					"video modality is not enabled for this session"))
+			}
+		}
+	}
+
+	return applied, reconnect
 }
 
 func (rs *RoboSession) handleAudioData(audioHandler *AudioHandler, data interface{}) {
@@ -293,15 +1001,39 @@ func (rs *RoboSession) handleAudioData(audioHandler *AudioHandler, data interfac
 	audioBytes, err := rs.extractAudioBytes(data)
 	if err != nil {
 		rs.Logger.Warn("Unable to extract audio bytes", zap.Error(err))
+		rs.sendError(ERROR_CODE_AUDIO_DECODE, "audio_data", err.Error())
 		return
 	}
 
 	// Hand off to the audio handler
 	if err := audioHandler.ProcessAudioData(audioBytes); err != nil {
 		rs.Logger.Error("Failed to process audio data", zap.Error(err))
+		if errors.Is(err, utils.ErrAudioDecode) {
+			rs.sendError(ERROR_CODE_AUDIO_DECODE, "audio_data", err.Error())
+			return
+		}
+		rs.sendError(ERROR_CODE_STT_UNAVAILABLE, "audio_data", err.Error())
 	}
 }
 
+// handleTextInput treats a typed command as a finished utterance, skipping
+// speech-to-text.
+func (rs *RoboSession) handleTextInput(data interface{}) {
+	payload, _ := data.(map[string]interface{})
+	text, _ := payload["text"].(string)
+	text = strings.TrimSpace(rs.filterTranscript(text))
+	if text == "" {
+		return
+	}
+
+	rs.Logger.Info("Text input received, processing transcript", zap.String("transcript", rs.redact(text)))
+	rs.sendWebSocketMessage("transcript_final", TranscriptPayload{Transcript: text, Source: "text"})
+	rs.Captions.Publish(text, true, "text")
+
+	rs.startUtterance()
+	rs.IntentionHandler.ProcessTranscript(text)
+}
+
 func (rs *RoboSession) extractAudioBytes(data interface{}) ([]byte, error) {
 	switch v := data.(type) {
 	case []byte:
@@ -321,37 +1053,192 @@ func (rs *RoboSession) extractAudioBytes(data interface{}) ([]byte, error) {
 }
 
 func (rs *RoboSession) sendWebSocketMessage(msgType string, data interface{}) {
+	worker := utils.Worker()
 	msg := WebSocketMessage{
 		Type:      msgType,
+		Version:   PROTOCOL_VERSION,
 		Data:      data,
-		Timestamp: time.Now(),
+		Worker:    &worker,
+		Timestamp: rs.Clock.Now(),
 	}
-	if err := rs.Connection.WriteJSON(msg); err != nil {
-		rs.Logger.Error("failed to send ws message",
-			zap.String("type", msgType), zap.Error(err))
+	rs.Outbound.Enqueue(msg)
+	if feedEventTypes[msgType] {
+		rs.Events.Publish(msg)
 	}
 }
 
+func (rs *RoboSession) sendProtocolError(protocolErr *ProtocolError) {
+	rs.Logger.Warn("Rejected malformed WebSocket message",
+		zap.String("code", protocolErr.Code),
+		zap.String("message_type", protocolErr.MessageType),
+		zap.String("field", protocolErr.Field),
+		zap.String("error", protocolErr.Message))
+	rs.sendWebSocketMessage("protocol_error", protocolErr)
+}
+
 // handles API requests to capture an image
 func (rs *RoboSession) handleVideoData(msg WebSocketMessage) {
-	b64, ok := msg.Data.(string)
-	if !ok {
+	var b64 string
+	switch data := msg.Data.(type) {
+	case string:
+		if protocolErr := checkFrameSize(data, rs.Config.WSMaxFrameBytes); protocolErr != nil {
+			rs.sendProtocolError(protocolErr)
+			return
+		}
+		b64 = data
+		if !strings.HasPrefix(b64, "data:image") {
+			b64 = "data:image/jpeg;base64," + b64
+		}
+	case []byte:
+		// Raw bytes over MessagePack are encoded once, for the vision model
+		if limit := rs.Config.WSMaxFrameBytes; limit > 0 && len(data) > limit {
+			rs.sendProtocolError(newProtocolError(PROTOCOL_ERROR_MESSAGE_TOO_LARGE, "video_data", "data",
+				fmt.Sprintf("frame of %d bytes exceeds %d bytes", len(data), limit)))
+			return
+		}
+		mimeType := http.DetectContentType(data)
+		if !strings.HasPrefix(mimeType, "image/") {
+			mimeType = "image/jpeg"
+		}
+		b64 = "data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(data)
+	default:
 		rs.Logger.Warn("video_data payload not a string", zap.Any("data", msg.Data))
 		return
 	}
+	rs.submitFrame(b64, msg.RequestID)
+}
+
+// queuedFrame is a data-URL frame waiting for analysis, with the ID of the
+// capture_request it answers, if any.
+type queuedFrame struct {
+	image     string
+	requestID string
+}
 
-	if !strings.HasPrefix(b64, "data:image") {
-		b64 = "data:image/jpeg;base64," + b64
+// submitFrame echoes a data-URL frame to the client and queues it for
+// analysis, reporting whether it was queued.
+func (rs *RoboSession) submitFrame(b64, requestID string) bool {
+	// Frames also arrive over HTTP, possibly while the session stops
+	rs.framesMu.RLock()
+	defer rs.framesMu.RUnlock()
+	if rs.framesClosed {
+		return false
 	}
+
+	// Kept for questions about the scene, and recorded for review
+	rs.RecentFrames.Add(b64, rs.Clock.Now())
+	rs.Recorder.Add(b64, rs.Clock.Now())
+
 	// 1) echo back so the <img id="videoPreview"> renders it
-	rs.sendWebSocketMessage("video_frame", map[string]string{
-		"image_b64": b64,
-	})
+	rs.sendWebSocketMessage("video_frame", VideoFramePayload{ImageB64: b64})
 
 	// 2) then hand off for analysis
 	select {
-	case rs.VideoAnalysisCh <- b64:
+	case rs.VideoAnalysisCh <- queuedFrame{image: b64, requestID: requestID}:
+		return true
 	default:
 		rs.Logger.Warn("video_analysis channel full, dropping frame")
+		rs.sendError(ERROR_CODE_FRAME_DROPPED, "video_data", "Vision analysis is busy, frame dropped")
+		return false
+	}
+}
+
+// stopIngest stops server-side capture of an RTSP source or camera.
+func (rs *RoboSession) stopIngest() {
+	if ingester := rs.swapIngester(nil); ingester != nil {
+		ingester.Stop()
+	}
+}
+
+// currentIngester returns the server-side capture, or nil when frames come
+// from the robot.
+func (rs *RoboSession) currentIngester() *RTSPIngester {
+	rs.stateMu.Lock()
+	defer rs.stateMu.Unlock()
+	return rs.ingester
+}
+
+// swapIngester installs a server-side capture and returns the previous
+// one, which the caller stops outside the lock.
+func (rs *RoboSession) swapIngester(ingester *RTSPIngester) *RTSPIngester {
+	rs.stateMu.Lock()
+	defer rs.stateMu.Unlock()
+	previous := rs.ingester
+	rs.ingester = ingester
+	return previous
+}
+
+// ingestDevice returns the server camera frames are captured from, if any.
+func (rs *RoboSession) ingestDevice() string {
+	if ingester := rs.currentIngester(); ingester != nil {
+		return ingester.device
+	}
+	return ""
+}
+
+// ingestURL returns the stream frames are captured from, if any.
+func (rs *RoboSession) ingestURL() string {
+	if ingester := rs.currentIngester(); ingester != nil {
+		return ingester.url
+	}
+	return ""
+}
+
+// VideoFrequency returns how often the client asked for pictures; the
+// effective interval is AdaptiveFrequency's.
+func (rs *RoboSession) VideoFrequency() time.Duration {
+	rs.stateMu.Lock()
+	defer rs.stateMu.Unlock()
+	return rs.videoFrequency
+}
+
+func (rs *RoboSession) setVideoFrequency(frequency time.Duration) {
+	rs.stateMu.Lock()
+	defer rs.stateMu.Unlock()
+	rs.videoFrequency = frequency
+}
+
+// setCameraDevice starts, replaces or stops (empty string) capture from a
+// camera attached to the server. It replaces an RTSP source.
+func (rs *RoboSession) setCameraDevice(device string) {
+	if device == "" {
+		if rs.ingestDevice() != "" {
+			rs.stopIngest()
+		}
+		return
+	}
+	rs.stopIngest()
+
+	ingester, err := StartDeviceIngester(rs, device)
+	if err != nil {
+		rs.Logger.Warn("Failed to start camera ingest", zap.String("device", device), zap.Error(err))
+		rs.sendError(ERROR_CODE_CAMERA_FAILED, "config", err.Error())
+		return
+	}
+	if previous := rs.swapIngester(ingester); previous != nil {
+		previous.Stop()
+	}
+}
+
+// setRTSPSource starts, replaces or stops (empty string) RTSP ingest. It
+// replaces a server camera.
+func (rs *RoboSession) setRTSPSource(url string) {
+	if url == "" {
+		if rs.ingestURL() != "" {
+			rs.stopIngest()
+		}
+		return
+	}
+	rs.stopIngest()
+
+	ingester, err := StartRTSPIngester(rs, url)
+	if err != nil {
+		rs.Logger.Warn("Failed to start RTSP ingest", zap.String("url", url), zap.Error(err))
+		rs.sendWebSocketMessage("rtsp_error", RTSPErrorPayload{URL: url, Error: err.Error()})
+		rs.sendError(ERROR_CODE_RTSP_FAILED, "config", err.Error())
+		return
+	}
+	if previous := rs.swapIngester(ingester); previous != nil {
+		previous.Stop()
 	}
 }
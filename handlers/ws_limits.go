@@ -0,0 +1,119 @@
+// handlers/ws_limits.go
+
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+// errMessageTooLarge is returned by readMessage for a message over
+// WS_MAX_MESSAGE_BYTES.
+var errMessageTooLarge = errors.New("message too large")
+
+// WebSocketLimits bound what a client may send on a session's WebSocket.
+type WebSocketLimits struct {
+	// MaxMessageBytes is the largest inbound message; a client exceeding it
+	// is disconnected
+	MaxMessageBytes int64
+	// MaxFrameBytes is the largest decoded video_data image; larger frames
+	// are rejected without disconnecting
+	MaxFrameBytes int
+	// ReadTimeout disconnects a client that sends nothing, not even a pong
+	// to the server's pings, for this long. 0 disables it
+	ReadTimeout time.Duration
+}
+
+// wsLimits reads WS_MAX_MESSAGE_BYTES, WS_MAX_FRAME_BYTES and
+// WS_READ_TIMEOUT.
+func wsLimits(cfg *utils.Config) WebSocketLimits {
+	return WebSocketLimits{
+		MaxMessageBytes: int64(cfg.WSMaxMessageBytes),
+		MaxFrameBytes:   cfg.WSMaxFrameBytes,
+		ReadTimeout:     cfg.WSReadTimeout,
+	}
+}
+
+// readMessage reads the next message, refusing to buffer more than limit
+// bytes of it. gorilla's own SetReadLimit closes the connection before the
+// client can be told why, so the limit is enforced here instead.
+func readMessage(conn *websocket.Conn, limit int64) ([]byte, error) {
+	_, reader, err := conn.NextReader()
+	if err != nil {
+		return nil, err
+	}
+	raw, err := io.ReadAll(io.LimitReader(reader, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(raw)) > limit {
+		return nil, errMessageTooLarge
+	}
+	return raw, nil
+}
+
+// keepAlive enforces the read deadline: every message or pong extends it,
+// and the server pings at half the timeout so idle but healthy clients stay
+// connected.
+func (rs *RoboSession) keepAlive(conn *websocket.Conn, timeout time.Duration) {
+	if timeout <= 0 {
+		return
+	}
+	conn.SetReadDeadline(rs.Clock.Now().Add(timeout))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(rs.Clock.Now().Add(timeout))
+	})
+
+	rs.Supervisor.Go("ws_ping", func() {
+		ping := rs.Clock.NewTicker(timeout / 2)
+		defer ping.Stop()
+		for {
+			select {
+			case <-rs.sessionCtx.Done():
+				return
+			case <-ping.C():
+				if err := conn.WriteControl(websocket.PingMessage, nil, rs.Clock.Now().Add(5*time.Second)); err != nil {
+					rs.Logger.Debug("Failed to send WebSocket ping", zap.Error(err))
+					return
+				}
+			}
+		}
+	})
+}
+
+// rejectOversized tells the client its message was too large, then closes
+// the connection with 1009. The rest of the message is never read, so the
+// connection cannot be used any further.
+func (rs *RoboSession) rejectOversized(conn *websocket.Conn, limit int64) {
+	rs.Logger.Warn("WebSocket message too large, disconnecting", zap.Int64("limit_bytes", limit))
+	rs.sendProtocolError(newProtocolError(PROTOCOL_ERROR_MESSAGE_TOO_LARGE, "", "",
+		fmt.Sprintf("message exceeds %d bytes", limit)))
+	rs.Outbound.Close(rs.Config.OutboundFlushTimeout)
+	conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseMessageTooBig, "message too large"), rs.Clock.Now().Add(time.Second))
+}
+
+// checkFrameSize rejects a video_data frame whose decoded image exceeds
+// WS_MAX_FRAME_BYTES.
+func checkFrameSize(dataURL string, limit int) *ProtocolError {
+	if limit <= 0 {
+		return nil
+	}
+	encoded := dataURL
+	if _, after, found := strings.Cut(dataURL, ","); found && strings.HasPrefix(dataURL, "data:") {
+		encoded = after
+	}
+	if size := base64.StdEncoding.DecodedLen(len(encoded)); size > limit {
+		return newProtocolError(PROTOCOL_ERROR_MESSAGE_TOO_LARGE, "video_data", "data",
+			fmt.Sprintf("frame of about %d bytes exceeds %d bytes", size, limit))
+	}
+	return nil
+}
@@ -0,0 +1,878 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/codec"
+	"github.com/Perceptus-Labs/perceptus-go-sdk/handlers"
+	"github.com/Perceptus-Labs/perceptus-go-sdk/mocks"
+	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+	"github.com/gorilla/websocket"
+	"github.com/redis/go-redis/v9"
+)
+
+// The integration tests run robot sessions against the HTTP API with every
+// provider mocked. They use the Redis at PERCEPTUS_TEST_REDIS_ADDR, or run
+// without one the way sessions do through a Redis outage.
+
+const (
+	testTimeout = 5 * time.Second
+	testAPIKey  = "integration-key"
+)
+
+var (
+	testServer       *httptest.Server
+	testSTT          *mocks.STT
+	testLLM          *mocks.LLM
+	testVectorStore  *mocks.VectorStore
+	testOrchestrator *mocks.Orchestrator
+)
+
+func TestMain(m *testing.M) {
+	testSTT = mocks.NewSTT()
+	if err := testSTT.Register(); err != nil {
+		panic(err)
+	}
+	testLLM = mocks.NewLLM()
+	testVectorStore = mocks.NewVectorStore()
+	testOrchestrator = mocks.NewOrchestrator()
+
+	for key, value := range map[string]string{
+		"OPENAI_API_KEY":                "test",
+		"OPENAI_BASE_URL":               testLLM.URL(),
+		"OPENAI_MAX_ATTEMPTS":           "1",
+		"STT_PROVIDER":                  mocks.STT_PROVIDER,
+		"PINECONE_API_KEY":              "test",
+		"PINECONE_HOST":                 testVectorStore.URL(),
+		"PINECONE_NAMESPACE":            "integration",
+		"PINECONE_WRITE_BATCH_SIZE":     "1",
+		"PINECONE_WRITE_FLUSH_INTERVAL": "20ms",
+		"ORCHESTRATOR_URL":              testOrchestrator.URL(),
+		"ORCHESTRATOR_API_KEY":          "test",
+		"FRAME_QUALITY_CHECK":           "false",
+		"API_KEYS":                      testAPIKey,
+	} {
+		os.Setenv(key, value)
+	}
+	for _, key := range []string{"TENANTS_FILE", "EMBEDDING_PROVIDER"} {
+		os.Unsetenv(key)
+	}
+	cfg, _, err := utils.LoadConfig("")
+	if err != nil {
+		panic(err)
+	}
+	utils.SetConfig(cfg)
+
+	redisClient := testRedisClient()
+	tenants, err := utils.NewTenantStore(redisClient)
+	if err != nil {
+		panic(err)
+	}
+	testServer = httptest.NewServer(newRouter(cfg, redisClient, tenants))
+
+	code := m.Run()
+
+	testServer.Close()
+	testOrchestrator.Close()
+	testVectorStore.Close()
+	testLLM.Close()
+	os.Exit(code)
+}
+
+// testRedisClient connects to PERCEPTUS_TEST_REDIS_ADDR, or returns a client
+// whose every command fails at once.
+func testRedisClient() *redis.Client {
+	if addr := os.Getenv("PERCEPTUS_TEST_REDIS_ADDR"); addr != "" {
+		return redis.NewClient(&redis.Options{Addr: addr})
+	}
+	return redis.NewClient(&redis.Options{
+		Addr:       "redis.invalid:6379",
+		MaxRetries: -1,
+		Dialer: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return nil, errors.New("no test Redis configured")
+		},
+	})
+}
+
+// apiRequest sends a request authenticated with the test API key.
+func apiRequest(method, path string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(method, testServer.URL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+testAPIKey)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return http.DefaultClient.Do(req)
+}
+
+// authHeader authenticates WebSocket dials with the test API key.
+func authHeader() http.Header {
+	return http.Header{"Authorization": {"Bearer " + testAPIKey}}
+}
+
+// testSession is a robot's WebSocket connection to the test server.
+type testSession struct {
+	t            *testing.T
+	conn         *websocket.Conn
+	id           string
+	capabilities testCapabilities
+	messages     chan testMessage
+}
+
+type testCapabilities struct {
+	Modalities []string `json:"modalities"`
+	Profile    string   `json:"profile"`
+	Memory     bool     `json:"memory"`
+}
+
+type testMessage struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+// startSession opens a robot session with the query string and reads its
+// welcome message.
+func startSession(t *testing.T, query string) *testSession {
+	t.Helper()
+	url := "ws" + strings.TrimPrefix(testServer.URL, "http") + "/robot/session?" + query
+	conn, _, err := websocket.DefaultDialer.Dial(url, authHeader())
+	if err != nil {
+		t.Fatalf("dial session: %v", err)
+	}
+	s := &testSession{t: t, conn: conn, messages: make(chan testMessage, 256)}
+
+	var welcome struct {
+		Data struct {
+			SessionID    string           `json:"session_id"`
+			Capabilities testCapabilities `json:"capabilities"`
+		} `json:"data"`
+	}
+	if err := conn.ReadJSON(&welcome); err != nil {
+		t.Fatalf("read welcome message: %v", err)
+	}
+	if welcome.Data.SessionID == "" {
+		t.Fatal("welcome message has no session_id")
+	}
+	s.id, s.capabilities = welcome.Data.SessionID, welcome.Data.Capabilities
+
+	go func() {
+		defer close(s.messages)
+		for {
+			var msg testMessage
+			if err := conn.ReadJSON(&msg); err != nil {
+				return
+			}
+			s.messages <- msg
+		}
+	}()
+	t.Cleanup(func() {
+		s.send("stop", nil)
+		conn.Close()
+	})
+	return s
+}
+
+func (s *testSession) send(msgType string, data interface{}) {
+	s.t.Helper()
+	if err := s.conn.WriteJSON(map[string]interface{}{"type": msgType, "data": data}); err != nil {
+		s.t.Fatalf("send %s: %v", msgType, err)
+	}
+}
+
+// expect skips messages until one of msgType arrives and decodes its data
+// into v.
+func (s *testSession) expect(msgType string, v interface{}) {
+	s.t.Helper()
+	timeout := time.After(testTimeout)
+	for {
+		select {
+		case msg, ok := <-s.messages:
+			if !ok {
+				s.t.Fatalf("connection closed waiting for %s", msgType)
+			}
+			if msg.Type != msgType {
+				continue
+			}
+			if v != nil {
+				if err := json.Unmarshal(msg.Data, v); err != nil {
+					s.t.Fatalf("decode %s: %v", msgType, err)
+				}
+			}
+			return
+		case <-timeout:
+			s.t.Fatalf("no %s message within %s", msgType, testTimeout)
+		}
+	}
+}
+
+func TestTextInputReachesOrchestrator(t *testing.T) {
+	testLLM.ScriptIntention(mocks.Intention{
+		HasClearIntention: true,
+		IntentionType:     "fetch",
+		Description:       "Bring the red mug from the kitchen",
+		Confidence:        0.95,
+	})
+	s := startSession(t, "modalities=")
+
+	s.send("text_input", map[string]string{"text": "please bring me the red mug"})
+
+	var transcript struct {
+		Transcript string `json:"transcript"`
+	}
+	s.expect("transcript_final", &transcript)
+	if transcript.Transcript != "please bring me the red mug" {
+		t.Errorf("transcript_final = %q", transcript.Transcript)
+	}
+
+	var intention models.IntentionResult
+	s.expect("intention_analysis", &intention)
+	if !intention.HasClearIntention || intention.IntentionType != "fetch" {
+		t.Errorf("intention_analysis = %+v, want a clear fetch intention", intention)
+	}
+
+	requests := testOrchestrator.WaitRequests(s.id, 1, testTimeout)
+	if len(requests) != 1 {
+		t.Fatalf("orchestrator received %d requests for the session, want 1", len(requests))
+	}
+	body := requests[0].Body
+	if body["intention_type"] != "fetch" || body["transcript"] != "please bring me the red mug" {
+		t.Errorf("orchestrator payload = %v", body)
+	}
+}
+
+func TestUnclearIntentionIsNotForwarded(t *testing.T) {
+	s := startSession(t, "modalities=")
+
+	// Unscripted, the LLM finds no clear intention
+	s.send("text_input", map[string]string{"text": "hmm"})
+
+	var intention models.IntentionResult
+	s.expect("intention_analysis", &intention)
+	if intention.HasClearIntention {
+		t.Errorf("intention_analysis = %+v, want no clear intention", intention)
+	}
+	if requests := testOrchestrator.Requests(s.id); len(requests) != 0 {
+		t.Errorf("orchestrator received %d requests for the session, want none", len(requests))
+	}
+}
+
+func TestSpeechReachesOrchestrator(t *testing.T) {
+	testSTT.Say("turn left at the door")
+	testLLM.ScriptIntention(mocks.Intention{
+		HasClearIntention: true,
+		IntentionType:     "navigation",
+		Description:       "Turn left at the door",
+		Confidence:        0.9,
+	})
+	s := startSession(t, "modalities=audio")
+
+	// 100ms of 16 kHz 16-bit silence
+	s.send("audio_data", base64.StdEncoding.EncodeToString(make([]byte, 3200)))
+
+	var transcript struct {
+		Transcript string `json:"transcript"`
+	}
+	s.expect("transcript_final", &transcript)
+	if transcript.Transcript != "turn left at the door" {
+		t.Errorf("transcript_final = %q", transcript.Transcript)
+	}
+
+	requests := testOrchestrator.WaitRequests(s.id, 1, testTimeout)
+	if len(requests) != 1 || requests[0].Body["intention_type"] != "navigation" {
+		t.Fatalf("orchestrator requests for the session = %v, want one navigation intention", requests)
+	}
+	if testSTT.AudioBytes() == 0 {
+		t.Error("speech-to-text received no audio")
+	}
+}
+
+func TestSceneIsRememberedForIntentions(t *testing.T) {
+	overview := "A kitchen counter with a red mug next to the sink"
+	testLLM.SetScene(models.EnvironmentContext{
+		Overview:    overview,
+		KeyElements: []string{"red mug", "sink"},
+		Activities:  []string{},
+	})
+	s := startSession(t, "modalities=video")
+
+	s.send("video_data", testFrame(t))
+
+	var scene models.EnvironmentContext
+	s.expect("video_analysis", &scene)
+	if scene.Overview != overview {
+		t.Errorf("video_analysis overview = %q, want %q", scene.Overview, overview)
+	}
+
+	// Earlier sessions' records may already be stored, so wait for this one
+	var stored bool
+	for deadline := time.Now().Add(testTimeout); !stored && time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		for _, record := range testVectorStore.Records("integration") {
+			text, _ := record["chunk_text"].(string)
+			if record["session_id"] == s.id && strings.Contains(text, overview) {
+				stored = true
+			}
+		}
+	}
+	if !stored {
+		t.Fatalf("vector store has no record of the scene: %v", testVectorStore.Records("integration"))
+	}
+
+	intentions := len(testLLM.Requests(mocks.LLM_TASK_INTENTION))
+	s.send("text_input", map[string]string{"text": "where is the red mug"})
+	s.expect("intention_analysis", nil)
+
+	requests := testLLM.Requests(mocks.LLM_TASK_INTENTION)
+	if len(requests) <= intentions {
+		t.Fatal("the LLM received no intention request")
+	}
+	if prompt := requests[len(requests)-1].Text(); !strings.Contains(prompt, overview) {
+		t.Errorf("intention prompt lacks the scene:\n%s", prompt)
+	}
+}
+
+func TestProfilePresetsSession(t *testing.T) {
+	s := startSession(t, "profile=voice-only")
+
+	if got := strings.Join(s.capabilities.Modalities, ","); got != "audio,text" {
+		t.Errorf("modalities = %s, want audio,text", got)
+	}
+	if s.capabilities.Profile != "voice-only" || s.capabilities.Memory {
+		t.Errorf("capabilities = %+v, want the voice-only profile without memory", s.capabilities)
+	}
+
+	// The profile leaves the camera off
+	s.send("video_data", testFrame(t))
+	var protocolErr struct {
+		Code string `json:"code"`
+	}
+	s.expect("protocol_error", &protocolErr)
+	if protocolErr.Code != "E_MODALITY_DISABLED" {
+		t.Errorf("protocol_error code = %s, want E_MODALITY_DISABLED", protocolErr.Code)
+	}
+}
+
+func TestCameraControlReachesRobot(t *testing.T) {
+	s := startSession(t, "modalities=video")
+
+	type cameraState struct {
+		RequestID string               `json:"request_id"`
+		Action    string               `json:"action"`
+		Settings  utils.CameraSettings `json:"settings"`
+	}
+	response := make(chan *http.Response, 1)
+	go func() {
+		body := strings.NewReader(`{"width":1920,"height":1080,"zoom":2}`)
+		resp, err := apiRequest(http.MethodPost, "/robot/sessions/"+s.id+"/camera?wait=5s", body)
+		if err != nil {
+			t.Errorf("post camera settings: %v", err)
+		}
+		response <- resp
+	}()
+
+	var request cameraState
+	s.expect("camera_control", &request)
+	if request.Action != "set" || request.Settings.Width != 1920 || request.Settings.Zoom != 2 {
+		t.Fatalf("camera_control = %+v, want the posted settings", request)
+	}
+	s.send("camera_control", map[string]interface{}{
+		"action":     "state",
+		"request_id": request.RequestID,
+		"settings":   request.Settings,
+	})
+
+	resp := <-response
+	if resp == nil {
+		return
+	}
+	defer resp.Body.Close()
+	var state cameraState
+	if err := json.NewDecoder(resp.Body).Decode(&state); err != nil {
+		t.Fatalf("decode camera state: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || state.Action != "state" || state.Settings.Height != 1080 {
+		t.Errorf("camera response = %d %+v, want the robot's state", resp.StatusCode, state)
+	}
+}
+
+func TestInternalStreamIsRefused(t *testing.T) {
+	s := startSession(t, "modalities=video")
+
+	s.send("config", map[string]interface{}{"rtsp_url": "rtsp://127.0.0.1:8554/stream"})
+	var sessionErr struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	}
+	s.expect("error", &sessionErr)
+	if sessionErr.Code != "E_RTSP_FAILED" || !strings.Contains(sessionErr.Message, "internal address") {
+		t.Errorf("error = %+v, want E_RTSP_FAILED for an internal address", sessionErr)
+	}
+}
+
+func TestDebugIntentionReachesOrchestrator(t *testing.T) {
+	defaults := utils.CurrentConfig()
+	cfg := *defaults
+	cfg.DebugEndpointsEnabled = true
+	utils.SetConfig(&cfg)
+	defer utils.SetConfig(defaults)
+	s := startSession(t, "modalities=")
+
+	body := strings.NewReader(`{"session_id":"` + s.id + `","intention_type":"deliver","description":"Take the parcel to room 4","slots":{"room":"4"}}`)
+	resp, err := apiRequest(http.MethodPost, "/debug/intentions", body)
+	if err != nil {
+		t.Fatalf("post simulated intention: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("POST /debug/intentions = %d, want 200", resp.StatusCode)
+	}
+
+	requests := testOrchestrator.WaitRequests(s.id, 1, testTimeout)
+	if len(requests) != 1 {
+		t.Fatalf("orchestrator received %d requests for the session, want 1", len(requests))
+	}
+	payload := requests[0].Body
+	if payload["intention_type"] != "deliver" || payload["source"] != "simulated" || payload["confidence"] != 1.0 {
+		t.Errorf("orchestrator payload = %v", payload)
+	}
+}
+
+// dialMessagePack opens a session speaking MessagePack, returning the
+// connection and a reader of its messages.
+func dialMessagePack(t *testing.T, query string) (*websocket.Conn, func() (string, map[string]interface{})) {
+	t.Helper()
+	dialer := *websocket.DefaultDialer
+	dialer.Subprotocols = []string{codec.SUBPROTOCOL_MSGPACK}
+	conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(testServer.URL, "http")+"/robot/session?"+query, authHeader())
+	if err != nil {
+		t.Fatalf("dial session: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	if conn.Subprotocol() != codec.SUBPROTOCOL_MSGPACK {
+		t.Fatalf("negotiated subprotocol %q, want %s", conn.Subprotocol(), codec.SUBPROTOCOL_MSGPACK)
+	}
+
+	read := func() (string, map[string]interface{}) {
+		t.Helper()
+		conn.SetReadDeadline(time.Now().Add(testTimeout))
+		frameType, raw, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("read message: %v", err)
+		}
+		if frameType != websocket.BinaryMessage {
+			t.Fatalf("frame type = %d, want binary", frameType)
+		}
+		var msg struct {
+			Type string                 `json:"type"`
+			Data map[string]interface{} `json:"data"`
+		}
+		if err := codec.Unmarshal(raw, &msg); err != nil {
+			t.Fatalf("decode message: %v", err)
+		}
+		return msg.Type, msg.Data
+	}
+	if msgType, data := read(); msgType != "text" || data["session_id"] == "" {
+		t.Fatalf("first message = %s %v, want the welcome", msgType, data)
+	}
+	return conn, read
+}
+
+func TestMessagePackSession(t *testing.T) {
+	conn, read := dialMessagePack(t, "modalities=")
+	message, err := codec.Marshal(map[string]interface{}{"type": "text_input", "data": map[string]string{"text": "hello there"}})
+	if err != nil {
+		t.Fatalf("encode text_input: %v", err)
+	}
+	if err := conn.WriteMessage(websocket.BinaryMessage, message); err != nil {
+		t.Fatalf("send text_input: %v", err)
+	}
+	for {
+		msgType, data := read()
+		if msgType == "protocol_error" {
+			t.Fatalf("protocol_error: %v", data)
+		}
+		if msgType == "transcript_final" {
+			if data["transcript"] != "hello there" {
+				t.Errorf("transcript_final = %v", data)
+			}
+			return
+		}
+	}
+}
+
+func TestMessagePackBinaryFrame(t *testing.T) {
+	conn, read := dialMessagePack(t, "modalities=video")
+	frame, err := base64.StdEncoding.DecodeString(testFrame(t))
+	if err != nil {
+		t.Fatalf("decode frame: %v", err)
+	}
+	// The frame goes as raw bytes, not base64
+	message, err := codec.Marshal(map[string]interface{}{"type": "video_data", "data": frame})
+	if err != nil {
+		t.Fatalf("encode video_data: %v", err)
+	}
+	if err := conn.WriteMessage(websocket.BinaryMessage, message); err != nil {
+		t.Fatalf("send video_data: %v", err)
+	}
+	for {
+		msgType, data := read()
+		if msgType == "protocol_error" {
+			t.Fatalf("protocol_error: %v", data)
+		}
+		if msgType == "video_frame" {
+			want := "data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(frame)
+			if data["image_b64"] != want {
+				t.Errorf("video_frame image_b64 = %.40v..., want the frame as a JPEG data URL", data["image_b64"])
+			}
+			return
+		}
+	}
+}
+
+func TestInactivityPromptsAndLowPower(t *testing.T) {
+	s := startSession(t, "modalities=video")
+	s.send("config", map[string]interface{}{
+		"inactivity_prompt_after":    "1s",
+		"inactivity_prompt":          "Anything else?",
+		"inactivity_low_power_after": "2s",
+	})
+
+	var prompt struct {
+		Text  string `json:"text"`
+		Speak bool   `json:"speak"`
+	}
+	s.expect("prompt_user", &prompt)
+	if prompt.Text != "Anything else?" || !prompt.Speak {
+		t.Errorf("prompt_user = %+v, want the configured prompt, spoken", prompt)
+	}
+
+	type powerMode struct {
+		Mode           string `json:"mode"`
+		Reason         string `json:"reason"`
+		VideoFrequency string `json:"video_frequency"`
+	}
+	var lowPower powerMode
+	s.expect("power_mode", &lowPower)
+	if lowPower.Mode != "low_power" || lowPower.Reason != "inactivity" || lowPower.VideoFrequency != "5m0s" {
+		t.Errorf("power_mode = %+v, want low power at 5m0s after inactivity", lowPower)
+	}
+
+	// Speaking up returns to normal
+	s.send("text_input", map[string]string{"text": "yes, one more thing"})
+	var normal powerMode
+	s.expect("power_mode", &normal)
+	if normal.Mode != "normal" || normal.Reason != "activity" || normal.VideoFrequency != "30s" {
+		t.Errorf("power_mode = %+v, want normal again after activity", normal)
+	}
+}
+
+func TestUnknownMessageIsRejected(t *testing.T) {
+	s := startSession(t, "modalities=")
+
+	s.send("teleport", nil)
+
+	var protocolErr struct {
+		Code        string `json:"code"`
+		MessageType string `json:"message_type"`
+	}
+	s.expect("protocol_error", &protocolErr)
+	if protocolErr.MessageType != "teleport" {
+		t.Errorf("protocol_error = %+v, want one for the teleport message", protocolErr)
+	}
+}
+
+func TestUnsupportedSampleRateIsRejected(t *testing.T) {
+	s := startSession(t, "modalities=audio")
+
+	s.send("config", map[string]interface{}{"audio_encoding": "linear16", "audio_sample_rate": 49})
+
+	var protocolErr struct {
+		Field string `json:"field"`
+	}
+	s.expect("protocol_error", &protocolErr)
+	if protocolErr.Field != "data.audio_sample_rate" {
+		t.Errorf("protocol_error field = %q, want data.audio_sample_rate", protocolErr.Field)
+	}
+}
+
+func TestServerCamerasAreOptIn(t *testing.T) {
+	resp, err := apiRequest(http.MethodGet, "/robot/cameras", nil)
+	if err != nil {
+		t.Fatalf("list cameras: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("GET /robot/cameras = %d, want 404", resp.StatusCode)
+	}
+
+	s := startSession(t, "modalities=video")
+	s.send("config", map[string]interface{}{"camera_device": "/dev/video0"})
+
+	var protocolErr struct {
+		Field string `json:"field"`
+	}
+	s.expect("protocol_error", &protocolErr)
+	if protocolErr.Field != "data.camera_device" {
+		t.Errorf("protocol_error field = %q, want data.camera_device", protocolErr.Field)
+	}
+}
+
+// testFrame returns a base64 JPEG camera frame.
+func testFrame(t *testing.T) string {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 320, 240))
+	for y := 0; y < 240; y++ {
+		for x := 0; x < 320; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: uint8(x ^ y), A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatalf("encode frame: %v", err)
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+func TestEnvironmentChangeIsReported(t *testing.T) {
+	testLLM.Script(mocks.LLM_TASK_VISION,
+		models.EnvironmentContext{Overview: "An empty hallway", KeyElements: []string{"door"}, Activities: []string{}},
+		models.EnvironmentContext{Overview: "A person at the door", KeyElements: []string{"door", "a person"}, Activities: []string{}},
+	)
+	s := startSession(t, "modalities=video")
+	s.send("config", map[string]interface{}{"environment_changes": true})
+
+	s.send("video_data", testFrame(t))
+	s.expect("video_analysis", nil)
+	s.send("video_data", testFrame(t))
+
+	var change models.EnvironmentChange
+	s.expect("environment_change", &change)
+	want := models.SceneChange{Field: "key_elements", Change: models.CHANGE_APPEARED, Value: "a person"}
+	if len(change.Changes) != 1 || change.Changes[0] != want {
+		t.Errorf("environment_change changes = %+v, want only %+v", change.Changes, want)
+	}
+}
+
+func TestCaptureWaitsForItsOwnFrame(t *testing.T) {
+	testLLM.Script(mocks.LLM_TASK_VISION,
+		models.EnvironmentContext{Overview: "A frame the robot pushed", KeyElements: []string{}, Activities: []string{}},
+		models.EnvironmentContext{Overview: "The requested frame", KeyElements: []string{}, Activities: []string{}},
+	)
+	s := startSession(t, "modalities=video")
+
+	type captureResult struct {
+		RequestID string                    `json:"request_id"`
+		Analysis  models.EnvironmentContext `json:"analysis"`
+		Error     string                    `json:"error"`
+	}
+	results := make(chan captureResult, 1)
+	go func() {
+		var result captureResult
+		resp, err := apiRequest(http.MethodPost, "/robot/sessions/"+s.id+"/capture?wait=10s", nil)
+		if err != nil {
+			result.Error = err.Error()
+		} else {
+			json.NewDecoder(resp.Body).Decode(&result)
+			resp.Body.Close()
+		}
+		results <- result
+	}()
+
+	var request struct {
+		RequestID string `json:"request_id"`
+	}
+	s.expect("capture_request", &request)
+	// Another frame is analyzed before the robot answers the request
+	s.send("video_data", testFrame(t))
+	s.expect("video_analysis", nil)
+	message := map[string]interface{}{"type": "video_data", "request_id": request.RequestID, "data": testFrame(t)}
+	if err := s.conn.WriteJSON(message); err != nil {
+		t.Fatalf("send video_data: %v", err)
+	}
+
+	result := <-results
+	if result.Error != "" || result.RequestID != request.RequestID {
+		t.Fatalf("capture = %+v, want request %s answered", result, request.RequestID)
+	}
+	if result.Analysis.Overview != "The requested frame" || result.Analysis.RequestID != request.RequestID {
+		t.Errorf("capture analysis = %+v, want the requested frame's", result.Analysis)
+	}
+}
+
+func TestLiveSessionDataIsNotDeleted(t *testing.T) {
+	s := startSession(t, "modalities=")
+
+	resp, err := apiRequest(http.MethodDelete, "/robot/sessions/"+s.id+"/data", nil)
+	if err != nil {
+		t.Fatalf("delete session data: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("DELETE /robot/sessions/{id}/data of a live session = %d, want 409", resp.StatusCode)
+	}
+
+	// Tenant-wide deletion must be confirmed
+	resp, err = apiRequest(http.MethodDelete, "/tenant/data", nil)
+	if err != nil {
+		t.Fatalf("delete tenant data: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("DELETE /tenant/data without confirm = %d, want 400", resp.StatusCode)
+	}
+}
+
+func TestTranscriptSearchValidatesQuery(t *testing.T) {
+	for query, want := range map[string]int{
+		"":                     http.StatusBadRequest,
+		"q=kitchen&mode=fuzzy": http.StatusBadRequest,
+		"q=kitchen&from=today": http.StatusBadRequest,
+		"q=kitchen&limit=500":  http.StatusBadRequest,
+		"q=kitchen&from=2026-01-02T00:00:00Z&to=2026-01-01T00:00:00Z": http.StatusBadRequest,
+	} {
+		resp, err := apiRequest(http.MethodGet, "/search?"+query, nil)
+		if err != nil {
+			t.Fatalf("search transcripts: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("GET /search?%s = %d, want %d", query, resp.StatusCode, want)
+		}
+	}
+}
+
+func TestTranscriptSearchFindsUtterance(t *testing.T) {
+	if os.Getenv("PERCEPTUS_TEST_REDIS_ADDR") == "" {
+		t.Skip("the transcript index needs PERCEPTUS_TEST_REDIS_ADDR")
+	}
+	s := startSession(t, "modalities=")
+	s.send("text_input", map[string]string{"text": "where did I leave the blue umbrella"})
+	s.expect("intention_analysis", nil)
+
+	// The intention is archived after it is sent; matches are newest first
+	var results []handlers.TranscriptMatch
+	for deadline := time.Now().Add(testTimeout); (len(results) == 0 || results[0].SessionID != s.id) && time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		resp, err := apiRequest(http.MethodGet, "/search?q=Blue+umbrel", nil)
+		if err != nil {
+			t.Fatalf("search transcripts: %v", err)
+		}
+		var body struct {
+			Results []handlers.TranscriptMatch `json:"results"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || err != nil {
+			t.Fatalf("GET /search = %d, %v, want 200", resp.StatusCode, err)
+		}
+		results = body.Results
+	}
+	if len(results) == 0 || results[0].SessionID != s.id || results[0].Text != "where did I leave the blue umbrella" {
+		t.Errorf("results = %+v, want the utterance of session %s first", results, s.id)
+	}
+}
+
+func TestRequestsNeedAPIKey(t *testing.T) {
+	// Erasure and bulk data access above all
+	for _, route := range []struct{ method, path string }{
+		{http.MethodGet, "/robot/profiles"},
+		{http.MethodDelete, "/tenant/data?confirm=default"},
+		{http.MethodDelete, "/robot/sessions/s1/data"},
+		{http.MethodDelete, "/robot/robots/r1/data"},
+		{http.MethodGet, "/robot/sessions/s1/export"},
+		{http.MethodPost, "/robot/sessions/import"},
+		{http.MethodGet, "/search?q=mug"},
+	} {
+		req, err := http.NewRequest(route.method, testServer.URL+route.path, nil)
+		if err != nil {
+			t.Fatalf("new request: %v", err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s: %v", route.method, route.path, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("%s %s without an API key = %d, want 401", route.method, route.path, resp.StatusCode)
+		}
+	}
+
+	url := "ws" + strings.TrimPrefix(testServer.URL, "http") + "/robot/session?api_key=wrong"
+	if _, resp, err := websocket.DefaultDialer.Dial(url, nil); err == nil || resp == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("session with a wrong API key: err = %v, want a 401 response", err)
+	}
+}
+
+func TestRoutes(t *testing.T) {
+	for _, test := range []struct {
+		method, path string
+		auth         bool
+		want         int
+	}{
+		{http.MethodGet, "/robot/profiles", true, http.StatusOK},
+		{http.MethodGet, "/v1/robot/profiles", true, http.StatusOK},
+		{http.MethodGet, "/v1/robot/profiles", false, http.StatusUnauthorized},
+		{http.MethodDelete, "/robot/profiles", true, http.StatusMethodNotAllowed},
+		{http.MethodGet, "/robot/nowhere", true, http.StatusNotFound},
+		{http.MethodGet, "/schemas", false, http.StatusOK},
+		{http.MethodGet, "/health", false, http.StatusOK},
+		// The dashboard page is public; the admin API is off without ADMIN_API_KEY
+		{http.MethodGet, "/admin", false, http.StatusOK},
+		{http.MethodGet, "/admin/sessions", true, http.StatusNotFound},
+	} {
+		req, err := http.NewRequest(test.method, testServer.URL+test.path, nil)
+		if err != nil {
+			t.Fatalf("new request: %v", err)
+		}
+		if test.auth {
+			req.Header = authHeader()
+		}
+		req.Header.Set("X-Request-ID", "route-test")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s: %v", test.method, test.path, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != test.want {
+			t.Errorf("%s %s = %d, want %d", test.method, test.path, resp.StatusCode, test.want)
+		}
+		if id := resp.Header.Get("X-Request-ID"); id != "route-test" {
+			t.Errorf("%s %s: X-Request-ID = %q, want route-test", test.method, test.path, id)
+		}
+	}
+}
+
+func TestEchoProbeStampsEgressInWriter(t *testing.T) {
+	s := startSession(t, "modalities=")
+
+	s.send("echo_probe", map[string]interface{}{"probe_id": "p1", "client_sent_at": time.Now().UnixMilli()})
+
+	var result handlers.EchoProbeResult
+	s.expect("echo_probe_result", &result)
+	if result.ProbeID != "p1" {
+		t.Errorf("probe_id = %q, want p1", result.ProbeID)
+	}
+	if result.ServerSentAt < result.ServerDispatchedAt || result.ServerDispatchedAt < result.ServerReceivedAt {
+		t.Errorf("timestamps received %d, dispatched %d, sent %d, want them in order",
+			result.ServerReceivedAt, result.ServerDispatchedAt, result.ServerSentAt)
+	}
+	if result.OutboundMs < 0 || result.ServerMs+0.001 < result.QueueMs+result.HandlerMs {
+		t.Errorf("outbound_ms = %v, server_ms = %v, want the outbound wait within server_ms", result.OutboundMs, result.ServerMs)
+	}
+}
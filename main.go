@@ -2,7 +2,8 @@ package main
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
@@ -10,42 +11,63 @@ import (
 	"time"
 
 	"github.com/Perceptus-Labs/perceptus-go-sdk/handlers"
+	"github.com/Perceptus-Labs/perceptus-go-sdk/testmode"
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
 	"github.com/lpernett/godotenv"
 	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
-	"go.uber.org/zap/zapcore"
 )
 
-// Load environment variables from .env file
-// Without this, it tries to use the SSL cert logic
+// Load environment variables from .env file and the config file, and set up
+// logging
 func init() {
-	// Initialize zap logger with colored output
-	config := zap.NewDevelopmentConfig()
-	config.EncoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
-	config.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
-	config.EncoderConfig.EncodeCaller = zapcore.ShortCallerEncoder
+	// Load .env and the config file first so they can configure logging;
+	// report the result once the logger exists. Environment variables win
+	// over .env, which wins over the config file
+	envErr := godotenv.Load()
+	configPath := utils.ConfigFilePath()
+	cfg, unknownSettings, configErr := utils.LoadConfig(configPath)
+	if cfg != nil {
+		utils.SetConfig(cfg)
+	}
 
-	logger, err := config.Build()
+	logger, err := utils.NewLogger(utils.CurrentConfig())
 	if err != nil {
 		panic("Failed to initialize logger: " + err.Error())
 	}
 	zap.ReplaceGlobals(logger)
 
-	zap.L().Info("Loading environment variables")
-	err = godotenv.Load()
-	if err != nil {
+	zap.L().Info("Loaded environment variables", zap.String("log_level", utils.LogLevel.String()))
+	if envErr != nil {
 		zap.L().Warn("Error loading .env file")
 	}
+	if cfg == nil {
+		zap.L().Fatal("Failed to load config file", zap.Error(configErr))
+	}
+	if configPath != "" {
+		zap.L().Info("Loaded config file", zap.String("path", configPath), zap.Int("settings", len(cfg.FileSettings())))
+	}
+	if len(unknownSettings) > 0 {
+		zap.L().Warn("Unknown settings in config file", zap.String("path", configPath), zap.Strings("settings", unknownSettings))
+	}
+	if configErr != nil {
+		zap.L().Fatal("Invalid configuration", zap.Error(configErr))
+	}
+	testmode.Configure(cfg.TestMode, cfg.TestModeFaults)
 }
 
 func main() {
 	// Set up logging
-	zap.L().Info("Server Version: Perceptus Robot SDK V1")
+	zap.L().Info("Server Version: Perceptus Robot SDK V1",
+		zap.String("version", utils.Version),
+		zap.String("instance_id", utils.InstanceID()))
+	utils.RecordModelVersions()
+	cfg := utils.CurrentConfig()
 
 	// Set up Redis connection
 	redisClient := redis.NewClient(&redis.Options{
-		Addr:        os.Getenv("REDIS_HOST"),
-		Password:    os.Getenv("REDIS_PASSWORD"),
+		Addr:        cfg.RedisHost,
+		Password:    cfg.RedisPassword,
 		DB:          0,
 		DialTimeout: 20 * time.Second, // initial connection timeout
 	})
@@ -59,36 +81,82 @@ func main() {
 	}
 	zap.L().Info("Successfully connected to Redis")
 
-	// WebSocket endpoint for robot sessions
-	http.HandleFunc("/robot/session", func(w http.ResponseWriter, r *http.Request) {
-		handlers.HandleRobotSession(w, r, redisClient)
-	})
+	tenants, err := utils.NewTenantStore(redisClient)
+	if err != nil {
+		zap.L().Fatal("Failed to load tenants", zap.Error(err))
+	}
 
-	// Health check endpoint
-	http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
-	})
+	// Stored transcripts, analyses and snapshots are encrypted per tenant
+	if err := utils.ConfigureArtifactEncryption(tenants); err != nil {
+		zap.L().Fatal("Failed to configure artifact encryption", zap.Error(err))
+	}
+
+	// Memory writes and lookups embed text with EMBEDDING_PROVIDER unless the
+	// Pinecone index embeds it
+	if _, err := utils.ConfiguredEmbedder(); err != nil {
+		zap.L().Fatal("Invalid embedding configuration", zap.Error(err))
+	}
+
+	// Periodic jobs, run once per interval across replicas
+	handlers.StartScheduler(redisClient)
+	defer handlers.StopScheduler()
+
+	// Vector writes are batched off the request path; flush them on exit
+	defer handlers.FlushPineconeWrites()
+
+	// Lifecycle webhooks are delivered in the background; wait for them on exit
+	defer handlers.FlushWebhooks()
+
+	handler := newRouter(cfg, redisClient, tenants)
+
+	// TLS is terminated here or by a proxy in front of the server
+	serverTLS, err := newServerTLS(cfg)
+	if err != nil {
+		zap.L().Fatal("Invalid TLS configuration", zap.Error(err))
+	}
 
 	// Set up signal handling
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
 
-	// Create a context with a timeout for the server
-	_, cancelServer := context.WithCancel(context.Background())
-	defer cancelServer()
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	go func() {
+		for range reload {
+			zap.L().Info("Received SIGHUP, reloading provider credentials")
+			if err := tenants.Reload(); err != nil {
+				zap.L().Error("Credential reload failed", zap.Error(err))
+			}
+			if err := serverTLS.reload(); err != nil {
+				zap.L().Error("TLS certificate reload failed", zap.Error(err))
+			}
+		}
+	}()
 
+	port := fmt.Sprintf(":%d", cfg.Port)
+	server := &http.Server{
+		Addr:              port,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
 	serverExit := make(chan struct{})
 
 	// Start HTTP server in a goroutine
 	go func() {
-		port := ":" + os.Getenv("PORT")
-		if port == ":" {
-			port = ":8080"
+		defer close(serverExit)
+		var err error
+		if serverTLS == nil {
+			zap.L().Info("Starting server", zap.String("port", port))
+			err = server.ListenAndServe()
+		} else {
+			serverTLS.serveRedirect()
+			server.TLSConfig = serverTLS.config
+			zap.L().Info("Starting server with TLS", zap.String("port", port))
+			err = server.ListenAndServeTLS("", "")
+		}
+		if !errors.Is(err, http.ErrServerClosed) {
+			zap.L().Error("Server error", zap.Error(err))
 		}
-		zap.L().Info("Starting server", zap.String("port", port))
-		zap.L().Fatal("Server error", zap.Error(http.ListenAndServe(port, nil)))
-		close(serverExit)
 	}()
 
 	// On termination, close all connections and shut down the server
@@ -99,8 +167,15 @@ func main() {
 		zap.L().Info("Server exited unexpectedly...")
 	}
 
-	// Cancel the context to stop the connection reset scheduler
-	cancelServer()
+	// Stop accepting connections and let REST requests finish, then end the
+	// live sessions, whose WebSockets the server no longer tracks. Deferred
+	// calls flush vector writes and webhooks afterwards
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancelShutdown()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		zap.L().Warn("HTTP server did not shut down cleanly", zap.Error(err))
+	}
+	handlers.StopSessions()
 
 	zap.L().Info("Server shut down gracefully")
 }
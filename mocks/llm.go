@@ -0,0 +1,356 @@
+package mocks
+
+import (
+	"encoding/json"
+	"hash/fnv"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
+)
+
+// Tasks an LLM request is recorded under. Requests with a JSON schema
+// response format are recorded under the schema's name (intention,
+// sentiment, preferences, session_summary, ...).
+const (
+	LLM_TASK_INTENTION = "intention"
+	LLM_TASK_VISION    = "vision"
+	LLM_TASK_CHAT      = "chat"
+	LLM_TASK_EMBEDDING = "embedding"
+)
+
+// EMBEDDING_DIMENSIONS is the size of the vectors the LLM embeds text into.
+const EMBEDDING_DIMENSIONS = 64
+
+// Intention is the intention model's JSON answer.
+type Intention struct {
+	HasClearIntention bool                   `json:"has_clear_intention"`
+	IntentionType     string                 `json:"intention_type"`
+	Description       string                 `json:"description"`
+	Confidence        float64                `json:"confidence"`
+	Reasoning         string                 `json:"reasoning"`
+	Slots             map[string]interface{} `json:"slots"`
+}
+
+// LLMRequest is a request the LLM received.
+type LLMRequest struct {
+	Task string
+	Body map[string]interface{}
+}
+
+// Text returns the text of the request's messages, for assertions on what
+// a prompt contained.
+func (r LLMRequest) Text() string {
+	var text []string
+	messages, _ := r.Body["messages"].([]interface{})
+	for _, message := range messages {
+		message, _ := message.(map[string]interface{})
+		switch content := message["content"].(type) {
+		case string:
+			text = append(text, content)
+		case []interface{}:
+			for _, part := range content {
+				part, _ := part.(map[string]interface{})
+				if s, ok := part["text"].(string); ok {
+					text = append(text, s)
+				}
+			}
+		}
+	}
+	if input, ok := r.Body["input"].(string); ok {
+		text = append(text, input)
+	}
+	return strings.Join(text, "\n")
+}
+
+// LLM is an OpenAI-compatible server. Chat completions are answered from
+// scripts per task: intentions and other structured answers are used once
+// each, in order, while the scene description and chat reply stay until
+// replaced. Unscripted structured requests get the zero value of their
+// schema, e.g. no clear intention. Embeddings are bag-of-words vectors, so
+// texts sharing words are similar.
+type LLM struct {
+	server *httptest.Server
+
+	mu       sync.Mutex
+	scripts  map[string][]string
+	scene    models.EnvironmentContext
+	chat     string
+	requests []LLMRequest
+}
+
+// NewLLM starts the server.
+func NewLLM() *LLM {
+	l := &LLM{
+		scripts: make(map[string][]string),
+		scene: models.EnvironmentContext{
+			Overview:    "An empty test room",
+			KeyElements: []string{},
+			Activities:  []string{},
+		},
+		chat: "ok",
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /chat/completions", l.handleChatCompletion)
+	mux.HandleFunc("POST /embeddings", l.handleEmbeddings)
+	l.server = httptest.NewServer(mux)
+	return l
+}
+
+// URL is the API root to set as OPENAI_BASE_URL.
+func (l *LLM) URL() string {
+	return l.server.URL
+}
+
+// Close stops the server.
+func (l *LLM) Close() {
+	l.server.Close()
+}
+
+// Script queues answers for requests of a JSON schema task, each marshalled
+// to JSON and used once.
+func (l *LLM) Script(task string, answers ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, answer := range answers {
+		content, _ := json.Marshal(answer)
+		l.scripts[task] = append(l.scripts[task], string(content))
+	}
+}
+
+// ScriptIntention queues answers for intention analysis.
+func (l *LLM) ScriptIntention(intentions ...Intention) {
+	for _, intention := range intentions {
+		l.Script(LLM_TASK_INTENTION, intention)
+	}
+}
+
+// SetScene sets the answer to scene analysis requests.
+func (l *LLM) SetScene(scene models.EnvironmentContext) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.scene = scene
+}
+
+// SetChat sets the answer to free-form requests.
+func (l *LLM) SetChat(content string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.chat = content
+}
+
+// Requests returns the requests of a task received so far, all of them for
+// an empty task.
+func (l *LLM) Requests(task string) []LLMRequest {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var requests []LLMRequest
+	for _, request := range l.requests {
+		if task == "" || request.Task == task {
+			requests = append(requests, request)
+		}
+	}
+	return requests
+}
+
+// WaitRequests waits until n requests of a task were received and returns
+// the requests received by then.
+func (l *LLM) WaitRequests(task string, n int, timeout time.Duration) []LLMRequest {
+	waitFor(timeout, func() bool { return len(l.Requests(task)) >= n })
+	return l.Requests(task)
+}
+
+func (l *LLM) record(r *http.Request) (LLMRequest, bool) {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		return LLMRequest{}, false
+	}
+	var body map[string]interface{}
+	if err := json.Unmarshal(data, &body); err != nil {
+		return LLMRequest{}, false
+	}
+	request := LLMRequest{Task: chatTask(body), Body: body}
+	if r.URL.Path == "/embeddings" {
+		request.Task = LLM_TASK_EMBEDDING
+	}
+	l.mu.Lock()
+	l.requests = append(l.requests, request)
+	l.mu.Unlock()
+	return request, true
+}
+
+func (l *LLM) handleChatCompletion(w http.ResponseWriter, r *http.Request) {
+	request, ok := l.record(r)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{"error": map[string]string{"message": "invalid JSON body"}})
+		return
+	}
+
+	l.mu.Lock()
+	var content string
+	switch {
+	case len(l.scripts[request.Task]) > 0:
+		content, l.scripts[request.Task] = l.scripts[request.Task][0], l.scripts[request.Task][1:]
+	case request.Task == LLM_TASK_VISION:
+		scene, _ := json.Marshal(l.scene)
+		content = string(scene)
+	case request.Task == LLM_TASK_CHAT:
+		content = l.chat
+	default:
+		zero, _ := json.Marshal(schemaZero(responseSchema(request.Body)))
+		content = string(zero)
+	}
+	l.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"id":      "chatcmpl-mock",
+		"object":  "chat.completion",
+		"created": time.Now().Unix(),
+		"model":   request.Body["model"],
+		"choices": []map[string]interface{}{{
+			"index":         0,
+			"message":       map[string]interface{}{"role": "assistant", "content": content},
+			"finish_reason": "stop",
+		}},
+		"usage": map[string]int{"prompt_tokens": 0, "completion_tokens": 0, "total_tokens": 0},
+	})
+}
+
+func (l *LLM) handleEmbeddings(w http.ResponseWriter, r *http.Request) {
+	request, ok := l.record(r)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{"error": map[string]string{"message": "invalid JSON body"}})
+		return
+	}
+	var inputs []string
+	switch input := request.Body["input"].(type) {
+	case string:
+		inputs = []string{input}
+	case []interface{}:
+		for _, item := range input {
+			text, _ := item.(string)
+			inputs = append(inputs, text)
+		}
+	}
+	dimensions := EMBEDDING_DIMENSIONS
+	if requested, ok := request.Body["dimensions"].(float64); ok && requested > 0 {
+		dimensions = int(requested)
+	}
+
+	data := make([]map[string]interface{}, len(inputs))
+	for i, text := range inputs {
+		data[i] = map[string]interface{}{"object": "embedding", "index": i, "embedding": Embed(text, dimensions)}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"object": "list", "data": data, "model": request.Body["model"]})
+}
+
+// Embed returns the bag-of-words vector of text: every word adds to the
+// dimension its hash selects, and the vector is normalized.
+func Embed(text string, dimensions int) []float64 {
+	vector := make([]float64, dimensions)
+	for _, word := range words(text) {
+		h := fnv.New32a()
+		h.Write([]byte(word))
+		vector[int(h.Sum32())%dimensions]++
+	}
+	var norm float64
+	for _, v := range vector {
+		norm += v * v
+	}
+	if norm > 0 {
+		norm = math.Sqrt(norm)
+		for i := range vector {
+			vector[i] /= norm
+		}
+	}
+	return vector
+}
+
+// words splits text into lowercase words.
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9')
+	})
+}
+
+// chatTask names a chat completion by its JSON schema, or as a vision
+// request when it carries an image.
+func chatTask(body map[string]interface{}) string {
+	format, _ := body["response_format"].(map[string]interface{})
+	jsonSchema, _ := format["json_schema"].(map[string]interface{})
+	if name, _ := jsonSchema["name"].(string); name != "" {
+		return name
+	}
+	messages, _ := body["messages"].([]interface{})
+	for _, message := range messages {
+		message, _ := message.(map[string]interface{})
+		parts, _ := message["content"].([]interface{})
+		for _, part := range parts {
+			if part, _ := part.(map[string]interface{}); part["type"] == "image_url" {
+				return LLM_TASK_VISION
+			}
+		}
+	}
+	return LLM_TASK_CHAT
+}
+
+func responseSchema(body map[string]interface{}) map[string]interface{} {
+	format, _ := body["response_format"].(map[string]interface{})
+	jsonSchema, _ := format["json_schema"].(map[string]interface{})
+	schema, _ := jsonSchema["schema"].(map[string]interface{})
+	return schema
+}
+
+// schemaZero returns the zero value of a JSON schema: empty strings and
+// arrays, zero numbers, false, null where allowed, and "none" or else the
+// first value of an enum.
+func schemaZero(schema map[string]interface{}) interface{} {
+	if enum, ok := schema["enum"].([]interface{}); ok && len(enum) > 0 {
+		if slices.Contains(enum, interface{}("none")) {
+			return "none"
+		}
+		return enum[0]
+	}
+	var kinds []string
+	switch kind := schema["type"].(type) {
+	case string:
+		kinds = []string{kind}
+	case []interface{}:
+		for _, k := range kind {
+			s, _ := k.(string)
+			kinds = append(kinds, s)
+		}
+	}
+	if slices.Contains(kinds, "null") {
+		return nil
+	}
+	kind := ""
+	if len(kinds) > 0 {
+		kind = kinds[0]
+	}
+	switch kind {
+	case "object":
+		object := map[string]interface{}{}
+		properties, _ := schema["properties"].(map[string]interface{})
+		for name, property := range properties {
+			property, _ := property.(map[string]interface{})
+			object[name] = schemaZero(property)
+		}
+		return object
+	case "array":
+		return []interface{}{}
+	case "string":
+		return ""
+	case "number", "integer":
+		return 0
+	case "boolean":
+		return false
+	}
+	return nil
+}
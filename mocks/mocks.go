@@ -0,0 +1,42 @@
+// Package mocks provides in-memory stand-ins for the providers a session
+// talks to, so the whole pipeline runs in tests without API keys:
+//
+//   - STT answers audio with canned transcripts; it registers as the "mock"
+//     stt_provider
+//   - LLM serves the OpenAI API with scripted intentions and scene
+//     descriptions; point OPENAI_BASE_URL at it
+//   - VectorStore serves the Pinecone records API; point PINECONE_HOST at it
+//   - Orchestrator records the intentions it is sent; point ORCHESTRATOR_URL
+//     at it
+//
+// Every mock records what it received. Sessions call providers from their
+// own goroutines, so the Wait methods poll until the expected calls arrived
+// or the timeout passed.
+package mocks
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// waitFor polls done until it reports true or timeout passes, and returns
+// its last answer.
+func waitFor(timeout time.Duration, done func() bool) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		if done() {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
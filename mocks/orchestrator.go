@@ -0,0 +1,100 @@
+package mocks
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"time"
+)
+
+// OrchestratorRequest is a call the orchestrator received.
+type OrchestratorRequest struct {
+	Path   string
+	Header http.Header
+	Body   map[string]interface{}
+}
+
+// Orchestrator accepts every call with 200 and records it, unless a
+// different status is set with Respond.
+type Orchestrator struct {
+	server *httptest.Server
+
+	mu       sync.Mutex
+	status   int
+	requests []OrchestratorRequest
+}
+
+// NewOrchestrator starts the server.
+func NewOrchestrator() *Orchestrator {
+	o := &Orchestrator{status: http.StatusOK}
+	o.server = httptest.NewServer(http.HandlerFunc(o.handle))
+	return o
+}
+
+// URL is the base URL to set as ORCHESTRATOR_URL.
+func (o *Orchestrator) URL() string {
+	return o.server.URL
+}
+
+// Close stops the server.
+func (o *Orchestrator) Close() {
+	o.server.Close()
+}
+
+// Respond sets the status of subsequent calls, e.g. 503 to test retries.
+func (o *Orchestrator) Respond(status int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.status = status
+}
+
+// Requests returns the calls received so far for a session, all of them
+// for an empty sessionID.
+func (o *Orchestrator) Requests(sessionID string) []OrchestratorRequest {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if sessionID == "" {
+		return slices.Clone(o.requests)
+	}
+	var requests []OrchestratorRequest
+	for _, request := range o.requests {
+		if request.Body["session_id"] == sessionID {
+			requests = append(requests, request)
+		}
+	}
+	return requests
+}
+
+// WaitRequests waits until n calls were received for a session and returns
+// them.
+func (o *Orchestrator) WaitRequests(sessionID string, n int, timeout time.Duration) []OrchestratorRequest {
+	waitFor(timeout, func() bool { return len(o.Requests(sessionID)) >= n })
+	return o.Requests(sessionID)
+}
+
+func (o *Orchestrator) handle(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	var body map[string]interface{}
+	if err := json.Unmarshal(data, &body); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON body"})
+		return
+	}
+
+	o.mu.Lock()
+	o.requests = append(o.requests, OrchestratorRequest{Path: r.URL.Path, Header: r.Header.Clone(), Body: body})
+	status := o.status
+	o.mu.Unlock()
+
+	if status >= http.StatusBadRequest {
+		writeJSON(w, status, map[string]string{"error": http.StatusText(status)})
+		return
+	}
+	writeJSON(w, status, map[string]string{"status": "accepted"})
+}
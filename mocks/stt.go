@@ -0,0 +1,162 @@
+package mocks
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+)
+
+// STT_PROVIDER is the stt_provider (or STT_PROVIDER) selecting the mock.
+const STT_PROVIDER = "mock"
+
+var errSTTClosed = errors.New("mock speech-to-text stream not connected")
+
+// STT is a speech-to-text backend answering audio with canned transcripts:
+// each audio chunk a stream receives takes the next queued transcript, which
+// is delivered as a final segment followed by END_OF_SPEECH. Audio arriving
+// with no transcript queued is only counted.
+type STT struct {
+	mu          sync.Mutex
+	transcripts []string
+	audioBytes  int
+	streams     int
+}
+
+// NewSTT returns a backend with transcripts queued.
+func NewSTT(transcripts ...string) *STT {
+	return &STT{transcripts: transcripts}
+}
+
+// Register makes the backend available as STT_PROVIDER, replacing a
+// previously registered mock.
+func (s *STT) Register() error {
+	return utils.RegisterSTTProvider(STT_PROVIDER, s.newStream)
+}
+
+// Say queues transcripts for the next audio chunks.
+func (s *STT) Say(transcripts ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.transcripts = append(s.transcripts, transcripts...)
+}
+
+// AudioBytes returns how much audio all streams received.
+func (s *STT) AudioBytes() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.audioBytes
+}
+
+// Streams returns how many streams were opened.
+func (s *STT) Streams() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.streams
+}
+
+// next records a chunk and returns the transcript it is answered with.
+func (s *STT) next(chunk int) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.audioBytes += chunk
+	if len(s.transcripts) == 0 {
+		return "", false
+	}
+	transcript := s.transcripts[0]
+	s.transcripts = s.transcripts[1:]
+	return transcript, true
+}
+
+func (s *STT) newStream(tenant *models.Tenant, config utils.STTStreamConfig) (utils.SpeechToText, error) {
+	s.mu.Lock()
+	s.streams++
+	s.mu.Unlock()
+	return &sttStream{
+		stt:          s,
+		out:          config.TranscriptionCh,
+		utterances:   make(chan string, 64),
+		disconnected: make(chan struct{}),
+	}, nil
+}
+
+type sttStream struct {
+	stt        *STT
+	out        chan string
+	utterances chan string
+
+	mu           sync.Mutex
+	connected    bool
+	disconnected chan struct{}
+}
+
+func (s *sttStream) Provider() string {
+	return STT_PROVIDER
+}
+
+func (s *sttStream) Connect() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.connected = true
+	go s.deliver()
+	return true
+}
+
+func (s *sttStream) Disconnected() <-chan struct{} {
+	return s.disconnected
+}
+
+func (s *sttStream) IsConnected() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.connected
+}
+
+func (s *sttStream) Send(data []byte) error {
+	if !s.IsConnected() {
+		return errSTTClosed
+	}
+	if transcript, ok := s.stt.next(len(data)); ok {
+		s.utterances <- transcript
+	}
+	return nil
+}
+
+func (s *sttStream) KeepAlive() error {
+	if !s.IsConnected() {
+		return errSTTClosed
+	}
+	return nil
+}
+
+func (s *sttStream) Finalize() error {
+	return s.KeepAlive()
+}
+
+func (s *sttStream) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.connected {
+		s.connected = false
+		close(s.disconnected)
+	}
+}
+
+// deliver sends the utterances in order, the way a backend's reader does.
+func (s *sttStream) deliver() {
+	for {
+		select {
+		case <-s.disconnected:
+			return
+		case transcript := <-s.utterances:
+			for _, segment := range []string{transcript, utils.END_OF_SPEECH} {
+				select {
+				case s.out <- segment:
+				case <-s.disconnected:
+					return
+				}
+			}
+		}
+	}
+}
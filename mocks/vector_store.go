@@ -0,0 +1,290 @@
+package mocks
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DEFAULT_NAMESPACE is the namespace of records upserted without one.
+const DEFAULT_NAMESPACE = "__default__"
+
+// Record is a stored record: its _id, text fields and metadata fields.
+type Record map[string]interface{}
+
+// VectorSearch is a search the vector store received.
+type VectorSearch struct {
+	Namespace string
+	Text      string
+	TopK      int
+	Filter    map[string]interface{}
+}
+
+// VectorStore serves the records API of a Pinecone index with integrated
+// embeddings: upserts and text searches. Searches rank records by the words
+// their chunk_text shares with the query and support the metadata filter
+// operators. Vector queries over gRPC (EMBEDDING_PROVIDER set) are not
+// served.
+type VectorStore struct {
+	server *httptest.Server
+
+	mu         sync.Mutex
+	namespaces map[string][]Record
+	searches   []VectorSearch
+}
+
+// NewVectorStore starts the server.
+func NewVectorStore() *VectorStore {
+	v := &VectorStore{namespaces: make(map[string][]Record)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /records/namespaces/{namespace}/upsert", v.handleUpsert)
+	mux.HandleFunc("POST /records/namespaces/{namespace}/search", v.handleSearch)
+	v.server = httptest.NewServer(mux)
+	return v
+}
+
+// URL is the index host to set as PINECONE_HOST.
+func (v *VectorStore) URL() string {
+	return v.server.URL
+}
+
+// Close stops the server.
+func (v *VectorStore) Close() {
+	v.server.Close()
+}
+
+// Records returns the records of a namespace in upsert order.
+func (v *VectorStore) Records(namespace string) []Record {
+	if namespace == "" {
+		namespace = DEFAULT_NAMESPACE
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return slices.Clone(v.namespaces[namespace])
+}
+
+// WaitRecords waits until a namespace holds n records and returns them.
+func (v *VectorStore) WaitRecords(namespace string, n int, timeout time.Duration) []Record {
+	waitFor(timeout, func() bool { return len(v.Records(namespace)) >= n })
+	return v.Records(namespace)
+}
+
+// Searches returns the searches received so far.
+func (v *VectorStore) Searches() []VectorSearch {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return slices.Clone(v.searches)
+}
+
+func (v *VectorStore) handleUpsert(w http.ResponseWriter, r *http.Request) {
+	namespace := r.PathValue("namespace")
+	var records []Record
+	scanner := bufio.NewScanner(r.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid record: " + err.Error()})
+			return
+		}
+		if record["_id"] == nil {
+			record["_id"] = record["id"]
+		}
+		if _, ok := record["_id"].(string); !ok {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "record without _id"})
+			return
+		}
+		records = append(records, record)
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for _, record := range records {
+		stored := v.namespaces[namespace]
+		i := slices.IndexFunc(stored, func(existing Record) bool { return existing["_id"] == record["_id"] })
+		if i >= 0 {
+			stored = slices.Delete(stored, i, i+1)
+		}
+		v.namespaces[namespace] = append(stored, record)
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (v *VectorStore) handleSearch(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Fields []string `json:"fields"`
+		Query  struct {
+			Filter map[string]interface{} `json:"filter"`
+			Inputs struct {
+				Text string `json:"text"`
+			} `json:"inputs"`
+			TopK int `json:"top_k"`
+		} `json:"query"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid search: " + err.Error()})
+		return
+	}
+	namespace := r.PathValue("namespace")
+
+	v.mu.Lock()
+	v.searches = append(v.searches, VectorSearch{
+		Namespace: namespace,
+		Text:      request.Query.Inputs.Text,
+		TopK:      request.Query.TopK,
+		Filter:    request.Query.Filter,
+	})
+	type hit struct {
+		record Record
+		score  float64
+	}
+	var hits []hit
+	for _, record := range v.namespaces[namespace] {
+		matches, err := matchFilter(record, request.Query.Filter)
+		if err != nil {
+			v.mu.Unlock()
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		if matches {
+			text, _ := record["chunk_text"].(string)
+			hits = append(hits, hit{record: record, score: wordSimilarity(request.Query.Inputs.Text, text)})
+		}
+	}
+	v.mu.Unlock()
+
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].score > hits[j].score })
+	if request.Query.TopK > 0 && len(hits) > request.Query.TopK {
+		hits = hits[:request.Query.TopK]
+	}
+	results := make([]map[string]interface{}, len(hits))
+	for i, hit := range hits {
+		fields := map[string]interface{}{}
+		for name, value := range hit.record {
+			if name != "_id" && (len(request.Fields) == 0 || slices.Contains(request.Fields, name)) {
+				fields[name] = value
+			}
+		}
+		results[i] = map[string]interface{}{"_id": hit.record["_id"], "_score": hit.score, "fields": fields}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"result": map[string]interface{}{"hits": results},
+		"usage":  map[string]int{"read_units": 1},
+	})
+}
+
+// wordSimilarity is the cosine similarity of the word sets of a and b.
+func wordSimilarity(a, b string) float64 {
+	setA, setB := map[string]bool{}, map[string]bool{}
+	for _, word := range words(a) {
+		setA[word] = true
+	}
+	for _, word := range words(b) {
+		setB[word] = true
+	}
+	if len(setA) == 0 || len(setB) == 0 {
+		return 0
+	}
+	shared := 0
+	for word := range setA {
+		if setB[word] {
+			shared++
+		}
+	}
+	return float64(shared) / math.Sqrt(float64(len(setA)*len(setB)))
+}
+
+// matchFilter evaluates a Pinecone metadata filter against a record.
+func matchFilter(record Record, filter map[string]interface{}) (bool, error) {
+	for field, condition := range filter {
+		switch field {
+		case "$and", "$or":
+			clauses, ok := condition.([]interface{})
+			if !ok {
+				return false, fmt.Errorf("%s takes a list of filters", field)
+			}
+			any := false
+			for _, clause := range clauses {
+				clause, _ := clause.(map[string]interface{})
+				matches, err := matchFilter(record, clause)
+				if err != nil {
+					return false, err
+				}
+				if field == "$and" && !matches {
+					return false, nil
+				}
+				any = any || matches
+			}
+			if field == "$or" && !any {
+				return false, nil
+			}
+			continue
+		}
+
+		operators, ok := condition.(map[string]interface{})
+		if !ok {
+			operators = map[string]interface{}{"$eq": condition}
+		}
+		value, present := record[field]
+		for operator, operand := range operators {
+			matches, err := matchOperator(operator, value, present, operand)
+			if err != nil {
+				return false, fmt.Errorf("%s: %w", field, err)
+			}
+			if !matches {
+				return false, nil
+			}
+		}
+	}
+	return true, nil
+}
+
+func matchOperator(operator string, value interface{}, present bool, operand interface{}) (bool, error) {
+	switch operator {
+	case "$exists":
+		exists, _ := operand.(bool)
+		return present == exists, nil
+	case "$eq":
+		return present && equalValues(value, operand), nil
+	case "$ne":
+		return !present || !equalValues(value, operand), nil
+	case "$in", "$nin":
+		options, ok := operand.([]interface{})
+		if !ok {
+			return false, fmt.Errorf("%s takes a list", operator)
+		}
+		found := present && slices.ContainsFunc(options, func(option interface{}) bool { return equalValues(value, option) })
+		return found == (operator == "$in"), nil
+	case "$gt", "$gte", "$lt", "$lte":
+		number, ok := value.(float64)
+		bound, boundOK := operand.(float64)
+		if !boundOK {
+			return false, fmt.Errorf("%s takes a number", operator)
+		}
+		if !present || !ok {
+			return false, nil
+		}
+		switch operator {
+		case "$gt":
+			return number > bound, nil
+		case "$gte":
+			return number >= bound, nil
+		case "$lt":
+			return number < bound, nil
+		default:
+			return number <= bound, nil
+		}
+	}
+	return false, fmt.Errorf("unsupported operator %s", operator)
+}
+
+func equalValues(a, b interface{}) bool {
+	return fmt.Sprint(a) == fmt.Sprint(b)
+}
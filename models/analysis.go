@@ -0,0 +1,78 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	ANALYSIS_KIND_INTENTION = "intention"
+	ANALYSIS_KIND_VISION    = "vision"
+)
+
+// AnalysisRecord is an archived online analysis that can be re-run offline.
+type AnalysisRecord struct {
+	ID                 string          `json:"id"`
+	SessionID          string          `json:"session_id"`
+	TenantID           string          `json:"tenant_id,omitempty"`
+	Kind               string          `json:"kind"`
+	Transcript         string          `json:"transcript,omitempty"`
+	EnvironmentContext []string        `json:"environment_context,omitempty"`
+	Preferences        []string        `json:"preferences,omitempty"`
+	Conversation       []string        `json:"conversation,omitempty"`
+	ImageData          string          `json:"image_data,omitempty"`
+	Result             json.RawMessage `json:"result"`
+	Worker             *WorkerInfo     `json:"worker,omitempty"`
+	Timestamp          time.Time       `json:"timestamp"`
+}
+
+// AnalysisDiff describes how a re-analysis differs from the original result.
+type AnalysisDiff struct {
+	RecordID  string            `json:"record_id"`
+	SessionID string            `json:"session_id"`
+	Kind      string            `json:"kind"`
+	Changes   map[string][2]any `json:"changes"`
+}
+
+type ReanalysisReport struct {
+	BatchID   string         `json:"batch_id"`
+	Status    string         `json:"status"`
+	Submitted int            `json:"submitted"`
+	Completed int            `json:"completed"`
+	Failed    int            `json:"failed"`
+	Unchanged int            `json:"unchanged"`
+	Diffs     []AnalysisDiff `json:"diffs"`
+	StartedAt time.Time      `json:"started_at"`
+	EndedAt   time.Time      `json:"ended_at"`
+}
+
+const (
+	FEEDBACK_LABEL_CORRECT   = "correct"
+	FEEDBACK_LABEL_INCORRECT = "incorrect"
+	FEEDBACK_LABEL_EXECUTED  = "executed"
+)
+
+// IntentionFeedback is a label attached to a detected intention by the
+// orchestrator or an operator.
+type IntentionFeedback struct {
+	IntentionID   string    `json:"intention_id"`
+	SessionID     string    `json:"session_id"`
+	TenantID      string    `json:"tenant_id"`
+	Label         string    `json:"label"`
+	IntentionType string    `json:"intention_type,omitempty"`
+	Comment       string    `json:"comment,omitempty"`
+	Source        string    `json:"source,omitempty"`
+	Timestamp     time.Time `json:"timestamp"`
+}
+
+// LabeledIntention is one line of the training data export: the archived
+// analysis input and output together with every feedback label it received.
+type LabeledIntention struct {
+	IntentionID        string              `json:"intention_id"`
+	SessionID          string              `json:"session_id"`
+	Transcript         string              `json:"transcript"`
+	EnvironmentContext []string            `json:"environment_context,omitempty"`
+	Result             json.RawMessage     `json:"result"`
+	Feedback           []IntentionFeedback `json:"feedback"`
+	Timestamp          time.Time           `json:"timestamp"`
+}
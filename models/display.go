@@ -0,0 +1,55 @@
+package models
+
+import "strconv"
+
+const (
+	DISPLAY_LAYOUT_TEXT    = "text"
+	DISPLAY_LAYOUT_IMAGE   = "image"
+	DISPLAY_LAYOUT_OPTIONS = "options"
+	DISPLAY_LAYOUT_CLEAR   = "clear"
+)
+
+// DisplayContent is structured UI content pushed to robots with screens.
+type DisplayContent struct {
+	ID         string          `json:"id"`
+	Layout     string          `json:"layout"`
+	Title      string          `json:"title,omitempty"`
+	Text       string          `json:"text,omitempty"`
+	ImageURL   string          `json:"image_url,omitempty"`
+	Options    []DisplayOption `json:"options,omitempty"`
+	TTLSeconds int             `json:"ttl_seconds,omitempty"`
+}
+
+type DisplayOption struct {
+	ID    string `json:"id"`
+	Label string `json:"label"`
+}
+
+// DisplayAck is sent by the robot once content was shown or interacted with.
+type DisplayAck struct {
+	DisplayID      string `json:"display_id"`
+	Status         string `json:"status"` // shown, dismissed, selected, error
+	SelectedOption string `json:"selected_option,omitempty"`
+	Error          string `json:"error,omitempty"`
+}
+
+func NewTextDisplay(title, text string) DisplayContent {
+	return DisplayContent{Layout: DISPLAY_LAYOUT_TEXT, Title: title, Text: text}
+}
+
+func NewImageDisplay(title, imageURL, caption string) DisplayContent {
+	return DisplayContent{Layout: DISPLAY_LAYOUT_IMAGE, Title: title, ImageURL: imageURL, Text: caption}
+}
+
+// NewOptionsDisplay builds a choice list; option IDs default to their index.
+func NewOptionsDisplay(title string, labels ...string) DisplayContent {
+	options := make([]DisplayOption, len(labels))
+	for i, label := range labels {
+		options[i] = DisplayOption{ID: strconv.Itoa(i), Label: label}
+	}
+	return DisplayContent{Layout: DISPLAY_LAYOUT_OPTIONS, Title: title, Options: options}
+}
+
+func NewClearDisplay() DisplayContent {
+	return DisplayContent{Layout: DISPLAY_LAYOUT_CLEAR}
+}
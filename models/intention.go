@@ -0,0 +1,39 @@
+package models
+
+const (
+	SLOT_TYPE_STRING   = "string"
+	SLOT_TYPE_NUMBER   = "number"
+	SLOT_TYPE_INTEGER  = "integer"
+	SLOT_TYPE_DATETIME = "datetime" // RFC 3339 timestamp
+)
+
+// IntentionType declares an intention_type the model may return and the typed
+// slots it fills for it, e.g. navigation -> target_location.
+type IntentionType struct {
+	Type        string           `json:"type"`
+	Description string           `json:"description"`
+	Slots       []SlotDefinition `json:"slots"`
+}
+
+type SlotDefinition struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"` // string, number, integer, datetime
+	Description string `json:"description,omitempty"`
+}
+
+const (
+	INTENTION_SOURCE_MODEL   = "model"
+	INTENTION_SOURCE_GRAMMAR = "grammar"
+	// Fabricated through POST /debug/intentions
+	INTENTION_SOURCE_SIMULATED = "simulated"
+)
+
+// CommandRule maps utterances to an intention without calling the model.
+// Patterns are regular expressions matched against the whole utterance,
+// lowercased and stripped of punctuation; named groups fill slots.
+type CommandRule struct {
+	IntentionType string                 `yaml:"intention_type" json:"intention_type"`
+	Description   string                 `yaml:"description" json:"description"`
+	Patterns      []string               `yaml:"patterns" json:"patterns"`
+	Slots         map[string]interface{} `yaml:"slots,omitempty" json:"slots,omitempty"`
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// Preference is a durable fact learned from conversation, e.g. "likes their
+// coffee black" or "the charger lives in the hallway closet". Subject is the
+// user ("user:<id>") or robot ("robot:<id>") it belongs to; SessionID and
+// Utterance record where it was learned.
+type Preference struct {
+	ID         string    `json:"id"`
+	Subject    string    `json:"subject"`
+	Fact       string    `json:"fact"`
+	Category   string    `json:"category,omitempty"`
+	Confidence float64   `json:"confidence"`
+	SessionID  string    `json:"session_id"`
+	Utterance  string    `json:"utterance"`
+	LearnedAt  time.Time `json:"learned_at"`
+}
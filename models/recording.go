@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// Recording is an MP4 recording of a session's video: the frames the robot
+// sent, at their real timing. Long sessions are split into several
+// recordings of at most RECORDING_MAX_DURATION.
+type Recording struct {
+	ID        string    `json:"id"`
+	SessionID string    `json:"session_id"`
+	TenantID  string    `json:"tenant_id"`
+	StartedAt time.Time `json:"started_at"`
+	EndedAt   time.Time `json:"ended_at"`
+	Frames    int       `json:"frames"`
+	Width     int       `json:"width"`
+	Height    int       `json:"height"`
+	Bytes     int64     `json:"bytes"`
+	// ExpiresAt is when the recording is purged, zero when it is kept
+	ExpiresAt time.Time `json:"expires_at,omitempty"`
+}
@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// Robot is a robot registered with a tenant. Sessions started with its
+// robot_id attach to it, so what the SDK knows about the robot outlives a
+// connection: the state it last reported, where it was and what it saw
+// there, its calibration and its cumulative usage.
+type Robot struct {
+	ID       string            `json:"id"`
+	TenantID string            `json:"tenant_id"`
+	Name     string            `json:"name,omitempty"`
+	Model    string            `json:"model,omitempty"`
+	Site     string            `json:"site,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// Calibration is robot specific configuration (camera intrinsics,
+	// microphone gain, ...) handed back to the robot at session start
+	Calibration  map[string]interface{} `json:"calibration,omitempty"`
+	RegisteredAt time.Time              `json:"registered_at"`
+	UpdatedAt    time.Time              `json:"updated_at"`
+
+	// Carried over from the robot's last session
+	LastSessionID string    `json:"last_session_id,omitempty"`
+	LastSeenAt    time.Time `json:"last_seen_at,omitempty"`
+	// LastState is the last robot_state, including its locations and
+	// timezone
+	LastState       map[string]interface{} `json:"last_state,omitempty"`
+	LastLocation    string                 `json:"last_location,omitempty"`
+	LastEnvironment *EnvironmentContext    `json:"last_environment,omitempty"`
+
+	// Usage counters summed over every session of the robot
+	Usage map[string]int64 `json:"usage,omitempty"`
+}
+
+// RobotRegistration is the part of a Robot set through the REST API.
+type RobotRegistration struct {
+	ID          string                 `json:"id"`
+	Name        string                 `json:"name,omitempty"`
+	Model       string                 `json:"model,omitempty"`
+	Site        string                 `json:"site,omitempty"`
+	Metadata    map[string]string      `json:"metadata,omitempty"`
+	Calibration map[string]interface{} `json:"calibration,omitempty"`
+}
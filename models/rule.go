@@ -0,0 +1,81 @@
+package models
+
+import "time"
+
+const (
+	RULE_ACTION_ALERT        = "alert"
+	RULE_ACTION_ORCHESTRATOR = "orchestrator"
+)
+
+// TriggerRule turns environment contexts into alerts or orchestrator calls.
+// All conditions must match (AND); when For is set they must keep matching on
+// every context observed for at least that long, e.g. "stove on and no person
+// present for 10m".
+type TriggerRule struct {
+	ID         string          `json:"id"`
+	Name       string          `json:"name"`
+	Conditions []RuleCondition `json:"conditions"`
+	For        string          `json:"for,omitempty"`      // duration, e.g. "10m"
+	Cooldown   string          `json:"cooldown,omitempty"` // duration between firings, default 5m
+	Actions    []string        `json:"actions"`            // alert, orchestrator
+}
+
+// RuleCondition tests one EnvironmentContext field. Field is one of overview,
+// layout, key_elements or activities; Operator is contains, not_contains,
+// equals or not_equals. String matching is case-insensitive and list fields
+// match when any element matches.
+type RuleCondition struct {
+	Field    string `json:"field"`
+	Operator string `json:"operator"`
+	Value    string `json:"value"`
+}
+
+// RuleTrigger is emitted when a rule fires.
+type RuleTrigger struct {
+	// TriggerID identifies this firing; orchestrators deduplicate on it
+	TriggerID          string             `json:"trigger_id"`
+	RuleID             string             `json:"rule_id"`
+	RuleName           string             `json:"rule_name"`
+	MatchingSince      int64              `json:"matching_since"`
+	EnvironmentContext EnvironmentContext `json:"environment_context"`
+}
+
+// Kinds of a SceneChange.
+const (
+	CHANGE_APPEARED    = "appeared"
+	CHANGE_DISAPPEARED = "disappeared"
+)
+
+// ChangeWatch picks notable changes out of the differences between
+// successive environment contexts, e.g. a person appearing. Field is
+// key_elements or activities and Change appeared or disappeared; either
+// left empty watches both. Value matches case-insensitively as a substring,
+// and an empty Value matches anything.
+type ChangeWatch struct {
+	ID       string   `json:"id"`
+	Name     string   `json:"name"`
+	Field    string   `json:"field,omitempty"`
+	Change   string   `json:"change,omitempty"`
+	Value    string   `json:"value,omitempty"`
+	Cooldown string   `json:"cooldown,omitempty"` // duration between firings, default 1m
+	Actions  []string `json:"actions"`            // alert, orchestrator
+}
+
+// SceneChange is a key element or activity that appeared in or disappeared
+// from the scene.
+type SceneChange struct {
+	Field  string `json:"field"`
+	Change string `json:"change"`
+	Value  string `json:"value"`
+}
+
+// EnvironmentChange is emitted when an environment context differs from the
+// scene seen before. Watches lists the IDs of the change watches it matched.
+type EnvironmentChange struct {
+	ChangeID          string        `json:"change_id"`
+	ContextID         string        `json:"context_id"`
+	PreviousContextID string        `json:"previous_context_id,omitempty"`
+	Changes           []SceneChange `json:"changes"`
+	Watches           []string      `json:"watches,omitempty"`
+	Timestamp         time.Time     `json:"timestamp"`
+}
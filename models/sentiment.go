@@ -0,0 +1,51 @@
+package models
+
+const (
+	SENTIMENT_POSITIVE = "positive"
+	SENTIMENT_NEUTRAL  = "neutral"
+	SENTIMENT_NEGATIVE = "negative"
+)
+
+// Emotions a transcript is classified as.
+const (
+	EMOTION_CALM       = "calm"
+	EMOTION_HAPPY      = "happy"
+	EMOTION_CONFUSED   = "confused"
+	EMOTION_FRUSTRATED = "frustrated"
+	EMOTION_ANGRY      = "angry"
+	EMOTION_SAD        = "sad"
+	EMOTION_AFRAID     = "afraid"
+	EMOTION_PANICKED   = "panicked"
+)
+
+var Emotions = []string{EMOTION_CALM, EMOTION_HAPPY, EMOTION_CONFUSED, EMOTION_FRUSTRATED, EMOTION_ANGRY, EMOTION_SAD, EMOTION_AFRAID, EMOTION_PANICKED}
+
+// Sentiment is how a final transcript sounds: its polarity, the dominant
+// emotion, how urgent it is (0-1) and whether the speaker seems to be in
+// distress or danger.
+type Sentiment struct {
+	Sentiment string  `json:"sentiment"`
+	Emotion   string  `json:"emotion"`
+	Urgency   float64 `json:"urgency"`
+	Distress  bool    `json:"distress"`
+}
+
+// EscalationPolicy escalates intentions by how they were said rather than
+// how confident the intention is, e.g. "help me" said in panic. All set
+// conditions must hold: the emotion is one of Emotions, the urgency is at
+// least MinUrgency and, with Distress, the speaker is in distress.
+type EscalationPolicy struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`
+	Emotions   []string `json:"emotions,omitempty"`
+	MinUrgency float64  `json:"min_urgency,omitempty"`
+	Distress   bool     `json:"distress,omitempty"`
+	Cooldown   string   `json:"cooldown,omitempty"` // duration between escalations, default 1m
+	Actions    []string `json:"actions"`            // alert, orchestrator
+}
+
+// Escalation names the policy an intention was escalated by.
+type Escalation struct {
+	PolicyID   string `json:"policy_id"`
+	PolicyName string `json:"policy_name"`
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 )
 
@@ -9,12 +10,119 @@ const (
 )
 
 type IntentionResult struct {
+	ID                 string
 	HasClearIntention  bool
 	IntentionType      string
 	Description        string
 	Confidence         float64
 	EnvironmentContext string
-	Timestamp          time.Time
+	// Remembered user preferences given to the model
+	Preferences []string
+	// Earlier conversation given to the model, kept for the archive only
+	Conversation []string `json:"-"`
+	Slots        map[string]interface{}
+	ToolCalls    []IntentionToolCall
+	Source       string // "model", "grammar" or "simulated"
+	// How the transcript was said, when sentiment analysis is enabled, and
+	// the policy that escalated the intention because of it
+	Sentiment  *Sentiment  `json:",omitempty"`
+	Escalation *Escalation `json:",omitempty"`
+	// Set when the user is asked to confirm the intention before it is
+	// forwarded to the orchestrator
+	AwaitingConfirmation bool
+	Timestamp            time.Time
+}
+
+// IntentionToolCall records a tool the intention model called while resolving
+// a transcript into concrete task parameters.
+type IntentionToolCall struct {
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+	Result    interface{}     `json:"result,omitempty"`
+	Error     string          `json:"error,omitempty"`
+}
+
+// WorkerInfo identifies the server instance and pipeline that produced an
+// event, so fleet-wide behavior changes can be correlated with releases.
+type WorkerInfo struct {
+	ServerVersion string            `json:"server_version"`
+	InstanceID    string            `json:"instance_id"`
+	Components    map[string]string `json:"components"`
+}
+
+// SessionMeta is the persisted description of a session, kept after it ends.
+type SessionMeta struct {
+	ID        string                 `json:"id"`
+	TenantID  string                 `json:"tenant_id"`
+	Worker    *WorkerInfo            `json:"worker,omitempty"`
+	StartTime time.Time              `json:"start_time"`
+	EndTime   time.Time              `json:"end_time,omitempty"`
+	Config    map[string]interface{} `json:"config"`
+	Metadata  map[string]string      `json:"metadata,omitempty"`
+}
+
+// SessionProfile is a named session setup a client selects with ?profile=
+// at connect instead of configuring every session itself.
+type SessionProfile struct {
+	Name        string `yaml:"name" json:"name"`
+	Description string `yaml:"description,omitempty" json:"description,omitempty"`
+	// Modalities enabled unless the client declares its own; all when empty
+	Modalities []string `yaml:"modalities,omitempty" json:"modalities,omitempty"`
+	// Memory turns Pinecone off when false: no contexts or intentions are
+	// stored or recalled
+	Memory *bool `yaml:"memory,omitempty" json:"memory,omitempty"`
+	// Config holds initial settings, with the keys of a config message
+	Config map[string]interface{} `yaml:"config,omitempty" json:"config,omitempty"`
+}
+
+// SessionSummary is written when a session ends: what the user asked for,
+// the actions triggered, what the robot saw and what is still open.
+type SessionSummary struct {
+	SessionID             string    `json:"session_id"`
+	TenantID              string    `json:"tenant_id"`
+	RobotID               string    `json:"robot_id,omitempty"`
+	UserID                string    `json:"user_id,omitempty"`
+	StartTime             time.Time `json:"start_time"`
+	EndTime               time.Time `json:"end_time"`
+	Summary               string    `json:"summary"`
+	UserRequests          []string  `json:"user_requests"`
+	Actions               []string  `json:"actions"`
+	EnvironmentHighlights []string  `json:"environment_highlights"`
+	FollowUps             []string  `json:"follow_ups"`
+	GeneratedAt           time.Time `json:"generated_at"`
+}
+
+// Scopes of a data deletion request.
+const (
+	DELETION_SCOPE_SESSION = "session"
+	DELETION_SCOPE_ROBOT   = "robot"
+	DELETION_SCOPE_TENANT  = "tenant"
+)
+
+// DeletionReport lists what a data deletion request removed. Deleting again
+// is safe, so a request that is not Complete can be retried.
+type DeletionReport struct {
+	Scope    string `json:"scope"`
+	TargetID string `json:"target_id"`
+	TenantID string `json:"tenant_id"`
+	// Sessions whose data was deleted; live sessions are left alone
+	Sessions            []string `json:"sessions"`
+	SkippedLive         []string `json:"skipped_live,omitempty"`
+	Transcripts         int      `json:"transcripts"`
+	EnvironmentContexts int      `json:"environment_contexts"`
+	Frames              int      `json:"frames"`
+	Recordings          int      `json:"recordings"`
+	Summaries           int      `json:"summaries"`
+	Feedback            int      `json:"feedback"`
+	Preferences         int      `json:"preferences"`
+	SiteObservations    int      `json:"site_observations"`
+	Robots              int      `json:"robots"`
+	// MemoryVectors counts the records deleted from the memory store by ID
+	MemoryVectors int       `json:"memory_vectors"`
+	RedisKeys     int       `json:"redis_keys"`
+	Errors        []string  `json:"errors,omitempty"`
+	Complete      bool      `json:"complete"`
+	DeletedAt     time.Time `json:"deleted_at"`
 }
 
 type EnvironmentContext struct {
@@ -26,4 +134,68 @@ type EnvironmentContext struct {
 	Layout         string            `json:"layout" optional:"true"`
 	Activities     []string          `json:"activities" optional:"true"`
 	AdditionalInfo map[string]string `json:"additional_info" optional:"true"`
+	Regions        []Region          `json:"regions,omitempty" optional:"true"`
+	Depth          *DepthStats       `json:"depth,omitempty" optional:"true"`
+	// Location is the robot's reported location when the frame was taken
+	Location string `json:"location,omitempty" optional:"true"`
+	// ImageURI is where the frame is stored when FRAME_STORE is set
+	ImageURI string `json:"image_uri,omitempty" optional:"true"`
+	// RequestID is the capture_request the frame answered
+	RequestID string `json:"request_id,omitempty" optional:"true"`
+}
+
+// Verdicts of a SceneAnswer to a yes/no question.
+const (
+	SCENE_VERDICT_YES     = "yes"
+	SCENE_VERDICT_NO      = "no"
+	SCENE_VERDICT_UNKNOWN = "unknown"
+)
+
+// SceneAnswer is the vision model's answer to a question about the latest
+// frames. Verdict is set for yes/no questions; Evidence is what in the
+// frames the answer rests on.
+type SceneAnswer struct {
+	Answer     string  `json:"answer"`
+	Verdict    string  `json:"verdict,omitempty"`
+	Confidence float64 `json:"confidence"`
+	Evidence   string  `json:"evidence,omitempty"`
+}
+
+// DepthStats summarizes the depth map paired with a frame. Distances are in
+// meters and measured in the forward region, where the robot would drive;
+// FreeSpace is the share of that region beyond the clear distance.
+type DepthStats struct {
+	NearestObstacle float64 `json:"nearest_obstacle_m"`
+	MedianDistance  float64 `json:"median_distance_m"`
+	FreeSpace       float64 `json:"free_space"`
+	ValidRatio      float64 `json:"valid_ratio"`
+	Width           int     `json:"width"`
+	Height          int     `json:"height"`
+}
+
+// Region locates a labeled element in a frame. Coordinates are normalized to
+// the frame size (0-1) with the origin at the top-left corner.
+type Region struct {
+	Label      string  `json:"label"`
+	X          float64 `json:"x"`
+	Y          float64 `json:"y"`
+	Width      float64 `json:"width"`
+	Height     float64 `json:"height"`
+	Confidence float64 `json:"confidence,omitempty"`
+}
+
+// SessionSnapshot is the periodically persisted state of a live session, used
+// to restore its context when the client reconnects after a server restart.
+type SessionSnapshot struct {
+	SessionID         string                 `json:"session_id"`
+	TenantID          string                 `json:"tenant_id"`
+	StartTime         time.Time              `json:"start_time"`
+	Config            map[string]interface{} `json:"config"`
+	CurrentTranscript string                 `json:"current_transcript"`
+	// Rolling summary and utterances of the conversation window
+	ConversationSummary string           `json:"conversation_summary,omitempty"`
+	Conversation        []string         `json:"conversation,omitempty"`
+	LastIntention       *IntentionResult `json:"last_intention,omitempty"`
+	Usage               map[string]int64 `json:"usage"`
+	UpdatedAt           time.Time        `json:"updated_at"`
 }
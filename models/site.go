@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// SiteObservation is the latest description of one location at a site,
+// merged from the environment contexts of every robot that saw it. Location
+// is the normalized location tag the observations were deduplicated by;
+// RobotID, SessionID and ContextID record who saw it last.
+type SiteObservation struct {
+	Site         string    `json:"site"`
+	Location     string    `json:"location"`
+	Overview     string    `json:"overview"`
+	KeyElements  []string  `json:"key_elements,omitempty"`
+	Layout       string    `json:"layout,omitempty"`
+	Activities   []string  `json:"activities,omitempty"`
+	RobotID      string    `json:"robot_id,omitempty"`
+	SessionID    string    `json:"session_id"`
+	ContextID    string    `json:"context_id"`
+	ObservedAt   time.Time `json:"observed_at"`
+	Observations int       `json:"observations"`
+}
@@ -0,0 +1,136 @@
+package models
+
+const (
+	DEFAULT_TENANT_ID = "default"
+)
+
+// Tenant is a customer fleet sharing the deployment. Empty provider fields
+// fall back to the server-wide environment configuration.
+type Tenant struct {
+	ID      string   `json:"id"`
+	Name    string   `json:"name"`
+	APIKeys []string `json:"api_keys"`
+
+	OpenAIAPIKey   string `json:"openai_api_key,omitempty"`
+	DeepgramAPIKey string `json:"deepgram_api_key,omitempty"`
+	// STTProvider selects the speech-to-text backend: deepgram (default),
+	// assemblyai, azure or vosk
+	STTProvider        string `json:"stt_provider,omitempty"`
+	AssemblyAIAPIKey   string `json:"assemblyai_api_key,omitempty"`
+	AzureSpeechKey     string `json:"azure_speech_key,omitempty"`
+	AzureSpeechRegion  string `json:"azure_speech_region,omitempty"`
+	PineconeAPIKey     string `json:"pinecone_api_key,omitempty"`
+	PineconeHost       string `json:"pinecone_host,omitempty"`
+	PineconeNamespace  string `json:"pinecone_namespace,omitempty"`
+	OrchestratorURL    string `json:"orchestrator_url,omitempty"`
+	OrchestratorAPIKey string `json:"orchestrator_api_key,omitempty"`
+	// OrchestratorAuth selects how orchestrator calls authenticate; nil
+	// sends OrchestratorAPIKey as a bearer token
+	OrchestratorAuth *OrchestratorAuth `json:"orchestrator_auth,omitempty"`
+	// OrchestratorRoutes send intentions to orchestrators by intention type;
+	// types without a route go to OrchestratorURL
+	OrchestratorRoutes []OrchestratorRoute `json:"orchestrator_routes,omitempty"`
+
+	// WebhookURLs receive session lifecycle events, signed with
+	// WebhookSecret when it is set
+	WebhookURLs   []string `json:"webhook_urls,omitempty"`
+	WebhookSecret string   `json:"webhook_secret,omitempty"`
+
+	// EncryptionKeyID names the key that encrypts the tenant's stored
+	// transcripts, analyses and snapshots. It is not inherited from the
+	// server default, so each tenant's data stays under its own key.
+	EncryptionKeyID string `json:"encryption_key_id,omitempty"`
+
+	// Incognito forces every session of the tenant into incognito mode
+	Incognito bool `json:"incognito,omitempty"`
+
+	// TranscriptFilters mask or redact content in transcripts before they
+	// are analyzed, stored, logged or sent on, by category (profanity,
+	// email, credit_card, phone) and action (mask, redact, off).
+	// ProfanityWords extends the built-in profanity list.
+	TranscriptFilters map[string]string `json:"transcript_filters,omitempty"`
+	ProfanityWords    []string          `json:"profanity_words,omitempty"`
+
+	RateLimits   TenantRateLimits `json:"rate_limits"`
+	TriggerRules []TriggerRule    `json:"trigger_rules,omitempty"`
+	// ChangeWatches pick the environment changes that alert or notify the
+	// orchestrator
+	ChangeWatches []ChangeWatch `json:"change_watches,omitempty"`
+	// EscalationPolicies act on the sentiment of transcripts; without any
+	// the SENTIMENT_ESCALATION_URGENCY default applies
+	EscalationPolicies []EscalationPolicy `json:"escalation_policies,omitempty"`
+}
+
+const (
+	ORCHESTRATOR_AUTH_BEARER = "bearer"
+	ORCHESTRATOR_AUTH_HMAC   = "hmac"
+	ORCHESTRATOR_AUTH_OAUTH2 = "oauth2"
+	ORCHESTRATOR_AUTH_NONE   = "none"
+)
+
+// OrchestratorAuth configures authentication towards the orchestrator. The
+// bearer scheme sends the tenant's OrchestratorAPIKey; hmac signs each body
+// with HMACSecret; oauth2 fetches client-credentials tokens from TokenURL.
+// Any scheme can be combined with mutual TLS by setting the certificate
+// files.
+type OrchestratorAuth struct {
+	Scheme string `json:"scheme,omitempty"`
+
+	HMACSecret string `json:"hmac_secret,omitempty"`
+	HMACHeader string `json:"hmac_header,omitempty"`
+
+	TokenURL     string   `json:"token_url,omitempty"`
+	ClientID     string   `json:"client_id,omitempty"`
+	ClientSecret string   `json:"client_secret,omitempty"`
+	Scopes       []string `json:"scopes,omitempty"`
+
+	TLSCertFile string `json:"tls_cert_file,omitempty"`
+	TLSKeyFile  string `json:"tls_key_file,omitempty"`
+	TLSCAFile   string `json:"tls_ca_file,omitempty"`
+}
+
+// ORCHESTRATOR_ROUTE_DEFAULT is the intention type of the route taken by
+// types without their own.
+const ORCHESTRATOR_ROUTE_DEFAULT = "*"
+
+// OrchestratorRoute sends intentions of IntentionType to every endpoint.
+type OrchestratorRoute struct {
+	IntentionType string                 `json:"intention_type"`
+	Endpoints     []OrchestratorEndpoint `json:"endpoints"`
+}
+
+// OrchestratorEndpoint is an orchestrator a route posts to. Without APIKey
+// and Auth it uses the tenant's orchestrator credentials.
+type OrchestratorEndpoint struct {
+	URL    string            `json:"url"`
+	APIKey string            `json:"api_key,omitempty"`
+	Auth   *OrchestratorAuth `json:"auth,omitempty"`
+}
+
+type TenantRateLimits struct {
+	// MessagesPerSecond caps inbound WebSocket messages per session (0 = unlimited)
+	MessagesPerSecond float64 `json:"messages_per_second,omitempty"`
+	// Burst is the number of messages allowed above the steady rate
+	Burst int `json:"burst,omitempty"`
+	// MaxSessions caps concurrent sessions per instance (0 = MAX_SESSIONS_PER_TENANT)
+	MaxSessions int `json:"max_sessions,omitempty"`
+}
+
+const (
+	USAGE_SESSIONS    = "sessions"
+	USAGE_AUDIO_BYTES = "audio_bytes"
+	// Audio actually streamed to speech-to-text, less than audio_bytes when
+	// voice activity detection holds back silence
+	USAGE_AUDIO_STREAMED_BYTES = "audio_streamed_bytes"
+	USAGE_FRAMES_ANALYZED      = "frames_analyzed"
+	USAGE_FRAMES_REJECTED      = "frames_rejected"
+	USAGE_DEPTH_FRAMES         = "depth_frames"
+	USAGE_INTENTIONS           = "intentions"
+	USAGE_ORCHESTRATIONS       = "orchestrations"
+	USAGE_RATE_LIMITED         = "rate_limited"
+	USAGE_SCENE_QUESTIONS      = "scene_questions"
+	// Audio windows sent to the sound classifier
+	USAGE_SOUND_WINDOWS = "sound_windows"
+	// Transcripts classified by sentiment analysis
+	USAGE_SENTIMENTS = "sentiments"
+)
@@ -0,0 +1,160 @@
+// Package router holds the HTTP middleware the API's chi router is built
+// with: request IDs, zap request logging, panic recovery and CORS.
+package router
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/cors"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const REQUEST_ID_HEADER = "X-Request-ID"
+
+type requestIDKey struct{}
+
+// Accepted incoming request IDs; others are replaced
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// RequestID tags each request with the caller's X-Request-ID, or a new one,
+// and echoes it in the response.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(REQUEST_ID_HEADER)
+		if !requestIDPattern.MatchString(id) {
+			id = uuid.New().String()
+		}
+		w.Header().Set(REQUEST_ID_HEADER, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+// GetRequestID returns the ID RequestID gave the request, or "".
+func GetRequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// Logger logs each request once it completes: Info for successful ones,
+// Warn for client and Error for server errors. Requests to the skipped paths
+// (health checks, metrics scrapes) are logged at Debug.
+func Logger(logger *zap.Logger, skip ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			started := time.Now()
+			recorder := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(recorder, r)
+
+			status := recorder.Status()
+			switch {
+			case status != 0:
+			case strings.EqualFold(r.Header.Get("Upgrade"), "websocket"):
+				// The upgrade is written to the hijacked connection
+				status = http.StatusSwitchingProtocols
+			default:
+				status = http.StatusOK
+			}
+			fields := []zap.Field{
+				zap.String("method", r.Method),
+				zap.String("path", r.URL.Path),
+				zap.Int("status", status),
+				zap.Int("bytes", recorder.BytesWritten()),
+				zap.Duration("duration", time.Since(started)),
+				zap.String("remote_addr", r.RemoteAddr),
+				zap.String("request_id", GetRequestID(r.Context())),
+			}
+			switch {
+			case slices.Contains(skip, r.URL.Path):
+				logger.Debug("HTTP request", fields...)
+			case status >= 500:
+				logger.Error("HTTP request", fields...)
+			case status >= 400:
+				logger.Warn("HTTP request", fields...)
+			default:
+				logger.Info("HTTP request", fields...)
+			}
+		})
+	}
+}
+
+// Recoverer turns a panicking handler into a 500 response instead of a
+// crashed connection, logging the stack.
+func Recoverer(logger *zap.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			recorder := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			defer func() {
+				recovered := recover()
+				if recovered == nil {
+					return
+				}
+				// Aborts are how handlers end a response on purpose
+				if recovered == http.ErrAbortHandler {
+					panic(recovered)
+				}
+				logger.Error("Recovered from handler panic",
+					zap.Any("panic", recovered),
+					zap.String("method", r.Method),
+					zap.String("path", r.URL.Path),
+					zap.String("request_id", GetRequestID(r.Context())),
+					zap.ByteString("stack", debug.Stack()))
+				if recorder.Status() == 0 {
+					http.Error(w, "internal server error", http.StatusInternalServerError)
+				}
+			}()
+			next.ServeHTTP(recorder, r)
+		})
+	}
+}
+
+// CORS lets browsers on the allowed origins ("*" for any) call the API,
+// answering preflight requests itself. Without origins it does nothing.
+func CORS(origins []string) func(http.Handler) http.Handler {
+	if len(origins) == 0 {
+		return func(next http.Handler) http.Handler { return next }
+	}
+	return cors.Handler(cors.Options{
+		AllowOriginFunc: func(r *http.Request, origin string) bool { return OriginAllowed(origins, origin) },
+		AllowedMethods:  []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowedHeaders:  []string{"Authorization", "Content-Type", REQUEST_ID_HEADER},
+		ExposedHeaders:  []string{REQUEST_ID_HEADER},
+		MaxAge:          int((10 * time.Minute).Seconds()),
+	})
+}
+
+// ParseOrigins splits a comma-separated origin list.
+func ParseOrigins(value string) []string {
+	var origins []string
+	for _, origin := range strings.Split(value, ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins = append(origins, strings.TrimSuffix(origin, "/"))
+		}
+	}
+	return origins
+}
+
+// OriginAllowed reports whether origin is in the list: listed exactly, under
+// a wildcard subdomain entry like https://*.example.com, or allowed by "*".
+func OriginAllowed(origins []string, origin string) bool {
+	origin = strings.ToLower(strings.TrimSuffix(origin, "/"))
+	for _, allowed := range origins {
+		allowed = strings.ToLower(allowed)
+		if allowed == "*" || allowed == origin {
+			return true
+		}
+		if scheme, domain, ok := strings.Cut(allowed, "://*."); ok {
+			if rest, found := strings.CutPrefix(origin, scheme+"://"); found && strings.HasSuffix(rest, "."+domain) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,119 @@
+package router_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/router"
+	"go.uber.org/zap"
+)
+
+func TestRequestIDKeepsValidIDs(t *testing.T) {
+	var seen string
+	handler := router.RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = router.GetRequestID(r.Context())
+	}))
+
+	for _, test := range []struct {
+		incoming string
+		keep     bool
+	}{
+		{"req-42.a:b", true},
+		{"", false},
+		{"has space", false},
+		{"<script>", false},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(router.REQUEST_ID_HEADER, test.incoming)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		echoed := rec.Header().Get(router.REQUEST_ID_HEADER)
+		if echoed != seen || seen == "" {
+			t.Errorf("request ID %q: got = %q in the context and %q echoed, want the same non-empty ID", test.incoming, seen, echoed)
+		}
+		if (seen == test.incoming) != test.keep {
+			t.Errorf("request ID %q: got = %q, want kept %v", test.incoming, seen, test.keep)
+		}
+	}
+}
+
+func TestRecovererAnswers500(t *testing.T) {
+	handler := router.Recoverer(zap.NewNop())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500", rec.Code)
+	}
+}
+
+func TestCORSAnswersAllowedOrigins(t *testing.T) {
+	reached := false
+	handler := router.CORS([]string{"https://*.example.com"})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+	}))
+
+	preflight := func(origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodOptions, "/robot/profiles", nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		req.Header.Set("Access-Control-Request-Headers", "Authorization")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+	if rec := preflight("https://app.example.com"); rec.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" {
+		t.Errorf("preflight from an allowed origin: Access-Control-Allow-Origin = %q, want the origin", rec.Header().Get("Access-Control-Allow-Origin"))
+	}
+	if rec := preflight("https://example.org"); rec.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("preflight from another origin: Access-Control-Allow-Origin = %q, want none", rec.Header().Get("Access-Control-Allow-Origin"))
+	}
+	if reached {
+		t.Error("preflight reached the handler")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/robot/profiles", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if !reached || !strings.EqualFold(rec.Header().Get("Access-Control-Expose-Headers"), router.REQUEST_ID_HEADER) {
+		t.Errorf("request from an allowed origin: reached = %v, exposed headers = %q, want the request ID header", reached, rec.Header().Get("Access-Control-Expose-Headers"))
+	}
+}
+
+func TestCORSWithoutOriginsDoesNothing(t *testing.T) {
+	handler := router.CORS(nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	req := httptest.NewRequest(http.MethodOptions, "/", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusTeapot || rec.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("got = %d with Access-Control-Allow-Origin %q, want the handler's response", rec.Code, rec.Header().Get("Access-Control-Allow-Origin"))
+	}
+}
+
+func TestOriginAllowed(t *testing.T) {
+	origins := router.ParseOrigins(" https://robots.example.com/, https://*.fleet.io ")
+	for origin, want := range map[string]bool{
+		"https://robots.example.com": true,
+		"HTTPS://Robots.Example.com": true,
+		"https://eu.fleet.io":        true,
+		"https://fleet.io":           false,
+		"http://eu.fleet.io":         false,
+		"https://evil.com":           false,
+	} {
+		if got := router.OriginAllowed(origins, origin); got != want {
+			t.Errorf("OriginAllowed(%q) = %v, want %v", origin, got, want)
+		}
+	}
+	if !router.OriginAllowed([]string{"*"}, "https://anything.dev") {
+		t.Error(`OriginAllowed("*") = false, want true`)
+	}
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/handlers"
+	"github.com/Perceptus-Labs/perceptus-go-sdk/router"
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// newRouter builds the HTTP API. Every request gets an X-Request-ID, is
+// logged and recovered from panics; CORS_ALLOWED_ORIGINS opens it to
+// browsers. The tenant API is served under /robot (and its neighbours) and
+// again under /v1; plain REST calls are cut off with 504 after
+// HTTP_REQUEST_TIMEOUT, streaming routes are not.
+func newRouter(cfg *utils.Config, redisClient *redis.Client, tenants *utils.TenantStore) http.Handler {
+	r := chi.NewRouter()
+	r.Use(
+		router.RequestID,
+		router.Logger(zap.L(), "/health", "/metrics"),
+		router.Recoverer(zap.L()),
+		router.CORS(router.ParseOrigins(cfg.CORSAllowedOrigins)),
+	)
+
+	tenantAPI := func(r chi.Router) {
+		tenantRoutes(r, cfg, redisClient, tenants)
+	}
+	r.Group(tenantAPI)
+	r.Route("/v1", tenantAPI)
+
+	r.Route("/admin", func(r chi.Router) {
+		// The dashboard page asks for the admin key itself
+		r.Get("/", handlers.HandleDashboard)
+		r.Group(func(r chi.Router) {
+			adminRoutes(r, redisClient, tenants)
+		})
+	})
+
+	// JSON Schemas of WebSocket messages and orchestrator payloads
+	r.Get("/schemas", handlers.HandleSchemas)
+	r.Get("/schemas/{kind}/{name}", handlers.HandleSchemas)
+
+	// Prometheus metrics
+	r.Handle("/metrics", promhttp.Handler())
+
+	// Health check endpoint
+	r.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+	})
+
+	return r
+}
+
+// tenantRoutes registers the API authenticated by tenant API keys.
+func tenantRoutes(r chi.Router, cfg *utils.Config, redisClient *redis.Client, tenants *utils.TenantStore) {
+	// Live captions authenticate with their own viewer token
+	r.Get("/robot/sessions/{id}/captions", handlers.HandleCaptions)
+
+	r.Group(func(r chi.Router) {
+		r.Use(handlers.RequireTenant(tenants))
+
+		// Streaming: the robot session WebSocket and the dashboard event feed
+		r.HandleFunc("/robot/session", func(w http.ResponseWriter, r *http.Request) {
+			handlers.HandleRobotSession(w, r, redisClient, tenants)
+		})
+		r.Get("/robot/sessions/{id}/events", func(w http.ResponseWriter, r *http.Request) {
+			handlers.HandleSessionEvents(w, r, tenants)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.Timeout(cfg.HTTPRequestTimeout))
+			restRoutes(r, redisClient, tenants)
+		})
+	})
+}
+
+// restRoutes registers the tenant API's plain REST calls.
+func restRoutes(r chi.Router, redisClient *redis.Client, tenants *utils.TenantStore) {
+	r.Route("/robot", func(r chi.Router) {
+		// Session profiles selectable with ?profile= at connect
+		r.Get("/profiles", handlers.HandleSessionProfiles)
+
+		// Ask a live session's robot for a frame
+		r.Post("/sessions/{id}/capture", func(w http.ResponseWriter, r *http.Request) {
+			handlers.HandleSessionCapture(w, r, tenants)
+		})
+
+		// Upload frames for a live session's vision analysis over HTTP
+		r.Post("/sessions/{id}/frames", func(w http.ResponseWriter, r *http.Request) {
+			handlers.HandleFrameUpload(w, r, tenants)
+		})
+
+		// Ask about what a live session's camera sees now
+		r.Post("/sessions/{id}/scene-questions", func(w http.ResponseWriter, r *http.Request) {
+			handlers.HandleSceneQuestion(w, r, tenants)
+		})
+
+		// Push display content to a live session's screen
+		r.Post("/sessions/{id}/display", func(w http.ResponseWriter, r *http.Request) {
+			handlers.HandleSessionDisplay(w, r, tenants)
+		})
+
+		// Cameras attached to the server, selectable with camera_device
+		r.Get("/cameras", handlers.HandleCameras)
+
+		// Read or change a live session's camera parameters
+		camera := func(w http.ResponseWriter, r *http.Request) {
+			handlers.HandleSessionCamera(w, r, tenants)
+		}
+		r.Get("/sessions/{id}/camera", camera)
+		r.Post("/sessions/{id}/camera", camera)
+
+		// Caption viewer tokens issued and revoked by the session owner
+		captionTokens := func(w http.ResponseWriter, r *http.Request) {
+			handlers.HandleCaptionToken(w, r, tenants)
+		}
+		r.Post("/sessions/{id}/captions/tokens", captionTokens)
+		r.Delete("/sessions/{id}/captions/tokens", captionTokens)
+
+		// Portable session bundles for support escalation and migration
+		r.Get("/sessions/{id}/export", func(w http.ResponseWriter, r *http.Request) {
+			handlers.HandleSessionExport(w, r, redisClient, tenants)
+		})
+		r.Post("/sessions/import", func(w http.ResponseWriter, r *http.Request) {
+			handlers.HandleSessionImport(w, r, redisClient, tenants)
+		})
+
+		// Data-subject erasure: everything stored about a session or robot
+		r.Delete("/sessions/{id}/data", func(w http.ResponseWriter, r *http.Request) {
+			handlers.HandleSessionDataDeletion(w, r, redisClient, tenants)
+		})
+		r.Delete("/robots/{id}/data", func(w http.ResponseWriter, r *http.Request) {
+			handlers.HandleRobotDataDeletion(w, r, redisClient, tenants)
+		})
+
+		// End-of-session summaries and a robot's daily digest
+		r.Get("/sessions/{id}/summary", func(w http.ResponseWriter, r *http.Request) {
+			handlers.HandleSessionSummary(w, r, redisClient, tenants)
+		})
+		r.Get("/summaries", func(w http.ResponseWriter, r *http.Request) {
+			handlers.HandleRobotDigest(w, r, redisClient, tenants)
+		})
+
+		// Long-term preference memory of a user or robot
+		r.Get("/preferences", func(w http.ResponseWriter, r *http.Request) {
+			handlers.HandlePreferences(w, r, redisClient, tenants)
+		})
+		r.Delete("/preferences/{id}", func(w http.ResponseWriter, r *http.Request) {
+			handlers.HandleDeletePreference(w, r, redisClient, tenants)
+		})
+
+		// World model shared by the robots at a site
+		r.Get("/sites/{site}/memory", func(w http.ResponseWriter, r *http.Request) {
+			handlers.HandleSiteMemory(w, r, redisClient, tenants)
+		})
+
+		// Registered robots and the state they carry across sessions
+		r.HandleFunc("/robots", func(w http.ResponseWriter, r *http.Request) {
+			handlers.HandleRobots(w, r, redisClient, tenants)
+		})
+		r.HandleFunc("/robots/{id}", func(w http.ResponseWriter, r *http.Request) {
+			handlers.HandleRobot(w, r, redisClient, tenants)
+		})
+
+		// MP4 recordings of session video (RECORDING_ENABLED)
+		r.Get("/sessions/{id}/recordings", func(w http.ResponseWriter, r *http.Request) {
+			handlers.HandleSessionRecordings(w, r, redisClient, tenants)
+		})
+		r.Get("/recordings/{id}", func(w http.ResponseWriter, r *http.Request) {
+			handlers.HandleRecording(w, r, redisClient, tenants)
+		})
+
+		// Frames environment contexts were described from (FRAME_STORE)
+		r.Get("/contexts/{id}/image", func(w http.ResponseWriter, r *http.Request) {
+			handlers.HandleContextImage(w, r, tenants)
+		})
+
+		// Intention history and feedback
+		r.Post("/sessions/{id}/intentions/{intention_id}/feedback", func(w http.ResponseWriter, r *http.Request) {
+			handlers.HandleIntentionFeedback(w, r, redisClient, tenants)
+		})
+		r.Get("/sessions/{id}/intentions", func(w http.ResponseWriter, r *http.Request) {
+			handlers.HandleSessionIntentions(w, r, redisClient, tenants)
+		})
+	})
+
+	// Simulated intentions for orchestrator development
+	// (DEBUG_ENDPOINTS_ENABLED)
+	r.Post("/debug/intentions", func(w http.ResponseWriter, r *http.Request) {
+		handlers.HandleDebugIntention(w, r, tenants)
+	})
+
+	// Labeled training data export
+	r.Get("/intentions/feedback/export", func(w http.ResponseWriter, r *http.Request) {
+		handlers.HandleFeedbackExport(w, r, redisClient, tenants)
+	})
+
+	// Keyword and semantic search over archived transcripts
+	r.Get("/search", func(w http.ResponseWriter, r *http.Request) {
+		handlers.HandleTranscriptSearch(w, r, redisClient, tenants)
+	})
+
+	// Usage counters for the caller's tenant
+	r.HandleFunc("/tenant/usage", func(w http.ResponseWriter, r *http.Request) {
+		handlers.HandleTenantUsage(w, r, tenants)
+	})
+
+	// Erasure of everything stored about the caller's tenant
+	r.Delete("/tenant/data", func(w http.ResponseWriter, r *http.Request) {
+		handlers.HandleTenantDataDeletion(w, r, redisClient, tenants)
+	})
+}
+
+// adminRoutes registers the operator API, authenticated by ADMIN_API_KEY.
+func adminRoutes(r chi.Router, redisClient *redis.Client, tenants *utils.TenantStore) {
+	r.Use(handlers.RequireAdmin)
+
+	// Rotate provider credentials without a restart (also on SIGHUP)
+	r.Post("/reload", func(w http.ResponseWriter, r *http.Request) {
+		handlers.HandleReloadCredentials(w, r, tenants)
+	})
+
+	// Rewrap stored artifacts after moving a tenant to a new encryption key
+	r.Post("/encryption/rotate", func(w http.ResponseWriter, r *http.Request) {
+		handlers.HandleRotateEncryptionKeys(w, r, redisClient)
+	})
+
+	// Per-tenant routing of intention types to orchestrators
+	orchestratorRoutes := func(w http.ResponseWriter, r *http.Request) {
+		handlers.HandleAdminOrchestratorRoutes(w, r, redisClient, tenants)
+	}
+	r.Get("/tenants/{id}/orchestrator-routes", orchestratorRoutes)
+	r.Put("/tenants/{id}/orchestrator-routes", orchestratorRoutes)
+	r.Delete("/tenants/{id}/orchestrator-routes", orchestratorRoutes)
+
+	// Admin dashboard: live sessions, event feeds, usage and session controls
+	r.Get("/sessions", handlers.HandleAdminSessions)
+	r.Get("/sessions/{id}/events", handlers.HandleAdminSessionEvents)
+	r.Delete("/sessions/{id}", handlers.HandleAdminTerminateSession)
+	r.Post("/sessions/{id}/commands", handlers.HandleAdminSessionCommand)
+	r.Put("/sessions/{id}/log-level", handlers.HandleAdminSessionLogLevel)
+	r.Delete("/sessions/{id}/log-level", handlers.HandleAdminSessionLogLevel)
+	r.Get("/log-level", handlers.HandleAdminLogLevel)
+	r.Put("/log-level", handlers.HandleAdminLogLevel)
+	r.Get("/usage", func(w http.ResponseWriter, r *http.Request) {
+		handlers.HandleAdminUsage(w, r, tenants)
+	})
+
+	// Inspect or change injected provider faults (TESTMODE=true only)
+	r.Get("/testmode", handlers.HandleTestMode)
+	r.Put("/testmode", handlers.HandleTestMode)
+}
@@ -0,0 +1,212 @@
+// Package testmode injects provider faults for chaos testing. It is inert
+// until Configure switches it on (TESTMODE=true); faults then start as
+// TESTMODE_FAULTS, e.g.
+//
+//	TESTMODE_FAULTS="llm:latency=2s,error=0.2;stt:error=0.05;orchestrator:malformed=0.5"
+//
+// and can be read with GET /admin/testmode and replaced at runtime with
+// PUT /admin/testmode. Targets are stt (Deepgram), llm (OpenAI), memory
+// (Pinecone) and orchestrator.
+package testmode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	TARGET_STT          = "stt"
+	TARGET_LLM          = "llm"
+	TARGET_MEMORY       = "memory"
+	TARGET_ORCHESTRATOR = "orchestrator"
+)
+
+var Targets = []string{TARGET_STT, TARGET_LLM, TARGET_MEMORY, TARGET_ORCHESTRATOR}
+
+// ErrInjected is returned by calls failed on purpose.
+var ErrInjected = errors.New("testmode: injected fault")
+
+// Fault describes what happens to calls to one target. Latency is added to
+// every call; ErrorRate and MalformedRate are probabilities between 0 and 1.
+type Fault struct {
+	Latency       time.Duration
+	ErrorRate     float64
+	MalformedRate float64
+}
+
+var (
+	mu      sync.RWMutex
+	enabled bool
+	faults  = map[string]Fault{}
+)
+
+// Enabled reports whether fault injection is switched on (TESTMODE=true).
+func Enabled() bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return enabled
+}
+
+// Configure switches fault injection on or off at startup, with the faults
+// in spec (TESTMODE_FAULTS) when on.
+func Configure(on bool, spec string) {
+	parsed := map[string]Fault{}
+	if on {
+		var err error
+		if parsed, err = ParseFaults(spec); err != nil {
+			zap.L().Error("Invalid TESTMODE_FAULTS, starting without faults", zap.Error(err))
+			parsed = map[string]Fault{}
+		}
+	}
+	mu.Lock()
+	enabled, faults = on, parsed
+	mu.Unlock()
+	if on {
+		zap.L().Warn("TESTMODE enabled, provider faults will be injected", zap.String("faults", FormatFaults(parsed)))
+	}
+}
+
+// ParseFaults parses "target:key=value,...;target:..." where keys are
+// latency (Go duration), error and malformed (rates).
+func ParseFaults(spec string) (map[string]Fault, error) {
+	result := map[string]Fault{}
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		target, settings, ok := strings.Cut(entry, ":")
+		if !ok || !validTarget(target) {
+			return nil, fmt.Errorf("invalid fault entry %q", entry)
+		}
+
+		var fault Fault
+		for _, setting := range strings.Split(settings, ",") {
+			key, value, _ := strings.Cut(strings.TrimSpace(setting), "=")
+			var err error
+			switch key {
+			case "latency":
+				fault.Latency, err = time.ParseDuration(value)
+			case "error":
+				fault.ErrorRate, err = parseRate(value)
+			case "malformed":
+				fault.MalformedRate, err = parseRate(value)
+			default:
+				err = fmt.Errorf("unknown fault setting %q", key)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("invalid fault for %s: %w", target, err)
+			}
+		}
+		result[target] = fault
+	}
+	return result, nil
+}
+
+// FormatFaults renders faults in the TESTMODE_FAULTS format.
+func FormatFaults(faults map[string]Fault) string {
+	var entries []string
+	for _, target := range Targets {
+		fault, ok := faults[target]
+		if !ok {
+			continue
+		}
+		entries = append(entries, fmt.Sprintf("%s:latency=%s,error=%g,malformed=%g",
+			target, fault.Latency, fault.ErrorRate, fault.MalformedRate))
+	}
+	return strings.Join(entries, ";")
+}
+
+func parseRate(value string) (float64, error) {
+	rate, err := strconv.ParseFloat(value, 64)
+	if err != nil || rate < 0 || rate > 1 {
+		return 0, fmt.Errorf("rate %q must be between 0 and 1", value)
+	}
+	return rate, nil
+}
+
+func validTarget(target string) bool {
+	for _, t := range Targets {
+		if t == target {
+			return true
+		}
+	}
+	return false
+}
+
+// SetFaults replaces the active faults. It fails when test mode is off so a
+// production server cannot be switched into chaos mode remotely.
+func SetFaults(next map[string]Fault) error {
+	if !Enabled() {
+		return fmt.Errorf("test mode is disabled")
+	}
+	for target := range next {
+		if !validTarget(target) {
+			return fmt.Errorf("unknown fault target %q", target)
+		}
+	}
+	mu.Lock()
+	faults = next
+	mu.Unlock()
+	zap.L().Warn("Replaced injected faults", zap.String("faults", FormatFaults(next)))
+	return nil
+}
+
+// Faults returns a copy of the active faults.
+func Faults() map[string]Fault {
+	mu.RLock()
+	defer mu.RUnlock()
+	copied := make(map[string]Fault, len(faults))
+	for target, fault := range faults {
+		copied[target] = fault
+	}
+	return copied
+}
+
+func faultFor(target string) (Fault, bool) {
+	if !Enabled() {
+		return Fault{}, false
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+	fault, ok := faults[target]
+	return fault, ok
+}
+
+// Inject delays a call to target by the configured latency and returns
+// ErrInjected at the configured error rate. It returns nil immediately when
+// test mode is off.
+func Inject(ctx context.Context, target string) error {
+	fault, ok := faultFor(target)
+	if !ok {
+		return nil
+	}
+	if fault.Latency > 0 {
+		timer := time.NewTimer(fault.Latency)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	if fault.ErrorRate > 0 && rand.Float64() < fault.ErrorRate {
+		return fmt.Errorf("%w (%s)", ErrInjected, target)
+	}
+	return nil
+}
+
+// Malformed reports whether the current response from target should be
+// corrupted.
+func Malformed(target string) bool {
+	fault, ok := faultFor(target)
+	return ok && fault.MalformedRate > 0 && rand.Float64() < fault.MalformedRate
+}
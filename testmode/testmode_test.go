@@ -0,0 +1,80 @@
+package testmode
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestParseFaults(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		want    map[string]Fault
+		wantErr bool
+	}{
+		{name: "empty", spec: "", want: map[string]Fault{}},
+		{
+			name: "several targets",
+			spec: "llm:latency=2s,error=0.2; stt:error=0.05;orchestrator:malformed=0.5",
+			want: map[string]Fault{
+				TARGET_LLM:          {Latency: 2 * time.Second, ErrorRate: 0.2},
+				TARGET_STT:          {ErrorRate: 0.05},
+				TARGET_ORCHESTRATOR: {MalformedRate: 0.5},
+			},
+		},
+		{name: "unknown target", spec: "camera:error=0.1", wantErr: true},
+		{name: "missing settings", spec: "llm", wantErr: true},
+		{name: "unknown setting", spec: "llm:timeout=1s", wantErr: true},
+		{name: "rate above 1", spec: "memory:error=1.5", wantErr: true},
+		{name: "invalid latency", spec: "memory:latency=soon", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseFaults(tt.spec)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseFaults(%q) = %v, want an error", tt.spec, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseFaults(%q): %v", tt.spec, err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("ParseFaults(%q) = %v, want %v", tt.spec, got, tt.want)
+			}
+			for target, fault := range tt.want {
+				if got[target] != fault {
+					t.Errorf("fault for %s = %+v, want %+v", target, got[target], fault)
+				}
+			}
+		})
+	}
+}
+
+func TestFormatFaultsRoundTrips(t *testing.T) {
+	faults := map[string]Fault{
+		TARGET_MEMORY: {Latency: 500 * time.Millisecond, ErrorRate: 0.1},
+		TARGET_LLM:    {MalformedRate: 0.25},
+	}
+	parsed, err := ParseFaults(FormatFaults(faults))
+	if err != nil {
+		t.Fatalf("ParseFaults(FormatFaults()): %v", err)
+	}
+	for target, fault := range faults {
+		if parsed[target] != fault {
+			t.Errorf("fault for %s = %+v, want %+v", target, parsed[target], fault)
+		}
+	}
+}
+
+func TestSetFaultsNeedsTestMode(t *testing.T) {
+	Configure(false, "")
+	if err := SetFaults(map[string]Fault{TARGET_LLM: {ErrorRate: 1}}); err == nil {
+		t.Error("SetFaults succeeded with test mode off")
+	}
+	if err := Inject(context.Background(), TARGET_LLM); err != nil {
+		t.Errorf("Inject with test mode off = %v, want nil", err)
+	}
+}
@@ -0,0 +1,59 @@
+package testmode
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+)
+
+// malformedBody is returned in place of a provider response; it is
+// truncated JSON, which every caller must reject cleanly.
+const malformedBody = `{"choices":[{"message":{"content":"{\"has_clear_intention\": tr`
+
+type transport struct {
+	target string
+	base   http.RoundTripper
+}
+
+// Transport wraps base so requests to target see the injected faults:
+// latency before the request, a 503 at the error rate and a truncated body
+// at the malformed rate. It returns base unchanged when test mode is off.
+func Transport(target string, base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if !Enabled() {
+		return base
+	}
+	return &transport{target: target, base: base}
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := Inject(req.Context(), t.target); err != nil {
+		if req.Context().Err() != nil {
+			return nil, err
+		}
+		return syntheticResponse(req, http.StatusServiceUnavailable, err.Error()), nil
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || !Malformed(t.target) {
+		return resp, err
+	}
+	resp.Body.Close()
+	return syntheticResponse(req, http.StatusOK, malformedBody), nil
+}
+
+func syntheticResponse(req *http.Request, status int, body string) *http.Response {
+	return &http.Response{
+		Status:        http.StatusText(status),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"application/json"}},
+		Body:          io.NopCloser(bytes.NewBufferString(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// serverTLS is how the server terminates TLS: with a certificate and key
+// read from TLS_CERT_FILE and TLS_KEY_FILE (reloaded on SIGHUP, so renewed
+// certificates need no restart), or with certificates obtained from Let's
+// Encrypt for TLS_AUTOCERT_DOMAINS. Without either the server speaks plain
+// HTTP, e.g. behind a load balancer that terminates TLS.
+type serverTLS struct {
+	config *tls.Config
+	// redirect answers on TLS_REDIRECT_PORT: ACME HTTP challenges and a
+	// redirect to HTTPS for everything else
+	redirect     http.Handler
+	redirectPort string
+
+	// Certificate files and the keypair loaded from them
+	certFile, keyFile string
+	mu                sync.RWMutex
+	cert              *tls.Certificate
+}
+
+// newServerTLS reads the TLS configuration, nil for plain HTTP.
+func newServerTLS(cfg *utils.Config) (*serverTLS, error) {
+	certFile, keyFile := cfg.TLSCertFile, cfg.TLSKeyFile
+	var domains []string
+	for _, domain := range strings.Split(cfg.TLSAutocertDomains, ",") {
+		if domain = strings.TrimSpace(domain); domain != "" {
+			domains = append(domains, domain)
+		}
+	}
+
+	switch {
+	case certFile != "" || keyFile != "":
+		if certFile == "" || keyFile == "" {
+			return nil, fmt.Errorf("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+		}
+		if len(domains) > 0 {
+			return nil, fmt.Errorf("TLS_CERT_FILE and TLS_AUTOCERT_DOMAINS are mutually exclusive")
+		}
+		s := &serverTLS{certFile: certFile, keyFile: keyFile, redirect: redirectToHTTPS(cfg.Port), redirectPort: cfg.TLSRedirectPort}
+		if err := s.reload(); err != nil {
+			return nil, err
+		}
+		s.config = &tls.Config{
+			MinVersion: tls.VersionTLS12,
+			GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+				s.mu.RLock()
+				defer s.mu.RUnlock()
+				return s.cert, nil
+			},
+		}
+		return s, nil
+
+	case len(domains) > 0:
+		cacheDir := cfg.TLSAutocertCacheDir
+		if cacheDir == "" {
+			cacheDir = "autocert-cache"
+		}
+		manager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(domains...),
+			Cache:      autocert.DirCache(cacheDir),
+			Email:      cfg.TLSAutocertEmail,
+		}
+		if directory := cfg.TLSAutocertDirectoryURL; directory != "" {
+			// e.g. the Let's Encrypt staging environment
+			manager.Client = &acme.Client{DirectoryURL: directory}
+		}
+		config := manager.TLSConfig()
+		config.MinVersion = tls.VersionTLS12
+		return &serverTLS{config: config, redirect: manager.HTTPHandler(nil), redirectPort: cfg.TLSRedirectPort}, nil
+	}
+	return nil, nil
+}
+
+// reload reads the certificate files again. A failed reload keeps the
+// current certificate.
+func (s *serverTLS) reload() error {
+	if s == nil || s.certFile == "" {
+		return nil
+	}
+	cert, err := tls.LoadX509KeyPair(s.certFile, s.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	s.mu.Lock()
+	s.cert = &cert
+	s.mu.Unlock()
+	return nil
+}
+
+// serveRedirect listens on TLS_REDIRECT_PORT, if set, for plain HTTP.
+func (s *serverTLS) serveRedirect() {
+	if s == nil || s.redirectPort == "" {
+		return
+	}
+	port := s.redirectPort
+	go func() {
+		zap.L().Info("Starting HTTP redirect server", zap.String("port", port))
+		server := &http.Server{Addr: ":" + port, Handler: s.redirect, ReadHeaderTimeout: 10 * time.Second}
+		if err := server.ListenAndServe(); err != nil {
+			zap.L().Error("HTTP redirect server error", zap.Error(err))
+		}
+	}()
+}
+
+// redirectToHTTPS sends requests to the same host on the HTTPS port.
+func redirectToHTTPS(port int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if port != 443 {
+			host = net.JoinHostPort(host, strconv.Itoa(port))
+		}
+		target := "https://" + host + r.URL.RequestURI()
+		http.Redirect(w, r, target, http.StatusMovedPermanently)
+	}
+}
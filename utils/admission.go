@@ -0,0 +1,135 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrAdmissionLimit = errors.New("session limit reached")
+
+// AdmissionLimits caps concurrent sessions on this instance. Zero means
+// unlimited.
+type AdmissionLimits struct {
+	Global    int
+	PerTenant int
+	PerIP     int
+	// QueueSize is the number of requests allowed to wait for a slot
+	QueueSize int
+}
+
+// AdmissionLimitsFromConfig reads MAX_SESSIONS, MAX_SESSIONS_PER_TENANT,
+// MAX_SESSIONS_PER_IP and ADMISSION_QUEUE_SIZE.
+func AdmissionLimitsFromConfig(cfg *Config) AdmissionLimits {
+	return AdmissionLimits{
+		Global:    cfg.MaxSessions,
+		PerTenant: cfg.MaxSessionsPerTenant,
+		PerIP:     cfg.MaxSessionsPerIP,
+		QueueSize: cfg.AdmissionQueueSize,
+	}
+}
+
+// AdmissionController counts live sessions globally, per tenant and per
+// client IP, and admits new ones while all counts are below their limits.
+type AdmissionController struct {
+	limits AdmissionLimits
+
+	mu       sync.Mutex
+	total    int
+	byTenant map[string]int
+	byIP     map[string]int
+	waiting  int
+	// released is closed and replaced whenever a slot frees up
+	released chan struct{}
+}
+
+func NewAdmissionController(limits AdmissionLimits) *AdmissionController {
+	return &AdmissionController{
+		limits:   limits,
+		byTenant: make(map[string]int),
+		byIP:     make(map[string]int),
+		released: make(chan struct{}),
+	}
+}
+
+// Acquire admits a session, waiting up to wait for a slot when limits are
+// reached. tenantLimit overrides the per-tenant default when positive. The
+// returned release func must be called once the session ends.
+func (a *AdmissionController) Acquire(ctx context.Context, tenantID string, tenantLimit int, ip string, wait time.Duration) (func(), error) {
+	if tenantLimit <= 0 {
+		tenantLimit = a.limits.PerTenant
+	}
+
+	var deadline <-chan time.Time
+	queued := false
+	defer func() {
+		if queued {
+			a.mu.Lock()
+			a.waiting--
+			a.mu.Unlock()
+		}
+	}()
+
+	for {
+		a.mu.Lock()
+		if a.admits(tenantID, tenantLimit, ip) {
+			a.total++
+			a.byTenant[tenantID]++
+			a.byIP[ip]++
+			a.mu.Unlock()
+			return a.releaseFunc(tenantID, ip), nil
+		}
+		if wait <= 0 || (!queued && a.waiting >= a.limits.QueueSize) {
+			a.mu.Unlock()
+			return nil, ErrAdmissionLimit
+		}
+		if !queued {
+			queued = true
+			a.waiting++
+			deadline = time.After(wait)
+		}
+		released := a.released
+		a.mu.Unlock()
+
+		select {
+		case <-released:
+		case <-deadline:
+			return nil, ErrAdmissionLimit
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (a *AdmissionController) admits(tenantID string, tenantLimit int, ip string) bool {
+	if a.limits.Global > 0 && a.total >= a.limits.Global {
+		return false
+	}
+	if tenantLimit > 0 && a.byTenant[tenantID] >= tenantLimit {
+		return false
+	}
+	if a.limits.PerIP > 0 && a.byIP[ip] >= a.limits.PerIP {
+		return false
+	}
+	return true
+}
+
+func (a *AdmissionController) releaseFunc(tenantID, ip string) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			a.mu.Lock()
+			defer a.mu.Unlock()
+			a.total--
+			if a.byTenant[tenantID]--; a.byTenant[tenantID] <= 0 {
+				delete(a.byTenant, tenantID)
+			}
+			if a.byIP[ip]--; a.byIP[ip] <= 0 {
+				delete(a.byIP, ip)
+			}
+			close(a.released)
+			a.released = make(chan struct{})
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package utils_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+)
+
+func TestAdmissionLimits(t *testing.T) {
+	type session struct{ tenant, ip string }
+	for _, test := range []struct {
+		name     string
+		limits   utils.AdmissionLimits
+		override int
+		admitted []session
+		next     session
+		want     error
+	}{
+		{"unlimited", utils.AdmissionLimits{}, 0, []session{{"a", "1"}, {"a", "1"}}, session{"a", "1"}, nil},
+		{"global", utils.AdmissionLimits{Global: 2}, 0, []session{{"a", "1"}, {"b", "2"}}, session{"c", "3"}, utils.ErrAdmissionLimit},
+		{"per tenant", utils.AdmissionLimits{PerTenant: 1}, 0, []session{{"a", "1"}}, session{"a", "2"}, utils.ErrAdmissionLimit},
+		{"other tenant", utils.AdmissionLimits{PerTenant: 1}, 0, []session{{"a", "1"}}, session{"b", "1"}, nil},
+		{"tenant override", utils.AdmissionLimits{PerTenant: 1}, 2, []session{{"a", "1"}}, session{"a", "2"}, nil},
+		{"per IP", utils.AdmissionLimits{PerIP: 1}, 0, []session{{"a", "1"}}, session{"b", "1"}, utils.ErrAdmissionLimit},
+	} {
+		admission := utils.NewAdmissionController(test.limits)
+		for _, s := range test.admitted {
+			if _, err := admission.Acquire(context.Background(), s.tenant, test.override, s.ip, 0); err != nil {
+				t.Fatalf("%s: Acquire(%v) error = %v", test.name, s, err)
+			}
+		}
+		_, err := admission.Acquire(context.Background(), test.next.tenant, test.override, test.next.ip, 0)
+		if !errors.Is(err, test.want) {
+			t.Errorf("%s: Acquire(%v) error = %v, want %v", test.name, test.next, err, test.want)
+		}
+	}
+}
+
+func TestAdmissionWaitsForRelease(t *testing.T) {
+	admission := utils.NewAdmissionController(utils.AdmissionLimits{Global: 1, QueueSize: 1})
+	release, err := admission.Acquire(context.Background(), "a", 0, "1", 0)
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+
+	admitted := make(chan error, 1)
+	go func() {
+		_, err := admission.Acquire(context.Background(), "b", 0, "2", time.Minute)
+		admitted <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	release()
+	release() // Releasing twice frees one slot
+	select {
+	case err := <-admitted:
+		if err != nil {
+			t.Errorf("queued Acquire() error = %v, want nil", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("queued Acquire() not admitted after a release")
+	}
+	if _, err := admission.Acquire(context.Background(), "d", 0, "4", 0); !errors.Is(err, utils.ErrAdmissionLimit) {
+		t.Errorf("Acquire() after a double release error = %v, want %v", err, utils.ErrAdmissionLimit)
+	}
+}
+
+func TestAdmissionWaitEnds(t *testing.T) {
+	admission := utils.NewAdmissionController(utils.AdmissionLimits{Global: 1, QueueSize: 10})
+	if _, err := admission.Acquire(context.Background(), "a", 0, "1", 0); err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+	if _, err := admission.Acquire(context.Background(), "b", 0, "2", 20*time.Millisecond); !errors.Is(err, utils.ErrAdmissionLimit) {
+		t.Errorf("Acquire() past its wait error = %v, want %v", err, utils.ErrAdmissionLimit)
+	}
+	// Without a queue nobody waits
+	full := utils.NewAdmissionController(utils.AdmissionLimits{Global: 1})
+	if _, err := full.Acquire(context.Background(), "a", 0, "1", 0); err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+	if _, err := full.Acquire(context.Background(), "b", 0, "2", time.Hour); !errors.Is(err, utils.ErrAdmissionLimit) {
+		t.Errorf("Acquire() with a full queue error = %v, want %v", err, utils.ErrAdmissionLimit)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := admission.Acquire(ctx, "b", 0, "2", time.Minute); !errors.Is(err, context.Canceled) {
+		t.Errorf("Acquire() with a canceled context error = %v, want %v", err, context.Canceled)
+	}
+}
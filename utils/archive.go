@@ -0,0 +1,273 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// Records archived before the archive was kept per tenant, moved into
+	// the tenant archives by PurgeExpiredRecords
+	legacyAnalysisArchiveKey = "perceptus:analysis_archive"
+	analysisArchiveKeyPrefix = "perceptus:analysis_archive:tenant:"
+	analysisArchiveMaxLen    = 10000
+	sessionArchiveKeyPrefix  = "perceptus:analysis_archive:session:"
+	sessionMetaKeyPrefix     = "perceptus:session_meta:"
+	sessionArchiveRetention  = 30 * 24 * time.Hour
+	reanalysisKeyPrefix      = "perceptus:reanalysis:"
+	worldStateKeyPrefix      = "perceptus:world_state:"
+)
+
+// ArchiveFramesEnabled reports whether raw frames should be archived alongside
+// vision analyses. Frames are large, so this is opt-in via ARCHIVE_FRAMES.
+func ArchiveFramesEnabled() bool {
+	return CurrentConfig().ArchiveFrames
+}
+
+// analysisArchiveKey is the bounded archive of a tenant's analyses.
+func analysisArchiveKey(tenantID string) string {
+	return analysisArchiveKeyPrefix + tenantID
+}
+
+// ArchiveAnalysis appends an online analysis to its tenant's bounded Redis
+// archive, and indexes the transcript of an intention for keyword search.
+func ArchiveAnalysis(ctx context.Context, rdb *redis.Client, record models.AnalysisRecord) error {
+	data, err := marshalArtifact(ctx, record.TenantID, record)
+	if err != nil {
+		return fmt.Errorf("failed to encode analysis record: %w", err)
+	}
+
+	archiveKey := analysisArchiveKey(record.TenantID)
+	sessionKey := sessionArchiveKeyPrefix + record.SessionID
+
+	pipe := rdb.TxPipeline()
+	pipe.RPush(ctx, archiveKey, data)
+	pipe.LTrim(ctx, archiveKey, -analysisArchiveMaxLen, -1)
+	pipe.RPush(ctx, sessionKey, data)
+	pipe.Expire(ctx, sessionKey, sessionArchiveRetention)
+	if record.Kind == models.ANALYSIS_KIND_INTENTION && record.Transcript != "" {
+		indexTranscript(ctx, pipe, record, data)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to archive analysis: %w", err)
+	}
+	return nil
+}
+
+// LoadArchivedAnalyses returns up to limit archived records of a tenant, or
+// of every tenant when tenantID is empty, newer than since.
+func LoadArchivedAnalyses(ctx context.Context, rdb *redis.Client, tenantID string, since time.Time, limit int) ([]models.AnalysisRecord, error) {
+	keys := []string{analysisArchiveKey(tenantID)}
+	if tenantID == "" {
+		var err error
+		if keys, err = analysisArchiveKeys(ctx, rdb); err != nil {
+			return nil, err
+		}
+	}
+
+	var records []models.AnalysisRecord
+	for _, key := range keys {
+		raw, err := rdb.LRange(ctx, key, 0, -1).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read analysis archive: %w", err)
+		}
+		for _, item := range raw {
+			var record models.AnalysisRecord
+			if err := unmarshalArtifact(ctx, []byte(item), &record); err != nil {
+				continue
+			}
+			if record.Timestamp.Before(since) {
+				continue
+			}
+			records = append(records, record)
+			if limit > 0 && len(records) >= limit {
+				return records, nil
+			}
+		}
+	}
+	return records, nil
+}
+
+// analysisArchiveKeys lists the tenant archives.
+func analysisArchiveKeys(ctx context.Context, rdb *redis.Client) ([]string, error) {
+	var keys []string
+	iter := rdb.Scan(ctx, 0, analysisArchiveKeyPrefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("failed to scan analysis archives: %w", err)
+	}
+	return keys, nil
+}
+
+// LoadSessionAnalyses returns every archived record of a session in order.
+func LoadSessionAnalyses(ctx context.Context, rdb *redis.Client, sessionID string) ([]models.AnalysisRecord, error) {
+	raw, err := rdb.LRange(ctx, sessionArchiveKeyPrefix+sessionID, 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session archive: %w", err)
+	}
+
+	records := make([]models.AnalysisRecord, 0, len(raw))
+	for _, item := range raw {
+		var record models.AnalysisRecord
+		if err := unmarshalArtifact(ctx, []byte(item), &record); err != nil {
+			continue
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// PurgeExpiredRecords removes entries older than the archive retention from
+// the tenant analysis archives, the feedback indexes and the transcript
+// indexes, which unlike the per-session keys do not expire on their own.
+func PurgeExpiredRecords(ctx context.Context, rdb *redis.Client) (int, error) {
+	cutoff := time.Now().Add(-sessionArchiveRetention)
+	if err := migrateAnalysisArchive(ctx, rdb); err != nil {
+		return 0, err
+	}
+
+	keys, err := analysisArchiveKeys(ctx, rdb)
+	if err != nil {
+		return 0, err
+	}
+	purged := 0
+	for _, key := range keys {
+		removed, err := purgeExpiredAnalyses(ctx, rdb, key, cutoff)
+		purged += removed
+		if err != nil {
+			return purged, err
+		}
+	}
+
+	iter := rdb.Scan(ctx, 0, feedbackIndexKeyPrefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		removed, err := rdb.ZRemRangeByScore(ctx, iter.Val(), "-inf", "("+strconv.FormatInt(cutoff.Unix(), 10)).Result()
+		if err != nil {
+			return purged, fmt.Errorf("failed to purge feedback index: %w", err)
+		}
+		purged += int(removed)
+	}
+	if err := iter.Err(); err != nil {
+		return purged, fmt.Errorf("failed to scan feedback indexes: %w", err)
+	}
+
+	removed, err := purgeTranscriptIndex(ctx, rdb, cutoff)
+	purged += removed
+	return purged, err
+}
+
+// purgeExpiredAnalyses pops the records older than cutoff off an archive,
+// which is appended in time order, so they are at its head.
+func purgeExpiredAnalyses(ctx context.Context, rdb *redis.Client, key string, cutoff time.Time) (int, error) {
+	purged := 0
+	for {
+		head, err := rdb.LIndex(ctx, key, 0).Result()
+		if err == redis.Nil {
+			return purged, nil
+		}
+		if err != nil {
+			return purged, fmt.Errorf("failed to read analysis archive: %w", err)
+		}
+		data, err := openArtifact(ctx, []byte(head))
+		if err != nil {
+			// Never purge a record only because its key is unavailable
+			return purged, fmt.Errorf("failed to decrypt analysis archive: %w", err)
+		}
+		var record models.AnalysisRecord
+		if err := json.Unmarshal(data, &record); err == nil && !record.Timestamp.Before(cutoff) {
+			return purged, nil
+		}
+		if err := rdb.LPop(ctx, key).Err(); err != nil {
+			return purged, fmt.Errorf("failed to purge analysis archive: %w", err)
+		}
+		purged++
+	}
+}
+
+// migrateAnalysisArchive moves the records of the legacy shared archive to
+// the head of their tenant's archive: they predate everything archived per
+// tenant, so the archives stay in time order. Records whose key is
+// unavailable stay where they are.
+func migrateAnalysisArchive(ctx context.Context, rdb *redis.Client) error {
+	raw, err := rdb.LRange(ctx, legacyAnalysisArchiveKey, 0, -1).Result()
+	if err != nil {
+		return fmt.Errorf("failed to read legacy analysis archive: %w", err)
+	}
+	for i := len(raw) - 1; i >= 0; i-- {
+		var record models.AnalysisRecord
+		if err := unmarshalArtifact(ctx, []byte(raw[i]), &record); err != nil {
+			continue
+		}
+		pipe := rdb.TxPipeline()
+		pipe.LPush(ctx, analysisArchiveKey(record.TenantID), raw[i])
+		pipe.LRem(ctx, legacyAnalysisArchiveKey, 1, raw[i])
+		if _, err := pipe.Exec(ctx); err != nil {
+			return fmt.Errorf("failed to migrate analysis archive: %w", err)
+		}
+	}
+	return nil
+}
+
+// SaveSessionMeta persists the session description next to its archive.
+func SaveSessionMeta(ctx context.Context, rdb *redis.Client, meta models.SessionMeta) error {
+	data, err := marshalArtifact(ctx, meta.TenantID, meta)
+	if err != nil {
+		return fmt.Errorf("failed to encode session meta: %w", err)
+	}
+	if err := rdb.Set(ctx, sessionMetaKeyPrefix+meta.ID, data, sessionArchiveRetention).Err(); err != nil {
+		return fmt.Errorf("failed to save session meta: %w", err)
+	}
+	return nil
+}
+
+func LoadSessionMeta(ctx context.Context, rdb *redis.Client, sessionID string) (*models.SessionMeta, error) {
+	data, err := rdb.Get(ctx, sessionMetaKeyPrefix+sessionID).Bytes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load session meta: %w", err)
+	}
+	var meta models.SessionMeta
+	if err := unmarshalArtifact(ctx, data, &meta); err != nil {
+		return nil, fmt.Errorf("failed to decode session meta: %w", err)
+	}
+	return &meta, nil
+}
+
+// SaveWorldState persists the compacted world state summary of a session.
+func SaveWorldState(ctx context.Context, rdb *redis.Client, tenantID, sessionID, summary string) error {
+	data, err := sealArtifact(ctx, tenantID, []byte(summary))
+	if err != nil {
+		return fmt.Errorf("failed to encode world state: %w", err)
+	}
+	if err := rdb.Set(ctx, worldStateKeyPrefix+sessionID, data, sessionArchiveRetention).Err(); err != nil {
+		return fmt.Errorf("failed to save world state: %w", err)
+	}
+	return nil
+}
+
+// StoreReanalysis persists re-analysis results and the diff report for a batch.
+func StoreReanalysis(ctx context.Context, rdb *redis.Client, report *models.ReanalysisReport, results map[string]json.RawMessage) error {
+	key := reanalysisKeyPrefix + report.BatchID
+
+	fields := make(map[string]interface{}, len(results)+1)
+	for id, result := range results {
+		fields["result:"+id] = string(result)
+	}
+	reportData, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("failed to marshal reanalysis report: %w", err)
+	}
+	fields["report"] = string(reportData)
+
+	if err := rdb.HSet(ctx, key, fields).Err(); err != nil {
+		return fmt.Errorf("failed to store reanalysis results: %w", err)
+	}
+	return nil
+}
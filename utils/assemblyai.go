@@ -0,0 +1,208 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+const assemblyAIStreamingURL = "wss://streaming.assemblyai.com/v3/ws"
+
+// AssemblyAI rejects audio messages shorter than 50ms or longer than 1s
+const (
+	assemblyAIMinChunk = 0.05
+	assemblyAIMaxChunk = 1.0
+)
+
+// AssemblyAIClient streams audio to AssemblyAI Universal Streaming (v3). A
+// turn corresponds to an utterance: partial turns are interim results and
+// the end of a turn delivers the final transcript and END_OF_SPEECH.
+// Endpointing maps to the silence that ends a confident turn and utterance
+// end to the silence that ends any turn.
+type AssemblyAIClient struct {
+	sttStream
+	apiKey string
+	url    string
+
+	// Audio is regrouped into chunks AssemblyAI accepts
+	pendingMu sync.Mutex
+	pending   []byte
+	minChunk  int
+	maxChunk  int
+}
+
+type assemblyAIMessage struct {
+	Type            string  `json:"type"`
+	Transcript      string  `json:"transcript"`
+	EndOfTurn       bool    `json:"end_of_turn"`
+	TurnIsFormatted bool    `json:"turn_is_formatted"`
+	AudioDuration   float64 `json:"audio_duration_seconds"`
+	Error           string  `json:"error"`
+	Words           []struct {
+		Text       string  `json:"text"`
+		Confidence float64 `json:"confidence"`
+	} `json:"words"`
+}
+
+func NewAssemblyAIClient(apiKey string, config STTStreamConfig) (*AssemblyAIClient, error) {
+	if apiKey == "" {
+		return nil, fmt.Errorf("AssemblyAI API key not configured")
+	}
+
+	var encoding string
+	bytesPerSample := 2
+	switch config.Format.Encoding {
+	case AudioEncodingLinear16:
+		encoding = "pcm_s16le"
+	case "mulaw":
+		encoding, bytesPerSample = "pcm_mulaw", 1
+	default:
+		return nil, fmt.Errorf("AssemblyAI streaming requires linear16 or mulaw audio, got %q", config.Format.Encoding)
+	}
+	if config.Format.SampleRate == 0 {
+		return nil, fmt.Errorf("AssemblyAI streaming requires AUDIO_SAMPLE_RATE")
+	}
+
+	query := url.Values{}
+	query.Set("sample_rate", strconv.Itoa(config.Format.SampleRate))
+	query.Set("encoding", encoding)
+	query.Set("format_turns", strconv.FormatBool(config.Options.SmartFormat))
+	if config.Model != "" {
+		query.Set("speech_model", config.Model)
+	}
+	if config.Options.EndpointingMs > 0 {
+		query.Set("min_end_of_turn_silence_when_confident", strconv.Itoa(config.Options.EndpointingMs))
+	}
+	if config.Options.UtteranceEndMs > 0 {
+		query.Set("max_turn_silence", strconv.Itoa(config.Options.UtteranceEndMs))
+	}
+	if terms := plainTerms(config.Options); len(terms) > 0 {
+		keyterms, err := json.Marshal(terms)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode AssemblyAI keyterms: %w", err)
+		}
+		query.Set("keyterms_prompt", string(keyterms))
+	}
+
+	// e.g. the EU endpoint for data residency
+	endpoint := CurrentConfig().AssemblyAIStreamingURL
+	if endpoint == "" {
+		endpoint = assemblyAIStreamingURL
+	}
+
+	bytesPerSecond := float64(config.Format.SampleRate * bytesPerSample)
+	return &AssemblyAIClient{
+		sttStream: newSTTStream(STT_PROVIDER_ASSEMBLYAI, config),
+		apiKey:    apiKey,
+		url:       endpoint + "?" + query.Encode(),
+		minChunk:  int(bytesPerSecond * assemblyAIMinChunk),
+		maxChunk:  int(bytesPerSecond * assemblyAIMaxChunk),
+	}, nil
+}
+
+func (c *AssemblyAIClient) Connect() bool {
+	header := http.Header{}
+	header.Set("Authorization", c.apiKey)
+	return c.dial(c.url, header, c.handleMessage)
+}
+
+func (c *AssemblyAIClient) handleMessage(messageType int, data []byte) {
+	if messageType != websocket.TextMessage {
+		return
+	}
+	var message assemblyAIMessage
+	if err := json.Unmarshal(data, &message); err != nil {
+		zap.L().Warn("Invalid AssemblyAI message", zap.Error(err))
+		return
+	}
+
+	switch message.Type {
+	case "Begin":
+		zap.L().Info("AssemblyAI stream opened")
+	case "Turn":
+		c.handleTurn(message)
+	case "Termination":
+		zap.L().Info("AssemblyAI stream terminated", zap.Float64("audio_seconds", message.AudioDuration))
+		c.markDisconnected()
+	default:
+		if message.Error != "" {
+			zap.L().Error("AssemblyAI stream error", zap.String("error", message.Error))
+			return
+		}
+		zap.L().Debug("Unhandled AssemblyAI message", zap.String("type", message.Type))
+	}
+}
+
+func (c *AssemblyAIClient) handleTurn(message assemblyAIMessage) {
+	if !message.EndOfTurn {
+		if c.config.Options.InterimResults {
+			zap.L().Debug("Interim transcript", zap.String("transcript", strings.TrimSpace(message.Transcript)))
+		}
+		return
+	}
+	// With formatting on, the unformatted end of turn is followed by the
+	// formatted one; only the latter is delivered
+	if c.config.Options.SmartFormat && !message.TurnIsFormatted {
+		return
+	}
+
+	confidence := -1.0
+	if len(message.Words) > 0 {
+		confidence = 0
+		for _, word := range message.Words {
+			confidence += word.Confidence
+		}
+		confidence /= float64(len(message.Words))
+	}
+	c.emit(message.Transcript, confidence)
+	if c.endsSpeech() {
+		c.config.TranscriptionCh <- END_OF_SPEECH
+	}
+}
+
+// Send queues audio and writes it in chunks of 50ms to 1s.
+func (c *AssemblyAIClient) Send(data []byte) error {
+	if !c.IsConnected() {
+		return fmt.Errorf("assemblyai stream not connected")
+	}
+
+	c.pendingMu.Lock()
+	defer c.pendingMu.Unlock()
+	c.pending = append(c.pending, data...)
+	for len(c.pending) >= c.minChunk {
+		size := min(len(c.pending), c.maxChunk)
+		if err := c.write(websocket.BinaryMessage, c.pending[:size]); err != nil {
+			return err
+		}
+		c.pending = c.pending[size:]
+	}
+	return nil
+}
+
+// Finalize writes the queued audio, padded with silence to the minimum
+// chunk, and forces the end of the current turn.
+func (c *AssemblyAIClient) Finalize() error {
+	c.pendingMu.Lock()
+	defer c.pendingMu.Unlock()
+	if len(c.pending) > 0 {
+		chunk := append(c.pending, make([]byte, max(c.minChunk-len(c.pending), 0))...)
+		if err := c.write(websocket.BinaryMessage, chunk); err != nil {
+			return err
+		}
+		c.pending = nil
+	}
+	return c.write(websocket.TextMessage, []byte(`{"type":"ForceEndpoint"}`))
+}
+
+func (c *AssemblyAIClient) Close() {
+	c.close(func() error {
+		return c.write(websocket.TextMessage, []byte(`{"type":"Terminate"}`))
+	})
+}
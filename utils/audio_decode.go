@@ -0,0 +1,187 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// AudioEncodingOpus is an Ogg/Opus stream, as written by libopusenc,
+	// GStreamer's oggmux or ffmpeg -f ogg. Bare Opus packets carry no framing
+	// and cannot be decoded from a byte stream.
+	AudioEncodingOpus = "opus"
+	// AudioEncodingAAC is an ADTS AAC stream.
+	AudioEncodingAAC = "aac"
+)
+
+var ErrAudioDecode = errors.New("audio decode failed")
+
+// ffmpegInputFormats maps compressed encodings to ffmpeg demuxers.
+var ffmpegInputFormats = map[string]string{
+	AudioEncodingOpus: "ogg",
+	AudioEncodingAAC:  "aac",
+}
+
+// audioSampleRates are the sample rates robots may stream audio at.
+var audioSampleRates = map[int]bool{
+	8000: true, 16000: true, 22050: true, 24000: true, 32000: true, 44100: true, 48000: true,
+}
+
+// ValidAudioSampleRate reports whether robots may stream audio at hz.
+func ValidAudioSampleRate(hz int) bool {
+	return audioSampleRates[hz]
+}
+
+// IsCompressedEncoding reports whether the server must decode an encoding to
+// PCM before it is sent to Deepgram.
+func IsCompressedEncoding(encoding string) bool {
+	_, ok := ffmpegInputFormats[encoding]
+	return ok
+}
+
+// AudioDecoder decodes a compressed audio stream into mono linear16 PCM,
+// handing decoded audio to a sink as it becomes available.
+type AudioDecoder interface {
+	Write(chunk []byte) error
+	Close() error
+}
+
+// FFmpegAudioDecoder streams compressed audio through a long-running ffmpeg
+// process. If ffmpeg exits on corrupt input, the next Write starts a new
+// process; ADTS streams resume immediately, Ogg streams once the client
+// restarts the stream with its headers.
+type FFmpegAudioDecoder struct {
+	inputFormat string
+	sampleRate  int
+	sink        func(pcm []byte)
+
+	mu     sync.Mutex
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stderr *stderrTail
+	exited chan struct{}
+	closed bool
+}
+
+// NewFFmpegAudioDecoder decodes the given encoding to PCM at sampleRate. The
+// sink runs on the decoder's reader goroutine with chunks of whole samples.
+func NewFFmpegAudioDecoder(encoding string, sampleRate int, sink func(pcm []byte)) (*FFmpegAudioDecoder, error) {
+	inputFormat, ok := ffmpegInputFormats[encoding]
+	if !ok {
+		return nil, fmt.Errorf("unsupported audio encoding %q", encoding)
+	}
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		return nil, fmt.Errorf("decoding %s audio requires ffmpeg: %w", encoding, err)
+	}
+	return &FFmpegAudioDecoder{inputFormat: inputFormat, sampleRate: sampleRate, sink: sink}, nil
+}
+
+// start launches ffmpeg. Called with d.mu held.
+func (d *FFmpegAudioDecoder) start() error {
+	cmd := exec.Command("ffmpeg",
+		"-hide_banner", "-loglevel", "error",
+		"-fflags", "nobuffer", "-probesize", "32", "-analyzeduration", "0",
+		"-f", d.inputFormat, "-i", "pipe:0",
+		"-ac", "1", "-ar", strconv.Itoa(d.sampleRate),
+		"-f", "s16le", "-acodec", "pcm_s16le", "-flush_packets", "1",
+		"pipe:1")
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("failed to open ffmpeg input: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to open ffmpeg output: %w", err)
+	}
+	stderr := &stderrTail{}
+	cmd.Stderr = stderr
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start ffmpeg: %w", err)
+	}
+
+	exited := make(chan struct{})
+	d.cmd, d.stdin, d.stderr, d.exited = cmd, stdin, stderr, exited
+	go d.read(cmd, stdout, exited)
+	return nil
+}
+
+func (d *FFmpegAudioDecoder) read(cmd *exec.Cmd, stdout io.Reader, exited chan struct{}) {
+	defer close(exited)
+	buf := make([]byte, 8192)
+	var carry []byte
+	for {
+		n, err := stdout.Read(buf)
+		if n > 0 {
+			pcm := append(carry, buf[:n]...)
+			carry = nil
+			if len(pcm)%2 == 1 {
+				carry = []byte{pcm[len(pcm)-1]}
+				pcm = pcm[:len(pcm)-1]
+			}
+			if len(pcm) > 0 {
+				d.sink(pcm)
+			}
+		}
+		if err != nil {
+			cmd.Wait()
+			return
+		}
+	}
+}
+
+// Write feeds a compressed chunk to ffmpeg.
+func (d *FFmpegAudioDecoder) Write(chunk []byte) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return fmt.Errorf("%w: decoder closed", ErrAudioDecode)
+	}
+
+	if d.cmd != nil {
+		select {
+		case <-d.exited:
+			// ffmpeg gave up on the previous input; report it once and restart
+			err := fmt.Errorf("%w: ffmpeg exited: %s", ErrAudioDecode, d.stderr.String())
+			d.cmd = nil
+			return err
+		default:
+		}
+	}
+	if d.cmd == nil {
+		if err := d.start(); err != nil {
+			return fmt.Errorf("%w: %v", ErrAudioDecode, err)
+		}
+	}
+
+	if _, err := d.stdin.Write(chunk); err != nil {
+		return fmt.Errorf("%w: %v", ErrAudioDecode, err)
+	}
+	return nil
+}
+
+// Close ends the input, letting ffmpeg flush the remaining audio to the sink,
+// and kills ffmpeg if it does not exit within two seconds. The sink must not
+// wait on anything held by the caller of Close.
+func (d *FFmpegAudioDecoder) Close() error {
+	d.mu.Lock()
+	d.closed = true
+	cmd, stdin, exited := d.cmd, d.stdin, d.exited
+	d.mu.Unlock()
+
+	if cmd == nil {
+		return nil
+	}
+	stdin.Close()
+	select {
+	case <-exited:
+	case <-time.After(2 * time.Second):
+		cmd.Process.Kill()
+		<-exited
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+package utils
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+const AudioEncodingLinear16 = "linear16"
+
+// minPreprocessSampleRate is the lowest rate with samples in a 10ms frame.
+const minPreprocessSampleRate = 100
+
+// AudioFormat describes raw audio sent to Deepgram. The zero value lets
+// Deepgram detect containerized audio such as webm/opus.
+type AudioFormat struct {
+	Encoding   string
+	SampleRate int
+}
+
+// AudioFormatFromConfig reads AUDIO_ENCODING and AUDIO_SAMPLE_RATE.
+func AudioFormatFromConfig(cfg *Config) AudioFormat {
+	format := AudioFormat{Encoding: cfg.AudioEncoding}
+	if format.Encoding != "" {
+		format.SampleRate = cfg.AudioSampleRate
+	}
+	return format
+}
+
+// AudioPreprocessor denoises and normalizes 16-bit little-endian mono PCM
+// before it is streamed to STT. It chains a high-pass filter that removes
+// motor rumble, a noise gate that tracks the background noise floor and
+// attenuates frames close to it, and an automatic gain control that brings
+// speech to a target level. State carries over between chunks, so one
+// preprocessor must be used per stream.
+type AudioPreprocessor struct {
+	frameSize int
+
+	// High-pass biquad coefficients and state
+	b0, b1, b2, a1, a2 float64
+	x1, x2, y1, y2     float64
+
+	noiseFloor      float64
+	gateThreshold   float64
+	gateAttenuation float64
+	gateGain        float64
+
+	targetRMS float64
+	maxGain   float64
+	gain      float64
+
+	carry []byte
+}
+
+// NewAudioPreprocessor configures the stage from AUDIO_HIGHPASS_HZ,
+// AUDIO_NOISE_GATE_DB, AUDIO_AGC_TARGET_DBFS and AUDIO_AGC_MAX_GAIN_DB. A
+// zero rate means 16 kHz; rates too low for 10ms frames are an error.
+func NewAudioPreprocessor(cfg *Config, sampleRate int) (*AudioPreprocessor, error) {
+	if sampleRate == 0 {
+		sampleRate = 16000
+	}
+	if sampleRate < minPreprocessSampleRate {
+		return nil, fmt.Errorf("unsupported sample rate for preprocessing: %d Hz", sampleRate)
+	}
+	p := &AudioPreprocessor{
+		frameSize:       sampleRate / 100, // 10ms
+		gateThreshold:   dbToLinear(cfg.AudioNoiseGateDB),
+		gateAttenuation: dbToLinear(-20),
+		gateGain:        1,
+		targetRMS:       dbToLinear(cfg.AudioAGCTargetDBFS),
+		maxGain:         dbToLinear(cfg.AudioAGCMaxGainDB),
+		gain:            1,
+	}
+	p.setHighPass(cfg.AudioHighpassHz, float64(sampleRate))
+	return p, nil
+}
+
+// setHighPass computes a second-order Butterworth high-pass filter.
+func (p *AudioPreprocessor) setHighPass(cutoff, sampleRate float64) {
+	if cutoff <= 0 {
+		p.b0 = 1
+		return
+	}
+	w0 := 2 * math.Pi * cutoff / sampleRate
+	alpha := math.Sin(w0) / math.Sqrt2 // Q = 1/sqrt(2)
+	cos := math.Cos(w0)
+	a0 := 1 + alpha
+	p.b0 = (1 + cos) / 2 / a0
+	p.b1 = -(1 + cos) / a0
+	p.b2 = (1 + cos) / 2 / a0
+	p.a1 = -2 * cos / a0
+	p.a2 = (1 - alpha) / a0
+}
+
+// Process returns the preprocessed audio. A trailing odd byte is kept for the
+// next chunk, so the output may be one byte shorter than the input.
+func (p *AudioPreprocessor) Process(data []byte) []byte {
+	if len(p.carry) > 0 {
+		data = append(p.carry, data...)
+		p.carry = nil
+	}
+	if len(data)%2 == 1 {
+		p.carry = []byte{data[len(data)-1]}
+		data = data[:len(data)-1]
+	}
+
+	samples := make([]float64, len(data)/2)
+	for i := range samples {
+		samples[i] = float64(int16(binary.LittleEndian.Uint16(data[2*i:]))) / 32768
+	}
+
+	for start := 0; start < len(samples); start += p.frameSize {
+		end := start + p.frameSize
+		if end > len(samples) {
+			end = len(samples)
+		}
+		p.processFrame(samples[start:end])
+	}
+
+	out := make([]byte, len(data))
+	for i, sample := range samples {
+		binary.LittleEndian.PutUint16(out[2*i:], uint16(int16(math.Round(sample*32767))))
+	}
+	return out
+}
+
+func (p *AudioPreprocessor) processFrame(frame []float64) {
+	var energy float64
+	for i, x := range frame {
+		y := p.b0*x + p.b1*p.x1 + p.b2*p.x2 - p.a1*p.y1 - p.a2*p.y2
+		p.x2, p.x1 = p.x1, x
+		p.y2, p.y1 = p.y1, y
+		frame[i] = y
+		energy += y * y
+	}
+	rms := math.Sqrt(energy / float64(len(frame)))
+
+	// Track the noise floor: follow drops quickly, rises slowly so speech
+	// does not pull it up
+	switch {
+	case p.noiseFloor == 0:
+		p.noiseFloor = rms
+	case rms < p.noiseFloor:
+		p.noiseFloor = 0.9*p.noiseFloor + 0.1*rms
+	default:
+		p.noiseFloor = 0.999*p.noiseFloor + 0.001*rms
+	}
+
+	speech := rms > p.noiseFloor*p.gateThreshold
+	gateTarget := p.gateAttenuation
+	if speech {
+		gateTarget = 1
+		// Adapt gain on speech only, attacking fast and releasing slowly
+		if rms > 0 {
+			desired := math.Min(math.Max(p.targetRMS/rms, 1/p.maxGain), p.maxGain)
+			rate := 0.05
+			if desired < p.gain {
+				rate = 0.5
+			}
+			p.gain += (desired - p.gain) * rate
+		}
+	}
+
+	step := (gateTarget - p.gateGain) / float64(len(frame))
+	for i, y := range frame {
+		p.gateGain += step
+		out := y * p.gain * p.gateGain
+		frame[i] = math.Max(-1, math.Min(1, out))
+	}
+}
+
+func dbToLinear(db float64) float64 {
+	return math.Pow(10, db/20)
+}
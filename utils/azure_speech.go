@@ -0,0 +1,296 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+const azureSpeechURL = "wss://%s.stt.speech.microsoft.com/speech/recognition/conversation/cognitiveservices/v1"
+
+// Segmentation silence the service uses when Endpointing does not set one
+const azureDefaultSegmentationMs = 500
+
+// AzureSpeechClient streams audio to Azure Speech continuous recognition
+// over its WebSocket protocol, the one the Speech SDKs use. Hypotheses are
+// interim results and phrases final segments. Endpointing sets the
+// segmentation silence that ends a phrase; Azure has no utterance end
+// event, so it is emulated with scheduleEndOfSpeech.
+type AzureSpeechClient struct {
+	sttStream
+	key       string
+	url       string
+	requestID string
+
+	// The first audio message carries the WAV header describing the format
+	headerSent bool
+	wavHeader  []byte
+}
+
+type azurePhrase struct {
+	RecognitionStatus string `json:"RecognitionStatus"`
+	DisplayText       string `json:"DisplayText"`
+	NBest             []struct {
+		Confidence float64 `json:"Confidence"`
+		Lexical    string  `json:"Lexical"`
+		Display    string  `json:"Display"`
+	} `json:"NBest"`
+}
+
+func NewAzureSpeechClient(key, region string, config STTStreamConfig) (*AzureSpeechClient, error) {
+	if key == "" || region == "" {
+		return nil, fmt.Errorf("Azure Speech key and region not configured")
+	}
+
+	var formatTag, bitsPerSample uint16
+	switch config.Format.Encoding {
+	case AudioEncodingLinear16:
+		formatTag, bitsPerSample = 1, 16
+	case "mulaw":
+		formatTag, bitsPerSample = 7, 8
+	default:
+		return nil, fmt.Errorf("Azure Speech streaming requires linear16 or mulaw audio, got %q", config.Format.Encoding)
+	}
+	if config.Format.SampleRate == 0 {
+		return nil, fmt.Errorf("Azure Speech streaming requires AUDIO_SAMPLE_RATE")
+	}
+
+	language := config.Language
+	if language == "" || language == "en" {
+		language = "en-US"
+	}
+	query := url.Values{}
+	query.Set("language", language)
+	query.Set("format", "detailed")
+	if config.Options.EndpointingMs > 0 {
+		// Azure accepts 100-5000ms
+		query.Set("segmentationSilenceTimeoutMs", strconv.Itoa(min(max(config.Options.EndpointingMs, 100), 5000)))
+	}
+
+	// Sovereign clouds and on-premises containers use their own endpoint
+	endpoint := CurrentConfig().AzureSpeechEndpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf(azureSpeechURL, region)
+	}
+
+	return &AzureSpeechClient{
+		sttStream: newSTTStream(STT_PROVIDER_AZURE, config),
+		key:       key,
+		url:       endpoint + "?" + query.Encode(),
+		requestID: azureID(),
+		wavHeader: wavHeader(formatTag, bitsPerSample, uint32(config.Format.SampleRate)),
+	}, nil
+}
+
+func (c *AzureSpeechClient) Connect() bool {
+	header := http.Header{}
+	header.Set("Ocp-Apim-Subscription-Key", c.key)
+	header.Set("X-ConnectionId", azureID())
+	if !c.dial(c.url, header, c.handleMessage) {
+		return false
+	}
+
+	speechConfig := map[string]interface{}{
+		"context": map[string]interface{}{
+			"system": map[string]string{"name": "perceptus", "version": Version, "build": "Go", "lang": "Go"},
+			"os":     map[string]string{"platform": "Linux", "name": "perceptus", "version": Version},
+			"audio": map[string]interface{}{
+				"source": map[string]string{"connectivity": "Unknown", "manufacturer": "Perceptus", "model": "robot", "type": "Stream"},
+			},
+		},
+	}
+	if err := c.sendJSON("speech.config", speechConfig); err != nil {
+		zap.L().Error("Failed to configure Azure Speech stream", zap.Error(err))
+		c.Close()
+		return false
+	}
+
+	// Phrase lists bias recognition like Deepgram keyterms
+	if terms := plainTerms(c.config.Options); len(terms) > 0 {
+		items := make([]map[string]string, len(terms))
+		for i, term := range terms {
+			items[i] = map[string]string{"Text": term}
+		}
+		phraseList := map[string]interface{}{
+			"dgi": map[string]interface{}{
+				"Groups": []map[string]interface{}{{"Type": "Generic", "Items": items}},
+			},
+		}
+		if err := c.sendJSON("speech.context", phraseList); err != nil {
+			zap.L().Error("Failed to send Azure Speech phrase list", zap.Error(err))
+			c.Close()
+			return false
+		}
+	}
+	return true
+}
+
+func (c *AzureSpeechClient) sendJSON(path string, body interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	headers := c.headers(path, "application/json")
+	return c.write(websocket.TextMessage, append([]byte(headers+"\r\n"), data...))
+}
+
+// headers formats the message headers of the Speech protocol, each line
+// terminated by CRLF.
+func (c *AzureSpeechClient) headers(path, contentType string) string {
+	return fmt.Sprintf("Path: %s\r\nX-RequestId: %s\r\nX-Timestamp: %s\r\nContent-Type: %s\r\n",
+		path, c.requestID, time.Now().UTC().Format("2006-01-02T15:04:05.000Z"), contentType)
+}
+
+// Send wraps the audio in a binary audio message: a big-endian header
+// length, the headers and the samples.
+func (c *AzureSpeechClient) Send(data []byte) error {
+	if !c.IsConnected() {
+		return fmt.Errorf("azure stream not connected")
+	}
+	if !c.headerSent {
+		data = append(append([]byte(nil), c.wavHeader...), data...)
+	}
+	if err := c.sendAudio(data); err != nil {
+		return err
+	}
+	c.headerSent = true
+	return nil
+}
+
+func (c *AzureSpeechClient) sendAudio(data []byte) error {
+	headers := c.headers("audio", "audio/x-wav")
+	message := make([]byte, 2, 2+len(headers)+len(data))
+	binary.BigEndian.PutUint16(message, uint16(len(headers)))
+	message = append(message, headers...)
+	message = append(message, data...)
+	return c.write(websocket.BinaryMessage, message)
+}
+
+func (c *AzureSpeechClient) handleMessage(messageType int, data []byte) {
+	if messageType != websocket.TextMessage {
+		return
+	}
+	head, body, _ := bytes.Cut(data, []byte("\r\n\r\n"))
+	var path string
+	for _, line := range strings.Split(string(head), "\r\n") {
+		if name, value, ok := strings.Cut(line, ":"); ok && strings.EqualFold(name, "Path") {
+			path = strings.ToLower(strings.TrimSpace(value))
+		}
+	}
+
+	switch path {
+	case "speech.hypothesis":
+		// The speaker continues, so the utterance has not ended
+		c.cancelEndOfSpeech()
+		if c.config.Options.InterimResults {
+			var hypothesis struct {
+				Text string `json:"Text"`
+			}
+			if json.Unmarshal(body, &hypothesis) == nil {
+				zap.L().Debug("Interim transcript", zap.String("transcript", hypothesis.Text))
+			}
+		}
+	case "speech.phrase":
+		c.handlePhrase(body)
+	case "turn.end":
+		// The service ends a turn after long silences or its session limit;
+		// the stream has to be reopened
+		zap.L().Info("Azure Speech turn ended")
+		c.markDisconnected()
+	case "speech.startdetected", "speech.enddetected", "turn.start":
+		zap.L().Debug("Azure Speech event", zap.String("path", path))
+	default:
+		zap.L().Debug("Unhandled Azure Speech message", zap.String("path", path))
+	}
+}
+
+func (c *AzureSpeechClient) handlePhrase(body []byte) {
+	var phrase azurePhrase
+	if err := json.Unmarshal(body, &phrase); err != nil {
+		zap.L().Warn("Invalid Azure Speech phrase", zap.Error(err))
+		return
+	}
+	switch phrase.RecognitionStatus {
+	case "Success":
+	case "NoMatch", "InitialSilenceTimeout", "BabbleTimeout":
+		zap.L().Debug("Azure Speech phrase not recognized", zap.String("status", phrase.RecognitionStatus))
+		return
+	default:
+		zap.L().Warn("Azure Speech recognition failed", zap.String("status", phrase.RecognitionStatus))
+		return
+	}
+
+	transcript, confidence := phrase.DisplayText, -1.0
+	if len(phrase.NBest) > 0 {
+		best := phrase.NBest[0]
+		confidence = best.Confidence
+		transcript = best.Lexical
+		if c.config.Options.SmartFormat {
+			transcript = best.Display
+		}
+	}
+	c.emit(transcript, confidence)
+	c.scheduleEndOfSpeech()
+}
+
+// Finalize sends enough silence for segmentation to end the phrase; the
+// protocol has no flush message short of ending the stream.
+func (c *AzureSpeechClient) Finalize() error {
+	silence := c.config.Options.EndpointingMs
+	if silence <= 0 {
+		silence = azureDefaultSegmentationMs
+	}
+	bytesPerSecond := c.config.Format.SampleRate * int(binary.LittleEndian.Uint16(c.wavHeader[32:34]))
+	return c.Send(silenceOf(c.config.Format.Encoding, bytesPerSecond*(silence+100)/1000))
+}
+
+func (c *AzureSpeechClient) Close() {
+	c.cancelEndOfSpeech()
+	// An empty audio message ends the stream
+	c.close(func() error { return c.sendAudio(nil) })
+}
+
+// silenceOf returns size bytes of silence in the encoding.
+func silenceOf(encoding string, size int) []byte {
+	silence := make([]byte, size)
+	if encoding == "mulaw" {
+		// Zero amplitude is 0xFF in mu-law
+		for i := range silence {
+			silence[i] = 0xFF
+		}
+	}
+	return silence
+}
+
+func azureID() string {
+	return strings.ReplaceAll(uuid.New().String(), "-", "")
+}
+
+// wavHeader describes a mono stream of unknown length.
+func wavHeader(formatTag, bitsPerSample uint16, sampleRate uint32) []byte {
+	blockAlign := bitsPerSample / 8
+	header := new(bytes.Buffer)
+	header.WriteString("RIFF")
+	binary.Write(header, binary.LittleEndian, uint32(0))
+	header.WriteString("WAVEfmt ")
+	binary.Write(header, binary.LittleEndian, uint32(16))
+	binary.Write(header, binary.LittleEndian, formatTag)
+	binary.Write(header, binary.LittleEndian, uint16(1))
+	binary.Write(header, binary.LittleEndian, sampleRate)
+	binary.Write(header, binary.LittleEndian, sampleRate*uint32(blockAlign))
+	binary.Write(header, binary.LittleEndian, blockAlign)
+	binary.Write(header, binary.LittleEndian, bitsPerSample)
+	header.WriteString("data")
+	binary.Write(header, binary.LittleEndian, uint32(0))
+	return header.Bytes()
+}
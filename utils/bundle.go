@@ -0,0 +1,219 @@
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
+)
+
+const (
+	bundleFormatVersion = 1
+	bundleManifestName  = "manifest.json"
+	bundleSignatureName = "manifest.sig"
+	// maxBundleContentSize caps the uncompressed size of a bundle's files
+	maxBundleContentSize = 1 << 30
+)
+
+// BundleManifest lists the files of a session bundle and their checksums.
+type BundleManifest struct {
+	Version    int               `json:"version"`
+	SessionID  string            `json:"session_id"`
+	TenantID   string            `json:"tenant_id"`
+	ExportedAt time.Time         `json:"exported_at"`
+	Files      map[string]string `json:"files"` // name -> sha256
+}
+
+// SessionBundle is the decoded content of an exported session.
+type SessionBundle struct {
+	Manifest BundleManifest
+	Meta     models.SessionMeta
+	Records  []models.AnalysisRecord
+}
+
+func bundleSigningKey() ([]byte, error) {
+	key := CurrentConfig().ExportSigningKey
+	if key == "" {
+		return nil, fmt.Errorf("EXPORT_SIGNING_KEY environment variable not set")
+	}
+	return []byte(key), nil
+}
+
+func signBundle(key, manifest []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(manifest)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// WriteSessionBundle writes a signed zip containing the session description,
+// transcripts, intentions, environment contexts and optionally the frames.
+func WriteSessionBundle(w io.Writer, meta models.SessionMeta, records []models.AnalysisRecord, includeMedia bool) error {
+	key, err := bundleSigningKey()
+	if err != nil {
+		return err
+	}
+
+	files := map[string][]byte{}
+	sessionJSON, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal session: %w", err)
+	}
+	files["session.json"] = sessionJSON
+
+	var transcripts, intentions, contexts bytes.Buffer
+	for _, record := range records {
+		switch record.Kind {
+		case models.ANALYSIS_KIND_INTENTION:
+			line, _ := json.Marshal(map[string]interface{}{
+				"id":         record.ID,
+				"transcript": record.Transcript,
+				"timestamp":  record.Timestamp,
+			})
+			transcripts.Write(append(line, '\n'))
+			intentions.Write(append(mustJSON(record), '\n'))
+		case models.ANALYSIS_KIND_VISION:
+			if includeMedia && record.ImageData != "" {
+				if image, err := decodeDataURL(record.ImageData); err == nil {
+					files["media/"+record.ID+".jpg"] = image
+				}
+			}
+			record.ImageData = ""
+			contexts.Write(append(mustJSON(record), '\n'))
+		}
+	}
+	files["transcripts.jsonl"] = transcripts.Bytes()
+	files["intentions.jsonl"] = intentions.Bytes()
+	files["environment_contexts.jsonl"] = contexts.Bytes()
+
+	manifest := BundleManifest{
+		Version:    bundleFormatVersion,
+		SessionID:  meta.ID,
+		TenantID:   meta.TenantID,
+		ExportedAt: time.Now(),
+		Files:      make(map[string]string, len(files)),
+	}
+	for name, content := range files {
+		sum := sha256.Sum256(content)
+		manifest.Files[name] = hex.EncodeToString(sum[:])
+	}
+	manifestJSON, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+	files[bundleManifestName] = manifestJSON
+	files[bundleSignatureName] = []byte(signBundle(key, manifestJSON))
+
+	zw := zip.NewWriter(w)
+	for name, content := range files {
+		f, err := zw.Create(name)
+		if err != nil {
+			return fmt.Errorf("failed to add %s to bundle: %w", name, err)
+		}
+		if _, err := f.Write(content); err != nil {
+			return fmt.Errorf("failed to write %s to bundle: %w", name, err)
+		}
+	}
+	return zw.Close()
+}
+
+// ReadSessionBundle verifies the bundle signature and checksums and decodes it.
+// Frames stored under media/ are re-attached to their vision records.
+func ReadSessionBundle(data []byte) (*SessionBundle, error) {
+	key, err := bundleSigningKey()
+	if err != nil {
+		return nil, err
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid bundle archive: %w", err)
+	}
+
+	// Sizes in the zip headers are not trusted: every file is read through
+	// a limit of what is left of maxBundleContentSize
+	files := make(map[string][]byte, len(zr.File))
+	remaining := int64(maxBundleContentSize)
+	for _, f := range zr.File {
+		if f.UncompressedSize64 > uint64(remaining) {
+			return nil, fmt.Errorf("bundle content exceeds %d bytes", maxBundleContentSize)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s: %w", f.Name, err)
+		}
+		content, err := io.ReadAll(io.LimitReader(rc, remaining+1))
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", f.Name, err)
+		}
+		if int64(len(content)) > remaining {
+			return nil, fmt.Errorf("bundle content exceeds %d bytes", maxBundleContentSize)
+		}
+		remaining -= int64(len(content))
+		files[f.Name] = content
+	}
+
+	manifestJSON, ok := files[bundleManifestName]
+	if !ok {
+		return nil, fmt.Errorf("bundle has no manifest")
+	}
+	expected := signBundle(key, manifestJSON)
+	if !hmac.Equal([]byte(expected), bytes.TrimSpace(files[bundleSignatureName])) {
+		return nil, fmt.Errorf("bundle signature mismatch")
+	}
+
+	bundle := &SessionBundle{}
+	if err := json.Unmarshal(manifestJSON, &bundle.Manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+	if bundle.Manifest.Version != bundleFormatVersion {
+		return nil, fmt.Errorf("unsupported bundle version %d", bundle.Manifest.Version)
+	}
+	for name, checksum := range bundle.Manifest.Files {
+		sum := sha256.Sum256(files[name])
+		if hex.EncodeToString(sum[:]) != checksum {
+			return nil, fmt.Errorf("checksum mismatch for %s", name)
+		}
+	}
+
+	if err := json.Unmarshal(files["session.json"], &bundle.Meta); err != nil {
+		return nil, fmt.Errorf("invalid session.json: %w", err)
+	}
+	for _, name := range []string{"intentions.jsonl", "environment_contexts.jsonl"} {
+		for _, line := range bytes.Split(files[name], []byte("\n")) {
+			if len(bytes.TrimSpace(line)) == 0 {
+				continue
+			}
+			var record models.AnalysisRecord
+			if err := json.Unmarshal(line, &record); err != nil {
+				return nil, fmt.Errorf("invalid record in %s: %w", name, err)
+			}
+			if image, ok := files["media/"+record.ID+".jpg"]; ok {
+				record.ImageData = "data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(image)
+			}
+			bundle.Records = append(bundle.Records, record)
+		}
+	}
+	return bundle, nil
+}
+
+func mustJSON(v interface{}) []byte {
+	data, _ := json.Marshal(v)
+	return data
+}
+
+func decodeDataURL(dataURL string) ([]byte, error) {
+	if idx := strings.Index(dataURL, ","); idx >= 0 && strings.HasPrefix(dataURL, "data:") {
+		dataURL = dataURL[idx+1:]
+	}
+	return base64.StdEncoding.DecodeString(dataURL)
+}
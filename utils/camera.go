@@ -0,0 +1,643 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	neturl "net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// CameraCapture grabs single JPEG frames from a camera source.
+type CameraCapture interface {
+	CaptureFrame(ctx context.Context) ([]byte, error)
+	Close() error
+}
+
+// Camera backends selectable with CAMERA_BACKEND. gocv (OpenCV) is only
+// compiled in with -tags gocv; auto picks it when it is.
+const (
+	CAMERA_BACKEND_AUTO   = "auto"
+	CAMERA_BACKEND_GOCV   = "gocv"
+	CAMERA_BACKEND_FFMPEG = "ffmpeg"
+)
+
+// Camera is a capture whose settings can be adjusted.
+type Camera interface {
+	CameraCapture
+	AdjustableCamera
+}
+
+// CameraDevice is a local camera found by ListCameras. ID is what
+// OpenDeviceCamera and the device setting take.
+type CameraDevice struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// CameraBackend returns the backend cameras are opened with.
+func CameraBackend() string {
+	backend := strings.ToLower(strings.TrimSpace(CurrentConfig().CameraBackend))
+	if backend == "" || backend == CAMERA_BACKEND_AUTO {
+		if gocvAvailable {
+			return CAMERA_BACKEND_GOCV
+		}
+		return CAMERA_BACKEND_FFMPEG
+	}
+	return backend
+}
+
+// OpenStreamCamera opens an RTSP (H.264/H.265) or HTTP MJPEG stream with the
+// configured backend, falling back to ffmpeg when gocv cannot open it. The
+// URL is checked with ValidateStreamURL first.
+func OpenStreamCamera(ctx context.Context, url string) (Camera, error) {
+	if err := ValidateStreamURL(ctx, url); err != nil {
+		return nil, err
+	}
+	if CameraBackend() == CAMERA_BACKEND_GOCV {
+		camera, err := NewGoCVStreamCapture(url)
+		if err == nil {
+			return camera, nil
+		}
+		zap.L().Warn("gocv capture failed, falling back to ffmpeg", zap.String("url", url), zap.Error(err))
+	}
+	return NewStreamCapture(url)
+}
+
+// OpenDeviceCamera opens a local camera, given as for ResolveCameraDevice,
+// with the configured backend, falling back to ffmpeg when gocv cannot
+// open it.
+func OpenDeviceCamera(ctx context.Context, device string) (Camera, error) {
+	device, err := ResolveCameraDevice(ctx, device)
+	if err != nil {
+		return nil, err
+	}
+	if CameraBackend() == CAMERA_BACKEND_GOCV {
+		camera, err := NewGoCVDeviceCapture(device)
+		if err == nil {
+			return camera, nil
+		}
+		zap.L().Warn("gocv capture failed, falling back to ffmpeg", zap.String("device", device), zap.Error(err))
+	}
+	return NewDeviceCapture(device)
+}
+
+// streamProtocols are the ffmpeg protocols a stream of each scheme may
+// use, so a stream cannot point ffmpeg at local files or other protocols.
+var streamProtocols = map[string]string{
+	"rtsp":  "rtsp,rtp,tcp,udp",
+	"rtsps": "rtsps,rtsp,rtp,tcp,udp,tls",
+	"http":  "http,tcp",
+	"https": "https,http,tcp,tls",
+}
+
+// streamScheme returns the lowercase scheme of a stream URL.
+func streamScheme(url string) string {
+	scheme, _, found := strings.Cut(url, "://")
+	if !found {
+		return ""
+	}
+	return strings.ToLower(scheme)
+}
+
+// ValidateStreamURL checks a stream URL sent by a client: it must be
+// RTSP(S) or HTTP(S), and its host must not resolve to a loopback,
+// private, link-local or otherwise internal address, unless the address is
+// in RTSP_ALLOWED_NETWORKS (comma-separated CIDRs, for cameras on the
+// server's LAN).
+func ValidateStreamURL(ctx context.Context, url string) error {
+	parsed, err := neturl.Parse(url)
+	if err != nil {
+		return fmt.Errorf("invalid stream url: %w", err)
+	}
+	if _, ok := streamProtocols[strings.ToLower(parsed.Scheme)]; !ok {
+		return fmt.Errorf("unsupported stream url scheme: %q", parsed.Scheme)
+	}
+	host := parsed.Hostname()
+	if host == "" {
+		return fmt.Errorf("stream url has no host")
+	}
+	allowed, err := allowedStreamNetworks()
+	if err != nil {
+		return err
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return fmt.Errorf("failed to resolve stream host %q: %w", host, err)
+	}
+	for _, addr := range addrs {
+		if !internalAddress(addr.IP) {
+			continue
+		}
+		permitted := false
+		for _, network := range allowed {
+			permitted = permitted || network.Contains(addr.IP)
+		}
+		if !permitted {
+			return fmt.Errorf("stream host %q resolves to internal address %s", host, addr.IP)
+		}
+	}
+	return nil
+}
+
+// allowedStreamNetworks parses RTSP_ALLOWED_NETWORKS.
+func allowedStreamNetworks() ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, cidr := range splitTerms(CurrentConfig().RTSPAllowedNetworks) {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RTSP_ALLOWED_NETWORKS entry %q: %w", cidr, err)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// internalAddress reports whether an address is not reachable on the
+// public internet.
+func internalAddress(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() ||
+		ip.IsMulticast() || ip.IsUnspecified()
+}
+
+// ListCameras enumerates the local cameras: the devices gocv can open, or
+// those the operating system reports.
+func ListCameras(ctx context.Context) ([]CameraDevice, error) {
+	if CameraBackend() == CAMERA_BACKEND_GOCV {
+		if devices, err := listGoCVCameras(); err == nil {
+			return devices, nil
+		}
+	}
+	return listSystemCameras(ctx)
+}
+
+// ResolveCameraDevice maps a camera given by ID, name (case-insensitive)
+// or, on Windows, position in the device list to its ID. An empty device
+// is the platform default; DirectShow has none, so on Windows it is the
+// first camera found. Devices that cannot be listed are used as given.
+func ResolveCameraDevice(ctx context.Context, device string) (string, error) {
+	if device == "" && runtime.GOOS != "windows" {
+		return "", nil
+	}
+	devices, err := listSystemCameras(ctx)
+	if err != nil {
+		if device == "" {
+			return "", fmt.Errorf("failed to find a camera: %w", err)
+		}
+		return device, nil
+	}
+	if device == "" {
+		if len(devices) == 0 {
+			return "", fmt.Errorf("no camera found")
+		}
+		return devices[0].ID, nil
+	}
+
+	for _, candidate := range devices {
+		if candidate.ID == device {
+			return candidate.ID, nil
+		}
+	}
+	for _, candidate := range devices {
+		if strings.EqualFold(candidate.Name, device) {
+			return candidate.ID, nil
+		}
+	}
+	if index, err := strconv.Atoi(device); err == nil && runtime.GOOS == "windows" && index >= 0 && index < len(devices) {
+		return devices[index].ID, nil
+	}
+	return "", fmt.Errorf("camera %q not found", device)
+}
+
+// SelectCameraDevice switches a camera to another local device, given as
+// for ResolveCameraDevice.
+func SelectCameraDevice(ctx context.Context, camera AdjustableCamera, device string) (CameraSettings, error) {
+	if device == "" {
+		return camera.CameraSettings(), fmt.Errorf("a camera device is required")
+	}
+	id, err := ResolveCameraDevice(ctx, device)
+	if err != nil {
+		return camera.CameraSettings(), err
+	}
+	return camera.ApplyCameraSettings(ctx, CameraSettings{Device: id})
+}
+
+// listSystemCameras lists the cameras the operating system reports: v4l2
+// devices on Linux, ffmpeg's device list on macOS and Windows.
+func listSystemCameras(ctx context.Context) ([]CameraDevice, error) {
+	switch runtime.GOOS {
+	case "linux":
+		paths, err := filepath.Glob("/dev/video*")
+		if err != nil {
+			return nil, err
+		}
+		devices := make([]CameraDevice, 0, len(paths))
+		for _, path := range paths {
+			device := CameraDevice{ID: path, Name: path}
+			if name, err := os.ReadFile(filepath.Join("/sys/class/video4linux", filepath.Base(path), "name")); err == nil {
+				device.Name = strings.TrimSpace(string(name))
+			}
+			devices = append(devices, device)
+		}
+		return devices, nil
+	case "darwin":
+		return listFFmpegDevices(ctx, []string{"-f", "avfoundation", "-list_devices", "true", "-i", ""}, avfoundationDevicePattern)
+	case "windows":
+		return listFFmpegDevices(ctx, []string{"-f", "dshow", "-list_devices", "true", "-i", "dummy"}, dshowDevicePattern)
+	}
+	return nil, fmt.Errorf("camera enumeration not supported on %s", runtime.GOOS)
+}
+
+var (
+	// [AVFoundation indev @ 0x...] [0] FaceTime HD Camera
+	avfoundationDevicePattern = regexp.MustCompile(`\] \[(\d+)\] (.+)$`)
+	// [dshow @ 0x...] "Integrated Camera" (video)
+	dshowDevicePattern = regexp.MustCompile(`\] "(.+)" \(video\)$`)
+)
+
+// listFFmpegDevices parses the video devices ffmpeg lists on stderr. The
+// listing run always exits with an error.
+func listFFmpegDevices(ctx context.Context, args []string, pattern *regexp.Regexp) ([]CameraDevice, error) {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "ffmpeg", append([]string{"-hide_banner"}, args...)...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil && stderr.Len() == 0 {
+		return nil, fmt.Errorf("ffmpeg device listing failed: %w", err)
+	}
+
+	var devices []CameraDevice
+	for _, line := range strings.Split(stderr.String(), "\n") {
+		// AVFoundation lists audio devices after the video ones
+		if strings.Contains(line, "audio devices") {
+			break
+		}
+		match := pattern.FindStringSubmatch(strings.TrimSpace(line))
+		if match == nil {
+			continue
+		}
+		if len(match) == 3 {
+			devices = append(devices, CameraDevice{ID: match[1], Name: match[2]})
+		} else {
+			devices = append(devices, CameraDevice{ID: match[1], Name: match[1]})
+		}
+	}
+	return devices, nil
+}
+
+// AdjustableCamera is a capture whose parameters can be read and changed
+// while it runs.
+type AdjustableCamera interface {
+	CameraSettings() CameraSettings
+	ApplyCameraSettings(ctx context.Context, update CameraSettings) (CameraSettings, error)
+}
+
+// ErrCameraSettingUnsupported is returned for settings a camera source
+// cannot apply, such as exposure on an RTSP stream.
+var ErrCameraSettingUnsupported = errors.New("camera setting not supported by this source")
+
+// CameraSettings are the adjustable parameters of a camera. In an update,
+// zero values leave a parameter unchanged; in a reported state they mean
+// the camera's default.
+type CameraSettings struct {
+	// Width and Height are the frame resolution in pixels
+	Width  int `json:"width,omitempty"`
+	Height int `json:"height,omitempty"`
+	// ExposureMS fixes the exposure time; AutoExposure hands it back to
+	// the camera
+	ExposureMS   float64 `json:"exposure_ms,omitempty"`
+	AutoExposure bool    `json:"auto_exposure,omitempty"`
+	// Zoom is a digital zoom factor of 1 or more, centered on ROI
+	Zoom float64 `json:"zoom,omitempty"`
+	// ROI is the normalized region of the sensor image that is captured
+	ROI *CropRect `json:"roi,omitempty"`
+	// Device selects a local camera (v4l2 path, avfoundation index or
+	// dshow name)
+	Device string `json:"device,omitempty"`
+}
+
+const (
+	maxCameraWidth      = 7680
+	maxCameraHeight     = 4320
+	maxCameraExposureMS = 10000
+	maxCameraZoom       = 16
+)
+
+// Validate rejects out-of-range values and clamps ROI to the frame.
+func (s CameraSettings) Validate() (CameraSettings, error) {
+	if (s.Width == 0) != (s.Height == 0) {
+		return s, fmt.Errorf("width and height must be set together")
+	}
+	if s.Width < 0 || s.Height < 0 || s.Width > maxCameraWidth || s.Height > maxCameraHeight {
+		return s, fmt.Errorf("resolution must be at most %dx%d", maxCameraWidth, maxCameraHeight)
+	}
+	if s.ExposureMS < 0 || s.ExposureMS > maxCameraExposureMS {
+		return s, fmt.Errorf("exposure_ms must be between 0 and %d", maxCameraExposureMS)
+	}
+	if s.ExposureMS > 0 && s.AutoExposure {
+		return s, fmt.Errorf("exposure_ms and auto_exposure are exclusive")
+	}
+	if s.Zoom != 0 && (s.Zoom < 1 || s.Zoom > maxCameraZoom) {
+		return s, fmt.Errorf("zoom must be between 1 and %d", maxCameraZoom)
+	}
+	if s.ROI != nil {
+		roi, err := s.ROI.Validate()
+		if err != nil {
+			return s, fmt.Errorf("invalid roi: %w", err)
+		}
+		s.ROI = &roi
+	}
+	return s, nil
+}
+
+// Merge returns the settings with the parameters set in update replaced. A
+// zoom of 1 and a full-frame ROI reset those parameters.
+func (s CameraSettings) Merge(update CameraSettings) CameraSettings {
+	if update.Width > 0 {
+		s.Width, s.Height = update.Width, update.Height
+	}
+	if update.ExposureMS > 0 {
+		s.ExposureMS, s.AutoExposure = update.ExposureMS, false
+	}
+	if update.AutoExposure {
+		s.ExposureMS, s.AutoExposure = 0, false
+	}
+	if update.Zoom == 1 {
+		s.Zoom = 0
+	} else if update.Zoom > 0 {
+		s.Zoom = update.Zoom
+	}
+	if update.ROI != nil {
+		s.ROI = update.ROI
+		if update.ROI.isFull() {
+			s.ROI = nil
+		}
+	}
+	if update.Device != "" {
+		s.Device = update.Device
+	}
+	return s
+}
+
+// crop returns the region captured after ROI and zoom, and whether it is
+// smaller than the frame.
+func (s CameraSettings) crop() (CropRect, bool) {
+	region := FullFrame
+	if s.ROI != nil {
+		region = *s.ROI
+	}
+	if s.Zoom > 1 {
+		width, height := region.Width/s.Zoom, region.Height/s.Zoom
+		region = CropRect{
+			X:      region.X + (region.Width-width)/2,
+			Y:      region.Y + (region.Height-height)/2,
+			Width:  width,
+			Height: height,
+		}
+	}
+	return region, !region.isFull()
+}
+
+// ParseResolution parses "1280x720".
+func ParseResolution(value string) (int, int, error) {
+	w, h, ok := strings.Cut(strings.ToLower(strings.TrimSpace(value)), "x")
+	width, errW := strconv.Atoi(w)
+	height, errH := strconv.Atoi(h)
+	if !ok || errW != nil || errH != nil || width <= 0 || height <= 0 {
+		return 0, 0, fmt.Errorf("resolution must be WIDTHxHEIGHT, e.g. 1280x720")
+	}
+	return width, height, nil
+}
+
+// FFmpegCapture captures frames by running ffmpeg once per frame. It is the
+// fallback when the build or the source does not support gocv. Settings
+// apply from the next frame: resolution, zoom and ROI through ffmpeg
+// filters (and the capture size of local devices), exposure through
+// v4l2-ctl on Linux devices.
+type FFmpegCapture struct {
+	// url is set for streams, otherwise the capture reads a local device
+	url string
+
+	mu        sync.Mutex
+	settings  CameraSettings
+	inputArgs []string
+}
+
+// NewRTSPCapture returns a capture pulling frames from an RTSP(S) stream.
+func NewRTSPCapture(url string) (*FFmpegCapture, error) {
+	scheme := streamScheme(url)
+	if scheme != "rtsp" && scheme != "rtsps" {
+		return nil, fmt.Errorf("unsupported RTSP url scheme: %q", url)
+	}
+	return &FFmpegCapture{
+		url:       url,
+		inputArgs: []string{"-protocol_whitelist", streamProtocols[scheme], "-rtsp_transport", "tcp", "-i", url},
+	}, nil
+}
+
+// NewStreamCapture returns a capture pulling frames from an RTSP(S) stream
+// or an HTTP(S) MJPEG stream.
+func NewStreamCapture(url string) (*FFmpegCapture, error) {
+	if scheme := streamScheme(url); scheme == "http" || scheme == "https" {
+		return &FFmpegCapture{url: url, inputArgs: []string{"-protocol_whitelist", streamProtocols[scheme], "-i", url}}, nil
+	}
+	return NewRTSPCapture(url)
+}
+
+// NewDeviceCapture returns a capture reading a local camera: a v4l2 device
+// on Linux (default /dev/video0), an AVFoundation index on macOS (default 0)
+// or a DirectShow device name on Windows.
+func NewDeviceCapture(device string) (*FFmpegCapture, error) {
+	inputArgs, err := localDeviceArgs("video", device)
+	if err != nil {
+		return nil, err
+	}
+	return &FFmpegCapture{settings: CameraSettings{Device: device}, inputArgs: inputArgs}, nil
+}
+
+// localDeviceArgs returns the ffmpeg input arguments of a local "video" or
+// "audio" device for the current platform.
+func localDeviceArgs(kind, device string) ([]string, error) {
+	switch runtime.GOOS {
+	case "linux":
+		if kind == "video" {
+			if device == "" {
+				device = "/dev/video0"
+			}
+			return []string{"-f", "v4l2", "-i", device}, nil
+		}
+		if device == "" {
+			device = "default"
+		}
+		return []string{"-f", "pulse", "-i", device}, nil
+	case "darwin":
+		if device == "" {
+			device = "0"
+		}
+		if kind == "video" {
+			return []string{"-f", "avfoundation", "-framerate", "30", "-i", device + ":none"}, nil
+		}
+		return []string{"-f", "avfoundation", "-i", "none:" + device}, nil
+	case "windows":
+		if device == "" {
+			return nil, fmt.Errorf("a DirectShow %s device name is required on windows, see ListCameras", kind)
+		}
+		return []string{"-f", "dshow", "-i", kind + "=" + device}, nil
+	}
+	return nil, fmt.Errorf("local %s capture not supported on %s", kind, runtime.GOOS)
+}
+
+// CameraSettings returns the settings applied to the capture.
+func (c *FFmpegCapture) CameraSettings() CameraSettings {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.settings
+}
+
+// ApplyCameraSettings merges update into the capture's settings and returns
+// the result. Nothing changes when a setting cannot be applied.
+func (c *FFmpegCapture) ApplyCameraSettings(ctx context.Context, update CameraSettings) (CameraSettings, error) {
+	update, err := update.Validate()
+	if err != nil {
+		return c.CameraSettings(), err
+	}
+	if c.url == "" && update.Device != "" {
+		if update.Device, err = ResolveCameraDevice(ctx, update.Device); err != nil {
+			return c.CameraSettings(), err
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.url != "" {
+		if err := checkStreamSettings(update); err != nil {
+			return c.settings, err
+		}
+	}
+
+	settings := c.settings.Merge(update)
+	inputArgs := c.inputArgs
+	if c.url == "" && (update.Device != "" || update.Width > 0) {
+		if inputArgs, err = localDeviceArgs("video", settings.Device); err != nil {
+			return c.settings, err
+		}
+		if settings.Width > 0 {
+			inputArgs = withInputOption(inputArgs, "-video_size", fmt.Sprintf("%dx%d", settings.Width, settings.Height))
+		}
+	}
+	if update.ExposureMS > 0 || update.AutoExposure {
+		if err := setDeviceExposure(ctx, settings); err != nil {
+			return c.settings, err
+		}
+	}
+
+	c.settings, c.inputArgs = settings, inputArgs
+	return c.settings, nil
+}
+
+// checkStreamSettings rejects the settings only local devices support.
+func checkStreamSettings(update CameraSettings) error {
+	if update.Device != "" {
+		return fmt.Errorf("%w: device (set rtsp_url to switch streams)", ErrCameraSettingUnsupported)
+	}
+	if update.ExposureMS > 0 || update.AutoExposure {
+		return fmt.Errorf("%w: exposure", ErrCameraSettingUnsupported)
+	}
+	return nil
+}
+
+// withInputOption adds an option for the input that follows it.
+func withInputOption(args []string, name, value string) []string {
+	for i, arg := range args {
+		if arg == "-i" {
+			withOption := append([]string{}, args[:i]...)
+			withOption = append(withOption, name, value)
+			return append(withOption, args[i:]...)
+		}
+	}
+	return args
+}
+
+// setDeviceExposure sets the exposure of a v4l2 device with v4l2-ctl,
+// trying the control names of current kernels before the legacy ones.
+// Exposure times are in units of 100µs.
+func setDeviceExposure(ctx context.Context, settings CameraSettings) error {
+	if runtime.GOOS != "linux" {
+		return fmt.Errorf("%w: exposure on %s", ErrCameraSettingUnsupported, runtime.GOOS)
+	}
+	device := settings.Device
+	if device == "" {
+		device = "/dev/video0"
+	}
+
+	// 1 is manual exposure, 3 aperture priority (automatic exposure time)
+	controls := [][2]string{{"auto_exposure", "exposure_time_absolute"}, {"exposure_auto", "exposure_absolute"}}
+	var lastErr error
+	for _, names := range controls {
+		ctrl := names[0] + "=3"
+		if settings.ExposureMS > 0 {
+			ctrl = fmt.Sprintf("%s=1,%s=%d", names[0], names[1], int(settings.ExposureMS*10))
+		}
+		var stderr bytes.Buffer
+		cmd := exec.CommandContext(ctx, "v4l2-ctl", "-d", device, "--set-ctrl="+ctrl)
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			lastErr = fmt.Errorf("v4l2-ctl failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+			continue
+		}
+		return nil
+	}
+	return fmt.Errorf("failed to set exposure: %w", lastErr)
+}
+
+// filters returns the ffmpeg video filters applying the settings.
+func (s CameraSettings) filters() string {
+	var filters []string
+	if region, cropped := s.crop(); cropped {
+		filters = append(filters, fmt.Sprintf("crop=iw*%.4f:ih*%.4f:iw*%.4f:ih*%.4f",
+			region.Width, region.Height, region.X, region.Y))
+	}
+	if s.Width > 0 {
+		filters = append(filters, fmt.Sprintf("scale=%d:%d", s.Width, s.Height))
+	}
+	return strings.Join(filters, ",")
+}
+
+func (c *FFmpegCapture) CaptureFrame(ctx context.Context) ([]byte, error) {
+	c.mu.Lock()
+	args := append([]string{"-hide_banner", "-loglevel", "error"}, c.inputArgs...)
+	if filters := c.settings.filters(); filters != "" {
+		args = append(args, "-vf", filters)
+	}
+	c.mu.Unlock()
+	args = append(args, "-frames:v", "1", "-f", "image2", "-vcodec", "mjpeg", "pipe:1")
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("ffmpeg capture failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	if stdout.Len() == 0 {
+		return nil, fmt.Errorf("ffmpeg produced no frame")
+	}
+	return stdout.Bytes(), nil
+}
+
+func (c *FFmpegCapture) Close() error {
+	return nil
+}
@@ -0,0 +1,365 @@
+//go:build gocv
+
+package utils
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"gocv.io/x/gocv"
+)
+
+const gocvAvailable = true
+
+const (
+	// gocvReopenDelay is how long the reader waits before reopening a
+	// source that failed or stopped delivering frames
+	gocvReopenDelay = time.Second
+	// gocvMaxDeviceIndex bounds the device indexes probed by ListCameras
+	gocvMaxDeviceIndex = 10
+)
+
+// GoCVCapture reads a camera or stream continuously with OpenCV and keeps
+// the latest frame, so CaptureFrame returns a current frame without
+// starting a process and waiting for a keyframe. Resolution and exposure of
+// local devices are set on the device, which is reopened to apply them;
+// zoom and ROI crop the frame, and streams are scaled to the resolution.
+type GoCVCapture struct {
+	// url is set for streams, otherwise the capture reads a local device
+	url string
+
+	mu       sync.Mutex
+	settings CameraSettings
+	// autoExposure hands exposure back to the device on the next open
+	autoExposure bool
+	// reopen asks the reader to reopen the source; reopened receives the
+	// result when set
+	reopen   bool
+	reopened chan error
+	latest   gocv.Mat
+	// frameReady is closed and replaced when a frame arrives
+	frameReady chan struct{}
+	lastErr    error
+	closed     bool
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewGoCVStreamCapture opens an RTSP or HTTP MJPEG stream with OpenCV's
+// FFmpeg backend.
+func NewGoCVStreamCapture(url string) (Camera, error) {
+	if _, ok := streamProtocols[streamScheme(url)]; !ok {
+		return nil, fmt.Errorf("unsupported stream url scheme: %q", url)
+	}
+	capture, err := startGoCVCapture(url, CameraSettings{})
+	if err != nil {
+		return nil, err
+	}
+	return capture, nil
+}
+
+// NewGoCVDeviceCapture opens a local camera by index (default 0) or, on
+// Linux, by v4l2 device path.
+func NewGoCVDeviceCapture(device string) (Camera, error) {
+	capture, err := startGoCVCapture("", CameraSettings{Device: device})
+	if err != nil {
+		return nil, err
+	}
+	return capture, nil
+}
+
+func startGoCVCapture(url string, settings CameraSettings) (*GoCVCapture, error) {
+	capture, err := openGoCV(url, settings, false)
+	if err != nil {
+		return nil, err
+	}
+	c := &GoCVCapture{
+		url:        url,
+		settings:   settings,
+		latest:     gocv.NewMat(),
+		frameReady: make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	go c.run(capture)
+	return c, nil
+}
+
+// openGoCV opens a source and applies the device settings.
+func openGoCV(url string, settings CameraSettings, autoExposure bool) (*gocv.VideoCapture, error) {
+	var capture *gocv.VideoCapture
+	var err error
+	switch {
+	case url != "":
+		capture, err = gocv.OpenVideoCaptureWithAPI(url, gocv.VideoCaptureFFmpeg)
+	case strings.HasPrefix(settings.Device, "/dev/"):
+		capture, err = gocv.OpenVideoCaptureWithAPI(settings.Device, gocv.VideoCaptureV4L2)
+	default:
+		index := 0
+		if settings.Device != "" {
+			if index, err = strconv.Atoi(settings.Device); err != nil {
+				return nil, fmt.Errorf("gocv opens cameras by index or v4l2 path, not %q", settings.Device)
+			}
+		}
+		capture, err = gocv.OpenVideoCapture(index)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open camera: %w", err)
+	}
+	if !capture.IsOpened() {
+		capture.Close()
+		return nil, fmt.Errorf("failed to open camera")
+	}
+
+	// Only the newest frame is of interest
+	capture.Set(gocv.VideoCaptureBufferSize, 1)
+	if url != "" {
+		return capture, nil
+	}
+	if settings.Width > 0 {
+		// USB cameras deliver high resolutions only as MJPEG
+		capture.Set(gocv.VideoCaptureFOURCC, float64(capture.ToCodec("MJPG")))
+		capture.Set(gocv.VideoCaptureFrameWidth, float64(settings.Width))
+		capture.Set(gocv.VideoCaptureFrameHeight, float64(settings.Height))
+		width, height := int(capture.Get(gocv.VideoCaptureFrameWidth)), int(capture.Get(gocv.VideoCaptureFrameHeight))
+		if width != settings.Width || height != settings.Height {
+			zap.L().Info("Camera does not support the resolution, scaling frames",
+				zap.Int("width", width), zap.Int("height", height))
+		}
+	}
+	// Exposure modes and units are V4L2's: 1 is manual, 3 aperture
+	// priority, exposure times in 100µs
+	if settings.ExposureMS > 0 {
+		capture.Set(gocv.VideoCaptureAutoExposure, 1)
+		capture.Set(gocv.VideoCaptureExposure, settings.ExposureMS*10)
+	} else if autoExposure {
+		capture.Set(gocv.VideoCaptureAutoExposure, 3)
+	}
+	return capture, nil
+}
+
+// run reads frames until the capture is closed, reopening the source when
+// it fails or the settings ask for it.
+func (c *GoCVCapture) run(capture *gocv.VideoCapture) {
+	frame := gocv.NewMat()
+	defer func() {
+		if capture != nil {
+			capture.Close()
+		}
+		frame.Close()
+		c.mu.Lock()
+		c.closed = true
+		c.latest.Close()
+		c.mu.Unlock()
+	}()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		default:
+		}
+
+		c.mu.Lock()
+		reopen, reopened := c.reopen, c.reopened
+		settings, autoExposure := c.settings, c.autoExposure
+		c.reopen, c.reopened = false, nil
+		c.mu.Unlock()
+
+		if reopen || capture == nil {
+			if capture != nil {
+				capture.Close()
+				capture = nil
+			}
+			var err error
+			capture, err = openGoCV(c.url, settings, autoExposure)
+			if reopened != nil {
+				reopened <- err
+			}
+			if err != nil {
+				c.setError(err)
+				if !c.wait(gocvReopenDelay) {
+					return
+				}
+				continue
+			}
+			c.mu.Lock()
+			c.autoExposure = false
+			c.mu.Unlock()
+		}
+
+		if !capture.Read(&frame) || frame.Empty() {
+			c.setError(fmt.Errorf("camera stopped delivering frames"))
+			capture.Close()
+			capture = nil
+			if !c.wait(gocvReopenDelay) {
+				return
+			}
+			continue
+		}
+
+		c.mu.Lock()
+		frame.CopyTo(&c.latest)
+		c.lastErr = nil
+		close(c.frameReady)
+		c.frameReady = make(chan struct{})
+		c.mu.Unlock()
+	}
+}
+
+func (c *GoCVCapture) setError(err error) {
+	c.mu.Lock()
+	c.lastErr = err
+	c.mu.Unlock()
+}
+
+// wait sleeps unless the capture is closed meanwhile.
+func (c *GoCVCapture) wait(d time.Duration) bool {
+	select {
+	case <-c.done:
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
+
+// CaptureFrame waits for the next frame and returns it as JPEG, cropped and
+// scaled to the settings.
+func (c *GoCVCapture) CaptureFrame(ctx context.Context) ([]byte, error) {
+	c.mu.Lock()
+	ready := c.frameReady
+	c.mu.Unlock()
+
+	select {
+	case <-ready:
+	case <-c.done:
+		return nil, fmt.Errorf("camera closed")
+	case <-ctx.Done():
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.lastErr != nil {
+			return nil, fmt.Errorf("gocv capture failed: %w", c.lastErr)
+		}
+		return nil, fmt.Errorf("no frame from camera: %w", ctx.Err())
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed || c.latest.Empty() {
+		return nil, fmt.Errorf("camera closed")
+	}
+
+	img := c.latest
+	if region, cropped := c.settings.crop(); cropped {
+		cols, rows := float64(img.Cols()), float64(img.Rows())
+		roi := img.Region(image.Rect(
+			int(region.X*cols), int(region.Y*rows),
+			int((region.X+region.Width)*cols), int((region.Y+region.Height)*rows)))
+		defer roi.Close()
+		img = roi
+	}
+	if c.settings.Width > 0 && (img.Cols() != c.settings.Width || img.Rows() != c.settings.Height) {
+		scaled := gocv.NewMat()
+		defer scaled.Close()
+		gocv.Resize(img, &scaled, image.Pt(c.settings.Width, c.settings.Height), 0, 0, gocv.InterpolationArea)
+		img = scaled
+	}
+
+	buf, err := gocv.IMEncode(gocv.JPEGFileExt, img)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode frame: %w", err)
+	}
+	defer buf.Close()
+	return append([]byte(nil), buf.GetBytes()...), nil
+}
+
+// CameraSettings returns the settings applied to the capture.
+func (c *GoCVCapture) CameraSettings() CameraSettings {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.settings
+}
+
+// ApplyCameraSettings merges update into the capture's settings and returns
+// the result. Device changes, resolution and exposure reopen local devices;
+// when that fails the previous settings are restored.
+func (c *GoCVCapture) ApplyCameraSettings(ctx context.Context, update CameraSettings) (CameraSettings, error) {
+	update, err := update.Validate()
+	if err != nil {
+		return c.CameraSettings(), err
+	}
+
+	c.mu.Lock()
+	previous := c.settings
+	if c.closed {
+		c.mu.Unlock()
+		return previous, fmt.Errorf("camera closed")
+	}
+	if c.url != "" {
+		if err := checkStreamSettings(update); err != nil {
+			c.mu.Unlock()
+			return previous, err
+		}
+	}
+	c.settings = previous.Merge(update)
+	if c.url != "" || (update.Device == "" && update.Width == 0 && update.ExposureMS == 0 && !update.AutoExposure) {
+		settings := c.settings
+		c.mu.Unlock()
+		return settings, nil
+	}
+	if update.AutoExposure {
+		c.autoExposure = true
+	}
+	reopened := make(chan error, 1)
+	c.reopen, c.reopened = true, reopened
+	c.mu.Unlock()
+
+	select {
+	case err = <-reopened:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	if err != nil {
+		c.mu.Lock()
+		c.settings, c.reopen, c.reopened = previous, true, nil
+		c.mu.Unlock()
+		return previous, fmt.Errorf("failed to apply camera settings: %w", err)
+	}
+	return c.CameraSettings(), nil
+}
+
+func (c *GoCVCapture) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.done)
+	})
+	return nil
+}
+
+// listGoCVCameras probes device indexes, numbered like /dev/videoN on Linux.
+func listGoCVCameras() ([]CameraDevice, error) {
+	var devices []CameraDevice
+	for index := 0; index < gocvMaxDeviceIndex; index++ {
+		capture, err := gocv.OpenVideoCapture(index)
+		if err != nil {
+			continue
+		}
+		opened := capture.IsOpened()
+		capture.Close()
+		if !opened {
+			continue
+		}
+		id := strconv.Itoa(index)
+		if runtime.GOOS == "linux" {
+			id = "/dev/video" + id
+		}
+		devices = append(devices, CameraDevice{ID: id, Name: fmt.Sprintf("camera %d", index)})
+	}
+	return devices, nil
+}
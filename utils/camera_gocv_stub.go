@@ -0,0 +1,24 @@
+//go:build !gocv
+
+package utils
+
+import "fmt"
+
+const gocvAvailable = false
+
+var errGoCVUnavailable = fmt.Errorf("built without gocv support, rebuild with -tags gocv")
+
+// NewGoCVStreamCapture fails in builds without gocv, which needs cgo and
+// OpenCV; build with -tags gocv to enable it.
+func NewGoCVStreamCapture(url string) (Camera, error) {
+	return nil, errGoCVUnavailable
+}
+
+// NewGoCVDeviceCapture fails in builds without gocv.
+func NewGoCVDeviceCapture(device string) (Camera, error) {
+	return nil, errGoCVUnavailable
+}
+
+func listGoCVCameras() ([]CameraDevice, error) {
+	return nil, errGoCVUnavailable
+}
@@ -0,0 +1,153 @@
+package utils
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Clock is the source of time for the session pipeline. Production code uses
+// SystemClock; tests inject a ManualClock to drive time-based behavior such as
+// frame sampling, transcript flush windows and staleness checks.
+type Clock interface {
+	Now() time.Time
+	Since(t time.Time) time.Duration
+	After(d time.Duration) <-chan time.Time
+	NewTicker(d time.Duration) Ticker
+}
+
+// Ticker is the subset of time.Ticker used by the pipeline.
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// IDGenerator creates unique IDs for sessions, intentions and other records.
+type IDGenerator interface {
+	NewID() string
+}
+
+// SystemClock is the wall clock.
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time                         { return time.Now() }
+func (SystemClock) Since(t time.Time) time.Duration        { return time.Since(t) }
+func (SystemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+func (SystemClock) NewTicker(d time.Duration) Ticker       { return systemTicker{time.NewTicker(d)} }
+
+type systemTicker struct{ *time.Ticker }
+
+func (t systemTicker) C() <-chan time.Time { return t.Ticker.C }
+
+// UUIDGenerator returns random UUIDs.
+type UUIDGenerator struct{}
+
+func (UUIDGenerator) NewID() string { return uuid.New().String() }
+
+// ManualClock only moves when advanced. Timers and tickers fire during
+// Advance once their deadline is reached.
+type ManualClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []*manualTimer
+}
+
+type manualTimer struct {
+	deadline time.Time
+	period   time.Duration // 0 for one-shot timers
+	ch       chan time.Time
+	stopped  bool
+}
+
+func NewManualClock(start time.Time) *ManualClock {
+	return &ManualClock{now: start}
+}
+
+func (c *ManualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *ManualClock) Since(t time.Time) time.Duration {
+	return c.Now().Sub(t)
+}
+
+func (c *ManualClock) After(d time.Duration) <-chan time.Time {
+	return c.addTimer(d, 0).ch
+}
+
+func (c *ManualClock) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("non-positive interval for NewTicker")
+	}
+	return &manualTicker{clock: c, timer: c.addTimer(d, d)}
+}
+
+func (c *ManualClock) addTimer(d, period time.Duration) *manualTimer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	timer := &manualTimer{deadline: c.now.Add(d), period: period, ch: make(chan time.Time, 1)}
+	c.waiters = append(c.waiters, timer)
+	return timer
+}
+
+// Advance moves the clock forward and fires every due timer and ticker. Like
+// time.Ticker, a ticker whose reader is behind drops ticks.
+func (c *ManualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+	pending := c.waiters[:0]
+	for _, timer := range c.waiters {
+		if timer.stopped {
+			continue
+		}
+		for !timer.deadline.After(c.now) {
+			select {
+			case timer.ch <- timer.deadline:
+			default:
+			}
+			if timer.period == 0 {
+				timer.stopped = true
+				break
+			}
+			timer.deadline = timer.deadline.Add(timer.period)
+		}
+		if !timer.stopped {
+			pending = append(pending, timer)
+		}
+	}
+	c.waiters = pending
+}
+
+type manualTicker struct {
+	clock *ManualClock
+	timer *manualTimer
+}
+
+func (t *manualTicker) C() <-chan time.Time { return t.timer.ch }
+
+func (t *manualTicker) Stop() {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	t.timer.stopped = true
+}
+
+// SequentialIDs returns "<prefix>-1", "<prefix>-2", ... for reproducible IDs.
+type SequentialIDs struct {
+	Prefix string
+
+	mu   sync.Mutex
+	next int
+}
+
+func (g *SequentialIDs) NewID() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.next++
+	return fmt.Sprintf("%s-%d", g.Prefix, g.next)
+}
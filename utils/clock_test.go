@@ -0,0 +1,43 @@
+package utils_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+)
+
+func TestManualClockFiresDueTimers(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := utils.NewManualClock(start)
+	after := clock.After(10 * time.Second)
+	ticker := clock.NewTicker(4 * time.Second)
+	defer ticker.Stop()
+
+	clock.Advance(5 * time.Second)
+	select {
+	case <-after:
+		t.Fatal("After fired before its deadline")
+	default:
+	}
+	if tick := <-ticker.C(); !tick.Equal(start.Add(4 * time.Second)) {
+		t.Errorf("tick = %s, want %s", tick, start.Add(4*time.Second))
+	}
+
+	clock.Advance(5 * time.Second)
+	if fired := <-after; !fired.Equal(start.Add(10 * time.Second)) {
+		t.Errorf("After fired at %s, want %s", fired, start.Add(10*time.Second))
+	}
+	if got := clock.Since(start); got != 10*time.Second {
+		t.Errorf("Since = %s, want 10s", got)
+	}
+}
+
+func TestSequentialIDs(t *testing.T) {
+	ids := &utils.SequentialIDs{Prefix: "session"}
+	for _, want := range []string{"session-1", "session-2", "session-3"} {
+		if got := ids.NewID(); got != want {
+			t.Errorf("NewID = %s, want %s", got, want)
+		}
+	}
+}
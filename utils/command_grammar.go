@@ -0,0 +1,147 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultCommandRules are the fast-path commands used unless
+// COMMAND_GRAMMAR_FILE points to a YAML list of models.CommandRule.
+var DefaultCommandRules = []models.CommandRule{
+	{
+		IntentionType: "stop",
+		Description:   "Stop all motion immediately",
+		Patterns:      []string{`(emergency )?stop( (it|now|moving|that|right now))?`, `halt`, `freeze`},
+	},
+	{
+		IntentionType: "pause",
+		Description:   "Pause the current task",
+		Patterns:      []string{`pause( (it|now|that))?`, `wait( a (second|moment|minute))?`, `hold on`},
+	},
+	{
+		IntentionType: "navigation",
+		Description:   "Come to the speaker",
+		Patterns:      []string{`come (here|over here|to me)`},
+		Slots:         map[string]interface{}{"target_location": "user"},
+	},
+}
+
+// CommandGrammar matches utterances against compiled command rules.
+type CommandGrammar struct {
+	rules    []models.CommandRule
+	patterns [][]*regexp.Regexp
+}
+
+// NewCommandGrammar compiles the rules; patterns are anchored so only whole
+// utterances match.
+func NewCommandGrammar(rules []models.CommandRule) (*CommandGrammar, error) {
+	grammar := &CommandGrammar{rules: rules, patterns: make([][]*regexp.Regexp, len(rules))}
+	for i, rule := range rules {
+		if rule.IntentionType == "" {
+			return nil, fmt.Errorf("command rule without intention_type")
+		}
+		if len(rule.Patterns) == 0 {
+			return nil, fmt.Errorf("command rule %s has no patterns", rule.IntentionType)
+		}
+		for _, pattern := range rule.Patterns {
+			re, err := regexp.Compile(`^(?:` + pattern + `)$`)
+			if err != nil {
+				return nil, fmt.Errorf("command rule %s: invalid pattern %q: %w", rule.IntentionType, pattern, err)
+			}
+			grammar.patterns[i] = append(grammar.patterns[i], re)
+		}
+	}
+	return grammar, nil
+}
+
+func LoadCommandRules(path string) ([]models.CommandRule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read command grammar file: %w", err)
+	}
+	var rules []models.CommandRule
+	if err := yaml.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("failed to parse command grammar file: %w", err)
+	}
+	return rules, nil
+}
+
+var (
+	commandGrammarOnce sync.Once
+	commandGrammar     *CommandGrammar
+)
+
+// SharedCommandGrammar returns the grammar configured by COMMAND_GRAMMAR_FILE,
+// or nil when COMMAND_GRAMMAR_ENABLED is false.
+func SharedCommandGrammar() *CommandGrammar {
+	commandGrammarOnce.Do(func() {
+		if !CurrentConfig().CommandGrammarEnabled {
+			return
+		}
+		rules := DefaultCommandRules
+		if path := CurrentConfig().CommandGrammarFile; path != "" {
+			loaded, err := LoadCommandRules(path)
+			if err != nil {
+				zap.L().Error("Failed to load command grammar, using defaults", zap.String("path", path), zap.Error(err))
+			} else {
+				rules = loaded
+			}
+		}
+		grammar, err := NewCommandGrammar(rules)
+		if err != nil {
+			zap.L().Error("Invalid command grammar, fast path disabled", zap.Error(err))
+			return
+		}
+		commandGrammar = grammar
+	})
+	return commandGrammar
+}
+
+// Match returns the rule matching the whole utterance and the slots taken
+// from the rule and the pattern's named groups.
+func (g *CommandGrammar) Match(utterance string) (*models.CommandRule, map[string]interface{}, bool) {
+	if g == nil {
+		return nil, nil, false
+	}
+	normalized := normalizeUtterance(utterance)
+	for i, patterns := range g.patterns {
+		for _, re := range patterns {
+			match := re.FindStringSubmatch(normalized)
+			if match == nil {
+				continue
+			}
+			rule := g.rules[i]
+			slots := make(map[string]interface{}, len(rule.Slots))
+			for name, value := range rule.Slots {
+				slots[name] = value
+			}
+			for j, name := range re.SubexpNames() {
+				if name != "" && match[j] != "" {
+					slots[name] = match[j]
+				}
+			}
+			return &rule, slots, true
+		}
+	}
+	return nil, nil, false
+}
+
+// normalizeUtterance lowercases, drops punctuation and collapses whitespace,
+// so "Stop!" and "  stop " both read "stop".
+func normalizeUtterance(utterance string) string {
+	cleaned := strings.Map(func(r rune) rune {
+		if unicode.IsPunct(r) {
+			return -1
+		}
+		return unicode.ToLower(r)
+	}, utterance)
+	return strings.Join(strings.Fields(cleaned), " ")
+}
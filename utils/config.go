@@ -0,0 +1,306 @@
+package utils
+
+import (
+	"compress/flate"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+// DEFAULT_CONFIG_FILE is read when CONFIG_FILE is unset and it exists.
+const DEFAULT_CONFIG_FILE = "perceptus.yaml"
+
+// ConfigFilePath returns CONFIG_FILE, or DEFAULT_CONFIG_FILE when it exists,
+// or "" when there is no config file.
+func ConfigFilePath() string {
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		return path
+	}
+	if _, err := os.Stat(DEFAULT_CONFIG_FILE); err == nil {
+		return DEFAULT_CONFIG_FILE
+	}
+	return ""
+}
+
+// LoadConfigFile reads a YAML config file into settings. A setting's name is
+// its path in the file joined with underscores and uppercased, so
+//
+//	port: 8080
+//	openai:
+//	  max_attempts: 5
+//	acoustic_events: [alarm, doorbell]
+//
+// sets PORT, OPENAI_MAX_ATTEMPTS and ACOUSTIC_EVENTS=alarm,doorbell.
+func LoadConfigFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	var root map[string]interface{}
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	settings := make(map[string]string)
+	if err := flattenConfig("", root, settings); err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+	return settings, nil
+}
+
+func flattenConfig(prefix string, node map[string]interface{}, settings map[string]string) error {
+	for key, value := range node {
+		name := strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(key))
+		if prefix != "" {
+			name = prefix + "_" + name
+		}
+		switch value := value.(type) {
+		case map[string]interface{}:
+			if err := flattenConfig(name, value, settings); err != nil {
+				return err
+			}
+		case []interface{}:
+			items := make([]string, 0, len(value))
+			for _, item := range value {
+				text, ok := configScalar(item)
+				if !ok {
+					return fmt.Errorf("%s: list items must be plain values", name)
+				}
+				items = append(items, text)
+			}
+			settings[name] = strings.Join(items, ",")
+		default:
+			text, ok := configScalar(value)
+			if !ok {
+				return fmt.Errorf("%s: unsupported value", name)
+			}
+			settings[name] = text
+		}
+	}
+	return nil
+}
+
+func configScalar(value interface{}) (string, bool) {
+	switch value := value.(type) {
+	case nil:
+		return "", true
+	case string:
+		return value, true
+	case bool:
+		return strconv.FormatBool(value), true
+	case int:
+		return strconv.Itoa(value), true
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64), true
+	case time.Time:
+		return value.Format(time.RFC3339), true
+	}
+	return "", false
+}
+
+// LoadConfig reads the settings from the config file at path (none when
+// path is empty) and the environment, including .env, which takes
+// precedence over the file. Names in the file that are not settings of the
+// server, usually typos, are returned. If the file cannot be read the
+// config is nil; invalid values are reported all at once and leave their
+// settings at the default, so a typo can fail startup instead of going
+// unnoticed.
+func LoadConfig(path string) (cfg *Config, unknown []string, err error) {
+	values := make(map[string]string)
+	if path != "" {
+		if values, err = LoadConfigFile(path); err != nil {
+			return nil, nil, err
+		}
+	}
+	cfg = DefaultConfig()
+	for name := range values {
+		if _, known := configFields()[name]; known || taskSetting(name) != "" {
+			cfg.fileSettings = append(cfg.fileSettings, name)
+		} else {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(cfg.fileSettings)
+	sort.Strings(unknown)
+	for _, entry := range os.Environ() {
+		name, value, _ := strings.Cut(entry, "=")
+		values[name] = value
+	}
+	return cfg, unknown, cfg.apply(values)
+}
+
+// apply sets the fields whose setting has a value. A string setting that is
+// present but empty clears its default; other settings treat empty as unset.
+func (c *Config) apply(values map[string]string) error {
+	var errs []error
+	fail := func(name, value string, err error) {
+		errs = append(errs, fmt.Errorf("%s=%q: %w", name, value, err))
+	}
+	target := reflect.ValueOf(c).Elem()
+	for name, index := range configFields() {
+		value, present := values[name]
+		field := target.Field(index)
+		if !present || (value == "" && field.Kind() != reflect.String) {
+			continue
+		}
+		if err := setConfigField(field, name, value); err != nil {
+			fail(name, value, err)
+		}
+	}
+	if _, present := values["WEBSOCKET_ALLOWED_ORIGINS"]; !present {
+		c.WebSocketAllowedOrigins = c.CORSAllowedOrigins
+	}
+
+	for name, value := range values {
+		if value == "" {
+			continue
+		}
+		switch prefix := taskSetting(name); prefix {
+		case modelSettingPrefix:
+			task := strings.ToLower(strings.TrimPrefix(name, prefix))
+			if _, ok := defaultTaskModels[task]; !ok {
+				fail(name, value, fmt.Errorf("unknown model task"))
+				continue
+			}
+			chain, err := ParseModelChain(value)
+			if err != nil {
+				fail(name, value, err)
+				continue
+			}
+			c.Models[task] = chain
+		case openAITimeoutSettingPrefix:
+			timeout, err := parseSettingDuration(value)
+			if err != nil {
+				fail(name, value, err)
+				continue
+			}
+			c.OpenAIRequestTimeouts[strings.ToLower(strings.TrimPrefix(name, prefix))] = timeout
+		}
+	}
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Error() < errs[j].Error() })
+	return errors.Join(errs...)
+}
+
+// settingRanges bounds the integer settings that have no meaning outside a
+// range.
+var settingRanges = map[string][2]int{
+	"PORT":                 {1, 65535},
+	"WS_COMPRESSION_LEVEL": {flate.HuffmanOnly, flate.BestCompression},
+	"WS_MAX_MESSAGE_BYTES": {1, math.MaxInt},
+}
+
+// positiveSettings are the duration settings that must be above zero, e.g.
+// the period of a ticker.
+var positiveSettings = map[string]bool{
+	"STT_KEEPALIVE_INTERVAL": true,
+}
+
+// configFields maps the settings to the index of their Config field.
+var configFields = sync.OnceValue(func() map[string]int {
+	fields := make(map[string]int)
+	config := reflect.TypeOf(Config{})
+	for i := 0; i < config.NumField(); i++ {
+		if name := config.Field(i).Tag.Get("setting"); name != "" {
+			fields[name] = i
+		}
+	}
+	return fields
+})
+
+// taskSetting returns the prefix of a setting named after a task, or "".
+func taskSetting(name string) string {
+	for _, prefix := range []string{modelSettingPrefix, openAITimeoutSettingPrefix} {
+		if strings.HasPrefix(name, prefix) && len(name) > len(prefix) {
+			return prefix
+		}
+	}
+	return ""
+}
+
+func setConfigField(field reflect.Value, name, value string) error {
+	switch field.Interface().(type) {
+	case string:
+		field.SetString(value)
+	case time.Duration:
+		d, err := parseSettingDuration(value)
+		if err != nil {
+			return err
+		}
+		if d == 0 && positiveSettings[name] {
+			return fmt.Errorf("must be positive")
+		}
+		field.SetInt(int64(d))
+	case int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("not an integer")
+		}
+		if bounds, ok := settingRanges[name]; ok && (n < bounds[0] || n > bounds[1]) {
+			return fmt.Errorf("must be between %d and %d", bounds[0], bounds[1])
+		}
+		field.SetInt(int64(n))
+	case float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("not a number")
+		}
+		field.SetFloat(f)
+	case bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("not a boolean")
+		}
+		field.SetBool(b)
+	}
+	return nil
+}
+
+func parseSettingDuration(value string) (time.Duration, error) {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("not a duration such as 30s")
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("must not be negative")
+	}
+	return d, nil
+}
+
+// FileSettings returns the names of the settings taken from the config
+// file.
+func (c *Config) FileSettings() []string {
+	return c.fileSettings
+}
+
+// currentConfig is the configuration installed by SetConfig.
+var currentConfig atomic.Pointer[Config]
+
+// SetConfig installs the configuration read by CurrentConfig, e.g. after a
+// reload. Sessions keep the configuration they started with.
+func SetConfig(cfg *Config) {
+	currentConfig.Store(cfg)
+}
+
+// CurrentConfig returns the installed configuration. Until one is installed,
+// e.g. in tools and tests, it is read from the environment on first use.
+func CurrentConfig() *Config {
+	if cfg := currentConfig.Load(); cfg != nil {
+		return cfg
+	}
+	cfg, _, err := LoadConfig("")
+	if err != nil {
+		zap.L().Warn("Invalid configuration, affected settings use their defaults", zap.Error(err))
+	}
+	currentConfig.CompareAndSwap(nil, cfg)
+	return currentConfig.Load()
+}
@@ -0,0 +1,424 @@
+package utils
+
+import (
+	"compress/flate"
+	"strings"
+	"time"
+)
+
+// Config holds the server settings, read by LoadConfig from the config file
+// and the environment. A field's tag names its setting, documented in
+// config.example.env; a setting added to the code belongs here, or config
+// files setting it are warned about. Fields are typed by the kind of their
+// setting: string, int, float64, bool or time.Duration.
+type Config struct {
+	AcousticEvents                     string        `setting:"ACOUSTIC_EVENTS"`
+	AcousticEventsEnabled              bool          `setting:"ACOUSTIC_EVENTS_ENABLED"`
+	AcousticEventAPIKey                string        `setting:"ACOUSTIC_EVENT_API_KEY"`
+	AcousticEventCooldown              time.Duration `setting:"ACOUSTIC_EVENT_COOLDOWN"`
+	AcousticEventMinScore              float64       `setting:"ACOUSTIC_EVENT_MIN_SCORE"`
+	AcousticEventNotify                string        `setting:"ACOUSTIC_EVENT_NOTIFY"`
+	AcousticEventTimeout               time.Duration `setting:"ACOUSTIC_EVENT_TIMEOUT"`
+	AcousticEventURL                   string        `setting:"ACOUSTIC_EVENT_URL"`
+	AcousticEventWindow                time.Duration `setting:"ACOUSTIC_EVENT_WINDOW"`
+	AdaptiveVideoFrequency             bool          `setting:"ADAPTIVE_VIDEO_FREQUENCY"`
+	AdminAPIKey                        string        `setting:"ADMIN_API_KEY"`
+	AdmissionMaxWait                   time.Duration `setting:"ADMISSION_MAX_WAIT"`
+	AdmissionQueueSize                 int           `setting:"ADMISSION_QUEUE_SIZE"`
+	AdmissionRetryAfter                time.Duration `setting:"ADMISSION_RETRY_AFTER"`
+	AdmissionWait                      time.Duration `setting:"ADMISSION_WAIT"`
+	APIKeys                            string        `setting:"API_KEYS"`
+	ArchiveFrames                      bool          `setting:"ARCHIVE_FRAMES"`
+	AssemblyAIAPIKey                   string        `setting:"ASSEMBLYAI_API_KEY"`
+	AssemblyAIStreamingURL             string        `setting:"ASSEMBLYAI_STREAMING_URL"`
+	AudioAGCMaxGainDB                  float64       `setting:"AUDIO_AGC_MAX_GAIN_DB"`
+	AudioAGCTargetDBFS                 float64       `setting:"AUDIO_AGC_TARGET_DBFS"`
+	AudioEncoding                      string        `setting:"AUDIO_ENCODING"`
+	AudioHighpassHz                    float64       `setting:"AUDIO_HIGHPASS_HZ"`
+	AudioNoiseGateDB                   float64       `setting:"AUDIO_NOISE_GATE_DB"`
+	AudioPreprocessing                 bool          `setting:"AUDIO_PREPROCESSING"`
+	AudioSampleRate                    int           `setting:"AUDIO_SAMPLE_RATE"`
+	AWSAccessKeyID                     string        `setting:"AWS_ACCESS_KEY_ID"`
+	AWSSecretAccessKey                 string        `setting:"AWS_SECRET_ACCESS_KEY"`
+	AWSSessionToken                    string        `setting:"AWS_SESSION_TOKEN"`
+	AzureSpeechEndpoint                string        `setting:"AZURE_SPEECH_ENDPOINT"`
+	AzureSpeechKey                     string        `setting:"AZURE_SPEECH_KEY"`
+	AzureSpeechRegion                  string        `setting:"AZURE_SPEECH_REGION"`
+	CameraBackend                      string        `setting:"CAMERA_BACKEND"`
+	CaptionMaxViewers                  int           `setting:"CAPTION_MAX_VIEWERS"`
+	CaptionTokenTTL                    time.Duration `setting:"CAPTION_TOKEN_TTL"`
+	CaptureRequests                    bool          `setting:"CAPTURE_REQUESTS"`
+	ChangeWatchesFile                  string        `setting:"CHANGE_WATCHES_FILE"`
+	CommandGrammarEnabled              bool          `setting:"COMMAND_GRAMMAR_ENABLED"`
+	CommandGrammarFile                 string        `setting:"COMMAND_GRAMMAR_FILE"`
+	CORSAllowedOrigins                 string        `setting:"CORS_ALLOWED_ORIGINS"`
+	DebugEndpointsEnabled              bool          `setting:"DEBUG_ENDPOINTS_ENABLED"`
+	DeepgramAPIKey                     string        `setting:"DEEPGRAM_API_KEY"`
+	DepthClearDistance                 float64       `setting:"DEPTH_CLEAR_DISTANCE"`
+	DepthMaxRange                      float64       `setting:"DEPTH_MAX_RANGE"`
+	DepthMaxSkew                       time.Duration `setting:"DEPTH_MAX_SKEW"`
+	DepthMinRange                      float64       `setting:"DEPTH_MIN_RANGE"`
+	DepthVision                        bool          `setting:"DEPTH_VISION"`
+	EmbeddingAPIKey                    string        `setting:"EMBEDDING_API_KEY"`
+	EmbeddingDimensions                int           `setting:"EMBEDDING_DIMENSIONS"`
+	EmbeddingModel                     string        `setting:"EMBEDDING_MODEL"`
+	EmbeddingProvider                  string        `setting:"EMBEDDING_PROVIDER"`
+	EmbeddingURL                       string        `setting:"EMBEDDING_URL"`
+	EncryptionKeys                     string        `setting:"ENCRYPTION_KEYS"`
+	EncryptionKeyID                    string        `setting:"ENCRYPTION_KEY_ID"`
+	EncryptionRotationInterval         time.Duration `setting:"ENCRYPTION_ROTATION_INTERVAL"`
+	EnvironmentCacheSize               int           `setting:"ENVIRONMENT_CACHE_SIZE"`
+	EnvironmentChanges                 bool          `setting:"ENVIRONMENT_CHANGES"`
+	EnvironmentChangeAbsentFor         int           `setting:"ENVIRONMENT_CHANGE_ABSENT_FOR"`
+	ExportSigningKey                   string        `setting:"EXPORT_SIGNING_KEY"`
+	FrameQualityCheck                  bool          `setting:"FRAME_QUALITY_CHECK"`
+	FrameQualityMaxBrightness          float64       `setting:"FRAME_QUALITY_MAX_BRIGHTNESS"`
+	FrameQualityMaxClipped             float64       `setting:"FRAME_QUALITY_MAX_CLIPPED"`
+	FrameQualityMinBrightness          float64       `setting:"FRAME_QUALITY_MIN_BRIGHTNESS"`
+	FrameQualityMinSharpness           float64       `setting:"FRAME_QUALITY_MIN_SHARPNESS"`
+	FrameStore                         string        `setting:"FRAME_STORE"`
+	FrameStoreDir                      string        `setting:"FRAME_STORE_DIR"`
+	FrameStoreImage                    string        `setting:"FRAME_STORE_IMAGE"`
+	FrameStoreS3Bucket                 string        `setting:"FRAME_STORE_S3_BUCKET"`
+	FrameStoreS3Endpoint               string        `setting:"FRAME_STORE_S3_ENDPOINT"`
+	FrameStoreS3Prefix                 string        `setting:"FRAME_STORE_S3_PREFIX"`
+	FrameStoreS3Region                 string        `setting:"FRAME_STORE_S3_REGION"`
+	FrameStoreTimeout                  time.Duration `setting:"FRAME_STORE_TIMEOUT"`
+	FrameUploadMaxBytes                int           `setting:"FRAME_UPLOAD_MAX_BYTES"`
+	FrameUploadMaxFrames               int           `setting:"FRAME_UPLOAD_MAX_FRAMES"`
+	HTTPRequestTimeout                 time.Duration `setting:"HTTP_REQUEST_TIMEOUT"`
+	InactivityLowPowerAfter            time.Duration `setting:"INACTIVITY_LOW_POWER_AFTER"`
+	InactivityPrompt                   string        `setting:"INACTIVITY_PROMPT"`
+	InactivityPromptAfter              time.Duration `setting:"INACTIVITY_PROMPT_AFTER"`
+	InactivityPromptSpeak              bool          `setting:"INACTIVITY_PROMPT_SPEAK"`
+	InactivityVideoFrequency           time.Duration `setting:"INACTIVITY_VIDEO_FREQUENCY"`
+	InstanceID                         string        `setting:"INSTANCE_ID"`
+	IntentionCoalesce                  bool          `setting:"INTENTION_COALESCE"`
+	IntentionConfirmation              bool          `setting:"INTENTION_CONFIRMATION"`
+	IntentionConfirmationMinConfidence float64       `setting:"INTENTION_CONFIRMATION_MIN_CONFIDENCE"`
+	IntentionConfirmationTimeout       time.Duration `setting:"INTENTION_CONFIRMATION_TIMEOUT"`
+	IntentionDedupSimilarity           float64       `setting:"INTENTION_DEDUP_SIMILARITY"`
+	IntentionDedupWindow               time.Duration `setting:"INTENTION_DEDUP_WINDOW"`
+	IntentionToolsEnabled              bool          `setting:"INTENTION_TOOLS_ENABLED"`
+	IntentionToolMaxRounds             int           `setting:"INTENTION_TOOL_MAX_ROUNDS"`
+	IntentionTypesFile                 string        `setting:"INTENTION_TYPES_FILE"`
+	LogFormat                          string        `setting:"LOG_FORMAT"`
+	LogLevel                           string        `setting:"LOG_LEVEL"`
+	LogRedact                          bool          `setting:"LOG_REDACT"`
+	MaxSessions                        int           `setting:"MAX_SESSIONS"`
+	MaxSessionsPerIP                   int           `setting:"MAX_SESSIONS_PER_IP"`
+	MaxSessionsPerTenant               int           `setting:"MAX_SESSIONS_PER_TENANT"`
+	MemoryCompactionInterval           time.Duration `setting:"MEMORY_COMPACTION_INTERVAL"`
+	MemoryCompactionKeepRecent         int           `setting:"MEMORY_COMPACTION_KEEP_RECENT"`
+	MetricsLabels                      string        `setting:"METRICS_LABELS"`
+	MetricsLabelMaxValues              int           `setting:"METRICS_LABEL_MAX_VALUES"`
+	OpenAIAPIKey                       string        `setting:"OPENAI_API_KEY"`
+	OpenAIBaseURL                      string        `setting:"OPENAI_BASE_URL"`
+	OpenAIMaxAttempts                  int           `setting:"OPENAI_MAX_ATTEMPTS"`
+	OpenAIMaxIdleConns                 int           `setting:"OPENAI_MAX_IDLE_CONNS"`
+	OpenAIMaxRetryAfter                time.Duration `setting:"OPENAI_MAX_RETRY_AFTER"`
+	OpenAIRequestTimeout               time.Duration `setting:"OPENAI_REQUEST_TIMEOUT"`
+	OpenAIRetryBackoff                 time.Duration `setting:"OPENAI_RETRY_BACKOFF"`
+	OpenAIRetryMaxBackoff              time.Duration `setting:"OPENAI_RETRY_MAX_BACKOFF"`
+	OrchestratorAPIKey                 string        `setting:"ORCHESTRATOR_API_KEY"`
+	OrchestratorAuthScheme             string        `setting:"ORCHESTRATOR_AUTH_SCHEME"`
+	OrchestratorHMACHeader             string        `setting:"ORCHESTRATOR_HMAC_HEADER"`
+	OrchestratorHMACSecret             string        `setting:"ORCHESTRATOR_HMAC_SECRET"`
+	OrchestratorMaxAttempts            int           `setting:"ORCHESTRATOR_MAX_ATTEMPTS"`
+	OrchestratorOAuthClientID          string        `setting:"ORCHESTRATOR_OAUTH_CLIENT_ID"`
+	OrchestratorOAuthClientSecret      string        `setting:"ORCHESTRATOR_OAUTH_CLIENT_SECRET"`
+	OrchestratorOAuthScopes            string        `setting:"ORCHESTRATOR_OAUTH_SCOPES"`
+	OrchestratorOAuthTokenURL          string        `setting:"ORCHESTRATOR_OAUTH_TOKEN_URL"`
+	OrchestratorRetryBackoff           time.Duration `setting:"ORCHESTRATOR_RETRY_BACKOFF"`
+	OrchestratorRoutesFile             string        `setting:"ORCHESTRATOR_ROUTES_FILE"`
+	OrchestratorTLSCAFile              string        `setting:"ORCHESTRATOR_TLS_CA_FILE"`
+	OrchestratorTLSCertFile            string        `setting:"ORCHESTRATOR_TLS_CERT_FILE"`
+	OrchestratorTLSKeyFile             string        `setting:"ORCHESTRATOR_TLS_KEY_FILE"`
+	OrchestratorURL                    string        `setting:"ORCHESTRATOR_URL"`
+	OutboundFlushTimeout               time.Duration `setting:"OUTBOUND_FLUSH_TIMEOUT"`
+	OutboundQueueSize                  int           `setting:"OUTBOUND_QUEUE_SIZE"`
+	OutboundWriteTimeout               time.Duration `setting:"OUTBOUND_WRITE_TIMEOUT"`
+	PineconeAPIKey                     string        `setting:"PINECONE_API_KEY"`
+	PineconeFilterSession              bool          `setting:"PINECONE_FILTER_SESSION"`
+	PineconeFilterTypes                string        `setting:"PINECONE_FILTER_TYPES"`
+	PineconeHost                       string        `setting:"PINECONE_HOST"`
+	PineconeMaxAge                     time.Duration `setting:"PINECONE_MAX_AGE"`
+	PineconeNamespace                  string        `setting:"PINECONE_NAMESPACE"`
+	PineconeQueryTimeout               time.Duration `setting:"PINECONE_QUERY_TIMEOUT"`
+	PineconeRecencyHalfLife            time.Duration `setting:"PINECONE_RECENCY_HALF_LIFE"`
+	PineconeTopK                       int           `setting:"PINECONE_TOP_K"`
+	PineconeWriteBatchSize             int           `setting:"PINECONE_WRITE_BATCH_SIZE"`
+	PineconeWriteFlushInterval         time.Duration `setting:"PINECONE_WRITE_FLUSH_INTERVAL"`
+	PineconeWriteQueueSize             int           `setting:"PINECONE_WRITE_QUEUE_SIZE"`
+	PineconeWriteRetries               int           `setting:"PINECONE_WRITE_RETRIES"`
+	Port                               int           `setting:"PORT"`
+	PreferenceMaxPerSubject            int           `setting:"PREFERENCE_MAX_PER_SUBJECT"`
+	PreferenceMemoryEnabled            bool          `setting:"PREFERENCE_MEMORY_ENABLED"`
+	PreferenceMinConfidence            float64       `setting:"PREFERENCE_MIN_CONFIDENCE"`
+	PreferenceNamespace                string        `setting:"PREFERENCE_NAMESPACE"`
+	PreferenceTopK                     int           `setting:"PREFERENCE_TOP_K"`
+	ProviderHealthCheckInterval        time.Duration `setting:"PROVIDER_HEALTH_CHECK_INTERVAL"`
+	ProviderIdleTimeout                time.Duration `setting:"PROVIDER_IDLE_TIMEOUT"`
+	RecordingDir                       string        `setting:"RECORDING_DIR"`
+	RecordingEnabled                   bool          `setting:"RECORDING_ENABLED"`
+	RecordingFPS                       int           `setting:"RECORDING_FPS"`
+	RecordingMaxDuration               time.Duration `setting:"RECORDING_MAX_DURATION"`
+	RecordingRetention                 time.Duration `setting:"RECORDING_RETENTION"`
+	RedisHost                          string        `setting:"REDIS_HOST"`
+	RedisPassword                      string        `setting:"REDIS_PASSWORD"`
+	RetentionPurgeInterval             time.Duration `setting:"RETENTION_PURGE_INTERVAL"`
+	RobotRegistrationRequired          bool          `setting:"ROBOT_REGISTRATION_REQUIRED"`
+	RTSPAllowedNetworks                string        `setting:"RTSP_ALLOWED_NETWORKS"`
+	SceneQACaptureWait                 time.Duration `setting:"SCENE_QA_CAPTURE_WAIT"`
+	SceneQAMaxFrames                   int           `setting:"SCENE_QA_MAX_FRAMES"`
+	SceneQAMaxFrameAge                 time.Duration `setting:"SCENE_QA_MAX_FRAME_AGE"`
+	ScheduledCaptureInterval           time.Duration `setting:"SCHEDULED_CAPTURE_INTERVAL"`
+	SentimentAnalysisEnabled           bool          `setting:"SENTIMENT_ANALYSIS_ENABLED"`
+	SentimentAPIKey                    string        `setting:"SENTIMENT_API_KEY"`
+	SentimentBackend                   string        `setting:"SENTIMENT_BACKEND"`
+	SentimentEscalationUrgency         float64       `setting:"SENTIMENT_ESCALATION_URGENCY"`
+	SentimentTimeout                   time.Duration `setting:"SENTIMENT_TIMEOUT"`
+	SentimentURL                       string        `setting:"SENTIMENT_URL"`
+	ServerCamerasEnabled               bool          `setting:"SERVER_CAMERAS_ENABLED"`
+	SessionProfilesFile                string        `setting:"SESSION_PROFILES_FILE"`
+	SessionSnapshotInterval            time.Duration `setting:"SESSION_SNAPSHOT_INTERVAL"`
+	SessionSnapshotTTL                 time.Duration `setting:"SESSION_SNAPSHOT_TTL"`
+	SessionSummaryEnabled              bool          `setting:"SESSION_SUMMARY_ENABLED"`
+	SessionSummaryTimeout              time.Duration `setting:"SESSION_SUMMARY_TIMEOUT"`
+	SessionWarmup                      bool          `setting:"SESSION_WARMUP"`
+	SessionWarmupTimeout               time.Duration `setting:"SESSION_WARMUP_TIMEOUT"`
+	ShutdownTimeout                    time.Duration `setting:"SHUTDOWN_TIMEOUT"`
+	SiteMemoryEnabled                  bool          `setting:"SITE_MEMORY_ENABLED"`
+	SiteMemoryTopK                     int           `setting:"SITE_MEMORY_TOP_K"`
+	SiteMemoryTTL                      time.Duration `setting:"SITE_MEMORY_TTL"`
+	StaleContextAfter                  time.Duration `setting:"STALE_CONTEXT_AFTER"`
+	StaleContextCheckInterval          time.Duration `setting:"STALE_CONTEXT_CHECK_INTERVAL"`
+	STTEndpointingMs                   int           `setting:"STT_ENDPOINTING_MS"`
+	STTFillerWords                     bool          `setting:"STT_FILLER_WORDS"`
+	STTInterimResults                  bool          `setting:"STT_INTERIM_RESULTS"`
+	STTKeepaliveInterval               time.Duration `setting:"STT_KEEPALIVE_INTERVAL"`
+	STTKeyterms                        string        `setting:"STT_KEYTERMS"`
+	STTKeywords                        string        `setting:"STT_KEYWORDS"`
+	STTLanguage                        string        `setting:"STT_LANGUAGE"`
+	STTProvider                        string        `setting:"STT_PROVIDER"`
+	STTReconnectBufferBytes            int           `setting:"STT_RECONNECT_BUFFER_BYTES"`
+	STTReconnectMaxAttempts            int           `setting:"STT_RECONNECT_MAX_ATTEMPTS"`
+	STTReconnectMaxBackoff             time.Duration `setting:"STT_RECONNECT_MAX_BACKOFF"`
+	STTSceneBoost                      bool          `setting:"STT_SCENE_BOOST"`
+	STTSceneBoostInterval              time.Duration `setting:"STT_SCENE_BOOST_INTERVAL"`
+	STTSceneBoostMaxTerms              int           `setting:"STT_SCENE_BOOST_MAX_TERMS"`
+	STTSmartFormat                     bool          `setting:"STT_SMART_FORMAT"`
+	STTUtteranceEndMs                  int           `setting:"STT_UTTERANCE_END_MS"`
+	SupervisorBackoff                  time.Duration `setting:"SUPERVISOR_BACKOFF"`
+	SupervisorMaxBackoff               time.Duration `setting:"SUPERVISOR_MAX_BACKOFF"`
+	SupervisorMaxRestarts              int           `setting:"SUPERVISOR_MAX_RESTARTS"`
+	SupervisorRestartWindow            time.Duration `setting:"SUPERVISOR_RESTART_WINDOW"`
+	TenantsFile                        string        `setting:"TENANTS_FILE"`
+	TestMode                           bool          `setting:"TESTMODE"`
+	TestModeFaults                     string        `setting:"TESTMODE_FAULTS"`
+	TLSAutocertCacheDir                string        `setting:"TLS_AUTOCERT_CACHE_DIR"`
+	TLSAutocertDirectoryURL            string        `setting:"TLS_AUTOCERT_DIRECTORY_URL"`
+	TLSAutocertDomains                 string        `setting:"TLS_AUTOCERT_DOMAINS"`
+	TLSAutocertEmail                   string        `setting:"TLS_AUTOCERT_EMAIL"`
+	TLSCertFile                        string        `setting:"TLS_CERT_FILE"`
+	TLSKeyFile                         string        `setting:"TLS_KEY_FILE"`
+	TLSRedirectPort                    string        `setting:"TLS_REDIRECT_PORT"`
+	TranscriptEchoInterim              bool          `setting:"TRANSCRIPT_ECHO_INTERIM"`
+	TranscriptFilters                  string        `setting:"TRANSCRIPT_FILTERS"`
+	TranscriptFlushAfter               time.Duration `setting:"TRANSCRIPT_FLUSH_AFTER"`
+	TranscriptMaxLength                int           `setting:"TRANSCRIPT_MAX_LENGTH"`
+	TranscriptProfanityWords           string        `setting:"TRANSCRIPT_PROFANITY_WORDS"`
+	TranscriptSummaryWords             int           `setting:"TRANSCRIPT_SUMMARY_WORDS"`
+	TranscriptWindowTokens             int           `setting:"TRANSCRIPT_WINDOW_TOKENS"`
+	TriggerRulesFile                   string        `setting:"TRIGGER_RULES_FILE"`
+	TrustProxyHeaders                  bool          `setting:"TRUST_PROXY_HEADERS"`
+	VADEnabled                         bool          `setting:"VAD_ENABLED"`
+	VADEngine                          string        `setting:"VAD_ENGINE"`
+	VADHangover                        time.Duration `setting:"VAD_HANGOVER"`
+	VADMode                            int           `setting:"VAD_MODE"`
+	VADOnset                           time.Duration `setting:"VAD_ONSET"`
+	VADPreroll                         time.Duration `setting:"VAD_PREROLL"`
+	VideoFrameBudgetPerHour            int           `setting:"VIDEO_FRAME_BUDGET_PER_HOUR"`
+	VideoFrequencyMax                  time.Duration `setting:"VIDEO_FREQUENCY_MAX"`
+	VideoFrequencyMin                  time.Duration `setting:"VIDEO_FREQUENCY_MIN"`
+	VideoMotionThreshold               float64       `setting:"VIDEO_MOTION_THRESHOLD"`
+	VisionCrop                         string        `setting:"VISION_CROP"`
+	VisionJPEGQuality                  int           `setting:"VISION_JPEG_QUALITY"`
+	VisionMaxDimension                 int           `setting:"VISION_MAX_DIMENSION"`
+	VisionRegionsEnabled               bool          `setting:"VISION_REGIONS_ENABLED"`
+	VoskLogLevel                       int           `setting:"VOSK_LOG_LEVEL"`
+	VoskModelPath                      string        `setting:"VOSK_MODEL_PATH"`
+	WebhookFlushTimeout                time.Duration `setting:"WEBHOOK_FLUSH_TIMEOUT"`
+	WebhookMaxAttempts                 int           `setting:"WEBHOOK_MAX_ATTEMPTS"`
+	WebhookQueueSize                   int           `setting:"WEBHOOK_QUEUE_SIZE"`
+	WebhookRetryBackoff                time.Duration `setting:"WEBHOOK_RETRY_BACKOFF"`
+	WebhookSecret                      string        `setting:"WEBHOOK_SECRET"`
+	WebhookTimeout                     time.Duration `setting:"WEBHOOK_TIMEOUT"`
+	WebhookURLs                        string        `setting:"WEBHOOK_URLS"`
+	WebhookWorkers                     int           `setting:"WEBHOOK_WORKERS"`
+	WebSocketAllowedOrigins            string        `setting:"WEBSOCKET_ALLOWED_ORIGINS"`
+	WSCompression                      bool          `setting:"WS_COMPRESSION"`
+	WSCompressionLevel                 int           `setting:"WS_COMPRESSION_LEVEL"`
+	WSCompressionMinBytes              int           `setting:"WS_COMPRESSION_MIN_BYTES"`
+	WSMaxFrameBytes                    int           `setting:"WS_MAX_FRAME_BYTES"`
+	WSMaxMessageBytes                  int           `setting:"WS_MAX_MESSAGE_BYTES"`
+	WSReadTimeout                      time.Duration `setting:"WS_READ_TIMEOUT"`
+
+	// Models holds the model lists of MODEL_<TASK> by task, e.g.
+	// MODEL_VISION=gpt-4.1-2025-04-14,gpt-4.1-mini-2025-04-14
+	Models ModelChains
+	// OpenAIRequestTimeouts holds OPENAI_REQUEST_TIMEOUT_<TASK> by task
+	OpenAIRequestTimeouts map[string]time.Duration
+
+	// fileSettings are the names of the settings taken from the config file
+	fileSettings []string
+}
+
+// Prefixes of the settings named after a task, e.g. MODEL_VISION or
+// OPENAI_REQUEST_TIMEOUT_BATCH.
+const (
+	modelSettingPrefix         = "MODEL_"
+	openAITimeoutSettingPrefix = "OPENAI_REQUEST_TIMEOUT_"
+)
+
+// DefaultConfig returns the settings used when neither the config file nor
+// the environment sets them.
+func DefaultConfig() *Config {
+	return &Config{
+		AcousticEventCooldown: 10 * time.Second,
+		AcousticEventMinScore: 0.5,
+		// Safety-relevant sounds notify the orchestrator
+		AcousticEventNotify:                strings.Join([]string{SOUND_EVENT_ALARM, SOUND_EVENT_GLASS_BREAKING}, ","),
+		AcousticEvents:                     strings.Join(soundEvents, ","),
+		AcousticEventTimeout:               5 * time.Second,
+		AcousticEventWindow:                time.Second,
+		AdmissionMaxWait:                   30 * time.Second,
+		AdmissionQueueSize:                 100,
+		AdmissionRetryAfter:                5 * time.Second,
+		AudioAGCMaxGainDB:                  24,
+		AudioAGCTargetDBFS:                 -20,
+		AudioHighpassHz:                    100,
+		AudioNoiseGateDB:                   6,
+		AudioSampleRate:                    16000,
+		CaptionMaxViewers:                  20,
+		CaptionTokenTTL:                    12 * time.Hour,
+		CommandGrammarEnabled:              true,
+		DepthClearDistance:                 1,
+		DepthMaxRange:                      10,
+		DepthMaxSkew:                       time.Second,
+		DepthMinRange:                      0.1,
+		EnvironmentCacheSize:               10,
+		EnvironmentChangeAbsentFor:         2,
+		FrameQualityCheck:                  true,
+		FrameQualityMaxBrightness:          225,
+		FrameQualityMaxClipped:             0.6,
+		FrameQualityMinBrightness:          35,
+		FrameQualityMinSharpness:           40,
+		FrameStoreTimeout:                  10 * time.Second,
+		FrameUploadMaxBytes:                5 << 20,
+		FrameUploadMaxFrames:               10,
+		HTTPRequestTimeout:                 time.Minute,
+		InactivityPrompt:                   "Do you still need anything?",
+		InactivityPromptSpeak:              true,
+		InactivityVideoFrequency:           5 * time.Minute,
+		IntentionCoalesce:                  true,
+		IntentionConfirmationMinConfidence: 0.4,
+		IntentionConfirmationTimeout:       15 * time.Second,
+		IntentionDedupSimilarity:           0.92,
+		IntentionDedupWindow:               30 * time.Second,
+		IntentionToolMaxRounds:             3,
+		IntentionToolsEnabled:              true,
+		LogRedact:                          true,
+		MemoryCompactionInterval:           10 * time.Minute,
+		MemoryCompactionKeepRecent:         5,
+		MetricsLabelMaxValues:              50,
+		OpenAIMaxAttempts:                  3,
+		OpenAIMaxIdleConns:                 64,
+		OpenAIMaxRetryAfter:                20 * time.Second,
+		OpenAIRequestTimeout:               30 * time.Second,
+		OpenAIRetryBackoff:                 500 * time.Millisecond,
+		OpenAIRetryMaxBackoff:              8 * time.Second,
+		OrchestratorMaxAttempts:            3,
+		OrchestratorRetryBackoff:           time.Second,
+		OutboundFlushTimeout:               2 * time.Second,
+		OutboundQueueSize:                  256,
+		OutboundWriteTimeout:               10 * time.Second,
+		PineconeFilterSession:              true,
+		PineconeFilterTypes:                "environment_context,world_state",
+		PineconeQueryTimeout:               2 * time.Second,
+		PineconeTopK:                       5,
+		PineconeWriteBatchSize:             50,
+		PineconeWriteFlushInterval:         2 * time.Second,
+		PineconeWriteQueueSize:             1000,
+		PineconeWriteRetries:               3,
+		Port:                               8080,
+		PreferenceMaxPerSubject:            200,
+		PreferenceMinConfidence:            0.7,
+		PreferenceTopK:                     5,
+		ProviderHealthCheckInterval:        time.Minute,
+		ProviderIdleTimeout:                10 * time.Minute,
+		RecordingFPS:                       2,
+		RecordingMaxDuration:               time.Hour,
+		RecordingRetention:                 7 * 24 * time.Hour,
+		RetentionPurgeInterval:             time.Hour,
+		SceneQACaptureWait:                 5 * time.Second,
+		SceneQAMaxFrameAge:                 10 * time.Second,
+		SceneQAMaxFrames:                   3,
+		SentimentEscalationUrgency:         0.8,
+		SentimentTimeout:                   5 * time.Second,
+		SessionSnapshotInterval:            10 * time.Second,
+		SessionSnapshotTTL:                 time.Hour,
+		SessionSummaryEnabled:              true,
+		SessionSummaryTimeout:              15 * time.Second,
+		SessionWarmupTimeout:               3 * time.Second,
+		ShutdownTimeout:                    30 * time.Second,
+		SiteMemoryTopK:                     3,
+		SiteMemoryTTL:                      7 * 24 * time.Hour,
+		StaleContextAfter:                  2 * time.Minute,
+		StaleContextCheckInterval:          time.Minute,
+		STTEndpointingMs:                   100,
+		STTFillerWords:                     true,
+		STTInterimResults:                  true,
+		STTKeepaliveInterval:               5 * time.Second,
+		STTReconnectBufferBytes:            320000,
+		STTReconnectMaxAttempts:            8,
+		STTReconnectMaxBackoff:             10 * time.Second,
+		STTSceneBoostInterval:              time.Minute,
+		STTSceneBoostMaxTerms:              20,
+		STTUtteranceEndMs:                  1500,
+		SupervisorBackoff:                  100 * time.Millisecond,
+		SupervisorMaxBackoff:               5 * time.Second,
+		SupervisorMaxRestarts:              5,
+		SupervisorRestartWindow:            time.Minute,
+		TranscriptEchoInterim:              true,
+		TranscriptFlushAfter:               30 * time.Second,
+		TranscriptMaxLength:                2000,
+		TranscriptSummaryWords:             150,
+		TranscriptWindowTokens:             1000,
+		VADHangover:                        600 * time.Millisecond,
+		VADMode:                            2,
+		VADOnset:                           60 * time.Millisecond,
+		VADPreroll:                         300 * time.Millisecond,
+		VideoFrequencyMax:                  2 * time.Minute,
+		VideoFrequencyMin:                  5 * time.Second,
+		VideoMotionThreshold:               0.06,
+		VisionJPEGQuality:                  85,
+		VisionMaxDimension:                 1024,
+		VisionRegionsEnabled:               true,
+		VoskLogLevel:                       -1,
+		WebhookFlushTimeout:                5 * time.Second,
+		WebhookMaxAttempts:                 5,
+		WebhookQueueSize:                   1000,
+		WebhookRetryBackoff:                time.Second,
+		WebhookTimeout:                     10 * time.Second,
+		WebhookWorkers:                     4,
+		WSCompression:                      true,
+		WSCompressionLevel:                 flate.BestSpeed,
+		WSCompressionMinBytes:              512,
+		WSMaxFrameBytes:                    5 << 20,
+		WSMaxMessageBytes:                  8 << 20,
+		WSReadTimeout:                      60 * time.Second,
+		Models:                             make(ModelChains),
+		OpenAIRequestTimeouts:              make(map[string]time.Duration),
+	}
+}
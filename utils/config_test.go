@@ -0,0 +1,149 @@
+package utils_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+)
+
+// unsetenv removes settings from the environment for the test.
+func unsetenv(t *testing.T, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		t.Setenv(name, "")
+		os.Unsetenv(name)
+	}
+}
+
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "perceptus.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfigEnvironmentOverridesFile(t *testing.T) {
+	unsetenv(t, "OPENAI_MAX_ATTEMPTS", "ACOUSTIC_EVENTS", "CORS_ALLOWED_ORIGINS", "WEBSOCKET_ALLOWED_ORIGINS", "MODEL_INTENTION")
+	t.Setenv("PORT", "9100")
+	path := writeConfigFile(t, `
+port: 9000
+openai:
+  max_attempts: 5
+acoustic_events: [alarm, doorbell]
+cors_allowed_origins: https://example.com
+model:
+  intention: gpt-4o-mini
+tyop_setting: x
+`)
+
+	cfg, unknown, err := utils.LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg.Port != 9100 {
+		t.Errorf("Port = %d, want 9100 from the environment", cfg.Port)
+	}
+	if cfg.OpenAIMaxAttempts != 5 {
+		t.Errorf("OpenAIMaxAttempts = %d, want 5", cfg.OpenAIMaxAttempts)
+	}
+	if cfg.AcousticEvents != "alarm,doorbell" {
+		t.Errorf("AcousticEvents = %q, want alarm,doorbell", cfg.AcousticEvents)
+	}
+	if cfg.WebSocketAllowedOrigins != "https://example.com" {
+		t.Errorf("WebSocketAllowedOrigins = %q, want the CORS origins", cfg.WebSocketAllowedOrigins)
+	}
+	if got := cfg.Models[utils.MODEL_TASK_INTENTION]; !reflect.DeepEqual(got, []string{"gpt-4o-mini"}) {
+		t.Errorf("intention models = %v, want [gpt-4o-mini]", got)
+	}
+	if !reflect.DeepEqual(unknown, []string{"TYOP_SETTING"}) {
+		t.Errorf("unknown = %v, want [TYOP_SETTING]", unknown)
+	}
+	want := []string{"ACOUSTIC_EVENTS", "CORS_ALLOWED_ORIGINS", "MODEL_INTENTION", "OPENAI_MAX_ATTEMPTS", "PORT"}
+	if got := cfg.FileSettings(); !reflect.DeepEqual(got, want) {
+		t.Errorf("FileSettings() = %v, want %v", got, want)
+	}
+}
+
+func TestLoadConfigInvalidValuesKeepDefaults(t *testing.T) {
+	t.Setenv("PORT", "70000")
+	t.Setenv("OPENAI_MAX_ATTEMPTS", "many")
+	t.Setenv("SHUTDOWN_TIMEOUT", "-1s")
+	t.Setenv("MODEL_TELEPATHY", "gpt-4o")
+	t.Setenv("STT_KEEPALIVE_INTERVAL", "0s")
+
+	cfg, _, err := utils.LoadConfig("")
+	if err == nil {
+		t.Fatal("LoadConfig succeeded with invalid values")
+	}
+	for _, name := range []string{"PORT", "OPENAI_MAX_ATTEMPTS", "SHUTDOWN_TIMEOUT", "MODEL_TELEPATHY", "STT_KEEPALIVE_INTERVAL"} {
+		if !strings.Contains(err.Error(), name) {
+			t.Errorf("error %q does not mention %s", err, name)
+		}
+	}
+	defaults := utils.DefaultConfig()
+	if cfg.Port != defaults.Port || cfg.OpenAIMaxAttempts != defaults.OpenAIMaxAttempts || cfg.ShutdownTimeout != defaults.ShutdownTimeout || cfg.STTKeepaliveInterval != defaults.STTKeepaliveInterval {
+		t.Errorf("invalid settings = %d, %d, %s, %s, want the defaults", cfg.Port, cfg.OpenAIMaxAttempts, cfg.ShutdownTimeout, cfg.STTKeepaliveInterval)
+	}
+}
+
+func TestLoadConfigEmptyValues(t *testing.T) {
+	t.Setenv("INACTIVITY_PROMPT", "")
+	t.Setenv("PORT", "")
+	t.Setenv("OPENAI_REQUEST_TIMEOUT_VISION", "45s")
+
+	cfg, _, err := utils.LoadConfig("")
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg.InactivityPrompt != "" {
+		t.Errorf("InactivityPrompt = %q, want empty when set but empty", cfg.InactivityPrompt)
+	}
+	if cfg.Port != utils.DefaultConfig().Port {
+		t.Errorf("Port = %d, want the default when empty", cfg.Port)
+	}
+	if got := cfg.OpenAIRequestTimeouts["vision"]; got != 45*time.Second {
+		t.Errorf("vision request timeout = %s, want 45s", got)
+	}
+}
+
+func TestReloadKeepsConfigOnInvalidTenants(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "tenants.json")
+	if err := os.WriteFile(path, []byte(`[{"id":"acme","api_keys":["key"]}]`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	unsetenv(t, "CONFIG_FILE")
+	t.Setenv("TENANTS_FILE", path)
+	cfg, _, err := utils.LoadConfig("")
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	previous := utils.CurrentConfig()
+	utils.SetConfig(cfg)
+	defer utils.SetConfig(previous)
+	tenants, err := utils.NewTenantStore(nil)
+	if err != nil {
+		t.Fatalf("NewTenantStore: %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte(`[{"api_keys":["key"]}]`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PORT", "9200")
+	if err := tenants.Reload(); err == nil {
+		t.Fatal("Reload succeeded with a tenant without id")
+	}
+	if got := utils.CurrentConfig(); got != cfg {
+		t.Errorf("CurrentConfig() port = %d after a failed reload, want the previous config", got.Port)
+	}
+	if tenants.Current("acme") == nil {
+		t.Error("tenant acme missing after a failed reload")
+	}
+}
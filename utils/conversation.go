@@ -0,0 +1,60 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// EstimateTokens approximates the model tokens of text, about four
+// characters each for English.
+func EstimateTokens(text string) int {
+	return (utf8.RuneCountInString(text) + 3) / 4
+}
+
+// SummarizeConversation folds utterances that left the conversation window
+// into the rolling summary of earlier conversation.
+func (c *OpenAIClient) SummarizeConversation(ctx context.Context, previousSummary string, utterances []string, maxWords int) (string, error) {
+	if previousSummary == "" {
+		previousSummary = "(none yet)"
+	}
+
+	prompt := fmt.Sprintf(`You keep the memory of a long conversation between a user and their household robot. Merge the older utterances below into the summary of the conversation so far.
+
+Summary so far:
+%s
+
+Older utterances (oldest first):
+- %s
+
+Write an updated summary of at most %d words. Keep what later requests may refer to: tasks asked for and whether they are still open, objects, places and people mentioned, and stated constraints such as "not before noon". Drop small talk and details that no longer matter. Return only the summary text.`, previousSummary, strings.Join(utterances, "\n- "), maxWords)
+
+	message, err := c.completeTask(ctx, MODEL_TASK_SUMMARIZATION, map[string]interface{}{
+		"messages": []GPTMessage{
+			{Role: "user", Content: prompt},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(message.Content), nil
+}
+
+// TranslateCaption translates a transcript line into language, a BCP-47
+// code such as "es" or "pt-BR".
+func (c *OpenAIClient) TranslateCaption(ctx context.Context, text, language string) (string, error) {
+	prompt := fmt.Sprintf(`Translate this line of a live transcript into the language with the BCP-47 code %q. Keep names as they are. Return only the translation.
+
+%s`, language, text)
+
+	message, err := c.completeTask(ctx, MODEL_TASK_SUMMARIZATION, map[string]interface{}{
+		"messages": []GPTMessage{
+			{Role: "user", Content: prompt},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(message.Content), nil
+}
@@ -4,23 +4,99 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"fmt"
 	"io"
-	"os"
 	"strconv"
 	"strings"
+	"sync"
 
+	"github.com/Perceptus-Labs/perceptus-go-sdk/testmode"
 	msginterfaces "github.com/deepgram/deepgram-go-sdk/pkg/api/listen/v1/websocket/interfaces"
 	"github.com/deepgram/deepgram-go-sdk/pkg/client/interfaces"
 	"github.com/deepgram/deepgram-go-sdk/pkg/client/listen"
 	"go.uber.org/zap"
 )
 
+const DeepgramModel = "nova-3"
+
+// STTOptions tune end-of-speech detection and recognition of the Deepgram
+// stream. Shorter endpointing lowers latency but cuts off speakers who pause
+// mid-sentence; noisy rooms usually need longer values.
+type STTOptions struct {
+	// EndpointingMs is the silence after which a segment is finalized
+	// (0 disables endpointing)
+	EndpointingMs int
+	// UtteranceEndMs is the gap between words after which Deepgram sends
+	// UtteranceEnd (0 disables; at least 1000, requires InterimResults).
+	// Without it the end of speech is taken from endpointing.
+	UtteranceEndMs int
+	InterimResults bool
+	FillerWords    bool
+	SmartFormat    bool
+	// Keywords boost words on nova-2 and older models ("word" or
+	// "word:intensifier"); Keyterms prompt nova-3 models
+	Keywords []string
+	Keyterms []string
+	// Language is the BCP-47 code of the spoken language, or "multi" for
+	// Deepgram's multilingual code switching
+	Language string
+}
+
+// DefaultSTTOptions reads the server defaults from cfg.
+func DefaultSTTOptions(cfg *Config) STTOptions {
+	language := cfg.STTLanguage
+	if language == "" {
+		language = "en"
+	}
+	return STTOptions{
+		EndpointingMs:  cfg.STTEndpointingMs,
+		UtteranceEndMs: cfg.STTUtteranceEndMs,
+		InterimResults: cfg.STTInterimResults,
+		FillerWords:    cfg.STTFillerWords,
+		SmartFormat:    cfg.STTSmartFormat,
+		Keywords:       splitTerms(cfg.STTKeywords),
+		Keyterms:       splitTerms(cfg.STTKeyterms),
+		Language:       language,
+	}
+}
+
+// Validate reports the first option Deepgram would reject.
+func (o STTOptions) Validate() error {
+	if o.EndpointingMs < 0 {
+		return fmt.Errorf("endpointing must not be negative")
+	}
+	if o.UtteranceEndMs != 0 && o.UtteranceEndMs < 1000 {
+		return fmt.Errorf("utterance end must be 0 or at least 1000ms")
+	}
+	if o.UtteranceEndMs != 0 && !o.InterimResults {
+		return fmt.Errorf("utterance end requires interim results")
+	}
+	return nil
+}
+
+func splitTerms(value string) []string {
+	var terms []string
+	for _, term := range strings.Split(value, ",") {
+		if term = strings.TrimSpace(term); term != "" {
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
 type DeepgramCallback struct {
 	TranscriptionChannel chan string
 	confidenceThreshold  float64
 
 	lang                string
 	totalAudioBytesSent int64
+
+	// endOnSpeechFinal signals the end of speech on endpointed segments when
+	// UtteranceEnd is disabled
+	endOnSpeechFinal bool
+
+	disconnected     chan struct{}
+	disconnectedOnce sync.Once
 }
 
 type DeepgramClient struct {
@@ -33,27 +109,48 @@ func (c *DeepgramCallback) defaultConfidenceThreshold() float64 {
 }
 
 func InitDeepgramClient(
+	apiKey string,
+	model string,
 	lang string,
 	confidenceThreshold string,
+	format AudioFormat,
+	stt STTOptions,
 	transcriptionCh chan string,
 ) *DeepgramClient {
-	apiKey := os.Getenv("DEEPGRAM_API_KEY")
-
 	if apiKey == "" {
-		zap.L().Error("DEEPGRAM_API_KEY environment variable not set")
+		zap.L().Error("Deepgram API key not configured")
 	}
 
-	model := "nova-3"
-
 	ctx := context.Background()
 	transcriptOptions := &interfaces.LiveTranscriptionOptions{
 		Language:       lang,
 		Channels:       1,
-		Endpointing:    "100",
-		InterimResults: true,
-		FillerWords:    true,
+		Endpointing:    "false",
+		InterimResults: stt.InterimResults,
+		FillerWords:    stt.FillerWords,
+		SmartFormat:    stt.SmartFormat,
 		Model:          model,
-		UtteranceEndMs: "1500",
+		Encoding:       format.Encoding,
+		SampleRate:     format.SampleRate,
+	}
+	if stt.EndpointingMs > 0 {
+		transcriptOptions.Endpointing = strconv.Itoa(stt.EndpointingMs)
+	}
+	if stt.UtteranceEndMs > 0 {
+		transcriptOptions.UtteranceEndMs = strconv.Itoa(stt.UtteranceEndMs)
+	}
+
+	// Deepgram rejects keywords on nova-3 and keyterms on older models
+	if strings.HasPrefix(model, "nova-3") {
+		transcriptOptions.Keyterm = stt.Keyterms
+		if len(stt.Keywords) > 0 {
+			zap.L().Warn("Keywords are not supported by nova-3, use keyterms", zap.String("model", model))
+		}
+	} else {
+		transcriptOptions.Keywords = stt.Keywords
+		if len(stt.Keyterms) > 0 {
+			zap.L().Warn("Keyterms require a nova-3 model", zap.String("model", model))
+		}
 	}
 
 	if lang != "en" && model == "nova-3" {
@@ -76,6 +173,9 @@ func InitDeepgramClient(
 
 		lang:                lang,
 		totalAudioBytesSent: 0,
+		endOnSpeechFinal:    stt.UtteranceEndMs == 0 && stt.EndpointingMs > 0,
+
+		disconnected: make(chan struct{}),
 	}
 
 	dgClient, err := listen.NewWebSocketUsingCallback(ctx, apiKey, clientOptions, transcriptOptions, callback)
@@ -89,25 +189,92 @@ func InitDeepgramClient(
 	}
 }
 
-func (d *DeepgramClient) Connect() {
-	if !d.dgClient.Connect() {
+func (d *DeepgramClient) Provider() string {
+	return STT_PROVIDER_DEEPGRAM
+}
+
+// Connect opens the streaming connection and reports whether it succeeded.
+func (d *DeepgramClient) Connect() bool {
+	if err := testmode.Inject(context.Background(), testmode.TARGET_STT); err != nil {
+		zap.L().Error("ERROR: Failed to connect to Deepgram WebSocket", zap.Error(err))
+		d.callback.markDisconnected()
+		return false
+	}
+	if d.dgClient == nil || !d.dgClient.Connect() {
 		zap.L().Error("ERROR: Failed to connect to Deepgram WebSocket")
+		d.callback.markDisconnected()
+		return false
+	}
+	return true
+}
+
+// Disconnected is closed once the stream is lost: the socket closed, a send
+// or keep-alive failed, or the connection never opened.
+func (d *DeepgramClient) Disconnected() <-chan struct{} {
+	return d.callback.disconnected
+}
+
+// IsConnected reports whether the stream is still usable.
+func (d *DeepgramClient) IsConnected() bool {
+	select {
+	case <-d.callback.disconnected:
+		return false
+	default:
+		return true
 	}
 }
 
 func (d *DeepgramClient) Send(data []byte) error {
+	if !d.IsConnected() {
+		return fmt.Errorf("deepgram stream not connected")
+	}
+	if err := testmode.Inject(context.Background(), testmode.TARGET_STT); err != nil {
+		zap.L().Error("Error streaming to Deepgram", zap.Error(err))
+		d.callback.markDisconnected()
+		return err
+	}
 	reader := bufio.NewReader(bytes.NewReader(data))
 	err := d.dgClient.Stream(reader)
 	if err != nil && err != io.EOF {
 		zap.L().Error("Error streaming to Deepgram", zap.Error(err))
+		d.callback.markDisconnected()
 		return err
 	}
 	d.callback.totalAudioBytesSent += int64(len(data))
 	return nil
 }
 
+// KeepAlive sends a keep-alive control message on the open stream.
+func (d *DeepgramClient) KeepAlive() error {
+	if d.dgClient == nil || !d.IsConnected() {
+		return fmt.Errorf("deepgram stream not connected")
+	}
+	if err := d.dgClient.KeepAlive(); err != nil {
+		d.callback.markDisconnected()
+		return err
+	}
+	return nil
+}
+
+// Finalize asks Deepgram to flush; its final result ends the utterance.
+func (d *DeepgramClient) Finalize() error {
+	if d.dgClient == nil || !d.IsConnected() {
+		return fmt.Errorf("deepgram stream not connected")
+	}
+	return d.dgClient.Finalize()
+}
+
 func (d *DeepgramClient) Close() {
-	d.dgClient.Stop()
+	d.callback.markDisconnected()
+	if d.dgClient != nil {
+		d.dgClient.Stop()
+	}
+}
+
+func (c *DeepgramCallback) markDisconnected() {
+	c.disconnectedOnce.Do(func() {
+		close(c.disconnected)
+	})
 }
 
 func (c *DeepgramCallback) Open(or *msginterfaces.OpenResponse) error {
@@ -126,24 +293,30 @@ func (c *DeepgramCallback) Message(mr *msginterfaces.MessageResponse) error {
 
 	alternative := mr.Channel.Alternatives[0]
 	transcript = strings.TrimSpace(alternative.Transcript)
-	transcriptionConfidence = alternative.Confidence
-
-	if transcript == "" || transcript == " " {
-		return nil
+	if testmode.Malformed(testmode.TARGET_STT) {
+		transcript = "\ufffd\ufffd " + transcript
 	}
+	transcriptionConfidence = alternative.Confidence
 
-	if transcriptionConfidence < c.defaultConfidenceThreshold() {
+	sent := false
+	switch {
+	case transcript == "":
+	case transcriptionConfidence < c.defaultConfidenceThreshold():
 		zap.L().Debug("Discarding low confidence transcript", zap.String("transcript", transcript))
-		return nil
-	}
-
-	if mr.IsFinal {
+	case mr.IsFinal:
 		zap.L().Debug("Final word of a sentence received", zap.String("transcript", transcript))
 		c.TranscriptionChannel <- transcript
-	} else {
+		sent = true
+	default:
 		zap.L().Debug("Interim transcript", zap.String("transcript", transcript))
 	}
 
+	// A flushed result ends the utterance even when empty: no more audio
+	// follows for Deepgram to detect its end
+	if (sent && mr.SpeechFinal && c.endOnSpeechFinal) || (mr.IsFinal && mr.FromFinalize) {
+		c.TranscriptionChannel <- END_OF_SPEECH
+	}
+
 	return nil
 }
 
@@ -159,12 +332,13 @@ func (c *DeepgramCallback) SpeechStarted(ssr *msginterfaces.SpeechStartedRespons
 
 func (c *DeepgramCallback) UtteranceEnd(ur *msginterfaces.UtteranceEndResponse) error {
 	zap.L().Debug("Utterance ended")
-	c.TranscriptionChannel <- "<END_OF_SPEECH>"
+	c.TranscriptionChannel <- END_OF_SPEECH
 	return nil
 }
 
 func (c *DeepgramCallback) Close(cr *msginterfaces.CloseResponse) error {
 	zap.L().Info("WebSocket connection closed")
+	c.markDisconnected()
 	return nil
 }
 
@@ -0,0 +1,240 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Encodings of depth_data maps: a 16-bit grayscale PNG, or raw little-endian
+// uint16 values compressed with zstd.
+const (
+	DEPTH_ENCODING_PNG  = "png"
+	DEPTH_ENCODING_ZSTD = "zstd"
+)
+
+const (
+	maxDepthDimension = 4096
+	// Percentiles are computed over at most this many forward pixels
+	maxDepthSamples = 20000
+	// Long side of the rendering passed to the vision model
+	depthRenderDimension = 512
+)
+
+// DepthFrame is a decoded depth map registered to the RGB camera. Values are
+// raw sensor units; multiplied by Scale they give meters. Zero means no
+// reading.
+type DepthFrame struct {
+	Width  int
+	Height int
+	Values []uint16
+	Scale  float64
+}
+
+// DepthSettings bound the trusted sensor range and the distance beyond which
+// the way ahead counts as free.
+type DepthSettings struct {
+	MinRange      float64
+	MaxRange      float64
+	ClearDistance float64
+}
+
+// DepthSettingsFromConfig reads DEPTH_MIN_RANGE, DEPTH_MAX_RANGE and
+// DEPTH_CLEAR_DISTANCE (meters).
+func DepthSettingsFromConfig(cfg *Config) DepthSettings {
+	return DepthSettings{
+		MinRange:      cfg.DepthMinRange,
+		MaxRange:      cfg.DepthMaxRange,
+		ClearDistance: cfg.DepthClearDistance,
+	}
+}
+
+// DecodeDepth decodes a base64 (or data URL) depth map. Width and height are
+// required for zstd maps and ignored for PNGs; scale is meters per unit and
+// defaults to millimeters.
+func DecodeDepth(data, encoding string, width, height int, scale float64) (*DepthFrame, error) {
+	if strings.HasPrefix(data, "data:") {
+		if _, payload, ok := strings.Cut(data, ","); ok {
+			data = payload
+		}
+	}
+	raw, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64 depth data: %w", err)
+	}
+	if scale <= 0 {
+		scale = 0.001
+	}
+
+	switch encoding {
+	case DEPTH_ENCODING_PNG:
+		return decodeDepthPNG(raw, scale)
+	case DEPTH_ENCODING_ZSTD:
+		return decodeDepthZstd(raw, width, height, scale)
+	default:
+		return nil, fmt.Errorf("unsupported depth encoding %q", encoding)
+	}
+}
+
+func checkDepthSize(width, height int) error {
+	if width < 1 || height < 1 || width > maxDepthDimension || height > maxDepthDimension {
+		return fmt.Errorf("depth map must be between 1x1 and %dx%d, got %dx%d", maxDepthDimension, maxDepthDimension, width, height)
+	}
+	return nil
+}
+
+func decodeDepthPNG(raw []byte, scale float64) (*DepthFrame, error) {
+	config, err := png.DecodeConfig(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("invalid depth PNG: %w", err)
+	}
+	if config.ColorModel != color.Gray16Model {
+		return nil, fmt.Errorf("depth PNG must be 16-bit grayscale")
+	}
+	if err := checkDepthSize(config.Width, config.Height); err != nil {
+		return nil, err
+	}
+
+	img, err := png.Decode(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("invalid depth PNG: %w", err)
+	}
+	gray, ok := img.(*image.Gray16)
+	if !ok {
+		return nil, fmt.Errorf("depth PNG must be 16-bit grayscale")
+	}
+
+	bounds := gray.Bounds()
+	frame := &DepthFrame{Width: bounds.Dx(), Height: bounds.Dy(), Scale: scale}
+	frame.Values = make([]uint16, frame.Width*frame.Height)
+	for y := 0; y < frame.Height; y++ {
+		row := gray.Pix[y*gray.Stride:]
+		for x := 0; x < frame.Width; x++ {
+			frame.Values[y*frame.Width+x] = binary.BigEndian.Uint16(row[2*x:])
+		}
+	}
+	return frame, nil
+}
+
+func decodeDepthZstd(raw []byte, width, height int, scale float64) (*DepthFrame, error) {
+	if err := checkDepthSize(width, height); err != nil {
+		return nil, err
+	}
+	size := width * height * 2
+	decoder, err := zstd.NewReader(nil, zstd.WithDecoderMaxMemory(uint64(size)), zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create zstd decoder: %w", err)
+	}
+	defer decoder.Close()
+
+	decoded, err := decoder.DecodeAll(raw, make([]byte, 0, size))
+	if err != nil {
+		return nil, fmt.Errorf("invalid zstd depth data: %w", err)
+	}
+	if len(decoded) != size {
+		return nil, fmt.Errorf("depth data has %d bytes, expected %d for %dx%d", len(decoded), size, width, height)
+	}
+
+	frame := &DepthFrame{Width: width, Height: height, Scale: scale, Values: make([]uint16, width*height)}
+	for i := range frame.Values {
+		frame.Values[i] = binary.LittleEndian.Uint16(decoded[2*i:])
+	}
+	return frame, nil
+}
+
+// distance returns the depth at index i in meters, 0 when outside the range.
+func (f *DepthFrame) distance(i int, settings DepthSettings) float64 {
+	meters := float64(f.Values[i]) * f.Scale
+	if f.Values[i] == 0 || meters < settings.MinRange || meters > settings.MaxRange {
+		return 0
+	}
+	return meters
+}
+
+// Stats summarizes the map. The forward region, where the robot would drive,
+// is the central 60% of the width in the lower two thirds of the image; the
+// nearest obstacle is its 1st percentile distance so sensor speckle does not
+// count as an obstacle.
+func (f *DepthFrame) Stats(settings DepthSettings) models.DepthStats {
+	stats := models.DepthStats{Width: f.Width, Height: f.Height}
+
+	valid := 0
+	for i := range f.Values {
+		if f.distance(i, settings) > 0 {
+			valid++
+		}
+	}
+	stats.ValidRatio = round2(float64(valid) / float64(len(f.Values)))
+
+	x0, x1 := f.Width/5, f.Width-f.Width/5
+	y0 := f.Height / 3
+	step := max(1, int(math.Sqrt(float64((x1-x0)*(f.Height-y0))/maxDepthSamples)))
+	var forward []float64
+	free := 0
+	for y := y0; y < f.Height; y += step {
+		for x := x0; x < x1; x += step {
+			if meters := f.distance(y*f.Width+x, settings); meters > 0 {
+				forward = append(forward, meters)
+				if meters >= settings.ClearDistance {
+					free++
+				}
+			}
+		}
+	}
+	if len(forward) == 0 {
+		return stats
+	}
+	sort.Float64s(forward)
+	stats.NearestObstacle = round2(forward[len(forward)/100])
+	stats.MedianDistance = round2(forward[len(forward)/2])
+	stats.FreeSpace = round2(float64(free) / float64(len(forward)))
+	return stats
+}
+
+// Render colorizes the map for the vision model, near red through far blue
+// with missing readings black, as a JPEG data URL.
+func (f *DepthFrame) Render(settings DepthSettings) (string, error) {
+	scale := math.Min(1, float64(depthRenderDimension)/float64(max(f.Width, f.Height)))
+	width := max(1, int(float64(f.Width)*scale))
+	height := max(1, int(float64(f.Height)*scale))
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	span := settings.MaxRange - settings.MinRange
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			meters := f.distance(int(float64(y)/scale)*f.Width+int(float64(x)/scale), settings)
+			if meters == 0 {
+				img.Set(x, y, color.RGBA{A: 255})
+				continue
+			}
+			t := (meters - settings.MinRange) / span
+			img.Set(x, y, color.RGBA{
+				R: uint8(255 * (1 - t)),
+				G: uint8(255 * (1 - math.Abs(2*t-1))),
+				B: uint8(255 * t),
+				A: 255,
+			})
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 80}); err != nil {
+		return "", fmt.Errorf("failed to encode depth rendering: %w", err)
+	}
+	return "data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+func round2(value float64) float64 {
+	return math.Round(value*100) / 100
+}
@@ -0,0 +1,205 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/testmode"
+)
+
+const (
+	EMBEDDING_PROVIDER_INTEGRATED = "integrated"
+	EMBEDDING_PROVIDER_OPENAI     = "openai"
+	EMBEDDING_PROVIDER_COHERE     = "cohere"
+	EMBEDDING_PROVIDER_HTTP       = "http"
+)
+
+// Input types of an embedding request. Models trained for retrieval (e.g.
+// Cohere) embed stored documents and search queries differently.
+const (
+	EMBEDDING_INPUT_DOCUMENT = "document"
+	EMBEDDING_INPUT_QUERY    = "query"
+)
+
+const cohereEmbedURL = "https://api.cohere.com/v2/embed"
+
+var embeddingHTTPClient = &http.Client{
+	Timeout:   30 * time.Second,
+	Transport: testmode.Transport(testmode.TARGET_MEMORY, nil),
+}
+
+// Embedder turns texts into dense vectors for Pinecone indexes without
+// integrated embeddings. It returns one vector per text, in order.
+type Embedder interface {
+	Embed(ctx context.Context, inputType string, texts []string) ([][]float32, error)
+}
+
+// ConfiguredEmbedder returns the embedder selected by EMBEDDING_PROVIDER, or
+// nil for the default "integrated", where Pinecone embeds the text with its
+// hosted model. The settings are read per call so a reload applies them:
+//   - openai: the OpenAI embeddings API (EMBEDDING_API_KEY, falling back to
+//     OPENAI_API_KEY)
+//   - cohere: the Cohere v2 embed API
+//   - http: any server speaking the OpenAI embeddings API at EMBEDDING_URL,
+//     e.g. a local sentence-transformers model behind text-embeddings-inference
+//
+// EMBEDDING_MODEL picks the model and EMBEDDING_DIMENSIONS shortens vectors
+// of models that support it; both must match the index.
+func ConfiguredEmbedder() (Embedder, error) {
+	cfg := CurrentConfig()
+	provider := strings.ToLower(strings.TrimSpace(cfg.EmbeddingProvider))
+	model := cfg.EmbeddingModel
+	apiKey := cfg.EmbeddingAPIKey
+	dimensions := cfg.EmbeddingDimensions
+
+	switch provider {
+	case "", EMBEDDING_PROVIDER_INTEGRATED:
+		return nil, nil
+	case EMBEDDING_PROVIDER_OPENAI:
+		if apiKey == "" {
+			apiKey = cfg.OpenAIAPIKey
+		}
+		if model == "" {
+			model = EmbeddingModel
+		}
+		return &openAIEmbedder{url: openAIBaseURL() + "/embeddings", apiKey: apiKey, model: model, dimensions: dimensions}, nil
+	case EMBEDDING_PROVIDER_COHERE:
+		if apiKey == "" {
+			return nil, fmt.Errorf("EMBEDDING_API_KEY is required for the cohere embedding provider")
+		}
+		if model == "" {
+			model = "embed-english-v3.0"
+		}
+		return &cohereEmbedder{apiKey: apiKey, model: model, dimensions: dimensions}, nil
+	case EMBEDDING_PROVIDER_HTTP:
+		url := cfg.EmbeddingURL
+		if url == "" {
+			return nil, fmt.Errorf("EMBEDDING_URL is required for the http embedding provider")
+		}
+		return &openAIEmbedder{url: url, apiKey: apiKey, model: model, dimensions: dimensions}, nil
+	default:
+		return nil, fmt.Errorf("unknown EMBEDDING_PROVIDER %q", provider)
+	}
+}
+
+// openAIEmbedder calls an OpenAI-compatible /embeddings endpoint.
+type openAIEmbedder struct {
+	url        string
+	apiKey     string
+	model      string
+	dimensions int
+}
+
+func (e *openAIEmbedder) Embed(ctx context.Context, inputType string, texts []string) ([][]float32, error) {
+	request := map[string]interface{}{
+		"input": texts,
+	}
+	if e.model != "" {
+		request["model"] = e.model
+	}
+	if e.dimensions > 0 {
+		request["dimensions"] = e.dimensions
+	}
+
+	var response struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := postEmbeddingRequest(ctx, e.url, e.apiKey, request, &response); err != nil {
+		return nil, err
+	}
+	sort.Slice(response.Data, func(i, j int) bool { return response.Data[i].Index < response.Data[j].Index })
+
+	vectors := make([][]float32, len(response.Data))
+	for i, item := range response.Data {
+		vectors[i] = item.Embedding
+	}
+	return checkEmbeddings(vectors, len(texts))
+}
+
+// cohereEmbedder calls the Cohere v2 embed API.
+type cohereEmbedder struct {
+	apiKey     string
+	model      string
+	dimensions int
+}
+
+func (e *cohereEmbedder) Embed(ctx context.Context, inputType string, texts []string) ([][]float32, error) {
+	cohereInputType := "search_document"
+	if inputType == EMBEDDING_INPUT_QUERY {
+		cohereInputType = "search_query"
+	}
+	request := map[string]interface{}{
+		"model":           e.model,
+		"texts":           texts,
+		"input_type":      cohereInputType,
+		"embedding_types": []string{"float"},
+	}
+	if e.dimensions > 0 {
+		request["output_dimension"] = e.dimensions
+	}
+
+	var response struct {
+		Embeddings struct {
+			Float [][]float32 `json:"float"`
+		} `json:"embeddings"`
+	}
+	if err := postEmbeddingRequest(ctx, cohereEmbedURL, e.apiKey, request, &response); err != nil {
+		return nil, err
+	}
+	return checkEmbeddings(response.Embeddings.Float, len(texts))
+}
+
+func postEmbeddingRequest(ctx context.Context, url, apiKey string, request, out interface{}) error {
+	requestBody, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("failed to marshal embedding request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(requestBody))
+	if err != nil {
+		return fmt.Errorf("failed to create embedding request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+
+	resp, err := embeddingHTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call embedding API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read embedding response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("embedding API returned %d: %s", resp.StatusCode, body)
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("failed to decode embedding response: %w", err)
+	}
+	return nil
+}
+
+func checkEmbeddings(vectors [][]float32, expected int) ([][]float32, error) {
+	if len(vectors) != expected {
+		return nil, fmt.Errorf("embedding API returned %d vectors for %d texts", len(vectors), expected)
+	}
+	for _, vector := range vectors {
+		if len(vector) == 0 {
+			return nil, fmt.Errorf("embedding API returned an empty vector")
+		}
+	}
+	return vectors, nil
+}
@@ -0,0 +1,507 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// artifactEnvelopePrefix marks an encrypted artifact. Values without it are
+// legacy plaintext and are returned unchanged.
+const artifactEnvelopePrefix = "enc:v1:"
+
+var ErrUnknownEncryptionKey = errors.New("unknown encryption key")
+
+// KeyProvider wraps and unwraps data keys with a key encryption key that never
+// leaves it, as a KMS does. The additional data binds a wrapped key to the
+// tenant it was issued for.
+type KeyProvider interface {
+	WrapKey(ctx context.Context, keyID string, dataKey, aad []byte) ([]byte, error)
+	UnwrapKey(ctx context.Context, keyID string, wrapped, aad []byte) ([]byte, error)
+}
+
+// LocalKeyProvider holds AES-256 key encryption keys in memory. It stands in
+// for a KMS when keys are distributed through the environment.
+type LocalKeyProvider struct {
+	keys map[string]cipher.AEAD
+}
+
+// NewLocalKeyProvider parses ENCRYPTION_KEYS-style specs such as
+// "tenant-a-2024=<base64 key>,tenant-a-2025=<base64 key>". Every key must
+// decode to 32 bytes.
+func NewLocalKeyProvider(spec string) (*LocalKeyProvider, error) {
+	p := &LocalKeyProvider{keys: make(map[string]cipher.AEAD)}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		id, encoded, ok := strings.Cut(entry, "=")
+		if !ok || id == "" {
+			return nil, fmt.Errorf("invalid encryption key entry %q, want id=base64key", entry)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("encryption key %s is not valid base64: %w", id, err)
+		}
+		if len(key) != 32 {
+			return nil, fmt.Errorf("encryption key %s must be 32 bytes, got %d", id, len(key))
+		}
+		aead, err := newGCM(key)
+		if err != nil {
+			return nil, err
+		}
+		p.keys[id] = aead
+	}
+	return p, nil
+}
+
+func (p *LocalKeyProvider) WrapKey(ctx context.Context, keyID string, dataKey, aad []byte) ([]byte, error) {
+	aead, ok := p.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownEncryptionKey, keyID)
+	}
+	return sealGCM(aead, dataKey, aad)
+}
+
+func (p *LocalKeyProvider) UnwrapKey(ctx context.Context, keyID string, wrapped, aad []byte) ([]byte, error) {
+	aead, ok := p.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownEncryptionKey, keyID)
+	}
+	return openGCM(aead, wrapped, aad)
+}
+
+// artifactEnvelope is the stored form of an encrypted artifact: the payload
+// is sealed with a random data key, and the data key is wrapped with the
+// tenant's key encryption key.
+type artifactEnvelope struct {
+	KeyID    string `json:"kid"`
+	TenantID string `json:"tid"`
+	DataKey  []byte `json:"dk"`
+	Data     []byte `json:"ct"`
+}
+
+// ArtifactCipher encrypts session artifacts persisted to Redis with
+// AES-256-GCM envelope encryption under a per-tenant key. Tenants without a
+// key ID are stored in plaintext.
+type ArtifactCipher struct {
+	provider KeyProvider
+	keyFor   func(tenantID string) string
+}
+
+// NewArtifactCipher uses keyFor to find the current key ID of a tenant.
+// Rotating a tenant's key only changes what keyFor returns; older key IDs
+// must remain available from the provider until RotateArtifactKeys has
+// rewrapped everything sealed under them.
+func NewArtifactCipher(provider KeyProvider, keyFor func(tenantID string) string) *ArtifactCipher {
+	return &ArtifactCipher{provider: provider, keyFor: keyFor}
+}
+
+var (
+	artifactCipherMu sync.RWMutex
+	artifactCipher   *ArtifactCipher
+)
+
+// SetArtifactCipher replaces the cipher used by the archive, snapshot,
+// feedback and world state stores.
+func SetArtifactCipher(c *ArtifactCipher) {
+	artifactCipherMu.Lock()
+	artifactCipher = c
+	artifactCipherMu.Unlock()
+}
+
+// ArtifactCipherFromConfig reads key encryption keys from ENCRYPTION_KEYS
+// and applies ENCRYPTION_KEY_ID to every tenant. Servers use
+// ConfigureArtifactEncryption instead, which honors per-tenant key IDs.
+func ArtifactCipherFromConfig(cfg *Config) (*ArtifactCipher, error) {
+	provider, err := NewLocalKeyProvider(cfg.EncryptionKeys)
+	if err != nil {
+		return nil, err
+	}
+	keyID := cfg.EncryptionKeyID
+	return NewArtifactCipher(provider, func(string) string { return keyID }), nil
+}
+
+// ConfigureArtifactEncryption encrypts artifacts under each tenant's current
+// EncryptionKeyID and fails if a tenant refers to a key that is not loaded.
+func ConfigureArtifactEncryption(tenants *TenantStore) error {
+	provider, err := NewLocalKeyProvider(CurrentConfig().EncryptionKeys)
+	if err != nil {
+		return err
+	}
+	for _, tenant := range tenants.All() {
+		if tenant.EncryptionKeyID == "" {
+			continue
+		}
+		if _, ok := provider.keys[tenant.EncryptionKeyID]; !ok {
+			return fmt.Errorf("tenant %s: %w: %s", tenant.ID, ErrUnknownEncryptionKey, tenant.EncryptionKeyID)
+		}
+	}
+
+	SetArtifactCipher(NewArtifactCipher(provider, func(tenantID string) string {
+		if tenant := tenants.Current(tenantID); tenant != nil {
+			return tenant.EncryptionKeyID
+		}
+		return ""
+	}))
+	zap.L().Info("Configured artifact encryption", zap.Int("keys", len(provider.keys)))
+	return nil
+}
+
+// artifacts returns the configured cipher, falling back to the installed
+// configuration for tools that never call ConfigureArtifactEncryption.
+func artifacts() (*ArtifactCipher, error) {
+	artifactCipherMu.RLock()
+	c := artifactCipher
+	artifactCipherMu.RUnlock()
+	if c != nil {
+		return c, nil
+	}
+
+	c, err := ArtifactCipherFromConfig(CurrentConfig())
+	if err != nil {
+		return nil, fmt.Errorf("failed to load encryption keys: %w", err)
+	}
+	artifactCipherMu.Lock()
+	if artifactCipher == nil {
+		artifactCipher = c
+	}
+	c = artifactCipher
+	artifactCipherMu.Unlock()
+	return c, nil
+}
+
+// Seal encrypts an artifact of a tenant under the tenant's current key, or
+// returns it unchanged if the tenant has none.
+func (c *ArtifactCipher) Seal(ctx context.Context, tenantID string, plaintext []byte) ([]byte, error) {
+	keyID := c.keyFor(tenantID)
+	if keyID == "" {
+		return plaintext, nil
+	}
+
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	aad := []byte(tenantID)
+	data, err := sealGCM(aead, plaintext, aad)
+	if err != nil {
+		return nil, err
+	}
+	wrapped, err := c.provider.WrapKey(ctx, keyID, dataKey, aad)
+	if err != nil {
+		return nil, fmt.Errorf("failed to wrap data key: %w", err)
+	}
+	return marshalEnvelope(artifactEnvelope{KeyID: keyID, TenantID: tenantID, DataKey: wrapped, Data: data})
+}
+
+// Open decrypts a sealed artifact. Plaintext artifacts written before
+// encryption was enabled are returned unchanged.
+func (c *ArtifactCipher) Open(ctx context.Context, stored []byte) ([]byte, error) {
+	if !bytes.HasPrefix(stored, []byte(artifactEnvelopePrefix)) {
+		return stored, nil
+	}
+	envelope, err := unmarshalEnvelope(stored)
+	if err != nil {
+		return nil, err
+	}
+
+	aad := []byte(envelope.TenantID)
+	dataKey, err := c.provider.UnwrapKey(ctx, envelope.KeyID, envelope.DataKey, aad)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := openGCM(aead, envelope.Data, aad)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt artifact: %w", err)
+	}
+	return plaintext, nil
+}
+
+// rewrap re-wraps the data key of an artifact sealed under a key other than
+// its tenant's current one. The payload ciphertext is left untouched.
+func (c *ArtifactCipher) rewrap(ctx context.Context, stored []byte) ([]byte, bool, error) {
+	if !bytes.HasPrefix(stored, []byte(artifactEnvelopePrefix)) {
+		return nil, false, nil
+	}
+	envelope, err := unmarshalEnvelope(stored)
+	if err != nil {
+		return nil, false, err
+	}
+	current := c.keyFor(envelope.TenantID)
+	if current == "" || current == envelope.KeyID {
+		return nil, false, nil
+	}
+
+	aad := []byte(envelope.TenantID)
+	dataKey, err := c.provider.UnwrapKey(ctx, envelope.KeyID, envelope.DataKey, aad)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+	wrapped, err := c.provider.WrapKey(ctx, current, dataKey, aad)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to wrap data key: %w", err)
+	}
+	envelope.KeyID = current
+	envelope.DataKey = wrapped
+	rewrapped, err := marshalEnvelope(envelope)
+	return rewrapped, err == nil, err
+}
+
+// sealArtifact and openArtifact are used by the Redis stores.
+func sealArtifact(ctx context.Context, tenantID string, plaintext []byte) ([]byte, error) {
+	c, err := artifacts()
+	if err != nil {
+		return nil, err
+	}
+	return c.Seal(ctx, tenantID, plaintext)
+}
+
+func openArtifact(ctx context.Context, stored []byte) ([]byte, error) {
+	if !bytes.HasPrefix(stored, []byte(artifactEnvelopePrefix)) {
+		return stored, nil
+	}
+	c, err := artifacts()
+	if err != nil {
+		return nil, err
+	}
+	return c.Open(ctx, stored)
+}
+
+// marshalArtifact and unmarshalArtifact combine JSON encoding with sealing.
+func marshalArtifact(ctx context.Context, tenantID string, v any) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return sealArtifact(ctx, tenantID, data)
+}
+
+func unmarshalArtifact(ctx context.Context, stored []byte, v any) error {
+	data, err := openArtifact(ctx, stored)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// Compare-and-swap updates, so a rotation never overwrites an artifact
+// changed or trimmed since it was read.
+var (
+	rewrapListItem = redis.NewScript(`
+if redis.call('LINDEX', KEYS[1], ARGV[1]) == ARGV[2] then
+	redis.call('LSET', KEYS[1], ARGV[1], ARGV[3])
+	return 1
+end
+return 0`)
+	rewrapString = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	redis.call('SET', KEYS[1], ARGV[2], 'KEEPTTL')
+	return 1
+end
+return 0`)
+	rewrapHashField = redis.NewScript(`
+if redis.call('HGET', KEYS[1], ARGV[1]) == ARGV[2] then
+	redis.call('HSET', KEYS[1], ARGV[1], ARGV[3])
+	return 1
+end
+return 0`)
+)
+
+// Kinds of Redis values holding sealed artifacts.
+const (
+	storedString = iota
+	storedList
+	storedHash
+)
+
+// RotateArtifactKeys rewraps every stored artifact whose data key is wrapped
+// under a key other than its tenant's current one, and returns the number of
+// artifacts rewrapped. Only Redis is covered: frames and recordings are
+// rotated by RotateFrameKeys and RotateRecordingKeys. Afterwards the old key
+// can be retired.
+func RotateArtifactKeys(ctx context.Context, rdb *redis.Client) (int, error) {
+	c, err := artifacts()
+	if err != nil {
+		return 0, err
+	}
+
+	rotated := 0
+	rotateKey := func(key string, kind int) error {
+		switch kind {
+		case storedList:
+			items, err := rdb.LRange(ctx, key, 0, -1).Result()
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %w", key, err)
+			}
+			for i, item := range items {
+				rewrapped, changed, err := c.rewrap(ctx, []byte(item))
+				if err != nil {
+					return fmt.Errorf("failed to rewrap %s[%d]: %w", key, i, err)
+				}
+				if !changed {
+					continue
+				}
+				swapped, err := rewrapListItem.Run(ctx, rdb, []string{key}, i, item, rewrapped).Int()
+				if err != nil {
+					return fmt.Errorf("failed to update %s: %w", key, err)
+				}
+				rotated += swapped
+			}
+			return nil
+		case storedHash:
+			fields, err := rdb.HGetAll(ctx, key).Result()
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %w", key, err)
+			}
+			for field, item := range fields {
+				rewrapped, changed, err := c.rewrap(ctx, []byte(item))
+				if err != nil {
+					return fmt.Errorf("failed to rewrap %s[%s]: %w", key, field, err)
+				}
+				if !changed {
+					continue
+				}
+				swapped, err := rewrapHashField.Run(ctx, rdb, []string{key}, field, item, rewrapped).Int()
+				if err != nil {
+					return fmt.Errorf("failed to update %s: %w", key, err)
+				}
+				rotated += swapped
+			}
+			return nil
+		}
+
+		item, err := rdb.Get(ctx, key).Result()
+		if err == redis.Nil {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", key, err)
+		}
+		rewrapped, changed, err := c.rewrap(ctx, []byte(item))
+		if err != nil {
+			return fmt.Errorf("failed to rewrap %s: %w", key, err)
+		}
+		if !changed {
+			return nil
+		}
+		swapped, err := rewrapString.Run(ctx, rdb, []string{key}, item, rewrapped).Int()
+		if err != nil {
+			return fmt.Errorf("failed to update %s: %w", key, err)
+		}
+		rotated += swapped
+		return nil
+	}
+
+	if err := rotateKey(legacyAnalysisArchiveKey, storedList); err != nil {
+		return rotated, err
+	}
+	stores := []struct {
+		pattern string
+		kind    int
+	}{
+		{analysisArchiveKeyPrefix + "*", storedList},
+		{sessionArchiveKeyPrefix + "*", storedList},
+		{feedbackKeyPrefix + "*", storedList},
+		{sessionMetaKeyPrefix + "*", storedString},
+		{sessionSnapshotKeyPrefix + "*", storedString},
+		{worldStateKeyPrefix + "*", storedString},
+		{orchestratorRoutesKeyPrefix + "*", storedString},
+		{preferencesKeyPrefix + "*", storedString},
+		{sessionSummaryKeyPrefix + "*", storedString},
+		{robotKeyPrefix + "*", storedString},
+		{recordingKeyPrefix + "*", storedString},
+		{siteMemoryKeyPrefix + "*", storedHash},
+		{transcriptDocsKeyPrefix + "*", storedHash},
+	}
+	for _, store := range stores {
+		iter := rdb.Scan(ctx, 0, store.pattern, 100).Iterator()
+		for iter.Next(ctx) {
+			if err := rotateKey(iter.Val(), store.kind); err != nil {
+				return rotated, err
+			}
+		}
+		if err := iter.Err(); err != nil {
+			return rotated, fmt.Errorf("failed to scan %s: %w", store.pattern, err)
+		}
+	}
+	return rotated, nil
+}
+
+func marshalEnvelope(envelope artifactEnvelope) ([]byte, error) {
+	data, err := json.Marshal(envelope)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal artifact envelope: %w", err)
+	}
+	return append([]byte(artifactEnvelopePrefix), data...), nil
+}
+
+// artifactTenant returns the tenant a sealed artifact was sealed for, which
+// is readable without its key, or "" for plaintext.
+func artifactTenant(stored []byte) string {
+	if !bytes.HasPrefix(stored, []byte(artifactEnvelopePrefix)) {
+		return ""
+	}
+	envelope, err := unmarshalEnvelope(stored)
+	if err != nil {
+		return ""
+	}
+	return envelope.TenantID
+}
+
+func unmarshalEnvelope(stored []byte) (artifactEnvelope, error) {
+	var envelope artifactEnvelope
+	if err := json.Unmarshal(stored[len(artifactEnvelopePrefix):], &envelope); err != nil {
+		return envelope, fmt.Errorf("failed to decode artifact envelope: %w", err)
+	}
+	return envelope, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+	return aead, nil
+}
+
+// sealGCM prepends a random nonce to the ciphertext.
+func sealGCM(aead cipher.AEAD, plaintext, aad []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return aead.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+func openGCM(aead cipher.AEAD, sealed, aad []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, aad)
+}
@@ -0,0 +1,82 @@
+package utils_test
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func keySpec(ids ...string) string {
+	entries := make([]string, len(ids))
+	for i, id := range ids {
+		entries[i] = id + "=" + base64.StdEncoding.EncodeToString([]byte(strings.Repeat(id[len(id)-1:], 32)))
+	}
+	return strings.Join(entries, ",")
+}
+
+func useKeys(t *testing.T, current string, ids ...string) {
+	t.Helper()
+	provider, err := utils.NewLocalKeyProvider(keySpec(ids...))
+	if err != nil {
+		t.Fatalf("NewLocalKeyProvider() error = %v", err)
+	}
+	utils.SetArtifactCipher(utils.NewArtifactCipher(provider, func(string) string { return current }))
+}
+
+func TestRotationRewrapsSiteMemoryAndFrames(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer rdb.Close()
+	frames := &utils.LocalFrameStore{Dir: t.TempDir()}
+	t.Cleanup(func() { utils.SetArtifactCipher(nil) })
+
+	useKeys(t, "old1", "old1")
+	observation := models.SiteObservation{Site: "warehouse", Location: "dock", Overview: "pallets", SessionID: "s1", ContextID: "c1", ObservedAt: time.Now()}
+	if _, err := utils.MergeSiteObservation(ctx, rdb, "acme", observation, 0); err != nil {
+		t.Fatalf("MergeSiteObservation() error = %v", err)
+	}
+	if err := utils.StoreFrame(ctx, frames, "acme", "c1", base64.StdEncoding.EncodeToString([]byte("frame"))); err != nil {
+		t.Fatalf("StoreFrame() error = %v", err)
+	}
+
+	useKeys(t, "new2", "old1", "new2")
+	rotated, err := utils.RotateArtifactKeys(ctx, rdb)
+	if err != nil {
+		t.Fatalf("RotateArtifactKeys() error = %v", err)
+	}
+	if rotated != 1 {
+		t.Errorf("RotateArtifactKeys() = %d, want 1", rotated)
+	}
+	rotated, err = utils.RotateFrameKeys(ctx, frames)
+	if err != nil {
+		t.Fatalf("RotateFrameKeys() error = %v", err)
+	}
+	if rotated != 1 {
+		t.Errorf("RotateFrameKeys() = %d, want 1", rotated)
+	}
+
+	// The old key is retired: everything must open under the new one
+	useKeys(t, "new2", "new2")
+	stored, err := utils.LoadSiteMemory(ctx, rdb, "acme", "warehouse")
+	if err != nil {
+		t.Fatalf("LoadSiteMemory() error = %v", err)
+	}
+	if len(stored) != 1 || stored[0].Overview != "pallets" {
+		t.Errorf("LoadSiteMemory() = %+v, want the dock observation", stored)
+	}
+	frame, _, err := utils.LoadFrame(ctx, frames, "acme", "c1")
+	if err != nil {
+		t.Fatalf("LoadFrame() error = %v", err)
+	}
+	if string(frame) != "frame" {
+		t.Errorf("LoadFrame() = %q, want %q", frame, "frame")
+	}
+}
@@ -0,0 +1,267 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
+	"github.com/redis/go-redis/v9"
+)
+
+// ListTenantSessions returns the sessions of a tenant whose description is
+// still stored and matches, in no particular order, and the IDs of the
+// tenant's sessions whose description is sealed under a key that is not
+// available, which cannot be matched.
+func ListTenantSessions(ctx context.Context, rdb *redis.Client, tenantID string, match func(models.SessionMeta) bool) ([]models.SessionMeta, []string, error) {
+	var sessions []models.SessionMeta
+	var unreadable []string
+	iter := rdb.Scan(ctx, 0, sessionMetaKeyPrefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		data, err := rdb.Get(ctx, iter.Val()).Bytes()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to load session meta: %w", err)
+		}
+		var meta models.SessionMeta
+		if err := unmarshalArtifact(ctx, data, &meta); err != nil {
+			// Another tenant's key may be unavailable
+			if artifactTenant(data) == tenantID {
+				unreadable = append(unreadable, strings.TrimPrefix(iter.Val(), sessionMetaKeyPrefix))
+			}
+			continue
+		}
+		if meta.TenantID == tenantID && match(meta) {
+			sessions = append(sessions, meta)
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to scan sessions: %w", err)
+	}
+	return sessions, unreadable, nil
+}
+
+// EraseSessionArchive deletes what Redis keeps of a session apart from its
+// description: the archived analyses (records, loaded by the caller before
+// the frames and memory records made from them were deleted), world state,
+// snapshot, summary, the feedback on its intentions and their entries in the
+// transcript index. The session's entries in the tenant archive are left to
+// PurgeArchivedSessions.
+func EraseSessionArchive(ctx context.Context, rdb *redis.Client, tenantID, sessionID string, records []models.AnalysisRecord, report *models.DeletionReport) error {
+	summary, err := LoadSessionSummary(ctx, rdb, sessionID)
+	if err != nil {
+		return err
+	}
+
+	pipe := rdb.TxPipeline()
+	var deleted, feedback []*redis.IntCmd
+	for _, key := range []string{
+		sessionArchiveKeyPrefix + sessionID,
+		worldStateKeyPrefix + sessionID,
+		sessionSnapshotKeyPrefix + sessionID,
+		sessionSummaryKeyPrefix + sessionID,
+	} {
+		deleted = append(deleted, pipe.Del(ctx, key))
+	}
+	if summary != nil {
+		pipe.ZRem(ctx, sessionSummaryIndexKey(tenantID, summary.RobotID), sessionID)
+		report.Summaries++
+	}
+	for _, record := range records {
+		switch record.Kind {
+		case models.ANALYSIS_KIND_INTENTION:
+			report.Transcripts++
+			feedback = append(feedback, pipe.Del(ctx, feedbackKeyPrefix+record.ID))
+			pipe.ZRem(ctx, feedbackIndexKeyPrefix+tenantID, sessionID+":"+record.ID)
+		case models.ANALYSIS_KIND_VISION:
+			report.EnvironmentContexts++
+		}
+	}
+	unindexTranscripts(ctx, pipe, tenantID, records)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to delete session data: %w", err)
+	}
+	for _, cmd := range deleted {
+		report.RedisKeys += int(cmd.Val())
+	}
+	for _, cmd := range feedback {
+		report.Feedback += int(cmd.Val())
+		report.RedisKeys += int(cmd.Val())
+	}
+	return nil
+}
+
+// DeleteSessionMeta deletes a session's description, after which the
+// session can no longer be looked up.
+func DeleteSessionMeta(ctx context.Context, rdb *redis.Client, sessionID string) error {
+	if err := rdb.Del(ctx, sessionMetaKeyPrefix+sessionID).Err(); err != nil {
+		return fmt.Errorf("failed to delete session meta: %w", err)
+	}
+	return nil
+}
+
+// PurgeArchivedSessions removes the records of the given sessions from their
+// tenant's analysis archive and from stored re-analysis results, and returns
+// how many it removed. Records whose key is unavailable cannot be matched
+// and are left to the archive retention.
+func PurgeArchivedSessions(ctx context.Context, rdb *redis.Client, tenantID string, sessionIDs map[string]bool) (int, error) {
+	archiveKey := analysisArchiveKey(tenantID)
+	raw, err := rdb.LRange(ctx, archiveKey, 0, -1).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read analysis archive: %w", err)
+	}
+	purged := 0
+	recordIDs := make(map[string]bool)
+	for _, item := range raw {
+		var record models.AnalysisRecord
+		if err := unmarshalArtifact(ctx, []byte(item), &record); err != nil || !sessionIDs[record.SessionID] {
+			continue
+		}
+		recordIDs[record.ID] = true
+		removed, err := rdb.LRem(ctx, archiveKey, 1, item).Result()
+		if err != nil {
+			return purged, fmt.Errorf("failed to purge analysis archive: %w", err)
+		}
+		purged += int(removed)
+	}
+
+	iter := rdb.Scan(ctx, 0, reanalysisKeyPrefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		removed, err := purgeReanalysis(ctx, rdb, iter.Val(), sessionIDs, recordIDs)
+		if err != nil {
+			return purged, err
+		}
+		purged += removed
+	}
+	if err := iter.Err(); err != nil {
+		return purged, fmt.Errorf("failed to scan reanalysis results: %w", err)
+	}
+	return purged, nil
+}
+
+// purgeReanalysis drops the results and diffs of the sessions from one
+// re-analysis batch.
+func purgeReanalysis(ctx context.Context, rdb *redis.Client, key string, sessionIDs, recordIDs map[string]bool) (int, error) {
+	fields, err := rdb.HGetAll(ctx, key).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read reanalysis results: %w", err)
+	}
+	var stale []string
+	for field := range fields {
+		if id, ok := strings.CutPrefix(field, "result:"); ok && recordIDs[id] {
+			stale = append(stale, field)
+		}
+	}
+
+	var report models.ReanalysisReport
+	rewrite := false
+	if err := json.Unmarshal([]byte(fields["report"]), &report); err == nil {
+		kept := slices.DeleteFunc(slices.Clone(report.Diffs), func(diff models.AnalysisDiff) bool { return sessionIDs[diff.SessionID] })
+		rewrite = len(kept) < len(report.Diffs)
+		report.Diffs = kept
+	}
+	if len(stale) == 0 && !rewrite {
+		return 0, nil
+	}
+
+	pipe := rdb.TxPipeline()
+	if len(stale) > 0 {
+		pipe.HDel(ctx, key, stale...)
+	}
+	if rewrite {
+		data, err := json.Marshal(report)
+		if err != nil {
+			return 0, fmt.Errorf("failed to marshal reanalysis report: %w", err)
+		}
+		pipe.HSet(ctx, key, "report", string(data))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, fmt.Errorf("failed to purge reanalysis results: %w", err)
+	}
+	return len(stale), nil
+}
+
+// ErasePreferences removes the tenant's preferences that match, learned for
+// any user or robot, and returns their IDs for removal from the memory
+// store.
+func ErasePreferences(ctx context.Context, rdb *redis.Client, tenantID string, match func(models.Preference) bool) ([]string, error) {
+	prefix := preferencesKeyPrefix + tenantID + ":"
+	var removed []string
+	iter := rdb.Scan(ctx, 0, prefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		subject := strings.TrimPrefix(iter.Val(), prefix)
+		ids, err := updatePreferences(ctx, rdb, tenantID, subject, func(preferences []models.Preference) []models.Preference {
+			return slices.DeleteFunc(slices.Clone(preferences), match)
+		})
+		if err != nil {
+			return removed, err
+		}
+		removed = append(removed, ids...)
+	}
+	if err := iter.Err(); err != nil {
+		return removed, fmt.Errorf("failed to scan preferences: %w", err)
+	}
+	return removed, nil
+}
+
+// EraseSiteObservations removes the tenant's site observations that match
+// and returns their memory store IDs. A location keeps only its latest
+// observation, so one a later session made is not from the earlier one.
+func EraseSiteObservations(ctx context.Context, rdb *redis.Client, tenantID string, match func(models.SiteObservation) bool) ([]string, error) {
+	prefix := siteMemoryKeyPrefix + tenantID + ":"
+	var removed []string
+	iter := rdb.Scan(ctx, 0, prefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		site := strings.TrimPrefix(iter.Val(), prefix)
+		observations, err := LoadSiteMemory(ctx, rdb, tenantID, site)
+		if err != nil {
+			return removed, err
+		}
+		for _, observation := range observations {
+			if !match(observation) {
+				continue
+			}
+			if err := rdb.HDel(ctx, iter.Val(), observation.Location).Err(); err != nil {
+				return removed, fmt.Errorf("failed to delete site observation: %w", err)
+			}
+			removed = append(removed, SiteObservationID(site, observation.Location))
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return removed, fmt.Errorf("failed to scan site memory: %w", err)
+	}
+	return removed, nil
+}
+
+// DeleteSessionRecordings deletes a session's recordings, their files and
+// index entries, and returns how many it deleted.
+func DeleteSessionRecordings(ctx context.Context, rdb *redis.Client, dir, tenantID, sessionID string) (int, error) {
+	sessionKey := sessionRecordingKeyPrefix + tenantID + ":" + sessionID
+	ids, err := rdb.ZRange(ctx, sessionKey, 0, -1).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to list recordings: %w", err)
+	}
+	deleted := 0
+	for _, id := range ids {
+		if path, err := recordingPath(dir, tenantID, id); err == nil {
+			if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return deleted, fmt.Errorf("failed to delete recording: %w", err)
+			}
+		}
+		pipe := rdb.TxPipeline()
+		pipe.Del(ctx, recordingKeyPrefix+tenantID+":"+id)
+		pipe.ZRem(ctx, recordingIndexKey, tenantID+"/"+id)
+		pipe.ZRem(ctx, sessionKey, id)
+		if _, err := pipe.Exec(ctx); err != nil {
+			return deleted, fmt.Errorf("failed to delete recording: %w", err)
+		}
+		deleted++
+	}
+	return deleted, nil
+}
@@ -0,0 +1,46 @@
+package utils_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestListTenantSessionsReportsUnreadable(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer rdb.Close()
+	t.Cleanup(func() { utils.SetArtifactCipher(nil) })
+
+	useKeys(t, "old1", "old1")
+	for _, meta := range []models.SessionMeta{
+		{ID: "lost", TenantID: "acme"},
+		{ID: "other", TenantID: "globex"},
+	} {
+		if err := utils.SaveSessionMeta(ctx, rdb, meta); err != nil {
+			t.Fatalf("SaveSessionMeta() error = %v", err)
+		}
+	}
+	useKeys(t, "new2", "new2")
+	if err := utils.SaveSessionMeta(ctx, rdb, models.SessionMeta{ID: "kept", TenantID: "acme"}); err != nil {
+		t.Fatalf("SaveSessionMeta() error = %v", err)
+	}
+
+	sessions, unreadable, err := utils.ListTenantSessions(ctx, rdb, "acme", func(models.SessionMeta) bool { return true })
+	if err != nil {
+		t.Fatalf("ListTenantSessions() error = %v", err)
+	}
+	if len(sessions) != 1 || sessions[0].ID != "kept" {
+		t.Errorf("sessions = %+v, want kept", sessions)
+	}
+	// Another tenant's sessions are not the caller's concern
+	if !slices.Equal(unreadable, []string{"lost"}) {
+		t.Errorf("unreadable = %v, want [lost]", unreadable)
+	}
+}
@@ -0,0 +1,135 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	feedbackKeyPrefix      = "perceptus:intention_feedback:"
+	feedbackIndexKeyPrefix = "perceptus:intention_feedback_index:"
+)
+
+// FindSessionAnalysis returns the archived record with the given ID from a
+// session's archive.
+func FindSessionAnalysis(ctx context.Context, rdb *redis.Client, sessionID, recordID string) (*models.AnalysisRecord, error) {
+	records, err := LoadSessionAnalyses(ctx, rdb, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	for _, record := range records {
+		if record.ID == recordID {
+			return &record, nil
+		}
+	}
+	return nil, fmt.Errorf("analysis %s not found in session %s", recordID, sessionID)
+}
+
+// SaveIntentionFeedback appends a label to an intention and indexes the
+// intention under its tenant for export. Feedback shares the retention of the
+// session archive it refers to.
+func SaveIntentionFeedback(ctx context.Context, rdb *redis.Client, feedback models.IntentionFeedback) error {
+	data, err := marshalArtifact(ctx, feedback.TenantID, feedback)
+	if err != nil {
+		return fmt.Errorf("failed to encode intention feedback: %w", err)
+	}
+
+	key := feedbackKeyPrefix + feedback.IntentionID
+	indexKey := feedbackIndexKeyPrefix + feedback.TenantID
+
+	pipe := rdb.TxPipeline()
+	pipe.RPush(ctx, key, data)
+	pipe.Expire(ctx, key, sessionArchiveRetention)
+	pipe.ZAdd(ctx, indexKey, redis.Z{
+		Score:  float64(feedback.Timestamp.Unix()),
+		Member: feedback.SessionID + ":" + feedback.IntentionID,
+	})
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to save intention feedback: %w", err)
+	}
+	return nil
+}
+
+// LoadIntentionFeedback returns every label of an intention in order.
+func LoadIntentionFeedback(ctx context.Context, rdb *redis.Client, intentionID string) ([]models.IntentionFeedback, error) {
+	raw, err := rdb.LRange(ctx, feedbackKeyPrefix+intentionID, 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read intention feedback: %w", err)
+	}
+
+	feedback := make([]models.IntentionFeedback, 0, len(raw))
+	for _, item := range raw {
+		var entry models.IntentionFeedback
+		if err := unmarshalArtifact(ctx, []byte(item), &entry); err != nil {
+			continue
+		}
+		feedback = append(feedback, entry)
+	}
+	return feedback, nil
+}
+
+// ExportLabeledIntentions calls fn for every intention of a tenant that
+// received feedback since the given time, oldest first. Intentions whose
+// archive has expired are skipped.
+func ExportLabeledIntentions(ctx context.Context, rdb *redis.Client, tenantID string, since time.Time, fn func(models.LabeledIntention) error) error {
+	members, err := rdb.ZRangeByScore(ctx, feedbackIndexKeyPrefix+tenantID, &redis.ZRangeBy{
+		Min: strconv.FormatInt(since.Unix(), 10),
+		Max: "+inf",
+	}).Result()
+	if err != nil {
+		return fmt.Errorf("failed to read feedback index: %w", err)
+	}
+
+	sessions := make(map[string]map[string]models.AnalysisRecord)
+	for _, member := range members {
+		sep := strings.LastIndex(member, ":")
+		if sep < 0 {
+			continue
+		}
+		sessionID, intentionID := member[:sep], member[sep+1:]
+
+		records, ok := sessions[sessionID]
+		if !ok {
+			loaded, err := LoadSessionAnalyses(ctx, rdb, sessionID)
+			if err != nil {
+				return err
+			}
+			records = make(map[string]models.AnalysisRecord, len(loaded))
+			for _, record := range loaded {
+				records[record.ID] = record
+			}
+			sessions[sessionID] = records
+		}
+		record, ok := records[intentionID]
+		if !ok {
+			continue
+		}
+
+		feedback, err := LoadIntentionFeedback(ctx, rdb, intentionID)
+		if err != nil {
+			return err
+		}
+		if len(feedback) == 0 {
+			continue
+		}
+
+		if err := fn(models.LabeledIntention{
+			IntentionID:        intentionID,
+			SessionID:          sessionID,
+			Transcript:         record.Transcript,
+			EnvironmentContext: record.EnvironmentContext,
+			Result:             record.Result,
+			Feedback:           feedback,
+			Timestamp:          record.Timestamp,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
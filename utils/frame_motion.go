@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+)
+
+// Side of the luminance grid frames are compared on
+const frameSignatureSize = 32
+
+// FrameSignature is a coarse luminance grid of a frame for motion scoring.
+type FrameSignature []float64
+
+// SignFrame decodes a JPEG or PNG frame (raw base64 or data URL) and samples
+// its luminance on a frameSignatureSize square grid.
+func SignFrame(imageData string) (FrameSignature, error) {
+	raw, err := decodeDataURL(imageData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode frame: %w", err)
+	}
+	img, _, err := decodeFrame(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := img.Bounds()
+	if bounds.Dx() < 1 || bounds.Dy() < 1 {
+		return nil, fmt.Errorf("empty frame")
+	}
+	signature := make(FrameSignature, 0, frameSignatureSize*frameSignatureSize)
+	for row := 0; row < frameSignatureSize; row++ {
+		y := bounds.Min.Y + (2*row+1)*bounds.Dy()/(2*frameSignatureSize)
+		for col := 0; col < frameSignatureSize; col++ {
+			x := bounds.Min.X + (2*col+1)*bounds.Dx()/(2*frameSignatureSize)
+			r, g, b, _ := img.At(x, y).RGBA()
+			signature = append(signature, (0.299*float64(r)+0.587*float64(g)+0.114*float64(b))/257)
+		}
+	}
+	return signature, nil
+}
+
+// MotionScore returns how much changed between two frames, from 0 (same
+// scene) to 1: the mean absolute luminance difference of their signatures,
+// with the global brightness change removed so lighting shifts and auto
+// exposure do not count as motion. Without a previous frame it is 0.
+func MotionScore(previous, current FrameSignature) float64 {
+	if len(previous) == 0 || len(previous) != len(current) {
+		return 0
+	}
+	var offset float64
+	for i := range current {
+		offset += current[i] - previous[i]
+	}
+	offset /= float64(len(current))
+
+	var diff float64
+	for i := range current {
+		diff += math.Abs(current[i] - previous[i] - offset)
+	}
+	return math.Min(diff/float64(len(current))/255, 1)
+}
@@ -0,0 +1,133 @@
+package utils
+
+import (
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"math"
+)
+
+const (
+	FRAME_ISSUE_BLURRY       = "blurry"
+	FRAME_ISSUE_UNDEREXPOSED = "underexposed"
+	FRAME_ISSUE_OVEREXPOSED  = "overexposed"
+)
+
+const frameQualityAnalysisPixels = 320
+
+// FrameQuality scores a frame's fitness for scene analysis. Sharpness is the
+// variance of the Laplacian of the luminance; brightness is the mean
+// luminance (0-255); the clipped fractions count near-black and near-white
+// pixels.
+type FrameQuality struct {
+	Sharpness      float64  `json:"sharpness"`
+	Brightness     float64  `json:"brightness"`
+	DarkFraction   float64  `json:"dark_fraction"`
+	BrightFraction float64  `json:"bright_fraction"`
+	Issues         []string `json:"issues,omitempty"`
+}
+
+// FrameQualityThresholds are the limits below or above which a frame is
+// rejected.
+type FrameQualityThresholds struct {
+	MinSharpness  float64
+	MinBrightness float64
+	MaxBrightness float64
+	MaxClipped    float64
+}
+
+// FrameQualityThresholdsFromConfig reads FRAME_QUALITY_MIN_SHARPNESS,
+// FRAME_QUALITY_MIN_BRIGHTNESS, FRAME_QUALITY_MAX_BRIGHTNESS and
+// FRAME_QUALITY_MAX_CLIPPED.
+func FrameQualityThresholdsFromConfig(cfg *Config) FrameQualityThresholds {
+	return FrameQualityThresholds{
+		MinSharpness:  cfg.FrameQualityMinSharpness,
+		MinBrightness: cfg.FrameQualityMinBrightness,
+		MaxBrightness: cfg.FrameQualityMaxBrightness,
+		MaxClipped:    cfg.FrameQualityMaxClipped,
+	}
+}
+
+// ScoreFrame decodes a JPEG or PNG frame (raw base64 or data URL) and scores
+// its sharpness and exposure against the thresholds. Large frames are
+// sampled down to about frameQualityAnalysisPixels on the long side first.
+func ScoreFrame(imageData string, thresholds FrameQualityThresholds) (*FrameQuality, error) {
+	raw, err := decodeDataURL(imageData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode frame: %w", err)
+	}
+	img, _, err := decodeFrame(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	gray, width, height := luminance(img)
+	if width < 3 || height < 3 {
+		return nil, fmt.Errorf("frame too small to score (%dx%d)", width, height)
+	}
+
+	quality := &FrameQuality{}
+	var sum float64
+	var dark, bright int
+	for _, y := range gray {
+		sum += y
+		if y < 16 {
+			dark++
+		} else if y > 240 {
+			bright++
+		}
+	}
+	pixels := float64(len(gray))
+	quality.Brightness = sum / pixels
+	quality.DarkFraction = float64(dark) / pixels
+	quality.BrightFraction = float64(bright) / pixels
+
+	// Variance of the 4-neighbour Laplacian; low values mean few edges
+	var lapSum, lapSumSq float64
+	var count float64
+	for y := 1; y < height-1; y++ {
+		for x := 1; x < width-1; x++ {
+			i := y*width + x
+			lap := gray[i-1] + gray[i+1] + gray[i-width] + gray[i+width] - 4*gray[i]
+			lapSum += lap
+			lapSumSq += lap * lap
+			count++
+		}
+	}
+	mean := lapSum / count
+	quality.Sharpness = lapSumSq/count - mean*mean
+
+	if quality.Brightness < thresholds.MinBrightness || quality.DarkFraction > thresholds.MaxClipped {
+		quality.Issues = append(quality.Issues, FRAME_ISSUE_UNDEREXPOSED)
+	}
+	if quality.Brightness > thresholds.MaxBrightness || quality.BrightFraction > thresholds.MaxClipped {
+		quality.Issues = append(quality.Issues, FRAME_ISSUE_OVEREXPOSED)
+	}
+	// Exposure problems flatten edges too, so only call out blur on its own
+	if len(quality.Issues) == 0 && quality.Sharpness < thresholds.MinSharpness {
+		quality.Issues = append(quality.Issues, FRAME_ISSUE_BLURRY)
+	}
+	return quality, nil
+}
+
+// luminance returns the Rec. 601 luma of img, sampled with a stride so the
+// long side has about frameQualityAnalysisPixels pixels.
+func luminance(img image.Image) ([]float64, int, int) {
+	bounds := img.Bounds()
+	stride := int(math.Ceil(float64(max(bounds.Dx(), bounds.Dy())) / frameQualityAnalysisPixels))
+	if stride < 1 {
+		stride = 1
+	}
+
+	width := (bounds.Dx() + stride - 1) / stride
+	height := (bounds.Dy() + stride - 1) / stride
+	gray := make([]float64, 0, width*height)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += stride {
+		for x := bounds.Min.X; x < bounds.Max.X; x += stride {
+			r, g, b, _ := img.At(x, y).RGBA()
+			gray = append(gray, (0.299*float64(r)+0.587*float64(g)+0.114*float64(b))/257)
+		}
+	}
+	return gray, width, height
+}
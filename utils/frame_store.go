@@ -0,0 +1,491 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Backends of FRAME_STORE.
+const (
+	FRAME_STORE_LOCAL = "local"
+	FRAME_STORE_S3    = "s3"
+)
+
+// Which image of a frame FRAME_STORE_IMAGE keeps.
+const (
+	FRAME_IMAGE_ORIGINAL = "original"
+	FRAME_IMAGE_ANALYZED = "analyzed"
+)
+
+// ErrFrameNotFound is returned for a frame that was never stored or has
+// been removed.
+var ErrFrameNotFound = errors.New("frame not found")
+
+var frameKeyPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._:-]*$`)
+
+// FrameStore keeps analyzed frames keyed by tenant and environment context
+// ID. Frames are sealed with the tenant's artifact key when encryption at
+// rest is configured.
+type FrameStore interface {
+	// URI is where the frame of a context is stored, known before Put
+	URI(tenantID, contextID string) string
+	Put(ctx context.Context, tenantID, contextID string, image []byte) error
+	Get(ctx context.Context, tenantID, contextID string) ([]byte, error)
+	// Delete removes a frame; removing a missing frame is not an error
+	Delete(ctx context.Context, tenantID, contextID string) error
+	// List calls fn with every stored frame
+	List(ctx context.Context, fn func(tenantID, contextID string) error) error
+}
+
+// FrameStoreConfig is the FRAME_STORE configuration.
+type FrameStoreConfig struct {
+	Store FrameStore
+	// Image is FRAME_IMAGE_ORIGINAL or FRAME_IMAGE_ANALYZED
+	Image string
+}
+
+// FrameStoreFromConfig reads FRAME_STORE ("local" or "s3", unset disables) and
+// the settings of its backend; a nil config means frames are not stored.
+func FrameStoreFromConfig(cfg *Config) (*FrameStoreConfig, error) {
+	config := &FrameStoreConfig{Image: cfg.FrameStoreImage}
+	switch config.Image {
+	case "":
+		config.Image = FRAME_IMAGE_ANALYZED
+	case FRAME_IMAGE_ORIGINAL, FRAME_IMAGE_ANALYZED:
+	default:
+		return nil, fmt.Errorf("unknown FRAME_STORE_IMAGE %q", config.Image)
+	}
+
+	switch backend := cfg.FrameStore; backend {
+	case "":
+		return nil, nil
+	case FRAME_STORE_LOCAL:
+		dir := cfg.FrameStoreDir
+		if dir == "" {
+			return nil, fmt.Errorf("FRAME_STORE_DIR not configured")
+		}
+		config.Store = &LocalFrameStore{Dir: dir}
+	case FRAME_STORE_S3:
+		store := &S3FrameStore{
+			Bucket:       cfg.FrameStoreS3Bucket,
+			Region:       cfg.FrameStoreS3Region,
+			Endpoint:     cfg.FrameStoreS3Endpoint,
+			Prefix:       cfg.FrameStoreS3Prefix,
+			AccessKeyID:  cfg.AWSAccessKeyID,
+			SecretKey:    cfg.AWSSecretAccessKey,
+			SessionToken: cfg.AWSSessionToken,
+			http:         &http.Client{Timeout: cfg.FrameStoreTimeout},
+		}
+		if store.Bucket == "" || store.Region == "" {
+			return nil, fmt.Errorf("FRAME_STORE_S3_BUCKET and FRAME_STORE_S3_REGION must be set")
+		}
+		if store.AccessKeyID == "" || store.SecretKey == "" {
+			return nil, fmt.Errorf("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set")
+		}
+		config.Store = store
+	default:
+		return nil, fmt.Errorf("unknown FRAME_STORE %q", backend)
+	}
+	return config, nil
+}
+
+// frameKey is the object name of a frame, validated so context IDs taken
+// from a URL cannot escape the tenant's directory.
+func frameKey(tenantID, contextID string) (string, error) {
+	if !frameKeyPattern.MatchString(tenantID) || !frameKeyPattern.MatchString(contextID) || strings.Contains(contextID, "..") {
+		return "", fmt.Errorf("invalid frame key %q/%q", tenantID, contextID)
+	}
+	return tenantID + "/" + contextID, nil
+}
+
+// StoreFrame seals a frame (raw base64 or data URL) and puts it in store.
+func StoreFrame(ctx context.Context, store FrameStore, tenantID, contextID, imageData string) error {
+	raw, err := decodeDataURL(imageData)
+	if err != nil {
+		return fmt.Errorf("failed to decode frame: %w", err)
+	}
+	sealed, err := sealArtifact(ctx, tenantID, raw)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt frame: %w", err)
+	}
+	return store.Put(ctx, tenantID, contextID, sealed)
+}
+
+// LoadFrame returns a stored frame and its content type.
+func LoadFrame(ctx context.Context, store FrameStore, tenantID, contextID string) ([]byte, string, error) {
+	stored, err := store.Get(ctx, tenantID, contextID)
+	if err != nil {
+		return nil, "", err
+	}
+	raw, err := openArtifact(ctx, stored)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decrypt frame: %w", err)
+	}
+	return raw, http.DetectContentType(raw), nil
+}
+
+// RotateFrameKeys rewraps the stored frames whose data key is wrapped under
+// a key other than their tenant's current one, like RotateArtifactKeys does
+// for Redis, and returns the number of frames rewrapped. Frames are written
+// once, so a frame is not compared before it is replaced.
+func RotateFrameKeys(ctx context.Context, store FrameStore) (int, error) {
+	c, err := artifacts()
+	if err != nil {
+		return 0, err
+	}
+	rotated := 0
+	err = store.List(ctx, func(tenantID, contextID string) error {
+		stored, err := store.Get(ctx, tenantID, contextID)
+		if errors.Is(err, ErrFrameNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		rewrapped, changed, err := c.rewrap(ctx, stored)
+		if err != nil {
+			return fmt.Errorf("failed to rewrap frame %s/%s: %w", tenantID, contextID, err)
+		}
+		if !changed {
+			return nil
+		}
+		if err := store.Put(ctx, tenantID, contextID, rewrapped); err != nil {
+			return err
+		}
+		rotated++
+		return nil
+	})
+	return rotated, err
+}
+
+// LocalFrameStore keeps frames as files under Dir/<tenant>/<context id>.
+type LocalFrameStore struct {
+	Dir string
+}
+
+func (s *LocalFrameStore) URI(tenantID, contextID string) string {
+	path, err := s.path(tenantID, contextID)
+	if err != nil {
+		return ""
+	}
+	return (&url.URL{Scheme: "file", Path: path}).String()
+}
+
+func (s *LocalFrameStore) path(tenantID, contextID string) (string, error) {
+	key, err := frameKey(tenantID, contextID)
+	if err != nil {
+		return "", err
+	}
+	dir, err := filepath.Abs(s.Dir)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, filepath.FromSlash(key)), nil
+}
+
+func (s *LocalFrameStore) Put(ctx context.Context, tenantID, contextID string, image []byte) error {
+	path, err := s.path(tenantID, contextID)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return fmt.Errorf("failed to create frame directory: %w", err)
+	}
+	// Write and rename so a reader never sees half a frame
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, image, 0o640); err != nil {
+		return fmt.Errorf("failed to write frame: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write frame: %w", err)
+	}
+	return nil
+}
+
+func (s *LocalFrameStore) Get(ctx context.Context, tenantID, contextID string) ([]byte, error) {
+	path, err := s.path(tenantID, contextID)
+	if err != nil {
+		return nil, ErrFrameNotFound
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrFrameNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read frame: %w", err)
+	}
+	return data, nil
+}
+
+func (s *LocalFrameStore) Delete(ctx context.Context, tenantID, contextID string) error {
+	path, err := s.path(tenantID, contextID)
+	if err != nil {
+		return nil
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete frame: %w", err)
+	}
+	return nil
+}
+
+func (s *LocalFrameStore) List(ctx context.Context, fn func(tenantID, contextID string) error) error {
+	dir, err := filepath.Abs(s.Dir)
+	if err != nil {
+		return err
+	}
+	err = filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || strings.HasSuffix(path, ".tmp") {
+			return ctx.Err()
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		tenantID, contextID, ok := strings.Cut(filepath.ToSlash(rel), "/")
+		if !ok {
+			return nil
+		}
+		if _, err := frameKey(tenantID, contextID); err != nil {
+			return nil
+		}
+		return fn(tenantID, contextID)
+	})
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to list frames: %w", err)
+	}
+	return nil
+}
+
+// S3FrameStore keeps frames as objects <prefix><tenant>/<context id> in an
+// S3 bucket. Endpoint selects an S3-compatible service such as MinIO, which
+// is addressed path-style. Requests are signed with AWS Signature V4.
+// Retention is left to the bucket's lifecycle rules.
+type S3FrameStore struct {
+	Bucket       string
+	Region       string
+	Endpoint     string
+	Prefix       string
+	AccessKeyID  string
+	SecretKey    string
+	SessionToken string
+
+	http *http.Client
+}
+
+func (s *S3FrameStore) URI(tenantID, contextID string) string {
+	key, err := frameKey(tenantID, contextID)
+	if err != nil {
+		return ""
+	}
+	return "s3://" + s.Bucket + "/" + s.Prefix + key
+}
+
+func (s *S3FrameStore) objectURL(tenantID, contextID string) (*url.URL, error) {
+	key, err := frameKey(tenantID, contextID)
+	if err != nil {
+		return nil, err
+	}
+	return url.Parse(s.bucketURL() + s.Prefix + key)
+}
+
+// bucketURL ends with a slash.
+func (s *S3FrameStore) bucketURL() string {
+	if s.Endpoint == "" {
+		return fmt.Sprintf("https://%s.s3.%s.amazonaws.com/", s.Bucket, s.Region)
+	}
+	return strings.TrimSuffix(s.Endpoint, "/") + "/" + s.Bucket + "/"
+}
+
+func (s *S3FrameStore) Put(ctx context.Context, tenantID, contextID string, image []byte) error {
+	_, err := s.do(ctx, http.MethodPut, tenantID, contextID, image)
+	if err != nil {
+		return fmt.Errorf("failed to upload frame: %w", err)
+	}
+	return nil
+}
+
+func (s *S3FrameStore) Get(ctx context.Context, tenantID, contextID string) ([]byte, error) {
+	data, err := s.do(ctx, http.MethodGet, tenantID, contextID, nil)
+	if err != nil && !errors.Is(err, ErrFrameNotFound) {
+		return nil, fmt.Errorf("failed to download frame: %w", err)
+	}
+	return data, err
+}
+
+func (s *S3FrameStore) Delete(ctx context.Context, tenantID, contextID string) error {
+	_, err := s.do(ctx, http.MethodDelete, tenantID, contextID, nil)
+	if err != nil && !errors.Is(err, ErrFrameNotFound) {
+		return fmt.Errorf("failed to delete frame: %w", err)
+	}
+	return nil
+}
+
+// List pages through the frames under the prefix with ListObjectsV2.
+func (s *S3FrameStore) List(ctx context.Context, fn func(tenantID, contextID string) error) error {
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {s.Prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.bucketURL(), nil)
+		if err != nil {
+			return err
+		}
+		// Signature V4 wants spaces encoded as %20
+		req.URL.RawQuery = strings.ReplaceAll(query.Encode(), "+", "%20")
+		s.sign(req, nil, time.Now().UTC())
+
+		resp, err := s.http.Do(req)
+		if err != nil {
+			return fmt.Errorf("failed to list frames: %w", err)
+		}
+		data, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("failed to list frames: %w", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("failed to list frames: S3 returned status %d: %s", resp.StatusCode, data)
+		}
+		var page struct {
+			Contents []struct {
+				Key string
+			}
+			IsTruncated           bool
+			NextContinuationToken string
+		}
+		if err := xml.Unmarshal(data, &page); err != nil {
+			return fmt.Errorf("failed to decode frame list: %w", err)
+		}
+
+		for _, object := range page.Contents {
+			tenantID, contextID, ok := strings.Cut(strings.TrimPrefix(object.Key, s.Prefix), "/")
+			if !ok {
+				continue
+			}
+			if _, err := frameKey(tenantID, contextID); err != nil {
+				continue
+			}
+			if err := fn(tenantID, contextID); err != nil {
+				return err
+			}
+		}
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return nil
+		}
+		token = page.NextContinuationToken
+	}
+}
+
+func (s *S3FrameStore) do(ctx context.Context, method, tenantID, contextID string, body []byte) ([]byte, error) {
+	objectURL, err := s.objectURL(tenantID, contextID)
+	if err != nil {
+		return nil, ErrFrameNotFound
+	}
+	req, err := http.NewRequestWithContext(ctx, method, objectURL.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/octet-stream")
+	}
+	s.sign(req, body, time.Now().UTC())
+
+	resp, err := s.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, ErrFrameNotFound
+	case resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent:
+		return nil, fmt.Errorf("S3 returned status %d: %s", resp.StatusCode, data)
+	}
+	return data, nil
+}
+
+// sign adds the AWS Signature V4 headers for an S3 request.
+func (s *S3FrameStore) sign(req *http.Request, body []byte, now time.Time) {
+	payloadHash := sha256Hex(body)
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if s.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + s.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretKey), date)
+	for _, part := range []string{s.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,221 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"strconv"
+	"strings"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
+)
+
+// maxFrameDimension bounds the sides of frames decoded from clients. It is
+// checked on the image header, before any pixels are allocated.
+const maxFrameDimension = 8192
+
+// decodeFrame decodes a JPEG or PNG frame whose sides are within
+// maxFrameDimension.
+func decodeFrame(raw []byte) (image.Image, string, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(raw))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decode image: %w", err)
+	}
+	if err := checkFrameSize(config.Width, config.Height); err != nil {
+		return nil, "", err
+	}
+	img, format, err := image.Decode(bytes.NewReader(raw))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decode image: %w", err)
+	}
+	return img, format, nil
+}
+
+func checkFrameSize(width, height int) error {
+	if width < 1 || height < 1 || width > maxFrameDimension || height > maxFrameDimension {
+		return fmt.Errorf("frame must be between 1x1 and %dx%d, got %dx%d", maxFrameDimension, maxFrameDimension, width, height)
+	}
+	return nil
+}
+
+// CropRect is a region of a frame in normalized coordinates (0-1, origin at
+// the top-left corner).
+type CropRect struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+// FullFrame covers the whole frame.
+var FullFrame = CropRect{Width: 1, Height: 1}
+
+// ParseCropRect parses "center:0.8" (a centered crop keeping 80% of each
+// side) or "x,y,width,height" in normalized coordinates.
+func ParseCropRect(value string) (CropRect, error) {
+	value = strings.TrimSpace(value)
+	if fraction, ok := strings.CutPrefix(value, "center:"); ok {
+		f, err := strconv.ParseFloat(fraction, 64)
+		if err != nil || f <= 0 || f > 1 {
+			return CropRect{}, fmt.Errorf("invalid center crop fraction %q", fraction)
+		}
+		return CropRect{X: (1 - f) / 2, Y: (1 - f) / 2, Width: f, Height: f}, nil
+	}
+
+	parts := strings.Split(value, ",")
+	if len(parts) != 4 {
+		return CropRect{}, fmt.Errorf("crop must be center:<fraction> or x,y,width,height")
+	}
+	var numbers [4]float64
+	for i, part := range parts {
+		n, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return CropRect{}, fmt.Errorf("invalid crop value %q: %w", part, err)
+		}
+		numbers[i] = n
+	}
+	return CropRect{X: numbers[0], Y: numbers[1], Width: numbers[2], Height: numbers[3]}.Validate()
+}
+
+// Validate clamps the rectangle to the frame and rejects empty ones.
+func (c CropRect) Validate() (CropRect, error) {
+	x0, y0 := clamp01(c.X), clamp01(c.Y)
+	x1, y1 := clamp01(c.X+c.Width), clamp01(c.Y+c.Height)
+	if x1 <= x0 || y1 <= y0 {
+		return CropRect{}, fmt.Errorf("crop region is empty")
+	}
+	return CropRect{X: x0, Y: y0, Width: x1 - x0, Height: y1 - y0}, nil
+}
+
+func (c CropRect) isFull() bool {
+	return c.X <= 0 && c.Y <= 0 && c.Width >= 1 && c.Height >= 1
+}
+
+// UncropRegions maps boxes estimated on a cropped frame back to the
+// coordinates of the full frame.
+func UncropRegions(regions []models.Region, crop CropRect) []models.Region {
+	if crop.isFull() {
+		return regions
+	}
+	for i := range regions {
+		regions[i].X = crop.X + regions[i].X*crop.Width
+		regions[i].Y = crop.Y + regions[i].Y*crop.Height
+		regions[i].Width *= crop.Width
+		regions[i].Height *= crop.Height
+	}
+	return regions
+}
+
+// ImagePreprocessor prepares frames for the vision model: it crops to a
+// region of interest, downscales so the long side is at most MaxDimension
+// pixels and re-encodes as JPEG at JPEGQuality. Vision models bill by image
+// tiles, so a 4K frame costs several times a 1024px one without adding
+// useful detail for scene description.
+type ImagePreprocessor struct {
+	MaxDimension int
+	JPEGQuality  int
+	Crop         CropRect
+}
+
+// ImagePreprocessorFromConfig reads VISION_MAX_DIMENSION (0 keeps the
+// original size), VISION_JPEG_QUALITY and VISION_CROP.
+func ImagePreprocessorFromConfig(cfg *Config) (ImagePreprocessor, error) {
+	p := ImagePreprocessor{
+		MaxDimension: cfg.VisionMaxDimension,
+		JPEGQuality:  cfg.VisionJPEGQuality,
+		Crop:         FullFrame,
+	}
+	if p.JPEGQuality < 1 || p.JPEGQuality > 100 {
+		return p, fmt.Errorf("VISION_JPEG_QUALITY must be between 1 and 100")
+	}
+	if value := cfg.VisionCrop; value != "" {
+		crop, err := ParseCropRect(value)
+		if err != nil {
+			return p, fmt.Errorf("invalid VISION_CROP: %w", err)
+		}
+		p.Crop = crop
+	}
+	return p, nil
+}
+
+// Process crops and scales a JPEG or PNG frame (raw base64 or data URL) and
+// returns it as a JPEG data URL together with the crop that was applied. A
+// non-nil roi replaces the configured crop. Frames that need neither are
+// returned unchanged so JPEGs are not recompressed.
+func (p ImagePreprocessor) Process(imageData string, roi *CropRect) (string, CropRect, error) {
+	crop := p.Crop
+	if roi != nil {
+		crop = *roi
+	}
+
+	raw, err := decodeDataURL(imageData)
+	if err != nil {
+		return "", crop, fmt.Errorf("failed to decode frame: %w", err)
+	}
+	img, format, err := decodeFrame(raw)
+	if err != nil {
+		return "", crop, err
+	}
+
+	bounds := img.Bounds()
+	region := image.Rect(
+		bounds.Min.X+int(crop.X*float64(bounds.Dx())),
+		bounds.Min.Y+int(crop.Y*float64(bounds.Dy())),
+		bounds.Min.X+int((crop.X+crop.Width)*float64(bounds.Dx())),
+		bounds.Min.Y+int((crop.Y+crop.Height)*float64(bounds.Dy())),
+	)
+	if region.Empty() {
+		return "", crop, fmt.Errorf("crop region is empty for a %dx%d frame", bounds.Dx(), bounds.Dy())
+	}
+
+	width, height := region.Dx(), region.Dy()
+	if p.MaxDimension > 0 && max(width, height) > p.MaxDimension {
+		scale := float64(p.MaxDimension) / float64(max(width, height))
+		width = max(int(float64(width)*scale+0.5), 1)
+		height = max(int(float64(height)*scale+0.5), 1)
+	}
+	if region == bounds && width == region.Dx() && format == "jpeg" {
+		return imageData, FullFrame, nil
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, resizeBox(img, region, width, height), &jpeg.Options{Quality: p.JPEGQuality}); err != nil {
+		return "", crop, fmt.Errorf("failed to encode frame: %w", err)
+	}
+	return "data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()), crop, nil
+}
+
+// resizeBox scales region of img to width x height by averaging the source
+// pixels under each destination pixel, which avoids the aliasing of nearest
+// neighbour sampling when shrinking large frames.
+func resizeBox(img image.Image, region image.Rectangle, width, height int) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	scaleX := float64(region.Dx()) / float64(width)
+	scaleY := float64(region.Dy()) / float64(height)
+
+	for y := 0; y < height; y++ {
+		sy0 := region.Min.Y + int(float64(y)*scaleY)
+		sy1 := max(region.Min.Y+int(float64(y+1)*scaleY), sy0+1)
+		for x := 0; x < width; x++ {
+			sx0 := region.Min.X + int(float64(x)*scaleX)
+			sx1 := max(region.Min.X+int(float64(x+1)*scaleX), sx0+1)
+
+			var r, g, b, a, n uint64
+			for sy := sy0; sy < sy1; sy++ {
+				for sx := sx0; sx < sx1; sx++ {
+					pr, pg, pb, pa := img.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(pr), g+uint64(pg), b+uint64(pb), a+uint64(pa)
+					n++
+				}
+			}
+			i := dst.PixOffset(x, y)
+			dst.Pix[i+0] = uint8(r / n >> 8)
+			dst.Pix[i+1] = uint8(g / n >> 8)
+			dst.Pix[i+2] = uint8(b / n >> 8)
+			dst.Pix[i+3] = uint8(a / n >> 8)
+		}
+	}
+	return dst
+}
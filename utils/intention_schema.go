@@ -0,0 +1,220 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
+	"go.uber.org/zap"
+)
+
+// DefaultIntentionTypes is the intention registry used unless
+// INTENTION_TYPES_FILE points to a JSON array of models.IntentionType.
+var DefaultIntentionTypes = []models.IntentionType{
+	{
+		Type:        "navigation",
+		Description: "Move to a place",
+		Slots: []models.SlotDefinition{
+			{Name: "target_location", Type: models.SLOT_TYPE_STRING, Description: "Where to go"},
+			{Name: "deadline", Type: models.SLOT_TYPE_DATETIME, Description: "When to arrive by"},
+		},
+	},
+	{
+		Type:        "fetch",
+		Description: "Bring an object to someone",
+		Slots: []models.SlotDefinition{
+			{Name: "target_object", Type: models.SLOT_TYPE_STRING, Description: "What to bring"},
+			{Name: "target_location", Type: models.SLOT_TYPE_STRING, Description: "Where to find it"},
+			{Name: "quantity", Type: models.SLOT_TYPE_INTEGER},
+			{Name: "deadline", Type: models.SLOT_TYPE_DATETIME},
+		},
+	},
+	{
+		Type:        "manipulation",
+		Description: "Pick up, place or operate an object",
+		Slots: []models.SlotDefinition{
+			{Name: "target_object", Type: models.SLOT_TYPE_STRING},
+			{Name: "target_location", Type: models.SLOT_TYPE_STRING, Description: "Where the object is or goes"},
+			{Name: "quantity", Type: models.SLOT_TYPE_INTEGER},
+		},
+	},
+	{
+		Type:        "charging",
+		Description: "Go to the charging dock",
+		Slots: []models.SlotDefinition{
+			{Name: "deadline", Type: models.SLOT_TYPE_DATETIME, Description: "When to be charged by"},
+		},
+	},
+	{
+		Type:        "information_gathering",
+		Description: "Look for or check on something",
+		Slots: []models.SlotDefinition{
+			{Name: "target_object", Type: models.SLOT_TYPE_STRING},
+			{Name: "target_location", Type: models.SLOT_TYPE_STRING},
+		},
+	},
+}
+
+var (
+	intentionTypesOnce sync.Once
+	intentionTypes     []models.IntentionType
+)
+
+// IntentionTypes returns the intention registry.
+func IntentionTypes() []models.IntentionType {
+	intentionTypesOnce.Do(func() {
+		intentionTypes = DefaultIntentionTypes
+		path := CurrentConfig().IntentionTypesFile
+		if path == "" {
+			return
+		}
+		types, err := LoadIntentionTypes(path)
+		if err != nil {
+			zap.L().Error("Failed to load intention types, using defaults", zap.String("path", path), zap.Error(err))
+			return
+		}
+		intentionTypes = types
+	})
+	return intentionTypes
+}
+
+func LoadIntentionTypes(path string) ([]models.IntentionType, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read intention types file: %w", err)
+	}
+	var types []models.IntentionType
+	if err := json.Unmarshal(data, &types); err != nil {
+		return nil, fmt.Errorf("failed to parse intention types file: %w", err)
+	}
+	for _, intentionType := range types {
+		if intentionType.Type == "" {
+			return nil, fmt.Errorf("intention type without a name")
+		}
+		for _, slot := range intentionType.Slots {
+			if _, ok := slotJSONTypes[slot.Type]; !ok {
+				return nil, fmt.Errorf("intention type %s: slot %s has unknown type %q", intentionType.Type, slot.Name, slot.Type)
+			}
+		}
+	}
+	return types, nil
+}
+
+var slotJSONTypes = map[string]string{
+	models.SLOT_TYPE_STRING:   "string",
+	models.SLOT_TYPE_NUMBER:   "number",
+	models.SLOT_TYPE_INTEGER:  "integer",
+	models.SLOT_TYPE_DATETIME: "string",
+}
+
+// intentionTypesPrompt lists the intention types and their slots.
+func intentionTypesPrompt(types []models.IntentionType) string {
+	var b strings.Builder
+	for _, intentionType := range types {
+		var slots []string
+		for _, slot := range intentionType.Slots {
+			slots = append(slots, fmt.Sprintf("%s (%s)", slot.Name, slot.Type))
+		}
+		fmt.Fprintf(&b, "- %s: %s. Slots: %s\n", intentionType.Type, intentionType.Description, strings.Join(slots, ", "))
+	}
+	return b.String()
+}
+
+// intentionResponseFormat returns a strict JSON schema for the intention
+// answer. Slots are the union over all intention types; unused ones are null.
+func intentionResponseFormat(types []models.IntentionType) map[string]interface{} {
+	typeNames := []string{"none"}
+	slotTypes := make(map[string]string)
+	for _, intentionType := range types {
+		typeNames = append(typeNames, intentionType.Type)
+		for _, slot := range intentionType.Slots {
+			slotTypes[slot.Name] = slotJSONTypes[slot.Type]
+		}
+	}
+
+	slotNames := make([]string, 0, len(slotTypes))
+	for name := range slotTypes {
+		slotNames = append(slotNames, name)
+	}
+	sort.Strings(slotNames)
+
+	slotProperties := make(map[string]interface{}, len(slotNames))
+	for _, name := range slotNames {
+		slotProperties[name] = map[string]interface{}{"type": []string{slotTypes[name], "null"}}
+	}
+
+	return map[string]interface{}{
+		"type": "json_schema",
+		"json_schema": map[string]interface{}{
+			"name":   "intention",
+			"strict": true,
+			"schema": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"has_clear_intention": map[string]interface{}{"type": "boolean"},
+					"intention_type":      map[string]interface{}{"type": "string", "enum": typeNames},
+					"description":         map[string]interface{}{"type": "string"},
+					"confidence":          map[string]interface{}{"type": "number"},
+					"reasoning":           map[string]interface{}{"type": "string"},
+					"slots": map[string]interface{}{
+						"type":                 "object",
+						"properties":           slotProperties,
+						"required":             slotNames,
+						"additionalProperties": false,
+					},
+				},
+				"required":             []string{"has_clear_intention", "intention_type", "description", "confidence", "reasoning", "slots"},
+				"additionalProperties": false,
+			},
+		},
+	}
+}
+
+// NormalizeSlots keeps the slots defined for intentionType whose values are
+// set and of the declared type.
+func NormalizeSlots(intentionType string, slots map[string]interface{}) map[string]interface{} {
+	var definition *models.IntentionType
+	for _, candidate := range IntentionTypes() {
+		if candidate.Type == intentionType {
+			definition = &candidate
+			break
+		}
+	}
+	if definition == nil {
+		return nil
+	}
+
+	normalized := make(map[string]interface{})
+	for _, slot := range definition.Slots {
+		value, ok := slots[slot.Name]
+		if !ok || value == nil {
+			continue
+		}
+		switch slot.Type {
+		case models.SLOT_TYPE_STRING:
+			if s, ok := value.(string); ok && s != "" {
+				normalized[slot.Name] = s
+			}
+		case models.SLOT_TYPE_NUMBER:
+			if f, ok := value.(float64); ok {
+				normalized[slot.Name] = f
+			}
+		case models.SLOT_TYPE_INTEGER:
+			if f, ok := value.(float64); ok && f == float64(int64(f)) {
+				normalized[slot.Name] = int64(f)
+			}
+		case models.SLOT_TYPE_DATETIME:
+			if s, ok := value.(string); ok {
+				if t, err := time.Parse(time.RFC3339, s); err == nil {
+					normalized[slot.Name] = t.Format(time.RFC3339)
+				}
+			}
+		}
+	}
+	return normalized
+}
@@ -0,0 +1,190 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
+)
+
+// ToolCall is a function call requested by the chat completion API.
+type ToolCall struct {
+	ID       string           `json:"id"`
+	Type     string           `json:"type"`
+	Function ToolCallFunction `json:"function"`
+}
+
+type ToolCallFunction struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// ToolHandler serves one tool call. The returned value is sent back to the
+// model as JSON.
+type ToolHandler func(ctx context.Context, arguments json.RawMessage) (interface{}, error)
+
+// IntentionTool is a tool the intention model may call before finalizing its
+// answer. Parameters is a JSON Schema object describing the arguments.
+type IntentionTool struct {
+	Name        string
+	Description string
+	Parameters  map[string]interface{}
+	Handler     ToolHandler
+}
+
+// ToolRegistry holds the tools offered to the intention model.
+type ToolRegistry struct {
+	mu    sync.RWMutex
+	tools map[string]IntentionTool
+}
+
+func NewToolRegistry() *ToolRegistry {
+	return &ToolRegistry{tools: make(map[string]IntentionTool)}
+}
+
+// Register adds or replaces a tool.
+func (r *ToolRegistry) Register(tool IntentionTool) {
+	if tool.Parameters == nil {
+		tool.Parameters = map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tools[tool.Name] = tool
+}
+
+func (r *ToolRegistry) Len() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.tools)
+}
+
+// definitions returns the tools in chat completion "tools" format.
+func (r *ToolRegistry) definitions() []map[string]interface{} {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.tools))
+	for name := range r.tools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	definitions := make([]map[string]interface{}, 0, len(names))
+	for _, name := range names {
+		tool := r.tools[name]
+		definitions = append(definitions, map[string]interface{}{
+			"type": "function",
+			"function": map[string]interface{}{
+				"name":        tool.Name,
+				"description": tool.Description,
+				"parameters":  tool.Parameters,
+			},
+		})
+	}
+	return definitions
+}
+
+// callAll serves the tool calls of one model turn concurrently.
+func (r *ToolRegistry) callAll(ctx context.Context, calls []ToolCall) []models.IntentionToolCall {
+	records := make([]models.IntentionToolCall, len(calls))
+
+	var wg sync.WaitGroup
+	for i, call := range calls {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			records[i] = r.call(ctx, call)
+		}()
+	}
+	wg.Wait()
+
+	return records
+}
+
+func (r *ToolRegistry) call(ctx context.Context, call ToolCall) models.IntentionToolCall {
+	record := models.IntentionToolCall{Name: call.Function.Name}
+
+	arguments := json.RawMessage(call.Function.Arguments)
+	if len(arguments) == 0 {
+		arguments = json.RawMessage("{}")
+	}
+	if !json.Valid(arguments) {
+		record.Error = "arguments are not valid JSON"
+		return record
+	}
+	record.Arguments = arguments
+
+	r.mu.RLock()
+	tool, ok := r.tools[call.Function.Name]
+	r.mu.RUnlock()
+	if !ok {
+		record.Error = fmt.Sprintf("unknown tool %q", call.Function.Name)
+		return record
+	}
+
+	result, err := tool.Handler(ctx, arguments)
+	if err != nil {
+		record.Error = err.Error()
+		return record
+	}
+	record.Result = result
+	return record
+}
+
+func toolOutput(record models.IntentionToolCall) string {
+	var output interface{} = record.Result
+	if record.Error != "" {
+		output = map[string]string{"error": record.Error}
+	}
+	data, err := json.Marshal(output)
+	if err != nil {
+		return fmt.Sprintf(`{"error":%q}`, err.Error())
+	}
+	return string(data)
+}
+
+const intentionToolsPrompt = `You can call tools to look up the robot's state, the named locations on its map and the current time. Use them to resolve relative references such as "where you were", "the charger" or "before dinner" into concrete slot values before giving your final JSON answer. Do not call tools for transcripts without an actionable request.`
+
+// AnalyzeTranscriptWithTools runs intention analysis while letting the model
+// call the registered tools. Tool calls of a turn are served in parallel; after
+// maxRounds turns the model must answer without further calls.
+func (c *OpenAIClient) AnalyzeTranscriptWithTools(ctx context.Context, transcript string, environmentContext, preferences, conversation []string, tools *ToolRegistry, maxRounds int) (*models.IntentionResult, error) {
+	requestBody := IntentionRequestBody(transcript, environmentContext, preferences, conversation)
+	messages := []GPTMessage{{Role: "system", Content: intentionToolsPrompt}}
+	messages = append(messages, requestBody["messages"].([]GPTMessage)...)
+	requestBody["tools"] = tools.definitions()
+	requestBody["parallel_tool_calls"] = true
+
+	var toolCalls []models.IntentionToolCall
+	for round := 0; ; round++ {
+		requestBody["messages"] = messages
+		if round >= maxRounds {
+			requestBody["tool_choice"] = "none"
+		}
+
+		message, err := c.completeTask(ctx, MODEL_TASK_INTENTION, requestBody)
+		if err != nil {
+			return nil, err
+		}
+		if len(message.ToolCalls) == 0 || round >= maxRounds {
+			result := ParseIntentionContent(message.Content)
+			result.ToolCalls = toolCalls
+			return result, nil
+		}
+
+		var content interface{}
+		if message.Content != "" {
+			content = message.Content
+		}
+		messages = append(messages, GPTMessage{Role: "assistant", Content: content, ToolCalls: message.ToolCalls})
+
+		records := tools.callAll(ctx, message.ToolCalls)
+		for i, call := range message.ToolCalls {
+			messages = append(messages, GPTMessage{Role: "tool", ToolCallID: call.ID, Content: toolOutput(records[i])})
+		}
+		toolCalls = append(toolCalls, records...)
+	}
+}
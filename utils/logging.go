@@ -0,0 +1,230 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	LOG_FORMAT_CONSOLE = "console"
+	LOG_FORMAT_JSON    = "json"
+)
+
+// LogLevel is the instance-wide log level, changed at runtime through
+// PUT /admin/log-level. Session loggers follow it unless overridden.
+var LogLevel = zap.NewAtomicLevelAt(zapcore.DebugLevel)
+
+// Fields whose values are user content, redacted above debug level
+var sensitiveLogFields = map[string]bool{
+	"transcript":          true,
+	"text":                true,
+	"description":         true,
+	"content":             true,
+	"body":                true,
+	"utterance":           true,
+	"environment_context": true,
+}
+
+// Runs of base64 this long are image, audio or depth payloads, never useful
+// in a log line
+var base64Run = regexp.MustCompile(`(data:[a-z]+/[a-z0-9.+-]+;base64,)?[A-Za-z0-9+/]{256,}={0,2}`)
+
+// NewLogger builds the root logger from LOG_FORMAT ("console", colored for
+// development, or "json" for log pipelines), LOG_LEVEL (debug for console,
+// info for json by default) and LOG_REDACT (default true), which replaces
+// transcripts and base64 payloads in entries above debug level.
+func NewLogger(cfg *Config) (*zap.Logger, error) {
+	format := strings.ToLower(cfg.LogFormat)
+	var config zap.Config
+	switch format {
+	case "", LOG_FORMAT_CONSOLE:
+		config = zap.NewDevelopmentConfig()
+		config.EncoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
+		config.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+		config.EncoderConfig.EncodeCaller = zapcore.ShortCallerEncoder
+	case LOG_FORMAT_JSON:
+		config = zap.NewProductionConfig()
+		config.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+		// Sampling would drop repeated per-frame lines of different sessions
+		config.Sampling = nil
+		LogLevel.SetLevel(zapcore.InfoLevel)
+	default:
+		return nil, fmt.Errorf("unknown LOG_FORMAT %q", format)
+	}
+	if value := cfg.LogLevel; value != "" {
+		level, err := zapcore.ParseLevel(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid LOG_LEVEL: %w", err)
+		}
+		LogLevel.SetLevel(level)
+	}
+
+	// The base core takes every level; levelCore applies LogLevel or a
+	// session's override on top
+	config.Level = zap.NewAtomicLevelAt(zapcore.DebugLevel)
+	redact := cfg.LogRedact
+	return config.Build(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		if redact {
+			core = &redactCore{Core: core}
+		}
+		return &levelCore{Core: core, enabler: LogLevel}
+	}))
+}
+
+// LogLevelOverride is a per-session log level; unset, it follows LogLevel.
+type LogLevelOverride struct {
+	level atomic.Pointer[zapcore.Level]
+}
+
+func (o *LogLevelOverride) Enabled(level zapcore.Level) bool {
+	if override := o.level.Load(); override != nil {
+		return level >= *override
+	}
+	return LogLevel.Enabled(level)
+}
+
+// Set overrides the level; nil restores the instance-wide level.
+func (o *LogLevelOverride) Set(level *zapcore.Level) {
+	o.level.Store(level)
+}
+
+// Level returns the effective level and whether it is overridden.
+func (o *LogLevelOverride) Level() (zapcore.Level, bool) {
+	if override := o.level.Load(); override != nil {
+		return *override, true
+	}
+	return LogLevel.Level(), false
+}
+
+// WithLevelOverride returns a logger whose level is decided by override. It
+// only lowers the level below LogLevel for loggers built by NewLogger.
+func WithLevelOverride(logger *zap.Logger, override *LogLevelOverride) *zap.Logger {
+	return logger.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		if leveled, ok := core.(*levelCore); ok {
+			core = leveled.Core
+		}
+		return &levelCore{Core: core, enabler: override}
+	}))
+}
+
+// levelCore filters entries by a level that can change at runtime.
+type levelCore struct {
+	zapcore.Core
+	enabler zapcore.LevelEnabler
+}
+
+func (c *levelCore) Enabled(level zapcore.Level) bool {
+	return c.enabler.Enabled(level)
+}
+
+func (c *levelCore) With(fields []zapcore.Field) zapcore.Core {
+	return &levelCore{Core: c.Core.With(fields), enabler: c.enabler}
+}
+
+func (c *levelCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.enabler.Enabled(entry.Level) {
+		return checked
+	}
+	return c.Core.Check(entry, checked)
+}
+
+// redactCore scrubs user content and base64 payloads from entries above
+// debug level. Fields added with With are not scrubbed; they carry IDs.
+type redactCore struct {
+	zapcore.Core
+}
+
+func (c *redactCore) With(fields []zapcore.Field) zapcore.Core {
+	return &redactCore{Core: c.Core.With(fields)}
+}
+
+func (c *redactCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+func (c *redactCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	if entry.Level <= zapcore.DebugLevel {
+		return c.Core.Write(entry, fields)
+	}
+	entry.Message = redactBase64(entry.Message)
+	redacted := make([]zapcore.Field, len(fields))
+	for i, field := range fields {
+		redacted[i] = redactField(field)
+	}
+	return c.Core.Write(entry, redacted)
+}
+
+func redactField(field zapcore.Field) zapcore.Field {
+	switch field.Type {
+	case zapcore.StringType:
+		if sensitiveLogFields[field.Key] {
+			return zap.String(field.Key, redactedText(len(field.String)))
+		}
+		return zap.String(field.Key, redactBase64(field.String))
+	case zapcore.ErrorType:
+		if err, ok := field.Interface.(error); ok {
+			if message := err.Error(); base64Run.MatchString(message) {
+				return zap.String(field.Key, redactBase64(message))
+			}
+		}
+	case zapcore.ReflectType:
+		if sensitiveLogFields[field.Key] {
+			return zap.String(field.Key, "[redacted]")
+		}
+		// Round-trip through JSON to scrub nested payloads (e.g. message data)
+		data, err := json.Marshal(field.Interface)
+		if err != nil {
+			return field
+		}
+		var value interface{}
+		if err := json.Unmarshal(data, &value); err != nil {
+			return field
+		}
+		return zap.Any(field.Key, redactValue(value))
+	}
+	return field
+}
+
+func redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			if text, ok := item.(string); ok && sensitiveLogFields[strings.ToLower(key)] {
+				v[key] = redactedText(len(text))
+			} else {
+				v[key] = redactValue(item)
+			}
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = redactValue(item)
+		}
+		return v
+	case string:
+		return redactBase64(v)
+	}
+	return value
+}
+
+func redactBase64(text string) string {
+	if len(text) < 256 {
+		return text
+	}
+	return base64Run.ReplaceAllStringFunc(text, func(payload string) string {
+		return fmt.Sprintf("[base64 %d chars]", len(payload))
+	})
+}
+
+func redactedText(length int) string {
+	return fmt.Sprintf("[redacted %d chars]", length)
+}
@@ -0,0 +1,205 @@
+package utils
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Label dimensions attached to session metrics. Which ones are populated is
+// controlled by METRICS_LABELS; the others are reported empty.
+const (
+	METRIC_LABEL_TENANT      = "tenant"
+	METRIC_LABEL_ROBOT_MODEL = "robot_model"
+	METRIC_LABEL_PROFILE     = "profile"
+	METRIC_LABEL_SITE        = "site"
+)
+
+var sessionLabelNames = []string{METRIC_LABEL_TENANT, METRIC_LABEL_ROBOT_MODEL, METRIC_LABEL_PROFILE, METRIC_LABEL_SITE}
+
+// defaultMetricLabels leaves out site, which robots name freely
+var defaultMetricLabels = []string{METRIC_LABEL_TENANT, METRIC_LABEL_ROBOT_MODEL, METRIC_LABEL_PROFILE}
+
+var (
+	sessionsActive = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "perceptus_sessions_active",
+		Help: "Robot sessions currently connected.",
+	}, sessionLabelNames)
+
+	sessionsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "perceptus_sessions_total",
+		Help: "Robot sessions started.",
+	}, sessionLabelNames)
+
+	messagesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "perceptus_ws_messages_total",
+		Help: "Inbound WebSocket messages by type.",
+	}, append([]string{"type"}, sessionLabelNames...))
+
+	analysisDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "perceptus_analysis_duration_seconds",
+		Help:    "Latency of intention and vision analysis.",
+		Buckets: []float64{0.25, 0.5, 1, 2, 4, 8, 16, 30},
+	}, append([]string{"kind"}, sessionLabelNames...))
+
+	providerErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "perceptus_provider_errors_total",
+		Help: "Failed calls to external providers.",
+	}, append([]string{"provider"}, sessionLabelNames...))
+
+	outboundDropped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "perceptus_ws_outbound_dropped_total",
+		Help: "Outbound WebSocket messages dropped by the send queue, by type.",
+	}, append([]string{"type"}, sessionLabelNames...))
+
+	workerPanics = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "perceptus_session_worker_panics_total",
+		Help: "Panics recovered in session goroutines, by worker.",
+	}, append([]string{"worker"}, sessionLabelNames...))
+
+	openAIRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "perceptus_openai_retries_total",
+		Help: "OpenAI requests retried, by task and reason.",
+	}, []string{"task", "reason"})
+
+	openAIRetriesExhausted = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "perceptus_openai_retries_exhausted_total",
+		Help: "OpenAI requests given up on after a retryable failure, by task and reason.",
+	}, []string{"task", "reason"})
+
+	providerPoolConnections = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "perceptus_provider_pool_connections",
+		Help: "Provider connections shared across sessions, by provider.",
+	}, []string{"provider"})
+
+	providerHealthChecks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "perceptus_provider_health_checks_total",
+		Help: "Health checks of pooled provider connections, by provider and result.",
+	}, []string{"provider", "result"})
+)
+
+// metricLabelLimiter caps the number of distinct values per label dimension
+// so a misbehaving client cannot explode series cardinality. Values past the
+// cap are reported as "other".
+type metricLabelLimiter struct {
+	mu     sync.Mutex
+	max    int
+	values map[string]map[string]bool
+}
+
+var labelLimiter = &metricLabelLimiter{values: make(map[string]map[string]bool)}
+
+func (l *metricLabelLimiter) admit(dimension, value string) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Read lazily so the installed configuration is picked up
+	if l.max == 0 {
+		l.max = CurrentConfig().MetricsLabelMaxValues
+	}
+
+	seen, ok := l.values[dimension]
+	if !ok {
+		seen = make(map[string]bool)
+		l.values[dimension] = seen
+	}
+	if seen[value] {
+		return value
+	}
+	if len(seen) >= l.max {
+		return "other"
+	}
+	seen[value] = true
+	return value
+}
+
+func enabledMetricLabels() map[string]bool {
+	value := CurrentConfig().MetricsLabels
+	if value == "" {
+		value = strings.Join(defaultMetricLabels, ",")
+	}
+	enabled := make(map[string]bool)
+	for _, name := range strings.Split(value, ",") {
+		enabled[strings.TrimSpace(name)] = true
+	}
+	return enabled
+}
+
+// sanitizeLabelValue keeps label values short and free of characters that
+// make dashboards awkward to query.
+func sanitizeLabelValue(value string) string {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value == "" {
+		return "unknown"
+	}
+	if len(value) > 64 {
+		value = value[:64]
+	}
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		}
+		return '_'
+	}, value)
+}
+
+// MetricLabels are the per-session metric dimensions.
+type MetricLabels struct {
+	Tenant     string
+	RobotModel string
+	Profile    string
+	Site       string
+}
+
+// NewMetricLabels sanitizes and caps the label values of a session.
+func NewMetricLabels(tenant, robotModel, profile, site string) MetricLabels {
+	enabled := enabledMetricLabels()
+	value := func(dimension, raw string) string {
+		if !enabled[dimension] {
+			return ""
+		}
+		return labelLimiter.admit(dimension, sanitizeLabelValue(raw))
+	}
+	return MetricLabels{
+		Tenant:     value(METRIC_LABEL_TENANT, tenant),
+		RobotModel: value(METRIC_LABEL_ROBOT_MODEL, robotModel),
+		Profile:    value(METRIC_LABEL_PROFILE, profile),
+		Site:       value(METRIC_LABEL_SITE, site),
+	}
+}
+
+func (l MetricLabels) values(extra ...string) []string {
+	return append(extra, l.Tenant, l.RobotModel, l.Profile, l.Site)
+}
+
+func (l MetricLabels) SessionStarted() {
+	sessionsTotal.WithLabelValues(l.values()...).Inc()
+	sessionsActive.WithLabelValues(l.values()...).Inc()
+}
+
+func (l MetricLabels) SessionEnded() {
+	sessionsActive.WithLabelValues(l.values()...).Dec()
+}
+
+func (l MetricLabels) MessageReceived(messageType string) {
+	messagesTotal.WithLabelValues(l.values(messageType)...).Inc()
+}
+
+func (l MetricLabels) ObserveAnalysis(kind string, seconds float64) {
+	analysisDuration.WithLabelValues(l.values(kind)...).Observe(seconds)
+}
+
+func (l MetricLabels) ProviderError(provider string) {
+	providerErrors.WithLabelValues(l.values(provider)...).Inc()
+}
+
+func (l MetricLabels) OutboundDropped(messageType string) {
+	outboundDropped.WithLabelValues(l.values(messageType)...).Inc()
+}
+
+func (l MetricLabels) WorkerPanic(worker string) {
+	workerPanics.WithLabelValues(l.values(worker)...).Inc()
+}
@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+)
+
+// MicrophoneStream captures a local microphone with ffmpeg. It yields mono
+// linear16 PCM when the format asks for it and webm/opus otherwise, which
+// Deepgram detects on its own.
+type MicrophoneStream struct {
+	cmd    *exec.Cmd
+	stdout io.ReadCloser
+	stderr stderrTail
+}
+
+// OpenMicrophone starts capturing a local audio device (see
+// NewDeviceCapture for the device naming of each platform). The capture
+// stops when ctx is cancelled or Close is called.
+func OpenMicrophone(ctx context.Context, device string, format AudioFormat) (*MicrophoneStream, error) {
+	inputArgs, err := localDeviceArgs("audio", device)
+	if err != nil {
+		return nil, err
+	}
+
+	args := append([]string{"-hide_banner", "-loglevel", "error"}, inputArgs...)
+	args = append(args, "-ac", "1")
+	switch format.Encoding {
+	case AudioEncodingLinear16:
+		args = append(args, "-ar", strconv.Itoa(format.SampleRate), "-f", "s16le", "-acodec", "pcm_s16le")
+	case AudioEncodingOpus:
+		args = append(args, "-c:a", "libopus", "-b:a", "24k", "-f", "ogg", "-page_duration", "100000")
+	case AudioEncodingAAC:
+		args = append(args, "-c:a", "aac", "-b:a", "48k", "-f", "adts")
+	default:
+		args = append(args, "-c:a", "libopus", "-f", "webm", "-cluster_time_limit", "100")
+	}
+	args = append(args, "pipe:1")
+
+	m := &MicrophoneStream{cmd: exec.CommandContext(ctx, "ffmpeg", args...)}
+	m.cmd.Stderr = &m.stderr
+	m.stdout, err = m.cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open ffmpeg output: %w", err)
+	}
+	if err := m.cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start ffmpeg: %w", err)
+	}
+	return m, nil
+}
+
+func (m *MicrophoneStream) Read(p []byte) (int, error) {
+	n, err := m.stdout.Read(p)
+	if err == io.EOF {
+		if waitErr := m.cmd.Wait(); waitErr != nil {
+			return n, fmt.Errorf("ffmpeg microphone capture failed: %w: %s", waitErr, m.stderr.String())
+		}
+	}
+	return n, err
+}
+
+func (m *MicrophoneStream) Close() error {
+	if m.cmd.Process != nil {
+		m.cmd.Process.Kill()
+	}
+	return nil
+}
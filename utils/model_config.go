@@ -0,0 +1,159 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// Tasks that use a configurable model.
+const (
+	MODEL_TASK_INTENTION     = "intention"
+	MODEL_TASK_VISION        = "vision"
+	MODEL_TASK_SUMMARIZATION = "summarization"
+	MODEL_TASK_EMBEDDING     = "embedding"
+	MODEL_TASK_STT           = "stt"
+	MODEL_TASK_SENTIMENT     = "sentiment"
+)
+
+var defaultTaskModels = map[string]string{
+	MODEL_TASK_INTENTION:     IntentionModel,
+	MODEL_TASK_VISION:        VisionModel,
+	MODEL_TASK_SUMMARIZATION: SummarizationModel,
+	MODEL_TASK_EMBEDDING:     EmbeddingModel,
+	MODEL_TASK_STT:           DeepgramModel,
+	MODEL_TASK_SENTIMENT:     SentimentModel,
+}
+
+var modelNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._:/-]*$`)
+
+// ModelChains maps tasks to the models tried in order; later models are
+// fallbacks used when an earlier one is missing or deprecated.
+type ModelChains map[string][]string
+
+// ParseModelChain parses a comma-separated model list.
+func ParseModelChain(value string) ([]string, error) {
+	var chain []string
+	for _, model := range strings.Split(value, ",") {
+		model = strings.TrimSpace(model)
+		if model == "" {
+			continue
+		}
+		if !modelNamePattern.MatchString(model) {
+			return nil, fmt.Errorf("invalid model name %q", model)
+		}
+		chain = append(chain, model)
+	}
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("empty model list")
+	}
+	return chain, nil
+}
+
+// ValidateModelChains checks that every task is known and every chain holds
+// valid model names.
+func ValidateModelChains(chains ModelChains) error {
+	for task, chain := range chains {
+		if _, ok := defaultTaskModels[task]; !ok {
+			return fmt.Errorf("unknown model task %q", task)
+		}
+		if len(chain) == 0 {
+			return fmt.Errorf("%s: empty model list", task)
+		}
+		for _, model := range chain {
+			if !modelNamePattern.MatchString(model) {
+				return fmt.Errorf("%s: invalid model name %q", task, model)
+			}
+		}
+	}
+	return nil
+}
+
+// DefaultModelChain returns the chain for a task from MODEL_<TASK>, e.g.
+// MODEL_INTENTION=gpt-4.1-2025-04-14,gpt-4.1-nano-2025-04-14, or the built-in
+// default.
+func DefaultModelChain(task string) []string {
+	if chain, ok := CurrentConfig().Models[task]; ok {
+		return chain
+	}
+	return []string{defaultTaskModels[task]}
+}
+
+// RecordModelVersions reports the configured primary models as worker
+// components.
+func RecordModelVersions() {
+	SetComponentVersion("stt", "deepgram/"+DefaultModelChain(MODEL_TASK_STT)[0])
+	SetComponentVersion("intention_model", DefaultModelChain(MODEL_TASK_INTENTION)[0])
+	SetComponentVersion("vision_model", DefaultModelChain(MODEL_TASK_VISION)[0])
+	SetComponentVersion("embedding_model", DefaultModelChain(MODEL_TASK_EMBEDDING)[0])
+}
+
+// OpenAIError is a non-200 response of the OpenAI API.
+type OpenAIError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *OpenAIError) Error() string {
+	return fmt.Sprintf("OpenAI API returned status %d: %s", e.StatusCode, e.Body)
+}
+
+// IsModelUnavailable reports whether err means the requested model does not
+// exist (anymore) for this account.
+func IsModelUnavailable(err error) bool {
+	var apiErr *OpenAIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	if apiErr.StatusCode == 404 {
+		return true
+	}
+	body := strings.ToLower(apiErr.Body)
+	return strings.Contains(body, "model_not_found") ||
+		strings.Contains(body, "deprecated") ||
+		strings.Contains(body, "does not exist")
+}
+
+func (c *OpenAIClient) modelChain(task string) []string {
+	if c.ModelSource != nil {
+		if chain := c.ModelSource(task); len(chain) > 0 {
+			return chain
+		}
+	}
+	return DefaultModelChain(task)
+}
+
+// withModelFallback calls fn with each model of the task's chain until one
+// succeeds or fails for a reason other than the model being unavailable.
+func (c *OpenAIClient) withModelFallback(task string, fn func(model string) error) error {
+	chain := c.modelChain(task)
+	var err error
+	for i, model := range chain {
+		err = fn(model)
+		if err == nil || !IsModelUnavailable(err) {
+			return err
+		}
+		if i+1 < len(chain) {
+			zap.L().Warn("Model unavailable, falling back",
+				zap.String("task", task), zap.String("model", model),
+				zap.String("fallback", chain[i+1]), zap.Error(err))
+		}
+	}
+	return err
+}
+
+// completeTask runs a chat completion with the task's model chain.
+func (c *OpenAIClient) completeTask(ctx context.Context, task string, requestBody map[string]interface{}) (*GPTResponseMessage, error) {
+	var message *GPTResponseMessage
+	err := c.withModelFallback(task, func(model string) error {
+		requestBody["model"] = model
+		var err error
+		message, err = c.chatCompletionMessage(ctx, task, requestBody)
+		return err
+	})
+	return message, err
+}
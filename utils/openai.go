@@ -5,9 +5,8 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
+	"math"
 	"net/http"
-	"os"
 	"strings"
 	"time"
 
@@ -15,24 +14,51 @@ import (
 	"go.uber.org/zap"
 )
 
+const (
+	IntentionModel     = "gpt-4.1-nano-2025-04-14"
+	VisionModel        = "gpt-4.1-nano-2025-04-14" // vision-enabled model
+	SummarizationModel = "gpt-4.1-nano-2025-04-14"
+	SentimentModel     = "gpt-4.1-nano-2025-04-14"
+	EmbeddingModel     = "text-embedding-3-small"
+)
+
 type OpenAIClient struct {
 	APIKey string
 	Client *http.Client
+
+	// KeySource, when set, is consulted on every request so rotated keys
+	// apply without recreating the client
+	KeySource func() string
+
+	// ModelSource, when set, returns the model chain for a task, e.g. with
+	// per-session overrides; otherwise the environment configuration is used
+	ModelSource func(task string) []string
+
+	// RetryPolicy, when set, replaces the policy read from the configuration
+	RetryPolicy *OpenAIRetryPolicy
+
+	// Clock, when set, times retry waits instead of the wall clock
+	Clock Clock
 }
 
 type GPTMessage struct {
-	Role    string      `json:"role"`
-	Content interface{} `json:"content"`
+	Role       string      `json:"role"`
+	Content    interface{} `json:"content"`
+	ToolCalls  []ToolCall  `json:"tool_calls,omitempty"`
+	ToolCallID string      `json:"tool_call_id,omitempty"`
 }
 
 type GPTResponse struct {
 	Choices []struct {
-		Message struct {
-			Content string `json:"content"`
-		} `json:"message"`
+		Message GPTResponseMessage `json:"message"`
 	} `json:"choices"`
 }
 
+type GPTResponseMessage struct {
+	Content   string     `json:"content"`
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+}
+
 type ImageContent struct {
 	Type     string `json:"type"`
 	Text     string `json:"text,omitempty"`
@@ -42,34 +68,79 @@ type ImageContent struct {
 }
 
 func NewOpenAIClient() *OpenAIClient {
-	apiKey := os.Getenv("OPENAI_API_KEY")
+	apiKey := CurrentConfig().OpenAIAPIKey
 	if apiKey == "" {
 		zap.L().Fatal("OPENAI_API_KEY environment variable not set")
 	}
 
+	return NewOpenAIClientWithKey(apiKey)
+}
+
+// NewOpenAIClientWithKey creates a client for an explicit (e.g. per-tenant) key.
+func NewOpenAIClientWithKey(apiKey string) *OpenAIClient {
+	if apiKey == "" {
+		zap.L().Error("OpenAI API key not configured")
+	}
+
 	return &OpenAIClient{
 		APIKey: apiKey,
-		Client: &http.Client{Timeout: 30 * time.Second},
+		Client: sharedOpenAIHTTPClient(),
+	}
+}
+
+// NewOpenAIClientWithKeySource creates a client reading its key per request.
+func NewOpenAIClientWithKeySource(source func() string) *OpenAIClient {
+	client := NewOpenAIClientWithKey(source())
+	client.KeySource = source
+	return client
+}
+
+func (c *OpenAIClient) apiKey() string {
+	if c.KeySource != nil {
+		if key := c.KeySource(); key != "" {
+			return key
+		}
 	}
+	return c.APIKey
+}
+
+func (c *OpenAIClient) AnalyzeTranscriptForIntention(ctx context.Context, transcript string, environmentContext, preferences, conversation []string) (*models.IntentionResult, error) {
+	return c.sendRequest(ctx, IntentionRequestBody(transcript, environmentContext, preferences, conversation))
 }
 
-func (c *OpenAIClient) AnalyzeTranscriptForIntention(ctx context.Context, transcript string, environmentContext []string) (*models.IntentionResult, error) {
+// IntentionRequestBody builds the chat completion request used for intention
+// analysis, shared by the online path and the batch re-analysis job.
+// Preferences are remembered facts about the user that help resolve vague
+// requests ("the usual", "my charger"). Conversation is what was said
+// before the transcript, oldest first, so follow-ups such as "bring it here
+// instead" can be resolved.
+func IntentionRequestBody(transcript string, environmentContext, preferences, conversation []string) map[string]interface{} {
 	contextStr := ""
 	if len(environmentContext) > 0 {
 		contextStr = "Current environment context:\n" + strings.Join(environmentContext, "\n") + "\n\n"
 	}
+	if len(preferences) > 0 {
+		contextStr += "Known user preferences:\n- " + strings.Join(preferences, "\n- ") + "\n\n"
+	}
+	if len(conversation) > 0 {
+		contextStr += "Conversation before the transcript (oldest first):\n- " + strings.Join(conversation, "\n- ") + "\n\n"
+	}
 
+	types := IntentionTypes()
 	prompt := fmt.Sprintf(`%sAnalyze the following transcript to determine if the user has expressed a clear intention for the robot to perform a task.
 
 Transcript: "%s"
 
 Please analyze this transcript and respond with a JSON object containing:
 - "has_clear_intention": boolean indicating if there's a clear actionable intention
-- "intention_type": string describing the type of intention (e.g., "navigation", "manipulation", "information_gathering", etc.)
+- "intention_type": one of the intention types below, or "none"
 - "description": string with a detailed description of what the user wants
 - "confidence": float between 0 and 1 indicating confidence in the analysis
 - "reasoning": string explaining your analysis
+- "slots": the slots of the chosen intention type filled with concrete values; use null for slots that are not mentioned or do not apply. Datetimes are RFC 3339 timestamps
 
+Intention types:
+%s
 Examples of clear intentions:
 - "Go to the kitchen and bring me a glass of water"
 - "Move to the living room"
@@ -82,17 +153,7 @@ Examples of unclear/no intentions:
 - "What time is it?"
 - General conversation without specific requests
 
-Return the JSON object only, no other text.
-Return in the following format:
-{
-	"has_clear_intention": boolean,
-	"intention_type": string,
-	"description": string,
-	"confidence": float,
-	"reasoning": string
-}
-
-Be conservative - only mark as clear intention if the user is explicitly asking the robot to do something specific.`, contextStr, transcript)
+Be conservative - only mark as clear intention if the user is explicitly asking the robot to do something specific.`, contextStr, transcript, intentionTypesPrompt(types))
 
 	messages := []GPTMessage{
 		{
@@ -101,22 +162,63 @@ Be conservative - only mark as clear intention if the user is explicitly asking
 		},
 	}
 
-	requestBody := map[string]interface{}{
-		"model":    "gpt-4.1-nano-2025-04-14",
-		"messages": messages,
+	return map[string]interface{}{
+		"model":           DefaultModelChain(MODEL_TASK_INTENTION)[0],
+		"messages":        messages,
+		"response_format": intentionResponseFormat(types),
 	}
-
-	return c.sendRequest(ctx, requestBody)
 }
 
 // AnalyzeImageContext requests a detailed, structured, holistic context description.
 func (c *OpenAIClient) AnalyzeImageContext(ctx context.Context, imageData string) (*models.EnvironmentContext, error) {
+	message, err := c.completeTask(ctx, MODEL_TASK_VISION, ImageContextRequestBody(imageData))
+	if err != nil {
+		return nil, err
+	}
+	return ParseEnvironmentContent(message.Content)
+}
+
+// AnalyzeImageWithDepth is AnalyzeImageContext with a colorized depth map of
+// the same view, so the model can judge distances and free space.
+func (c *OpenAIClient) AnalyzeImageWithDepth(ctx context.Context, imageData, depthImage string) (*models.EnvironmentContext, error) {
+	body := ImageContextRequestBody(imageData)
+	if depthImage != "" {
+		AddDepthImage(body, depthImage)
+	}
+	message, err := c.completeTask(ctx, MODEL_TASK_VISION, body)
+	if err != nil {
+		return nil, err
+	}
+	return ParseEnvironmentContent(message.Content)
+}
+
+// AddDepthImage appends a depth rendering to a scene analysis request.
+func AddDepthImage(body map[string]interface{}, depthImage string) {
+	messages := body["messages"].([]map[string]interface{})
+	user := messages[len(messages)-1]
+	user["content"] = append(user["content"].([]map[string]interface{}),
+		map[string]interface{}{
+			"type": "text",
+			"text": "The second image is a depth map of the same view: red is near, blue is far, black has no reading. Use it for distances, obstacles and free space.",
+		},
+		map[string]interface{}{
+			"type":      "image_url",
+			"image_url": map[string]string{"url": depthImage},
+		},
+	)
+}
+
+// ImageContextRequestBody builds the vision request used for scene analysis.
+func ImageContextRequestBody(imageData string) map[string]interface{} {
 	systemPrompt := `You are a vision-enabled assistant. Return ONLY a JSON object with key: overview (string), key_elements (array of strings), layout (string), activities (array of strings), additional_info (object of string pairs). No extra keys or prose.`
+	if VisionRegionsEnabled() {
+		systemPrompt = `You are a vision-enabled assistant. Return ONLY a JSON object with key: overview (string), key_elements (array of strings), layout (string), activities (array of strings), additional_info (object of string pairs), regions (array of objects with label, x, y, width, height, confidence: the bounding box of each visible key element, coordinates as fractions 0-1 of the image width and height from the top-left corner). No extra keys or prose.`
+	}
 
 	userPrompt := "Analyze the scene depicted by the image below and output a structured JSON context description."
 
 	payload := map[string]interface{}{
-		"model": "gpt-4.1-nano-2025-04-14", // vision-enabled model
+		"model": DefaultModelChain(MODEL_TASK_VISION)[0],
 		"messages": []map[string]interface{}{
 			{
 				"role":    "system",
@@ -140,85 +242,75 @@ func (c *OpenAIClient) AnalyzeImageContext(ctx context.Context, imageData string
 		},
 	}
 
-	bodyBytes, err := json.Marshal(payload)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
-	}
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
-		"https://api.openai.com/v1/chat/completions", bytes.NewBuffer(bodyBytes))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
-	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+c.APIKey)
-
-	resp, err := c.Client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed API request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		b, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("OpenAI API error: %s", string(b))
-	}
-
-	var raw struct {
-		Choices []struct {
-			Message struct {
-				Content string `json:"content"`
-			} `json:"message"`
-		} `json:"choices"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
-	}
-	if len(raw.Choices) == 0 {
-		return nil, fmt.Errorf("no choices in response")
-	}
+	return payload
+}
 
-	content := raw.Choices[0].Message.Content
+// ParseEnvironmentContent decodes the model's JSON scene description.
+func ParseEnvironmentContent(content string) (*models.EnvironmentContext, error) {
 	clean := strings.TrimSpace(content)
 	clean = strings.TrimPrefix(clean, "```json")
 	clean = strings.TrimSuffix(clean, "```")
-	zap.L().Debug("OpenAI context JSON", zap.String("content", content))
+	// Without the content: the global logger has no session's redaction
+	zap.L().Debug("OpenAI context JSON", zap.Int("content_length", len(content)))
 
 	var ctxDesc models.EnvironmentContext
 	if err := json.Unmarshal([]byte(clean), &ctxDesc); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal context JSON: %w", err)
 	}
+	ctxDesc.Regions = NormalizeRegions(ctxDesc.Regions)
 
 	return &ctxDesc, nil
 }
 
 func (c *OpenAIClient) sendRequest(ctx context.Context, requestBody map[string]interface{}) (*models.IntentionResult, error) {
-	requestBodyBytes, err := json.Marshal(requestBody)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request body: %w", err)
-	}
-
-	req, err := http.NewRequestWithContext(ctx, "POST", "https://api.openai.com/v1/chat/completions", bytes.NewBuffer(requestBodyBytes))
+	message, err := c.completeTask(ctx, MODEL_TASK_INTENTION, requestBody)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+		return nil, err
 	}
+	return ParseIntentionContent(message.Content), nil
+}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+c.APIKey)
+// Warmup sends a one-token completion on a task's model chain so the first
+// real request does not pay for connection setup.
+func (c *OpenAIClient) Warmup(ctx context.Context, task string) error {
+	_, err := c.completeTask(ctx, task, map[string]interface{}{
+		"messages": []GPTMessage{
+			{Role: "user", Content: "ping"},
+		},
+		"max_tokens": 1,
+	})
+	return err
+}
 
-	resp, err := c.Client.Do(req)
+// ChatCompletion sends a chat completion request and returns the content of
+// the first choice.
+func (c *OpenAIClient) ChatCompletion(ctx context.Context, requestBody map[string]interface{}) (string, error) {
+	message, err := c.chatCompletionMessage(ctx, OPENAI_TASK_CHAT, requestBody)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send HTTP request: %w", err)
+		return "", err
 	}
-	defer resp.Body.Close()
+	return message.Content, nil
+}
 
-	bodyBytes, err := io.ReadAll(resp.Body)
+// chatCompletionMessage sends a chat completion request and returns the
+// message of the first choice, including any tool calls. Rate limits and
+// server errors are retried with the task's timeout.
+func (c *OpenAIClient) chatCompletionMessage(ctx context.Context, task string, requestBody map[string]interface{}) (*GPTResponseMessage, error) {
+	requestBodyBytes, err := json.Marshal(requestBody)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
+		return nil, fmt.Errorf("failed to marshal request body: %w", err)
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("OpenAI API returned status %d: %s", resp.StatusCode, string(bodyBytes))
+	bodyBytes, err := c.do(ctx, task, func(ctx context.Context) (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "POST", openAIBaseURL()+"/chat/completions", bytes.NewReader(requestBodyBytes))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		return req, nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	var response GPTResponse
@@ -230,24 +322,119 @@ func (c *OpenAIClient) sendRequest(ctx context.Context, requestBody map[string]i
 		return nil, fmt.Errorf("no choices in OpenAI API response")
 	}
 
-	content := response.Choices[0].Message.Content
-	zap.L().Debug("OpenAI response content", zap.String("content", content))
+	return &response.Choices[0].Message, nil
+}
+
+// intentionResponse is the JSON answer of the intention model.
+type intentionResponse struct {
+	HasClearIntention bool                   `json:"has_clear_intention"`
+	IntentionType     string                 `json:"intention_type"`
+	Description       string                 `json:"description"`
+	Confidence        float64                `json:"confidence"`
+	Reasoning         string                 `json:"reasoning"`
+	Slots             map[string]interface{} `json:"slots"`
+}
+
+// ParseIntentionContent decodes the model's intention JSON, falling back to an
+// empty result when the content is not valid JSON.
+func ParseIntentionContent(content string) *models.IntentionResult {
+	zap.L().Debug("OpenAI response content", zap.Int("content_length", len(content)))
 
-	var intentionResult models.IntentionResult
-	if err := json.Unmarshal([]byte(content), &intentionResult); err != nil {
+	var response intentionResponse
+	if err := json.Unmarshal([]byte(content), &response); err != nil {
 		zap.L().Warn("Failed to parse OpenAI response as JSON, using raw content",
 			zap.Error(err),
 			zap.String("content", content))
 
-		intentionResult = models.IntentionResult{
-			HasClearIntention:  intentionResult.HasClearIntention,
-			IntentionType:      intentionResult.IntentionType,
-			Description:        intentionResult.Description,
-			Confidence:         intentionResult.Confidence,
-			EnvironmentContext: intentionResult.EnvironmentContext,
-			Timestamp:          time.Now(),
+		return &models.IntentionResult{Timestamp: time.Now()}
+	}
+
+	return &models.IntentionResult{
+		HasClearIntention: response.HasClearIntention,
+		IntentionType:     response.IntentionType,
+		Description:       response.Description,
+		Confidence:        response.Confidence,
+		Slots:             NormalizeSlots(response.IntentionType, response.Slots),
+		Timestamp:         time.Now(),
+	}
+}
+
+// CreateEmbedding returns the embedding vector for text.
+func (c *OpenAIClient) CreateEmbedding(ctx context.Context, text string) ([]float64, error) {
+	var response struct {
+		Data []struct {
+			Embedding []float64 `json:"embedding"`
+		} `json:"data"`
+	}
+	err := c.withModelFallback(MODEL_TASK_EMBEDDING, func(model string) error {
+		requestBody, err := json.Marshal(map[string]interface{}{
+			"model": model,
+			"input": text,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to marshal request body: %w", err)
 		}
+		return c.doJSON(ctx, MODEL_TASK_EMBEDDING, http.MethodPost, "/embeddings", "application/json", requestBody, &response)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create embedding: %w", err)
+	}
+	if len(response.Data) == 0 {
+		return nil, fmt.Errorf("no embedding in OpenAI API response")
+	}
+	return response.Data[0].Embedding, nil
+}
+
+// CosineSimilarity returns the cosine similarity of two equal-length vectors.
+func CosineSimilarity(a, b []float64) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// SummarizeEnvironment folds new scene descriptions into a rolling world state
+// document describing the stable layout, objects and recent changes.
+func (c *OpenAIClient) SummarizeEnvironment(ctx context.Context, previousSummary string, contexts []models.EnvironmentContext) (string, error) {
+	var observations strings.Builder
+	for _, envContext := range contexts {
+		fmt.Fprintf(&observations, "[%s] overview: %s; key elements: %s; layout: %s; activities: %s\n",
+			envContext.Timestamp.Format(time.RFC3339),
+			envContext.Overview,
+			strings.Join(envContext.KeyElements, ", "),
+			envContext.Layout,
+			strings.Join(envContext.Activities, ", "))
+	}
+
+	if previousSummary == "" {
+		previousSummary = "(none yet)"
 	}
 
-	return &intentionResult, nil
+	prompt := fmt.Sprintf(`You maintain the "world state" memory of a robot. Merge the new observations into the current world state.
+
+Current world state:
+%s
+
+New observations (oldest first):
+%s
+Write an updated world state in plain prose of at most 200 words: the stable layout, notable objects and where they are, people and ongoing activities, and what changed recently. Drop redundant or outdated details. Return only the world state text.`, previousSummary, observations.String())
+
+	message, err := c.completeTask(ctx, MODEL_TASK_SUMMARIZATION, map[string]interface{}{
+		"messages": []GPTMessage{
+			{Role: "user", Content: prompt},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	return message.Content, nil
 }
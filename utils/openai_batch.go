@@ -0,0 +1,211 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// openAIBaseURL is the root of the OpenAI API, OPENAI_BASE_URL when set, e.g.
+// for a proxy, an OpenAI-compatible server or a test double. Read per call
+// so a reload applies it.
+func openAIBaseURL() string {
+	if url := CurrentConfig().OpenAIBaseURL; url != "" {
+		return strings.TrimRight(url, "/")
+	}
+	return "https://api.openai.com/v1"
+}
+
+// BatchRequest is a single line of a Batch API input file.
+type BatchRequest struct {
+	CustomID string                 `json:"custom_id"`
+	Method   string                 `json:"method"`
+	URL      string                 `json:"url"`
+	Body     map[string]interface{} `json:"body"`
+}
+
+type Batch struct {
+	ID            string `json:"id"`
+	Status        string `json:"status"`
+	InputFileID   string `json:"input_file_id"`
+	OutputFileID  string `json:"output_file_id"`
+	ErrorFileID   string `json:"error_file_id"`
+	RequestCounts struct {
+		Total     int `json:"total"`
+		Completed int `json:"completed"`
+		Failed    int `json:"failed"`
+	} `json:"request_counts"`
+}
+
+// Done reports whether the batch has reached a terminal state.
+func (b *Batch) Done() bool {
+	switch b.Status {
+	case "completed", "failed", "expired", "cancelled":
+		return true
+	}
+	return false
+}
+
+// SubmitBatch uploads the requests as a JSONL file and creates a 24h batch.
+func (c *OpenAIClient) SubmitBatch(ctx context.Context, requests []BatchRequest) (*Batch, error) {
+	var jsonl bytes.Buffer
+	enc := json.NewEncoder(&jsonl)
+	for _, r := range requests {
+		if err := enc.Encode(r); err != nil {
+			return nil, fmt.Errorf("failed to encode batch request: %w", err)
+		}
+	}
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	if err := writer.WriteField("purpose", "batch"); err != nil {
+		return nil, fmt.Errorf("failed to write purpose field: %w", err)
+	}
+	part, err := writer.CreateFormFile("file", "reanalysis.jsonl")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create form file: %w", err)
+	}
+	if _, err := part.Write(jsonl.Bytes()); err != nil {
+		return nil, fmt.Errorf("failed to write batch file: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close multipart writer: %w", err)
+	}
+
+	var file struct {
+		ID string `json:"id"`
+	}
+	if err := c.doJSON(ctx, OPENAI_TASK_BATCH, http.MethodPost, "/files", writer.FormDataContentType(), body.Bytes(), &file); err != nil {
+		return nil, fmt.Errorf("failed to upload batch file: %w", err)
+	}
+
+	createBody, err := json.Marshal(map[string]interface{}{
+		"input_file_id":     file.ID,
+		"endpoint":          "/v1/chat/completions",
+		"completion_window": "24h",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal batch request: %w", err)
+	}
+
+	var batch Batch
+	if err := c.doJSON(ctx, OPENAI_TASK_BATCH, http.MethodPost, "/batches", "application/json", createBody, &batch); err != nil {
+		return nil, fmt.Errorf("failed to create batch: %w", err)
+	}
+	return &batch, nil
+}
+
+func (c *OpenAIClient) GetBatch(ctx context.Context, batchID string) (*Batch, error) {
+	var batch Batch
+	if err := c.doJSON(ctx, OPENAI_TASK_BATCH, http.MethodGet, "/batches/"+batchID, "", nil, &batch); err != nil {
+		return nil, fmt.Errorf("failed to get batch %s: %w", batchID, err)
+	}
+	return &batch, nil
+}
+
+// WaitForBatch polls the batch until it reaches a terminal state or ctx ends.
+func (c *OpenAIClient) WaitForBatch(ctx context.Context, batchID string, pollInterval time.Duration) (*Batch, error) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		batch, err := c.GetBatch(ctx, batchID)
+		if err != nil {
+			return nil, err
+		}
+		if batch.Done() {
+			return batch, nil
+		}
+		zap.L().Info("Waiting for batch",
+			zap.String("batch_id", batchID),
+			zap.String("status", batch.Status),
+			zap.Int("completed", batch.RequestCounts.Completed),
+			zap.Int("total", batch.RequestCounts.Total))
+
+		select {
+		case <-ctx.Done():
+			return batch, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// DownloadBatchResults fetches the output file and returns the assistant
+// message content keyed by custom_id.
+func (c *OpenAIClient) DownloadBatchResults(ctx context.Context, fileID string) (map[string]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.retryPolicy().timeout(OPENAI_TASK_BATCH))
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, openAIBaseURL()+"/files/"+fileID+"/content", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.apiKey())
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download batch output: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("OpenAI API returned status %d: %s", resp.StatusCode, string(b))
+	}
+
+	results := make(map[string]string)
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var line struct {
+			CustomID string `json:"custom_id"`
+			Response struct {
+				StatusCode int         `json:"status_code"`
+				Body       GPTResponse `json:"body"`
+			} `json:"response"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			zap.L().Warn("Skipping malformed batch output line", zap.Error(err))
+			continue
+		}
+		if line.Response.StatusCode != http.StatusOK || len(line.Response.Body.Choices) == 0 {
+			continue
+		}
+		results[line.CustomID] = line.Response.Body.Choices[0].Message.Content
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read batch output: %w", err)
+	}
+	return results, nil
+}
+
+// doJSON sends body to an API path, retried per the client's retry policy,
+// and decodes the JSON response into out.
+func (c *OpenAIClient) doJSON(ctx context.Context, task, method, path, contentType string, body []byte, out interface{}) error {
+	bodyBytes, err := c.do(ctx, task, func(ctx context.Context) (*http.Request, error) {
+		var reader io.Reader
+		if body != nil {
+			reader = bytes.NewReader(body)
+		}
+		req, err := http.NewRequestWithContext(ctx, method, openAIBaseURL()+path, reader)
+		if err != nil {
+			return nil, err
+		}
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		return req, nil
+	})
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(bodyBytes, out)
+}
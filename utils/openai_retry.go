@@ -0,0 +1,215 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Requests that are not for a model task, labelled for timeouts and retry
+// metrics: the Batch API (file uploads and downloads) and ChatCompletion.
+const (
+	OPENAI_TASK_BATCH = "batch"
+	OPENAI_TASK_CHAT  = "chat"
+)
+
+// Why an OpenAI request was retried.
+const (
+	OPENAI_RETRY_RATE_LIMITED = "rate_limited"
+	OPENAI_RETRY_SERVER_ERROR = "server_error"
+	OPENAI_RETRY_TIMEOUT      = "timeout"
+	OPENAI_RETRY_NETWORK      = "network"
+)
+
+// OpenAIRetryPolicy decides how failed OpenAI requests are retried: 429 and
+// 5xx responses, timeouts and network errors are retried up to MaxAttempts,
+// waiting for the Retry-After the API asks for or an exponential backoff
+// with jitter. Each attempt has its own timeout.
+type OpenAIRetryPolicy struct {
+	MaxAttempts int
+	Backoff     time.Duration
+	MaxBackoff  time.Duration
+	// MaxRetryAfter caps the Retry-After honored; a longer wait fails the
+	// request rather than holding up the session
+	MaxRetryAfter time.Duration
+	// Timeout of an attempt, and per task overrides
+	Timeout      time.Duration
+	TaskTimeouts map[string]time.Duration
+}
+
+// OpenAIRetryPolicyFromConfig reads OPENAI_MAX_ATTEMPTS,
+// OPENAI_RETRY_BACKOFF, OPENAI_RETRY_MAX_BACKOFF, OPENAI_MAX_RETRY_AFTER,
+// OPENAI_REQUEST_TIMEOUT and OPENAI_REQUEST_TIMEOUT_<TASK>.
+func OpenAIRetryPolicyFromConfig(cfg *Config) OpenAIRetryPolicy {
+	policy := OpenAIRetryPolicy{
+		MaxAttempts:   max(cfg.OpenAIMaxAttempts, 1),
+		Backoff:       cfg.OpenAIRetryBackoff,
+		MaxBackoff:    cfg.OpenAIRetryMaxBackoff,
+		MaxRetryAfter: cfg.OpenAIMaxRetryAfter,
+		Timeout:       cfg.OpenAIRequestTimeout,
+		TaskTimeouts:  map[string]time.Duration{OPENAI_TASK_BATCH: 5 * time.Minute},
+	}
+	for task, timeout := range cfg.OpenAIRequestTimeouts {
+		if timeout > 0 {
+			policy.TaskTimeouts[task] = timeout
+		}
+	}
+	return policy
+}
+
+func (p OpenAIRetryPolicy) timeout(task string) time.Duration {
+	if timeout, ok := p.TaskTimeouts[task]; ok {
+		return timeout
+	}
+	return p.Timeout
+}
+
+// backoff returns the jittered wait before the given retry (1 for the
+// first): half the exponential delay plus a random share of the other half.
+func (p OpenAIRetryPolicy) backoff(retry int) time.Duration {
+	delay := min(p.Backoff<<(retry-1), p.MaxBackoff)
+	if delay <= 0 {
+		return 0
+	}
+	return delay/2 + rand.N(delay/2+1)
+}
+
+func (c *OpenAIClient) clock() Clock {
+	if c.Clock != nil {
+		return c.Clock
+	}
+	return SystemClock{}
+}
+
+func (c *OpenAIClient) retryPolicy() OpenAIRetryPolicy {
+	if c.RetryPolicy != nil {
+		return *c.RetryPolicy
+	}
+	return OpenAIRetryPolicyFromConfig(CurrentConfig())
+}
+
+// do sends the request built by newRequest, retrying per the client's
+// retry policy, and returns the body of the 200 response. newRequest is
+// called for every attempt.
+func (c *OpenAIClient) do(ctx context.Context, task string, newRequest func(ctx context.Context) (*http.Request, error)) ([]byte, error) {
+	policy := c.retryPolicy()
+	clock := c.clock()
+	for attempt := 1; ; attempt++ {
+		body, header, err := c.attempt(ctx, policy.timeout(task), newRequest)
+		if err == nil {
+			return body, nil
+		}
+		reason := openAIRetryReason(ctx, err)
+		if reason == "" {
+			return nil, err
+		}
+		if attempt >= policy.MaxAttempts {
+			openAIRetriesExhausted.WithLabelValues(task, reason).Inc()
+			return nil, err
+		}
+
+		wait := policy.backoff(attempt)
+		if retryAfter, ok := parseRetryAfter(header, clock.Now()); ok {
+			if retryAfter > policy.MaxRetryAfter {
+				openAIRetriesExhausted.WithLabelValues(task, reason).Inc()
+				return nil, fmt.Errorf("%w (retry after %s)", err, retryAfter)
+			}
+			wait = retryAfter
+		}
+		if deadline, ok := ctx.Deadline(); ok && deadline.Sub(clock.Now()) < wait {
+			openAIRetriesExhausted.WithLabelValues(task, reason).Inc()
+			return nil, err
+		}
+
+		openAIRetries.WithLabelValues(task, reason).Inc()
+		zap.L().Warn("Retrying OpenAI request",
+			zap.String("task", task), zap.String("reason", reason),
+			zap.Int("attempt", attempt), zap.Duration("backoff", wait), zap.Error(err))
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-clock.After(wait):
+		}
+	}
+}
+
+// attempt sends one request with its own timeout. Non-200 responses are
+// returned as *OpenAIError along with their headers.
+func (c *OpenAIClient) attempt(ctx context.Context, timeout time.Duration, newRequest func(ctx context.Context) (*http.Request, error)) ([]byte, http.Header, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := newRequest(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.apiKey())
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to send HTTP request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, resp.Header, &OpenAIError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+	return body, nil, nil
+}
+
+// openAIRetryReason classifies a failed attempt, "" when retrying cannot
+// help: the caller's context is done, the request was rejected, or the
+// account is out of quota (also a 429).
+func openAIRetryReason(ctx context.Context, err error) string {
+	if ctx.Err() != nil {
+		return ""
+	}
+	var apiErr *OpenAIError
+	if errors.As(err, &apiErr) {
+		switch {
+		case apiErr.StatusCode == http.StatusTooManyRequests && !strings.Contains(apiErr.Body, "insufficient_quota"):
+			return OPENAI_RETRY_RATE_LIMITED
+		case apiErr.StatusCode >= 500:
+			return OPENAI_RETRY_SERVER_ERROR
+		}
+		return ""
+	}
+	if errors.Is(err, context.DeadlineExceeded) || os.IsTimeout(err) {
+		return OPENAI_RETRY_TIMEOUT
+	}
+	return OPENAI_RETRY_NETWORK
+}
+
+// parseRetryAfter reads the wait the API asks for: retry-after-ms, else
+// Retry-After in seconds or as an HTTP date.
+func parseRetryAfter(header http.Header, now time.Time) (time.Duration, bool) {
+	if header == nil {
+		return 0, false
+	}
+	if ms, err := strconv.ParseFloat(header.Get("Retry-After-Ms"), 64); err == nil && ms >= 0 {
+		return time.Duration(ms * float64(time.Millisecond)), true
+	}
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds >= 0 {
+		return time.Duration(seconds * float64(time.Second)), true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(at.Sub(now), 0), true
+	}
+	return 0, false
+}
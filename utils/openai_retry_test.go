@@ -0,0 +1,75 @@
+package utils_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+)
+
+func TestOpenAIRetryWaitsForRetryAfter(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.Header().Set("Retry-After", "30")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"}}]}`))
+	}))
+	defer server.Close()
+	defaults := utils.CurrentConfig()
+	cfg := *defaults
+	cfg.OpenAIBaseURL = server.URL
+	utils.SetConfig(&cfg)
+	defer utils.SetConfig(defaults)
+
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := utils.NewManualClock(start)
+	client := utils.NewOpenAIClientWithKey("test")
+	client.Clock = clock
+	client.RetryPolicy = &utils.OpenAIRetryPolicy{
+		MaxAttempts:   2,
+		Backoff:       time.Second,
+		MaxBackoff:    time.Second,
+		MaxRetryAfter: time.Minute,
+		Timeout:       5 * time.Second,
+	}
+
+	type result struct {
+		content string
+		err     error
+	}
+	done := make(chan result, 1)
+	go func() {
+		content, err := client.ChatCompletion(context.Background(), map[string]interface{}{"model": "test"})
+		done <- result{content, err}
+	}()
+
+	// The retry waits on the manual clock only, so it completes once the
+	// clock has been moved past the Retry-After
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case r := <-done:
+			if r.err != nil || r.content != "ok" {
+				t.Fatalf("ChatCompletion = %q, %v, want ok", r.content, r.err)
+			}
+			if waited := clock.Since(start); waited < 30*time.Second {
+				t.Errorf("retried after %s, want the 30s Retry-After", waited)
+			}
+			if calls.Load() != 2 {
+				t.Errorf("requests = %d, want 2", calls.Load())
+			}
+			return
+		case <-timeout:
+			t.Fatal("no response within 5s")
+		case <-time.After(time.Millisecond):
+			clock.Advance(time.Second)
+		}
+	}
+}
@@ -0,0 +1,281 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
+	"github.com/Perceptus-Labs/perceptus-go-sdk/testmode"
+)
+
+const (
+	defaultHMACHeader     = "X-Perceptus-Signature"
+	hmacTimestampHeader   = "X-Perceptus-Timestamp"
+	orchestratorTimeout   = 10 * time.Minute
+	oauthTokenExpirySlack = 30 * time.Second
+)
+
+// IDEMPOTENCY_KEY_HEADER carries the intention or trigger ID of an
+// orchestrator call, the same on every retry, so receivers can drop
+// duplicate deliveries.
+const IDEMPOTENCY_KEY_HEADER = "Idempotency-Key"
+
+// OrchestratorClient posts payloads to an orchestrator with the tenant's
+// authentication scheme and, when configured, a client certificate.
+type OrchestratorClient struct {
+	baseURL string
+	apiKey  string
+	auth    models.OrchestratorAuth
+	http    *http.Client
+
+	tokenMu     sync.Mutex
+	token       string
+	tokenExpiry time.Time
+}
+
+// NewOrchestratorClient builds a client for tenant's orchestrator settings.
+// A nil OrchestratorAuth uses the bearer scheme with OrchestratorAPIKey.
+func NewOrchestratorClient(tenant *models.Tenant) (*OrchestratorClient, error) {
+	auth := models.OrchestratorAuth{Scheme: models.ORCHESTRATOR_AUTH_BEARER}
+	if tenant.OrchestratorAuth != nil {
+		auth = *tenant.OrchestratorAuth
+		if auth.Scheme == "" {
+			auth.Scheme = models.ORCHESTRATOR_AUTH_BEARER
+		}
+	}
+
+	switch auth.Scheme {
+	case models.ORCHESTRATOR_AUTH_BEARER, models.ORCHESTRATOR_AUTH_NONE:
+	case models.ORCHESTRATOR_AUTH_HMAC:
+		if auth.HMACSecret == "" {
+			return nil, fmt.Errorf("hmac orchestrator auth requires hmac_secret")
+		}
+	case models.ORCHESTRATOR_AUTH_OAUTH2:
+		if auth.TokenURL == "" || auth.ClientID == "" {
+			return nil, fmt.Errorf("oauth2 orchestrator auth requires token_url and client_id")
+		}
+	default:
+		return nil, fmt.Errorf("unknown orchestrator auth scheme %q", auth.Scheme)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	tlsConfig, err := orchestratorTLSConfig(auth)
+	if err != nil {
+		return nil, err
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	return &OrchestratorClient{
+		baseURL: strings.TrimRight(tenant.OrchestratorURL, "/"),
+		apiKey:  tenant.OrchestratorAPIKey,
+		auth:    auth,
+		http:    &http.Client{Timeout: orchestratorTimeout, Transport: testmode.Transport(testmode.TARGET_ORCHESTRATOR, transport)},
+	}, nil
+}
+
+// orchestratorTLSConfig loads the client certificate for mutual TLS and an
+// optional CA bundle to verify the orchestrator with.
+func orchestratorTLSConfig(auth models.OrchestratorAuth) (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if auth.TLSCertFile != "" || auth.TLSKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(auth.TLSCertFile, auth.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load orchestrator client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	if auth.TLSCAFile != "" {
+		pem, err := os.ReadFile(auth.TLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read orchestrator CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", auth.TLSCAFile)
+		}
+		config.RootCAs = pool
+	}
+	return config, nil
+}
+
+// Post sends payload as JSON to path on the orchestrator and returns the
+// response status and body. A non-empty idempotencyKey is sent in the
+// Idempotency-Key header.
+func (c *OrchestratorClient) Post(ctx context.Context, path, idempotencyKey string, payload interface{}) (int, []byte, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to create orchestrator request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if idempotencyKey != "" {
+		req.Header.Set(IDEMPOTENCY_KEY_HEADER, idempotencyKey)
+	}
+	if err := c.authenticate(ctx, req, body); err != nil {
+		return 0, nil, err
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to call orchestrator: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	if resp.StatusCode == http.StatusUnauthorized && c.auth.Scheme == models.ORCHESTRATOR_AUTH_OAUTH2 {
+		// Force a fresh token on the next call in case it was revoked
+		c.tokenMu.Lock()
+		c.token = ""
+		c.tokenMu.Unlock()
+	}
+	return resp.StatusCode, respBody, nil
+}
+
+func (c *OrchestratorClient) authenticate(ctx context.Context, req *http.Request, body []byte) error {
+	switch c.auth.Scheme {
+	case models.ORCHESTRATOR_AUTH_BEARER:
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	case models.ORCHESTRATOR_AUTH_HMAC:
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		header := c.auth.HMACHeader
+		if header == "" {
+			header = defaultHMACHeader
+		}
+		req.Header.Set(hmacTimestampHeader, timestamp)
+		req.Header.Set(header, "sha256="+SignOrchestratorBody(c.auth.HMACSecret, timestamp, body))
+	case models.ORCHESTRATOR_AUTH_OAUTH2:
+		token, err := c.accessToken(ctx)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return nil
+}
+
+// OrchestratorRetryable reports whether an orchestrator call that failed
+// with err or status may succeed when sent again.
+func OrchestratorRetryable(status int, err error) bool {
+	if err != nil {
+		return true
+	}
+	return status == http.StatusTooManyRequests || status >= http.StatusInternalServerError
+}
+
+// SignOrchestratorBody returns the hex HMAC-SHA256 of "<timestamp>.<body>",
+// the value receivers recompute to verify hmac-authenticated calls.
+func SignOrchestratorBody(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// accessToken returns a cached client-credentials token, fetching a new one
+// shortly before the previous one expires.
+func (c *OrchestratorClient) accessToken(ctx context.Context) (string, error) {
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+	if c.token != "" && time.Now().Before(c.tokenExpiry) {
+		return c.token, nil
+	}
+
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(c.auth.Scopes) > 0 {
+		form.Set("scope", strings.Join(c.auth.Scopes, " "))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.auth.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("failed to create token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(c.auth.ClientID), url.QueryEscape(c.auth.ClientSecret))
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch orchestrator token: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token endpoint returned %s", resp.Status)
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("failed to decode token response: %w", err)
+	}
+	if token.AccessToken == "" {
+		return "", fmt.Errorf("token response has no access_token")
+	}
+
+	lifetime := time.Duration(token.ExpiresIn) * time.Second
+	if lifetime <= 0 {
+		lifetime = time.Hour
+	}
+	c.token = token.AccessToken
+	c.tokenExpiry = time.Now().Add(max(lifetime-oauthTokenExpirySlack, lifetime/2))
+	return c.token, nil
+}
+
+var orchestratorClients = struct {
+	sync.Mutex
+	byTenant map[string]*cachedOrchestratorClient
+}{byTenant: make(map[string]*cachedOrchestratorClient)}
+
+type cachedOrchestratorClient struct {
+	fingerprint string
+	client      *OrchestratorClient
+}
+
+// SharedOrchestratorClient returns the tenant's client, rebuilt when its
+// orchestrator settings change (e.g. after a credential reload) so cached
+// OAuth tokens and TLS connections are reused between calls.
+func SharedOrchestratorClient(tenant *models.Tenant) (*OrchestratorClient, error) {
+	settings, _ := json.Marshal(struct {
+		URL    string
+		APIKey string
+		Auth   *models.OrchestratorAuth
+	}{tenant.OrchestratorURL, tenant.OrchestratorAPIKey, tenant.OrchestratorAuth})
+	fingerprint := string(settings)
+
+	// Routed endpoints of a tenant each keep their own client
+	key := tenant.ID + " " + tenant.OrchestratorURL
+
+	orchestratorClients.Lock()
+	defer orchestratorClients.Unlock()
+	if cached, ok := orchestratorClients.byTenant[key]; ok && cached.fingerprint == fingerprint {
+		return cached.client, nil
+	}
+	client, err := NewOrchestratorClient(tenant)
+	if err != nil {
+		return nil, err
+	}
+	orchestratorClients.byTenant[key] = &cachedOrchestratorClient{fingerprint: fingerprint, client: client}
+	return client, nil
+}
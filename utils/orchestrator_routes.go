@@ -0,0 +1,121 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
+	"github.com/redis/go-redis/v9"
+)
+
+const orchestratorRoutesKeyPrefix = "perceptus:orchestrator_routes:"
+
+// LoadOrchestratorRoutes reads a JSON array of orchestrator routes.
+func LoadOrchestratorRoutes(path string) ([]models.OrchestratorRoute, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read orchestrator routes file: %w", err)
+	}
+	var routes []models.OrchestratorRoute
+	if err := json.Unmarshal(data, &routes); err != nil {
+		return nil, fmt.Errorf("failed to parse orchestrator routes file: %w", err)
+	}
+	if err := ValidateOrchestratorRoutes(routes); err != nil {
+		return nil, err
+	}
+	return routes, nil
+}
+
+// ValidateOrchestratorRoutes checks that each intention type is routed once
+// to at least one absolute http(s) URL.
+func ValidateOrchestratorRoutes(routes []models.OrchestratorRoute) error {
+	seen := make(map[string]bool, len(routes))
+	for _, route := range routes {
+		if route.IntentionType == "" {
+			return fmt.Errorf("orchestrator route without intention_type")
+		}
+		if seen[route.IntentionType] {
+			return fmt.Errorf("duplicate orchestrator route for %q", route.IntentionType)
+		}
+		seen[route.IntentionType] = true
+
+		if len(route.Endpoints) == 0 {
+			return fmt.Errorf("orchestrator route %q has no endpoints", route.IntentionType)
+		}
+		for _, endpoint := range route.Endpoints {
+			parsed, err := url.Parse(endpoint.URL)
+			if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+				return fmt.Errorf("orchestrator route %q: invalid url %q", route.IntentionType, endpoint.URL)
+			}
+		}
+	}
+	return nil
+}
+
+// RouteEndpoints returns the endpoints for intentionType: its own route,
+// else the "*" route, else none (the tenant's OrchestratorURL).
+func RouteEndpoints(routes []models.OrchestratorRoute, intentionType string) []models.OrchestratorEndpoint {
+	var fallback []models.OrchestratorEndpoint
+	for _, route := range routes {
+		switch route.IntentionType {
+		case intentionType:
+			return route.Endpoints
+		case models.ORCHESTRATOR_ROUTE_DEFAULT:
+			fallback = route.Endpoints
+		}
+	}
+	return fallback
+}
+
+// EndpointTenant returns a copy of tenant whose orchestrator settings are
+// those of endpoint, keeping the tenant's credentials where the endpoint has
+// none.
+func EndpointTenant(tenant *models.Tenant, endpoint models.OrchestratorEndpoint) *models.Tenant {
+	routed := *tenant
+	routed.OrchestratorURL = endpoint.URL
+	if endpoint.APIKey != "" {
+		routed.OrchestratorAPIKey = endpoint.APIKey
+	}
+	if endpoint.Auth != nil {
+		routed.OrchestratorAuth = endpoint.Auth
+	}
+	return &routed
+}
+
+// SaveOrchestratorRoutes stores a tenant's routing table, which takes
+// precedence over the one in its configuration.
+func SaveOrchestratorRoutes(ctx context.Context, rdb *redis.Client, tenantID string, routes []models.OrchestratorRoute) error {
+	data, err := marshalArtifact(ctx, tenantID, routes)
+	if err != nil {
+		return fmt.Errorf("failed to encode orchestrator routes: %w", err)
+	}
+	if err := rdb.Set(ctx, orchestratorRoutesKeyPrefix+tenantID, data, 0).Err(); err != nil {
+		return fmt.Errorf("failed to save orchestrator routes: %w", err)
+	}
+	return nil
+}
+
+// LoadStoredOrchestratorRoutes returns the tenant's stored routing table and
+// false when none is stored.
+func LoadStoredOrchestratorRoutes(ctx context.Context, rdb *redis.Client, tenantID string) ([]models.OrchestratorRoute, bool, error) {
+	data, err := rdb.Get(ctx, orchestratorRoutesKeyPrefix+tenantID).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	var routes []models.OrchestratorRoute
+	if err := unmarshalArtifact(ctx, data, &routes); err != nil {
+		return nil, false, fmt.Errorf("failed to decode orchestrator routes: %w", err)
+	}
+	return routes, true, nil
+}
+
+func DeleteOrchestratorRoutes(ctx context.Context, rdb *redis.Client, tenantID string) error {
+	return rdb.Del(ctx, orchestratorRoutesKeyPrefix+tenantID).Err()
+}
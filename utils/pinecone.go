@@ -3,87 +3,435 @@ package utils
 import (
 	"context"
 	"fmt"
-	"log"
-	"os"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/Perceptus-Labs/perceptus-go-sdk/testmode"
 	"github.com/pinecone-io/go-pinecone/v4/pinecone"
+	"google.golang.org/protobuf/types/known/structpb"
 )
 
-func GetPineconeIndex(perceptusID *string) (*pinecone.IndexConnection, error) {
-	pc, err := pinecone.NewClient(pinecone.NewClientParams{
-		ApiKey: os.Getenv("PINECONE_API_KEY"),
-	})
+// GetPineconeIndex connects to an index. Hosts given as http:// (Pinecone
+// Local, test doubles) are reached without TLS.
+func GetPineconeIndex(apiKey, host, namespace string) (*pinecone.IndexConnection, error) {
+	params := pinecone.NewClientParams{
+		ApiKey: apiKey,
+	}
+	if strings.HasPrefix(host, "http://") {
+		// The SDK switches REST calls to https; gRPC already honors the scheme
+		params.RestClient = &http.Client{Transport: plainHTTPTransport{}}
+	}
+	pc, err := pinecone.NewClient(params)
 	if err != nil {
-		log.Fatalf("Failed to create Client: %v", err)
+		return nil, fmt.Errorf("failed to create Pinecone client: %w", err)
 	}
-	idxConnection, err := pc.Index(pinecone.NewIndexConnParams{Host: os.Getenv("PINECONE_HOST"), Namespace: os.Getenv("PINECONE_NAMESPACE")})
+	idxConnection, err := pc.Index(pinecone.NewIndexConnParams{Host: host, Namespace: namespace})
 	if err != nil {
-		log.Fatalf("Failed to create IndexConnection for Host: %v", err)
+		return nil, fmt.Errorf("failed to create IndexConnection for host %s: %w", host, err)
 	}
 
 	return idxConnection, nil
 }
 
-func FetchResponseFromPinecone(ctx context.Context, index *pinecone.IndexConnection, promptText string) ([]string, error) {
+// plainHTTPTransport sends https requests as plain http.
+type plainHTTPTransport struct{}
+
+func (plainHTTPTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme = "http"
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// PineconeIndex is an index connection that follows credential rotation: the
+// pooled connection for the credentials returned by source is used, so
+// rotated credentials apply on the next call.
+type PineconeIndex struct {
+	mu     sync.Mutex
+	source func() (apiKey, host, namespace string)
+	conn   *pinecone.IndexConnection
+}
+
+func NewPineconeIndex(source func() (apiKey, host, namespace string)) (*PineconeIndex, error) {
+	p := &PineconeIndex{source: source}
+	if _, err := p.Conn(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Conn returns the connection for the current credentials.
+func (p *PineconeIndex) Conn() (*pinecone.IndexConnection, error) {
+	apiKey, host, namespace := p.source()
+	conn, err := SharedPineconeIndex(apiKey, host, namespace)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err != nil {
+		if p.conn != nil {
+			// Keep serving with the previous credentials
+			return p.conn, nil
+		}
+		return nil, err
+	}
+	p.conn = conn
+	return conn, nil
+}
+
+// WarmupPinecone issues a cheap stats request so the first query reuses an
+// established connection.
+func WarmupPinecone(ctx context.Context, index *pinecone.IndexConnection) error {
+	if _, err := index.DescribeIndexStats(ctx); err != nil {
+		return fmt.Errorf("error describing Pinecone index: %w", err)
+	}
+	return nil
+}
+
+// PineconeQuery is a text search over stored records, optionally restricted
+// by record metadata and re-ranked by age.
+type PineconeQuery struct {
+	Text string
+	TopK int
+	// SessionID and Types restrict matches to one session and to records of
+	// these types (e.g. environment_context, world_state)
+	SessionID string
+	Types     []string
+	// Subject restricts matches to the preferences of one user or robot
+	Subject string
+	// Site restricts matches to the shared memory of one site
+	Site string
+	// RobotID restricts matches to records of sessions with that robot_id
+	// metadata
+	RobotID string
+	// Since and Until bound the record timestamp (zero leaves a side open)
+	Since, Until time.Time
+	// RecencyHalfLife halves a match's score for every half-life of age,
+	// so recent scenes outrank older, slightly closer matches (0 disables)
+	RecencyHalfLife time.Duration
+}
+
+// PineconeQueryFromConfig reads the filters applied to intention analysis
+// lookups: PINECONE_FILTER_SESSION, PINECONE_FILTER_TYPES, PINECONE_MAX_AGE
+// and PINECONE_RECENCY_HALF_LIFE.
+func PineconeQueryFromConfig(cfg *Config, sessionID string, now time.Time) PineconeQuery {
+	query := PineconeQuery{
+		TopK:            cfg.PineconeTopK,
+		Types:           splitTerms(cfg.PineconeFilterTypes),
+		RecencyHalfLife: cfg.PineconeRecencyHalfLife,
+	}
+	if cfg.PineconeFilterSession {
+		query.SessionID = sessionID
+	}
+	if maxAge := cfg.PineconeMaxAge; maxAge > 0 {
+		query.Since = now.Add(-maxAge)
+	}
+	return query
+}
+
+// filter builds the Pinecone metadata filter, nil when unrestricted.
+func (q PineconeQuery) filter() *map[string]interface{} {
+	filter := map[string]interface{}{}
+	if q.SessionID != "" {
+		filter["session_id"] = map[string]interface{}{"$eq": q.SessionID}
+	}
+	if q.Subject != "" {
+		filter["subject"] = map[string]interface{}{"$eq": q.Subject}
+	}
+	if q.Site != "" {
+		filter["site"] = map[string]interface{}{"$eq": q.Site}
+	}
+	if q.RobotID != "" {
+		filter["meta_robot_id"] = map[string]interface{}{"$eq": q.RobotID}
+	}
+	if len(q.Types) > 0 {
+		types := make([]interface{}, len(q.Types))
+		for i, t := range q.Types {
+			types[i] = t
+		}
+		filter["type"] = map[string]interface{}{"$in": types}
+	}
+	timestamp := map[string]interface{}{}
+	if !q.Since.IsZero() {
+		timestamp["$gte"] = q.Since.Unix()
+	}
+	if !q.Until.IsZero() {
+		timestamp["$lte"] = q.Until.Unix()
+	}
+	if len(timestamp) > 0 {
+		filter["timestamp"] = timestamp
+	}
+	if len(filter) == 0 {
+		return nil
+	}
+	return &filter
+}
+
+// PineconeMatch is a search hit. Timestamp is zero for records stored
+// without one.
+type PineconeMatch struct {
+	ID        string
+	Text      string
+	Score     float64
+	Timestamp time.Time
+	// SessionID is the session the record was stored by, if any
+	SessionID string
+}
+
+func FetchResponseFromPinecone(ctx context.Context, index *pinecone.IndexConnection, query PineconeQuery) ([]string, error) {
 	// Use text-based search with integrated embeddings
 	// No need to manually vectorize the prompt text
-	ragResponse, err := QueryPinecone(ctx, promptText, index, 5)
+	matches, err := SearchPinecone(ctx, index, query)
 	if err != nil {
 		return nil, fmt.Errorf("error querying Pinecone index: %w", err)
 	}
-	return ragResponse, nil
+	texts := make([]string, 0, len(matches))
+	for _, match := range matches {
+		texts = append(texts, match.Text)
+	}
+	return texts, nil
 }
 
 func QueryPinecone(ctx context.Context, queryText string, index *pinecone.IndexConnection, topK int) ([]string, error) {
-	// Use text-based search with integrated embeddings
-	// Pinecone will automatically convert the query text to a vector
+	return FetchResponseFromPinecone(ctx, index, PineconeQuery{Text: queryText, TopK: topK})
+}
+
+// SearchPinecone runs a filtered text search. With a recency half-life it
+// fetches extra candidates and returns the TopK best after decaying scores
+// by age. The text is embedded by Pinecone, or by the configured Embedder
+// for indexes without integrated embeddings.
+func SearchPinecone(ctx context.Context, index *pinecone.IndexConnection, query PineconeQuery) ([]PineconeMatch, error) {
+	if err := testmode.Inject(ctx, testmode.TARGET_MEMORY); err != nil {
+		return nil, fmt.Errorf("error searching Pinecone index: %w", err)
+	}
+	if testmode.Malformed(testmode.TARGET_MEMORY) {
+		return []PineconeMatch{{Text: "\x00{\"overview\": \"trunc"}}, nil
+	}
+
+	topK := query.TopK
+	if topK < 1 {
+		topK = 5
+	}
+	candidates := topK
+	if query.RecencyHalfLife > 0 {
+		candidates = topK * 3
+	}
 
+	embedder, err := ConfiguredEmbedder()
+	if err != nil {
+		return nil, err
+	}
+	var hits []pineconeHit
+	if embedder != nil {
+		hits, err = queryPineconeVectors(ctx, index, embedder, query, candidates)
+	} else {
+		hits, err = searchPineconeRecords(ctx, index, query, candidates)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error searching Pinecone index: %w", err)
+	}
+
+	// Extract the matches
+	now := time.Now()
+	var matches []PineconeMatch
+	for _, hit := range hits {
+		if hit.fields == nil {
+			continue
+		}
+		match := PineconeMatch{ID: hit.id, Score: hit.score}
+		// Try to get chunk_text first, then fall back to other fields
+		if chunkText, ok := hit.fields["chunk_text"].(string); ok && chunkText != "" {
+			match.Text = chunkText
+		} else if category, ok := hit.fields["category"].(string); ok && category != "" {
+			match.Text = category
+		} else {
+			continue
+		}
+		if timestamp, ok := hit.fields["timestamp"].(float64); ok && timestamp > 0 {
+			match.Timestamp = time.Unix(int64(timestamp), 0)
+		}
+		match.SessionID, _ = hit.fields["session_id"].(string)
+		if query.RecencyHalfLife > 0 && !match.Timestamp.IsZero() {
+			age := now.Sub(match.Timestamp)
+			if age > 0 {
+				match.Score *= math.Pow(0.5, float64(age)/float64(query.RecencyHalfLife))
+			}
+		}
+		matches = append(matches, match)
+	}
+
+	if query.RecencyHalfLife > 0 {
+		sort.SliceStable(matches, func(i, j int) bool { return matches[i].Score > matches[j].Score })
+	}
+	if len(matches) > topK {
+		matches = matches[:topK]
+	}
+	return matches, nil
+}
+
+type pineconeHit struct {
+	id     string
+	score  float64
+	fields map[string]interface{}
+}
+
+// searchPineconeRecords searches an index with integrated embeddings; Pinecone
+// converts the query text to a vector itself.
+func searchPineconeRecords(ctx context.Context, index *pinecone.IndexConnection, query PineconeQuery, topK int) ([]pineconeHit, error) {
 	res, err := index.SearchRecords(ctx, &pinecone.SearchRecordsRequest{
 		Query: pinecone.SearchRecordsQuery{
-			TopK: int32(topK),
+			TopK:   int32(topK),
+			Filter: query.filter(),
 			Inputs: &map[string]interface{}{
-				"text": queryText,
+				"text": query.Text,
 			},
 		},
-		Fields: &[]string{"chunk_text", "category"},
+		Fields: &[]string{"chunk_text", "category", "timestamp", "session_id"},
 	})
 	if err != nil {
-		return nil, fmt.Errorf("error searching Pinecone index: %w", err)
+		return nil, err
 	}
-
-	// Extract the matches
-	var matches []string
+	hits := make([]pineconeHit, 0, len(res.Result.Hits))
 	for _, hit := range res.Result.Hits {
-		if hit.Fields != nil {
-			// Try to get chunk_text first, then fall back to other fields
-			if chunkText, ok := hit.Fields["chunk_text"].(string); ok && chunkText != "" {
-				matches = append(matches, chunkText)
-			} else if category, ok := hit.Fields["category"].(string); ok && category != "" {
-				matches = append(matches, category)
-			}
-		}
+		hits = append(hits, pineconeHit{id: hit.Id, score: float64(hit.Score), fields: hit.Fields})
 	}
+	return hits, nil
+}
 
-	return matches, nil
+// queryPineconeVectors embeds the query text and runs a vector query.
+func queryPineconeVectors(ctx context.Context, index *pinecone.IndexConnection, embedder Embedder, query PineconeQuery, topK int) ([]pineconeHit, error) {
+	vectors, err := embedder.Embed(ctx, EMBEDDING_INPUT_QUERY, []string{query.Text})
+	if err != nil {
+		return nil, err
+	}
+	request := &pinecone.QueryByVectorValuesRequest{
+		Vector:          vectors[0],
+		TopK:            uint32(topK),
+		IncludeMetadata: true,
+	}
+	if filter := query.filter(); filter != nil {
+		if request.MetadataFilter, err = structpb.NewStruct(*filter); err != nil {
+			return nil, fmt.Errorf("invalid metadata filter: %w", err)
+		}
+	}
+	res, err := index.QueryByVectorValues(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	hits := make([]pineconeHit, 0, len(res.Matches))
+	for _, match := range res.Matches {
+		if match.Vector == nil || match.Vector.Metadata == nil {
+			continue
+		}
+		hits = append(hits, pineconeHit{id: match.Vector.Id, score: float64(match.Score), fields: match.Vector.Metadata.AsMap()})
+	}
+	return hits, nil
 }
 
 func UpsertToPinecone(ctx context.Context, index *pinecone.IndexConnection, vectorID string, text string, metadata map[string]interface{}) error {
-	// Use integrated embeddings - just upsert the text directly
-	// Pinecone will automatically convert it to vectors using the hosted embedding model
+	return UpsertRecordsToPinecone(ctx, index, []*pinecone.IntegratedRecord{NewPineconeRecord(vectorID, text, metadata)})
+}
 
-	// Create the record with text field (should match your index's field_map configuration)
+// NewPineconeRecord builds a text record for an index with integrated
+// embeddings; Pinecone converts the text to vectors with its hosted model.
+// The text field should match the index's field_map configuration.
+func NewPineconeRecord(vectorID string, text string, metadata map[string]interface{}) *pinecone.IntegratedRecord {
 	record := pinecone.IntegratedRecord{
 		"_id":        vectorID,
 		"chunk_text": text,
 		"category":   fmt.Sprintf("%v", metadata),
 	}
+	for _, field := range pineconeFilterFields {
+		if value, ok := metadata[field]; ok {
+			record[field] = value
+		}
+	}
+	return &record
+}
+
+// pineconeFilterFields are stored as record fields so queries can filter on
+// them; the full metadata is kept in category.
+var pineconeFilterFields = []string{"session_id", "type", "timestamp", "subject", "site"}
+
+// UpsertRecordsToPinecone upserts a batch of text records in one request.
+// With a configured Embedder the texts are embedded first and stored as
+// vectors whose metadata holds the record fields.
+func UpsertRecordsToPinecone(ctx context.Context, index *pinecone.IndexConnection, records []*pinecone.IntegratedRecord) error {
+	if err := testmode.Inject(ctx, testmode.TARGET_MEMORY); err != nil {
+		return fmt.Errorf("failed to upsert text records to Pinecone: %w", err)
+	}
+	embedder, err := ConfiguredEmbedder()
+	if err != nil {
+		return err
+	}
+	if embedder != nil {
+		return upsertEmbeddedRecords(ctx, index, embedder, records)
+	}
+	if err := index.UpsertRecords(ctx, records); err != nil {
+		return fmt.Errorf("failed to upsert text records to Pinecone: %w", err)
+	}
+	return nil
+}
+
+func upsertEmbeddedRecords(ctx context.Context, index *pinecone.IndexConnection, embedder Embedder, records []*pinecone.IntegratedRecord) error {
+	texts := make([]string, len(records))
+	for i, record := range records {
+		texts[i], _ = (*record)["chunk_text"].(string)
+	}
+	values, err := embedder.Embed(ctx, EMBEDDING_INPUT_DOCUMENT, texts)
+	if err != nil {
+		return fmt.Errorf("failed to embed records for Pinecone: %w", err)
+	}
 
-	records := []*pinecone.IntegratedRecord{&record}
+	vectors := make([]*pinecone.Vector, len(records))
+	for i, record := range records {
+		fields := make(map[string]interface{}, len(*record))
+		for field, value := range *record {
+			if field != "_id" {
+				fields[field] = value
+			}
+		}
+		metadata, err := structpb.NewStruct(fields)
+		if err != nil {
+			return fmt.Errorf("invalid record metadata: %w", err)
+		}
+		id, _ := (*record)["_id"].(string)
+		vectors[i] = &pinecone.Vector{Id: id, Values: &values[i], Metadata: metadata}
+	}
+	if _, err := index.UpsertVectors(ctx, vectors); err != nil {
+		return fmt.Errorf("failed to upsert vectors to Pinecone: %w", err)
+	}
+	return nil
+}
 
-	err := index.UpsertRecords(ctx, records)
+// DeleteFromPineconeByFilter removes the records matching a metadata
+// filter. Only pod-based indexes support it; serverless indexes fail.
+func DeleteFromPineconeByFilter(ctx context.Context, index *pinecone.IndexConnection, filter map[string]interface{}) error {
+	metadataFilter, err := structpb.NewStruct(filter)
 	if err != nil {
-		return fmt.Errorf("failed to upsert text record to Pinecone: %w", err)
+		return fmt.Errorf("invalid Pinecone filter: %w", err)
 	}
+	if err := testmode.Inject(ctx, testmode.TARGET_MEMORY); err != nil {
+		return fmt.Errorf("failed to delete records from Pinecone: %w", err)
+	}
+	if err := index.DeleteVectorsByFilter(ctx, metadataFilter); err != nil {
+		return fmt.Errorf("failed to delete records from Pinecone: %w", err)
+	}
+	return nil
+}
 
+// DeleteFromPinecone removes records by ID.
+func DeleteFromPinecone(ctx context.Context, index *pinecone.IndexConnection, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	if err := testmode.Inject(ctx, testmode.TARGET_MEMORY); err != nil {
+		return fmt.Errorf("failed to delete records from Pinecone: %w", err)
+	}
+	if err := index.DeleteVectorsById(ctx, ids); err != nil {
+		return fmt.Errorf("failed to delete records from Pinecone: %w", err)
+	}
 	return nil
 }
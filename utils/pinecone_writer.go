@@ -0,0 +1,184 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/pinecone-io/go-pinecone/v4/pinecone"
+	"go.uber.org/zap"
+)
+
+// Integrated-embedding upserts accept at most 96 records per request
+const maxPineconeBatchSize = 96
+
+var (
+	ErrPineconeQueueFull    = errors.New("pinecone write queue full")
+	ErrPineconeWriterClosed = errors.New("pinecone writer closed")
+)
+
+// PineconeWrite is a text record queued for an index. OnFailure is called
+// when the record is dropped, either because the queue was full or because
+// every retry failed.
+type PineconeWrite struct {
+	Index     *PineconeIndex
+	ID        string
+	Text      string
+	Metadata  map[string]interface{}
+	OnFailure func(error)
+}
+
+// PineconeWriter batches record upserts off the request path. Writes are
+// grouped per index and flushed when a group reaches the batch size or the
+// flush interval passes; failed batches are retried with backoff.
+type PineconeWriter struct {
+	queue         chan PineconeWrite
+	batchSize     int
+	flushInterval time.Duration
+	retries       int
+
+	// mu guards closed, so no write is sent on the closed queue
+	mu        sync.Mutex
+	closed    bool
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+// NewPineconeWriterFromConfig reads PINECONE_WRITE_QUEUE_SIZE,
+// PINECONE_WRITE_BATCH_SIZE, PINECONE_WRITE_FLUSH_INTERVAL and
+// PINECONE_WRITE_RETRIES.
+func NewPineconeWriterFromConfig(cfg *Config) *PineconeWriter {
+	return NewPineconeWriter(
+		cfg.PineconeWriteQueueSize,
+		cfg.PineconeWriteBatchSize,
+		cfg.PineconeWriteFlushInterval,
+		cfg.PineconeWriteRetries,
+	)
+}
+
+func NewPineconeWriter(queueSize, batchSize int, flushInterval time.Duration, retries int) *PineconeWriter {
+	if batchSize <= 0 || batchSize > maxPineconeBatchSize {
+		batchSize = maxPineconeBatchSize
+	}
+	if flushInterval <= 0 {
+		flushInterval = 2 * time.Second
+	}
+	w := &PineconeWriter{
+		queue:         make(chan PineconeWrite, max(queueSize, 1)),
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+		retries:       max(retries, 0),
+		done:          make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// Enqueue queues a write without blocking. Writes enqueued after Close
+// fail with ErrPineconeWriterClosed.
+func (w *PineconeWriter) Enqueue(write PineconeWrite) {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		write.fail(ErrPineconeWriterClosed)
+		return
+	}
+	select {
+	case w.queue <- write:
+		w.mu.Unlock()
+	default:
+		w.mu.Unlock()
+		write.fail(ErrPineconeQueueFull)
+	}
+}
+
+// Close flushes the queued writes and stops the writer.
+func (w *PineconeWriter) Close() {
+	w.closeOnce.Do(func() {
+		w.mu.Lock()
+		w.closed = true
+		close(w.queue)
+		w.mu.Unlock()
+		<-w.done
+	})
+}
+
+func (w *PineconeWriter) run() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.flushInterval)
+	defer ticker.Stop()
+
+	pending := make(map[*PineconeIndex][]PineconeWrite)
+	for {
+		select {
+		case write, ok := <-w.queue:
+			if !ok {
+				for index, writes := range pending {
+					w.flush(index, writes)
+				}
+				return
+			}
+			pending[write.Index] = append(pending[write.Index], write)
+			if len(pending[write.Index]) >= w.batchSize {
+				w.flush(write.Index, pending[write.Index])
+				delete(pending, write.Index)
+			}
+		case <-ticker.C:
+			for index, writes := range pending {
+				w.flush(index, writes)
+			}
+			clear(pending)
+		}
+	}
+}
+
+func (w *PineconeWriter) flush(index *PineconeIndex, writes []PineconeWrite) {
+	for start := 0; start < len(writes); start += w.batchSize {
+		batch := writes[start:min(start+w.batchSize, len(writes))]
+
+		records := make([]*pinecone.IntegratedRecord, len(batch))
+		for i, write := range batch {
+			records[i] = NewPineconeRecord(write.ID, write.Text, write.Metadata)
+		}
+
+		var err error
+		backoff := 500 * time.Millisecond
+		for attempt := 0; attempt <= w.retries; attempt++ {
+			if attempt > 0 {
+				time.Sleep(backoff)
+				backoff *= 2
+			}
+			err = w.upsert(index, records)
+			if err == nil {
+				break
+			}
+			zap.L().Warn("Pinecone batch upsert failed",
+				zap.Int("records", len(records)), zap.Int("attempt", attempt+1), zap.Error(err))
+		}
+		if err != nil {
+			for _, write := range batch {
+				write.fail(err)
+			}
+		}
+	}
+}
+
+func (w *PineconeWriter) upsert(index *PineconeIndex, records []*pinecone.IntegratedRecord) error {
+	conn, err := index.Conn()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return UpsertRecordsToPinecone(ctx, conn, records)
+}
+
+func (write PineconeWrite) fail(err error) {
+	if write.OnFailure != nil {
+		write.OnFailure(err)
+		return
+	}
+	zap.L().Error("Dropped Pinecone write", zap.String("vector_id", write.ID), zap.Error(err))
+}
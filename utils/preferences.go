@@ -0,0 +1,248 @@
+package utils
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
+	"github.com/redis/go-redis/v9"
+)
+
+// PREFERENCE_RECORD_TYPE is the memory store type of preference records,
+// kept in their own namespace apart from scene context.
+const PREFERENCE_RECORD_TYPE = "preference"
+
+const preferencesKeyPrefix = "perceptus:preferences:"
+
+// PreferenceSubject names whose preferences a session learns: the user when
+// the client identified one, else the robot, else "" (preference memory off).
+func PreferenceSubject(userID, robotID string) string {
+	switch {
+	case userID != "":
+		return "user:" + userID
+	case robotID != "":
+		return "robot:" + robotID
+	}
+	return ""
+}
+
+// PreferenceID derives a stable ID from the subject and the normalized fact,
+// so learning the same fact twice updates one record.
+func PreferenceID(subject, fact string) string {
+	sum := sha256.Sum256([]byte(subject + "\x00" + strings.ToLower(strings.Join(strings.Fields(fact), " "))))
+	return "pref-" + hex.EncodeToString(sum[:8])
+}
+
+// PreferenceNamespace is the Pinecone namespace of the tenant's preferences,
+// PREFERENCE_NAMESPACE or the tenant's namespace with a "-preferences"
+// suffix.
+func PreferenceNamespace(tenant *models.Tenant) string {
+	if namespace := CurrentConfig().PreferenceNamespace; namespace != "" {
+		return namespace
+	}
+	if tenant.PineconeNamespace == "" {
+		return "preferences"
+	}
+	return tenant.PineconeNamespace + "-preferences"
+}
+
+func preferencesKey(tenantID, subject string) string {
+	return preferencesKeyPrefix + tenantID + ":" + subject
+}
+
+// LoadPreferences returns a subject's preferences, newest first.
+func LoadPreferences(ctx context.Context, rdb *redis.Client, tenantID, subject string) ([]models.Preference, error) {
+	data, err := rdb.Get(ctx, preferencesKey(tenantID, subject)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load preferences: %w", err)
+	}
+	var preferences []models.Preference
+	if err := unmarshalArtifact(ctx, data, &preferences); err != nil {
+		return nil, fmt.Errorf("failed to decode preferences: %w", err)
+	}
+	return preferences, nil
+}
+
+// SavePreferences adds learned preferences, replacing records with the same
+// ID and those listed in replaced, and keeps the newest limit. It returns
+// the IDs dropped from the store, for removal from the memory index.
+func SavePreferences(ctx context.Context, rdb *redis.Client, tenantID, subject string, learned []models.Preference, replaced []string, limit int) ([]string, error) {
+	return updatePreferences(ctx, rdb, tenantID, subject, func(preferences []models.Preference) []models.Preference {
+		drop := make(map[string]bool, len(learned)+len(replaced))
+		for _, id := range replaced {
+			drop[id] = true
+		}
+		for _, preference := range learned {
+			drop[preference.ID] = true
+		}
+		kept := append([]models.Preference(nil), learned...)
+		for _, preference := range preferences {
+			if !drop[preference.ID] {
+				kept = append(kept, preference)
+			}
+		}
+		sort.SliceStable(kept, func(i, j int) bool { return kept[i].LearnedAt.After(kept[j].LearnedAt) })
+		if limit > 0 && len(kept) > limit {
+			kept = kept[:limit]
+		}
+		return kept
+	})
+}
+
+// DeletePreference removes one preference and reports whether it existed.
+func DeletePreference(ctx context.Context, rdb *redis.Client, tenantID, subject, id string) (bool, error) {
+	removed, err := updatePreferences(ctx, rdb, tenantID, subject, func(preferences []models.Preference) []models.Preference {
+		kept := make([]models.Preference, 0, len(preferences))
+		for _, preference := range preferences {
+			if preference.ID != id {
+				kept = append(kept, preference)
+			}
+		}
+		return kept
+	})
+	return len(removed) > 0, err
+}
+
+// updatePreferences applies update in an optimistic transaction, retried
+// when another session changed the subject's preferences meanwhile, and
+// returns the IDs it removed.
+func updatePreferences(ctx context.Context, rdb *redis.Client, tenantID, subject string, update func([]models.Preference) []models.Preference) ([]string, error) {
+	key := preferencesKey(tenantID, subject)
+	var removed []string
+	txn := func(tx *redis.Tx) error {
+		removed = nil
+		var preferences []models.Preference
+		data, err := tx.Get(ctx, key).Bytes()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return err
+		}
+		if err == nil {
+			if err := unmarshalArtifact(ctx, data, &preferences); err != nil {
+				return fmt.Errorf("failed to decode preferences: %w", err)
+			}
+		}
+
+		updated := update(preferences)
+		kept := make(map[string]bool, len(updated))
+		for _, preference := range updated {
+			kept[preference.ID] = true
+		}
+		for _, preference := range preferences {
+			if !kept[preference.ID] {
+				removed = append(removed, preference.ID)
+			}
+		}
+
+		stored, err := marshalArtifact(ctx, tenantID, updated)
+		if err != nil {
+			return fmt.Errorf("failed to encode preferences: %w", err)
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			if len(updated) == 0 {
+				pipe.Del(ctx, key)
+			} else {
+				pipe.Set(ctx, key, stored, 0)
+			}
+			return nil
+		})
+		return err
+	}
+
+	for attempt := 0; attempt < 5; attempt++ {
+		err := rdb.Watch(ctx, txn, key)
+		if errors.Is(err, redis.TxFailedErr) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to save preferences: %w", err)
+		}
+		return removed, nil
+	}
+	return nil, fmt.Errorf("failed to save preferences: too many concurrent updates")
+}
+
+// ExtractedPreference is a fact found in an utterance. Replaces lists the
+// IDs of known preferences it supersedes ("the charger moved to the garage").
+type ExtractedPreference struct {
+	Fact       string   `json:"fact"`
+	Category   string   `json:"category"`
+	Confidence float64  `json:"confidence"`
+	Replaces   []string `json:"replaces"`
+}
+
+// ExtractPreferences asks the model for durable facts about the user or
+// their home in utterance. Known preferences are listed so they are not
+// extracted again and can be superseded.
+func (c *OpenAIClient) ExtractPreferences(ctx context.Context, utterance string, known []models.Preference) ([]ExtractedPreference, error) {
+	var knownList strings.Builder
+	for _, preference := range known {
+		fmt.Fprintf(&knownList, "- [%s] %s\n", preference.ID, preference.Fact)
+	}
+	if knownList.Len() == 0 {
+		knownList.WriteString("(none yet)\n")
+	}
+
+	prompt := fmt.Sprintf(`You maintain the long-term memory of a household robot. Extract durable facts about the user, their preferences, habits and their home from the utterance below: things that will still be true in future conversations, e.g. "likes their coffee black" or "the charger lives in the hallway closet".
+
+Ignore one-off requests, the current task, questions and small talk. Do not repeat known facts. When a fact changes a known one, set "replaces" to the known fact's ID. State each fact as a short sentence in third person. Return an empty list when there is nothing durable.
+
+Known facts:
+%s
+Utterance: "%s"`, knownList.String(), utterance)
+
+	message, err := c.completeTask(ctx, MODEL_TASK_SUMMARIZATION, map[string]interface{}{
+		"messages": []GPTMessage{
+			{Role: "user", Content: prompt},
+		},
+		"response_format": preferencesResponseFormat,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var response struct {
+		Preferences []ExtractedPreference `json:"preferences"`
+	}
+	if err := json.Unmarshal([]byte(message.Content), &response); err != nil {
+		return nil, fmt.Errorf("failed to parse extracted preferences: %w", err)
+	}
+	return response.Preferences, nil
+}
+
+var preferencesResponseFormat = map[string]interface{}{
+	"type": "json_schema",
+	"json_schema": map[string]interface{}{
+		"name":   "preferences",
+		"strict": true,
+		"schema": map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"preferences": map[string]interface{}{
+					"type": "array",
+					"items": map[string]interface{}{
+						"type": "object",
+						"properties": map[string]interface{}{
+							"fact":       map[string]interface{}{"type": "string"},
+							"category":   map[string]interface{}{"type": "string", "enum": []string{"food_drink", "location", "routine", "person", "comfort", "other"}},
+							"confidence": map[string]interface{}{"type": "number"},
+							"replaces":   map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+						},
+						"required":             []string{"fact", "category", "confidence", "replaces"},
+						"additionalProperties": false,
+					},
+				},
+			},
+			"required":             []string{"preferences"},
+			"additionalProperties": false,
+		},
+	},
+}
@@ -0,0 +1,163 @@
+package utils
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/testmode"
+	"github.com/pinecone-io/go-pinecone/v4/pinecone"
+	"go.uber.org/zap"
+)
+
+// Provider connections are shared by all sessions and API requests instead
+// of being opened per session. OpenAI clients send through one HTTP client
+// that keeps connections to the API open; Pinecone index connections (a gRPC
+// channel each) are opened on first use per API key, host and namespace and
+// reused until they fail a health check or go unused.
+
+// pooledConnectionCloseDelay is how long a connection dropped from the pool
+// stays open for the calls still using it.
+const pooledConnectionCloseDelay = time.Minute
+
+var (
+	openAIHTTPOnce   sync.Once
+	openAIHTTPClient *http.Client
+)
+
+// sharedOpenAIHTTPClient returns the HTTP client of all OpenAI clients. It
+// keeps up to OPENAI_MAX_IDLE_CONNS idle connections to the API rather than
+// net/http's default of two per host, so bursts of sessions reuse them.
+func sharedOpenAIHTTPClient() *http.Client {
+	openAIHTTPOnce.Do(func() {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		idle := CurrentConfig().OpenAIMaxIdleConns
+		transport.MaxIdleConns = idle
+		transport.MaxIdleConnsPerHost = idle
+		// Requests are bounded by the retry policy's per-attempt timeouts
+		openAIHTTPClient = &http.Client{Transport: testmode.Transport(testmode.TARGET_LLM, transport)}
+	})
+	return openAIHTTPClient
+}
+
+type pineconePoolKey struct {
+	apiKey, host, namespace string
+}
+
+type pooledPineconeIndex struct {
+	// ready is closed once conn or err is set
+	ready    chan struct{}
+	conn     *pinecone.IndexConnection
+	err      error
+	lastUsed time.Time
+}
+
+type pineconePool struct {
+	mu      sync.Mutex
+	indexes map[pineconePoolKey]*pooledPineconeIndex
+	checks  sync.Once
+}
+
+var sharedPinecone = &pineconePool{indexes: make(map[pineconePoolKey]*pooledPineconeIndex)}
+
+// SharedPineconeIndex returns the pooled connection to an index, opening it
+// on first use. Concurrent callers wait for the same connection; failures
+// are not cached, so the next call tries again.
+func SharedPineconeIndex(apiKey, host, namespace string) (*pinecone.IndexConnection, error) {
+	return sharedPinecone.get(pineconePoolKey{apiKey: apiKey, host: host, namespace: namespace})
+}
+
+func (p *pineconePool) get(key pineconePoolKey) (*pinecone.IndexConnection, error) {
+	p.mu.Lock()
+	entry, ok := p.indexes[key]
+	if !ok {
+		entry = &pooledPineconeIndex{ready: make(chan struct{})}
+		p.indexes[key] = entry
+	}
+	entry.lastUsed = time.Now()
+	p.mu.Unlock()
+
+	if ok {
+		<-entry.ready
+		return entry.conn, entry.err
+	}
+
+	entry.conn, entry.err = GetPineconeIndex(key.apiKey, key.host, key.namespace)
+	close(entry.ready)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if entry.err != nil {
+		if p.indexes[key] == entry {
+			delete(p.indexes, key)
+		}
+		return nil, entry.err
+	}
+	providerPoolConnections.WithLabelValues("pinecone").Set(float64(len(p.indexes)))
+	p.checks.Do(func() {
+		if interval := CurrentConfig().ProviderHealthCheckInterval; interval > 0 {
+			go p.runHealthChecks(interval)
+		}
+	})
+	return entry.conn, nil
+}
+
+// runHealthChecks drops connections unused for PROVIDER_IDLE_TIMEOUT and
+// those whose index stopped answering, so the next call reconnects.
+func (p *pineconePool) runHealthChecks(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		idleTimeout := CurrentConfig().ProviderIdleTimeout
+
+		p.mu.Lock()
+		entries := make(map[pineconePoolKey]*pooledPineconeIndex, len(p.indexes))
+		for key, entry := range p.indexes {
+			select {
+			case <-entry.ready:
+			default:
+				// Still connecting
+				continue
+			}
+			if idleTimeout > 0 && time.Since(entry.lastUsed) > idleTimeout {
+				p.drop(key, entry, "idle")
+				continue
+			}
+			entries[key] = entry
+		}
+		p.mu.Unlock()
+
+		for key, entry := range entries {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			err := WarmupPinecone(ctx, entry.conn)
+			cancel()
+			if err == nil {
+				providerHealthChecks.WithLabelValues("pinecone", "ok").Inc()
+				continue
+			}
+			providerHealthChecks.WithLabelValues("pinecone", "failed").Inc()
+			zap.L().Warn("Pooled Pinecone connection failed its health check, reconnecting on next use",
+				zap.String("host", key.host), zap.String("namespace", key.namespace), zap.Error(err))
+			p.mu.Lock()
+			if p.indexes[key] == entry {
+				p.drop(key, entry, "unhealthy")
+			}
+			p.mu.Unlock()
+		}
+	}
+}
+
+// drop removes a connection from the pool and closes it once the calls
+// still using it had time to finish. p.mu must be held.
+func (p *pineconePool) drop(key pineconePoolKey, entry *pooledPineconeIndex, reason string) {
+	delete(p.indexes, key)
+	providerPoolConnections.WithLabelValues("pinecone").Set(float64(len(p.indexes)))
+	zap.L().Debug("Dropped pooled Pinecone connection",
+		zap.String("host", key.host), zap.String("namespace", key.namespace), zap.String("reason", reason))
+	time.AfterFunc(pooledConnectionCloseDelay, func() {
+		if err := entry.conn.Close(); err != nil {
+			zap.L().Debug("Failed to close Pinecone connection", zap.Error(err))
+		}
+	})
+}
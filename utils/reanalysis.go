@@ -0,0 +1,183 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// confidenceDiffThreshold is the minimum confidence change reported as a diff.
+const confidenceDiffThreshold = 0.1
+
+// RunBatchReanalysis re-runs archived analyses of a tenant (of every tenant
+// when tenantID is empty) through the Batch API, stores the new results next
+// to the originals and returns a diff report.
+func RunBatchReanalysis(ctx context.Context, client *OpenAIClient, rdb *redis.Client, tenantID string, since time.Time, limit int, pollInterval time.Duration) (*models.ReanalysisReport, error) {
+	report := &models.ReanalysisReport{StartedAt: time.Now()}
+
+	records, err := LoadArchivedAnalyses(ctx, rdb, tenantID, since, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[string]models.AnalysisRecord, len(records))
+	var requests []BatchRequest
+	for _, record := range records {
+		var body map[string]interface{}
+		switch record.Kind {
+		case models.ANALYSIS_KIND_INTENTION:
+			body = IntentionRequestBody(record.Transcript, record.EnvironmentContext, record.Preferences, record.Conversation)
+		case models.ANALYSIS_KIND_VISION:
+			if record.ImageData == "" {
+				continue
+			}
+			body = ImageContextRequestBody(record.ImageData)
+		default:
+			continue
+		}
+		byID[record.ID] = record
+		requests = append(requests, BatchRequest{
+			CustomID: record.ID,
+			Method:   "POST",
+			URL:      "/v1/chat/completions",
+			Body:     body,
+		})
+	}
+
+	if len(requests) == 0 {
+		report.Status = "empty"
+		report.EndedAt = time.Now()
+		return report, nil
+	}
+
+	batch, err := client.SubmitBatch(ctx, requests)
+	if err != nil {
+		return nil, err
+	}
+	report.BatchID = batch.ID
+	report.Submitted = len(requests)
+	zap.L().Info("Submitted reanalysis batch", zap.String("batch_id", batch.ID), zap.Int("requests", len(requests)))
+
+	batch, err = client.WaitForBatch(ctx, batch.ID, pollInterval)
+	if err != nil {
+		return report, err
+	}
+	report.Status = batch.Status
+	report.Failed = batch.RequestCounts.Failed
+	if batch.OutputFileID == "" {
+		report.EndedAt = time.Now()
+		return report, fmt.Errorf("batch %s finished with status %s and no output", batch.ID, batch.Status)
+	}
+
+	contents, err := client.DownloadBatchResults(ctx, batch.OutputFileID)
+	if err != nil {
+		return report, err
+	}
+
+	results := make(map[string]json.RawMessage, len(contents))
+	for id, content := range contents {
+		record, ok := byID[id]
+		if !ok {
+			continue
+		}
+
+		diff, result, err := diffAnalysis(record, content)
+		if err != nil {
+			zap.L().Warn("Failed to reconcile reanalysis", zap.String("record_id", id), zap.Error(err))
+			report.Failed++
+			continue
+		}
+		results[id] = result
+		report.Completed++
+		if diff == nil {
+			report.Unchanged++
+		} else {
+			report.Diffs = append(report.Diffs, *diff)
+		}
+	}
+	report.EndedAt = time.Now()
+
+	if err := StoreReanalysis(ctx, rdb, report, results); err != nil {
+		return report, err
+	}
+	return report, nil
+}
+
+func diffAnalysis(record models.AnalysisRecord, content string) (*models.AnalysisDiff, json.RawMessage, error) {
+	changes := make(map[string][2]any)
+
+	switch record.Kind {
+	case models.ANALYSIS_KIND_INTENTION:
+		var original models.IntentionResult
+		if err := json.Unmarshal(record.Result, &original); err != nil {
+			return nil, nil, fmt.Errorf("failed to decode original intention: %w", err)
+		}
+		updated := ParseIntentionContent(content)
+		if original.HasClearIntention != updated.HasClearIntention {
+			changes["has_clear_intention"] = [2]any{original.HasClearIntention, updated.HasClearIntention}
+		}
+		if original.IntentionType != updated.IntentionType {
+			changes["intention_type"] = [2]any{original.IntentionType, updated.IntentionType}
+		}
+		if math.Abs(original.Confidence-updated.Confidence) >= confidenceDiffThreshold {
+			changes["confidence"] = [2]any{original.Confidence, updated.Confidence}
+		}
+		if string(mustJSON(original.Slots)) != string(mustJSON(updated.Slots)) {
+			changes["slots"] = [2]any{original.Slots, updated.Slots}
+		}
+		result, err := json.Marshal(updated)
+		if err != nil {
+			return nil, nil, err
+		}
+		return newAnalysisDiff(record, changes), result, nil
+
+	case models.ANALYSIS_KIND_VISION:
+		var original models.EnvironmentContext
+		if err := json.Unmarshal(record.Result, &original); err != nil {
+			return nil, nil, fmt.Errorf("failed to decode original context: %w", err)
+		}
+		updated, err := ParseEnvironmentContent(content)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !sameElements(original.KeyElements, updated.KeyElements) {
+			changes["key_elements"] = [2]any{original.KeyElements, updated.KeyElements}
+		}
+		if !sameElements(original.Activities, updated.Activities) {
+			changes["activities"] = [2]any{original.Activities, updated.Activities}
+		}
+		result, err := json.Marshal(updated)
+		if err != nil {
+			return nil, nil, err
+		}
+		return newAnalysisDiff(record, changes), result, nil
+	}
+
+	return nil, nil, fmt.Errorf("unknown analysis kind %q", record.Kind)
+}
+
+func newAnalysisDiff(record models.AnalysisRecord, changes map[string][2]any) *models.AnalysisDiff {
+	if len(changes) == 0 {
+		return nil
+	}
+	return &models.AnalysisDiff{
+		RecordID:  record.ID,
+		SessionID: record.SessionID,
+		Kind:      record.Kind,
+		Changes:   changes,
+	}
+}
+
+func sameElements(a, b []string) bool {
+	a, b = slices.Clone(a), slices.Clone(b)
+	slices.Sort(a)
+	slices.Sort(b)
+	return slices.Equal(a, b)
+}
@@ -0,0 +1,498 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	recordingKeyPrefix        = "perceptus:recording:"
+	sessionRecordingKeyPrefix = "perceptus:recordings:session:"
+	// recordingIndexKey orders every recording by its end, for the purge
+	recordingIndexKey = "perceptus:recordings"
+)
+
+// ErrRecordingNotFound is returned for a recording that was never saved or
+// has been purged.
+var ErrRecordingNotFound = errors.New("recording not found")
+
+// RecordingConfig is the RECORDING_* configuration.
+type RecordingConfig struct {
+	Dir string
+	// FPS is the frame rate of the recordings; frames are repeated to fill
+	// the time between the frames the robot sends
+	FPS         int
+	MaxDuration time.Duration
+	// Retention is how long recordings are kept, 0 keeps them
+	Retention time.Duration
+}
+
+// RecordingConfigFromConfig reads RECORDING_ENABLED, RECORDING_DIR,
+// RECORDING_FPS, RECORDING_MAX_DURATION and RECORDING_RETENTION; a nil
+// config means sessions are not recorded.
+func RecordingConfigFromConfig(cfg *Config) (*RecordingConfig, error) {
+	if !cfg.RecordingEnabled {
+		return nil, nil
+	}
+	config := &RecordingConfig{
+		Dir:         cfg.RecordingDir,
+		FPS:         cfg.RecordingFPS,
+		MaxDuration: cfg.RecordingMaxDuration,
+		Retention:   cfg.RecordingRetention,
+	}
+	if config.Dir == "" {
+		config.Dir = "recordings"
+	}
+	if config.FPS < 1 || config.FPS > 30 {
+		return nil, fmt.Errorf("RECORDING_FPS must be between 1 and 30")
+	}
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		return nil, fmt.Errorf("recording requires ffmpeg: %w", err)
+	}
+	return config, nil
+}
+
+// recordingPath is where a recording is kept, validated like frame keys so
+// IDs taken from a URL cannot escape the tenant's directory.
+func recordingPath(dir, tenantID, recordingID string) (string, error) {
+	key, err := frameKey(tenantID, recordingID)
+	if err != nil {
+		return "", ErrRecordingNotFound
+	}
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, filepath.FromSlash(key)+".mp4"), nil
+}
+
+// VideoRecorder encodes frames into an H.264 MP4 file with a long-running
+// ffmpeg process. Frames are written at a constant frame rate: each frame
+// is repeated until the next one arrives, so the recording plays back in
+// real time however irregularly the robot sends frames. The first frame
+// sets the size of the recording; later frames are scaled to it.
+type VideoRecorder struct {
+	path string
+	fps  int
+
+	mu            sync.Mutex
+	cmd           *exec.Cmd
+	stdin         io.WriteCloser
+	stderr        *stderrTail
+	started       time.Time
+	last          time.Time
+	lastFrame     []byte
+	written       int
+	frames        int
+	width, height int
+	err           error
+}
+
+// NewVideoRecorder records to path; ffmpeg starts with the first frame.
+func NewVideoRecorder(path string, fps int) *VideoRecorder {
+	return &VideoRecorder{path: path, fps: fps}
+}
+
+// WriteFrame adds a JPEG or PNG frame (raw base64 or data URL) received at
+// the given time. After an error the recorder ignores further frames.
+func (r *VideoRecorder) WriteFrame(imageData string, at time.Time) error {
+	raw, err := decodeDataURL(imageData)
+	if err != nil {
+		return fmt.Errorf("failed to decode frame: %w", err)
+	}
+	frame, err := recordingFrame(raw)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return r.err
+	}
+	if r.cmd == nil {
+		config, _, err := image.DecodeConfig(bytes.NewReader(frame))
+		if err != nil {
+			return fmt.Errorf("failed to read frame size: %w", err)
+		}
+		// H.264 wants even dimensions
+		r.width, r.height = config.Width&^1, config.Height&^1
+		if err := r.start(); err != nil {
+			r.err = err
+			return err
+		}
+		r.started = at
+	}
+	if at.Before(r.last) {
+		at = r.last
+	}
+
+	// Hold the previous frame until this one's slot, then show this one.
+	// Frames arriving faster than the frame rate share a slot; the newest
+	// is shown from the next one.
+	slot := int(at.Sub(r.started).Seconds()*float64(r.fps)) + 1
+	if r.lastFrame != nil && slot <= r.written {
+		r.lastFrame, r.last = frame, at
+		return nil
+	}
+	for r.lastFrame != nil && r.written < slot-1 {
+		if err := r.write(r.lastFrame); err != nil {
+			return err
+		}
+	}
+	if err := r.write(frame); err != nil {
+		return err
+	}
+	r.lastFrame, r.last = frame, at
+	r.frames++
+	return nil
+}
+
+// recordingFrame returns the frame as a JPEG, the input format of ffmpeg's
+// pipe; PNG frames are re-encoded.
+func recordingFrame(raw []byte) ([]byte, error) {
+	if bytes.HasPrefix(raw, []byte{0xff, 0xd8}) {
+		return raw, nil
+	}
+	img, _, err := decodeFrame(raw)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 90}); err != nil {
+		return nil, fmt.Errorf("failed to encode frame: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// start launches ffmpeg. Called with r.mu held.
+func (r *VideoRecorder) start() error {
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o750); err != nil {
+		return fmt.Errorf("failed to create recording directory: %w", err)
+	}
+	cmd := exec.Command("ffmpeg",
+		"-hide_banner", "-loglevel", "error",
+		"-f", "image2pipe", "-c:v", "mjpeg", "-framerate", strconv.Itoa(r.fps), "-i", "pipe:0",
+		"-an", "-vf", fmt.Sprintf("scale=%d:%d,format=yuv420p", r.width, r.height),
+		"-c:v", "libx264", "-preset", "veryfast", "-crf", "28",
+		"-movflags", "+faststart", "-f", "mp4", "-y", r.path)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("failed to open ffmpeg input: %w", err)
+	}
+	r.stderr = &stderrTail{}
+	cmd.Stderr = r.stderr
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start ffmpeg: %w", err)
+	}
+	r.cmd, r.stdin = cmd, stdin
+	return nil
+}
+
+// write sends one frame to ffmpeg. Called with r.mu held.
+func (r *VideoRecorder) write(frame []byte) error {
+	if _, err := r.stdin.Write(frame); err != nil {
+		r.err = fmt.Errorf("ffmpeg stopped recording: %w", err)
+		return r.err
+	}
+	r.written++
+	return nil
+}
+
+// Err is the error that stopped the recording, if any.
+func (r *VideoRecorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// Duration is the time covered by the frames written so far.
+func (r *VideoRecorder) Duration() time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.last.Sub(r.started)
+}
+
+// Close finishes the recording, waiting up to ten seconds for ffmpeg to
+// write the file. It returns the recording's frames, timing and size, nil
+// when nothing was recorded.
+func (r *VideoRecorder) Close() (*models.Recording, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cmd == nil {
+		return nil, nil
+	}
+	cmd := r.cmd
+	r.cmd = nil
+	r.stdin.Close()
+
+	var err error
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+	select {
+	case err = <-exited:
+	case <-time.After(10 * time.Second):
+		cmd.Process.Kill()
+		err = <-exited
+	}
+	if err != nil {
+		os.Remove(r.path)
+		return nil, fmt.Errorf("ffmpeg failed to finish recording: %w: %s", err, r.stderr.String())
+	}
+	return &models.Recording{
+		StartedAt: r.started,
+		// The last frame is shown for one more slot
+		EndedAt: r.last.Add(time.Second / time.Duration(r.fps)),
+		Frames:  r.frames,
+		Width:   r.width,
+		Height:  r.height,
+	}, nil
+}
+
+// SaveRecording seals the finished file at tmpPath into the recording's
+// place under dir and indexes it for its session and the purge. The file is
+// sealed in chunks, so it is never held in memory.
+func SaveRecording(ctx context.Context, rdb *redis.Client, dir string, recording *models.Recording, tmpPath string) error {
+	path, err := recordingPath(dir, recording.TenantID, recording.ID)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpPath)
+	src, err := os.Open(tmpPath)
+	if err != nil {
+		return fmt.Errorf("failed to read recording: %w", err)
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(path+".tmp", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o640)
+	if err != nil {
+		return fmt.Errorf("failed to write recording: %w", err)
+	}
+	size, err := sealArtifactStream(ctx, recording.TenantID, src, dst)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path + ".tmp")
+		return fmt.Errorf("failed to write recording: %w", err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		os.Remove(path + ".tmp")
+		return fmt.Errorf("failed to write recording: %w", err)
+	}
+	recording.Bytes = size
+
+	data, err := marshalArtifact(ctx, recording.TenantID, recording)
+	if err != nil {
+		return fmt.Errorf("failed to encode recording: %w", err)
+	}
+	sessionKey := sessionRecordingKeyPrefix + recording.TenantID + ":" + recording.SessionID
+	pipe := rdb.TxPipeline()
+	pipe.Set(ctx, recordingKeyPrefix+recording.TenantID+":"+recording.ID, data, 0)
+	pipe.ZAdd(ctx, sessionKey, redis.Z{Score: float64(recording.StartedAt.Unix()), Member: recording.ID})
+	pipe.ZAdd(ctx, recordingIndexKey, redis.Z{Score: float64(recording.EndedAt.Unix()), Member: recording.TenantID + "/" + recording.ID})
+	if _, err := pipe.Exec(ctx); err != nil {
+		os.Remove(path)
+		return fmt.Errorf("failed to index recording: %w", err)
+	}
+	return nil
+}
+
+// LoadRecording returns a recording's description.
+func LoadRecording(ctx context.Context, rdb *redis.Client, tenantID, recordingID string) (*models.Recording, error) {
+	data, err := rdb.Get(ctx, recordingKeyPrefix+tenantID+":"+recordingID).Bytes()
+	if err == redis.Nil {
+		return nil, ErrRecordingNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load recording: %w", err)
+	}
+	var recording models.Recording
+	if err := unmarshalArtifact(ctx, data, &recording); err != nil {
+		return nil, fmt.Errorf("failed to decode recording: %w", err)
+	}
+	return &recording, nil
+}
+
+// OpenRecording returns the MP4 file of a recording, decrypted as it is
+// read. The caller closes it.
+func OpenRecording(ctx context.Context, dir, tenantID, recordingID string) (io.ReadSeekCloser, error) {
+	path, err := recordingPath(dir, tenantID, recordingID)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrRecordingNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read recording: %w", err)
+	}
+	video, err := openArtifactStream(ctx, file)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to decrypt recording: %w", err)
+	}
+	return video, nil
+}
+
+// RotateRecordingKeys rewraps the recording files under dir whose data key
+// is wrapped under a key other than their tenant's current one, like
+// RotateArtifactKeys does for Redis, and returns the number of recordings
+// rewrapped. A recording purged meanwhile is not written back.
+func RotateRecordingKeys(ctx context.Context, rdb *redis.Client, dir string) (int, error) {
+	c, err := artifacts()
+	if err != nil {
+		return 0, err
+	}
+	members, err := rdb.ZRange(ctx, recordingIndexKey, 0, -1).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read recording index: %w", err)
+	}
+
+	rotated := 0
+	for _, member := range members {
+		tenantID, recordingID, _ := strings.Cut(member, "/")
+		path, err := recordingPath(dir, tenantID, recordingID)
+		if err != nil {
+			continue
+		}
+		changed, err := rewrapRecording(ctx, c, path)
+		if err != nil {
+			return rotated, fmt.Errorf("failed to rewrap recording %s: %w", member, err)
+		}
+		if !changed {
+			continue
+		}
+		if _, err := os.Stat(path); err != nil {
+			os.Remove(path + ".tmp")
+			continue
+		}
+		if err := os.Rename(path+".tmp", path); err != nil {
+			os.Remove(path + ".tmp")
+			return rotated, fmt.Errorf("failed to write recording: %w", err)
+		}
+		rotated++
+	}
+	return rotated, nil
+}
+
+// rewrapRecording writes the recording at path, rewrapped, to path.tmp. It
+// reports false if the recording is gone or needs no rewrap. Recordings
+// sealed whole before chunked sealing are rewrapped in memory.
+func rewrapRecording(ctx context.Context, c *ArtifactCipher, path string) (bool, error) {
+	src, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read recording: %w", err)
+	}
+	defer src.Close()
+
+	prefix := make([]byte, len(artifactEnvelopePrefix))
+	if _, err := src.ReadAt(prefix, 0); err == nil && bytes.Equal(prefix, []byte(artifactEnvelopePrefix)) {
+		stored, err := io.ReadAll(src)
+		if err != nil {
+			return false, fmt.Errorf("failed to read recording: %w", err)
+		}
+		rewrapped, changed, err := c.rewrap(ctx, stored)
+		if err != nil || !changed {
+			return false, err
+		}
+		if err := os.WriteFile(path+".tmp", rewrapped, 0o640); err != nil {
+			return false, fmt.Errorf("failed to write recording: %w", err)
+		}
+		return true, nil
+	}
+
+	dst, err := os.OpenFile(path+".tmp", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o640)
+	if err != nil {
+		return false, fmt.Errorf("failed to write recording: %w", err)
+	}
+	changed, err := c.rewrapStream(ctx, src, dst)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil || !changed {
+		os.Remove(path + ".tmp")
+		return false, err
+	}
+	return true, nil
+}
+
+// ListSessionRecordings returns a session's recordings in order.
+func ListSessionRecordings(ctx context.Context, rdb *redis.Client, tenantID, sessionID string) ([]models.Recording, error) {
+	ids, err := rdb.ZRange(ctx, sessionRecordingKeyPrefix+tenantID+":"+sessionID, 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list recordings: %w", err)
+	}
+	recordings := make([]models.Recording, 0, len(ids))
+	for _, id := range ids {
+		recording, err := LoadRecording(ctx, rdb, tenantID, id)
+		if errors.Is(err, ErrRecordingNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		recordings = append(recordings, *recording)
+	}
+	return recordings, nil
+}
+
+// PurgeExpiredRecordings deletes recordings that ended more than retention
+// ago, their files and their index entries.
+func PurgeExpiredRecordings(ctx context.Context, rdb *redis.Client, dir string, retention time.Duration) (int, error) {
+	if retention <= 0 {
+		return 0, nil
+	}
+	cutoff := time.Now().Add(-retention)
+	expired, err := rdb.ZRangeByScore(ctx, recordingIndexKey, &redis.ZRangeBy{
+		Min: "-inf",
+		Max: "(" + strconv.FormatInt(cutoff.Unix(), 10),
+	}).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read recording index: %w", err)
+	}
+
+	purged := 0
+	for _, member := range expired {
+		tenantID, recordingID, _ := strings.Cut(member, "/")
+		recording, err := LoadRecording(ctx, rdb, tenantID, recordingID)
+		if err != nil && !errors.Is(err, ErrRecordingNotFound) {
+			return purged, err
+		}
+		if path, err := recordingPath(dir, tenantID, recordingID); err == nil {
+			if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return purged, fmt.Errorf("failed to delete recording: %w", err)
+			}
+		}
+		pipe := rdb.TxPipeline()
+		pipe.Del(ctx, recordingKeyPrefix+tenantID+":"+recordingID)
+		if recording != nil {
+			pipe.ZRem(ctx, sessionRecordingKeyPrefix+tenantID+":"+recording.SessionID, recordingID)
+		}
+		pipe.ZRem(ctx, recordingIndexKey, member)
+		if _, err := pipe.Exec(ctx); err != nil {
+			return purged, fmt.Errorf("failed to purge recording: %w", err)
+		}
+		purged++
+	}
+	return purged, nil
+}
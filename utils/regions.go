@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"bytes"
+	"image"
+	"math"
+	"strings"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
+)
+
+// VisionRegionsEnabled reports whether scene analysis asks the model for
+// bounding boxes of key elements (VISION_REGIONS_ENABLED, default true).
+func VisionRegionsEnabled() bool {
+	return CurrentConfig().VisionRegionsEnabled
+}
+
+// NormalizeRegions clamps model-estimated boxes to the frame and drops
+// unlabeled or empty ones.
+func NormalizeRegions(regions []models.Region) []models.Region {
+	normalized := regions[:0]
+	for _, region := range regions {
+		region.Label = strings.TrimSpace(region.Label)
+		x0, y0 := clamp01(region.X), clamp01(region.Y)
+		x1, y1 := clamp01(region.X+region.Width), clamp01(region.Y+region.Height)
+		if region.Label == "" || x1 <= x0 || y1 <= y0 {
+			continue
+		}
+		region.X, region.Y = x0, y0
+		region.Width, region.Height = x1-x0, y1-y0
+		region.Confidence = clamp01(region.Confidence)
+		normalized = append(normalized, region)
+	}
+	if len(normalized) == 0 {
+		return nil
+	}
+	return normalized
+}
+
+// FrameSize returns the pixel dimensions of a JPEG or PNG frame (raw base64
+// or data URL) without decoding the whole image.
+func FrameSize(imageData string) (int, int, bool) {
+	raw, err := decodeDataURL(imageData)
+	if err != nil {
+		return 0, 0, false
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(raw))
+	if err != nil {
+		return 0, 0, false
+	}
+	return config.Width, config.Height, true
+}
+
+func clamp01(v float64) float64 {
+	if math.IsNaN(v) {
+		return 0
+	}
+	return math.Max(0, math.Min(1, v))
+}
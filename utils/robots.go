@@ -0,0 +1,195 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	robotKeyPrefix      = "perceptus:robot:"
+	robotUsageKeyPrefix = "perceptus:robot_usage:"
+	robotIndexKeyPrefix = "perceptus:robots:"
+)
+
+// ErrRobotNotFound is returned for a robot that is not registered.
+var ErrRobotNotFound = errors.New("robot not registered")
+
+var robotIDPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._:-]{0,127}$`)
+
+// ValidRobotID reports whether id can name a robot.
+func ValidRobotID(id string) bool {
+	return robotIDPattern.MatchString(id)
+}
+
+func robotKey(tenantID, robotID string) string {
+	return robotKeyPrefix + tenantID + ":" + robotID
+}
+
+// LoadRobot returns a registered robot with its cumulative usage.
+func LoadRobot(ctx context.Context, rdb *redis.Client, tenantID, robotID string) (*models.Robot, error) {
+	data, err := rdb.Get(ctx, robotKey(tenantID, robotID)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrRobotNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load robot: %w", err)
+	}
+	var robot models.Robot
+	if err := unmarshalArtifact(ctx, data, &robot); err != nil {
+		return nil, fmt.Errorf("failed to decode robot: %w", err)
+	}
+	usage, err := rdb.HGetAll(ctx, robotUsageKeyPrefix+tenantID+":"+robotID).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load robot usage: %w", err)
+	}
+	if len(usage) > 0 {
+		robot.Usage = make(map[string]int64, len(usage))
+		for counter, value := range usage {
+			robot.Usage[counter], _ = strconv.ParseInt(value, 10, 64)
+		}
+	}
+	return &robot, nil
+}
+
+// ListRobots returns the tenant's robots ordered by ID.
+func ListRobots(ctx context.Context, rdb *redis.Client, tenantID string) ([]models.Robot, error) {
+	ids, err := rdb.SMembers(ctx, robotIndexKeyPrefix+tenantID).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list robots: %w", err)
+	}
+	sort.Strings(ids)
+	robots := make([]models.Robot, 0, len(ids))
+	for _, id := range ids {
+		robot, err := LoadRobot(ctx, rdb, tenantID, id)
+		if errors.Is(err, ErrRobotNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		robots = append(robots, *robot)
+	}
+	return robots, nil
+}
+
+// RegisterRobot creates a robot or updates its registration. State carried
+// over from its sessions is kept; create reports whether it is new.
+func RegisterRobot(ctx context.Context, rdb *redis.Client, tenantID string, registration models.RobotRegistration, now time.Time) (*models.Robot, bool, error) {
+	if !ValidRobotID(registration.ID) {
+		return nil, false, fmt.Errorf("invalid robot id %q", registration.ID)
+	}
+	var created bool
+	robot, err := UpdateRobot(ctx, rdb, tenantID, registration.ID, true, func(robot *models.Robot) {
+		created = robot.RegisteredAt.IsZero()
+		if created {
+			robot.RegisteredAt = now
+		}
+		robot.Name, robot.Model, robot.Site = registration.Name, registration.Model, registration.Site
+		robot.Metadata, robot.Calibration = registration.Metadata, registration.Calibration
+		robot.UpdatedAt = now
+	})
+	return robot, created, err
+}
+
+// UpdateRobot applies update to a robot in an optimistic transaction,
+// retried when another session changed the robot meanwhile. Without create
+// an unknown robot is ErrRobotNotFound.
+func UpdateRobot(ctx context.Context, rdb *redis.Client, tenantID, robotID string, create bool, update func(*models.Robot)) (*models.Robot, error) {
+	key := robotKey(tenantID, robotID)
+	var robot models.Robot
+	txn := func(tx *redis.Tx) error {
+		robot = models.Robot{ID: robotID, TenantID: tenantID}
+		data, err := tx.Get(ctx, key).Bytes()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return err
+		}
+		if errors.Is(err, redis.Nil) && !create {
+			return ErrRobotNotFound
+		}
+		if err == nil {
+			if err := unmarshalArtifact(ctx, data, &robot); err != nil {
+				return fmt.Errorf("failed to decode robot: %w", err)
+			}
+		}
+
+		update(&robot)
+		// Usage is counted separately so sessions never race on it
+		robot.Usage = nil
+		stored, err := marshalArtifact(ctx, tenantID, robot)
+		if err != nil {
+			return fmt.Errorf("failed to encode robot: %w", err)
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, key, stored, 0)
+			pipe.SAdd(ctx, robotIndexKeyPrefix+tenantID, robotID)
+			return nil
+		})
+		return err
+	}
+
+	for attempt := 0; attempt < 5; attempt++ {
+		err := rdb.Watch(ctx, txn, key)
+		if errors.Is(err, redis.TxFailedErr) {
+			continue
+		}
+		if errors.Is(err, ErrRobotNotFound) {
+			return nil, err
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to save robot: %w", err)
+		}
+		return &robot, nil
+	}
+	return nil, fmt.Errorf("failed to save robot: too many concurrent updates")
+}
+
+// DeleteRobot removes a robot, its state and usage, and reports whether it
+// existed. Preferences learned for it are kept.
+func DeleteRobot(ctx context.Context, rdb *redis.Client, tenantID, robotID string) (bool, error) {
+	pipe := rdb.TxPipeline()
+	deleted := pipe.Del(ctx, robotKey(tenantID, robotID))
+	pipe.Del(ctx, robotUsageKeyPrefix+tenantID+":"+robotID)
+	pipe.SRem(ctx, robotIndexKeyPrefix+tenantID, robotID)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return false, fmt.Errorf("failed to delete robot: %w", err)
+	}
+	return deleted.Val() > 0, nil
+}
+
+// TenantRobotIDs returns the IDs of the tenant's robots, registered or only
+// counted in usage, ordered by ID.
+func TenantRobotIDs(ctx context.Context, rdb *redis.Client, tenantID string) ([]string, error) {
+	ids, err := rdb.SMembers(ctx, robotIndexKeyPrefix+tenantID).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list robots: %w", err)
+	}
+	for _, prefix := range []string{robotKey(tenantID, ""), robotUsageKeyPrefix + tenantID + ":"} {
+		iter := rdb.Scan(ctx, 0, prefix+"*", 100).Iterator()
+		for iter.Next(ctx) {
+			ids = append(ids, strings.TrimPrefix(iter.Val(), prefix))
+		}
+		if err := iter.Err(); err != nil {
+			return nil, fmt.Errorf("failed to scan robots: %w", err)
+		}
+	}
+	sort.Strings(ids)
+	return slices.Compact(ids), nil
+}
+
+// IncrementRobotUsage bumps a usage counter of a robot.
+func IncrementRobotUsage(ctx context.Context, rdb *redis.Client, tenantID, robotID, counter string, delta int64) error {
+	if err := rdb.HIncrBy(ctx, robotUsageKeyPrefix+tenantID+":"+robotID, counter, delta).Err(); err != nil {
+		return fmt.Errorf("failed to increment robot usage: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
+)
+
+// LoadTriggerRules reads a JSON array of trigger rules.
+func LoadTriggerRules(path string) ([]models.TriggerRule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rules file: %w", err)
+	}
+	var rules []models.TriggerRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("failed to parse rules file: %w", err)
+	}
+	if err := ValidateTriggerRules(rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// ValidateTriggerRules checks rule IDs, durations and actions.
+func ValidateTriggerRules(rules []models.TriggerRule) error {
+	seen := make(map[string]bool, len(rules))
+	for _, rule := range rules {
+		if rule.ID == "" {
+			return fmt.Errorf("trigger rule %q has no id", rule.Name)
+		}
+		if seen[rule.ID] {
+			return fmt.Errorf("duplicate trigger rule id %q", rule.ID)
+		}
+		seen[rule.ID] = true
+
+		for _, d := range []string{rule.For, rule.Cooldown} {
+			if _, err := time.ParseDuration(d); d != "" && err != nil {
+				return fmt.Errorf("trigger rule %q: invalid duration %q", rule.ID, d)
+			}
+		}
+		for _, action := range rule.Actions {
+			if action != models.RULE_ACTION_ALERT && action != models.RULE_ACTION_ORCHESTRATOR {
+				return fmt.Errorf("trigger rule %q: unknown action %q", rule.ID, action)
+			}
+		}
+	}
+	return nil
+}
+
+// RuleMatches reports whether every condition of the rule holds for envContext.
+func RuleMatches(rule models.TriggerRule, envContext models.EnvironmentContext) bool {
+	if len(rule.Conditions) == 0 {
+		return false
+	}
+	for _, condition := range rule.Conditions {
+		if !conditionMatches(condition, envContext) {
+			return false
+		}
+	}
+	return true
+}
+
+func conditionMatches(condition models.RuleCondition, envContext models.EnvironmentContext) bool {
+	var values []string
+	switch condition.Field {
+	case "overview":
+		values = []string{envContext.Overview}
+	case "layout":
+		values = []string{envContext.Layout}
+	case "key_elements":
+		values = envContext.KeyElements
+	case "activities":
+		values = envContext.Activities
+	default:
+		return false
+	}
+
+	want := strings.ToLower(condition.Value)
+	anyMatch := func(match func(string) bool) bool {
+		for _, v := range values {
+			if match(strings.ToLower(v)) {
+				return true
+			}
+		}
+		return false
+	}
+
+	switch condition.Operator {
+	case "contains":
+		return anyMatch(func(v string) bool { return strings.Contains(v, want) })
+	case "not_contains":
+		return !anyMatch(func(v string) bool { return strings.Contains(v, want) })
+	case "equals":
+		return anyMatch(func(v string) bool { return v == want })
+	case "not_equals":
+		return !anyMatch(func(v string) bool { return v == want })
+	}
+	return false
+}
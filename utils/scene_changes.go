@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
+)
+
+// LoadChangeWatches reads a JSON array of change watches.
+func LoadChangeWatches(path string) ([]models.ChangeWatch, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read change watches file: %w", err)
+	}
+	var watches []models.ChangeWatch
+	if err := json.Unmarshal(data, &watches); err != nil {
+		return nil, fmt.Errorf("failed to parse change watches file: %w", err)
+	}
+	if err := ValidateChangeWatches(watches); err != nil {
+		return nil, err
+	}
+	return watches, nil
+}
+
+// ValidateChangeWatches checks watch IDs, fields, changes, cooldowns and
+// actions.
+func ValidateChangeWatches(watches []models.ChangeWatch) error {
+	seen := make(map[string]bool, len(watches))
+	for _, watch := range watches {
+		if watch.ID == "" {
+			return fmt.Errorf("change watch %q has no id", watch.Name)
+		}
+		if seen[watch.ID] {
+			return fmt.Errorf("duplicate change watch id %q", watch.ID)
+		}
+		seen[watch.ID] = true
+
+		switch watch.Field {
+		case "", "key_elements", "activities":
+		default:
+			return fmt.Errorf("change watch %q: unknown field %q", watch.ID, watch.Field)
+		}
+		switch watch.Change {
+		case "", models.CHANGE_APPEARED, models.CHANGE_DISAPPEARED:
+		default:
+			return fmt.Errorf("change watch %q: unknown change %q", watch.ID, watch.Change)
+		}
+		if _, err := time.ParseDuration(watch.Cooldown); watch.Cooldown != "" && err != nil {
+			return fmt.Errorf("change watch %q: invalid duration %q", watch.ID, watch.Cooldown)
+		}
+		for _, action := range watch.Actions {
+			if action != models.RULE_ACTION_ALERT && action != models.RULE_ACTION_ORCHESTRATOR {
+				return fmt.Errorf("change watch %q: unknown action %q", watch.ID, action)
+			}
+		}
+	}
+	return nil
+}
+
+// ChangeWatchMatches reports whether a scene change is one the watch looks
+// for.
+func ChangeWatchMatches(watch models.ChangeWatch, change models.SceneChange) bool {
+	if watch.Field != "" && watch.Field != change.Field {
+		return false
+	}
+	if watch.Change != "" && watch.Change != change.Change {
+		return false
+	}
+	return strings.Contains(strings.ToLower(change.Value), strings.ToLower(watch.Value))
+}
+
+// sceneArticles are dropped from the start of scene items before comparing.
+var sceneArticles = []string{"a", "an", "the", "some"}
+
+// SceneItemKey normalizes a key element or activity so wording the vision
+// model varies between frames compares equal: case, punctuation, a leading
+// article and plural s are ignored, so "The red mugs." matches "red mug".
+func SceneItemKey(item string) string {
+	words := strings.FieldsFunc(strings.ToLower(item), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for len(words) > 1 && slices.Contains(sceneArticles, words[0]) {
+		words = words[1:]
+	}
+	for i, word := range words {
+		if len(word) > 3 && strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "ss") {
+			words[i] = strings.TrimSuffix(word, "s")
+		}
+	}
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
+)
+
+const sceneQuestionPrompt = `You are the eyes of a robot. Answer the user's question about the scene using only what is visible in the images, which are the robot's latest camera frames, oldest first. Return ONLY a JSON object with keys: answer (string, one or two sentences), verdict ("yes", "no" or "unknown" for yes/no questions, "" otherwise), confidence (number between 0 and 1), evidence (string, what in the images the answer rests on). When the images do not show enough to answer, say so, use verdict "unknown" and a low confidence. No extra keys or prose.`
+
+// AnswerSceneQuestion asks the vision model a question about frames (data
+// URLs, oldest first).
+func (c *OpenAIClient) AnswerSceneQuestion(ctx context.Context, question string, frames []string) (*models.SceneAnswer, error) {
+	content := []map[string]interface{}{
+		{"type": "text", "text": question},
+	}
+	for _, frame := range frames {
+		content = append(content, map[string]interface{}{
+			"type":      "image_url",
+			"image_url": map[string]string{"url": frame},
+		})
+	}
+
+	message, err := c.completeTask(ctx, MODEL_TASK_VISION, map[string]interface{}{
+		"messages": []map[string]interface{}{
+			{"role": "system", "content": sceneQuestionPrompt},
+			{"role": "user", "content": content},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ParseSceneAnswer(message.Content)
+}
+
+// ParseSceneAnswer decodes the model's JSON answer, clamping the confidence
+// and normalizing the verdict.
+func ParseSceneAnswer(content string) (*models.SceneAnswer, error) {
+	clean := strings.TrimSpace(content)
+	clean = strings.TrimPrefix(clean, "```json")
+	clean = strings.TrimSuffix(clean, "```")
+
+	var answer models.SceneAnswer
+	if err := json.Unmarshal([]byte(clean), &answer); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal scene answer JSON: %w", err)
+	}
+	if strings.TrimSpace(answer.Answer) == "" {
+		return nil, fmt.Errorf("scene answer is empty")
+	}
+	answer.Confidence = min(max(answer.Confidence, 0), 1)
+	switch verdict := strings.ToLower(strings.TrimSpace(answer.Verdict)); verdict {
+	case models.SCENE_VERDICT_YES, models.SCENE_VERDICT_NO, models.SCENE_VERDICT_UNKNOWN, "":
+		answer.Verdict = verdict
+	default:
+		answer.Verdict = models.SCENE_VERDICT_UNKNOWN
+	}
+	return &answer, nil
+}
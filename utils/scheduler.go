@@ -0,0 +1,118 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+const schedulerLockKeyPrefix = "perceptus:scheduler:"
+
+// Job is a periodic task run by the Scheduler.
+type Job func(ctx context.Context) error
+
+// Scheduler runs periodic jobs exactly once per interval across replicas.
+// Time is divided into interval-sized slots; every replica that knows a job
+// ticks once per interval, and only the first to take the slot's Redis lock
+// runs it.
+type Scheduler struct {
+	rdb *redis.Client
+
+	mu   sync.Mutex
+	jobs map[string]context.CancelFunc
+}
+
+func NewScheduler(rdb *redis.Client) *Scheduler {
+	return &Scheduler{
+		rdb:  rdb,
+		jobs: make(map[string]context.CancelFunc),
+	}
+}
+
+// Schedule starts running job every interval under a unique name, e.g.
+// "session:<id>:memory_compaction". Scheduling a name again replaces the job.
+func (s *Scheduler) Schedule(name string, interval time.Duration, job Job) {
+	if interval <= 0 {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.mu.Lock()
+	if previous, ok := s.jobs[name]; ok {
+		previous()
+	}
+	s.jobs[name] = cancel
+	s.mu.Unlock()
+
+	go s.run(ctx, name, interval, job)
+}
+
+// Cancel stops the named job.
+func (s *Scheduler) Cancel(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cancel, ok := s.jobs[name]; ok {
+		cancel()
+		delete(s.jobs, name)
+	}
+}
+
+// CancelPrefix stops every job whose name starts with prefix, e.g. all jobs
+// of a session.
+func (s *Scheduler) CancelPrefix(prefix string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for name, cancel := range s.jobs {
+		if strings.HasPrefix(name, prefix) {
+			cancel()
+			delete(s.jobs, name)
+		}
+	}
+}
+
+// Stop cancels all jobs.
+func (s *Scheduler) Stop() {
+	s.CancelPrefix("")
+}
+
+func (s *Scheduler) run(ctx context.Context, name string, interval time.Duration, job Job) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			acquired, err := s.acquire(ctx, name, interval, now)
+			if err != nil {
+				zap.L().Warn("Failed to acquire scheduler lock", zap.String("job", name), zap.Error(err))
+				continue
+			}
+			if !acquired {
+				continue
+			}
+			if err := job(ctx); err != nil {
+				zap.L().Warn("Scheduled job failed", zap.String("job", name), zap.Error(err))
+			}
+		}
+	}
+}
+
+// acquire takes the lock of the interval slot containing now. The lock
+// expires with the slot, so a crashed replica never blocks the next run.
+func (s *Scheduler) acquire(ctx context.Context, name string, interval time.Duration, now time.Time) (bool, error) {
+	slot := now.UnixNano() / int64(interval)
+	key := schedulerLockKeyPrefix + name + ":" + strconv.FormatInt(slot, 10)
+	acquired, err := s.rdb.SetNX(ctx, key, InstanceID(), interval).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to set scheduler lock: %w", err)
+	}
+	return acquired, nil
+}
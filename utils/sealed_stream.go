@@ -0,0 +1,296 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+)
+
+// sealedStreamPrefix marks a file sealed in chunks, which is read without
+// loading it whole. It is followed by a JSON sealedStreamHeader, a newline
+// and the sealed chunks.
+const sealedStreamPrefix = "encs:v1:"
+
+const (
+	sealedChunkSize = 64 << 10
+	// maxSealedStreamHeader bounds the header line
+	maxSealedStreamHeader = 4 << 10
+)
+
+// sealedStreamHeader describes a chunk-sealed file. Like an artifact
+// envelope, it carries the wrapped data key, so rotation only rewrites it.
+type sealedStreamHeader struct {
+	KeyID     string `json:"kid"`
+	TenantID  string `json:"tid"`
+	DataKey   []byte `json:"dk"`
+	Size      int64  `json:"size"`
+	ChunkSize int    `json:"chunk"`
+}
+
+// chunkNonce derives a chunk's nonce from its index; every stream has its
+// own data key, so nonces never repeat under a key.
+func chunkNonce(aead cipher.AEAD, index int64) []byte {
+	nonce := make([]byte, aead.NonceSize())
+	binary.BigEndian.PutUint64(nonce[len(nonce)-8:], uint64(index))
+	return nonce
+}
+
+// chunkAAD binds a chunk to its tenant, its place and the stream's size, so
+// chunks cannot be reordered and a truncated stream does not open.
+func chunkAAD(header sealedStreamHeader, index int64) []byte {
+	return []byte(header.TenantID + ":" + strconv.FormatInt(header.Size, 10) + ":" + strconv.FormatInt(index, 10))
+}
+
+// SealStream copies src to dst, sealed in chunks under the tenant's current
+// key, or unchanged if the tenant has none, and returns the plaintext size.
+func (c *ArtifactCipher) SealStream(ctx context.Context, tenantID string, src *os.File, dst io.Writer) (int64, error) {
+	keyID := c.keyFor(tenantID)
+	if keyID == "" {
+		return io.Copy(dst, src)
+	}
+	info, err := src.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("failed to stat stream: %w", err)
+	}
+
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return 0, fmt.Errorf("failed to generate data key: %w", err)
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return 0, err
+	}
+	wrapped, err := c.provider.WrapKey(ctx, keyID, dataKey, []byte(tenantID))
+	if err != nil {
+		return 0, fmt.Errorf("failed to wrap data key: %w", err)
+	}
+	header := sealedStreamHeader{KeyID: keyID, TenantID: tenantID, DataKey: wrapped, Size: info.Size(), ChunkSize: sealedChunkSize}
+	if err := writeSealedStreamHeader(dst, header); err != nil {
+		return 0, err
+	}
+
+	chunk := make([]byte, sealedChunkSize)
+	sealed := make([]byte, 0, sealedChunkSize+aead.Overhead())
+	var written int64
+	for index := int64(0); written < header.Size; index++ {
+		n, err := io.ReadFull(src, chunk[:min(int64(sealedChunkSize), header.Size-written)])
+		if err != nil {
+			return written, fmt.Errorf("failed to read stream: %w", err)
+		}
+		sealed = aead.Seal(sealed[:0], chunkNonce(aead, index), chunk[:n], chunkAAD(header, index))
+		if _, err := dst.Write(sealed); err != nil {
+			return written, fmt.Errorf("failed to write stream: %w", err)
+		}
+		written += int64(n)
+	}
+	return written, nil
+}
+
+// OpenStream returns a reader of a file written by SealStream; it decrypts
+// one chunk at a time, and seeking decrypts only the chunk sought to.
+// Plaintext files are returned as they are and whole-file envelopes are
+// opened in memory. The file is closed with the reader.
+func (c *ArtifactCipher) OpenStream(ctx context.Context, file *os.File) (io.ReadSeekCloser, error) {
+	prefix := make([]byte, len(sealedStreamPrefix))
+	n, err := file.ReadAt(prefix, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to read stream: %w", err)
+	}
+	prefix = prefix[:n]
+
+	switch {
+	case bytes.Equal(prefix, []byte(sealedStreamPrefix)):
+		header, offset, err := readSealedStreamHeader(file)
+		if err != nil {
+			return nil, err
+		}
+		dataKey, err := c.provider.UnwrapKey(ctx, header.KeyID, header.DataKey, []byte(header.TenantID))
+		if err != nil {
+			return nil, fmt.Errorf("failed to unwrap data key: %w", err)
+		}
+		aead, err := newGCM(dataKey)
+		if err != nil {
+			return nil, err
+		}
+		return &sealedStreamReader{file: file, aead: aead, header: header, dataOffset: offset, chunk: -1}, nil
+	case bytes.HasPrefix(prefix, []byte(artifactEnvelopePrefix)):
+		stored, err := io.ReadAll(io.NewSectionReader(file, 0, 1<<62))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read stream: %w", err)
+		}
+		plaintext, err := c.Open(ctx, stored)
+		if err != nil {
+			return nil, err
+		}
+		file.Close()
+		return nopSeekCloser{bytes.NewReader(plaintext)}, nil
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to read stream: %w", err)
+	}
+	return file, nil
+}
+
+// rewrapStream writes src to dst with its data key wrapped under the
+// tenant's current key, copying the sealed chunks unchanged. It reports
+// false, writing nothing, if src needs no rewrap.
+func (c *ArtifactCipher) rewrapStream(ctx context.Context, src *os.File, dst io.Writer) (bool, error) {
+	prefix := make([]byte, len(sealedStreamPrefix))
+	if _, err := src.ReadAt(prefix, 0); err != nil || !bytes.Equal(prefix, []byte(sealedStreamPrefix)) {
+		return false, nil
+	}
+	header, offset, err := readSealedStreamHeader(src)
+	if err != nil {
+		return false, err
+	}
+	current := c.keyFor(header.TenantID)
+	if current == "" || current == header.KeyID {
+		return false, nil
+	}
+
+	aad := []byte(header.TenantID)
+	dataKey, err := c.provider.UnwrapKey(ctx, header.KeyID, header.DataKey, aad)
+	if err != nil {
+		return false, fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+	if header.DataKey, err = c.provider.WrapKey(ctx, current, dataKey, aad); err != nil {
+		return false, fmt.Errorf("failed to wrap data key: %w", err)
+	}
+	header.KeyID = current
+	if err := writeSealedStreamHeader(dst, header); err != nil {
+		return false, err
+	}
+	if _, err := io.Copy(dst, io.NewSectionReader(src, offset, 1<<62)); err != nil {
+		return false, fmt.Errorf("failed to copy stream: %w", err)
+	}
+	return true, nil
+}
+
+func writeSealedStreamHeader(dst io.Writer, header sealedStreamHeader) error {
+	data, err := json.Marshal(header)
+	if err != nil {
+		return fmt.Errorf("failed to marshal stream header: %w", err)
+	}
+	line := append(append([]byte(sealedStreamPrefix), data...), '\n')
+	if _, err := dst.Write(line); err != nil {
+		return fmt.Errorf("failed to write stream: %w", err)
+	}
+	return nil
+}
+
+// readSealedStreamHeader returns the header of a sealed stream and where
+// its chunks start.
+func readSealedStreamHeader(file *os.File) (sealedStreamHeader, int64, error) {
+	var header sealedStreamHeader
+	buf := make([]byte, maxSealedStreamHeader)
+	n, err := file.ReadAt(buf, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return header, 0, fmt.Errorf("failed to read stream header: %w", err)
+	}
+	end := bytes.IndexByte(buf[:n], '\n')
+	if end < 0 {
+		return header, 0, errors.New("stream header too long")
+	}
+	if err := json.Unmarshal(buf[len(sealedStreamPrefix):end], &header); err != nil {
+		return header, 0, fmt.Errorf("failed to decode stream header: %w", err)
+	}
+	if header.ChunkSize <= 0 || header.Size < 0 {
+		return header, 0, errors.New("invalid stream header")
+	}
+	return header, int64(end + 1), nil
+}
+
+// sealedStreamReader decrypts a sealed stream chunk by chunk.
+type sealedStreamReader struct {
+	file       *os.File
+	aead       cipher.AEAD
+	header     sealedStreamHeader
+	dataOffset int64
+
+	offset int64
+	chunk  int64 // index of the chunk in plain, -1 before the first
+	plain  []byte
+}
+
+func (r *sealedStreamReader) Read(p []byte) (int, error) {
+	if r.offset >= r.header.Size {
+		return 0, io.EOF
+	}
+	chunkSize := int64(r.header.ChunkSize)
+	index := r.offset / chunkSize
+	if index != r.chunk {
+		if err := r.load(index); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.plain[r.offset-index*chunkSize:])
+	r.offset += int64(n)
+	return n, nil
+}
+
+func (r *sealedStreamReader) load(index int64) error {
+	chunkSize := int64(r.header.ChunkSize)
+	plainLen := min(chunkSize, r.header.Size-index*chunkSize)
+	sealed := make([]byte, plainLen+int64(r.aead.Overhead()))
+	position := r.dataOffset + index*(chunkSize+int64(r.aead.Overhead()))
+	if _, err := r.file.ReadAt(sealed, position); err != nil {
+		return fmt.Errorf("failed to read stream chunk %d: %w", index, err)
+	}
+	plain, err := r.aead.Open(r.plain[:0], chunkNonce(r.aead, index), sealed, chunkAAD(r.header, index))
+	if err != nil {
+		return fmt.Errorf("failed to decrypt stream chunk %d: %w", index, err)
+	}
+	r.plain, r.chunk = plain, index
+	return nil
+}
+
+func (r *sealedStreamReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.header.Size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	r.offset = offset
+	return offset, nil
+}
+
+func (r *sealedStreamReader) Close() error {
+	return r.file.Close()
+}
+
+type nopSeekCloser struct{ *bytes.Reader }
+
+func (nopSeekCloser) Close() error { return nil }
+
+// sealArtifactStream and openArtifactStream are used by the file stores.
+func sealArtifactStream(ctx context.Context, tenantID string, src *os.File, dst io.Writer) (int64, error) {
+	c, err := artifacts()
+	if err != nil {
+		return 0, err
+	}
+	return c.SealStream(ctx, tenantID, src, dst)
+}
+
+func openArtifactStream(ctx context.Context, file *os.File) (io.ReadSeekCloser, error) {
+	c, err := artifacts()
+	if err != nil {
+		return nil, err
+	}
+	return c.OpenStream(ctx, file)
+}
@@ -0,0 +1,131 @@
+package utils_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// saveTestRecording saves video as a recording of acme and returns it.
+func saveTestRecording(t *testing.T, rdb *redis.Client, dir string, video []byte) *models.Recording {
+	t.Helper()
+	tmp := filepath.Join(t.TempDir(), "recording.mp4")
+	if err := os.WriteFile(tmp, video, 0o600); err != nil {
+		t.Fatalf("write recording: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "acme"), 0o750); err != nil {
+		t.Fatalf("create recording directory: %v", err)
+	}
+	now := time.Now()
+	recording := &models.Recording{ID: "r1", TenantID: "acme", SessionID: "s1", StartedAt: now.Add(-time.Minute), EndedAt: now}
+	if err := utils.SaveRecording(context.Background(), rdb, dir, recording, tmp); err != nil {
+		t.Fatalf("SaveRecording() error = %v", err)
+	}
+	if recording.Bytes != int64(len(video)) {
+		t.Errorf("recording.Bytes = %d, want %d", recording.Bytes, len(video))
+	}
+	return recording
+}
+
+// readRecording reads a recording whole, then the range [from, to).
+func readRecording(t *testing.T, dir string, from, to int64) ([]byte, []byte) {
+	t.Helper()
+	video, err := utils.OpenRecording(context.Background(), dir, "acme", "r1")
+	if err != nil {
+		t.Fatalf("OpenRecording() error = %v", err)
+	}
+	defer video.Close()
+	whole, err := io.ReadAll(video)
+	if err != nil {
+		t.Fatalf("read recording: %v", err)
+	}
+	if _, err := video.Seek(from, io.SeekStart); err != nil {
+		t.Fatalf("Seek() error = %v", err)
+	}
+	part := make([]byte, to-from)
+	if _, err := io.ReadFull(video, part); err != nil {
+		t.Fatalf("read range: %v", err)
+	}
+	return whole, part
+}
+
+func TestRecordingsAreStreamedInChunks(t *testing.T) {
+	server := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer rdb.Close()
+	dir := t.TempDir()
+	t.Cleanup(func() { utils.SetArtifactCipher(nil) })
+
+	// Spans several chunks, the last one partial
+	video := make([]byte, 200<<10+123)
+	rand.New(rand.NewSource(1)).Read(video)
+
+	useKeys(t, "old1", "old1")
+	saveTestRecording(t, rdb, dir, video)
+	stored, err := os.ReadFile(filepath.Join(dir, "acme", "r1.mp4"))
+	if err != nil {
+		t.Fatalf("read stored recording: %v", err)
+	}
+	if bytes.Contains(stored, video[:1024]) {
+		t.Error("stored recording holds plaintext")
+	}
+
+	whole, part := readRecording(t, dir, 70000, 140000)
+	if !bytes.Equal(whole, video) {
+		t.Errorf("got = %d bytes, want the %d bytes saved", len(whole), len(video))
+	}
+	if !bytes.Equal(part, video[70000:140000]) {
+		t.Error("range across chunks differs from the saved bytes")
+	}
+
+	useKeys(t, "new2", "old1", "new2")
+	rotated, err := utils.RotateRecordingKeys(context.Background(), rdb, dir)
+	if err != nil {
+		t.Fatalf("RotateRecordingKeys() error = %v", err)
+	}
+	if rotated != 1 {
+		t.Errorf("RotateRecordingKeys() = %d, want 1", rotated)
+	}
+	useKeys(t, "new2", "new2")
+	if whole, _ := readRecording(t, dir, 0, 1); !bytes.Equal(whole, video) {
+		t.Error("rotated recording differs from the saved bytes")
+	}
+}
+
+func TestTruncatedRecordingDoesNotOpen(t *testing.T) {
+	server := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer rdb.Close()
+	dir := t.TempDir()
+	t.Cleanup(func() { utils.SetArtifactCipher(nil) })
+
+	useKeys(t, "old1", "old1")
+	saveTestRecording(t, rdb, dir, bytes.Repeat([]byte("frame"), 30000))
+	path := filepath.Join(dir, "acme", "r1.mp4")
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat recording: %v", err)
+	}
+	if err := os.Truncate(path, info.Size()-100); err != nil {
+		t.Fatalf("truncate recording: %v", err)
+	}
+
+	video, err := utils.OpenRecording(context.Background(), dir, "acme", "r1")
+	if err != nil {
+		t.Fatalf("OpenRecording() error = %v", err)
+	}
+	defer video.Close()
+	if _, err := io.ReadAll(video); err == nil {
+		t.Error("reading a truncated recording succeeded")
+	}
+}
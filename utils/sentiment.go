@@ -0,0 +1,208 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
+)
+
+// Sentiment backends, selected with SENTIMENT_BACKEND.
+const (
+	SENTIMENT_BACKEND_LLM  = "llm"
+	SENTIMENT_BACKEND_HTTP = "http"
+)
+
+// SentimentAnalyzer classifies the sentiment and emotion of a transcript.
+type SentimentAnalyzer interface {
+	AnalyzeSentiment(ctx context.Context, transcript string) (*models.Sentiment, error)
+}
+
+// NewSentimentAnalyzerFromConfig returns the analyzer SENTIMENT_BACKEND selects:
+// the sentiment model task of openaiClient (llm, the default) or the
+// classification service at SENTIMENT_URL (http).
+func NewSentimentAnalyzerFromConfig(cfg *Config, openaiClient *OpenAIClient) (SentimentAnalyzer, error) {
+	switch backend := cfg.SentimentBackend; backend {
+	case "", SENTIMENT_BACKEND_LLM:
+		return openaiClient, nil
+	case SENTIMENT_BACKEND_HTTP:
+		url := cfg.SentimentURL
+		if url == "" {
+			return nil, fmt.Errorf("SENTIMENT_URL not configured")
+		}
+		return &HTTPSentimentClassifier{
+			url:    url,
+			apiKey: cfg.SentimentAPIKey,
+			http:   &http.Client{Timeout: cfg.SentimentTimeout},
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown SENTIMENT_BACKEND %q", backend)
+	}
+}
+
+// AnalyzeSentiment asks the sentiment model how the transcript was said.
+func (c *OpenAIClient) AnalyzeSentiment(ctx context.Context, transcript string) (*models.Sentiment, error) {
+	prompt := fmt.Sprintf(`You listen to what people say to a home and service robot. Classify how the utterance below was said: its overall sentiment, the dominant emotion, its urgency from 0 (none) to 1 (emergency) and whether the speaker seems to be in distress or danger, e.g. calling for help, hurt, scared or panicking.
+
+Judge from the words, punctuation and repetition only. Everyday requests are calm with low urgency, even when phrased as commands.
+
+Utterance: "%s"`, transcript)
+
+	message, err := c.completeTask(ctx, MODEL_TASK_SENTIMENT, map[string]interface{}{
+		"messages": []GPTMessage{
+			{Role: "user", Content: prompt},
+		},
+		"response_format": sentimentResponseFormat,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var sentiment models.Sentiment
+	if err := json.Unmarshal([]byte(message.Content), &sentiment); err != nil {
+		return nil, fmt.Errorf("failed to parse sentiment: %w", err)
+	}
+	return normalizeSentiment(&sentiment), nil
+}
+
+var sentimentResponseFormat = map[string]interface{}{
+	"type": "json_schema",
+	"json_schema": map[string]interface{}{
+		"name":   "sentiment",
+		"strict": true,
+		"schema": map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"sentiment": map[string]interface{}{"type": "string", "enum": []string{models.SENTIMENT_POSITIVE, models.SENTIMENT_NEUTRAL, models.SENTIMENT_NEGATIVE}},
+				"emotion":   map[string]interface{}{"type": "string", "enum": models.Emotions},
+				"urgency":   map[string]interface{}{"type": "number"},
+				"distress":  map[string]interface{}{"type": "boolean"},
+			},
+			"required":             []string{"sentiment", "emotion", "urgency", "distress"},
+			"additionalProperties": false,
+		},
+	},
+}
+
+// HTTPSentimentClassifier posts transcripts as {"text": "..."} to a
+// dedicated classification service, which answers with a models.Sentiment:
+// {"sentiment":"negative","emotion":"panicked","urgency":0.9,"distress":true}.
+type HTTPSentimentClassifier struct {
+	url    string
+	apiKey string
+	http   *http.Client
+}
+
+func (c *HTTPSentimentClassifier) AnalyzeSentiment(ctx context.Context, transcript string) (*models.Sentiment, error) {
+	body, err := json.Marshal(map[string]string{"text": transcript})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal sentiment request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sentiment request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("sentiment request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err = io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sentiment response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("sentiment classification failed with status %d: %s", resp.StatusCode, body)
+	}
+
+	var sentiment models.Sentiment
+	if err := json.Unmarshal(body, &sentiment); err != nil {
+		return nil, fmt.Errorf("invalid sentiment response: %w", err)
+	}
+	return normalizeSentiment(&sentiment), nil
+}
+
+// normalizeSentiment clamps the urgency to [0, 1].
+func normalizeSentiment(sentiment *models.Sentiment) *models.Sentiment {
+	sentiment.Urgency = min(max(sentiment.Urgency, 0), 1)
+	return sentiment
+}
+
+// ValidateEscalationPolicies checks policy IDs, conditions, cooldowns and
+// actions.
+func ValidateEscalationPolicies(policies []models.EscalationPolicy) error {
+	seen := make(map[string]bool, len(policies))
+	for _, policy := range policies {
+		if policy.ID == "" {
+			return fmt.Errorf("escalation policy %q has no id", policy.Name)
+		}
+		if seen[policy.ID] {
+			return fmt.Errorf("duplicate escalation policy id %q", policy.ID)
+		}
+		seen[policy.ID] = true
+
+		if len(policy.Emotions) == 0 && policy.MinUrgency == 0 && !policy.Distress {
+			return fmt.Errorf("escalation policy %q has no conditions", policy.ID)
+		}
+		if policy.MinUrgency < 0 || policy.MinUrgency > 1 {
+			return fmt.Errorf("escalation policy %q: min_urgency must be between 0 and 1", policy.ID)
+		}
+		for _, emotion := range policy.Emotions {
+			if !slices.Contains(models.Emotions, emotion) {
+				return fmt.Errorf("escalation policy %q: unknown emotion %q", policy.ID, emotion)
+			}
+		}
+		if _, err := time.ParseDuration(policy.Cooldown); policy.Cooldown != "" && err != nil {
+			return fmt.Errorf("escalation policy %q: invalid duration %q", policy.ID, policy.Cooldown)
+		}
+		for _, action := range policy.Actions {
+			if action != models.RULE_ACTION_ALERT && action != models.RULE_ACTION_ORCHESTRATOR {
+				return fmt.Errorf("escalation policy %q: unknown action %q", policy.ID, action)
+			}
+		}
+	}
+	return nil
+}
+
+// DefaultEscalationPolicies is the policy of tenants without their own:
+// distress at SENTIMENT_ESCALATION_URGENCY (default 0.8) or above alerts
+// the client and the orchestrator. 0 disables it.
+func DefaultEscalationPolicies(cfg *Config) []models.EscalationPolicy {
+	urgency := cfg.SentimentEscalationUrgency
+	if urgency <= 0 {
+		return nil
+	}
+	return []models.EscalationPolicy{{
+		ID:         "distress",
+		Name:       "Distress",
+		MinUrgency: urgency,
+		Distress:   true,
+		Actions:    []string{models.RULE_ACTION_ALERT, models.RULE_ACTION_ORCHESTRATOR},
+	}}
+}
+
+// EscalationMatches reports whether every condition of the policy holds for
+// sentiment.
+func EscalationMatches(policy models.EscalationPolicy, sentiment *models.Sentiment) bool {
+	if sentiment == nil {
+		return false
+	}
+	if len(policy.Emotions) > 0 && !slices.Contains(policy.Emotions, sentiment.Emotion) {
+		return false
+	}
+	if policy.Distress && !sentiment.Distress {
+		return false
+	}
+	return sentiment.Urgency >= policy.MinUrgency
+}
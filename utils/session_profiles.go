@@ -0,0 +1,124 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultSessionProfiles are the profiles offered unless
+// SESSION_PROFILES_FILE points to a YAML list of models.SessionProfile.
+var DefaultSessionProfiles = []models.SessionProfile{
+	{
+		Name:        "voice-only",
+		Description: "Spoken commands without a camera or long-term memory",
+		Modalities:  []string{"audio"},
+		Memory:      profileFlag(false),
+	},
+	{
+		Name:        "vision-only",
+		Description: "Scene analysis and typed commands without a microphone",
+		Modalities:  []string{"video"},
+	},
+	{
+		Name:        "full-duplex",
+		Description: "Speech and camera with live interim transcripts",
+		Modalities:  []string{"audio", "video"},
+		Config: map[string]interface{}{
+			"stt_interim_results":      true,
+			"echo_interim_transcripts": true,
+		},
+	},
+	{
+		Name:        "low-bandwidth",
+		Description: "Speech and sparse frames for constrained links",
+		Modalities:  []string{"audio", "video"},
+		Config: map[string]interface{}{
+			"video_frequency":          "10s",
+			"adaptive_video_frequency": true,
+			"stt_interim_results":      false,
+			"echo_interim_transcripts": false,
+		},
+	},
+}
+
+var sessionProfileModalities = []string{"audio", "video", "text"}
+
+func profileFlag(value bool) *bool {
+	return &value
+}
+
+var (
+	sessionProfilesOnce sync.Once
+	sessionProfiles     []models.SessionProfile
+)
+
+// SessionProfiles returns the profiles clients can select.
+func SessionProfiles() []models.SessionProfile {
+	sessionProfilesOnce.Do(func() {
+		sessionProfiles = DefaultSessionProfiles
+		path := CurrentConfig().SessionProfilesFile
+		if path == "" {
+			return
+		}
+		profiles, err := LoadSessionProfiles(path)
+		if err != nil {
+			zap.L().Error("Failed to load session profiles, using defaults", zap.String("path", path), zap.Error(err))
+			return
+		}
+		sessionProfiles = profiles
+	})
+	return sessionProfiles
+}
+
+// SessionProfile returns the profile named name.
+func SessionProfile(name string) (*models.SessionProfile, bool) {
+	for _, profile := range SessionProfiles() {
+		if profile.Name == name {
+			return &profile, true
+		}
+	}
+	return nil, false
+}
+
+func LoadSessionProfiles(path string) ([]models.SessionProfile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session profiles file: %w", err)
+	}
+	var profiles []models.SessionProfile
+	if err := yaml.Unmarshal(data, &profiles); err != nil {
+		return nil, fmt.Errorf("failed to parse session profiles file: %w", err)
+	}
+	seen := make(map[string]bool, len(profiles))
+	for i, profile := range profiles {
+		if profile.Name == "" {
+			return nil, fmt.Errorf("session profile without a name")
+		}
+		if seen[profile.Name] {
+			return nil, fmt.Errorf("duplicate session profile %s", profile.Name)
+		}
+		seen[profile.Name] = true
+		for _, modality := range profile.Modalities {
+			if !slices.Contains(sessionProfileModalities, modality) {
+				return nil, fmt.Errorf("session profile %s: unknown modality %q", profile.Name, modality)
+			}
+		}
+		// Settings are applied like a config message, which is JSON
+		config, err := json.Marshal(profile.Config)
+		if err != nil {
+			return nil, fmt.Errorf("session profile %s: invalid config: %w", profile.Name, err)
+		}
+		profiles[i].Config = nil
+		if err := json.Unmarshal(config, &profiles[i].Config); err != nil {
+			return nil, fmt.Errorf("session profile %s: invalid config: %w", profile.Name, err)
+		}
+	}
+	return profiles, nil
+}
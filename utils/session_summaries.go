@@ -0,0 +1,160 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	sessionSummaryKeyPrefix = "perceptus:session_summary:"
+	// Per tenant and robot, session IDs scored by end time
+	sessionSummaryIndexPrefix = "perceptus:session_summaries:"
+)
+
+// SessionActivity is what a session summary is written from, oldest first.
+type SessionActivity struct {
+	Duration    time.Duration
+	Requests    []string
+	Actions     []string
+	Environment []string
+}
+
+// Empty reports whether there is nothing to summarize.
+func (a SessionActivity) Empty() bool {
+	return len(a.Requests) == 0 && len(a.Actions) == 0 && len(a.Environment) == 0
+}
+
+func sessionSummaryIndexKey(tenantID, robotID string) string {
+	return sessionSummaryIndexPrefix + tenantID + ":" + robotID
+}
+
+// SaveSessionSummary stores a summary next to the session archive and
+// indexes it under its robot ("" for sessions without robot_id), dropping
+// index entries past the archive retention.
+func SaveSessionSummary(ctx context.Context, rdb *redis.Client, summary models.SessionSummary) error {
+	data, err := marshalArtifact(ctx, summary.TenantID, summary)
+	if err != nil {
+		return fmt.Errorf("failed to encode session summary: %w", err)
+	}
+
+	indexKey := sessionSummaryIndexKey(summary.TenantID, summary.RobotID)
+	expired := summary.EndTime.Add(-sessionArchiveRetention).Unix()
+	pipe := rdb.TxPipeline()
+	pipe.Set(ctx, sessionSummaryKeyPrefix+summary.SessionID, data, sessionArchiveRetention)
+	pipe.ZAdd(ctx, indexKey, redis.Z{Score: float64(summary.EndTime.Unix()), Member: summary.SessionID})
+	pipe.ZRemRangeByScore(ctx, indexKey, "-inf", "("+strconv.FormatInt(expired, 10))
+	pipe.Expire(ctx, indexKey, sessionArchiveRetention)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to save session summary: %w", err)
+	}
+	return nil
+}
+
+// LoadSessionSummary returns a session's summary, or nil when none was
+// written.
+func LoadSessionSummary(ctx context.Context, rdb *redis.Client, sessionID string) (*models.SessionSummary, error) {
+	data, err := rdb.Get(ctx, sessionSummaryKeyPrefix+sessionID).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load session summary: %w", err)
+	}
+	var summary models.SessionSummary
+	if err := unmarshalArtifact(ctx, data, &summary); err != nil {
+		return nil, fmt.Errorf("failed to decode session summary: %w", err)
+	}
+	return &summary, nil
+}
+
+// LoadRobotSessionSummaries returns the summaries of a robot's sessions
+// that ended in [from, to), oldest first.
+func LoadRobotSessionSummaries(ctx context.Context, rdb *redis.Client, tenantID, robotID string, from, to time.Time) ([]models.SessionSummary, error) {
+	sessionIDs, err := rdb.ZRangeByScore(ctx, sessionSummaryIndexKey(tenantID, robotID), &redis.ZRangeBy{
+		Min: strconv.FormatInt(from.Unix(), 10),
+		Max: "(" + strconv.FormatInt(to.Unix(), 10),
+	}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session summary index: %w", err)
+	}
+
+	summaries := make([]models.SessionSummary, 0, len(sessionIDs))
+	for _, sessionID := range sessionIDs {
+		summary, err := LoadSessionSummary(ctx, rdb, sessionID)
+		if err != nil {
+			return nil, err
+		}
+		// Expired while still indexed
+		if summary == nil {
+			continue
+		}
+		summaries = append(summaries, *summary)
+	}
+	return summaries, nil
+}
+
+// SummarizeSession asks the model for an end-of-session summary. Only the
+// text fields of the result are set.
+func (c *OpenAIClient) SummarizeSession(ctx context.Context, activity SessionActivity) (*models.SessionSummary, error) {
+	list := func(items []string) string {
+		if len(items) == 0 {
+			return "(none)\n"
+		}
+		return "- " + strings.Join(items, "\n- ") + "\n"
+	}
+
+	prompt := fmt.Sprintf(`You write the end-of-session report of a household robot for the fleet operator. The session lasted %s.
+
+What the user said and the intention detected for it:
+%s
+Actions triggered:
+%s
+What the robot saw, most recent first:
+%s
+Summarize the session in at most three sentences. Then list what the user asked for, the actions triggered, notable things about the environment, and open follow-ups: requests that were not acted on, failed, or need a human. Keep each item short and factual, and leave lists empty rather than guessing.`,
+		activity.Duration.Round(time.Second), list(activity.Requests), list(activity.Actions), list(activity.Environment))
+
+	message, err := c.completeTask(ctx, MODEL_TASK_SUMMARIZATION, map[string]interface{}{
+		"messages": []GPTMessage{
+			{Role: "user", Content: prompt},
+		},
+		"response_format": sessionSummaryResponseFormat,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var summary models.SessionSummary
+	if err := json.Unmarshal([]byte(message.Content), &summary); err != nil {
+		return nil, fmt.Errorf("failed to parse session summary: %w", err)
+	}
+	return &summary, nil
+}
+
+var sessionSummaryResponseFormat = map[string]interface{}{
+	"type": "json_schema",
+	"json_schema": map[string]interface{}{
+		"name":   "session_summary",
+		"strict": true,
+		"schema": map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"summary":                map[string]interface{}{"type": "string"},
+				"user_requests":          map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+				"actions":                map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+				"environment_highlights": map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+				"follow_ups":             map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+			},
+			"required":             []string{"summary", "user_requests", "actions", "environment_highlights", "follow_ups"},
+			"additionalProperties": false,
+		},
+	},
+}
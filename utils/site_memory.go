@@ -0,0 +1,153 @@
+package utils
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
+	"github.com/redis/go-redis/v9"
+)
+
+// SITE_MEMORY_RECORD_TYPE is the memory store type of site observations,
+// kept next to the environment contexts they were merged from.
+const SITE_MEMORY_RECORD_TYPE = "site_memory"
+
+const siteMemoryKeyPrefix = "perceptus:site_memory:"
+
+// SiteLocationTag normalizes a location tag, so "Loading Dock",
+// "loading_dock" and "loading-dock " reported by different robots name one
+// location.
+func SiteLocationTag(location string) string {
+	location = strings.NewReplacer("_", " ", "-", " ").Replace(strings.ToLower(location))
+	return strings.Join(strings.Fields(location), " ")
+}
+
+// SiteObservationID derives a stable ID from the site and location, so a
+// new observation of a location replaces its record.
+func SiteObservationID(site, location string) string {
+	sum := sha256.Sum256([]byte(site + "\x00" + location))
+	return "site-" + hex.EncodeToString(sum[:8])
+}
+
+// siteMemoryKey is a hash of the site's observations by location tag.
+func siteMemoryKey(tenantID, site string) string {
+	return siteMemoryKeyPrefix + tenantID + ":" + site
+}
+
+// LoadSiteMemory returns the observations of a site, most recently observed
+// first.
+func LoadSiteMemory(ctx context.Context, rdb *redis.Client, tenantID, site string) ([]models.SiteObservation, error) {
+	stored, err := rdb.HGetAll(ctx, siteMemoryKey(tenantID, site)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load site memory: %w", err)
+	}
+	observations := make([]models.SiteObservation, 0, len(stored))
+	for location, data := range stored {
+		var observation models.SiteObservation
+		if err := unmarshalArtifact(ctx, []byte(data), &observation); err != nil {
+			return nil, fmt.Errorf("failed to decode site observation %q: %w", location, err)
+		}
+		observations = append(observations, observation)
+	}
+	sort.Slice(observations, func(i, j int) bool { return observations[i].ObservedAt.After(observations[j].ObservedAt) })
+	return observations, nil
+}
+
+// LoadSiteObservation returns the observation of one location, nil when no
+// robot has seen it.
+func LoadSiteObservation(ctx context.Context, rdb *redis.Client, tenantID, site, location string) (*models.SiteObservation, error) {
+	data, err := rdb.HGet(ctx, siteMemoryKey(tenantID, site), SiteLocationTag(location)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load site observation: %w", err)
+	}
+	var observation models.SiteObservation
+	if err := unmarshalArtifact(ctx, data, &observation); err != nil {
+		return nil, fmt.Errorf("failed to decode site observation: %w", err)
+	}
+	return &observation, nil
+}
+
+// MergeSiteObservation merges an observation into the site's memory in an
+// optimistic transaction: the newer description of a location wins and the
+// observation count carries over. The site expires ttl after its last
+// observation (0 keeps it). It returns the stored observation.
+func MergeSiteObservation(ctx context.Context, rdb *redis.Client, tenantID string, observation models.SiteObservation, ttl time.Duration) (models.SiteObservation, error) {
+	observation.Location = SiteLocationTag(observation.Location)
+	if observation.Site == "" || observation.Location == "" {
+		return observation, fmt.Errorf("site observation needs a site and a location")
+	}
+	key := siteMemoryKey(tenantID, observation.Site)
+
+	var merged models.SiteObservation
+	txn := func(tx *redis.Tx) error {
+		merged = observation
+		merged.Observations = 1
+		data, err := tx.HGet(ctx, key, observation.Location).Bytes()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return err
+		}
+		if err == nil {
+			var known models.SiteObservation
+			if err := unmarshalArtifact(ctx, data, &known); err != nil {
+				return fmt.Errorf("failed to decode site observation: %w", err)
+			}
+			if known.ObservedAt.After(observation.ObservedAt) {
+				// A late write of an older frame only counts
+				merged = known
+			}
+			merged.Observations = known.Observations + 1
+		}
+
+		stored, err := marshalArtifact(ctx, tenantID, merged)
+		if err != nil {
+			return fmt.Errorf("failed to encode site observation: %w", err)
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.HSet(ctx, key, observation.Location, stored)
+			if ttl > 0 {
+				pipe.Expire(ctx, key, ttl)
+			}
+			return nil
+		})
+		return err
+	}
+
+	for attempt := 0; attempt < 5; attempt++ {
+		err := rdb.Watch(ctx, txn, key)
+		if errors.Is(err, redis.TxFailedErr) {
+			continue
+		}
+		if err != nil {
+			return merged, fmt.Errorf("failed to save site observation: %w", err)
+		}
+		return merged, nil
+	}
+	return merged, fmt.Errorf("failed to save site observation: too many concurrent updates")
+}
+
+// FormatSiteObservation describes an observation for the memory store and
+// intention analysis, naming the robot that saw the location.
+func FormatSiteObservation(observation models.SiteObservation) string {
+	var b strings.Builder
+	b.WriteString(observation.Location)
+	if observation.RobotID != "" {
+		fmt.Fprintf(&b, " (seen by robot %s)", observation.RobotID)
+	}
+	b.WriteString(": " + observation.Overview)
+	if len(observation.KeyElements) > 0 {
+		b.WriteString(" Key elements: " + strings.Join(observation.KeyElements, ", ") + ".")
+	}
+	if observation.Layout != "" {
+		b.WriteString(" Layout: " + observation.Layout)
+	}
+	return b.String()
+}
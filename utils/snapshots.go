@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
+	"github.com/redis/go-redis/v9"
+)
+
+const sessionSnapshotKeyPrefix = "perceptus:session_snapshot:"
+
+func SaveSessionSnapshot(ctx context.Context, rdb *redis.Client, snapshot models.SessionSnapshot, ttl time.Duration) error {
+	data, err := marshalArtifact(ctx, snapshot.TenantID, snapshot)
+	if err != nil {
+		return fmt.Errorf("failed to encode session snapshot: %w", err)
+	}
+	if err := rdb.Set(ctx, sessionSnapshotKeyPrefix+snapshot.SessionID, data, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to save session snapshot: %w", err)
+	}
+	return nil
+}
+
+// ErrSnapshotNotClaimed is returned by ClaimSessionSnapshot when there is no
+// snapshot of the tenant to claim, or another connection claimed it first.
+var ErrSnapshotNotClaimed = errors.New("session snapshot not claimed")
+
+// ClaimSessionSnapshot loads a tenant's snapshot and deletes it in one
+// transaction, so a session is resumed by at most one connection. Snapshots
+// of other tenants are left alone.
+func ClaimSessionSnapshot(ctx context.Context, rdb *redis.Client, tenantID, sessionID string) (*models.SessionSnapshot, error) {
+	key := sessionSnapshotKeyPrefix + sessionID
+	var snapshot models.SessionSnapshot
+	err := rdb.Watch(ctx, func(tx *redis.Tx) error {
+		data, err := tx.Get(ctx, key).Bytes()
+		if errors.Is(err, redis.Nil) {
+			return ErrSnapshotNotClaimed
+		}
+		if err != nil {
+			return err
+		}
+		if err := unmarshalArtifact(ctx, data, &snapshot); err != nil {
+			return fmt.Errorf("failed to decode session snapshot: %w", err)
+		}
+		if snapshot.TenantID != tenantID {
+			return ErrSnapshotNotClaimed
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Del(ctx, key)
+			return nil
+		})
+		return err
+	}, key)
+	if errors.Is(err, redis.TxFailedErr) {
+		return nil, ErrSnapshotNotClaimed
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+func DeleteSessionSnapshot(ctx context.Context, rdb *redis.Client, sessionID string) error {
+	return rdb.Del(ctx, sessionSnapshotKeyPrefix+sessionID).Err()
+}
@@ -0,0 +1,159 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+)
+
+// Sound events the acoustic event detector reports.
+const (
+	SOUND_EVENT_DOORBELL       = "doorbell"
+	SOUND_EVENT_ALARM          = "alarm"
+	SOUND_EVENT_GLASS_BREAKING = "glass_breaking"
+	SOUND_EVENT_CRYING         = "crying"
+	SOUND_EVENT_DOG_BARK       = "dog_bark"
+)
+
+var soundEvents = []string{SOUND_EVENT_DOORBELL, SOUND_EVENT_ALARM, SOUND_EVENT_GLASS_BREAKING, SOUND_EVENT_CRYING, SOUND_EVENT_DOG_BARK}
+
+// soundEventClasses maps the AudioSet classes YAMNet scores to sound
+// events; other classes (speech, music, vehicles, ...) are ignored.
+var soundEventClasses = map[string]string{
+	"Doorbell":                    SOUND_EVENT_DOORBELL,
+	"Ding-dong":                   SOUND_EVENT_DOORBELL,
+	"Alarm":                       SOUND_EVENT_ALARM,
+	"Smoke detector, smoke alarm": SOUND_EVENT_ALARM,
+	"Fire alarm":                  SOUND_EVENT_ALARM,
+	"Car alarm":                   SOUND_EVENT_ALARM,
+	"Siren":                       SOUND_EVENT_ALARM,
+	"Civil defense siren":         SOUND_EVENT_ALARM,
+	"Shatter":                     SOUND_EVENT_GLASS_BREAKING,
+	"Breaking":                    SOUND_EVENT_GLASS_BREAKING,
+	"Crying, sobbing":             SOUND_EVENT_CRYING,
+	"Baby cry, infant cry":        SOUND_EVENT_CRYING,
+	"Bark":                        SOUND_EVENT_DOG_BARK,
+	"Bow-wow":                     SOUND_EVENT_DOG_BARK,
+	"Yip":                         SOUND_EVENT_DOG_BARK,
+}
+
+// ParseSoundEvents reads a comma-separated list of sound events.
+func ParseSoundEvents(value string) ([]string, error) {
+	events := splitTerms(value)
+	for _, event := range events {
+		if !slices.Contains(soundEvents, event) {
+			return nil, fmt.Errorf("unknown sound event %q", event)
+		}
+	}
+	return events, nil
+}
+
+// SoundScore is a classifier's score for one class of a window.
+type SoundScore struct {
+	Label string  `json:"label"`
+	Score float64 `json:"score"`
+}
+
+// SoundEvent is a sound event detected in a window: the best scoring class
+// that maps to it.
+type SoundEvent struct {
+	Event string
+	Label string
+	Score float64
+}
+
+// SoundEventsFromScores maps class scores to the events listed, keeping
+// the best class per event at or above minScore.
+func SoundEventsFromScores(scores []SoundScore, events []string, minScore float64) []SoundEvent {
+	best := make(map[string]SoundEvent)
+	for _, score := range scores {
+		event, ok := soundEventClasses[score.Label]
+		if !ok || score.Score < minScore || !slices.Contains(events, event) {
+			continue
+		}
+		if current, ok := best[event]; !ok || score.Score > current.Score {
+			best[event] = SoundEvent{Event: event, Label: score.Label, Score: score.Score}
+		}
+	}
+	detected := make([]SoundEvent, 0, len(best))
+	for _, event := range events {
+		if found, ok := best[event]; ok {
+			detected = append(detected, found)
+		}
+	}
+	return detected
+}
+
+// SoundClassifier scores a window of mono linear16 audio against the
+// AudioSet classes.
+type SoundClassifier interface {
+	Classify(ctx context.Context, pcm []byte, sampleRate int) ([]SoundScore, error)
+}
+
+// HTTPSoundClassifier posts windows as WAV files to a classification
+// service, such as YAMNet behind TensorFlow Serving or an ONNX runtime
+// server, which answers {"scores":[{"label":"Bark","score":0.91}, ...]}.
+type HTTPSoundClassifier struct {
+	url    string
+	apiKey string
+	http   *http.Client
+}
+
+// NewSoundClassifierFromConfig returns the classifier at ACOUSTIC_EVENT_URL,
+// authenticated with ACOUSTIC_EVENT_API_KEY when set.
+func NewSoundClassifierFromConfig(cfg *Config) (*HTTPSoundClassifier, error) {
+	url := cfg.AcousticEventURL
+	if url == "" {
+		return nil, fmt.Errorf("ACOUSTIC_EVENT_URL not configured")
+	}
+	return &HTTPSoundClassifier{
+		url:    url,
+		apiKey: cfg.AcousticEventAPIKey,
+		http:   &http.Client{Timeout: cfg.AcousticEventTimeout},
+	}, nil
+}
+
+func (c *HTTPSoundClassifier) Classify(ctx context.Context, pcm []byte, sampleRate int) ([]SoundScore, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(wavFile(pcm, sampleRate)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sound classification request: %w", err)
+	}
+	req.Header.Set("Content-Type", "audio/wav")
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("sound classification request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sound classification response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("sound classification failed with status %d: %s", resp.StatusCode, body)
+	}
+
+	var result struct {
+		Scores []SoundScore `json:"scores"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("invalid sound classification response: %w", err)
+	}
+	return result.Scores, nil
+}
+
+// wavFile wraps mono linear16 samples in a WAV file.
+func wavFile(pcm []byte, sampleRate int) []byte {
+	header := wavHeader(1, 16, uint32(sampleRate))
+	binary.LittleEndian.PutUint32(header[4:], uint32(36+len(pcm)))
+	binary.LittleEndian.PutUint32(header[40:], uint32(len(pcm)))
+	return append(header, pcm...)
+}
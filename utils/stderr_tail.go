@@ -0,0 +1,38 @@
+package utils
+
+import (
+	"strings"
+	"sync"
+)
+
+// maxStderrTail bounds what is kept of a long-running process's stderr.
+const maxStderrTail = 4096
+
+// stderrTail keeps the last maxStderrTail bytes written by a process, which
+// hold the error it exited with, so a process logging on every corrupt
+// packet cannot grow the server's memory.
+type stderrTail struct {
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (t *stderrTail) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(p) >= maxStderrTail {
+		t.buf = append(t.buf[:0], p[len(p)-maxStderrTail:]...)
+		return len(p), nil
+	}
+	if overflow := len(t.buf) + len(p) - maxStderrTail; overflow > 0 {
+		t.buf = append(t.buf[:0], t.buf[overflow:]...)
+	}
+	t.buf = append(t.buf, p...)
+	return len(p), nil
+}
+
+// String returns the kept output without surrounding whitespace.
+func (t *stderrTail) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return strings.TrimSpace(string(t.buf))
+}
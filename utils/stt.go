@@ -0,0 +1,324 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
+	"github.com/Perceptus-Labs/perceptus-go-sdk/testmode"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+const (
+	STT_PROVIDER_DEEPGRAM   = "deepgram"
+	STT_PROVIDER_ASSEMBLYAI = "assemblyai"
+	STT_PROVIDER_AZURE      = "azure"
+	STT_PROVIDER_VOSK       = "vosk"
+)
+
+// END_OF_SPEECH is sent on the transcription channel when the speaker
+// finished an utterance.
+const END_OF_SPEECH = "<END_OF_SPEECH>"
+
+// SpeechToText is a streaming speech-to-text connection. Final segments are
+// sent to the transcription channel as they are recognized, followed by
+// END_OF_SPEECH once the utterance ended.
+type SpeechToText interface {
+	// Provider names the backend for logs and metrics
+	Provider() string
+	// Connect opens the stream and reports whether it succeeded
+	Connect() bool
+	// Disconnected is closed once the stream is lost
+	Disconnected() <-chan struct{}
+	IsConnected() bool
+	Send(data []byte) error
+	// KeepAlive pings the open stream so a dropped connection is noticed
+	KeepAlive() error
+	// Finalize flushes the audio sent so far, for when no more audio follows
+	// an utterance (e.g. voice activity detection closed the gate); the
+	// final transcript and END_OF_SPEECH arrive as usual
+	Finalize() error
+	Close()
+}
+
+// STTStreamConfig describes a stream independently of the backend. Model is
+// the backend's model name, empty for its default.
+type STTStreamConfig struct {
+	Model               string
+	Language            string
+	ConfidenceThreshold float64
+	Format              AudioFormat
+	Options             STTOptions
+	TranscriptionCh     chan string
+}
+
+// STTProviderOf returns the tenant's speech-to-text backend, Deepgram unless
+// stt_provider (or STT_PROVIDER) selects another.
+func STTProviderOf(tenant *models.Tenant) string {
+	if tenant.STTProvider == "" {
+		return STT_PROVIDER_DEEPGRAM
+	}
+	return strings.ToLower(tenant.STTProvider)
+}
+
+// NewSpeechToText creates an unconnected stream for the tenant's backend.
+func NewSpeechToText(tenant *models.Tenant, config STTStreamConfig) (SpeechToText, error) {
+	switch provider := STTProviderOf(tenant); provider {
+	case STT_PROVIDER_DEEPGRAM:
+		return InitDeepgramClient(
+			tenant.DeepgramAPIKey,
+			config.Model,
+			config.Language,
+			strconv.FormatFloat(config.ConfidenceThreshold, 'f', -1, 64),
+			config.Format,
+			config.Options,
+			config.TranscriptionCh,
+		), nil
+	case STT_PROVIDER_ASSEMBLYAI:
+		return NewAssemblyAIClient(tenant.AssemblyAIAPIKey, config)
+	case STT_PROVIDER_AZURE:
+		return NewAzureSpeechClient(tenant.AzureSpeechKey, tenant.AzureSpeechRegion, config)
+	case STT_PROVIDER_VOSK:
+		return NewVoskClient(config)
+	default:
+		sttProvidersMu.RLock()
+		factory, ok := sttProviders[provider]
+		sttProvidersMu.RUnlock()
+		if !ok {
+			return nil, fmt.Errorf("unknown STT provider %q", provider)
+		}
+		return factory(tenant, config)
+	}
+}
+
+// STTFactory creates an unconnected stream of a registered backend.
+type STTFactory func(tenant *models.Tenant, config STTStreamConfig) (SpeechToText, error)
+
+var (
+	sttProvidersMu sync.RWMutex
+	sttProviders   = make(map[string]STTFactory)
+)
+
+// RegisterSTTProvider adds a speech-to-text backend that tenants select by
+// name with stt_provider, such as an in-house recognizer or a test double.
+// The built-in backends cannot be replaced.
+func RegisterSTTProvider(name string, factory STTFactory) error {
+	name = strings.ToLower(name)
+	switch name {
+	case "", STT_PROVIDER_DEEPGRAM, STT_PROVIDER_ASSEMBLYAI, STT_PROVIDER_AZURE, STT_PROVIDER_VOSK:
+		return fmt.Errorf("STT provider name %q is reserved", name)
+	}
+	sttProvidersMu.Lock()
+	defer sttProvidersMu.Unlock()
+	sttProviders[name] = factory
+	return nil
+}
+
+// plainTerms merges keyterms and keywords into plain phrases for backends
+// without Deepgram's "word:intensifier" syntax.
+func plainTerms(options STTOptions) []string {
+	terms := append([]string(nil), options.Keyterms...)
+	for _, keyword := range options.Keywords {
+		if word, _, ok := strings.Cut(keyword, ":"); ok {
+			keyword = word
+		}
+		terms = append(terms, keyword)
+	}
+	return terms
+}
+
+// sttStream holds the plumbing shared by the backends other than Deepgram:
+// the WebSocket connection of AssemblyAI and Azure, the disconnection
+// signal, and transcript delivery with emit.
+type sttStream struct {
+	provider string
+	config   STTStreamConfig
+	conn     *websocket.Conn
+	writeMu  sync.Mutex
+
+	disconnected     chan struct{}
+	disconnectedOnce sync.Once
+
+	endMu    sync.Mutex
+	endTimer *time.Timer
+}
+
+func newSTTStream(provider string, config STTStreamConfig) sttStream {
+	return sttStream{provider: provider, config: config, disconnected: make(chan struct{})}
+}
+
+func (s *sttStream) Provider() string {
+	return s.provider
+}
+
+// dial opens the connection and starts handle on every message received.
+func (s *sttStream) dial(url string, header http.Header, handle func(messageType int, data []byte)) bool {
+	if err := testmode.Inject(context.Background(), testmode.TARGET_STT); err != nil {
+		zap.L().Error("Failed to connect to speech-to-text stream", zap.String("provider", s.provider), zap.Error(err))
+		s.markDisconnected()
+		return false
+	}
+	dialer := websocket.Dialer{HandshakeTimeout: 10 * time.Second, Proxy: http.ProxyFromEnvironment}
+	conn, resp, err := dialer.Dial(url, header)
+	if err != nil {
+		fields := []zap.Field{zap.String("provider", s.provider), zap.Error(err)}
+		if resp != nil {
+			fields = append(fields, zap.Int("status", resp.StatusCode))
+		}
+		zap.L().Error("Failed to connect to speech-to-text stream", fields...)
+		s.markDisconnected()
+		return false
+	}
+	s.conn = conn
+
+	go func() {
+		defer s.markDisconnected()
+		for {
+			messageType, data, err := conn.ReadMessage()
+			if err != nil {
+				if s.IsConnected() {
+					zap.L().Info("Speech-to-text stream closed", zap.String("provider", s.provider), zap.Error(err))
+				}
+				return
+			}
+			handle(messageType, data)
+		}
+	}()
+	return true
+}
+
+func (s *sttStream) Disconnected() <-chan struct{} {
+	return s.disconnected
+}
+
+func (s *sttStream) IsConnected() bool {
+	select {
+	case <-s.disconnected:
+		return false
+	default:
+		return true
+	}
+}
+
+func (s *sttStream) markDisconnected() {
+	s.disconnectedOnce.Do(func() {
+		close(s.disconnected)
+	})
+}
+
+func (s *sttStream) write(messageType int, data []byte) error {
+	if s.conn == nil || !s.IsConnected() {
+		return fmt.Errorf("%s stream not connected", s.provider)
+	}
+	if err := testmode.Inject(context.Background(), testmode.TARGET_STT); err != nil {
+		s.markDisconnected()
+		return err
+	}
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	s.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	if err := s.conn.WriteMessage(messageType, data); err != nil {
+		s.markDisconnected()
+		return fmt.Errorf("failed to write to %s stream: %w", s.provider, err)
+	}
+	return nil
+}
+
+func (s *sttStream) KeepAlive() error {
+	if s.conn == nil || !s.IsConnected() {
+		return fmt.Errorf("%s stream not connected", s.provider)
+	}
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	if err := s.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(5*time.Second)); err != nil {
+		s.markDisconnected()
+		return err
+	}
+	return nil
+}
+
+// close marks the stream disconnected and closes the connection, sending
+// final first so the backend can flush pending results.
+func (s *sttStream) close(final func() error) {
+	if s.conn == nil {
+		s.markDisconnected()
+		return
+	}
+	if s.IsConnected() && final != nil {
+		if err := final(); err != nil {
+			zap.L().Debug("Failed to end speech-to-text stream", zap.String("provider", s.provider), zap.Error(err))
+		}
+	}
+	s.markDisconnected()
+	s.writeMu.Lock()
+	s.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+	s.writeMu.Unlock()
+	s.conn.Close()
+}
+
+// emit sends a final transcript unless it is empty or below the confidence
+// threshold; confidence < 0 means the backend reported none.
+func (s *sttStream) emit(transcript string, confidence float64) {
+	transcript = strings.TrimSpace(transcript)
+	if transcript == "" {
+		return
+	}
+	if testmode.Malformed(testmode.TARGET_STT) {
+		transcript = "\ufffd\ufffd " + transcript
+	}
+	if confidence >= 0 && confidence < s.config.ConfidenceThreshold {
+		zap.L().Debug("Discarding low confidence transcript", zap.String("transcript", transcript))
+		return
+	}
+	zap.L().Debug("Final transcript received", zap.String("provider", s.provider), zap.String("transcript", transcript))
+	s.config.TranscriptionCh <- transcript
+}
+
+// endsSpeech reports whether the options ask for end-of-speech signaling;
+// with both endpointing and utterance end disabled, transcripts are only
+// flushed by length or time, as with Deepgram.
+func (s *sttStream) endsSpeech() bool {
+	return s.config.Options.EndpointingMs > 0 || s.config.Options.UtteranceEndMs > 0
+}
+
+// scheduleEndOfSpeech emulates UtteranceEnd for backends without it: after a
+// final segment, END_OF_SPEECH follows once the utterance end gap, less the
+// endpointing silence already waited for, passes without new speech.
+func (s *sttStream) scheduleEndOfSpeech() {
+	options := s.config.Options
+	if !s.endsSpeech() {
+		return
+	}
+	if options.UtteranceEndMs == 0 {
+		s.config.TranscriptionCh <- END_OF_SPEECH
+		return
+	}
+
+	wait := time.Duration(max(options.UtteranceEndMs-options.EndpointingMs, 0)) * time.Millisecond
+	s.endMu.Lock()
+	defer s.endMu.Unlock()
+	if s.endTimer != nil {
+		s.endTimer.Stop()
+	}
+	s.endTimer = time.AfterFunc(wait, func() {
+		if s.IsConnected() {
+			s.config.TranscriptionCh <- END_OF_SPEECH
+		}
+	})
+}
+
+// cancelEndOfSpeech is called when speech resumes.
+func (s *sttStream) cancelEndOfSpeech() {
+	s.endMu.Lock()
+	defer s.endMu.Unlock()
+	if s.endTimer != nil {
+		s.endTimer.Stop()
+		s.endTimer = nil
+	}
+}
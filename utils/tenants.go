@@ -0,0 +1,453 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
+	"github.com/lpernett/godotenv"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+const tenantUsageKeyPrefix = "perceptus:tenant_usage:"
+
+// TenantStore resolves API keys to tenants. Without a TENANTS_FILE the store
+// runs in single-tenant mode: every connection maps to the default tenant,
+// authenticated by one of the API_KEYS.
+type TenantStore struct {
+	mu       sync.RWMutex
+	path     string
+	byAPIKey map[string]*models.Tenant
+	byID     map[string]*models.Tenant
+	fallback *models.Tenant
+	redis    *redis.Client
+}
+
+// DefaultTenant builds the tenant described by the server settings. A
+// rules, watches or routes file that cannot be loaded, or invalid
+// TRANSCRIPT_FILTERS, is an error like an invalid tenants file.
+func DefaultTenant(cfg *Config) (*models.Tenant, error) {
+	var rules []models.TriggerRule
+	if path := cfg.TriggerRulesFile; path != "" {
+		loaded, err := LoadTriggerRules(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load TRIGGER_RULES_FILE: %w", err)
+		}
+		rules = loaded
+	}
+	var watches []models.ChangeWatch
+	if path := cfg.ChangeWatchesFile; path != "" {
+		loaded, err := LoadChangeWatches(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load CHANGE_WATCHES_FILE: %w", err)
+		}
+		watches = loaded
+	}
+	filters, err := ParseTranscriptFilters(cfg.TranscriptFilters)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TRANSCRIPT_FILTERS: %w", err)
+	}
+	var routes []models.OrchestratorRoute
+	if path := cfg.OrchestratorRoutesFile; path != "" {
+		loaded, err := LoadOrchestratorRoutes(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load ORCHESTRATOR_ROUTES_FILE: %w", err)
+		}
+		routes = loaded
+	}
+
+	return &models.Tenant{
+		ID:                 models.DEFAULT_TENANT_ID,
+		Name:               "Default",
+		APIKeys:            splitTerms(cfg.APIKeys),
+		OpenAIAPIKey:       cfg.OpenAIAPIKey,
+		DeepgramAPIKey:     cfg.DeepgramAPIKey,
+		STTProvider:        cfg.STTProvider,
+		AssemblyAIAPIKey:   cfg.AssemblyAIAPIKey,
+		AzureSpeechKey:     cfg.AzureSpeechKey,
+		AzureSpeechRegion:  cfg.AzureSpeechRegion,
+		PineconeAPIKey:     cfg.PineconeAPIKey,
+		PineconeHost:       cfg.PineconeHost,
+		PineconeNamespace:  cfg.PineconeNamespace,
+		OrchestratorURL:    cfg.OrchestratorURL,
+		OrchestratorAPIKey: cfg.OrchestratorAPIKey,
+		OrchestratorAuth:   orchestratorAuthFromConfig(cfg),
+		OrchestratorRoutes: routes,
+		WebhookURLs:        splitTerms(cfg.WebhookURLs),
+		WebhookSecret:      cfg.WebhookSecret,
+		EncryptionKeyID:    cfg.EncryptionKeyID,
+		TranscriptFilters:  filters,
+		ProfanityWords:     splitTerms(cfg.TranscriptProfanityWords),
+		TriggerRules:       rules,
+		ChangeWatches:      watches,
+	}, nil
+}
+
+// orchestratorAuthFromConfig reads the ORCHESTRATOR_AUTH_SCHEME,
+// ORCHESTRATOR_HMAC_*, ORCHESTRATOR_OAUTH_* and ORCHESTRATOR_TLS_* settings.
+func orchestratorAuthFromConfig(cfg *Config) *models.OrchestratorAuth {
+	auth := &models.OrchestratorAuth{
+		Scheme:       cfg.OrchestratorAuthScheme,
+		HMACSecret:   cfg.OrchestratorHMACSecret,
+		HMACHeader:   cfg.OrchestratorHMACHeader,
+		TokenURL:     cfg.OrchestratorOAuthTokenURL,
+		ClientID:     cfg.OrchestratorOAuthClientID,
+		ClientSecret: cfg.OrchestratorOAuthClientSecret,
+		TLSCertFile:  cfg.OrchestratorTLSCertFile,
+		TLSKeyFile:   cfg.OrchestratorTLSKeyFile,
+		TLSCAFile:    cfg.OrchestratorTLSCAFile,
+	}
+	if scopes := cfg.OrchestratorOAuthScopes; scopes != "" {
+		auth.Scopes = strings.Split(scopes, ",")
+	}
+	if auth.Scheme == "" && auth.TLSCertFile == "" && auth.TLSCAFile == "" {
+		return nil
+	}
+	return auth
+}
+
+func NewTenantStore(redisClient *redis.Client) (*TenantStore, error) {
+	cfg := CurrentConfig()
+	store := &TenantStore{
+		path:     cfg.TenantsFile,
+		byAPIKey: make(map[string]*models.Tenant),
+		byID:     make(map[string]*models.Tenant),
+		redis:    redisClient,
+	}
+
+	if store.path == "" {
+		zap.L().Info("TENANTS_FILE not set, running in single-tenant mode")
+	}
+	index, err := store.index(cfg)
+	if err != nil {
+		return nil, err
+	}
+	store.install(index)
+	return store, nil
+}
+
+// Reload re-reads the .env file, the config file and the tenants file so
+// rotated provider credentials apply to new sessions and to subsequent
+// provider calls of live sessions, and other settings to new sessions.
+// Calls already in flight finish with the credentials they started with.
+// On error the previous configuration stays active.
+func (s *TenantStore) Reload() error {
+	if err := godotenv.Overload(); err != nil {
+		zap.L().Warn("Error reloading .env file", zap.Error(err))
+	}
+	path := ConfigFilePath()
+	cfg, unknown, err := LoadConfig(path)
+	if cfg == nil {
+		return err
+	}
+	if err != nil {
+		zap.L().Warn("Invalid configuration after reload, affected settings use their defaults", zap.Error(err))
+	}
+	if len(unknown) > 0 {
+		zap.L().Warn("Unknown settings in config file", zap.String("path", path), zap.Strings("settings", unknown))
+	}
+	// Nothing is installed until the tenants built from the new settings
+	// are valid
+	index, err := s.index(cfg)
+	if err != nil {
+		return err
+	}
+	SetConfig(cfg)
+	RecordModelVersions()
+	s.install(index)
+	if s.path == "" {
+		zap.L().Info("Reloaded provider credentials from environment")
+	}
+	// Pick up added encryption keys and tenants moved to a new key
+	return ConfigureArtifactEncryption(s)
+}
+
+// tenantIndex is the lookup state of a TenantStore, built before it
+// replaces the current one.
+type tenantIndex struct {
+	byAPIKey map[string]*models.Tenant
+	byID     map[string]*models.Tenant
+	fallback *models.Tenant
+}
+
+// index builds the tenants from the tenants file, or the single-tenant mode
+// tenant from cfg.
+func (s *TenantStore) index(cfg *Config) (*tenantIndex, error) {
+	if s.path != "" {
+		return readTenantsFile(s.path, cfg)
+	}
+	tenant, err := DefaultTenant(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return fallbackIndex(tenant)
+}
+
+func (s *TenantStore) install(index *tenantIndex) {
+	s.mu.Lock()
+	s.byAPIKey = index.byAPIKey
+	s.byID = index.byID
+	s.fallback = index.fallback
+	s.mu.Unlock()
+}
+
+// fallbackIndex indexes the single-tenant mode tenant, which must have API
+// keys: no request is served without one.
+func fallbackIndex(tenant *models.Tenant) (*tenantIndex, error) {
+	if len(tenant.APIKeys) == 0 {
+		return nil, fmt.Errorf("API_KEYS must be set when TENANTS_FILE is not")
+	}
+	byAPIKey := make(map[string]*models.Tenant, len(tenant.APIKeys))
+	for _, key := range tenant.APIKeys {
+		byAPIKey[key] = tenant
+	}
+	return &tenantIndex{byAPIKey: byAPIKey, byID: make(map[string]*models.Tenant), fallback: tenant}, nil
+}
+
+// Current returns the latest configuration of a tenant, or nil if it no
+// longer exists.
+func (s *TenantStore) Current(tenantID string) *models.Tenant {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.fallback != nil {
+		return s.fallback
+	}
+	return s.byID[tenantID]
+}
+
+// All returns every configured tenant.
+func (s *TenantStore) All() []*models.Tenant {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.fallback != nil {
+		return []*models.Tenant{s.fallback}
+	}
+	tenants := make([]*models.Tenant, 0, len(s.byID))
+	for _, tenant := range s.byID {
+		tenants = append(tenants, tenant)
+	}
+	return tenants
+}
+
+// Load (re)reads the tenants file, a JSON array of tenants.
+func (s *TenantStore) Load(path string) error {
+	index, err := readTenantsFile(path, CurrentConfig())
+	if err != nil {
+		return err
+	}
+	s.install(index)
+	return nil
+}
+
+// readTenantsFile reads a tenants file, filling in unset credentials from
+// the default tenant of cfg.
+func readTenantsFile(path string, cfg *Config) (*tenantIndex, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tenants file: %w", err)
+	}
+
+	var tenants []*models.Tenant
+	if err := json.Unmarshal(data, &tenants); err != nil {
+		return nil, fmt.Errorf("failed to parse tenants file: %w", err)
+	}
+
+	defaults, err := DefaultTenant(cfg)
+	if err != nil {
+		return nil, err
+	}
+	byAPIKey := make(map[string]*models.Tenant)
+	byID := make(map[string]*models.Tenant, len(tenants))
+	for _, tenant := range tenants {
+		if tenant.ID == "" {
+			return nil, fmt.Errorf("tenant without id in %s", path)
+		}
+		if err := ValidateTriggerRules(tenant.TriggerRules); err != nil {
+			return nil, fmt.Errorf("tenant %s: %w", tenant.ID, err)
+		}
+		if err := ValidateChangeWatches(tenant.ChangeWatches); err != nil {
+			return nil, fmt.Errorf("tenant %s: %w", tenant.ID, err)
+		}
+		if err := ValidateEscalationPolicies(tenant.EscalationPolicies); err != nil {
+			return nil, fmt.Errorf("tenant %s: %w", tenant.ID, err)
+		}
+		if err := ValidateOrchestratorRoutes(tenant.OrchestratorRoutes); err != nil {
+			return nil, fmt.Errorf("tenant %s: %w", tenant.ID, err)
+		}
+		if err := ValidateTranscriptFilters(tenant.TranscriptFilters); err != nil {
+			return nil, fmt.Errorf("tenant %s: %w", tenant.ID, err)
+		}
+		applyTenantDefaults(tenant, defaults)
+		byID[tenant.ID] = tenant
+		for _, key := range tenant.APIKeys {
+			if _, exists := byAPIKey[key]; exists {
+				return nil, fmt.Errorf("api key assigned to more than one tenant (tenant %s)", tenant.ID)
+			}
+			byAPIKey[key] = tenant
+		}
+	}
+
+	zap.L().Info("Loaded tenants", zap.Int("tenants", len(tenants)), zap.String("path", path))
+	return &tenantIndex{byAPIKey: byAPIKey, byID: byID}, nil
+}
+
+func applyTenantDefaults(tenant, defaults *models.Tenant) {
+	if tenant.OpenAIAPIKey == "" {
+		tenant.OpenAIAPIKey = defaults.OpenAIAPIKey
+	}
+	if tenant.DeepgramAPIKey == "" {
+		tenant.DeepgramAPIKey = defaults.DeepgramAPIKey
+	}
+	if tenant.STTProvider == "" {
+		tenant.STTProvider = defaults.STTProvider
+	}
+	if tenant.AssemblyAIAPIKey == "" {
+		tenant.AssemblyAIAPIKey = defaults.AssemblyAIAPIKey
+	}
+	if tenant.AzureSpeechKey == "" {
+		tenant.AzureSpeechKey = defaults.AzureSpeechKey
+	}
+	if tenant.AzureSpeechRegion == "" {
+		tenant.AzureSpeechRegion = defaults.AzureSpeechRegion
+	}
+	if tenant.PineconeAPIKey == "" {
+		tenant.PineconeAPIKey = defaults.PineconeAPIKey
+	}
+	if tenant.PineconeHost == "" {
+		tenant.PineconeHost = defaults.PineconeHost
+	}
+	if tenant.PineconeNamespace == "" {
+		// Isolate each tenant's memory in its own namespace by default
+		tenant.PineconeNamespace = tenant.ID
+	}
+	if tenant.OrchestratorURL == "" {
+		tenant.OrchestratorURL = defaults.OrchestratorURL
+	}
+	if tenant.OrchestratorAPIKey == "" {
+		tenant.OrchestratorAPIKey = defaults.OrchestratorAPIKey
+	}
+	if tenant.OrchestratorAuth == nil {
+		tenant.OrchestratorAuth = defaults.OrchestratorAuth
+	}
+	if len(tenant.OrchestratorRoutes) == 0 {
+		tenant.OrchestratorRoutes = defaults.OrchestratorRoutes
+	}
+	if len(tenant.WebhookURLs) == 0 {
+		tenant.WebhookURLs = defaults.WebhookURLs
+	}
+	if tenant.WebhookSecret == "" {
+		tenant.WebhookSecret = defaults.WebhookSecret
+	}
+	if tenant.TranscriptFilters == nil {
+		tenant.TranscriptFilters = defaults.TranscriptFilters
+	}
+	if len(tenant.ProfanityWords) == 0 {
+		tenant.ProfanityWords = defaults.ProfanityWords
+	}
+}
+
+type tenantKey struct{}
+
+// WithTenant returns a context carrying the tenant authenticated for a
+// request, which Resolve returns without looking up the API key again.
+func WithTenant(ctx context.Context, tenant *models.Tenant) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// Resolve returns the tenant owning the request's API key, taken from the
+// Authorization bearer header or the api_key query parameter (browsers cannot
+// set headers on WebSocket upgrades).
+func (s *TenantStore) Resolve(r *http.Request) (*models.Tenant, error) {
+	if tenant, ok := r.Context().Value(tenantKey{}).(*models.Tenant); ok {
+		return tenant, nil
+	}
+
+	apiKey := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if apiKey == "" {
+		apiKey = r.URL.Query().Get("api_key")
+	}
+	if apiKey == "" {
+		return nil, fmt.Errorf("missing API key")
+	}
+
+	s.mu.RLock()
+	tenant, ok := s.byAPIKey[apiKey]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown API key")
+	}
+	return tenant, nil
+}
+
+// IncrementUsage bumps a tenant usage counter. Failures are logged only, usage
+// accounting must never break a session.
+func (s *TenantStore) IncrementUsage(tenantID, counter string, delta int64) {
+	if s == nil || s.redis == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	if err := s.redis.HIncrBy(ctx, tenantUsageKeyPrefix+tenantID, counter, delta).Err(); err != nil {
+		zap.L().Warn("Failed to increment tenant usage",
+			zap.String("tenant_id", tenantID), zap.String("counter", counter), zap.Error(err))
+	}
+}
+
+// Usage returns all usage counters recorded for a tenant.
+func (s *TenantStore) Usage(ctx context.Context, tenantID string) (map[string]string, error) {
+	return s.redis.HGetAll(ctx, tenantUsageKeyPrefix+tenantID).Result()
+}
+
+// RateLimiter is a token bucket used for per-session inbound message limits.
+type RateLimiter struct {
+	mu       sync.Mutex
+	rate     float64
+	burst    float64
+	tokens   float64
+	lastFill time.Time
+}
+
+// NewRateLimiter returns nil (no limiting) when rate is not positive.
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	if burst <= 0 {
+		burst = int(rate) + 1
+	}
+	return &RateLimiter{
+		rate:     rate,
+		burst:    float64(burst),
+		tokens:   float64(burst),
+		lastFill: time.Now(),
+	}
+}
+
+func (l *RateLimiter) Allow() bool {
+	if l == nil {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.tokens += now.Sub(l.lastFill).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.lastFill = now
+
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
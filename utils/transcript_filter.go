@@ -0,0 +1,217 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Categories of content a transcript filter removes.
+const (
+	FILTER_PROFANITY   = "profanity"
+	FILTER_EMAIL       = "email"
+	FILTER_CREDIT_CARD = "credit_card"
+	FILTER_PHONE       = "phone"
+)
+
+// What a filter does with a match: mask keeps a hint of it ("f***",
+// "j***@example.com", "**** **** **** 1234"), redact replaces it with a
+// placeholder ("[EMAIL]"), off leaves it alone.
+const (
+	FILTER_ACTION_MASK   = "mask"
+	FILTER_ACTION_REDACT = "redact"
+	FILTER_ACTION_OFF    = "off"
+)
+
+// Categories in the order they are applied: credit card numbers before
+// phone numbers, which their digits would also match.
+var filterCategories = []string{FILTER_EMAIL, FILTER_CREDIT_CARD, FILTER_PHONE, FILTER_PROFANITY}
+
+// defaultProfanity is extended with TRANSCRIPT_PROFANITY_WORDS or a tenant's
+// profanity_words.
+var defaultProfanity = []string{
+	"fuck", "fucking", "motherfucker", "shit", "bullshit", "bitch", "asshole",
+	"bastard", "cunt", "dick", "prick", "piss", "wanker", "twat", "slut", "whore",
+}
+
+var (
+	emailPattern      = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+	creditCardPattern = regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`)
+	phonePattern      = regexp.MustCompile(`(?:\+|\b)\d[\d\s().-]{5,}\d\b`)
+)
+
+// TranscriptFilter masks or redacts profanity and personal data (emails,
+// credit card numbers, phone numbers) in transcripts.
+type TranscriptFilter struct {
+	actions   map[string]string
+	profanity *regexp.Regexp
+}
+
+// ParseTranscriptFilters reads a policy like
+// "profanity:mask,email:redact,phone:redact,credit_card:redact".
+func ParseTranscriptFilters(value string) (map[string]string, error) {
+	policy := make(map[string]string)
+	for _, entry := range splitTerms(value) {
+		category, action, found := strings.Cut(entry, ":")
+		if !found {
+			return nil, fmt.Errorf("transcript filter %q must be category:action", entry)
+		}
+		policy[strings.TrimSpace(category)] = strings.TrimSpace(action)
+	}
+	if err := ValidateTranscriptFilters(policy); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// ValidateTranscriptFilters checks a tenant's transcript_filters policy.
+func ValidateTranscriptFilters(policy map[string]string) error {
+	for category, action := range policy {
+		if !slices.Contains(filterCategories, category) {
+			return fmt.Errorf("unknown transcript filter %q", category)
+		}
+		switch action {
+		case FILTER_ACTION_MASK, FILTER_ACTION_REDACT, FILTER_ACTION_OFF:
+		default:
+			return fmt.Errorf("transcript filter %s: unknown action %q", category, action)
+		}
+	}
+	return nil
+}
+
+// NewTranscriptFilter builds the filter of a policy, with extra profanity
+// on top of the built-in list. It returns nil when the policy filters
+// nothing.
+func NewTranscriptFilter(policy map[string]string, extraProfanity []string) *TranscriptFilter {
+	actions := make(map[string]string)
+	for category, action := range policy {
+		if action == FILTER_ACTION_MASK || action == FILTER_ACTION_REDACT {
+			actions[category] = action
+		}
+	}
+	if len(actions) == 0 {
+		return nil
+	}
+
+	filter := &TranscriptFilter{actions: actions}
+	if _, ok := actions[FILTER_PROFANITY]; ok {
+		words := make([]string, 0, len(defaultProfanity)+len(extraProfanity))
+		for _, word := range append(slices.Clone(defaultProfanity), extraProfanity...) {
+			if word = strings.TrimSpace(strings.ToLower(word)); word != "" {
+				words = append(words, regexp.QuoteMeta(word))
+			}
+		}
+		// Longest first so "motherfucker" is not cut at "fuck"
+		slices.SortFunc(words, func(a, b string) int { return len(b) - len(a) })
+		filter.profanity = regexp.MustCompile(`(?i)\b(?:` + strings.Join(words, "|") + `)(?:s|es|ed|ing|er|ers)?\b`)
+	}
+	return filter
+}
+
+// Apply returns text with the filtered content masked or redacted, and the
+// number of matches per category. A nil filter returns text unchanged.
+func (f *TranscriptFilter) Apply(text string) (string, map[string]int) {
+	if f == nil || text == "" {
+		return text, nil
+	}
+	counts := make(map[string]int)
+	for _, category := range filterCategories {
+		action, ok := f.actions[category]
+		if !ok {
+			continue
+		}
+		var pattern *regexp.Regexp
+		var valid func(string) bool
+		switch category {
+		case FILTER_EMAIL:
+			pattern = emailPattern
+		case FILTER_CREDIT_CARD:
+			pattern, valid = creditCardPattern, luhnValid
+		case FILTER_PHONE:
+			pattern, valid = phonePattern, plausiblePhone
+		case FILTER_PROFANITY:
+			pattern = f.profanity
+		}
+		text = pattern.ReplaceAllStringFunc(text, func(match string) string {
+			if valid != nil && !valid(match) {
+				return match
+			}
+			counts[category]++
+			if action == FILTER_ACTION_REDACT {
+				return "[" + strings.ToUpper(category) + "]"
+			}
+			return maskMatch(category, match)
+		})
+	}
+	return text, counts
+}
+
+func maskMatch(category, match string) string {
+	switch category {
+	case FILTER_EMAIL:
+		local, domain, _ := strings.Cut(match, "@")
+		return local[:1] + "***@" + domain
+	case FILTER_CREDIT_CARD:
+		return maskDigits(match, 4)
+	case FILTER_PHONE:
+		return maskDigits(match, 2)
+	}
+	runes := []rune(match)
+	return string(runes[0]) + strings.Repeat("*", len(runes)-1)
+}
+
+// maskDigits stars all digits but the last keep, leaving separators.
+func maskDigits(match string, keep int) string {
+	total := digitCount(match)
+	seen := 0
+	return strings.Map(func(r rune) rune {
+		if !unicode.IsDigit(r) {
+			return r
+		}
+		seen++
+		if seen > total-keep {
+			return r
+		}
+		return '*'
+	}, match)
+}
+
+func digitCount(s string) int {
+	count := 0
+	for _, r := range s {
+		if unicode.IsDigit(r) {
+			count++
+		}
+	}
+	return count
+}
+
+// plausiblePhone accepts 7 to 15 digits, the range of national and E.164
+// numbers, which leaves years and small quantities alone.
+func plausiblePhone(match string) bool {
+	digits := digitCount(match)
+	return digits >= 7 && digits <= 15
+}
+
+// luhnValid reports whether the digits of match pass the Luhn checksum of
+// payment card numbers.
+func luhnValid(match string) bool {
+	sum, double := 0, false
+	for i := len(match) - 1; i >= 0; i-- {
+		c := match[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		digit := int(c - '0')
+		if double {
+			if digit *= 2; digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+		double = !double
+	}
+	return sum%10 == 0
+}
@@ -0,0 +1,85 @@
+package utils_test
+
+import (
+	"maps"
+	"testing"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+)
+
+func TestParseTranscriptFilters(t *testing.T) {
+	for _, test := range []struct {
+		value   string
+		want    map[string]string
+		wantErr bool
+	}{
+		{value: "", want: map[string]string{}},
+		{value: "profanity:mask, email:redact", want: map[string]string{"profanity": "mask", "email": "redact"}},
+		{value: "phone:off", want: map[string]string{"phone": "off"}},
+		{value: "email", wantErr: true},
+		{value: "address:redact", wantErr: true},
+		{value: "email:hide", wantErr: true},
+	} {
+		got, err := utils.ParseTranscriptFilters(test.value)
+		if (err != nil) != test.wantErr {
+			t.Errorf("ParseTranscriptFilters(%q) error = %v, want error %v", test.value, err, test.wantErr)
+			continue
+		}
+		if err == nil && !maps.Equal(got, test.want) {
+			t.Errorf("ParseTranscriptFilters(%q) = %v, want %v", test.value, got, test.want)
+		}
+	}
+}
+
+func TestTranscriptFilterApply(t *testing.T) {
+	mask := utils.NewTranscriptFilter(map[string]string{
+		utils.FILTER_PROFANITY:   utils.FILTER_ACTION_MASK,
+		utils.FILTER_EMAIL:       utils.FILTER_ACTION_MASK,
+		utils.FILTER_CREDIT_CARD: utils.FILTER_ACTION_MASK,
+		utils.FILTER_PHONE:       utils.FILTER_ACTION_MASK,
+	}, []string{"darn"})
+	redact := utils.NewTranscriptFilter(map[string]string{
+		utils.FILTER_EMAIL:       utils.FILTER_ACTION_REDACT,
+		utils.FILTER_CREDIT_CARD: utils.FILTER_ACTION_REDACT,
+		utils.FILTER_PHONE:       utils.FILTER_ACTION_REDACT,
+	}, nil)
+
+	for _, test := range []struct {
+		name   string
+		filter *utils.TranscriptFilter
+		text   string
+		want   string
+		counts map[string]int
+	}{
+		{"email masked", mask, "mail jane.doe@example.com now", "mail j***@example.com now", map[string]int{"email": 1}},
+		{"email redacted", redact, "mail jane.doe@example.com now", "mail [EMAIL] now", map[string]int{"email": 1}},
+		// 4111 1111 1111 1111 passes the Luhn check; ...1112 does not, and has
+		// too many digits for a phone number
+		{"card masked", mask, "card 4111 1111 1111 1111", "card **** **** **** 1111", map[string]int{"credit_card": 1}},
+		{"card redacted", redact, "card 4111-1111-1111-1111", "card [CREDIT_CARD]", map[string]int{"credit_card": 1}},
+		{"card failing Luhn", redact, "order 4111111111111112", "order 4111111111111112", map[string]int{}},
+		{"phone masked", mask, "call +1 (555) 010-9999", "call +* (***) ***-**99", map[string]int{"phone": 1}},
+		{"year is no phone", redact, "back in 2019 at 10:30", "back in 2019 at 10:30", map[string]int{}},
+		{"profanity masked", mask, "What the fuck, motherfuckers", "What the f***, m************", map[string]int{"profanity": 2}},
+		{"extra profanity", mask, "Darn it", "D*** it", map[string]int{"profanity": 1}},
+		{"words containing profanity", mask, "a scrapped shitake dickens", "a scrapped shitake dickens", map[string]int{}},
+	} {
+		got, counts := test.filter.Apply(test.text)
+		if got != test.want {
+			t.Errorf("%s: Apply(%q) = %q, want %q", test.name, test.text, got, test.want)
+		}
+		if !maps.Equal(counts, test.counts) {
+			t.Errorf("%s: counts = %v, want %v", test.name, counts, test.counts)
+		}
+	}
+}
+
+func TestTranscriptFilterOff(t *testing.T) {
+	filter := utils.NewTranscriptFilter(map[string]string{utils.FILTER_EMAIL: utils.FILTER_ACTION_OFF}, nil)
+	if filter != nil {
+		t.Fatal("NewTranscriptFilter() with every category off is not nil")
+	}
+	if got, counts := filter.Apply("jane@example.com"); got != "jane@example.com" || counts != nil {
+		t.Errorf("nil filter Apply() = %q, %v, want the text unchanged", got, counts)
+	}
+}
@@ -0,0 +1,195 @@
+package utils
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
+	"github.com/redis/go-redis/v9"
+)
+
+// The keyword index of a tenant's transcripts: for every word prefix a
+// sorted set of the intention records whose transcript has a word starting
+// with it, scored by time, and the records themselves, sealed as in the
+// archive. Prefixes are stored hashed, so keys do not spell out what was
+// said.
+const (
+	transcriptTermKeyPrefix = "perceptus:transcript_index:term:"
+	transcriptDocsKeyPrefix = "perceptus:transcript_index:docs:"
+	transcriptTimeKeyPrefix = "perceptus:transcript_index:time:"
+	// maxIndexedPrefix is the longest prefix indexed, in runes; longer
+	// terms are looked up by their first runes
+	maxIndexedPrefix = 12
+	// transcriptSearchBatch is how many records are loaded at a time
+	transcriptSearchBatch = 100
+)
+
+// SearchTerms splits text into lowercase words.
+func SearchTerms(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// transcriptPrefixes returns the indexed prefixes of a transcript's words.
+func transcriptPrefixes(transcript string) []string {
+	seen := make(map[string]bool)
+	var prefixes []string
+	for _, word := range SearchTerms(transcript) {
+		runes := []rune(word)
+		for n := 1; n <= min(len(runes), maxIndexedPrefix); n++ {
+			if prefix := string(runes[:n]); !seen[prefix] {
+				seen[prefix] = true
+				prefixes = append(prefixes, prefix)
+			}
+		}
+	}
+	return prefixes
+}
+
+func transcriptTermKey(tenantID, term string) string {
+	runes := []rune(term)
+	sum := sha256.Sum256([]byte(string(runes[:min(len(runes), maxIndexedPrefix)])))
+	return transcriptTermKeyPrefix + tenantID + ":" + hex.EncodeToString(sum[:16])
+}
+
+// transcriptDocID names a record in the index; imported sessions keep the
+// IDs of their records.
+func transcriptDocID(record models.AnalysisRecord) string {
+	return record.SessionID + ":" + record.ID
+}
+
+func transcriptScore(t time.Time) float64 {
+	return float64(t.UnixMilli())
+}
+
+// indexTranscript queues the indexing of an archived intention record,
+// sealed as stored, on pipe.
+func indexTranscript(ctx context.Context, pipe redis.Pipeliner, record models.AnalysisRecord, sealed []byte) {
+	docID := transcriptDocID(record)
+	entry := redis.Z{Score: transcriptScore(record.Timestamp), Member: docID}
+	for _, prefix := range transcriptPrefixes(record.Transcript) {
+		key := transcriptTermKey(record.TenantID, prefix)
+		pipe.ZAdd(ctx, key, entry)
+		pipe.Expire(ctx, key, sessionArchiveRetention)
+	}
+	pipe.HSet(ctx, transcriptDocsKeyPrefix+record.TenantID, docID, sealed)
+	pipe.ZAdd(ctx, transcriptTimeKeyPrefix+record.TenantID, entry)
+}
+
+// unindexTranscripts queues the removal of a tenant's intention records
+// from the index on pipe.
+func unindexTranscripts(ctx context.Context, pipe redis.Pipeliner, tenantID string, records []models.AnalysisRecord) {
+	for _, record := range records {
+		if record.Kind != models.ANALYSIS_KIND_INTENTION {
+			continue
+		}
+		docID := transcriptDocID(record)
+		for _, prefix := range transcriptPrefixes(record.Transcript) {
+			pipe.ZRem(ctx, transcriptTermKey(tenantID, prefix), docID)
+		}
+		pipe.HDel(ctx, transcriptDocsKeyPrefix+tenantID, docID)
+		pipe.ZRem(ctx, transcriptTimeKeyPrefix+tenantID, docID)
+	}
+}
+
+// SearchTranscripts calls visit with the tenant's indexed intention records
+// whose transcript has, for every term, a word starting with it, newest
+// first and between from and to (unbounded when zero), until visit returns
+// false. Records whose key is unavailable are skipped.
+func SearchTranscripts(ctx context.Context, rdb *redis.Client, tenantID string, terms []string, from, to time.Time, visit func(models.AnalysisRecord) bool) error {
+	if len(terms) == 0 {
+		return nil
+	}
+	keys := make([]string, len(terms))
+	for i, term := range terms {
+		keys[i] = transcriptTermKey(tenantID, term)
+	}
+	matches, err := rdb.ZInterWithScores(ctx, &redis.ZStore{Keys: keys, Aggregate: "MAX"}).Result()
+	if err != nil {
+		return fmt.Errorf("failed to search transcript index: %w", err)
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Score > matches[j].Score })
+	var ids []string
+	for _, match := range matches {
+		if (!to.IsZero() && match.Score > transcriptScore(to)) || (!from.IsZero() && match.Score < transcriptScore(from)) {
+			continue
+		}
+		ids = append(ids, match.Member.(string))
+	}
+	for len(ids) > 0 {
+		batch := ids[:min(len(ids), transcriptSearchBatch)]
+		ids = ids[len(batch):]
+		stored, err := rdb.HMGet(ctx, transcriptDocsKeyPrefix+tenantID, batch...).Result()
+		if err != nil {
+			return fmt.Errorf("failed to load indexed transcripts: %w", err)
+		}
+		for _, item := range stored {
+			data, ok := item.(string)
+			if !ok {
+				// Purged since the lookup
+				continue
+			}
+			var record models.AnalysisRecord
+			if err := unmarshalArtifact(ctx, []byte(data), &record); err != nil {
+				continue
+			}
+			if !visit(record) {
+				return nil
+			}
+		}
+	}
+	return nil
+}
+
+// purgeTranscriptIndex drops records older than cutoff from the tenants'
+// transcript indexes and returns how many records it dropped.
+func purgeTranscriptIndex(ctx context.Context, rdb *redis.Client, cutoff time.Time) (int, error) {
+	expired := "(" + strconv.FormatFloat(transcriptScore(cutoff), 'f', -1, 64)
+	purged := 0
+	iter := rdb.Scan(ctx, 0, transcriptTimeKeyPrefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		tenantID := strings.TrimPrefix(iter.Val(), transcriptTimeKeyPrefix)
+		ids, err := rdb.ZRangeByScore(ctx, iter.Val(), &redis.ZRangeBy{Min: "-inf", Max: expired}).Result()
+		if err != nil {
+			return purged, fmt.Errorf("failed to read transcript index: %w", err)
+		}
+		if len(ids) == 0 {
+			continue
+		}
+		pipe := rdb.TxPipeline()
+		pipe.HDel(ctx, transcriptDocsKeyPrefix+tenantID, ids...)
+		members := make([]interface{}, len(ids))
+		for i, id := range ids {
+			members[i] = id
+		}
+		pipe.ZRem(ctx, iter.Val(), members...)
+		if _, err := pipe.Exec(ctx); err != nil {
+			return purged, fmt.Errorf("failed to purge transcript index: %w", err)
+		}
+		purged += len(ids)
+	}
+	if err := iter.Err(); err != nil {
+		return purged, fmt.Errorf("failed to scan transcript indexes: %w", err)
+	}
+
+	// Prefix sets expire once nothing is added to them for the retention
+	iter = rdb.Scan(ctx, 0, transcriptTermKeyPrefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		if err := rdb.ZRemRangeByScore(ctx, iter.Val(), "-inf", expired).Err(); err != nil {
+			return purged, fmt.Errorf("failed to purge transcript index: %w", err)
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return purged, fmt.Errorf("failed to scan transcript indexes: %w", err)
+	}
+	return purged, nil
+}
@@ -0,0 +1,77 @@
+package utils_test
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestTranscriptIndex(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer rdb.Close()
+
+	start := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	for i, record := range []models.AnalysisRecord{
+		{ID: "i1", SessionID: "s1", TenantID: "acme", Transcript: "Go to the charging dock"},
+		{ID: "i2", SessionID: "s1", TenantID: "acme", Transcript: "Bring me the red mug"},
+		{ID: "i3", SessionID: "s2", TenantID: "acme", Transcript: "Is the charger in the kitchen?"},
+		{ID: "i4", SessionID: "s3", TenantID: "globex", Transcript: "Find the charger"},
+	} {
+		record.Kind = models.ANALYSIS_KIND_INTENTION
+		record.Result = json.RawMessage(`{}`)
+		record.Timestamp = start.Add(time.Duration(i) * time.Minute)
+		if err := utils.ArchiveAnalysis(ctx, rdb, record); err != nil {
+			t.Fatalf("ArchiveAnalysis() error = %v", err)
+		}
+	}
+
+	search := func(query string, from, to time.Time) []string {
+		t.Helper()
+		var ids []string
+		err := utils.SearchTranscripts(ctx, rdb, "acme", utils.SearchTerms(query), from, to, func(record models.AnalysisRecord) bool {
+			ids = append(ids, record.ID)
+			return true
+		})
+		if err != nil {
+			t.Fatalf("SearchTranscripts(%q) error = %v", query, err)
+		}
+		return ids
+	}
+	for _, test := range []struct {
+		query    string
+		from, to time.Time
+		want     []string
+	}{
+		{query: "charg", want: []string{"i3", "i1"}},
+		{query: "CHARGER kitchen", want: []string{"i3"}},
+		{query: "red mug", want: []string{"i2"}},
+		{query: "red dock", want: nil},
+		{query: "vacuum", want: nil},
+		{query: "charg", to: start.Add(time.Minute), want: []string{"i1"}},
+		{query: "charg", from: start.Add(time.Minute), want: []string{"i3"}},
+	} {
+		if got := search(test.query, test.from, test.to); !slices.Equal(got, test.want) {
+			t.Errorf("search %q from %v to %v = %v, want %v", test.query, test.from, test.to, got, test.want)
+		}
+	}
+
+	records, err := utils.LoadSessionAnalyses(ctx, rdb, "s2")
+	if err != nil {
+		t.Fatalf("LoadSessionAnalyses() error = %v", err)
+	}
+	if err := utils.EraseSessionArchive(ctx, rdb, "acme", "s2", records, &models.DeletionReport{}); err != nil {
+		t.Fatalf("EraseSessionArchive() error = %v", err)
+	}
+	if got := search("charg", time.Time{}, time.Time{}); !slices.Equal(got, []string{"i1"}) {
+		t.Errorf("search after erasing s2 = %v, want [i1]", got)
+	}
+}
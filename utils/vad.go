@@ -0,0 +1,288 @@
+package utils
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+)
+
+const vadFrameDuration = 20 * time.Millisecond
+
+// minVADSampleRate is the lowest rate voice activity detection accepts; the
+// voice band does not fit below it.
+const minVADSampleRate = 8000
+
+// Engines of VAD_ENGINE.
+const (
+	// VAD_ENGINE_AUTO uses WebRTC VAD where it is compiled in and supports
+	// the sample rate, the energy detector otherwise
+	VAD_ENGINE_AUTO   = "auto"
+	VAD_ENGINE_WEBRTC = "webrtc"
+	VAD_ENGINE_ENERGY = "energy"
+)
+
+// webrtcVADRates are the sample rates WebRTC VAD classifies.
+var webrtcVADRates = map[int]bool{8000: true, 16000: true, 32000: true, 48000: true}
+
+// Speech must exceed the noise floor by this many dB, by VAD_MODE: higher
+// modes are more aggressive about treating audio as non-speech, as with
+// WebRTC VAD
+var vadThresholdsDB = [4]float64{4, 6, 9, 12}
+
+// Frames quieter than this never count as speech, so digital silence and a
+// muted microphone stay closed whatever the noise floor
+const vadMinLevelDBFS = -55
+
+// VADSettings configure voice activity detection.
+type VADSettings struct {
+	Enabled bool
+	// Engine is a VAD_ENGINE_* value, empty meaning VAD_ENGINE_AUTO
+	Engine string
+	// Mode 0-3 trades missed speech for fewer false triggers
+	Mode int
+	// Onset is how long speech must last before the gate opens; the
+	// PreRoll before it is sent too, so word onsets are not cut
+	Onset   time.Duration
+	PreRoll time.Duration
+	// Hangover keeps the gate open through pauses between words
+	Hangover time.Duration
+}
+
+// VADSettingsFromConfig reads VAD_ENABLED, VAD_ENGINE, VAD_MODE, VAD_ONSET,
+// VAD_PREROLL and VAD_HANGOVER.
+func VADSettingsFromConfig(cfg *Config) VADSettings {
+	return VADSettings{
+		Enabled:  cfg.VADEnabled,
+		Engine:   cfg.VADEngine,
+		Mode:     min(max(cfg.VADMode, 0), 3),
+		Onset:    cfg.VADOnset,
+		PreRoll:  cfg.VADPreroll,
+		Hangover: cfg.VADHangover,
+	}
+}
+
+// speechClassifier tells whether a 20ms frame of 16-bit little-endian mono
+// PCM holds speech.
+type speechClassifier interface {
+	classify(frame []byte) bool
+}
+
+// VoiceActivityDetector gates 16-bit little-endian mono PCM so only speech
+// reaches the STT provider. Each 20ms frame is classified by WebRTC VAD or
+// the energy detector. The gate opens once speech lasts for the onset,
+// releasing the pre-roll buffered before it, and closes after the hangover
+// passes without speech. onChange is called on every transition, in order
+// with the audio returned by Process.
+type VoiceActivityDetector struct {
+	frameBytes     int
+	classifier     speechClassifier
+	onsetFrames    int
+	prerollFrames  int
+	hangoverFrames int
+	onChange       func(speaking bool)
+
+	speaking  bool
+	voicedRun int
+	silentRun int
+	preroll   [][]byte
+	carry     []byte
+}
+
+// NewVoiceActivityDetector gates audio at sampleRate (0 means 16000), which
+// must be at least 8000.
+func NewVoiceActivityDetector(sampleRate int, settings VADSettings, onChange func(speaking bool)) (*VoiceActivityDetector, error) {
+	if sampleRate == 0 {
+		sampleRate = 16000
+	}
+	if sampleRate < minVADSampleRate {
+		return nil, fmt.Errorf("voice activity detection needs a sample rate of at least %d Hz, got %d", minVADSampleRate, sampleRate)
+	}
+	mode := min(max(settings.Mode, 0), 3)
+
+	var classifier speechClassifier
+	switch settings.Engine {
+	case "", VAD_ENGINE_AUTO:
+		if webrtcVADAvailable && webrtcVADRates[sampleRate] {
+			var err error
+			if classifier, err = newWebRTCClassifier(sampleRate, mode); err != nil {
+				return nil, err
+			}
+		} else {
+			classifier = newEnergyClassifier(sampleRate, mode)
+		}
+	case VAD_ENGINE_WEBRTC:
+		if !webrtcVADRates[sampleRate] {
+			return nil, fmt.Errorf("WebRTC VAD supports 8000, 16000, 32000 and 48000 Hz audio, got %d", sampleRate)
+		}
+		var err error
+		if classifier, err = newWebRTCClassifier(sampleRate, mode); err != nil {
+			return nil, err
+		}
+	case VAD_ENGINE_ENERGY:
+		classifier = newEnergyClassifier(sampleRate, mode)
+	default:
+		return nil, fmt.Errorf("unknown VAD_ENGINE %q", settings.Engine)
+	}
+
+	frames := func(d time.Duration) int {
+		return int((d + vadFrameDuration - 1) / vadFrameDuration)
+	}
+	return &VoiceActivityDetector{
+		frameBytes:     sampleRate * int(vadFrameDuration/time.Millisecond) / 1000 * 2,
+		classifier:     classifier,
+		onsetFrames:    max(frames(settings.Onset), 1),
+		prerollFrames:  frames(settings.PreRoll),
+		hangoverFrames: max(frames(settings.Hangover), 1),
+		onChange:       onChange,
+	}, nil
+}
+
+// Speaking reports whether the gate is open.
+func (v *VoiceActivityDetector) Speaking() bool {
+	return v.speaking
+}
+
+// Process returns the audio to forward: nothing while the gate is closed,
+// the pre-roll and following frames once it opens. Audio not filling a
+// frame is kept for the next call.
+func (v *VoiceActivityDetector) Process(data []byte) []byte {
+	if len(v.carry) > 0 {
+		data = append(v.carry, data...)
+		v.carry = nil
+	}
+
+	var out []byte
+	for len(data) >= v.frameBytes {
+		frame := data[:v.frameBytes:v.frameBytes]
+		data = data[v.frameBytes:]
+		voiced := v.classifier.classify(frame)
+
+		if !v.speaking {
+			v.preroll = append(v.preroll, append([]byte(nil), frame...))
+			if len(v.preroll) > v.prerollFrames+v.onsetFrames {
+				v.preroll = v.preroll[1:]
+			}
+			if !voiced {
+				v.voicedRun = 0
+				continue
+			}
+			v.voicedRun++
+			if v.voicedRun < v.onsetFrames {
+				continue
+			}
+			v.speaking, v.silentRun = true, 0
+			if v.onChange != nil {
+				v.onChange(true)
+			}
+			for _, buffered := range v.preroll {
+				out = append(out, buffered...)
+			}
+			v.preroll = nil
+			continue
+		}
+
+		out = append(out, frame...)
+		if voiced {
+			v.silentRun = 0
+			continue
+		}
+		v.silentRun++
+		if v.silentRun >= v.hangoverFrames {
+			v.speaking, v.voicedRun = false, 0
+			if v.onChange != nil {
+				v.onChange(false)
+			}
+		}
+	}
+	if len(data) > 0 {
+		v.carry = append([]byte(nil), data...)
+	}
+	return out
+}
+
+// energyClassifier band-limits each frame to the voice band and compares it
+// with a tracked noise floor.
+type energyClassifier struct {
+	thresholdDB float64
+	// Voice band filter: high-pass then low-pass biquads
+	highPass, lowPass biquad
+	noiseFloor        float64
+}
+
+func newEnergyClassifier(sampleRate, mode int) *energyClassifier {
+	return &energyClassifier{
+		thresholdDB: vadThresholdsDB[mode],
+		highPass:    newHighPass(200, float64(sampleRate)),
+		lowPass:     newLowPass(math.Min(3800, 0.45*float64(sampleRate)), float64(sampleRate)),
+	}
+}
+
+// classify reports whether a frame holds speech and updates the noise
+// floor.
+func (v *energyClassifier) classify(frame []byte) bool {
+	var energy float64
+	samples := len(frame) / 2
+	for i := 0; i < samples; i++ {
+		x := float64(int16(binary.LittleEndian.Uint16(frame[2*i:]))) / 32768
+		y := v.lowPass.process(v.highPass.process(x))
+		energy += y * y
+	}
+	rms := math.Sqrt(energy / float64(samples))
+	level := 20 * math.Log10(math.Max(rms, 1e-9))
+
+	if v.noiseFloor == 0 {
+		v.noiseFloor = rms
+	}
+	floor := 20 * math.Log10(math.Max(v.noiseFloor, 1e-9))
+	voiced := level > vadMinLevelDBFS && level-floor > v.thresholdDB
+
+	// Follow quieter backgrounds quickly and louder ones slowly; speech
+	// frames still raise the floor a little so a steady new noise (a fan
+	// turning on) cannot hold the gate open
+	switch {
+	case rms < v.noiseFloor:
+		v.noiseFloor = 0.8*v.noiseFloor + 0.2*rms
+	case !voiced:
+		v.noiseFloor = 0.95*v.noiseFloor + 0.05*rms
+	default:
+		v.noiseFloor = 0.998*v.noiseFloor + 0.002*rms
+	}
+	return voiced
+}
+
+// biquad is a second-order IIR filter section.
+type biquad struct {
+	b0, b1, b2, a1, a2 float64
+	x1, x2, y1, y2     float64
+}
+
+func (f *biquad) process(x float64) float64 {
+	y := f.b0*x + f.b1*f.x1 + f.b2*f.x2 - f.a1*f.y1 - f.a2*f.y2
+	f.x2, f.x1 = f.x1, x
+	f.y2, f.y1 = f.y1, y
+	return y
+}
+
+// newHighPass and newLowPass compute Butterworth (Q = 1/sqrt(2)) sections.
+func newHighPass(cutoff, sampleRate float64) biquad {
+	w0 := 2 * math.Pi * cutoff / sampleRate
+	alpha := math.Sin(w0) / math.Sqrt2
+	cos := math.Cos(w0)
+	a0 := 1 + alpha
+	return biquad{
+		b0: (1 + cos) / 2 / a0, b1: -(1 + cos) / a0, b2: (1 + cos) / 2 / a0,
+		a1: -2 * cos / a0, a2: (1 - alpha) / a0,
+	}
+}
+
+func newLowPass(cutoff, sampleRate float64) biquad {
+	w0 := 2 * math.Pi * cutoff / sampleRate
+	alpha := math.Sin(w0) / math.Sqrt2
+	cos := math.Cos(w0)
+	a0 := 1 + alpha
+	return biquad{
+		b0: (1 - cos) / 2 / a0, b1: (1 - cos) / a0, b2: (1 - cos) / 2 / a0,
+		a1: -2 * cos / a0, a2: (1 - alpha) / a0,
+	}
+}
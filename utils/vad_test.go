@@ -0,0 +1,84 @@
+package utils_test
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+)
+
+// pcm returns d of 16-bit mono PCM at rate: a 440Hz tone at amplitude, or
+// silence at 0.
+func pcm(rate int, d time.Duration, amplitude float64) []byte {
+	samples := int(int64(rate) * int64(d) / int64(time.Second))
+	data := make([]byte, 2*samples)
+	for i := 0; i < samples; i++ {
+		x := amplitude * math.Sin(2*math.Pi*440*float64(i)/float64(rate))
+		binary.LittleEndian.PutUint16(data[2*i:], uint16(int16(x*32767)))
+	}
+	return data
+}
+
+func TestVADRejectsInvalidSampleRates(t *testing.T) {
+	settings := utils.VADSettings{Engine: utils.VAD_ENGINE_ENERGY, Mode: 2}
+	for _, test := range []struct {
+		rate    int
+		wantErr bool
+	}{
+		{0, false},
+		{8000, false},
+		{16000, false},
+		{44100, false},
+		{49, true},
+		{99, true},
+		{7999, true},
+		{-16000, true},
+	} {
+		_, err := utils.NewVoiceActivityDetector(test.rate, settings, nil)
+		if (err != nil) != test.wantErr {
+			t.Errorf("NewVoiceActivityDetector(%d) error = %v, want error %v", test.rate, err, test.wantErr)
+		}
+	}
+	if _, err := utils.NewVoiceActivityDetector(16000, utils.VADSettings{Engine: "silero"}, nil); err == nil {
+		t.Error("NewVoiceActivityDetector() with an unknown engine succeeded")
+	}
+	if _, err := utils.NewVoiceActivityDetector(44100, utils.VADSettings{Engine: utils.VAD_ENGINE_WEBRTC}, nil); err == nil {
+		t.Error("NewVoiceActivityDetector(44100) with WebRTC VAD succeeded")
+	}
+}
+
+func TestEnergyVADGatesSpeech(t *testing.T) {
+	var changes []bool
+	vad, err := utils.NewVoiceActivityDetector(16000, utils.VADSettings{
+		Engine:   utils.VAD_ENGINE_ENERGY,
+		Mode:     2,
+		Onset:    60 * time.Millisecond,
+		PreRoll:  100 * time.Millisecond,
+		Hangover: 200 * time.Millisecond,
+	}, func(speaking bool) { changes = append(changes, speaking) })
+	if err != nil {
+		t.Fatalf("NewVoiceActivityDetector() error = %v", err)
+	}
+
+	if out := vad.Process(pcm(16000, time.Second, 0.0005)); len(out) != 0 || vad.Speaking() {
+		t.Fatalf("quiet audio: got %d bytes out, speaking %v, want nothing", len(out), vad.Speaking())
+	}
+	speech := pcm(16000, 500*time.Millisecond, 0.3)
+	out := vad.Process(speech)
+	if !vad.Speaking() {
+		t.Fatal("gate closed during a loud tone")
+	}
+	// The pre-roll before the onset is sent with the speech
+	if len(out) <= len(speech) {
+		t.Errorf("got %d bytes out, want the %d speech bytes and the pre-roll", len(out), len(speech))
+	}
+	vad.Process(pcm(16000, time.Second, 0.0005))
+	if vad.Speaking() {
+		t.Error("gate still open after the hangover")
+	}
+	if len(changes) != 2 || !changes[0] || changes[1] {
+		t.Errorf("changes = %v, want [true false]", changes)
+	}
+}
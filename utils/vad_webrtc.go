@@ -0,0 +1,53 @@
+//go:build fvad
+
+package utils
+
+/*
+#cgo LDFLAGS: -lfvad
+#include <stdint.h>
+#include <fvad.h>
+*/
+import "C"
+
+import (
+	"fmt"
+	"runtime"
+	"unsafe"
+)
+
+const webrtcVADAvailable = true
+
+// webrtcClassifier classifies frames with libfvad, the WebRTC voice activity
+// detector extracted from the WebRTC native code. Its mode is VAD_MODE.
+type webrtcClassifier struct {
+	inst *C.Fvad
+}
+
+func newWebRTCClassifier(sampleRate, mode int) (speechClassifier, error) {
+	inst := C.fvad_new()
+	if inst == nil {
+		return nil, fmt.Errorf("failed to create WebRTC VAD")
+	}
+	if C.fvad_set_sample_rate(inst, C.int(sampleRate)) != 0 {
+		C.fvad_free(inst)
+		return nil, fmt.Errorf("WebRTC VAD does not support %d Hz audio", sampleRate)
+	}
+	if C.fvad_set_mode(inst, C.int(mode)) != 0 {
+		C.fvad_free(inst)
+		return nil, fmt.Errorf("WebRTC VAD does not support mode %d", mode)
+	}
+	// Detectors are dropped with the audio chain of a session, which has no
+	// close, so the instance is freed with the classifier
+	c := &webrtcClassifier{inst: inst}
+	runtime.SetFinalizer(c, func(c *webrtcClassifier) { C.fvad_free(c.inst) })
+	return c, nil
+}
+
+// classify passes the frame's samples as they are: PCM is little-endian, as
+// are the hosts the server is built for.
+func (c *webrtcClassifier) classify(frame []byte) bool {
+	samples := (*C.int16_t)(unsafe.Pointer(&frame[0]))
+	voiced := C.fvad_process(c.inst, samples, C.size_t(len(frame)/2)) == 1
+	runtime.KeepAlive(c)
+	return voiced
+}
@@ -0,0 +1,13 @@
+//go:build !fvad
+
+package utils
+
+import "fmt"
+
+const webrtcVADAvailable = false
+
+// newWebRTCClassifier fails in builds without libfvad, which needs cgo;
+// build with -tags fvad to enable it.
+func newWebRTCClassifier(sampleRate, mode int) (speechClassifier, error) {
+	return nil, fmt.Errorf("built without WebRTC VAD support, rebuild with -tags fvad")
+}
@@ -0,0 +1,267 @@
+//go:build vosk
+
+package utils
+
+/*
+#cgo LDFLAGS: -lvosk
+#include <stdlib.h>
+#include <vosk_api.h>
+*/
+import "C"
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/testmode"
+	"go.uber.org/zap"
+)
+
+// Chunks of audio waiting for the recognizer before new ones are dropped
+const voskQueueSize = 64
+
+// Models take seconds and hundreds of megabytes to load, so each is loaded
+// once and shared by the recognizers of all sessions.
+var (
+	voskModelsMu sync.Mutex
+	voskModels   = make(map[string]*C.VoskModel)
+	voskLogOnce  sync.Once
+)
+
+func loadVoskModel(path string) (*C.VoskModel, error) {
+	voskLogOnce.Do(func() {
+		C.vosk_set_log_level(C.int(CurrentConfig().VoskLogLevel))
+	})
+
+	voskModelsMu.Lock()
+	defer voskModelsMu.Unlock()
+	if model, ok := voskModels[path]; ok {
+		return model, nil
+	}
+	cPath := C.CString(path)
+	defer C.free(unsafe.Pointer(cPath))
+	model := C.vosk_model_new(cPath)
+	if model == nil {
+		return nil, fmt.Errorf("failed to load Vosk model from %s", path)
+	}
+	voskModels[path] = model
+	zap.L().Info("Loaded Vosk model", zap.String("path", path))
+	return model, nil
+}
+
+// VoskClient recognizes speech on the machine itself with Vosk (Kaldi), for
+// robots that run the server on their onboard computer without a reliable
+// network. Recognition runs on a worker goroutine per stream. Vosk has no
+// utterance end event, no formatting and a fixed endpointer: a final result
+// ends a segment, END_OF_SPEECH follows through scheduleEndOfSpeech, and
+// smart format, keyterms and keywords are ignored.
+type VoskClient struct {
+	sttStream
+	modelPath  string
+	recognizer *C.VoskRecognizer
+
+	audio    chan []byte
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+
+	// dropping is set while the recognizer falls behind
+	dropping atomic.Bool
+}
+
+type voskResult struct {
+	Text    string `json:"text"`
+	Partial string `json:"partial"`
+	Result  []struct {
+		Conf float64 `json:"conf"`
+	} `json:"result"`
+}
+
+func NewVoskClient(config STTStreamConfig) (*VoskClient, error) {
+	modelPath := CurrentConfig().VoskModelPath
+	if modelPath == "" {
+		return nil, fmt.Errorf("VOSK_MODEL_PATH not configured")
+	}
+	if config.Format.Encoding != AudioEncodingLinear16 {
+		return nil, fmt.Errorf("Vosk requires linear16 audio, got %q", config.Format.Encoding)
+	}
+	if config.Format.SampleRate == 0 {
+		return nil, fmt.Errorf("Vosk requires AUDIO_SAMPLE_RATE")
+	}
+	if config.Options.SmartFormat || len(plainTerms(config.Options)) > 0 {
+		zap.L().Debug("Vosk ignores smart format, keyterms and keywords")
+	}
+
+	return &VoskClient{
+		sttStream: newSTTStream(STT_PROVIDER_VOSK, config),
+		modelPath: modelPath,
+		audio:     make(chan []byte, voskQueueSize),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}, nil
+}
+
+func (c *VoskClient) Connect() bool {
+	if err := testmode.Inject(context.Background(), testmode.TARGET_STT); err != nil {
+		zap.L().Error("Failed to start Vosk recognizer", zap.Error(err))
+		c.markDisconnected()
+		return false
+	}
+	model, err := loadVoskModel(c.modelPath)
+	if err != nil {
+		zap.L().Error("Failed to start Vosk recognizer", zap.Error(err))
+		c.markDisconnected()
+		return false
+	}
+	c.recognizer = C.vosk_recognizer_new(model, C.float(c.config.Format.SampleRate))
+	if c.recognizer == nil {
+		zap.L().Error("Failed to start Vosk recognizer", zap.Int("sample_rate", c.config.Format.SampleRate))
+		c.markDisconnected()
+		return false
+	}
+	// Word results carry the confidences the threshold applies to
+	C.vosk_recognizer_set_words(c.recognizer, 1)
+
+	go c.recognize()
+	return true
+}
+
+// recognize feeds queued audio to the recognizer until the stream is
+// closed; a nil chunk asks for the final result of the audio so far.
+func (c *VoskClient) recognize() {
+	defer close(c.done)
+	for {
+		select {
+		case <-c.stop:
+			// Recognize what was queued before closing
+			for len(c.audio) > 0 {
+				if !c.accept(<-c.audio) {
+					return
+				}
+			}
+			c.deliver(C.vosk_recognizer_final_result(c.recognizer))
+			return
+		case chunk := <-c.audio:
+			if !c.accept(chunk) {
+				return
+			}
+		}
+	}
+}
+
+// accept recognizes a chunk and reports whether the recognizer still works.
+func (c *VoskClient) accept(chunk []byte) bool {
+	if chunk == nil {
+		c.deliver(C.vosk_recognizer_final_result(c.recognizer))
+		return true
+	}
+	switch C.vosk_recognizer_accept_waveform(c.recognizer, (*C.char)(unsafe.Pointer(&chunk[0])), C.int(len(chunk))) {
+	case 1:
+		c.deliver(C.vosk_recognizer_result(c.recognizer))
+	case 0:
+		c.partial(C.vosk_recognizer_partial_result(c.recognizer))
+	default:
+		zap.L().Error("Vosk failed to process audio")
+		c.markDisconnected()
+		return false
+	}
+	return true
+}
+
+// deliver emits a final result. The returned string is owned by the
+// recognizer.
+func (c *VoskClient) deliver(raw *C.char) {
+	var result voskResult
+	if err := json.Unmarshal([]byte(C.GoString(raw)), &result); err != nil {
+		zap.L().Warn("Invalid Vosk result", zap.Error(err))
+		return
+	}
+	if strings.TrimSpace(result.Text) == "" {
+		return
+	}
+
+	confidence := -1.0
+	if len(result.Result) > 0 {
+		confidence = 0
+		for _, word := range result.Result {
+			confidence += word.Conf
+		}
+		confidence /= float64(len(result.Result))
+	}
+	c.emit(result.Text, confidence)
+	c.scheduleEndOfSpeech()
+}
+
+// partial postpones the end of speech while words keep coming.
+func (c *VoskClient) partial(raw *C.char) {
+	var result voskResult
+	if err := json.Unmarshal([]byte(C.GoString(raw)), &result); err != nil || result.Partial == "" {
+		return
+	}
+	c.cancelEndOfSpeech()
+	if c.config.Options.InterimResults {
+		zap.L().Debug("Interim transcript", zap.String("transcript", result.Partial))
+	}
+}
+
+// Send queues audio for the recognizer. When the onboard computer cannot
+// keep up, audio is dropped rather than delaying the session.
+func (c *VoskClient) Send(data []byte) error {
+	if !c.IsConnected() {
+		return fmt.Errorf("vosk stream not connected")
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	select {
+	case c.audio <- append([]byte(nil), data...):
+		c.dropping.Store(false)
+	case <-c.stop:
+		return fmt.Errorf("vosk stream closed")
+	default:
+		if !c.dropping.Swap(true) {
+			zap.L().Warn("Vosk recognizer falling behind, dropping audio")
+		}
+	}
+	return nil
+}
+
+// KeepAlive has nothing to ping; it reports whether the recognizer runs.
+func (c *VoskClient) KeepAlive() error {
+	if !c.IsConnected() {
+		return fmt.Errorf("vosk stream not connected")
+	}
+	return nil
+}
+
+// Finalize asks for the final result once the queued audio is recognized.
+func (c *VoskClient) Finalize() error {
+	if !c.IsConnected() {
+		return fmt.Errorf("vosk stream not connected")
+	}
+	select {
+	case c.audio <- nil:
+		return nil
+	case <-c.done:
+		return fmt.Errorf("vosk stream closed")
+	}
+}
+
+// Close flushes the final result, stops the worker and frees the
+// recognizer.
+func (c *VoskClient) Close() {
+	c.cancelEndOfSpeech()
+	c.stopOnce.Do(func() { close(c.stop) })
+	if c.recognizer != nil {
+		<-c.done
+		C.vosk_recognizer_free(c.recognizer)
+		c.recognizer = nil
+	}
+	c.markDisconnected()
+}
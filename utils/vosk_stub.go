@@ -0,0 +1,11 @@
+//go:build !vosk
+
+package utils
+
+import "fmt"
+
+// NewVoskClient fails in builds without Vosk, which needs cgo and libvosk;
+// build with -tags vosk to enable it.
+func NewVoskClient(config STTStreamConfig) (SpeechToText, error) {
+	return nil, fmt.Errorf("built without Vosk support, rebuild with -tags vosk")
+}
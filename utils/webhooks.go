@@ -0,0 +1,221 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const (
+	WEBHOOK_SESSION_STARTED = "session_started"
+	WEBHOOK_SESSION_ENDED   = "session_ended"
+	WEBHOOK_SESSION_ERROR   = "session_error"
+)
+
+const (
+	webhookEventHeader    = "X-Perceptus-Event"
+	webhookDeliveryHeader = "X-Perceptus-Delivery"
+)
+
+var ErrWebhookQueueFull = errors.New("webhook queue full")
+
+// WebhookEvent is the body POSTed to webhook URLs. ID is stable across
+// retries so receivers can deduplicate deliveries.
+type WebhookEvent struct {
+	ID        string    `json:"id"`
+	Event     string    `json:"event"`
+	TenantID  string    `json:"tenant_id"`
+	SessionID string    `json:"session_id"`
+	Timestamp time.Time `json:"timestamp"`
+	// Metadata the session was started with
+	Metadata map[string]string `json:"metadata,omitempty"`
+	Data     interface{}       `json:"data"`
+}
+
+type webhookDelivery struct {
+	url     string
+	secret  string
+	event   WebhookEvent
+	body    []byte
+	attempt int
+}
+
+// WebhookDispatcher delivers signed webhook events off the request path.
+// Failed deliveries (network errors, 429 and 5xx) are retried with
+// exponential backoff; other 4xx responses are not retried.
+type WebhookDispatcher struct {
+	queue       chan webhookDelivery
+	http        *http.Client
+	maxAttempts int
+	backoff     time.Duration
+
+	// pending counts deliveries queued, in flight or waiting to retry
+	pending sync.WaitGroup
+	mu      sync.RWMutex
+	closed  bool
+}
+
+// NewWebhookDispatcherFromConfig reads WEBHOOK_QUEUE_SIZE, WEBHOOK_WORKERS,
+// WEBHOOK_TIMEOUT, WEBHOOK_MAX_ATTEMPTS and WEBHOOK_RETRY_BACKOFF.
+func NewWebhookDispatcherFromConfig(cfg *Config) *WebhookDispatcher {
+	return NewWebhookDispatcher(
+		cfg.WebhookQueueSize,
+		cfg.WebhookWorkers,
+		cfg.WebhookTimeout,
+		cfg.WebhookMaxAttempts,
+		cfg.WebhookRetryBackoff,
+	)
+}
+
+func NewWebhookDispatcher(queueSize, workers int, timeout time.Duration, maxAttempts int, backoff time.Duration) *WebhookDispatcher {
+	if backoff <= 0 {
+		backoff = time.Second
+	}
+	d := &WebhookDispatcher{
+		queue:       make(chan webhookDelivery, max(queueSize, 1)),
+		http:        &http.Client{Timeout: timeout},
+		maxAttempts: max(maxAttempts, 1),
+		backoff:     backoff,
+	}
+	for i := 0; i < max(workers, 1); i++ {
+		go d.run()
+	}
+	return d
+}
+
+// Send queues event for every webhook URL of the tenant without blocking.
+func (d *WebhookDispatcher) Send(tenant *models.Tenant, event WebhookEvent) {
+	if len(tenant.WebhookURLs) == 0 {
+		return
+	}
+	if event.ID == "" {
+		event.ID = uuid.New().String()
+	}
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+	event.TenantID = tenant.ID
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		zap.L().Error("Failed to encode webhook event", zap.String("event", event.Event), zap.Error(err))
+		return
+	}
+	for _, url := range tenant.WebhookURLs {
+		d.enqueue(webhookDelivery{url: url, secret: tenant.WebhookSecret, event: event, body: body, attempt: 1}, true)
+	}
+}
+
+// enqueue queues a delivery; fresh deliveries are added to pending, retries
+// already count and are still accepted while Close waits.
+func (d *WebhookDispatcher) enqueue(delivery webhookDelivery, fresh bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if fresh {
+		if d.closed {
+			return
+		}
+		d.pending.Add(1)
+	}
+	select {
+	case d.queue <- delivery:
+	default:
+		d.drop(delivery, ErrWebhookQueueFull)
+	}
+}
+
+func (d *WebhookDispatcher) drop(delivery webhookDelivery, err error) {
+	zap.L().Warn("Dropped webhook delivery",
+		zap.String("event", delivery.event.Event), zap.String("delivery_id", delivery.event.ID),
+		zap.String("url", delivery.url), zap.Int("attempts", delivery.attempt), zap.Error(err))
+	d.pending.Done()
+}
+
+// Close stops accepting events and waits up to timeout for queued and
+// retrying deliveries to finish.
+func (d *WebhookDispatcher) Close(timeout time.Duration) {
+	d.mu.Lock()
+	d.closed = true
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.pending.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		zap.L().Warn("Webhook deliveries still pending at shutdown")
+	}
+}
+
+func (d *WebhookDispatcher) run() {
+	for delivery := range d.queue {
+		retry, err := d.deliver(delivery)
+		switch {
+		case err == nil:
+			d.pending.Done()
+		case retry && delivery.attempt < d.maxAttempts:
+			wait := d.backoff << (delivery.attempt - 1)
+			wait += time.Duration(rand.Int63n(int64(wait)/2 + 1))
+			zap.L().Info("Retrying webhook delivery",
+				zap.String("event", delivery.event.Event), zap.String("url", delivery.url),
+				zap.Int("attempt", delivery.attempt), zap.Duration("backoff", wait), zap.Error(err))
+			delivery.attempt++
+			time.AfterFunc(wait, func() { d.enqueue(delivery, false) })
+		default:
+			d.drop(delivery, err)
+		}
+	}
+}
+
+// deliver POSTs the event once and reports whether a failure is retryable.
+func (d *WebhookDispatcher) deliver(delivery webhookDelivery) (bool, error) {
+	// WEBHOOK_TIMEOUT=0 waits as long as the receiver takes
+	ctx := context.Background()
+	if d.http.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.http.Timeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.url, bytes.NewReader(delivery.body))
+	if err != nil {
+		return false, fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "perceptus-webhooks/"+Version)
+	req.Header.Set(webhookEventHeader, delivery.event.Event)
+	req.Header.Set(webhookDeliveryHeader, delivery.event.ID)
+	req.Header.Set(hmacTimestampHeader, timestamp)
+	if delivery.secret != "" {
+		req.Header.Set(defaultHMACHeader, "sha256="+SignOrchestratorBody(delivery.secret, timestamp, delivery.body))
+	}
+
+	resp, err := d.http.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("failed to call webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError
+	return retry, fmt.Errorf("webhook returned %s", resp.Status)
+}
@@ -0,0 +1,32 @@
+package utils_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
+	"github.com/Perceptus-Labs/perceptus-go-sdk/utils"
+)
+
+func TestWebhookWithoutTimeoutIsDelivered(t *testing.T) {
+	received := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Get("X-Perceptus-Event")
+	}))
+	defer server.Close()
+
+	dispatcher := utils.NewWebhookDispatcher(1, 1, 0, 1, time.Millisecond)
+	dispatcher.Send(&models.Tenant{ID: "acme", WebhookURLs: []string{server.URL}}, utils.WebhookEvent{Event: utils.WEBHOOK_SESSION_STARTED})
+	dispatcher.Close(5 * time.Second)
+
+	select {
+	case event := <-received:
+		if event != utils.WEBHOOK_SESSION_STARTED {
+			t.Errorf("event = %q, want %q", event, utils.WEBHOOK_SESSION_STARTED)
+		}
+	default:
+		t.Error("webhook with WEBHOOK_TIMEOUT=0 was not delivered")
+	}
+}
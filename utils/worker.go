@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"os"
+	"runtime"
+	"sync"
+
+	"github.com/Perceptus-Labs/perceptus-go-sdk/models"
+	"github.com/google/uuid"
+)
+
+// Version is the server release, set at build time with
+// -ldflags "-X github.com/Perceptus-Labs/perceptus-go-sdk/utils.Version=v1.2.3"
+var Version = "dev"
+
+var (
+	workerOnce       sync.Once
+	workerInstanceID string
+
+	componentsMu sync.RWMutex
+	components   = map[string]string{
+		"go": runtime.Version(),
+	}
+)
+
+// InstanceID returns INSTANCE_ID, or the hostname with a random suffix.
+func InstanceID() string {
+	workerOnce.Do(func() {
+		workerInstanceID = CurrentConfig().InstanceID
+		if workerInstanceID == "" {
+			hostname, _ := os.Hostname()
+			workerInstanceID = hostname + "-" + uuid.New().String()[:8]
+		}
+	})
+	return workerInstanceID
+}
+
+// SetComponentVersion records the version of a pipeline component.
+func SetComponentVersion(name, version string) {
+	componentsMu.Lock()
+	defer componentsMu.Unlock()
+	components[name] = version
+}
+
+// Worker returns the identity of this server instance.
+func Worker() models.WorkerInfo {
+	componentsMu.RLock()
+	defer componentsMu.RUnlock()
+
+	snapshot := make(map[string]string, len(components))
+	for name, version := range components {
+		snapshot[name] = version
+	}
+	return models.WorkerInfo{
+		ServerVersion: Version,
+		InstanceID:    InstanceID(),
+		Components:    snapshot,
+	}
+}